@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"vinzhub-rest-api/internal/bootreport"
+)
+
+// TestBootProcess is not a test: bootReport runs the test binary with it
+// selected, to start the server in a child process.
+func TestBootProcess(t *testing.T) {
+	if os.Getenv("VINZHUB_BOOT_CHILD") != "1" {
+		t.Skip("helper process for the boot report tests")
+	}
+	os.Args = []string{"api"}
+	main()
+}
+
+// bootReport starts the server in dir with env and returns the boot report
+// it emits. The server runs until the test ends.
+func bootReport(t *testing.T, dir string, env ...string) *bootreport.Report {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestBootProcess$")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "VINZHUB_BOOT_CHILD=1", "SERVER_HOST=127.0.0.1", "SERVER_PORT=0",
+		"API_KEY=boot-test", "OTEL_EXPORTER_OTLP_ENDPOINT=")
+	cmd.Env = append(cmd.Env, env...)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd.Stdout = cmd.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	lines := make(chan string)
+	var logged bytes.Buffer
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("server exited without a boot report:\n%s", logged.String())
+			}
+			logged.WriteString(line + "\n")
+			if data, found := strings.CutPrefix(line, bootreport.Marker+" "); found {
+				var report bootreport.Report
+				if err := json.Unmarshal([]byte(data), &report); err != nil {
+					t.Fatalf("boot report is not JSON: %v\n%s", err, line)
+				}
+				go func() {
+					for range lines {
+					}
+				}()
+				return &report
+			}
+		case <-timeout:
+			t.Fatalf("no boot report within 30s:\n%s", logged.String())
+		}
+	}
+}
+
+// component returns the named component of r.
+func component(t *testing.T, r *bootreport.Report, name string) bootreport.Component {
+	t.Helper()
+	for _, c := range r.Components {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("boot report has no %s component: %+v", name, r.Components)
+	return bootreport.Component{}
+}
+
+func TestBootReportDemo(t *testing.T) {
+	dir := t.TempDir()
+	report := bootReport(t, dir, "APP_ENV=demo", "RETENTION_INTERVAL=0", "INTEGRITY_VERIFY_INTERVAL=0",
+		"SCHEMA_SAMPLE_RATE=0", "BUFFER_SPOOL_DIR=", "BUNDLE_KEY=")
+
+	if report.FormatVersion != bootreport.FormatVersion || report.Role != "demo" || report.Environment != "demo" {
+		t.Errorf("report = version %d, role %q, environment %q; want the demo instance", report.FormatVersion, report.Role, report.Environment)
+	}
+	if report.Degraded {
+		t.Errorf("demo instance reported degraded: %+v", report.Components)
+	}
+	if len(report.Listen) != 1 || report.Listen[0] != "127.0.0.1:0" {
+		t.Errorf("listen = %v, want [127.0.0.1:0]", report.Listen)
+	}
+	if report.ReadyAt.Before(report.StartedAt) {
+		t.Errorf("ready_at %v before started_at %v", report.ReadyAt, report.StartedAt)
+	}
+	for _, section := range []string{"server", "cache", "database", "storage"} {
+		if len(report.Config[section]) != 12 {
+			t.Errorf("config fingerprint of %s = %q, want 12 hex digits", section, report.Config[section])
+		}
+	}
+
+	for name, status := range map[string]string{
+		"sqlite":             bootreport.StatusOK,
+		"token_service":      bootreport.StatusOK,
+		"mysql":              bootreport.StatusDisabled,
+		"redis_buffer":       bootreport.StatusDisabled,
+		"retention":          bootreport.StatusDisabled,
+		"integrity_verifier": bootreport.StatusDisabled,
+		"schema_profiler":    bootreport.StatusDisabled,
+		"buffer_spool":       bootreport.StatusDisabled,
+		"bundles":            bootreport.StatusDisabled,
+	} {
+		if got := component(t, report, name).Status; got != status {
+			t.Errorf("%s status = %q, want %q", name, got, status)
+		}
+	}
+
+	// The same report is saved for the deploy script, just after it is logged
+	var (
+		saved []byte
+		err   error
+	)
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if saved, err = os.ReadFile(filepath.Join(dir, "data", "demo", "last_boot.json")); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("last_boot.json: %v", err)
+	}
+	var file bootreport.Report
+	if err := json.Unmarshal(saved, &file); err != nil || file.PID != report.PID || file.Degraded != report.Degraded {
+		t.Errorf("last_boot.json = %s (%v), want the emitted report", saved, err)
+	}
+}
+
+func TestBootReportDegraded(t *testing.T) {
+	dir := t.TempDir()
+	// Nothing listens on port 1, so MySQL and Redis are unavailable
+	report := bootReport(t, dir, "APP_ENV=development", "DB_PORT=1", "REDIS_PORT=1",
+		"BUNDLE_KEY=not-hex", "BOOT_REPORT_FILE=false")
+
+	if !report.Degraded || report.Role != "api" {
+		t.Errorf("report = degraded %v, role %q; want a degraded api instance", report.Degraded, report.Role)
+	}
+	for name, status := range map[string]string{
+		"sqlite":        bootreport.StatusOK,
+		"mysql":         bootreport.StatusDegraded,
+		"redis_buffer":  bootreport.StatusDegraded,
+		"token_service": bootreport.StatusDegraded,
+		"bundles":       bootreport.StatusDegraded,
+		"retention":     bootreport.StatusOK,
+	} {
+		if got := component(t, report, name); got.Status != status {
+			t.Errorf("%s = %+v, want status %q", name, got, status)
+		}
+	}
+	if detail := component(t, report, "mysql").Detail; !strings.Contains(detail, "127.0.0.1:1") {
+		t.Errorf("mysql detail = %q, want the failed address", detail)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "data", "last_boot.json")); !os.IsNotExist(err) {
+		t.Errorf("last_boot.json written with BOOT_REPORT_FILE=false (%v)", err)
+	}
+}
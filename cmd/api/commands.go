@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// command is a maintenance subcommand run instead of the HTTP server.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// commands lists the available subcommands (`api <name> [flags]`).
+var commands = []command{
+	{"replay-buffer", "Replay buffered inventories from Redis or a JSON dump into SQLite", runReplayBuffer},
+	{"rekey-buffer", "Move buffered entries from an old Redis key prefix to a new one", runRekeyBuffer},
+	{"reshard", "Move inventory rows to a different SQLite shard count (server must be stopped)", runReshard},
+	{"grep-archive", "Search the retention log archive by roblox user ID or request ID", runGrepArchive},
+	{"migrate", "Apply pending SQLite schema migrations and print schema versions", runMigrate},
+}
+
+// runCommand dispatches a subcommand and returns its exit code.
+func runCommand(name string, args []string) int {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd.run(args)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\nAvailable commands:\n", name)
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	return 2
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"vinzhub-rest-api/internal/demo"
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/service"
+	"vinzhub-rest-api/internal/transport/http/middleware"
+)
+
+// bootstrapDemoData seeds demo accounts and inventories, makes sure an API key
+// is configured and prints credentials ready to use with curl.
+func bootstrapDemoData(keys *repository.SQLiteKeyAccountRepository, store repository.InventoryRepository, tokens *service.TokenService) {
+	ctx := context.Background()
+
+	accounts, err := demo.Seed(ctx, keys, store)
+	if err != nil {
+		fatal("Failed to seed demo data", "error", err)
+	}
+
+	// The API key middleware reads API_KEYS per request. Configured keys
+	// may be real ones, so only a generated key is printed in full
+	configured := os.Getenv("API_KEYS")
+	if configured == "" {
+		configured = os.Getenv("API_KEY")
+	}
+	var generated string
+	if configured == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			fatal("Failed to generate demo API key", "error", err)
+		}
+		generated = "demo_" + hex.EncodeToString(b)
+		os.Setenv("API_KEYS", generated)
+	}
+
+	first := accounts[0]
+	validation, err := keys.ValidateKeyAndHWID(ctx, first.Key, first.HWID, first.RobloxUserID)
+	if err != nil {
+		fatal("Failed to validate demo account", "error", err)
+	}
+	token, err := tokens.GenerateToken(ctx, service.TokenData{
+		KeyAccountID:   validation.KeyAccountID,
+		KeyID:          validation.KeyID,
+		RobloxUserID:   validation.RobloxUserID,
+		RobloxUsername: validation.RobloxUsername,
+		HWID:           validation.HWID,
+	})
+	if err != nil {
+		fatal("Failed to issue demo token", "error", err)
+	}
+
+	logger.Info("Demo data seeded")
+	if generated != "" {
+		logger.Info("Demo API key", "api_key", generated)
+	} else {
+		logger.Info("Demo API keys", "api_keys", describeAPIKeys(configured))
+	}
+	logger.Info("Demo token", "token", token, "roblox_user_id", first.RobloxUserID, "expires_in", tokens.TTL())
+	for _, acc := range accounts {
+		logger.Info("Demo account", "key", acc.Key, "roblox_user_id", acc.RobloxUserID, "hwid", acc.HWID)
+	}
+}
+
+// describeAPIKeys summarizes configured API keys by count and key ID,
+// without revealing them.
+func describeAPIKeys(keys string) string {
+	var ids []string
+	for _, key := range strings.Split(keys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			ids = append(ids, middleware.KeyID(key))
+		}
+	}
+	return fmt.Sprintf("%d configured (ids %s)", len(ids), strings.Join(ids, ", "))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/service"
+	"vinzhub-rest-api/internal/transport/http/middleware"
+)
+
+// bootstrapLog runs bootstrapDemoData over temporary databases and returns
+// what it logged.
+func bootstrapLog(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	keys, err := repository.NewSQLiteKeyAccountRepository(filepath.Join(dir, "key_accounts.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { keys.Close() })
+	store, err := repository.NewSQLiteInventoryRepository(filepath.Join(dir, "inventory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	var out bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&out)
+	t.Cleanup(func() { log.SetOutput(prev) })
+	bootstrapDemoData(keys, store, service.NewTokenServiceWithStore(service.NewMemoryTokenStore()))
+	return out.String()
+}
+
+func TestDemoBootstrapDoesNotLogConfiguredKeys(t *testing.T) {
+	t.Setenv("API_KEYS", "prod-secret-one, prod-secret-two")
+	out := bootstrapLog(t)
+
+	for _, secret := range []string{"prod-secret-one", "prod-secret-two"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("startup log contains configured API key %q:\n%s", secret, out)
+		}
+		if !strings.Contains(out, middleware.KeyID(secret)) {
+			t.Errorf("startup log lacks the key ID of %q", secret)
+		}
+	}
+	if !strings.Contains(out, "2 configured") {
+		t.Errorf("startup log lacks the key count:\n%s", out)
+	}
+}
+
+func TestDemoBootstrapPrintsGeneratedKey(t *testing.T) {
+	t.Setenv("API_KEYS", "")
+	t.Setenv("API_KEY", "")
+	out := bootstrapLog(t)
+
+	if !strings.Contains(out, "api_key=demo_") {
+		t.Errorf("startup log lacks the generated demo key:\n%s", out)
+	}
+}
+
+func TestDescribeAPIKeys(t *testing.T) {
+	got := describeAPIKeys(" a ,,b")
+	want := "2 configured (ids " + middleware.KeyID("a") + ", " + middleware.KeyID("b") + ")"
+	if got != want {
+		t.Errorf("describeAPIKeys = %q, want %q", got, want)
+	}
+}
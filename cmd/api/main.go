@@ -8,23 +8,42 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
+	"vinzhub-rest-api/internal/bootreport"
+	"vinzhub-rest-api/internal/bundle"
 	"vinzhub-rest-api/internal/cache"
 	"vinzhub-rest-api/internal/config"
+	"vinzhub-rest-api/internal/demo"
+	"vinzhub-rest-api/internal/lifecycle"
+	"vinzhub-rest-api/internal/logging"
 	"vinzhub-rest-api/internal/repository"
 	"vinzhub-rest-api/internal/service"
+	"vinzhub-rest-api/internal/tracing"
 	httpTransport "vinzhub-rest-api/internal/transport/http"
 	"vinzhub-rest-api/internal/transport/http/handler"
 	"vinzhub-rest-api/internal/transport/http/middleware"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/internal/transport/queue"
 
 	"github.com/redis/go-redis/v9"
 	_ "github.com/go-sql-driver/mysql"
 )
 
+// logger logs the server's startup and shutdown.
+var logger = logging.Component("Main")
+
 func main() {
+	// Maintenance subcommands run instead of the server
+	bootstrapDemo := len(os.Args) > 1 && os.Args[1] == "--bootstrap-demo"
+	if len(os.Args) > 1 && !bootstrapDemo {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
 	// OPTIMIZATION FOR SHARED HOSTING (Low Resource Limits)
 	// Limit to 1 CPU core to reduce thread usage
 	runtime.GOMAXPROCS(1)
@@ -32,132 +51,806 @@ func main() {
 	// Load configuration
 	cfg := config.MustLoad()
 
-	log.Printf("Starting %s v%s in %s mode",
-		cfg.App.Name,
-		cfg.App.Version,
-		cfg.App.Environment,
+	logLevel, err := logging.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		fatal("Invalid LOG_LEVEL", "error", err)
+	}
+	logging.Setup(os.Stderr, logging.Options{Level: logLevel, SampleRate: cfg.Log.SampleRate, JSON: cfg.App.IsProduction()})
+	lifecycle.SetGoroutineCeiling(cfg.Server.GoroutineCeiling)
+	lifecycle.Monitor(time.Minute, nil)
+
+	// Shutdown order: drain HTTP, stop workers, flush buffers, close storage
+	shutdown := &lifecycle.Shutdown{}
+
+	logger.Info("Starting",
+		"app", cfg.App.Name,
+		"version", cfg.App.Version,
+		"env", cfg.App.Environment,
 	)
 
+	// Demo mode runs on seeded local data without MySQL or Redis
+	demoMode := cfg.App.IsDemo() || bootstrapDemo
+	if demoMode && cfg.App.IsProduction() {
+		fatal("Demo mode cannot run with APP_ENV=production")
+	}
+	dataDir := "./data"
+	role := "api"
+	if demoMode {
+		dataDir = demo.DataDir
+		role = "demo"
+		logger.Warn("DEMO MODE - seeded fake data, no MySQL or Redis")
+	}
+
+	// Boot report: what came up, emitted once everything is initialized
+	boot := bootreport.New(cfg.App.Name, cfg.App.Version, cfg.App.Environment, role)
+	boot.SetConfig(cfg.Fingerprints())
+
+	// Tracing: closed last, so spans of the final flush are exported
+	if cfg.Tracing.Enabled() {
+		tracer, err := tracing.Init(tracing.Config{
+			Endpoint:    cfg.Tracing.Endpoint,
+			Headers:     tracing.ParseHeaders(cfg.Tracing.Headers),
+			ServiceName: cfg.Tracing.ServiceName,
+			SampleRatio: cfg.Tracing.SampleRatio,
+		})
+		if err != nil {
+			fatal("Invalid tracing config", "error", err)
+		}
+		shutdown.AddFunc(lifecycle.PhaseStorage, "tracing", tracer.Close)
+		logger.Info("Tracing enabled", "endpoint", cfg.Tracing.Endpoint, "sample_ratio", cfg.Tracing.SampleRatio)
+		boot.OK("tracing", fmt.Sprintf("%s, sampling %v", cfg.Tracing.Endpoint, cfg.Tracing.SampleRatio))
+	} else {
+		boot.Disable("tracing", "OTEL_EXPORTER_OTLP_ENDPOINT not set")
+	}
+
 	// Initialize infrastructure layer
 	memoryCache := cache.NewMemoryCache()
-	defer memoryCache.Close()
+	shutdown.AddCloser(lifecycle.PhaseStorage, "memory_cache", memoryCache)
 
 	// Connect to Main Database (for key_accounts lookup - optional)
-	mainDB, err := connectDB(
-		cfg.Database.Host,
-		cfg.Database.Port,
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.Name,
-		"Main DB",
-	)
-	if err != nil {
-		log.Printf("Warning: Failed to connect to Main DB: %v", err)
-		mainDB = nil
+	var mainDB *sql.DB
+	if !demoMode {
+		mainDB, err = connectDB(
+			cfg.Database.Host,
+			cfg.Database.Port,
+			cfg.Database.User,
+			cfg.Database.Password,
+			cfg.Database.Name,
+			"Main DB",
+		)
+		if err != nil {
+			logger.Warn("Failed to connect to Main DB", "error", err)
+			mainDB = nil
+			boot.Degrade("mysql", err.Error())
+		} else {
+			shutdown.AddCloser(lifecycle.PhaseStorage, "mysql", mainDB)
+			logger.Info("Main DB connected")
+			boot.OK("mysql", fmt.Sprintf("%s:%d/%s", cfg.Database.Host, cfg.Database.Port, cfg.Database.Name))
+		}
 	} else {
-		defer mainDB.Close()
-		log.Println("✓ Main DB connected")
+		boot.Disable("mysql", "demo mode")
 	}
 
 	// Create data directory for SQLite
-	if err := os.MkdirAll("./data", 0755); err != nil {
-		log.Fatalf("Failed to create data directory: %v", err)
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		fatal("Failed to create data directory", "error", err)
 	}
 
 	// Initialize SQLite for inventory (LOCAL - no network latency!)
-	sqliteRepo, err := repository.NewSQLiteInventoryRepository("./data/inventory.db")
+	inventoryStore, primaryDB, err := repository.OpenInventoryStore(dataDir, cfg.Storage.SQLiteShards,
+		repository.WithBusyTimeout(cfg.Storage.SQLiteBusyTimeout), repository.WithLogger(logging.Component("SQLite")))
 	if err != nil {
-		log.Fatalf("FATAL: Failed to initialize SQLite: %v", err)
+		fatal("Failed to initialize SQLite", "error", err)
+	}
+	shutdown.AddCloser(lifecycle.PhaseStorage, "sqlite", primaryDB)
+	if cfg.Storage.SQLiteShards > 0 {
+		shutdown.AddCloser(lifecycle.PhaseStorage, "sqlite_shards", inventoryStore)
+		logger.Info("SQLite database initialized", "dir", dataDir, "shards", cfg.Storage.SQLiteShards)
+		boot.OK("sqlite", fmt.Sprintf("%s, %d shards", dataDir, cfg.Storage.SQLiteShards))
+	} else {
+		logger.Info("SQLite database initialized", "file", dataDir+"/inventory.db")
+		boot.OK("sqlite", dataDir+"/inventory.db")
+	}
+	if cfg.Storage.HistoryKeep > 0 {
+		inventoryStore.SetHistoryKeep(cfg.Storage.HistoryKeep)
+		logger.Info("Inventory history enabled", "versions_per_section", cfg.Storage.HistoryKeep)
+		boot.OK("inventory_history", fmt.Sprintf("last %d versions", cfg.Storage.HistoryKeep))
+	} else {
+		boot.Disable("inventory_history", "INVENTORY_HISTORY_KEEP=0")
+	}
+	inventoryStore.SetCompression(cfg.Storage.CompressMinBytes)
+	if cfg.Storage.CompressMinBytes > 0 {
+		boot.OK("blob_compression", fmt.Sprintf("gzip from %d bytes", cfg.Storage.CompressMinBytes))
+	} else {
+		boot.Disable("blob_compression", "INVENTORY_COMPRESS_MIN_BYTES=0")
 	}
-	defer sqliteRepo.Close()
-	log.Println("✓ SQLite database initialized (./data/inventory.db)")
 
-	// KeyAccount repo is optional (uses Main MySQL DB)
-	var keyAccountRepo repository.KeyAccountRepository
+	// KeyAccount repo is optional (uses Main MySQL DB, or embedded SQLite in demo mode)
+	var (
+		keyAccountRepo repository.KeyAccountRepository
+		authKeyRepo    repository.KeyAccountAuthRepository
+		provisioner    repository.KeyAccountProvisioner
+		syncRecorder   repository.KeyAccountSyncRecorder // UpdateLastSync flush side effect
+		demoKeys       *repository.SQLiteKeyAccountRepository
+	)
 	if mainDB != nil {
-		keyAccountRepo = repository.NewMySQLKeyAccountRepository(mainDB)
+		mysqlKeyRepo := repository.NewMySQLKeyAccountRepository(mainDB)
+		keyAccountRepo = mysqlKeyRepo
+		authKeyRepo = mysqlKeyRepo
+		provisioner = mysqlKeyRepo
+		syncRecorder = mysqlKeyRepo
+	}
+	if demoMode {
+		demoKeys, err = repository.NewSQLiteKeyAccountRepository(filepath.Join(dataDir, "key_accounts.db"))
+		if err != nil {
+			fatal("Failed to initialize demo key accounts", "error", err)
+		}
+		shutdown.AddCloser(lifecycle.PhaseStorage, "demo_keys", demoKeys)
+		keyAccountRepo = demoKeys
+		authKeyRepo = demoKeys
+		provisioner = demoKeys
+		syncRecorder = demoKeys
 	}
 
 	// Initialize Redis buffer (Redis buffers writes, SQLite persists)
 	// This buffers sync requests and batch-flushes to SQLite every 30 seconds
 	var redisBuffer *cache.RedisInventoryBuffer
 	
-	flushFunc := func(ctx context.Context, items []*cache.BufferedInventory) error {
-		// Convert to repository items
-		repoItems := make([]repository.InventoryItem, len(items))
-		for i, item := range items {
-			repoItems[i] = repository.InventoryItem{
-				KeyAccountID: item.KeyAccountID,
-				RobloxUserID: item.RobloxUserID,
-				RawJSON:      item.RawJSON,
-				SyncedAt:     item.UpdatedAt,
-			}
+	// Flush pipeline: persisting to SQLite decides success, side effects are
+	// best-effort and retried on later flushes
+	flushPipeline := service.NewFlushPipeline(inventoryStore.BatchUpsertRawInventoryStats)
+	flushPipeline.SetFlushLog(primaryDB)
+	flushPipeline.SetOutbox(primaryDB)
+	itemCounter := service.NewItemCounter(cfg.Inventory.ItemCountPath)
+	flushPipeline.SetItemCounter(itemCounter)
+	if cfg.Storage.FlushGuardDropRatio > 0 {
+		flushPipeline.SetGuard(service.NewFlushGuard(service.FlushGuardConfig{
+			DropRatio:      cfg.Storage.FlushGuardDropRatio,
+			TripFraction:   cfg.Storage.FlushGuardTripFraction,
+			Window:         cfg.Storage.FlushGuardWindow,
+			MinItems:       cfg.Storage.FlushGuardMinItems,
+			MinStoredBytes: cfg.Storage.FlushGuardMinBytes,
+		}, inventoryStore, primaryDB))
+		logger.Info("Data-loss guard enabled",
+			"drop_ratio", cfg.Storage.FlushGuardDropRatio, "trip_fraction", cfg.Storage.FlushGuardTripFraction,
+			"min_items", cfg.Storage.FlushGuardMinItems, "window", cfg.Storage.FlushGuardWindow)
+		boot.OK("flush_guard", "")
+	} else {
+		boot.Disable("flush_guard", "FLUSH_GUARD_DROP_RATIO=0")
+	}
+	if cfg.OpenCloud.Enabled() {
+		notifier := service.NewPersistedNotifier(service.OpenCloudConfig{
+			APIKey:     cfg.OpenCloud.APIKey,
+			UniverseID: cfg.OpenCloud.UniverseID,
+			Topic:      cfg.OpenCloud.Topic,
+			PerMinute:  cfg.OpenCloud.PublishPerMinute,
+		})
+		flushPipeline.AddSideEffect("opencloud_callback", notifier.Notify)
+		logger.Info("Persisted callbacks enabled", "universe_id", cfg.OpenCloud.UniverseID, "topic", cfg.OpenCloud.Topic)
+		boot.OK("opencloud_callbacks", "topic "+cfg.OpenCloud.Topic)
+	} else {
+		boot.Disable("opencloud_callbacks", "not configured")
+	}
+	// Last sync time and item count on key accounts (UpdateLastSync), after
+	// SQLite persisted the batch so a MySQL outage can't fail the flush
+	if syncRecorder != nil {
+		flushPipeline.AddSideEffect("key_account_sync", service.RecordKeyAccountSyncs(syncRecorder))
+		boot.OK("key_account_sync", "")
+	} else {
+		boot.Disable("key_account_sync", "no key account store")
+	}
+	var schemaProfiler *service.SchemaProfiler
+	if cfg.Inventory.SchemaSampleRate > 0 {
+		schemaProfiler = service.NewSchemaProfiler(primaryDB, service.SchemaProfilerConfig{
+			SampleRate:    cfg.Inventory.SchemaSampleRate,
+			MaxDepth:      cfg.Inventory.SchemaMaxDepth,
+			MaxPaths:      cfg.Inventory.SchemaMaxPaths,
+			PresenceAlert: cfg.Inventory.SchemaPresenceAlert,
+		})
+		schemaProfiler.Start(time.Minute)
+		shutdown.AddFunc(lifecycle.PhaseWorkers, "schema_profiler", schemaProfiler.Close)
+		flushPipeline.AddSideEffect("schema_profile", schemaProfiler.Observe)
+		boot.OK("schema_profiler", fmt.Sprintf("1 in %d payloads", cfg.Inventory.SchemaSampleRate))
+	} else {
+		boot.Disable("schema_profiler", "SCHEMA_SAMPLE_RATE=0")
+	}
+	var inventoryRules *service.InventoryRules
+	if cfg.Inventory.RulesFile != "" {
+		rules, err := service.NewInventoryRules(primaryDB, service.InventoryRulesConfig{
+			Path:     cfg.Inventory.RulesFile,
+			Game:     cfg.Inventory.RulesGame,
+			MaxBytes: cfg.Inventory.RulesMaxBytes,
+		})
+		if err != nil {
+			logger.Warn("Inventory rules disabled", "error", err)
+			boot.Degrade("inventory_rules", err.Error())
+		} else {
+			inventoryRules = rules
+			inventoryRules.Start()
+			shutdown.AddFunc(lifecycle.PhaseWorkers, "inventory_rules", inventoryRules.Close)
+			flushPipeline.AddSideEffect("inventory_rules", inventoryRules.Observe)
+			boot.OK("inventory_rules", "game "+cfg.Inventory.RulesGame)
+
+			// SIGHUP reloads the rules file without a restart
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+			lifecycle.Go("inventory.rules.reload", func() {
+				for range hup {
+					inventoryRules.Reload()
+				}
+			})
 		}
-		return sqliteRepo.BatchUpsertRawInventory(ctx, repoItems)
+	} else {
+		boot.Disable("inventory_rules", "INVENTORY_RULES_FILE not set")
 	}
+	// Retry side effects (Open Cloud callbacks, ...) left over from the last
+	// run, in the background as they may wait on remote services
+	lifecycle.Go("flush.outbox.drain", func() {
+		loaded, err := flushPipeline.DrainOutbox(context.Background())
+		if err != nil {
+			logger.Warn("Side-effect outbox not drained", "error", err)
+		} else if loaded > 0 {
+			logger.Info("Retried side-effect batches from the outbox", "batches", loaded)
+		}
+	})
+	flushFunc := flushPipeline.Flush
 
-	redisCfg := cache.RedisBufferConfig{
-		Addr:          "127.0.0.1:6379",
-		Password:      "",
-		DB:            1,
-		FlushInterval: 30 * time.Second,
-		KeyPrefix:     "vinzhub:fishit:inventory",
+	redisCfg := redisBufferConfig(cfg.Cache)
+	// The other Redis clients below connect the same way as the buffer
+	redisTLS, tlsErr := redisCfg.TLS.ClientConfig(redisCfg.Addr)
+	if tlsErr != nil {
+		fatal("Invalid Redis TLS config", "error", tlsErr)
 	}
 
 	var redisErr error
-	redisBuffer, redisErr = cache.NewRedisInventoryBuffer(redisCfg, flushFunc)
+	if demoMode {
+		redisErr = fmt.Errorf("disabled in demo mode")
+	} else {
+		redisBuffer, redisErr = cache.NewRedisInventoryBuffer(redisCfg, flushFunc)
+	}
 	if redisErr != nil {
-		log.Printf("⚠ Redis unavailable: %v (using direct SQLite writes)", redisErr)
+		logger.Warn("Redis unavailable, using direct SQLite writes", "error", redisErr)
 		// Redis is optional for development - production should have Redis
+		if demoMode {
+			boot.Disable("redis_buffer", "demo mode")
+		} else {
+			boot.Degrade("redis_buffer", redisErr.Error()+" (direct SQLite writes)")
+		}
+	} else {
+		boot.OK("redis_buffer", fmt.Sprintf("%s DB=%d", redisCfg.Addr, redisCfg.DB))
+		shutdown.Add(lifecycle.PhaseFlush, "redis_buffer", cfg.Server.ShutdownFlushTimeout, func(ctx context.Context) error {
+			return redisBuffer.Shutdown(ctx)
+		})
+		redisBuffer.SetHoldFunc(flushPipeline.Paused)
+		logger.Info("Redis buffer enabled", "flush_interval", cfg.Cache.BufferFlushInterval, "db", redisCfg.DB)
+		checkLegacyBufferPrefix(redisBuffer, cfg.Cache.LegacyKeyPrefix, cfg.Cache.LegacyAutoMigrate)
+	}
+
+	// Invalidation bus: keeps other instances' in-memory caches in step
+	var invalidationBus *cache.InvalidationBus
+	if redisBuffer != nil && cfg.Cache.InvalidationChannel != "" {
+		invalidationBus = cache.NewInvalidationBus(redis.NewClient(&redis.Options{
+			Addr:      redisCfg.Addr,
+			Password:  redisCfg.Password,
+			TLSConfig: redisTLS,
+		}), cfg.Cache.InvalidationChannel)
+		invalidationBus.Start()
+		shutdown.AddFunc(lifecycle.PhaseStorage, "invalidation_bus", invalidationBus.Close)
+		redisBuffer.SetInvalidationBus(invalidationBus)
+		boot.OK("invalidation_bus", cfg.Cache.InvalidationChannel)
+	} else if redisBuffer == nil {
+		boot.Disable("invalidation_bus", "no Redis")
 	} else {
-		defer redisBuffer.Close()
-		log.Println("✓ Redis buffer enabled (flush every 30s, DB=1)")
+		boot.Disable("invalidation_bus", "INVALIDATION_CHANNEL empty")
+	}
+
+	// Disk spool: takes buffer writes while Redis is under memory pressure
+	var spool *cache.DiskSpool
+	if cfg.Cache.SpoolDir != "" {
+		var spoolErr error
+		spool, spoolErr = cache.NewDiskSpool(cfg.Cache.SpoolDir, cfg.Cache.SpoolMaxBytes)
+		if spoolErr != nil {
+			logger.Warn("Buffer spool disabled", "error", spoolErr)
+			boot.Degrade("buffer_spool", spoolErr.Error())
+		} else {
+			boot.OK("buffer_spool", cfg.Cache.SpoolDir)
+			if redisBuffer != nil {
+				redisBuffer.SetSpool(spool, cfg.Cache.SpoolBudgetBytes)
+				logger.Info("Buffer spool enabled", "dir", cfg.Cache.SpoolDir,
+					"max_bytes", cfg.Cache.SpoolMaxBytes, "budget_bytes", cfg.Cache.SpoolBudgetBytes)
+			}
+			drainSpool(spool, redisBuffer, flushFunc, flushPipeline.Paused())
+		}
+	} else {
+		boot.Disable("buffer_spool", "BUFFER_SPOOL_DIR empty")
+	}
+
+	// In-memory fallback: keeps taking syncs if Redis dies mid-run
+	var fallbackBuffer *cache.FallbackBuffer
+	if redisBuffer != nil && cfg.Cache.BufferFallbackAfter > 0 {
+		memoryBuffer := cache.NewInventoryBuffer(cfg.Cache.BufferFlushInterval, flushFunc)
+		fallbackBuffer = cache.NewFallbackBuffer(redisBuffer, memoryBuffer, cfg.Cache.BufferFallbackAfter)
+		shutdown.Add(lifecycle.PhaseFlush, "buffer_fallback", cfg.Server.ShutdownFlushTimeout, func(context.Context) error {
+			return fallbackBuffer.Close()
+		})
+		boot.OK("buffer_fallback", fmt.Sprintf("in memory after %d Redis errors", cfg.Cache.BufferFallbackAfter))
+	} else if redisBuffer == nil {
+		boot.Disable("buffer_fallback", "no Redis")
+	} else {
+		boot.Disable("buffer_fallback", "BUFFER_FALLBACK_AFTER=0")
 	}
 
 	// Initialize service - with or without Redis buffer
 	var inventoryService *service.InventoryService
-	if redisBuffer != nil {
-		inventoryService = service.NewInventoryServiceWithBuffer(sqliteRepo, keyAccountRepo, redisBuffer)
-		log.Println("✓ InventoryService initialized (Redis → SQLite)")
+	if fallbackBuffer != nil {
+		inventoryService = service.NewInventoryServiceWithBuffer(inventoryStore, keyAccountRepo, fallbackBuffer)
+		logger.Info("InventoryService initialized (Redis → SQLite, in-memory fallback)")
+	} else if redisBuffer != nil {
+		inventoryService = service.NewInventoryServiceWithBuffer(inventoryStore, keyAccountRepo, redisBuffer)
+		logger.Info("InventoryService initialized (Redis → SQLite)")
 	} else {
-		inventoryService = service.NewInventoryService(sqliteRepo, keyAccountRepo)
-		log.Println("✓ InventoryService initialized (direct SQLite - no Redis)")
+		inventoryService = service.NewInventoryService(inventoryStore, keyAccountRepo)
+		logger.Info("InventoryService initialized (direct SQLite - no Redis)")
 	}
 	if inventoryService == nil {
-		log.Fatalf("FATAL: Failed to create InventoryService")
+		fatal("Failed to create InventoryService")
+	}
+	inventoryService.SetSections(cfg.Inventory.Sections)
+	inventoryService.SetKeyAccountPolicy(service.KeyAccountPolicy{
+		Required:     cfg.Inventory.RequireKeyAccount,
+		AllowOnError: cfg.Inventory.KeyAccountAllowOnError,
+		CacheTTL:     cfg.Inventory.KeyAccountCacheTTL,
+	}, memoryCache)
+	inventoryService.SetNegativeCache(memoryCache, cfg.Inventory.NegativeCacheTTL)
+	inventoryService.SetReadCache(service.InventoryCacheConfig{
+		TTL:        cfg.Inventory.ReadCacheTTL,
+		MaxEntries: cfg.Inventory.ReadCacheSize,
+		MaxBytes:   cfg.Inventory.ReadCacheMaxBytes,
+	})
+	inventoryService.SetExistenceCache(memoryCache, cfg.Inventory.ExistsCacheTTL, cfg.Inventory.ExistsNegativeCacheTTL)
+	inventoryService.SetInvalidationBus(invalidationBus)
+	inventoryService.SetItemCounter(itemCounter)
+	inventoryService.SetPayloadPolicy(service.PayloadPolicy{
+		RejectNull:  cfg.Inventory.RejectNull,
+		RejectEmpty: cfg.Inventory.RejectEmpty,
+	})
+	if cfg.Inventory.SchemaPath != "" {
+		schema, err := service.LoadPayloadSchema(cfg.Inventory.SchemaPath)
+		if err != nil {
+			fatal("Failed to load inventory schema", "error", err)
+		}
+		inventoryService.SetPayloadSchema(schema)
+		logger.Info("Inventory schema loaded", "path", cfg.Inventory.SchemaPath)
+		boot.OK("payload_schema", cfg.Inventory.SchemaPath)
+	} else {
+		boot.Disable("payload_schema", "INVENTORY_SCHEMA_PATH not set")
+	}
+	switch {
+	case cfg.Inventory.SyncMinInterval <= 0:
+		boot.Disable("sync_throttle", "SYNC_MIN_INTERVAL=0")
+	case redisBuffer != nil:
+		inventoryService.SetSyncThrottle(cache.NewRedisThrottle(redis.NewClient(&redis.Options{
+			Addr:      redisCfg.Addr,
+			Password:  redisCfg.Password,
+			DB:        redisCfg.DB,
+			TLSConfig: redisTLS,
+		})), cfg.Inventory.SyncMinInterval)
+		boot.OK("sync_throttle", fmt.Sprintf("Redis, one sync per section every %s", cfg.Inventory.SyncMinInterval))
+	default:
+		inventoryService.SetSyncThrottle(cache.NewMemoryThrottle(), cfg.Inventory.SyncMinInterval)
+		boot.OK("sync_throttle", fmt.Sprintf("in-memory, one sync per section every %s", cfg.Inventory.SyncMinInterval))
+	}
+	if cfg.Inventory.RequireKeyAccount {
+		if keyAccountRepo == nil {
+			logger.Warn("REQUIRE_KEY_ACCOUNT is on but Main DB is unavailable", "allow_on_error", cfg.Inventory.KeyAccountAllowOnError)
+		} else {
+			logger.Info("Strict key account mode enabled")
+		}
 	}
 
 	// Initialize transport layer - HTTP
 	httpHandler := handler.New(nil)
+	httpHandler.AddReadinessCheck("sqlite", true, primaryDB.Ping)
+	if cfg.Storage.SQLiteShards > 0 {
+		for i, shard := range inventoryStore.Partitions() {
+			httpHandler.AddReadinessCheck(fmt.Sprintf("sqlite_shard_%d", i), true, shard.Ping)
+		}
+	}
+	if redisBuffer != nil {
+		httpHandler.AddReadinessCheck("redis", false, redisBuffer.Ping)
+		httpHandler.SetBuffer(redisBuffer)
+	}
+	if mainDB != nil {
+		httpHandler.AddReadinessCheck("mysql", false, mainDB.PingContext)
+	}
 
 	var invHandler *handler.InventoryHandler
 	if inventoryService != nil {
 		invHandler = handler.NewInventoryHandler(inventoryService)
+		invHandler.SetAuditLog(primaryDB) // Support token reads
+		invHandler.SetExistsLimit(cfg.Inventory.ExistsRateLimit)
+		invHandler.SetMaxDecompressedBytes(cfg.Inventory.SyncMaxDecompressedBytes)
+		redaction := service.NewRedactionPolicy(cfg.Inventory.RedactPointers, cfg.Inventory.RedactMaxBytes)
+		if redaction.Enabled() {
+			invHandler.SetRedactionPolicy(redaction)
+			logger.Info("Inventory redaction enabled for non-owner reads", "pointers", len(cfg.Inventory.RedactPointers))
+		}
 	}
 
 	// Admin handler for stats dashboard
-	adminHandler := handler.NewAdminHandler(redisBuffer, sqliteRepo)
+	var adminBuffer handler.AdminBuffer
+	if redisBuffer != nil {
+		adminBuffer = redisBuffer // Keep a nil buffer an untyped nil
+	}
+	adminHandler := handler.NewAdminHandler(adminBuffer, inventoryStore)
+	if spool != nil {
+		adminHandler.SetBufferSpool(spool)
+	}
+	if fallbackBuffer != nil {
+		adminHandler.SetBufferFallback(fallbackBuffer)
+	}
+	adminHandler.SetFlushPipeline(flushPipeline, primaryDB)
+	adminHandler.SetFlushResumer(flushPipeline)
+	adminHandler.SetInventoryService(inventoryService)
+	adminHandler.SetAuditLog(primaryDB)
+	if inventoryRules != nil {
+		adminHandler.SetInventoryRules(inventoryRules)
+	}
+	if schemaProfiler != nil {
+		adminHandler.SetSchemaProfiler(schemaProfiler)
+	}
+	if provisioner != nil {
+		adminHandler.SetKeyAccountProvisioning(provisioner, inventoryService)
+	}
+	if resetter, ok := provisioner.(repository.KeyAccountHWIDResetter); ok {
+		adminHandler.SetHWIDReset(resetter, cfg.Cache.HWIDResetCooldown)
+	}
+	if cfg.Storage.BundleKey != "" {
+		bundles, err := openBundles(cfg.Storage.BundleKey, inventoryService, inventoryStore, dataDir)
+		if err != nil {
+			logger.Warn("Export bundles disabled", "error", err)
+			boot.Degrade("bundles", err.Error())
+		} else {
+			adminHandler.SetBundles(bundles)
+			logger.Info("Export bundles enabled")
+			boot.OK("bundles", "")
+		}
+	} else {
+		boot.Disable("bundles", "BUNDLE_KEY empty")
+	}
+	if cfg.Storage.SQLConsoleEnabled {
+		console, err := repository.OpenSQLConsole(filepath.Join(dataDir, repository.PrimaryDBName), repository.SQLConsoleOptions{
+			MaxRows:  cfg.Storage.SQLConsoleMaxRows,
+			MaxBytes: cfg.Storage.SQLConsoleMaxBytes,
+			Timeout:  cfg.Storage.SQLConsoleTimeout,
+		})
+		if err != nil {
+			logger.Warn("SQL console disabled", "error", err)
+			boot.Degrade("sql_console", err.Error())
+		} else {
+			shutdown.AddCloser(lifecycle.PhaseWorkers, "sql_console", console)
+			adminHandler.SetSQLConsole(console)
+			logger.Info("SQL console enabled (read-only)", "max_rows", cfg.Storage.SQLConsoleMaxRows, "timeout", cfg.Storage.SQLConsoleTimeout)
+			boot.OK("sql_console", "read-only")
+		}
+	} else {
+		boot.Disable("sql_console", "SQL_CONSOLE_ENABLED not set")
+	}
+
+	// Retention engine prunes auxiliary tables (flush log, integrity issues, ...)
+	retention := repository.NewRetentionEngine(primaryDB, cfg.Storage.RetentionBatch)
+	retention.SetBusyFunc(flushPipeline.Active)
+	if cfg.Storage.LogArchiveDir != "" {
+		archive, err := repository.NewLogArchive(cfg.Storage.LogArchiveDir)
+		if err != nil {
+			logger.Warn("Log archive disabled, pruned rows are deleted", "error", err)
+			boot.Degrade("log_archive", err.Error())
+		} else {
+			retention.SetArchive(archive)
+			adminHandler.SetLogArchive(archive)
+			boot.OK("log_archive", cfg.Storage.LogArchiveDir)
+		}
+	} else {
+		boot.Disable("log_archive", "LOG_ARCHIVE_DIR empty")
+	}
+	if cfg.Storage.RetentionInterval > 0 {
+		retention.Start(cfg.Storage.RetentionInterval)
+		shutdown.AddFunc(lifecycle.PhaseWorkers, "retention", retention.Close)
+		boot.OK("retention", "every "+cfg.Storage.RetentionInterval.String())
+	} else {
+		boot.Disable("retention", "RETENTION_INTERVAL=0")
+	}
+	adminHandler.SetRetentionEngine(retention)
+
+	// SQLite maintenance: incremental vacuum and ANALYZE on every file
+	sqliteFiles := inventoryStore.Partitions()
+	if cfg.Storage.SQLiteShards > 0 {
+		sqliteFiles = append(sqliteFiles, primaryDB) // Auxiliary tables
+	}
+	files := make([]handler.SQLiteFile, len(sqliteFiles))
+	for i, file := range sqliteFiles {
+		files[i] = file
+		if cfg.Storage.SQLiteMaintenanceInterval > 0 {
+			file.StartMaintenance(cfg.Storage.SQLiteMaintenanceInterval)
+		}
+	}
+	adminHandler.SetSQLiteFiles(files)
+	if cfg.Storage.BackupDir != "" {
+		backups, err := repository.NewBackups(cfg.Storage.BackupDir, cfg.Storage.BackupKeep, sqliteFiles)
+		if err != nil {
+			logger.Warn("Backups disabled", "error", err)
+			boot.Degrade("backups", err.Error())
+		} else {
+			adminHandler.SetBackups(backups)
+			boot.OK("backups", fmt.Sprintf("%s, keep %d", cfg.Storage.BackupDir, cfg.Storage.BackupKeep))
+		}
+	} else {
+		boot.Disable("backups", "BACKUP_DIR empty")
+	}
+	if cfg.Storage.SQLiteMaintenanceInterval > 0 {
+		boot.OK("sqlite_maintenance", "every "+cfg.Storage.SQLiteMaintenanceInterval.String())
+	} else {
+		boot.Disable("sqlite_maintenance", "SQLITE_MAINTENANCE_INTERVAL=0")
+	}
+
+	// Inventory retention deletes rows of users who stopped syncing
+	if cfg.Storage.RetentionMaxAge > 0 {
+		invRetention := service.NewInventoryRetention(inventoryStore, cfg.Storage.RetentionMaxAge)
+		invRetention.Start()
+		shutdown.AddFunc(lifecycle.PhaseWorkers, "inventory_retention", invRetention.Close)
+		adminHandler.SetInventoryRetention(invRetention)
+		boot.OK("inventory_retention", "older than "+cfg.Storage.RetentionMaxAge.String())
+	} else {
+		boot.Disable("inventory_retention", "RETENTION_MAX_AGE=0")
+	}
+
+	// Admin-triggered conversion of stored rows to the current blob format
+	recompressor := service.NewRecompressor(inventoryStore, 0)
+	shutdown.AddFunc(lifecycle.PhaseWorkers, "recompressor", recompressor.Close)
+	adminHandler.SetRecompressor(recompressor)
+
+	// Background integrity verifier (stored hashes vs row contents)
+	if cfg.Storage.IntegrityInterval > 0 {
+		verifier := service.NewIntegrityVerifier(inventoryStore, primaryDB, cfg.Storage.IntegrityBatch)
+		verifier.SetBusyFunc(flushPipeline.Active)
+		verifier.Start(cfg.Storage.IntegrityInterval)
+		shutdown.AddFunc(lifecycle.PhaseWorkers, "integrity_verifier", verifier.Close)
+		adminHandler.SetIntegrityVerifier(verifier)
+		boot.OK("integrity_verifier", "every "+cfg.Storage.IntegrityInterval.String())
+	} else {
+		boot.Disable("integrity_verifier", "INTEGRITY_VERIFY_INTERVAL=0")
+	}
+
+	// Optional queue ingestion (same validation/service path as HTTP sync).
+	// Deferred after the buffer, so it closes first and its in-flight
+	// message lands in the buffer before the final flush.
+	if cfg.Ingest.QueueURL != "" {
+		consumer, err := queue.NewConsumer(queue.Config{
+			URL:               cfg.Ingest.QueueURL,
+			Subject:           cfg.Ingest.Subject,
+			DeadLetterSubject: cfg.Ingest.DeadLetterSubject,
+			NATSStream:        cfg.Ingest.NATSStream,
+			NATSConsumer:      cfg.Ingest.NATSConsumer,
+		}, inventoryService)
+		if err != nil {
+			logger.Warn("Queue ingestion disabled", "error", err)
+			boot.Degrade("queue_consumer", err.Error())
+		} else {
+			consumer.Start()
+			shutdown.AddCloser(lifecycle.PhaseWorkers, "queue_consumer", consumer)
+			adminHandler.SetIngestConsumer(consumer)
+			logger.Info("Queue ingestion enabled", "subject", cfg.Ingest.Subject)
+			boot.OK("queue_consumer", "subject "+cfg.Ingest.Subject)
+		}
+	} else {
+		boot.Disable("queue_consumer", "INGEST_QUEUE_URL empty")
+	}
 
 	// Token service for session auth (uses same Redis connection)
 	var authHandler *handler.AuthHandler
-	redisForTokens := redis.NewClient(&redis.Options{
-		Addr:     "127.0.0.1:6379",
-		Password: "",
-		DB:       2, // Use different DB from buffer
+	var tokenService *service.TokenService
+	if demoMode {
+		tokenService = service.NewTokenServiceWithStore(service.NewMemoryTokenStore())
+	} else {
+		redisForTokens := redis.NewClient(&redis.Options{
+			Addr:      redisCfg.Addr,
+			Password:  redisCfg.Password,
+			DB:        2, // Use different DB from buffer
+			TLSConfig: redisTLS,
+		})
+		tokenService = service.NewTokenService(redisForTokens)
+	}
+	tokenService.SetExpiry(cfg.Cache.TokenTTL, cfg.Cache.TokenSliding)
+	tokenService.SetRefreshTTL(cfg.Cache.TokenRefreshTTL)
+	switch cfg.Cache.TokenBackend {
+	case service.TokenBackendJWT:
+		if err := tokenService.UseJWT([]byte(cfg.Cache.TokenSigningKey)); err != nil {
+			fatal("Invalid TOKEN_SIGNING_KEY", "error", err)
+		}
+		if cfg.Cache.TokenSliding {
+			logger.Warn("TOKEN_SLIDING has no effect with TOKEN_BACKEND=jwt")
+		}
+		logger.Info("Session tokens: signed (JWT)")
+	case service.TokenBackendRedis, "":
+	default:
+		fatal("Invalid TOKEN_BACKEND (want redis or jwt)", "backend", cfg.Cache.TokenBackend)
+	}
+	tokenService.SetValidationCache(service.TokenCacheConfig{
+		TTL:         cfg.Cache.TokenCacheTTL,
+		NegativeTTL: cfg.Cache.TokenNegativeCacheTTL,
+		MaxEntries:  cfg.Cache.TokenCacheSize,
 	})
-	tokenService := service.NewTokenService(redisForTokens)
-	middleware.SetTokenService(tokenService)
-	
-	// Auth handler requires MySQL key_accounts repo
-	if mainDB != nil {
-		mysqlKeyRepo := repository.NewMySQLKeyAccountRepository(mainDB)
-		authHandler = handler.NewAuthHandler(tokenService, mysqlKeyRepo)
-		log.Println("✓ Token auth enabled (Redis DB=2)")
+	tokenService.SetInvalidationBus(invalidationBus)
+	adminHandler.SetTokenCache(tokenService)
+	if invalidationBus != nil {
+		adminHandler.SetInvalidationBus(invalidationBus)
+	}
+	var authOpts []middleware.AuthOption
+	if len(cfg.Inventory.ExistsAPIKeys) > 0 {
+		authOpts = append(authOpts, middleware.WithScopedKeys(service.ScopeInventoryExists, middleware.StaticKeys(cfg.Inventory.ExistsAPIKeys)))
+	}
+	if len(cfg.Inventory.SyncUnthrottledAPIKeys) > 0 {
+		authOpts = append(authOpts, middleware.WithScopedKeys(service.ScopeSyncUnthrottled, middleware.StaticKeys(cfg.Inventory.SyncUnthrottledAPIKeys)))
+	}
+	for _, key := range cfg.Server.ScopedAPIKeys {
+		for _, scope := range key.Scopes {
+			if !middleware.KnownScope(scope) {
+				fatal("Invalid API_KEYS_JSON scope", "scope", scope, "key_id", middleware.KeyID(key.Key))
+			}
+		}
+		authOpts = append(authOpts, middleware.WithKeyScopes(key.Scopes, middleware.StaticKeys{key.Key}))
+	}
+	if n := len(cfg.Server.ScopedAPIKeys); n > 0 {
+		logger.Info("Scoped API keys loaded from API_KEYS_JSON", "keys", n)
+	}
+
+	// API keys managed through the admin API, checked after the env keys
+	apiKeys := service.NewAPIKeyStore(primaryDB, service.APIKeyRefreshInterval)
+	if err := apiKeys.Load(context.Background()); err != nil {
+		logger.Warn("Database API keys not loaded, retrying in the background", "error", err)
+		boot.Degrade("api_keys", err.Error())
+	} else {
+		boot.OK("api_keys", "")
+	}
+	apiKeys.Start()
+	shutdown.AddFunc(lifecycle.PhaseWorkers, "api_keys", apiKeys.Close)
+	authOpts = append(authOpts, middleware.WithKeyStore(apiKeys))
+	adminHandler.SetAPIKeys(apiKeys)
+	keyHashes, err := middleware.ParseKeyHashes(cfg.Server.APIKeyHashes)
+	if err != nil {
+		fatal("Invalid API_KEY_HASHES", "error", err)
+	}
+	if len(keyHashes) > 0 {
+		logger.Info("API key hashes loaded from API_KEY_HASHES", "hashes", len(keyHashes))
+	}
+	if cfg.App.IsProduction() && (os.Getenv("API_KEYS") != "" || os.Getenv("API_KEY") != "") {
+		logger.Warn("API_KEYS holds plaintext API keys in production; set API_KEY_HASHES instead")
+	}
+	adminHashes, err := middleware.ParseKeyHashes(cfg.Server.AdminAPIKeyHashes)
+	if err != nil {
+		fatal("Invalid ADMIN_API_KEY_HASHES", "error", err)
+	}
+	switch {
+	case len(cfg.Server.AdminAPIKeys) > 0 || len(adminHashes) > 0:
+		authOpts = append(authOpts, middleware.WithAdminKeys(middleware.AnyKeys{middleware.StaticKeys(cfg.Server.AdminAPIKeys), adminHashes}))
+		boot.OK("admin_auth", "")
+	case cfg.Server.ScopedAPIKeys.HasScope(service.ScopeAdmin):
+		logger.Warn("ADMIN_API_KEY not set: only API_KEYS_JSON keys scoped to admin can use the admin API")
+		boot.OK("admin_auth", "admin scoped keys only")
+	case cfg.App.IsProduction():
+		fatal("ADMIN_API_KEY (or ADMIN_API_KEY_HASHES) is required in production")
+	default:
+		logger.Warn("ADMIN_API_KEY not set: the admin API and dashboard are disabled")
+		boot.Disable("admin_auth", "ADMIN_API_KEY not set, admin API disabled")
+	}
+	auth := middleware.NewAuthMiddleware(tokenService, middleware.AnyKeys{middleware.EnvKeys{}, keyHashes}, authOpts...)
+	adminHandler.SetSupportTokens(tokenService)
+	adminHandler.SetAccountSessions(tokenService)
+
+	// Auth handler requires a key_accounts repo
+	if authKeyRepo != nil {
+		authHandler = handler.NewAuthHandler(tokenService, authKeyRepo)
+		if accounts, ok := authKeyRepo.(repository.KeyAccountInfoReader); ok {
+			authHandler.SetAccountInfo(accounts)
+		}
+		if beats, ok := authKeyRepo.(repository.KeyAccountHeartbeats); ok {
+			heartbeats := service.NewHeartbeatRecorder(beats, cfg.Database.HeartbeatFlushInterval, cfg.Database.HeartbeatOfflineAfter)
+			heartbeats.Start()
+			shutdown.AddFunc(lifecycle.PhaseWorkers, "heartbeats", heartbeats.Close)
+			authHandler.SetHeartbeats(heartbeats)
+			adminHandler.SetHeartbeats(heartbeats)
+		}
+		if demoMode {
+			logger.Info("Token auth enabled (in-memory tokens)")
+			boot.OK("token_service", "in-memory tokens")
+		} else {
+			logger.Info("Token auth enabled (Redis DB=2)")
+			boot.OK("token_service", "Redis DB=2")
+		}
 	} else {
-		log.Println("⚠ Token auth disabled (no MySQL connection)")
+		logger.Warn("Token auth disabled (no MySQL connection)")
+		boot.Degrade("token_service", "no key account repository (MySQL unavailable)")
+	}
+
+	if demoMode {
+		bootstrapDemoData(demoKeys, inventoryStore, tokenService)
 	}
 
-	router := httpTransport.NewRouter(httpHandler, invHandler, adminHandler, authHandler)
+	middleware.SetSLOObjectives(middleware.SLOObjectives{
+		Sync:   cfg.SLO.SyncLatency,
+		Read:   cfg.SLO.ReadLatency,
+		Auth:   cfg.SLO.AuthLatency,
+		Target: cfg.SLO.Target,
+	})
+	// Sync Idempotency-Keys are shared through Redis when there is one
+	var idempotencyStore cache.IdempotencyStore
+	switch {
+	case cfg.Inventory.IdempotencyTTL <= 0:
+		boot.Disable("sync_idempotency", "SYNC_IDEMPOTENCY_TTL=0")
+	case redisBuffer != nil:
+		idempotencyStore = cache.NewRedisIdempotencyStore(redis.NewClient(&redis.Options{
+			Addr:      redisCfg.Addr,
+			Password:  redisCfg.Password,
+			DB:        redisCfg.DB,
+			TLSConfig: redisTLS,
+		}))
+		boot.OK("sync_idempotency", fmt.Sprintf("Redis, keys kept %s", cfg.Inventory.IdempotencyTTL))
+	default:
+		idempotencyStore = cache.NewMemoryIdempotencyStore()
+		boot.OK("sync_idempotency", fmt.Sprintf("in-memory, keys kept %s", cfg.Inventory.IdempotencyTTL))
+	}
+
+	response.SetCursorSecret(cfg.Server.CursorSecret, cfg.Server.CursorTTL)
+	routerOpts := httpTransport.RouterOptions{
+		DisabledMiddleware: cfg.Server.DisabledMiddleware,
+		Auth:               auth,
+		MaxBodyBytes:       cfg.Server.MaxBodySize,
+		BatchMaxBodyBytes:  cfg.Server.BatchMaxBodySize,
+		IdempotencyStore:   idempotencyStore,
+		IdempotencyTTL:     cfg.Inventory.IdempotencyTTL,
+		OpenProfiling:      cfg.App.Debug,
+		AccessLogExclude:   cfg.Log.AccessExclude,
+	}
+	if cfg.App.Debug {
+		logger.Warn("APP_DEBUG: /debug/pprof is served without auth")
+		boot.OK("pprof", "no auth (APP_DEBUG)")
+	} else {
+		boot.OK("pprof", "admin API key required")
+	}
+	if cfg.Server.Compression {
+		routerOpts.CompressMinBytes = cfg.Server.CompressionMinBytes
+	}
+	adminNets, err := middleware.ParseIPAllowlist(cfg.Server.AdminIPAllowlist)
+	if err != nil {
+		fatal("Invalid ADMIN_IP_ALLOWLIST", "error", err)
+	}
+	routerOpts.AdminIPAllowlist = adminNets
+	routerOpts.TrustProxy = cfg.Server.TrustProxy
+	if len(adminNets) > 0 {
+		boot.OK("admin_ip_allowlist", strings.Join(cfg.Server.AdminIPAllowlist, ", "))
+	}
+	switch {
+	case len(cfg.Server.CORSAllowedOrigins) > 0:
+		routerOpts.CORS = &httpTransport.CORSOptions{
+			AllowedOrigins:   cfg.Server.CORSAllowedOrigins,
+			AllowCredentials: cfg.Server.CORSAllowCredentials,
+			MaxAge:           cfg.Server.CORSMaxAge,
+		}
+		if routerOpts.CORS.AllowCredentials && routerOpts.CORS.AllowsAnyOrigin() {
+			logger.Warn("CORS_ALLOW_CREDENTIALS ignored: browsers refuse credentials with origin *")
+		}
+		boot.OK("cors", strings.Join(cfg.Server.CORSAllowedOrigins, ", "))
+	case cfg.App.IsProduction():
+		routerOpts.CORS = &httpTransport.CORSOptions{MaxAge: cfg.Server.CORSMaxAge}
+		logger.Warn("CORS: no CORS_ALLOWED_ORIGINS, cross-origin requests are denied")
+		boot.OK("cors", "cross-origin requests denied")
+	default:
+		boot.OK("cors", "any origin (development)")
+	}
+	router := httpTransport.NewRouterWithOptions(routerOpts, httpHandler, invHandler, adminHandler, authHandler)
+	for _, line := range httpTransport.DescribeChains(routerOpts) {
+		logger.Info("Middleware chain", "chain", line)
+	}
 
 	// Configure HTTP server
 	server := &http.Server{
@@ -167,39 +860,62 @@ func main() {
 		WriteTimeout: cfg.Server.WriteTimeout,
 	}
 
+	// Boot report: one parseable line for deployment tooling
+	boot.AddListen(cfg.Server.Address())
+	boot.Finish()
+	if err := boot.Emit(os.Stderr); err != nil {
+		logger.Warn("Boot report not emitted", "error", err)
+	}
+	if cfg.App.BootReportFile {
+		if err := boot.WriteFile(filepath.Join(dataDir, "last_boot.json")); err != nil {
+			logger.Warn("Boot report not saved", "error", err)
+		}
+	}
+	if boot.Degraded {
+		logger.Warn("Started DEGRADED - see the boot report")
+	}
+
 	// Start server in goroutine
-	go func() {
-		log.Printf("HTTP server listening on %s", cfg.Server.Address())
-		log.Println("Available endpoints:")
-		log.Println("  GET  /api/v1/health")
-		log.Println("  POST /api/v1/auth/token (Get session token)")
-		log.Println("  POST /api/v1/inventory/{roblox_user_id}/sync")
-		log.Println("  GET  /api/v1/inventory/{roblox_user_id}")
-		log.Println("  GET  /api/v1/admin/stats")
-		log.Println("  GET  /admin  (Dashboard UI)")
+	serverErr := make(chan error, 1)
+	lifecycle.Go("http.server", func() {
+		logger.Info("HTTP server listening", "addr", cfg.Server.Address())
+		logger.Info("Available endpoints:")
+		logger.Info("  GET  /api/v1/health")
+		logger.Info("  POST /api/v1/auth/token (Get session token)")
+		logger.Info("  POST /api/v1/inventory/{roblox_user_id}/sync")
+		logger.Info("  GET  /api/v1/inventory/{roblox_user_id}")
+		logger.Info("  GET  /api/v1/admin/stats")
+		logger.Info("  GET  /admin  (Dashboard UI)")
 		
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server error: %v", err)
+			serverErr <- err
 		}
-	}()
+	})
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal, or the server failing
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	exitCode := 0
+	select {
+	case <-quit:
+	case err := <-serverErr:
+		logger.Error("Server error", "error", err)
+		exitCode = 1
+	}
 
-	log.Println("Shutting down server...")
+	logger.Info("Shutting down server...")
 
-	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
-	defer cancel()
-
-	// Shutdown HTTP server
-	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+	// In-flight syncs still add to the buffer, so the server drains before
+	// the final flush
+	shutdown.Add(lifecycle.PhaseServer, "http", cfg.Server.ShutdownTimeout, server.Shutdown)
+	if err := shutdown.Run(); err != nil {
+		logger.Warn("Shutdown incomplete", "error", err)
+		exitCode = 1
 	}
-
-	log.Println("Server stopped gracefully")
+	if exitCode == 0 {
+		logger.Info("Server stopped gracefully")
+	}
+	os.Exit(exitCode)
 }
 
 // connectDB establishes a connection to a MySQL database.
@@ -231,6 +947,53 @@ func connectDB(host string, port int, user, password, dbName, label string) (*sq
 	return db, nil
 }
 
+// openBundles creates the export bundle service. Manifests name this host
+// as the source; uploads are staged under the data directory.
+func openBundles(hexKey string, inventory *service.InventoryService, store repository.InventoryStore, dataDir string) (*service.BundleService, error) {
+	key, err := bundle.ParseKey(hexKey)
+	if err != nil {
+		return nil, err
+	}
+	source, _ := os.Hostname()
+	return service.NewBundleService(inventory, store, key, source, filepath.Join(dataDir, "bundles"))
+}
+
+// redisBufferConfig builds the Redis write buffer's settings from the cache
+// config. Zero values are filled in by NewRedisInventoryBuffer.
+func redisBufferConfig(c config.CacheConfig) cache.RedisBufferConfig {
+	return cache.RedisBufferConfig{
+		Addr:               fmt.Sprintf("%s:%d", c.RedisHost, c.RedisPort),
+		Password:           c.RedisPassword,
+		DB:                 c.BufferDB,
+		FlushInterval:      c.BufferFlushInterval,
+		KeyPrefix:          c.BufferKeyPrefix,
+		MaxBatchSize:       c.BufferMaxBatch,
+		StaleDataThreshold: c.BufferStaleThreshold,
+		DeadLetterAfter:    c.BufferDeadLetterAfter,
+		FlushAlertAfter:    c.BufferFlushAlertAfter,
+		MaxPending:         c.MaxPending,
+		BreakerThreshold:   c.RedisBreakerThreshold,
+		BreakerCooldown:    c.RedisBreakerCooldown,
+		TLS: cache.RedisTLSConfig{
+			Enabled:            c.RedisTLS,
+			CACertFile:         c.RedisTLSCACert,
+			ServerName:         c.RedisTLSServerName,
+			InsecureSkipVerify: c.RedisTLSInsecure,
+		},
+		Watchdog: cache.FlushWatchdogConfig{
+			MinBatch:  c.FlushBatchMin,
+			MaxBatch:  c.FlushBatchMax,
+			SoftLimit: c.FlushSoftLimit,
+		},
+	}
+}
+
+// fatal logs msg at ERROR and exits, like log.Fatal.
+func fatal(msg string, args ...interface{}) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
 // init sets up logging format
 func init() {
 	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds | log.Lshortfile)
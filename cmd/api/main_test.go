@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"vinzhub-rest-api/internal/config"
+)
+
+func TestRedisBufferConfig(t *testing.T) {
+	got := redisBufferConfig(config.CacheConfig{
+		RedisHost:            "redis.internal",
+		RedisPort:            6380,
+		RedisPassword:        "secret",
+		BufferDB:             3,
+		BufferKeyPrefix:      "game2:inventory",
+		BufferFlushInterval:  5 * time.Second,
+		BufferMaxBatch:       200,
+		BufferStaleThreshold: 15 * time.Minute,
+		FlushBatchMax:        100,
+	})
+	if got.Addr != "redis.internal:6380" || got.Password != "secret" || got.DB != 3 || got.KeyPrefix != "game2:inventory" {
+		t.Errorf("connection = %s, db %d, prefix %q", got.Addr, got.DB, got.KeyPrefix)
+	}
+	if got.FlushInterval != 5*time.Second || got.MaxBatchSize != 200 || got.StaleDataThreshold != 15*time.Minute || got.Watchdog.MaxBatch != 100 {
+		t.Errorf("buffer = flush %v, batch %d, stale %v, watchdog max %d",
+			got.FlushInterval, got.MaxBatchSize, got.StaleDataThreshold, got.Watchdog.MaxBatch)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"vinzhub-rest-api/internal/repository"
+)
+
+// runMigrate implements `api migrate`.
+// Opens every database file in the data directory, which applies pending
+// schema migrations, and prints each file's schema version. The server
+// migrates at startup as well; this runs it ahead of a deploy.
+func runMigrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dataDir := fs.String("data-dir", "./data", "Data directory holding inventory.db")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	store, primary, err := repository.OpenRecordedInventoryStore(*dataDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+	defer primary.Close()
+
+	files := store.Partitions()
+	if store != repository.InventoryStore(primary) {
+		defer store.Close()
+		files = append(files, primary)
+	}
+	for _, file := range files {
+		status, err := file.MigrationStatus(context.Background())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+			return 1
+		}
+		fmt.Printf("%s: schema version %d (latest %d)\n", status.File, status.Version, status.Latest)
+	}
+	return 0
+}
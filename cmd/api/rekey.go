@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"vinzhub-rest-api/internal/cache"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// runRekeyBuffer implements `api rekey-buffer`.
+// Moves buffered entries from one key prefix to another, keeping the newer
+// entry when a user/section exists under both. Safe to run against a live
+// server and to rerun.
+func runRekeyBuffer(args []string) int {
+	fs := flag.NewFlagSet("rekey-buffer", flag.ContinueOnError)
+	redisAddr := fs.String("redis-addr", "127.0.0.1:6379", "Redis address of the buffer")
+	redisPassword := fs.String("redis-password", "", "Redis password")
+	redisDB := fs.Int("redis-db", 1, "Redis database number")
+	from := fs.String("from", "", "Old buffer key prefix")
+	to := fs.String("to", "vinzhub:fishit:inventory", "New buffer key prefix")
+	dryRun := fs.Bool("dry-run", false, "Report what would be moved without writing")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *from == "" {
+		fmt.Fprintln(os.Stderr, "rekey-buffer: --from is required")
+		return 2
+	}
+
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: *redisAddr, Password: *redisPassword, DB: *redisDB})
+	defer client.Close()
+	if err := client.Ping(ctx).Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "rekey-buffer: failed to connect to Redis: %v\n", err)
+		return 1
+	}
+
+	result, err := cache.RekeyBuffer(ctx, client, *from, *to, *dryRun, func(p cache.RekeyResult) {
+		fmt.Fprintf(os.Stderr, "scanned %d: moved %d, replaced %d, dropped older %d, changed %d\n",
+			p.Scanned, p.Moved, p.ReplacedNew, p.DroppedOlder, p.Changed)
+	})
+	if result != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rekey-buffer: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// checkLegacyBufferPrefix looks for entries still buffered under a previous
+// prefix, which would never be flushed, and migrates them or warns.
+func checkLegacyBufferPrefix(buffer *cache.RedisInventoryBuffer, legacyPrefix string, autoMigrate bool) {
+	if legacyPrefix == "" || legacyPrefix == buffer.KeyPrefix() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	count, err := buffer.PrefixSize(ctx, legacyPrefix)
+	if err != nil {
+		logger.Warn("Failed to check legacy buffer prefix", "prefix", legacyPrefix, "error", err)
+		return
+	}
+	if count == 0 {
+		return
+	}
+
+	if !autoMigrate {
+		logger.Warn("Buffered entries are stranded under a legacy prefix and will NOT be flushed. "+
+			"Run `api rekey-buffer --from <prefix> --to <key_prefix>`, POST /admin/buffer/rekey, or set LEGACY_KEY_PREFIX_AUTO_MIGRATE=true",
+			"entries", count, "prefix", legacyPrefix, "key_prefix", buffer.KeyPrefix())
+		return
+	}
+
+	result, err := buffer.RekeyFrom(ctx, legacyPrefix, false, nil)
+	if err != nil {
+		logger.Warn("Legacy buffer migration failed", "prefix", legacyPrefix, "error", err)
+		return
+	}
+	logger.Info("Migrated legacy buffer prefix", "prefix", legacyPrefix, "moved", result.Moved,
+		"replaced", result.ReplacedNew, "dropped_older", result.DroppedOlder, "remaining", result.Remaining)
+}
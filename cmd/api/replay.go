@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"vinzhub-rest-api/internal/cache"
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/service"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// replayOutcome is the per-item result printed by replay-buffer.
+type replayOutcome struct {
+	Field        string     `json:"field"`
+	RobloxUserID string     `json:"roblox_user_id,omitempty"`
+	Section      string     `json:"section,omitempty"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+	Outcome      string     `json:"outcome"` // written, would_write, skipped_older, invalid
+	Detail       string     `json:"detail,omitempty"`
+}
+
+// runReplayBuffer implements `api replay-buffer`.
+// Reads buffered entries from a Redis instance (or a JSON export of the buffer
+// hash), validates them, and writes them to SQLite through
+// BatchUpsertRawInventory. Entries older than what SQLite already holds for
+// the same user/section are skipped so newer production data wins.
+func runReplayBuffer(args []string) int {
+	fs := flag.NewFlagSet("replay-buffer", flag.ContinueOnError)
+	redisAddr := fs.String("redis-addr", "", "Redis address to read the buffer from (e.g. 127.0.0.1:6380)")
+	redisPassword := fs.String("redis-password", "", "Redis password")
+	redisDB := fs.Int("redis-db", 1, "Redis database number")
+	prefix := fs.String("prefix", "vinzhub:fishit:inventory", "Buffer key prefix")
+	input := fs.String("input", "", "JSON export of the buffer hash (object of field -> entry, or array of entries)")
+	dataDir := fs.String("data-dir", "./data", "Target data directory (sharded or not)")
+	dryRun := fs.Bool("dry-run", false, "Report what would be written without writing")
+	batchSize := fs.Int("batch", 500, "Items per BatchUpsertRawInventory call")
+	itemCountPath := fs.String("item-count-path", os.Getenv("INVENTORY_ITEM_COUNT_PATH"), "Path of the items counted in each document (see INVENTORY_ITEM_COUNT_PATH)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if (*redisAddr == "") == (*input == "") {
+		fmt.Fprintln(os.Stderr, "replay-buffer: exactly one of --redis-addr or --input is required")
+		return 2
+	}
+
+	ctx := context.Background()
+
+	var (
+		raw map[string][]byte
+		err error
+	)
+	if *input != "" {
+		raw, err = readBufferExport(*input)
+	} else {
+		raw, err = readBufferFromRedis(ctx, *redisAddr, *redisPassword, *redisDB, *prefix)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay-buffer: %v\n", err)
+		return 1
+	}
+
+	repo, primary, err := repository.OpenRecordedInventoryStore(*dataDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay-buffer: %v\n", err)
+		return 1
+	}
+	defer primary.Close()
+	defer repo.Close()
+
+	outcomes, items := planReplay(ctx, repo, raw, *dryRun, service.NewItemCounter(*itemCountPath))
+
+	// Write in synced_at order so the replay is deterministic
+	if !*dryRun {
+		for start := 0; start < len(items); start += *batchSize {
+			end := start + *batchSize
+			if end > len(items) {
+				end = len(items)
+			}
+			if err := repo.BatchUpsertRawInventory(ctx, items[start:end]); err != nil {
+				fmt.Fprintf(os.Stderr, "replay-buffer: write failed after %d items: %v\n", start, err)
+				return 1
+			}
+		}
+	}
+
+	counts := make(map[string]int)
+	enc := json.NewEncoder(os.Stdout)
+	for _, o := range outcomes {
+		counts[o.Outcome]++
+		_ = enc.Encode(o)
+	}
+	fmt.Fprintf(os.Stderr, "replay-buffer: %d entries, %d written, %d would_write, %d skipped_older, %d invalid (dry-run=%v)\n",
+		len(outcomes), counts["written"], counts["would_write"], counts["skipped_older"], counts["invalid"], *dryRun)
+	return 0
+}
+
+// planReplay decodes every entry, compares it with the stored row and returns
+// the per-entry outcomes plus the items that should be written, oldest first,
+// with their items counted by counter.
+func planReplay(ctx context.Context, repo repository.InventoryStore, raw map[string][]byte, dryRun bool, counter *service.ItemCounter) ([]replayOutcome, []repository.InventoryItem) {
+	fields := make([]string, 0, len(raw))
+	for field := range raw {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var (
+		outcomes []replayOutcome
+		valid    []*cache.BufferedInventory
+		byInv    = make(map[*cache.BufferedInventory]int)
+	)
+	for _, field := range fields {
+		inv, err := cache.DecodeBufferedInventory(raw[field])
+		if err != nil {
+			outcomes = append(outcomes, replayOutcome{Field: field, Outcome: "invalid", Detail: err.Error()})
+			continue
+		}
+		byInv[inv] = len(outcomes)
+		outcomes = append(outcomes, replayOutcome{
+			Field:        field,
+			RobloxUserID: inv.RobloxUserID,
+			Section:      inv.SectionName(),
+			UpdatedAt:    &inv.UpdatedAt,
+		})
+		valid = append(valid, inv)
+	}
+
+	sort.SliceStable(valid, func(i, j int) bool {
+		return valid[i].UpdatedAt.Before(valid[j].UpdatedAt)
+	})
+
+	var items []repository.InventoryItem
+	for _, inv := range valid {
+		o := &outcomes[byInv[inv]]
+
+		_, storedAt, err := repo.GetRawInventorySection(ctx, inv.RobloxUserID, inv.SectionName())
+		if err != nil {
+			o.Outcome = "invalid"
+			o.Detail = "failed to read stored row: " + err.Error()
+			continue
+		}
+		if storedAt != nil && !inv.UpdatedAt.After(*storedAt) {
+			o.Outcome = "skipped_older"
+			o.Detail = "stored synced_at " + storedAt.UTC().Format(time.RFC3339Nano)
+			continue
+		}
+
+		if dryRun {
+			o.Outcome = "would_write"
+		} else {
+			o.Outcome = "written"
+		}
+		items = append(items, repository.InventoryItem{
+			KeyAccountID: inv.KeyAccountID,
+			RobloxUserID: inv.RobloxUserID,
+			Section:      inv.SectionName(),
+			RawJSON:      inv.RawJSON,
+			SyncedAt:     inv.UpdatedAt,
+			ItemCount:    counter.Count(inv.RawJSON),
+		})
+	}
+
+	return outcomes, items
+}
+
+// readBufferFromRedis scans the buffer hash of a (possibly secondary) Redis.
+func readBufferFromRedis(ctx context.Context, addr, password string, db int, prefix string) (map[string][]byte, error) {
+	client := redis.NewClient(&redis.Options{Addr: addr, Password: password, DB: db})
+	defer client.Close()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	result := make(map[string][]byte)
+	key := prefix + ":buffer"
+	var cursor uint64
+	for {
+		kvs, next, err := client.HScan(ctx, key, cursor, "*", 500).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", key, err)
+		}
+		for i := 0; i+1 < len(kvs); i += 2 {
+			result[kvs[i]] = []byte(kvs[i+1])
+		}
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+	return result, nil
+}
+
+// readBufferExport reads a JSON export of the buffer hash. Accepted shapes:
+//   - {"<field>": "<entry JSON string>", ...} (redis HGETALL style)
+//   - {"<field>": {<entry>}, ...}
+//   - [{<entry>}, ...]
+func readBufferExport(path string) (map[string][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var asArray []json.RawMessage
+	if err := json.Unmarshal(data, &asArray); err == nil {
+		result := make(map[string][]byte, len(asArray))
+		for i, entry := range asArray {
+			result[fmt.Sprintf("#%d", i)] = entry
+		}
+		return result, nil
+	}
+
+	var asObject map[string]json.RawMessage
+	if err := json.Unmarshal(data, &asObject); err != nil {
+		return nil, fmt.Errorf("unrecognized export format: %w", err)
+	}
+
+	result := make(map[string][]byte, len(asObject))
+	for field, entry := range asObject {
+		var encoded string
+		if err := json.Unmarshal(entry, &encoded); err == nil {
+			result[field] = []byte(encoded)
+			continue
+		}
+		result[field] = entry
+	}
+	return result, nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"vinzhub-rest-api/internal/repository"
+)
+
+// runReshard implements `api reshard`.
+// Moves every inventory row from the current layout (single inventory.db or
+// N shard files) to a new shard count. The server must be stopped. New shards
+// are built in a staging directory and only swapped in after the row counts
+// match; the old files are kept in a pre-reshard-<timestamp> directory.
+func runReshard(args []string) int {
+	fs := flag.NewFlagSet("reshard", flag.ContinueOnError)
+	dataDir := fs.String("data-dir", "./data", "Data directory holding inventory.db")
+	to := fs.Int("to", -1, "Target shard count (0 = single inventory.db)")
+	batchSize := fs.Int("batch", 500, "Items per BatchUpsertRawInventory call")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *to < 0 {
+		fmt.Fprintln(os.Stderr, "reshard: --to is required")
+		return 2
+	}
+
+	if err := reshard(context.Background(), *dataDir, *to, *batchSize); err != nil {
+		fmt.Fprintf(os.Stderr, "reshard: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func reshard(ctx context.Context, dataDir string, to, batchSize int) error {
+	primary, err := repository.NewSQLiteInventoryRepository(filepath.Join(dataDir, repository.PrimaryDBName))
+	if err != nil {
+		return err
+	}
+	defer primary.Close()
+
+	from, err := repository.ReadShardCount(ctx, primary)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		fmt.Fprintf(os.Stderr, "reshard: already at %d shards, nothing to do\n", to)
+		return nil
+	}
+
+	stamp := time.Now().UTC().Format("20060102-150405")
+	backupDir := filepath.Join(dataDir, "pre-reshard-"+stamp)
+	if err := os.Mkdir(backupDir, 0755); err != nil {
+		return err
+	}
+
+	// Source
+	var source repository.InventoryStore = primary
+	if from > 0 {
+		shards, err := repository.OpenShardSet(dataDir, from)
+		if err != nil {
+			return err
+		}
+		defer shards.Close()
+		source = shards
+	} else {
+		// Rows are deleted from inventory.db once copied - back it up first
+		if err := primary.VacuumInto(ctx, filepath.Join(backupDir, repository.PrimaryDBName)); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", repository.PrimaryDBName, err)
+		}
+	}
+
+	// Target
+	stagingDir := filepath.Join(dataDir, "reshard-"+stamp)
+	var target repository.InventoryStore = primary
+	var targetShards *repository.ShardedInventoryRepository
+	if to > 0 {
+		if err := os.MkdirAll(stagingDir, 0755); err != nil {
+			return err
+		}
+		targetShards, err = repository.OpenShardSet(stagingDir, to)
+		if err != nil {
+			return err
+		}
+		defer targetShards.Close()
+		target = targetShards
+	}
+
+	fmt.Fprintf(os.Stderr, "reshard: %d -> %d shards in %s\n", from, to, dataDir)
+
+	// Copy
+	var copied int64
+	batch := make([]repository.InventoryItem, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := target.BatchUpsertRawInventory(ctx, batch); err != nil {
+			return fmt.Errorf("write failed after %d rows: %w", copied, err)
+		}
+		copied += int64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+	err = source.ScanAll(ctx, func(item repository.InventoryItem) error {
+		batch = append(batch, item)
+		if len(batch) >= batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	// Verify
+	sourceStats, err := source.GetStats(ctx)
+	if err != nil {
+		return err
+	}
+	targetStats, err := target.GetStats(ctx)
+	if err != nil {
+		return err
+	}
+	if sourceStats["total_inventories"] != targetStats["total_inventories"] {
+		return fmt.Errorf("row count mismatch after copy (source %v, target %v); live data untouched, staging left in %s",
+			sourceStats["total_inventories"], targetStats["total_inventories"], stagingDir)
+	}
+
+	// Swap
+	if from > 0 {
+		if err := source.Close(); err != nil {
+			return err
+		}
+		for i := 0; i < from; i++ {
+			if err := moveDBFile(dataDir, backupDir, repository.ShardFileName(i)); err != nil {
+				return err
+			}
+		}
+	} else {
+		if _, err := primary.DeleteAllInventories(ctx); err != nil {
+			return err
+		}
+	}
+
+	if to > 0 {
+		if err := targetShards.Close(); err != nil {
+			return err
+		}
+		for i := 0; i < to; i++ {
+			if err := moveDBFile(stagingDir, dataDir, repository.ShardFileName(i)); err != nil {
+				return err
+			}
+		}
+		os.Remove(stagingDir)
+	}
+
+	if err := repository.WriteShardCount(ctx, primary, to); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "reshard: moved %d rows; set SQLITE_SHARDS=%d before starting the server (old files in %s)\n",
+		copied, to, backupDir)
+	return nil
+}
+
+// moveDBFile moves a SQLite file and its WAL/SHM companions between directories.
+func moveDBFile(fromDir, toDir, name string) error {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		src := filepath.Join(fromDir, name+suffix)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Rename(src, filepath.Join(toDir, name+suffix)); err != nil {
+			return fmt.Errorf("failed to move %s: %w", src, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"vinzhub-rest-api/internal/cache"
+)
+
+// drainSpool flushes entries spooled by a previous run. With a Redis buffer
+// they are merged with what Redis holds so the newest copy wins; without
+// one they go straight to the database.
+func drainSpool(spool *cache.DiskSpool, buffer *cache.RedisInventoryBuffer, flush cache.FlushFunc, paused bool) {
+	depth := spool.Depth()
+	if depth == 0 {
+		return
+	}
+	if paused {
+		logger.Warn("Spooled entries kept until flushing is resumed", "entries", depth)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	var drained int
+	var err error
+	if buffer != nil {
+		drained, err = buffer.DrainSpool(ctx)
+	} else {
+		drained, err = spool.Drain(ctx, flush)
+	}
+	if err != nil {
+		logger.Warn("Spool partly drained", "drained", drained, "entries", depth, "error", err)
+		return
+	}
+	logger.Info("Drained spooled entries", "entries", drained)
+}
@@ -1,6 +1,6 @@
 module vinzhub-rest-api
 
-go 1.22
+go 1.24.0
 
 require (
 	github.com/go-chi/chi/v5 v5.1.0
@@ -0,0 +1,163 @@
+// Package bootreport collects what came up at startup into one report that
+// deployment tooling can parse to decide whether an instance is healthy.
+//
+// The JSON format is stable: fields are only ever added. A change that
+// would break a parser bumps FormatVersion.
+package bootreport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FormatVersion is the version of the report format.
+const FormatVersion = 1
+
+// Marker prefixes the report line on stderr, so it can be found among the
+// log lines: grep '^BOOT_REPORT ' | cut -d' ' -f2-
+const Marker = "BOOT_REPORT"
+
+// Component statuses.
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded" // Wanted but unavailable; the instance runs without it
+	StatusDisabled = "disabled" // Off by configuration or mode
+)
+
+// Component is the startup outcome of one component.
+type Component struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Report describes one startup.
+type Report struct {
+	FormatVersion int               `json:"format_version"`
+	App           string            `json:"app"`
+	Version       string            `json:"version"`
+	Environment   string            `json:"environment"`
+	Role          string            `json:"role"`
+	PID           int               `json:"pid"`
+	Host          string            `json:"host"`
+	StartedAt     time.Time         `json:"started_at"`
+	ReadyAt       time.Time         `json:"ready_at"`
+	Listen        []string          `json:"listen"`
+	Degraded      bool              `json:"degraded"`
+	Components    []Component       `json:"components"`
+	Config        map[string]string `json:"config"` // Fingerprint per config section
+
+	mu sync.Mutex
+}
+
+// New starts a report for this process.
+func New(app, version, environment, role string) *Report {
+	host, _ := os.Hostname()
+	return &Report{
+		FormatVersion: FormatVersion,
+		App:           app,
+		Version:       version,
+		Environment:   environment,
+		Role:          role,
+		PID:           os.Getpid(),
+		Host:          host,
+		StartedAt:     time.Now().UTC(),
+		Listen:        []string{},
+		Components:    []Component{},
+		Config:        map[string]string{},
+	}
+}
+
+// Set records the status of a component, replacing an earlier one of the
+// same name.
+func (r *Report) Set(name, status, detail string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.Components {
+		if r.Components[i].Name == name {
+			r.Components[i] = Component{Name: name, Status: status, Detail: detail}
+			return
+		}
+	}
+	r.Components = append(r.Components, Component{Name: name, Status: status, Detail: detail})
+}
+
+// OK, Degrade and Disable are shorthands for Set.
+func (r *Report) OK(name, detail string)      { r.Set(name, StatusOK, detail) }
+func (r *Report) Degrade(name, detail string) { r.Set(name, StatusDegraded, detail) }
+func (r *Report) Disable(name, detail string) { r.Set(name, StatusDisabled, detail) }
+
+// AddListen records an address the instance serves on.
+func (r *Report) AddListen(addr string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Listen = append(r.Listen, addr)
+}
+
+// SetConfig records the config fingerprints.
+func (r *Report) SetConfig(fingerprints map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Config = fingerprints
+}
+
+// Finish stamps the report ready and works out whether any component is
+// degraded. Call it once every component has been recorded.
+func (r *Report) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ReadyAt = time.Now().UTC()
+	r.Degraded = false
+	for _, c := range r.Components {
+		if c.Status == StatusDegraded {
+			r.Degraded = true
+		}
+	}
+}
+
+// Marshal returns the report as one line of JSON.
+func (r *Report) Marshal() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return json.Marshal(r)
+}
+
+// Emit writes the report to w as a single "BOOT_REPORT {...}" line.
+func (r *Report) Emit(w io.Writer) error {
+	data, err := r.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to encode boot report: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "%s %s\n", Marker, data)
+	return err
+}
+
+// WriteFile saves the report to path, replacing the previous one in a
+// single rename so readers never see a partial file.
+func (r *Report) WriteFile(path string) error {
+	data, err := r.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to encode boot report: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".last_boot-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write boot report: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write boot report: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write boot report: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write boot report: %w", err)
+	}
+	return nil
+}
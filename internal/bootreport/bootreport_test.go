@@ -0,0 +1,89 @@
+package bootreport
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReportDegraded(t *testing.T) {
+	r := New("api", "1.0.0", "test", "api")
+	r.OK("sqlite", "")
+	r.Disable("mysql", "demo mode")
+	r.Finish()
+	if r.Degraded {
+		t.Error("disabled component made the report degraded")
+	}
+
+	r.Degrade("redis_buffer", "connection refused")
+	r.Finish()
+	if !r.Degraded {
+		t.Error("degraded component not reported")
+	}
+	// A later status replaces the earlier one
+	r.OK("redis_buffer", "reconnected")
+	r.Finish()
+	if r.Degraded || len(r.Components) != 3 {
+		t.Errorf("degraded %v with %d components, want recovered with 3", r.Degraded, len(r.Components))
+	}
+}
+
+func TestReportEmit(t *testing.T) {
+	r := New("api", "1.0.0", "test", "api")
+	r.AddListen("127.0.0.1:8080")
+	r.SetConfig(map[string]string{"server": "abc"})
+	r.Degrade("mysql", "down")
+	r.Finish()
+
+	var out bytes.Buffer
+	if err := r.Emit(&out); err != nil {
+		t.Fatal(err)
+	}
+	line, found := strings.CutPrefix(out.String(), Marker+" ")
+	if !found || strings.Count(line, "\n") != 1 || !strings.HasSuffix(line, "\n") {
+		t.Fatalf("Emit = %q, want one marked line", out.String())
+	}
+
+	// The field names are the format deployment tooling parses
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"format_version", "app", "version", "environment", "role", "pid", "host",
+		"started_at", "ready_at", "listen", "degraded", "components", "config"} {
+		if _, ok := doc[field]; !ok {
+			t.Errorf("report lacks %q", field)
+		}
+	}
+	components := doc["components"].([]interface{})
+	if c := components[0].(map[string]interface{}); c["name"] != "mysql" || c["status"] != "degraded" || c["detail"] != "down" {
+		t.Errorf("component = %v", c)
+	}
+}
+
+func TestReportWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "last_boot.json")
+	r := New("api", "1.0.0", "test", "api")
+	r.Finish()
+	for i := 0; i < 2; i++ { // The second write replaces the first
+		if err := r.WriteFile(path); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Report
+	if err := json.Unmarshal(data, &saved); err != nil || saved.PID != os.Getpid() {
+		t.Errorf("saved report = %s (%v)", data, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d files in the data directory, want only last_boot.json", len(entries))
+	}
+}
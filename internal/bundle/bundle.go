@@ -0,0 +1,427 @@
+// Package bundle reads and writes export bundles: a stream of user records,
+// gzip-compressed, encrypted with AES-GCM under a shared key and closed by
+// an HMAC-signed manifest listing every record's hash.
+//
+// Layout: an 8-byte magic, a 4-byte random nonce prefix, then frames of a
+// 4-byte big-endian length followed by the sealed bytes. Frames are sealed
+// with a nonce of the prefix and a frame counter, so they can't be
+// reordered, and the last one (the manifest) is sealed as final, so a
+// truncated bundle is detected. Both ends hold at most one frame and one
+// record in memory.
+package bundle
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	magic = "VZBNDL01"
+
+	// KeySize is the length of the shared bundle key.
+	KeySize = 32
+
+	// frameSize is the plaintext size of a data frame.
+	frameSize = 64 << 10
+	// maxFrameSize bounds a sealed frame, manifest included.
+	maxFrameSize = 64 << 20
+
+	frameData  byte = 0
+	frameFinal byte = 1
+)
+
+// ErrInvalid is returned for bundles that fail authentication or don't
+// match their manifest.
+var ErrInvalid = errors.New("invalid bundle")
+
+// Record is one user in a bundle.
+type Record struct {
+	RobloxUserID string             `json:"roblox_user_id"`
+	Sections     map[string]Section `json:"sections"`
+}
+
+// Section is one section of a user's inventory.
+type Section struct {
+	Data     json.RawMessage `json:"data"`
+	SyncedAt time.Time       `json:"synced_at"`
+	SHA256   string          `json:"sha256"`
+}
+
+// NewSection builds a section, hashing its document. The document is
+// compacted first, as that is how it is encoded in the bundle.
+func NewSection(data []byte, syncedAt time.Time) (Section, error) {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return Section{}, fmt.Errorf("failed to encode section: %w", err)
+	}
+	return Section{Data: compact.Bytes(), SyncedAt: syncedAt.UTC(), SHA256: sectionHash(compact.Bytes())}, nil
+}
+
+func sectionHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Manifest closes a bundle. MAC is the HMAC of the manifest without it.
+type Manifest struct {
+	Version   int             `json:"version"`
+	Source    string          `json:"source,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	Users     int             `json:"users"`
+	Sections  int             `json:"sections"`
+	Entries   []ManifestEntry `json:"entries"`
+	Missing   []string        `json:"missing,omitempty"` // Requested users that had no data
+	MAC       string          `json:"mac,omitempty"`
+}
+
+// ManifestEntry is the hash of one record as written to the bundle.
+type ManifestEntry struct {
+	RobloxUserID string `json:"roblox_user_id"`
+	SHA256       string `json:"sha256"`
+}
+
+// ParseKey decodes a hex-encoded bundle key.
+func ParseKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil || len(key) != KeySize {
+		return nil, fmt.Errorf("bundle key must be %d hex-encoded bytes", KeySize)
+	}
+	return key, nil
+}
+
+// deriveKeys splits the shared key into an encryption and a MAC key.
+func deriveKeys(key []byte) (cipher.AEAD, []byte, error) {
+	if len(key) != KeySize {
+		return nil, nil, fmt.Errorf("bundle key must be %d bytes", KeySize)
+	}
+	encKey := hmacSum(key, []byte("vinzhub bundle encryption"))
+	macKey := hmacSum(key, []byte("vinzhub bundle manifest"))
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, macKey, nil
+}
+
+func hmacSum(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// sign returns the MAC of a manifest, computed without its MAC field.
+func (m Manifest) sign(macKey []byte) (string, error) {
+	m.MAC = ""
+	body, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hmacSum(macKey, body)), nil
+}
+
+// frameCipher seals and opens numbered frames.
+type frameCipher struct {
+	aead   cipher.AEAD
+	prefix [4]byte
+	count  uint64
+}
+
+func (c *frameCipher) nonce() []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+	copy(nonce, c.prefix[:])
+	binary.BigEndian.PutUint64(nonce[4:], c.count)
+	return nonce
+}
+
+func (c *frameCipher) aad(kind byte) []byte {
+	return append(append([]byte(magic), c.prefix[:]...), kind)
+}
+
+// Writer writes a bundle. Add records, then Close to write the manifest.
+type Writer struct {
+	out    io.Writer
+	frames frameCipher
+	macKey []byte
+	buf    bytes.Buffer // Compressed bytes not yet sealed
+	gz     *gzip.Writer
+	source string
+
+	manifest Manifest
+	err      error
+}
+
+// NewWriter starts a bundle on out. source names the exporting deployment
+// in the manifest.
+func NewWriter(out io.Writer, key []byte, source string) (*Writer, error) {
+	aead, macKey, err := deriveKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{out: out, macKey: macKey, source: source}
+	w.frames.aead = aead
+	if _, err := rand.Read(w.frames.prefix[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	if _, err := out.Write(append([]byte(magic), w.frames.prefix[:]...)); err != nil {
+		return nil, err
+	}
+	w.gz = gzip.NewWriter(&w.buf)
+	return w, nil
+}
+
+// Add writes one record.
+func (w *Writer) Add(rec Record) error {
+	if w.err != nil {
+		return w.err
+	}
+	var encoded bytes.Buffer
+	enc := json.NewEncoder(&encoded)
+	enc.SetEscapeHTML(false) // Documents must stay byte for byte as hashed
+	if err := enc.Encode(rec); err != nil {
+		return err
+	}
+	line := encoded.Bytes()
+
+	sum := sha256.Sum256(line)
+	w.manifest.Entries = append(w.manifest.Entries, ManifestEntry{RobloxUserID: rec.RobloxUserID, SHA256: hex.EncodeToString(sum[:])})
+	w.manifest.Users++
+	w.manifest.Sections += len(rec.Sections)
+
+	if _, err := w.gz.Write(line); err != nil {
+		w.err = err
+		return err
+	}
+	for w.buf.Len() >= frameSize {
+		if err := w.writeFrame(frameData, w.buf.Next(frameSize)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Missing records requested users that had nothing to export.
+func (w *Writer) Missing(robloxUserID string) {
+	w.manifest.Missing = append(w.manifest.Missing, robloxUserID)
+}
+
+// Close flushes the records and writes the signed manifest. It does not
+// close the underlying writer.
+func (w *Writer) Close() (*Manifest, error) {
+	if w.err != nil {
+		return nil, w.err
+	}
+	if err := w.gz.Close(); err != nil {
+		return nil, err
+	}
+	for w.buf.Len() > 0 {
+		if err := w.writeFrame(frameData, w.buf.Next(frameSize)); err != nil {
+			return nil, err
+		}
+	}
+
+	w.manifest.Version = 1
+	w.manifest.Source = w.source
+	w.manifest.CreatedAt = time.Now().UTC()
+	mac, err := w.manifest.sign(w.macKey)
+	if err != nil {
+		return nil, err
+	}
+	w.manifest.MAC = mac
+	body, err := json.Marshal(w.manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.writeFrame(frameFinal, body); err != nil {
+		return nil, err
+	}
+	return &w.manifest, nil
+}
+
+func (w *Writer) writeFrame(kind byte, plain []byte) error {
+	sealed := w.frames.aead.Seal(nil, w.frames.nonce(), plain, w.frames.aad(kind))
+	w.frames.count++
+
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(sealed)))
+	if _, err := w.out.Write(header[:]); err != nil {
+		w.err = err
+		return err
+	}
+	if _, err := w.out.Write(sealed); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+// Reader reads a bundle. Next returns records as they are decrypted; the
+// bundle is only known to be intact once Next has returned io.EOF, which
+// it does after checking every record against the signed manifest.
+type Reader struct {
+	in     io.Reader
+	frames frameCipher
+	macKey []byte
+
+	plain    bytes.Buffer // Decrypted compressed bytes not yet consumed
+	final    []byte       // Manifest frame, once reached
+	dec      *json.Decoder
+	hashes   []ManifestEntry
+	manifest *Manifest
+}
+
+// NewReader starts reading a bundle from in.
+func NewReader(in io.Reader, key []byte) (*Reader, error) {
+	aead, macKey, err := deriveKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, len(magic)+4)
+	if _, err := io.ReadFull(in, header); err != nil || string(header[:len(magic)]) != magic {
+		return nil, fmt.Errorf("%w: not a bundle", ErrInvalid)
+	}
+	r := &Reader{in: in, macKey: macKey}
+	r.frames.aead = aead
+	copy(r.frames.prefix[:], header[len(magic):])
+
+	gz, err := gzip.NewReader(frameSource{r})
+	if err != nil {
+		if errors.Is(err, ErrInvalid) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	r.dec = json.NewDecoder(gz)
+	return r, nil
+}
+
+// Next returns the next record, or io.EOF once every record was read and
+// the manifest verified.
+func (r *Reader) Next() (*Record, error) {
+	if r.manifest != nil {
+		return nil, io.EOF
+	}
+
+	var line json.RawMessage
+	err := r.dec.Decode(&line)
+	if err == io.EOF {
+		return nil, r.verify()
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalid) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	var rec Record
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	for name, sec := range rec.Sections {
+		if sectionHash(sec.Data) != sec.SHA256 {
+			return nil, fmt.Errorf("%w: section %s of %s doesn't match its hash", ErrInvalid, name, rec.RobloxUserID)
+		}
+	}
+
+	// Records were written as compact JSON lines; hash them the same way
+	sum := sha256.Sum256(append([]byte(line), '\n'))
+	r.hashes = append(r.hashes, ManifestEntry{RobloxUserID: rec.RobloxUserID, SHA256: hex.EncodeToString(sum[:])})
+	return &rec, nil
+}
+
+// Manifest returns the verified manifest, after Next returned io.EOF.
+func (r *Reader) Manifest() *Manifest {
+	return r.manifest
+}
+
+// verify checks the manifest signature and the records read against it.
+func (r *Reader) verify() error {
+	if r.final == nil {
+		return fmt.Errorf("%w: truncated, no manifest", ErrInvalid)
+	}
+	var m Manifest
+	if err := json.Unmarshal(r.final, &m); err != nil {
+		return fmt.Errorf("%w: unreadable manifest: %v", ErrInvalid, err)
+	}
+	mac, err := m.sign(r.macKey)
+	if err != nil || !hmac.Equal([]byte(mac), []byte(m.MAC)) {
+		return fmt.Errorf("%w: manifest signature mismatch", ErrInvalid)
+	}
+	if len(m.Entries) != len(r.hashes) || m.Users != len(r.hashes) {
+		return fmt.Errorf("%w: manifest lists %d users, bundle holds %d", ErrInvalid, m.Users, len(r.hashes))
+	}
+	for i, entry := range m.Entries {
+		if entry != r.hashes[i] {
+			return fmt.Errorf("%w: record %d (%s) doesn't match the manifest", ErrInvalid, i, r.hashes[i].RobloxUserID)
+		}
+	}
+	r.manifest = &m
+	return io.EOF
+}
+
+// readFrame decrypts the next frame into the plaintext buffer, or keeps
+// the manifest frame aside. Returns io.EOF after the manifest.
+func (r *Reader) readFrame() error {
+	if r.final != nil {
+		// Nothing may follow the manifest
+		if n, _ := r.in.Read(make([]byte, 1)); n > 0 {
+			return fmt.Errorf("%w: data after manifest", ErrInvalid)
+		}
+		return io.EOF
+	}
+
+	var header [4]byte
+	if _, err := io.ReadFull(r.in, header[:]); err != nil {
+		return fmt.Errorf("%w: truncated, no manifest", ErrInvalid)
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxFrameSize {
+		return fmt.Errorf("%w: frame of %d bytes", ErrInvalid, size)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(r.in, sealed); err != nil {
+		return fmt.Errorf("%w: truncated frame", ErrInvalid)
+	}
+
+	nonce := r.frames.nonce()
+	r.frames.count++
+	if plain, err := r.frames.aead.Open(nil, nonce, sealed, r.frames.aad(frameData)); err == nil {
+		r.plain.Write(plain)
+		return nil
+	}
+	plain, err := r.frames.aead.Open(nil, nonce, sealed, r.frames.aad(frameFinal))
+	if err != nil {
+		return fmt.Errorf("%w: frame %d failed authentication (wrong key or tampered)", ErrInvalid, r.frames.count-1)
+	}
+	r.final = plain
+	return nil
+}
+
+// frameSource exposes the decrypted data frames as one stream.
+type frameSource struct{ r *Reader }
+
+func (s frameSource) Read(p []byte) (int, error) {
+	for s.r.plain.Len() == 0 {
+		if err := s.r.readFrame(); err != nil {
+			return 0, err
+		}
+	}
+	return s.r.plain.Read(p)
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// dropEntryScript removes one buffered field whatever its payload, in one
+// atomic call, and returns how many buffer entries it removed (0 or 1).
+var dropEntryScript = redis.NewScript(`
+	local removed = redis.call("HDEL", KEYS[1], ARGV[1])
+	redis.call("ZREM", KEYS[2], ARGV[1])
+	redis.call("HDEL", KEYS[3], ARGV[1])
+	redis.call("HDEL", KEYS[4], ARGV[1])
+	return removed
+`)
+
+// clearBufferScript deletes the whole buffer in one atomic call and returns
+// how many entries it held.
+var clearBufferScript = redis.NewScript(`
+	local count = redis.call("HLEN", KEYS[1])
+	redis.call("DEL", KEYS[1], KEYS[2], KEYS[3], KEYS[4], KEYS[5])
+	return count
+`)
+
+// Drop removes a user's buffered section without flushing it, e.g. a
+// corrupted payload that must not be persisted, along with any spooled
+// copy. Reports whether anything was buffered.
+func (b *RedisInventoryBuffer) Drop(ctx context.Context, robloxUserID, section string) (bool, error) {
+	field := BufferField(robloxUserID, section)
+	removed, err := dropEntryScript.Run(ctx, b.client,
+		[]string{b.bufferKey(), b.pendingKey(), b.hashesKey(), b.failuresKey()}, field).Int64()
+	if err != nil {
+		return false, err
+	}
+	spooled := false
+	if b.spool != nil {
+		spooled = b.spool.Discard(robloxUserID, section)
+	}
+	return removed > 0 || spooled, nil
+}
+
+// Clear drops every buffered entry without flushing, spooled ones included,
+// but not dead-lettered ones, and returns how many were dropped from Redis and from the spool.
+func (b *RedisInventoryBuffer) Clear(ctx context.Context) (int64, int, error) {
+	dropped, err := clearBufferScript.Run(ctx, b.client,
+		[]string{b.bufferKey(), b.pendingKey(), b.hashesKey(), b.failuresKey(), b.legacyPendingKey()}).Int64()
+	if err != nil {
+		return 0, 0, err
+	}
+	b.pendingBytes.Store(0)
+	spooled := 0
+	if b.spool != nil {
+		spooled = b.spool.Clear()
+	}
+	return dropped, spooled, nil
+}
@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// refreshPendingCount re-reads the pending count cached for Backpressure.
+// Called on every flush and cleanup cycle, so the check on each sync costs
+// no Redis round trip.
+func (b *RedisInventoryBuffer) refreshPendingCount(ctx context.Context) {
+	if count, err := b.Count(ctx); err == nil {
+		b.pendingCount.Store(count)
+	}
+}
+
+// Backpressure returns the pending count as of the last flush or cleanup
+// cycle, and the MaxPending limit (0 = none). Between cycles the count
+// lags, so the buffer may overshoot the limit by one interval of syncs.
+func (b *RedisInventoryBuffer) Backpressure() (pending, max int64) {
+	return b.pendingCount.Load(), b.maxPending
+}
+
+// Full reports whether syncs should be refused until flushing catches up.
+func (b *RedisInventoryBuffer) Full() bool {
+	return b.maxPending > 0 && b.pendingCount.Load() >= b.maxPending
+}
+
+// FlushInterval returns how often the buffer is flushed.
+func (b *RedisInventoryBuffer) FlushInterval() time.Duration {
+	return b.flushInterval
+}
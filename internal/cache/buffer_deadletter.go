@@ -0,0 +1,242 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// deadLetterScript moves a field from the buffer to the dead-letter hash,
+// unless its payload was rewritten since the failed flush read it (SHA-1 in
+// ARGV[2], empty for spooled entries not in Redis). A rewritten entry gets
+// a fresh failure count instead. Returns 1 when moved.
+var deadLetterScript = redis.NewScript(`
+	local current = redis.call("HGET", KEYS[1], ARGV[1])
+	if current and redis.sha1hex(current) ~= ARGV[2] then
+		redis.call("HDEL", KEYS[4], ARGV[1])
+		return 0
+	end
+	redis.call("HDEL", KEYS[1], ARGV[1])
+	redis.call("ZREM", KEYS[2], ARGV[1])
+	redis.call("HDEL", KEYS[3], ARGV[1])
+	redis.call("HDEL", KEYS[4], ARGV[1])
+	redis.call("HSET", KEYS[5], ARGV[1], ARGV[3])
+	return 1
+`)
+
+// requeueScript moves a dead-lettered field back into the buffer with a
+// fresh failure count. Returns 1 when requeued, 0 when a newer write is
+// buffered meanwhile (the dead letter is dropped in its favour) and -1
+// when the field isn't dead-lettered.
+var requeueScript = redis.NewScript(`
+	local record = redis.call("HGET", KEYS[4], ARGV[1])
+	if not record then
+		return -1
+	end
+	redis.call("HDEL", KEYS[4], ARGV[1])
+	redis.call("HDEL", KEYS[3], ARGV[1])
+	if redis.call("HSETNX", KEYS[1], ARGV[1], ARGV[2]) == 1 then
+		redis.call("ZADD", KEYS[2], "NX", ARGV[3], ARGV[1])
+		return 1
+	end
+	return 0
+`)
+
+// DeadLetter is a buffered entry taken out of the flush after failing
+// repeatedly while the rest of its batches were persisted.
+type DeadLetter struct {
+	Field     string             `json:"field"`
+	Failures  int64              `json:"failures"`
+	LastError string             `json:"last_error"`
+	DeadAt    time.Time          `json:"dead_at"`
+	Entry     *BufferedInventory `json:"entry"`
+}
+
+// ErrNotDeadLettered is returned by Requeue for a field that isn't in the
+// dead-letter hash.
+var ErrNotDeadLettered = errors.New("entry is not dead-lettered")
+
+// failuresKey returns the namespaced key of consecutive flush failures per
+// buffered field.
+func (b *RedisInventoryBuffer) failuresKey() string {
+	return b.keyPrefix + ":failures"
+}
+
+// deadLetterKey returns the namespaced key of dead-lettered entries.
+func (b *RedisInventoryBuffer) deadLetterKey() string {
+	return b.keyPrefix + ":deadletter"
+}
+
+// isolateFailures flushes the items of a failed batch one at a time, so a
+// poison entry doesn't hold back the rest. Entries flushed this way are
+// cleared; failing ones have their failure count raised and are
+// dead-lettered once it reaches the threshold. When no item succeeds on
+// its own the failure is not the items' fault (database down, guard
+// tripped), so nothing is counted and batchErr is returned. Otherwise it
+// returns the number of items flushed.
+func (b *RedisInventoryBuffer) isolateFailures(ctx context.Context, items []*BufferedInventory, fields []string, originalData map[string]string, spooled []SpooledEntry, batchErr error) (int, error) {
+	flushedData := make(map[string]string, len(items))
+	flushed := make(map[string]bool, len(items))
+	failed := make(map[string]error)
+	var failedAt []int
+	for i := range items {
+		if ctx.Err() != nil || b.onHold() {
+			break
+		}
+		if err := b.flushFunc(ctx, items[i:i+1]); err != nil {
+			if ctx.Err() == nil {
+				failed[fields[i]] = err
+				failedAt = append(failedAt, i)
+			}
+			continue
+		}
+		flushed[fields[i]] = true
+		if data, ok := originalData[fields[i]]; ok {
+			flushedData[fields[i]] = data
+		}
+	}
+	if len(flushed) == 0 {
+		return 0, batchErr
+	}
+
+	if _, err := b.ackFlushed(ctx, flushedData); err != nil {
+		b.logger.ErrorContext(ctx, "Failed to clear flushed entries", "error", err)
+	}
+	done := make([]SpooledEntry, 0, len(spooled))
+	for _, entry := range spooled {
+		if flushed[entry.field] {
+			done = append(done, entry)
+		}
+	}
+
+	deadLettered := 0
+	for _, i := range failedAt {
+		field := fields[i]
+		moved, err := b.countFailure(ctx, field, items[i], originalData[field], failed[field])
+		if err != nil {
+			b.logger.ErrorContext(ctx, "Failed to record flush failure", "field", field, "error", err)
+			continue
+		}
+		if moved {
+			deadLettered++
+			for _, entry := range spooled {
+				if entry.field == field {
+					done = append(done, entry)
+				}
+			}
+		}
+	}
+	if len(done) > 0 {
+		b.spool.Remove(done)
+	}
+
+	b.logger.WarnContext(ctx, "Flushed failed batch item by item", "flushed", len(flushed),
+		"failed", len(failed), "dead_lettered", deadLettered, "batch_error", batchErr)
+	return len(flushed), nil
+}
+
+// countFailure raises a field's consecutive failure count and moves it to
+// the dead-letter hash once the count reaches the threshold. Reports
+// whether it was moved.
+func (b *RedisInventoryBuffer) countFailure(ctx context.Context, field string, item *BufferedInventory, original string, flushErr error) (bool, error) {
+	failures, err := b.client.HIncrBy(ctx, b.failuresKey(), field, 1).Result()
+	if err != nil {
+		return false, err
+	}
+	if b.deadAfter <= 0 || failures < int64(b.deadAfter) {
+		return false, nil
+	}
+
+	record, err := json.Marshal(DeadLetter{
+		Field:     field,
+		Failures:  failures,
+		LastError: flushErr.Error(),
+		DeadAt:    time.Now().UTC(),
+		Entry:     item,
+	})
+	if err != nil {
+		return false, err
+	}
+	sum := ""
+	if original != "" {
+		h := sha1.Sum([]byte(original))
+		sum = hex.EncodeToString(h[:])
+	}
+	moved, err := deadLetterScript.Run(ctx, b.client,
+		[]string{b.bufferKey(), b.pendingKey(), b.hashesKey(), b.failuresKey(), b.deadLetterKey()},
+		field, sum, record).Int()
+	if err != nil {
+		return false, err
+	}
+	if moved == 1 {
+		b.logger.ErrorContext(ctx, "Dead-lettered buffered entry", "field", field,
+			"failures", failures, "error", flushErr)
+	}
+	return moved == 1, nil
+}
+
+// DeadLetters returns every dead-lettered entry.
+func (b *RedisInventoryBuffer) DeadLetters(ctx context.Context) ([]DeadLetter, error) {
+	records, err := b.client.HGetAll(ctx, b.deadLetterKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	letters := make([]DeadLetter, 0, len(records))
+	for field, record := range records {
+		var letter DeadLetter
+		if err := json.Unmarshal([]byte(record), &letter); err != nil {
+			b.logger.ErrorContext(ctx, "Skipping corrupt dead letter", "field", field, "error", err)
+			continue
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+// DeadLetterCount returns the number of dead-lettered entries.
+func (b *RedisInventoryBuffer) DeadLetterCount(ctx context.Context) (int64, error) {
+	return b.client.HLen(ctx, b.deadLetterKey()).Result()
+}
+
+// Requeue moves a user's dead-lettered section back into the buffer to be
+// flushed again, with a fresh failure count. Reports false when a newer
+// write was buffered meanwhile; the dead letter is dropped in its favour.
+// Returns ErrNotDeadLettered when there is nothing to requeue.
+func (b *RedisInventoryBuffer) Requeue(ctx context.Context, robloxUserID, section string) (bool, error) {
+	field := BufferField(robloxUserID, section)
+	record, err := b.client.HGet(ctx, b.deadLetterKey(), field).Bytes()
+	if err == redis.Nil {
+		return false, ErrNotDeadLettered
+	}
+	if err != nil {
+		return false, err
+	}
+	var letter DeadLetter
+	if err := json.Unmarshal(record, &letter); err != nil || letter.Entry == nil {
+		return false, errors.New("dead letter is corrupt")
+	}
+	payload, err := json.Marshal(letter.Entry)
+	if err != nil {
+		return false, err
+	}
+
+	requeued, err := requeueScript.Run(ctx, b.client,
+		[]string{b.bufferKey(), b.pendingKey(), b.failuresKey(), b.deadLetterKey()},
+		field, payload, pendingScore(time.Now())).Int()
+	if err != nil {
+		return false, err
+	}
+	if requeued < 0 {
+		return false, ErrNotDeadLettered
+	}
+	if requeued == 1 {
+		b.pendingBytes.Add(int64(len(payload)))
+		b.bus.Publish(ctx, InvalidateInventory, robloxUserID)
+	}
+	return requeued == 1, nil
+}
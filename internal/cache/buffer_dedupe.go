@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"vinzhub-rest-api/internal/tracing"
+)
+
+// addIfChangedScript buffers an entry unless the buffered one has the same
+// fingerprint. A fingerprint is recorded with the SHA-1 of the payload it
+// describes and only trusted while that payload is still buffered, so writes
+// that bypass it (rekeys, older releases) can never cause a wrong skip.
+// Returns 0 when nothing is buffered (and writes nothing), 1 when the entry
+// is unchanged and 2 when it was written.
+//
+// KEYS: buffer, pending queue, hashes
+// ARGV: field, payload, fingerprint, pending score
+var addIfChangedScript = redis.NewScript(`
+	local current = redis.call("HGET", KEYS[1], ARGV[1])
+	if not current then
+		return 0
+	end
+	if redis.call("HGET", KEYS[3], ARGV[1]) == ARGV[3] .. "@" .. redis.sha1hex(current) then
+		return 1
+	end
+	redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+	redis.call("ZADD", KEYS[2], "NX", ARGV[4], ARGV[1])
+	redis.call("HSET", KEYS[3], ARGV[1], ARGV[3] .. "@" .. redis.sha1hex(ARGV[2]))
+	return 2
+`)
+
+// Fingerprint identifies what a flush would persist for an entry: the hex
+// SHA-256 of its document (the stored content hash) and the key account it
+// is linked to.
+func Fingerprint(contentHash string, keyAccountID int64) string {
+	return contentHash + "/" + strconv.FormatInt(keyAccountID, 10)
+}
+
+// fingerprintOf returns the fingerprint of an entry.
+func fingerprintOf(data *BufferedInventory) string {
+	sum := sha256.Sum256(data.RawJSON)
+	return Fingerprint(hex.EncodeToString(sum[:]), data.KeyAccountID)
+}
+
+// entryFingerprint returns the value kept in the hashes key for an entry
+// buffered as payload.
+func entryFingerprint(data *BufferedInventory, payload []byte) string {
+	sum := sha1.Sum(payload)
+	return fingerprintOf(data) + "@" + hex.EncodeToString(sum[:])
+}
+
+// AddEntryIfChanged buffers an entry unless it would persist nothing new:
+// when the buffered copy has the same content and key account, or, with
+// nothing buffered, when persisted returns the entry's Fingerprint. persisted
+// may be nil. Returns false when the write was skipped.
+//
+// Entries asking for a callback are always written, as are entries while
+// the spool is in use, since a spooled copy may be newer than Redis's.
+func (b *RedisInventoryBuffer) AddEntryIfChanged(ctx context.Context, data *BufferedInventory, persisted func() (string, error)) (bool, error) {
+	ctx, span := tracing.Start(ctx, "RedisInventoryBuffer.AddEntryIfChanged")
+	if span == nil {
+		return b.addEntryIfChanged(ctx, data, persisted)
+	}
+	data.Trace = span.Ref()
+	written, err := b.addEntryIfChanged(ctx, data, persisted)
+	span.SetBool("inventory.written", written)
+	span.Fail(err)
+	span.End()
+	return written, err
+}
+
+func (b *RedisInventoryBuffer) addEntryIfChanged(ctx context.Context, data *BufferedInventory, persisted func() (string, error)) (bool, error) {
+	if data.Callback || (b.spool != nil && (b.spool.Depth() > 0 || (b.spoolBudget > 0 && b.pendingBytes.Load() > b.spoolBudget))) {
+		return true, b.AddEntry(ctx, data)
+	}
+
+	data.UpdatedAt = time.Now()
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return false, err
+	}
+
+	fingerprint := fingerprintOf(data)
+	field := BufferField(data.RobloxUserID, data.Section)
+	res, err := addIfChangedScript.Run(ctx, b.client, []string{b.bufferKey(), b.pendingKey(), b.hashesKey()},
+		field, jsonData, fingerprint, pendingScore(data.UpdatedAt)).Int()
+	if err != nil {
+		if b.spool != nil && isOOM(err) {
+			return true, b.spool.Write(ctx, data, "out of memory")
+		}
+		return false, err
+	}
+	switch res {
+	case 1:
+		return false, nil
+	case 2:
+		b.pendingBytes.Add(int64(len(jsonData)))
+		b.bus.Publish(ctx, InvalidateInventory, data.RobloxUserID)
+		return true, nil
+	}
+
+	if persisted != nil {
+		stored, err := persisted()
+		if err != nil {
+			b.logger.WarnContext(ctx, "Persisted fingerprint unavailable, buffering", "field", field, "error", err)
+		} else if stored == fingerprint {
+			return false, nil
+		}
+	}
+	return true, b.AddEntry(ctx, data)
+}
@@ -0,0 +1,303 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vinzhub-rest-api/internal/lifecycle"
+	"vinzhub-rest-api/internal/logging"
+)
+
+// FallbackAfterErrors is the default number of consecutive Redis errors
+// after which FallbackBuffer switches to the in-memory buffer.
+const FallbackAfterErrors = 3
+
+// fallbackProbeInterval is how often Redis is pinged while in fallback.
+const fallbackProbeInterval = 5 * time.Second
+
+// fallbackProbeTimeout bounds one ping, and each entry drained back.
+const fallbackProbeTimeout = 2 * time.Second
+
+// FallbackBuffer buffers through Redis and switches to an in-memory
+// InventoryBuffer when Redis keeps failing mid-run, so syncs keep being
+// accepted. While in fallback Redis is pinged in the background; once it
+// answers, the entries still held in memory are written back to Redis and
+// Redis takes over again. The in-memory buffer flushes to the database on
+// its own meanwhile, so a long outage doesn't hold entries back.
+//
+// Entries buffered in memory are only visible to this instance until they
+// are flushed or drained back, and other instances aren't told about them:
+// the invalidation bus runs over the same Redis.
+type FallbackBuffer struct {
+	redis  *RedisInventoryBuffer
+	memory *InventoryBuffer
+	after  int
+
+	// switchMu is held for reading by every operation and for writing
+	// while switching back, so no write lands in memory after the drain.
+	switchMu sync.RWMutex
+	fallback atomic.Bool
+	errors   atomic.Int64
+
+	mu       sync.Mutex
+	since    time.Time
+	switches int64
+	drained  int64
+	lastErr  string
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	logger   *slog.Logger
+}
+
+// NewFallbackBuffer wraps a Redis buffer with an in-memory one to fall back
+// to after `after` consecutive Redis errors (FallbackAfterErrors when <= 0).
+func NewFallbackBuffer(redis *RedisInventoryBuffer, memory *InventoryBuffer, after int) *FallbackBuffer {
+	if after <= 0 {
+		after = FallbackAfterErrors
+	}
+	b := &FallbackBuffer{
+		redis:  redis,
+		memory: memory,
+		after:  after,
+		stop:   make(chan struct{}),
+		logger: logging.Component("FallbackBuffer"),
+	}
+	lifecycle.Go("buffer.fallback", b.probe)
+	return b
+}
+
+// Active reports whether writes currently go to the in-memory buffer.
+func (b *FallbackBuffer) Active() bool {
+	return b.fallback.Load()
+}
+
+// observe records the outcome of a Redis operation and switches to the
+// in-memory buffer once errors reach the threshold. Reports whether the
+// buffer is (now) in fallback, so a failed operation can be retried there.
+func (b *FallbackBuffer) observe(ctx context.Context, err error) bool {
+	if err == nil {
+		b.errors.Store(0)
+		return false
+	}
+	if ctx.Err() != nil {
+		return false // The caller gave up, not Redis
+	}
+	if b.errors.Add(1) < int64(b.after) {
+		return false
+	}
+	if b.fallback.CompareAndSwap(false, true) {
+		b.mu.Lock()
+		b.since = time.Now()
+		b.switches++
+		b.lastErr = err.Error()
+		b.mu.Unlock()
+		b.logger.ErrorContext(ctx, "Redis keeps failing, buffering in memory", "errors", b.errors.Load(), "error", err)
+	}
+	return true
+}
+
+// AddEntryIfChanged buffers an entry in Redis, or in memory while in
+// fallback. A Redis write that trips the fallback is retried in memory.
+func (b *FallbackBuffer) AddEntryIfChanged(ctx context.Context, data *BufferedInventory, persisted func() (string, error)) (bool, error) {
+	b.switchMu.RLock()
+	defer b.switchMu.RUnlock()
+
+	if !b.fallback.Load() {
+		written, err := b.redis.AddEntryIfChanged(ctx, data, persisted)
+		if !b.observe(ctx, err) {
+			return written, err
+		}
+	}
+	return b.memory.AddEntryIfChanged(ctx, data, persisted)
+}
+
+// GetSection returns a buffered section. In fallback only memory is read:
+// Redis is down, and anything it still holds is older.
+func (b *FallbackBuffer) GetSection(ctx context.Context, robloxUserID, section string) (*BufferedInventory, error) {
+	b.switchMu.RLock()
+	defer b.switchMu.RUnlock()
+
+	if !b.fallback.Load() {
+		inv, err := b.redis.GetSection(ctx, robloxUserID, section)
+		if !b.observe(ctx, err) {
+			return inv, err
+		}
+	}
+	return b.memory.GetSection(ctx, robloxUserID, section)
+}
+
+// GetSections returns several buffered sections of a user, from memory
+// while in fallback.
+func (b *FallbackBuffer) GetSections(ctx context.Context, robloxUserID string, sections []string) (map[string]*BufferedInventory, error) {
+	b.switchMu.RLock()
+	defer b.switchMu.RUnlock()
+
+	if !b.fallback.Load() {
+		result, err := b.redis.GetSections(ctx, robloxUserID, sections)
+		if !b.observe(ctx, err) {
+			return result, err
+		}
+	}
+	return b.memory.GetSections(ctx, robloxUserID, sections)
+}
+
+// RemoveSection drops a buffered section from memory and, unless in
+// fallback, from Redis.
+func (b *FallbackBuffer) RemoveSection(ctx context.Context, robloxUserID, section string) error {
+	b.switchMu.RLock()
+	defer b.switchMu.RUnlock()
+
+	b.memory.RemoveSection(ctx, robloxUserID, section)
+	if b.fallback.Load() {
+		return nil
+	}
+	err := b.redis.RemoveSection(ctx, robloxUserID, section)
+	if b.observe(ctx, err) {
+		return nil // An older Redis copy loses to the newer row on flush
+	}
+	return err
+}
+
+// FlushWindow estimates the flush delay of the buffer in use.
+func (b *FallbackBuffer) FlushWindow(ctx context.Context) (time.Duration, int64) {
+	if b.fallback.Load() {
+		return b.memory.FlushWindow(ctx)
+	}
+	return b.redis.FlushWindow(ctx)
+}
+
+// Full reports whether syncs should be refused. The in-memory buffer has
+// no limit, so this follows Redis's only outside fallback.
+func (b *FallbackBuffer) Full() bool {
+	if b.fallback.Load() {
+		return b.memory.Full()
+	}
+	return b.redis.Full()
+}
+
+// Backpressure returns the pending count and limit of the buffer in use.
+func (b *FallbackBuffer) Backpressure() (pending, max int64) {
+	if b.fallback.Load() {
+		return b.memory.Backpressure()
+	}
+	return b.redis.Backpressure()
+}
+
+// FlushInterval returns how often the buffer in use is flushed.
+func (b *FallbackBuffer) FlushInterval() time.Duration {
+	if b.fallback.Load() {
+		return b.memory.FlushInterval()
+	}
+	return b.redis.FlushInterval()
+}
+
+// probe pings Redis while in fallback and switches back once it answers.
+func (b *FallbackBuffer) probe() {
+	ticker := time.NewTicker(fallbackProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			lifecycle.Touch("buffer.fallback")
+			if !b.fallback.Load() {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), fallbackProbeTimeout)
+			err := b.redis.Ping(ctx)
+			cancel()
+			if err != nil {
+				b.mu.Lock()
+				b.lastErr = err.Error()
+				b.mu.Unlock()
+				continue
+			}
+			b.switchBack()
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// switchBack drains the in-memory buffer back into Redis and switches writes
+// back to it. Most entries are drained while syncs still go to memory; the
+// few written meanwhile are drained with syncs held, then Redis takes over.
+// A failed drain stays in fallback and is retried on the next probe.
+func (b *FallbackBuffer) switchBack() {
+	drained, err := b.drain()
+	if err != nil {
+		b.logger.Warn("Redis answers again but draining memory failed, staying in fallback", "drained", drained, "error", err)
+		return
+	}
+
+	b.switchMu.Lock()
+	defer b.switchMu.Unlock()
+	n, err := b.drain()
+	drained += n
+	if err != nil {
+		b.logger.Warn("Redis answers again but draining memory failed, staying in fallback", "drained", drained, "error", err)
+		return
+	}
+	b.errors.Store(0)
+	b.fallback.Store(false)
+
+	b.mu.Lock()
+	since := b.since
+	b.drained += int64(drained)
+	b.mu.Unlock()
+	b.logger.Info("Redis recovered, buffering in Redis again", "drained", drained, "after", time.Since(since).Round(time.Second))
+}
+
+// drain moves every entry held in memory to Redis. Entries not moved are
+// put back unless a newer write replaced them. Returns the number moved.
+func (b *FallbackBuffer) drain() (int, error) {
+	items := b.memory.Take()
+	for i, inv := range items {
+		ctx, cancel := context.WithTimeout(context.Background(), fallbackProbeTimeout)
+		err := b.redis.AddEntry(ctx, inv)
+		cancel()
+		if err != nil {
+			b.memory.Restore(items[i:])
+			return i, err
+		}
+	}
+	return len(items), nil
+}
+
+// Stats reports the fallback state for the admin stats.
+func (b *FallbackBuffer) Stats(ctx context.Context) map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := map[string]interface{}{
+		"active":         b.fallback.Load(),
+		"after_errors":   b.after,
+		"redis_errors":   b.errors.Load(),
+		"switches":       b.switches,
+		"drained":        b.drained,
+		"memory_pending": b.memory.Count(),
+	}
+	if b.fallback.Load() {
+		stats["since"] = b.since
+		stats["last_error"] = b.lastErr
+	}
+	return stats
+}
+
+// Close stops probing Redis and flushes whatever the in-memory buffer
+// still holds to the database.
+func (b *FallbackBuffer) Close() error {
+	b.stopOnce.Do(func() {
+		close(b.stop)
+		if b.memory.Count() > 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			b.memory.Flush(ctx)
+			cancel()
+		}
+		b.memory.Close()
+	})
+	return nil
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Pending fields are kept in a sorted set scored by when they became
+// pending (unix milliseconds), so flushes take the oldest first. Releases
+// before the sorted set kept them in a plain set under ":pending"; members
+// found there are moved over at startup and on every cleanup cycle, which
+// also picks up writes from older instances during a rolling deploy.
+
+// queueMigrateChunk bounds the legacy pending members moved per call.
+const queueMigrateChunk = 500
+
+// migratePendingScript moves legacy pending members into the queue without
+// touching members already queued. ARGV holds field, score pairs; a member
+// is only added when it was still in the legacy set and its entry is
+// buffered, checked here so a write by an older instance since the entry
+// was read is never dropped. Returns the number moved.
+//
+// KEYS: legacy pending set, queue, buffer
+var migratePendingScript = redis.NewScript(`
+	local moved = 0
+	for i = 1, #ARGV, 2 do
+		if redis.call("SREM", KEYS[1], ARGV[i]) == 1 and redis.call("HEXISTS", KEYS[3], ARGV[i]) == 1 then
+			redis.call("ZADD", KEYS[2], "NX", ARGV[i + 1], ARGV[i])
+			moved = moved + 1
+		end
+	end
+	return moved
+`)
+
+// pendingScore is the queue score of an entry that became pending at t.
+func pendingScore(t time.Time) float64 {
+	return float64(t.UnixMilli())
+}
+
+// legacyPendingKey returns the namespaced key of the plain pending set used
+// before the queue.
+func (b *RedisInventoryBuffer) legacyPendingKey() string {
+	return b.keyPrefix + ":pending"
+}
+
+// migratePendingSet moves members of the legacy pending set into the queue,
+// scored by their entry's UpdatedAt (now for entries not buffered when
+// read). Members whose entry is gone are dropped. Returns the number moved.
+func (b *RedisInventoryBuffer) migratePendingSet(ctx context.Context) (int, error) {
+	moved := 0
+	for {
+		fields, err := b.client.SRandMemberN(ctx, b.legacyPendingKey(), queueMigrateChunk).Result()
+		if err != nil || len(fields) == 0 {
+			return moved, err
+		}
+		payloads, err := b.client.HMGet(ctx, b.bufferKey(), fields...).Result()
+		if err != nil {
+			return moved, err
+		}
+
+		args := make([]interface{}, 0, 2*len(fields))
+		for i, field := range fields {
+			updatedAt := time.Now()
+			var inv BufferedInventory
+			if payload, ok := payloads[i].(string); ok &&
+				json.Unmarshal([]byte(payload), &inv) == nil && !inv.UpdatedAt.IsZero() {
+				updatedAt = inv.UpdatedAt
+			}
+			args = append(args, field, pendingScore(updatedAt))
+		}
+		n, err := migratePendingScript.Run(ctx, b.client,
+			[]string{b.legacyPendingKey(), b.pendingKey(), b.bufferKey()}, args...).Int()
+		if err != nil {
+			return moved, err
+		}
+		moved += n
+	}
+}
+
+// migratePending runs migratePendingSet and logs what it moved.
+func (b *RedisInventoryBuffer) migratePending(ctx context.Context) {
+	moved, err := b.migratePendingSet(ctx)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "Failed to move legacy pending set into the queue", "moved", moved, "error", err)
+		return
+	}
+	if moved > 0 {
+		b.logger.InfoContext(ctx, "Moved legacy pending entries into the queue", "items", moved)
+	}
+}
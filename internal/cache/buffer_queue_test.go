@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMigratePendingSet(t *testing.T) {
+	ctx := context.Background()
+	b, client := newTestRedisBuffer(t, RedisBufferConfig{}, (&flushStore{}).flush)
+	updatedAt := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	entry := `{"RobloxUserID":"1","UpdatedAt":"` + updatedAt.Format(time.RFC3339Nano) + `"}`
+
+	client.HSet(ctx, b.bufferKey(), "legacy", entry, "queued", entry)
+	client.ZAdd(ctx, b.pendingKey(), redis.Z{Score: 42, Member: "queued"})
+	client.SAdd(ctx, b.legacyPendingKey(), "legacy", "queued", "orphan")
+
+	moved, err := b.migratePendingSet(ctx)
+	if err != nil || moved != 2 {
+		t.Fatalf("migratePendingSet = %d, %v; want 2 moved", moved, err)
+	}
+	if n := client.SCard(ctx, b.legacyPendingKey()).Val(); n != 0 {
+		t.Errorf("%d members left in the legacy set", n)
+	}
+	want := map[string]float64{"legacy": pendingScore(updatedAt), "queued": 42}
+	for field, score := range want {
+		if got, err := client.ZScore(ctx, b.pendingKey(), field).Result(); err != nil || got != score {
+			t.Errorf("%s score = %v, %v; want %v", field, got, err, score)
+		}
+	}
+	if client.ZScore(ctx, b.pendingKey(), "orphan").Err() != redis.Nil {
+		t.Error("orphan with no buffered entry was queued")
+	}
+}
+
+func TestMigratePendingScriptRechecksEntries(t *testing.T) {
+	ctx := context.Background()
+	b, client := newTestRedisBuffer(t, RedisBufferConfig{}, (&flushStore{}).flush)
+	client.SAdd(ctx, b.legacyPendingKey(), "written", "flushed")
+	// Read as an orphan, then written by an older instance before the move
+	client.HSet(ctx, b.bufferKey(), "written", `{}`)
+	// Read as buffered, then flushed by another instance before the move
+
+	moved, err := migratePendingScript.Run(ctx, b.client,
+		[]string{b.legacyPendingKey(), b.pendingKey(), b.bufferKey()},
+		"written", 1, "flushed", 2).Int()
+	if err != nil || moved != 1 {
+		t.Fatalf("script = %d, %v; want only the buffered entry moved", moved, err)
+	}
+	if client.ZScore(ctx, b.pendingKey(), "written").Err() != nil {
+		t.Error("entry written since it was read was not queued")
+	}
+	if client.ZScore(ctx, b.pendingKey(), "flushed").Err() != redis.Nil {
+		t.Error("entry flushed since it was read was queued")
+	}
+	if n := client.SCard(ctx, b.legacyPendingKey()).Val(); n != 0 {
+		t.Errorf("%d members left in the legacy set", n)
+	}
+}
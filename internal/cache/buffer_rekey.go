@@ -0,0 +1,266 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// rekeyScanCount is the HSCAN/SSCAN/ZSCAN page size used while re-keying.
+const rekeyScanCount = 200
+
+// moveIfAbsentScript moves one field from the old prefix to the new one when
+// the new prefix has nothing for it. Returns {1} when moved, {0} when the old
+// entry changed under us, and {2, existing} when the new prefix already has
+// an entry (the caller decides which one wins).
+//
+// KEYS: old buffer, old legacy pending set, new buffer, new queue, old queue
+// ARGV: field, expected old value, pending score
+var moveIfAbsentScript = redis.NewScript(`
+	if redis.call("HGET", KEYS[1], ARGV[1]) ~= ARGV[2] then
+		return {0}
+	end
+	local existing = redis.call("HGET", KEYS[3], ARGV[1])
+	if existing then
+		return {2, existing}
+	end
+	redis.call("HSET", KEYS[3], ARGV[1], ARGV[2])
+	redis.call("ZADD", KEYS[4], "NX", ARGV[3], ARGV[1])
+	redis.call("HDEL", KEYS[1], ARGV[1])
+	redis.call("SREM", KEYS[2], ARGV[1])
+	redis.call("ZREM", KEYS[5], ARGV[1])
+	return {1}
+`)
+
+// resolveConflictScript settles a field present under both prefixes. When
+// neither entry changed since they were compared, the winner is kept under
+// the new prefix and the old entry is removed. Returns 1 on success.
+//
+// KEYS: old buffer, old legacy pending set, new buffer, new queue, old queue
+// ARGV: field, expected old value, expected new value, winner ("old" or
+// "new"), pending score
+var resolveConflictScript = redis.NewScript(`
+	if redis.call("HGET", KEYS[1], ARGV[1]) ~= ARGV[2] or redis.call("HGET", KEYS[3], ARGV[1]) ~= ARGV[3] then
+		return 0
+	end
+	if ARGV[4] == "old" then
+		redis.call("HSET", KEYS[3], ARGV[1], ARGV[2])
+		redis.call("ZADD", KEYS[4], "NX", ARGV[5], ARGV[1])
+	end
+	redis.call("HDEL", KEYS[1], ARGV[1])
+	redis.call("SREM", KEYS[2], ARGV[1])
+	redis.call("ZREM", KEYS[5], ARGV[1])
+	return 1
+`)
+
+// RekeyResult reports a buffer prefix migration. In a dry run the counts
+// describe what would happen.
+type RekeyResult struct {
+	From         string `json:"from"`
+	To           string `json:"to"`
+	DryRun       bool   `json:"dry_run"`
+	Scanned      int    `json:"scanned"`
+	Moved        int    `json:"moved"`         // No entry under the new prefix
+	ReplacedNew  int    `json:"replaced_new"`  // Old entry was newer and replaced the new one
+	DroppedOlder int    `json:"dropped_older"` // New prefix already had a newer entry
+	Changed      int    `json:"changed"`       // Written concurrently; left for a rerun
+	Orphans      int    `json:"orphans"`       // Pending members without a buffered entry
+	Remaining    int64  `json:"remaining"`     // Entries left under the old prefix
+}
+
+// RekeyBuffer moves every buffered entry from oldPrefix to newPrefix. Each
+// field moves atomically; when a field exists under both prefixes the entry
+// with the newer UpdatedAt wins. progress (optional) is called after every
+// scan page. Safe to rerun: entries written concurrently are left in place
+// and reported as changed.
+func RekeyBuffer(ctx context.Context, client *redis.Client, oldPrefix, newPrefix string, dryRun bool, progress func(RekeyResult)) (*RekeyResult, error) {
+	if oldPrefix == "" || newPrefix == "" || oldPrefix == newPrefix {
+		return nil, fmt.Errorf("old and new prefixes must be set and differ")
+	}
+	// The old prefix may have been written by a release with a plain
+	// pending set, by one with the queue, or both
+	oldBuf, oldPending, oldQueue := oldPrefix+":buffer", oldPrefix+":pending", oldPrefix+":queue"
+	newBuf, newQueue := newPrefix+":buffer", newPrefix+":queue"
+	keys := []string{oldBuf, oldPending, newBuf, newQueue, oldQueue}
+
+	result := &RekeyResult{From: oldPrefix, To: newPrefix, DryRun: dryRun}
+
+	var cursor uint64
+	for {
+		page, next, err := client.HScan(ctx, oldBuf, cursor, "*", rekeyScanCount).Result()
+		if err != nil {
+			return result, fmt.Errorf("failed to scan %s: %w", oldBuf, err)
+		}
+		for i := 0; i+1 < len(page); i += 2 {
+			if err := rekeyField(ctx, client, keys, page[i], page[i+1], dryRun, result); err != nil {
+				return result, err
+			}
+		}
+		if progress != nil {
+			progress(*result)
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+
+	// Pending members whose entry is gone would never flush - drop them
+	for {
+		members, next, err := client.SScan(ctx, oldPending, cursor, "*", rekeyScanCount).Result()
+		if err != nil {
+			return result, fmt.Errorf("failed to scan %s: %w", oldPending, err)
+		}
+		if err := dropOrphans(ctx, client, oldBuf, members, dryRun, result, func(field string) {
+			client.SRem(ctx, oldPending, field)
+		}); err != nil {
+			return result, err
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	for {
+		page, next, err := client.ZScan(ctx, oldQueue, cursor, "*", rekeyScanCount).Result()
+		if err != nil {
+			return result, fmt.Errorf("failed to scan %s: %w", oldQueue, err)
+		}
+		members := make([]string, 0, len(page)/2)
+		for i := 0; i < len(page); i += 2 {
+			members = append(members, page[i]) // ZSCAN pages are member, score pairs
+		}
+		if err := dropOrphans(ctx, client, oldBuf, members, dryRun, result, func(field string) {
+			client.ZRem(ctx, oldQueue, field)
+		}); err != nil {
+			return result, err
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+
+	remaining, err := client.HLen(ctx, oldBuf).Result()
+	if err != nil {
+		return result, err
+	}
+	result.Remaining = remaining
+	return result, nil
+}
+
+// dropOrphans counts the pending members without a buffered entry and,
+// unless dryRun, removes each with remove.
+func dropOrphans(ctx context.Context, client *redis.Client, buf string, members []string, dryRun bool, result *RekeyResult, remove func(field string)) error {
+	for _, field := range members {
+		exists, err := client.HExists(ctx, buf, field).Result()
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		result.Orphans++
+		if !dryRun {
+			remove(field)
+		}
+	}
+	return nil
+}
+
+// rekeyField moves one field, resolving conflicts by UpdatedAt.
+
+func rekeyField(ctx context.Context, client *redis.Client, keys []string, field, oldValue string, dryRun bool, result *RekeyResult) error {
+	result.Scanned++
+
+	var existing string
+	if dryRun {
+		v, err := client.HGet(ctx, keys[2], field).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if err == redis.Nil {
+			result.Moved++
+			return nil
+		}
+		existing = v
+	} else {
+		res, err := moveIfAbsentScript.Run(ctx, client, keys, field, oldValue, entryScore(oldValue)).Slice()
+		if err != nil {
+			return fmt.Errorf("failed to move %s: %w", field, err)
+		}
+		switch res[0].(int64) {
+		case 0:
+			result.Changed++
+			return nil
+		case 1:
+			result.Moved++
+			return nil
+		}
+		existing, _ = res[1].(string)
+	}
+
+	winner := "new"
+	if entryNewer(oldValue, existing) {
+		winner = "old"
+	}
+	if !dryRun {
+		ok, err := resolveConflictScript.Run(ctx, client, keys, field, oldValue, existing, winner, entryScore(oldValue)).Int()
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", field, err)
+		}
+		if ok == 0 {
+			result.Changed++
+			return nil
+		}
+	}
+	if winner == "old" {
+		result.ReplacedNew++
+	} else {
+		result.DroppedOlder++
+	}
+	return nil
+}
+
+// entryNewer reports whether buffered entry a was updated after b. An entry
+// that can't be decoded never wins.
+func entryNewer(a, b string) bool {
+	var invA, invB BufferedInventory
+	if json.Unmarshal([]byte(a), &invA) != nil {
+		return false
+	}
+	if json.Unmarshal([]byte(b), &invB) != nil {
+		return true
+	}
+	return invA.UpdatedAt.After(invB.UpdatedAt)
+}
+
+// entryScore returns the pending score of a buffered entry moved between
+// prefixes: its UpdatedAt, or now when it can't be decoded.
+func entryScore(payload string) float64 {
+	var inv BufferedInventory
+	if json.Unmarshal([]byte(payload), &inv) != nil || inv.UpdatedAt.IsZero() {
+		return pendingScore(time.Now())
+	}
+	return pendingScore(inv.UpdatedAt)
+}
+
+// PrefixSize returns how many entries are buffered under a prefix.
+func PrefixSize(ctx context.Context, client *redis.Client, prefix string) (int64, error) {
+	return client.HLen(ctx, prefix+":buffer").Result()
+}
+
+// RekeyFrom moves entries buffered under oldPrefix into this buffer's prefix.
+func (b *RedisInventoryBuffer) RekeyFrom(ctx context.Context, oldPrefix string, dryRun bool, progress func(RekeyResult)) (*RekeyResult, error) {
+	return RekeyBuffer(ctx, b.client, oldPrefix, b.keyPrefix, dryRun, progress)
+}
+
+// PrefixSize returns how many entries are buffered under another prefix.
+func (b *RedisInventoryBuffer) PrefixSize(ctx context.Context, prefix string) (int64, error) {
+	return PrefixSize(ctx, b.client, prefix)
+}
+
+// KeyPrefix returns the buffer's key prefix.
+func (b *RedisInventoryBuffer) KeyPrefix() string {
+	return b.keyPrefix
+}
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// flushRetryDelays are the waits before each retry of a failed background
+// flush, before it gives up until the next tick. Each gets up to 50% jitter
+// so instances sharing a database don't retry in lockstep.
+var flushRetryDelays = []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second}
+
+// FlushAlertAfter is the default number of consecutive failed flush
+// attempts after which an alert is logged.
+const FlushAlertAfter = 10
+
+// flushWithRetry runs one background flush, retrying a failure with
+// backoff so a short database lock costs seconds instead of a tick.
+// Retries stop on shutdown or when flushing is put on hold.
+func (b *RedisInventoryBuffer) flushWithRetry() {
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), b.flushTimeout)
+		_, err := b.FlushBatch(ctx)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt == len(flushRetryDelays) {
+			b.logger.Error("Background flush failed, retrying next tick", "attempts", attempt+1, "error", err)
+			return
+		}
+
+		delay := flushRetryDelays[attempt]
+		delay += rand.N(delay / 2)
+		b.logger.Warn("Background flush failed, retrying", "attempt", attempt+1, "retry_in", delay, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-b.stopFlush:
+			return // The shutdown flush takes over
+		}
+		if b.onHold() {
+			return
+		}
+	}
+}
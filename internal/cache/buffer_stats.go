@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// flushRecord tracks the outcome of the buffer's flushes and stale cleanups.
+type flushRecord struct {
+	mu             sync.Mutex
+	startedAt      time.Time
+	lastFlushAt    time.Time // Last flush that succeeded, even with nothing to flush
+	lastFlushCount int
+	lastFlushErr   error
+	lastErrAt      time.Time
+	failures       int       // Consecutive failed flushes
+	failingSince   time.Time // First of the consecutive failures
+	lastCleanup    *CleanupStats
+}
+
+// CleanupStats describes the last stale cleanup.
+type CleanupStats struct {
+	At         time.Time `json:"at"`
+	DurationMs int64     `json:"duration_ms"`
+	Scanned    int       `json:"scanned"` // Fields pending since before the stale threshold
+	Removed    int       `json:"removed"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// BufferStats describes the buffer's depth and how far flushing lags.
+type BufferStats struct {
+	Pending      int64  `json:"pending_items"`
+	PendingError string `json:"pending_error,omitempty"` // Set when Redis couldn't be counted
+	Spooled      int    `json:"spooled_items"`
+	DeadLettered int64  `json:"dead_letter_items"`
+	OnHold       bool   `json:"on_hold"` // Flushing paused (see SetHoldFunc)
+
+	LastFlushAt    *time.Time `json:"last_flush_at,omitempty"`
+	LastFlushCount int        `json:"last_flush_count"`
+	// FlushLagSeconds is the time since the last successful flush, or
+	// since the buffer started when none succeeded yet
+	FlushLagSeconds float64    `json:"flush_lag_seconds"`
+	LastError       string     `json:"last_flush_error,omitempty"`
+	LastErrorAt     *time.Time `json:"last_flush_error_at,omitempty"`
+	// ConsecutiveFailures counts failed flush attempts, retries included,
+	// since the last success
+	ConsecutiveFailures int        `json:"consecutive_flush_failures"`
+	FailingSince        *time.Time `json:"failing_since,omitempty"`
+
+	LastCleanup *CleanupStats `json:"last_cleanup,omitempty"`
+
+	// Breaker is the circuit breaker guarding Redis commands
+	Breaker BreakerStats `json:"breaker"`
+}
+
+// recordFlush notes the outcome of one FlushBatch call. A later success
+// clears the last error. Every alertAfter failures in a row log an alert:
+// entries that can't be flushed are deleted by the stale cleanup once they
+// are older than the stale threshold.
+func (b *RedisInventoryBuffer) recordFlush(flushed int, err error) {
+	r := &b.flushes
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if err != nil {
+		r.lastFlushErr = err
+		r.lastErrAt = now
+		if r.failures == 0 {
+			r.failingSince = now
+		}
+		r.failures++
+		if b.alertAfter > 0 && r.failures%b.alertAfter == 0 {
+			b.logger.Error("ALERT: buffer flushes keep failing - unflushed entries are deleted once older than the stale threshold",
+				"consecutive_failures", r.failures, "failing_for", now.Sub(r.failingSince).Round(time.Second),
+				"stale_after", b.staleAfter, "error", err)
+		}
+		return
+	}
+	if b.alertAfter > 0 && r.failures >= b.alertAfter {
+		b.logger.Info("Buffer flushes recovered", "failed_attempts", r.failures,
+			"failed_for", now.Sub(r.failingSince).Round(time.Second))
+	}
+	r.failures = 0
+	r.failingSince = time.Time{}
+	r.lastFlushAt = now
+	r.lastFlushCount = flushed
+	r.lastFlushErr = nil
+	r.lastErrAt = time.Time{}
+}
+
+// recordCleanup notes the outcome of a stale cleanup.
+func (b *RedisInventoryBuffer) recordCleanup(at time.Time, took time.Duration, scanned, removed int, err error) {
+	cleanup := &CleanupStats{At: at, DurationMs: took.Milliseconds(), Scanned: scanned, Removed: removed}
+	if err != nil {
+		cleanup.Error = err.Error()
+	}
+	r := &b.flushes
+	r.mu.Lock()
+	r.lastCleanup = cleanup
+	r.mu.Unlock()
+}
+
+// LastCleanup returns the outcome of the last stale cleanup, or nil before
+// the first.
+func (b *RedisInventoryBuffer) LastCleanup() *CleanupStats {
+	r := &b.flushes
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastCleanup
+}
+
+// Stats returns the buffer's depth and flush lag.
+func (b *RedisInventoryBuffer) Stats(ctx context.Context) BufferStats {
+	var stats BufferStats
+	pending, err := b.Count(ctx)
+	if err != nil {
+		stats.PendingError = err.Error()
+	}
+	stats.Pending = pending
+	stats.DeadLettered, _ = b.DeadLetterCount(ctx)
+	if b.spool != nil {
+		stats.Spooled = b.spool.Depth()
+	}
+	stats.OnHold = b.onHold()
+	stats.Breaker = b.breaker.Stats()
+
+	r := &b.flushes
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	since := r.startedAt
+	if !r.lastFlushAt.IsZero() {
+		at := r.lastFlushAt
+		stats.LastFlushAt = &at
+		since = at
+	}
+	stats.LastFlushCount = r.lastFlushCount
+	stats.FlushLagSeconds = time.Since(since).Seconds()
+	if r.lastFlushErr != nil {
+		at := r.lastErrAt
+		stats.LastError = r.lastFlushErr.Error()
+		stats.LastErrorAt = &at
+	}
+	stats.LastCleanup = r.lastCleanup
+	stats.ConsecutiveFailures = r.failures
+	if r.failures > 0 {
+		since := r.failingSince
+		stats.FailingSince = &since
+	}
+	return stats
+}
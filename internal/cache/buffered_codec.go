@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// bufferedInventoryWire accepts every serialization of BufferedInventory that
+// has been written to Redis:
+//   - v1: KeyAccountID, RobloxUserID, RawJSON (base64), UpdatedAt
+//   - v2: v1 plus Section
+//
+// RawJSON may also arrive as an embedded JSON document (hand-edited exports).
+type bufferedInventoryWire struct {
+	KeyAccountID int64           `json:"KeyAccountID"`
+	RobloxUserID string          `json:"RobloxUserID"`
+	Section      string          `json:"Section"`
+	RawJSON      json.RawMessage `json:"RawJSON"`
+	UpdatedAt    time.Time       `json:"UpdatedAt"`
+}
+
+// DecodeBufferedInventory decodes and validates a buffered entry from any
+// known serialization version. Used by tooling that reads buffer dumps.
+func DecodeBufferedInventory(data []byte) (*BufferedInventory, error) {
+	var wire bufferedInventoryWire
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return nil, fmt.Errorf("invalid buffered entry: %w", err)
+	}
+
+	raw, err := decodeRawJSON(wire.RawJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &BufferedInventory{
+		KeyAccountID: wire.KeyAccountID,
+		RobloxUserID: wire.RobloxUserID,
+		Section:      wire.Section,
+		RawJSON:      raw,
+		UpdatedAt:    wire.UpdatedAt,
+	}
+	if err := ValidateBufferedInventory(inv); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// ValidateBufferedInventory checks that an entry is complete enough to persist.
+func ValidateBufferedInventory(inv *BufferedInventory) error {
+	switch {
+	case inv.RobloxUserID == "":
+		return errors.New("missing RobloxUserID")
+	case inv.UpdatedAt.IsZero():
+		return errors.New("missing UpdatedAt")
+	case len(inv.RawJSON) == 0:
+		return errors.New("missing RawJSON")
+	case !json.Valid(inv.RawJSON):
+		return errors.New("RawJSON is not valid JSON")
+	}
+	return nil
+}
+
+// decodeRawJSON handles RawJSON stored either as base64 ([]byte marshaling)
+// or as an embedded JSON value.
+func decodeRawJSON(field json.RawMessage) ([]byte, error) {
+	trimmed := bytes.TrimSpace(field)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return nil, errors.New("missing RawJSON")
+	}
+
+	if trimmed[0] != '"' {
+		return []byte(trimmed), nil
+	}
+
+	var encoded string
+	if err := json.Unmarshal(trimmed, &encoded); err != nil {
+		return nil, fmt.Errorf("invalid RawJSON: %w", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RawJSON encoding: %w", err)
+	}
+	return raw, nil
+}
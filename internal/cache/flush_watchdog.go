@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/metrics"
+)
+
+// watchdogAdjustments counts batch size changes made by the flush watchdog.
+var watchdogAdjustments = metrics.NewCounterVec("vinzhub_flush_watchdog_adjustments_total",
+	"Flush batch size changes made by the slow-flush watchdog.", "direction")
+
+// effectiveBatchSize is the batch size the watchdog currently allows, for
+// the gauge below; 0 until a watchdog exists.
+var effectiveBatchSize atomic.Int64
+
+var _ = metrics.NewGaugeFunc("vinzhub_flush_batch_size",
+	"Items per flush cycle currently allowed by the slow-flush watchdog.",
+	func() float64 { return float64(effectiveBatchSize.Load()) })
+
+const (
+	// watchdogSmoothing weighs the latest flush in the per-item cost average.
+	watchdogSmoothing = 0.3
+	// watchdogGrowth is the fraction a batch grows by per healthy flush.
+	watchdogGrowth = 0.1
+)
+
+// FlushWatchdogConfig bounds the slow-flush watchdog.
+type FlushWatchdogConfig struct {
+	MinBatch  int           // Smallest batch the watchdog may shrink to
+	MaxBatch  int           // Largest batch, and the starting size
+	SoftLimit time.Duration // A flush slower than this shrinks the batch
+}
+
+// FlushWatchdog sizes flush batches from how long flushes take. It keeps a
+// rolling average of the per-item cost; a flush slower than the soft limit
+// shrinks the batch to what the average says fits in half the limit, and
+// full batches that finish well within it grow the batch back by 10% at a
+// time. A batch settles where it takes between half and all of the limit.
+type FlushWatchdog struct {
+	cfg    FlushWatchdogConfig
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	batch     int
+	perItem   time.Duration // Rolling average cost of one item
+	lastTook  time.Duration
+	lastItems int
+	shrunk    int64
+	grown     int64
+	lastEvent string
+}
+
+// NewFlushWatchdog creates a watchdog starting at the maximum batch size.
+func NewFlushWatchdog(cfg FlushWatchdogConfig) *FlushWatchdog {
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = MaxBatchSize
+	}
+	if cfg.MinBatch <= 0 || cfg.MinBatch > cfg.MaxBatch {
+		cfg.MinBatch = min(50, cfg.MaxBatch)
+	}
+	w := &FlushWatchdog{cfg: cfg, logger: logging.Component("FlushWatchdog"), batch: cfg.MaxBatch}
+	effectiveBatchSize.Store(int64(w.batch))
+	return w
+}
+
+// BatchSize returns the number of items the next flush may take.
+func (w *FlushWatchdog) BatchSize() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.batch
+}
+
+// Observe records a flush of items that took took, and resizes the batch
+// for the next one. A flush cut off by its deadline counts as slow.
+func (w *FlushWatchdog) Observe(items int, took time.Duration, err error) {
+	if items <= 0 || w.cfg.SoftLimit <= 0 {
+		return
+	}
+	timedOut := errors.Is(err, context.DeadlineExceeded)
+	if err != nil && !timedOut {
+		return // Failed for another reason; says nothing about speed
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cost := took / time.Duration(items)
+	if w.perItem == 0 {
+		w.perItem = cost
+	} else {
+		w.perItem = time.Duration(watchdogSmoothing*float64(cost) + (1-watchdogSmoothing)*float64(w.perItem))
+	}
+	w.lastTook, w.lastItems = took, items
+
+	target := w.cfg.SoftLimit / 2
+	switch {
+	case timedOut || took > w.cfg.SoftLimit:
+		// Size for half the limit at the average cost, and at least halve
+		// on a timeout, when the cost of the cut-off items is unknown
+		next := int(target / max(w.perItem, cost))
+		if timedOut {
+			next = min(next, w.batch/2)
+		}
+		w.resize(max(next, w.cfg.MinBatch), took)
+	case items >= w.batch && w.batch < w.cfg.MaxBatch:
+		// A full batch within the limit: grow while the average says the
+		// larger batch still fits in half of it
+		next := min(w.batch+max(1, int(float64(w.batch)*watchdogGrowth)), w.cfg.MaxBatch)
+		if time.Duration(next)*w.perItem <= target {
+			w.resize(next, took)
+		}
+	}
+}
+
+// resize changes the batch size and reports the intervention. Callers hold mu.
+func (w *FlushWatchdog) resize(next int, took time.Duration) {
+	next = min(max(next, w.cfg.MinBatch), w.cfg.MaxBatch)
+	if next == w.batch {
+		return
+	}
+	prev := w.batch
+	w.batch = next
+	effectiveBatchSize.Store(int64(next))
+
+	if next < prev {
+		w.shrunk++
+		watchdogAdjustments.Inc("down")
+		w.lastEvent = fmt.Sprintf("%s: shrunk %d -> %d", time.Now().UTC().Format(time.RFC3339), prev, next)
+		w.logger.Warn("Slow flush, shrinking batch", "items", w.lastItems, "took", took.Round(time.Millisecond),
+			"soft_limit", w.cfg.SoftLimit, "per_item", w.perItem, "from", prev, "to", next)
+		return
+	}
+	w.grown++
+	watchdogAdjustments.Inc("up")
+	w.lastEvent = fmt.Sprintf("%s: grew %d -> %d", time.Now().UTC().Format(time.RFC3339), prev, next)
+	w.logger.Info("Flushes recovered, growing batch", "per_item", w.perItem, "from", prev, "to", next)
+}
+
+// Stats reports the watchdog state for the admin API.
+func (w *FlushWatchdog) Stats(ctx context.Context) map[string]interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stats := map[string]interface{}{
+		"batch_size":       w.batch,
+		"min_batch":        w.cfg.MinBatch,
+		"max_batch":        w.cfg.MaxBatch,
+		"soft_limit_ms":    w.cfg.SoftLimit.Milliseconds(),
+		"per_item_avg_us":  w.perItem.Microseconds(),
+		"last_flush_ms":    w.lastTook.Milliseconds(),
+		"last_flush_items": w.lastItems,
+		"times_shrunk":     w.shrunk,
+		"times_grown":      w.grown,
+	}
+	if w.lastEvent != "" {
+		stats["last_adjustment"] = w.lastEvent
+	}
+	return stats
+}
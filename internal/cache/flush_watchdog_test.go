@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// simulateFlushes runs cycles flushes of the watchdog's batch size, each
+// costing perItem per item on a simulated clock, and returns the last
+// batch size.
+func simulateFlushes(w *FlushWatchdog, cycles int, perItem time.Duration) int {
+	for i := 0; i < cycles; i++ {
+		batch := w.BatchSize()
+		w.Observe(batch, time.Duration(batch)*perItem, nil)
+	}
+	return w.BatchSize()
+}
+
+func TestFlushWatchdogConverges(t *testing.T) {
+	cfg := FlushWatchdogConfig{MinBatch: 10, MaxBatch: 500, SoftLimit: 10 * time.Second}
+	w := NewFlushWatchdog(cfg)
+
+	// The disk degrades: 100ms per item makes a full batch take 50s
+	slow := 100 * time.Millisecond
+	batch := simulateFlushes(w, 30, slow)
+	if took := time.Duration(batch) * slow; took > cfg.SoftLimit || took < cfg.SoftLimit/2 {
+		t.Errorf("settled at %d items (%v a flush), want between half and all of the %v soft limit", batch, took, cfg.SoftLimit)
+	}
+	if got := simulateFlushes(w, 30, slow); got != batch {
+		t.Errorf("batch moved from %d to %d at a steady cost", batch, got)
+	}
+	if got := effectiveBatchSize.Load(); got != int64(batch) {
+		t.Errorf("batch size gauge = %d, want %d", got, batch)
+	}
+
+	// The disk recovers; the batch grows back to the maximum
+	if got := simulateFlushes(w, 60, time.Millisecond); got != cfg.MaxBatch {
+		t.Errorf("after recovery batch = %d, want %d", got, cfg.MaxBatch)
+	}
+
+	stats := w.Stats(context.Background())
+	if stats["times_shrunk"].(int64) == 0 || stats["times_grown"].(int64) == 0 || stats["last_adjustment"] == nil {
+		t.Errorf("stats = %v, want the interventions counted", stats)
+	}
+}
+
+func TestFlushWatchdogBounds(t *testing.T) {
+	w := NewFlushWatchdog(FlushWatchdogConfig{MinBatch: 40, MaxBatch: 200, SoftLimit: time.Second})
+	if got := simulateFlushes(w, 20, time.Second); got != 40 {
+		t.Errorf("batch = %d at an unsustainable cost, want the minimum 40", got)
+	}
+
+	w = NewFlushWatchdog(FlushWatchdogConfig{MaxBatch: 200, SoftLimit: time.Second})
+	w.Observe(200, 5*time.Second, errors.New("disk I/O error"))
+	if got := w.BatchSize(); got != 200 {
+		t.Errorf("batch = %d after a failed flush, want it unchanged", got)
+	}
+	// A timeout at least halves the batch, though the cost looks sustainable
+	w.Observe(200, 100*time.Millisecond, context.DeadlineExceeded)
+	if got := w.BatchSize(); got != 100 {
+		t.Errorf("batch = %d after a timed-out flush, want 100", got)
+	}
+	// A partial batch says nothing about whether a larger one fits
+	w.Observe(10, time.Millisecond, nil)
+	if got := w.BatchSize(); got != 100 {
+		t.Errorf("batch = %d after a partial batch, want 100", got)
+	}
+
+	w = NewFlushWatchdog(FlushWatchdogConfig{MaxBatch: 300})
+	if got := simulateFlushes(w, 5, time.Minute); got != 300 {
+		t.Errorf("batch = %d without a soft limit, want it fixed at 300", got)
+	}
+}
@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// IdempotencyKeyPrefix namespaces idempotency records in Redis.
+const IdempotencyKeyPrefix = "vinzhub:idempotency:"
+
+// IdempotencyRecord is what is remembered for one idempotency key: a claim
+// while the first request runs, then its response.
+type IdempotencyRecord struct {
+	RequestHash string `json:"request_hash"` // A reused key must carry the same request
+	Done        bool   `json:"done"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// IdempotencyStore remembers idempotency keys for a limited time.
+type IdempotencyStore interface {
+	// Claim records an unfinished request under key unless the key is
+	// already known. Returns nil when claimed, otherwise the existing record.
+	Claim(ctx context.Context, key, requestHash string, ttl time.Duration) (*IdempotencyRecord, error)
+	// Complete replaces the claim with the finished request's response.
+	Complete(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error
+	// Get returns the record of key, nil when unknown or expired.
+	Get(ctx context.Context, key string) (*IdempotencyRecord, error)
+	// Release forgets a key, so a retry runs again.
+	Release(ctx context.Context, key string) error
+}
+
+// RedisIdempotencyStore keeps idempotency records in Redis with native
+// TTLs, shared by every instance.
+type RedisIdempotencyStore struct {
+	redis *redis.Client
+}
+
+// NewRedisIdempotencyStore creates a Redis-backed idempotency store.
+func NewRedisIdempotencyStore(redisClient *redis.Client) *RedisIdempotencyStore {
+	return &RedisIdempotencyStore{redis: redisClient}
+}
+
+// Claim sets the claim only if the key is absent.
+func (s *RedisIdempotencyStore) Claim(ctx context.Context, key, requestHash string, ttl time.Duration) (*IdempotencyRecord, error) {
+	data, err := json.Marshal(&IdempotencyRecord{RequestHash: requestHash})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		ok, err := s.redis.SetNX(ctx, IdempotencyKeyPrefix+key, data, ttl).Result()
+		if err != nil || ok {
+			return nil, err
+		}
+		record, err := s.Get(ctx, key)
+		if err != nil || record != nil {
+			return record, err
+		}
+		// Expired or released between the two calls - claim again
+	}
+}
+
+// Complete overwrites the claim, keeping the key for a full ttl.
+func (s *RedisIdempotencyStore) Complete(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.redis.Set(ctx, IdempotencyKeyPrefix+key, data, ttl).Err()
+}
+
+// Get reads a record.
+func (s *RedisIdempotencyStore) Get(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	data, err := s.redis.Get(ctx, IdempotencyKeyPrefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var record IdempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Release deletes a record.
+func (s *RedisIdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.redis.Del(ctx, IdempotencyKeyPrefix+key).Err()
+}
+
+// MemoryIdempotencyStore keeps idempotency records in process memory.
+// Records aren't shared between instances - meant for demo and
+// single-instance runs.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]memoryIdempotencyRecord
+	lastSweep time.Time
+}
+
+type memoryIdempotencyRecord struct {
+	record    IdempotencyRecord
+	expiresAt time.Time
+}
+
+// NewMemoryIdempotencyStore creates an in-memory idempotency store.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]memoryIdempotencyRecord)}
+}
+
+// Claim sets the claim only if the key is absent or expired. Expired
+// records are swept at most once a minute.
+func (s *MemoryIdempotencyStore) Claim(ctx context.Context, key, requestHash string, ttl time.Duration) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, rec := range s.records {
+			if now.After(rec.expiresAt) {
+				delete(s.records, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	if rec, ok := s.records[key]; ok && now.Before(rec.expiresAt) {
+		record := rec.record
+		return &record, nil
+	}
+	s.records[key] = memoryIdempotencyRecord{record: IdempotencyRecord{RequestHash: requestHash}, expiresAt: now.Add(ttl)}
+	return nil, nil
+}
+
+// Complete overwrites the claim.
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[key] = memoryIdempotencyRecord{record: *record, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// Get reads a record, ignoring it if expired.
+func (s *MemoryIdempotencyStore) Get(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[key]
+	if !ok || time.Now().After(rec.expiresAt) {
+		return nil, nil
+	}
+	record := rec.record
+	return &record, nil
+}
+
+// Release deletes a record.
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
@@ -0,0 +1,273 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"vinzhub-rest-api/internal/lifecycle"
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/metrics"
+	"vinzhub-rest-api/pkg/uid"
+)
+
+// Invalidation kinds. The key's meaning depends on the kind.
+const (
+	InvalidateInventory  = "inventory"  // Key: roblox user ID
+	InvalidateKeyAccount = "keyaccount" // Key: roblox user ID the lookup is cached under
+	InvalidateToken      = "token"      // Key: hex prefix of the token's SHA-256
+	InvalidateBlocklist  = "blocklist"  // No key: the whole list changed
+)
+
+// DefaultInvalidationChannel is the pub/sub channel used unless configured.
+const DefaultInvalidationChannel = "vinzhub:invalidate"
+
+const (
+	// invalidationPingInterval is how long the subscription may stay quiet
+	// before it is pinged, so a dead connection is noticed.
+	invalidationPingInterval = 30 * time.Second
+
+	// invalidationMaxBackoff caps the wait between resubscribe attempts.
+	invalidationMaxBackoff = 30 * time.Second
+)
+
+var (
+	invalidationMessages = metrics.NewCounterVec("vinzhub_invalidation_messages_total",
+		"Invalidation bus messages by direction (published, received) and kind.", "direction", "kind")
+	invalidationsHandled = metrics.NewCounterVec("vinzhub_invalidations_handled_total",
+		"Cache evictions made for invalidations from other instances, by kind.", "kind")
+)
+
+// invalidationMessage is the payload published on the channel.
+type invalidationMessage struct {
+	Origin string `json:"o"` // Publishing instance, which ignores its own messages
+	Kind   string `json:"k"`
+	Key    string `json:"key,omitempty"`
+}
+
+// InvalidationBus tells the other API instances to evict in-process cache
+// entries after a local change (a sync, a key-account edit, a revocation),
+// over Redis pub/sub. Messages are fire-and-forget; when the subscription
+// drops, messages may have been missed, so every cache is flushed both
+// when the drop is noticed and once resubscribed.
+//
+// A nil bus is valid and does nothing: single-instance deployments without
+// Redis have nothing to tell.
+type InvalidationBus struct {
+	client  *redis.Client
+	channel string
+	origin  string
+	logger  *slog.Logger
+
+	mu       sync.RWMutex
+	handlers map[string][]func(ctx context.Context, key string)
+	flushers []func(ctx context.Context)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	pubsub atomic.Pointer[redis.PubSub] // Closed by Close to end a pending read
+
+	subscribed atomic.Bool
+	drops      atomic.Int64
+	flushes    atomic.Int64
+	lastDrop   atomic.Int64 // unix seconds
+}
+
+// NewInvalidationBus creates a bus on channel. Start it to receive.
+func NewInvalidationBus(client *redis.Client, channel string) *InvalidationBus {
+	if channel == "" {
+		channel = DefaultInvalidationChannel
+	}
+	return &InvalidationBus{
+		client:   client,
+		channel:  channel,
+		origin:   uid.New(),
+		logger:   logging.Component("Invalidation"),
+		handlers: make(map[string][]func(ctx context.Context, key string)),
+		done:     make(chan struct{}),
+	}
+}
+
+// Subscribe registers fn to evict entries for invalidations of kind
+// published by other instances.
+func (b *InvalidationBus) Subscribe(kind string, fn func(ctx context.Context, key string)) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[kind] = append(b.handlers[kind], fn)
+}
+
+// OnFlush registers fn to drop a whole cache when invalidations may have
+// been missed.
+func (b *InvalidationBus) OnFlush(fn func(ctx context.Context)) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.flushers = append(b.flushers, fn)
+}
+
+// Publish tells the other instances to evict key of kind. Failures are
+// logged, not returned: the local change already happened, and the other
+// instances' entries expire by TTL in the worst case.
+func (b *InvalidationBus) Publish(ctx context.Context, kind, key string) {
+	if b == nil {
+		return
+	}
+	payload, _ := json.Marshal(invalidationMessage{Origin: b.origin, Kind: kind, Key: key})
+	if err := b.client.Publish(ctx, b.channel, payload).Err(); err != nil {
+		b.logger.WarnContext(ctx, "Failed to publish", "kind", kind, "key", key, "error", err)
+		return
+	}
+	invalidationMessages.Inc("published", kind)
+}
+
+// Start subscribes and handles invalidations until Close.
+func (b *InvalidationBus) Start() {
+	if b == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+	lifecycle.Go("invalidation.bus", func() {
+		b.receive(ctx)
+		close(b.done) // Not deferred: a panicking loop is restarted
+	})
+	b.logger.Info("Started", "channel", b.channel)
+}
+
+// Close unsubscribes.
+func (b *InvalidationBus) Close() {
+	if b == nil || b.cancel == nil {
+		return
+	}
+	b.cancel()
+	if pubsub := b.pubsub.Load(); pubsub != nil {
+		pubsub.Close()
+	}
+	<-b.done
+}
+
+// receive reads the subscription. go-redis reconnects and resubscribes on
+// the next read after an error; a subscribe confirmation after a drop
+// means messages may have been missed meanwhile.
+func (b *InvalidationBus) receive(ctx context.Context) {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	defer pubsub.Close()
+	b.pubsub.Store(pubsub)
+
+	lost := false
+	backoff := time.Second
+	for {
+		msg, err := pubsub.ReceiveTimeout(ctx, invalidationPingInterval)
+		if ctx.Err() != nil {
+			return
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			lifecycle.Touch("invalidation.bus")
+			if err = pubsub.Ping(ctx); err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			if !lost {
+				lost = true
+				b.subscribed.Store(false)
+				b.drops.Add(1)
+				b.lastDrop.Store(time.Now().Unix())
+				b.logger.Warn("Subscription lost, flushing caches", "error", err)
+				b.flush(ctx)
+			}
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			backoff = min(backoff*2, invalidationMaxBackoff)
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if m.Kind != "subscribe" {
+				continue
+			}
+			b.subscribed.Store(true)
+			backoff = time.Second
+			if lost {
+				lost = false
+				b.logger.Info("Resubscribed, flushing caches")
+				b.flush(ctx)
+			}
+		case *redis.Message:
+			lifecycle.Touch("invalidation.bus")
+			b.dispatch(ctx, m.Payload)
+		}
+	}
+}
+
+// dispatch runs the handlers of one message from another instance.
+func (b *InvalidationBus) dispatch(ctx context.Context, payload string) {
+	var msg invalidationMessage
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil || msg.Origin == b.origin {
+		return
+	}
+	invalidationMessages.Inc("received", msg.Kind)
+
+	b.mu.RLock()
+	handlers := b.handlers[msg.Kind]
+	b.mu.RUnlock()
+	for _, fn := range handlers {
+		fn(ctx, msg.Key)
+	}
+	if len(handlers) > 0 {
+		invalidationsHandled.Inc(msg.Kind)
+	}
+}
+
+// flush drops every registered cache.
+func (b *InvalidationBus) flush(ctx context.Context) {
+	b.mu.RLock()
+	flushers := b.flushers
+	b.mu.RUnlock()
+	for _, fn := range flushers {
+		fn(ctx)
+	}
+	b.flushes.Add(1)
+}
+
+// Stats returns subscription state for admin stats.
+func (b *InvalidationBus) Stats(ctx context.Context) map[string]interface{} {
+	b.mu.RLock()
+	kinds := make([]string, 0, len(b.handlers))
+	for kind := range b.handlers {
+		kinds = append(kinds, kind)
+	}
+	b.mu.RUnlock()
+	sort.Strings(kinds)
+
+	stats := map[string]interface{}{
+		"channel":    b.channel,
+		"origin":     b.origin,
+		"subscribed": b.subscribed.Load(),
+		"kinds":      kinds,
+		"drops":      b.drops.Load(),
+		"flushes":    b.flushes.Load(),
+	}
+	if last := b.lastDrop.Load(); last > 0 {
+		stats["last_drop_at"] = time.Unix(last, 0).UTC()
+	}
+	return stats
+}
@@ -2,27 +2,88 @@ package cache
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
+
+	"vinzhub-rest-api/internal/domain"
+	"vinzhub-rest-api/internal/lifecycle"
+	"vinzhub-rest-api/internal/logging"
+)
+
+// Buffer is a write-behind inventory buffer: syncs are held in it and
+// flushed to the database in batches. Implemented by RedisInventoryBuffer,
+// InventoryBuffer and FallbackBuffer.
+type Buffer interface {
+	// AddEntryIfChanged buffers an entry unless it would persist nothing
+	// new. Returns false when the write was skipped.
+	AddEntryIfChanged(ctx context.Context, data *BufferedInventory, persisted func() (string, error)) (bool, error)
+	// GetSection returns a buffered section, nil when none is buffered.
+	GetSection(ctx context.Context, robloxUserID, section string) (*BufferedInventory, error)
+	// GetSections returns several buffered sections of a user, keyed by
+	// section; sections with nothing buffered are absent.
+	GetSections(ctx context.Context, robloxUserID string, sections []string) (map[string]*BufferedInventory, error)
+	// RemoveSection drops a buffered section without flushing it.
+	RemoveSection(ctx context.Context, robloxUserID, section string) error
+	// FlushWindow estimates how long until an entry added now is flushed,
+	// and returns the current queue depth.
+	FlushWindow(ctx context.Context) (time.Duration, int64)
+	// Full reports whether syncs should be refused until flushing catches up.
+	Full() bool
+	// Backpressure returns the pending count and its limit (0 = none).
+	Backpressure() (pending, max int64)
+	// FlushInterval returns how often the buffer is flushed.
+	FlushInterval() time.Duration
+}
+
+var (
+	_ Buffer = (*RedisInventoryBuffer)(nil)
+	_ Buffer = (*InventoryBuffer)(nil)
+	_ Buffer = (*FallbackBuffer)(nil)
 )
 
 // InventoryBuffer holds pending inventory updates to be flushed to DB.
 // This implements write-behind caching to reduce database connections.
+// It is local to the process: FallbackBuffer uses it while Redis is down.
 type InventoryBuffer struct {
-	mu          sync.RWMutex
-	pending     map[string]*BufferedInventory // key: roblox_user_id
-	flushFunc   FlushFunc
-	flushTicker *time.Ticker
-	stopFlush   chan struct{}
+	mu            sync.RWMutex
+	pending       map[string]*BufferedInventory // key: BufferField
+	flushFunc     FlushFunc
+	flushInterval time.Duration
+	flushTicker   *time.Ticker
+	stopFlush     chan struct{}
+	stopOnce      sync.Once
+	logger        *slog.Logger
 }
 
 // BufferedInventory represents a pending inventory update.
 type BufferedInventory struct {
-	KeyAccountID int64
-	RobloxUserID string
-	RawJSON      []byte
-	UpdatedAt    time.Time
+	KeyAccountID  int64
+	RobloxUserID  string
+	Section       string // Empty for entries buffered before sections existed
+	RawJSON       []byte
+	UpdatedAt     time.Time
+	ClientVersion string `json:",omitempty"`
+	Callback      bool   `json:",omitempty"` // Notify the game once persisted
+	Trace         string `json:",omitempty"` // Span that buffered it, linked from the flush
+}
+
+// SectionName returns the entry's section, mapping legacy entries to the default.
+func (inv *BufferedInventory) SectionName() string {
+	if inv.Section == "" {
+		return domain.DefaultSection
+	}
+	return inv.Section
+}
+
+// BufferField returns the buffer hash field for a user's section.
+// The default section uses the bare user ID so entries written by older
+// versions (and read by older instances) stay compatible.
+func BufferField(robloxUserID, section string) string {
+	if section == "" || section == domain.DefaultSection {
+		return robloxUserID
+	}
+	return robloxUserID + ":" + section
 }
 
 // FlushFunc is called to persist buffered data to database.
@@ -33,38 +94,79 @@ type FlushFunc func(ctx context.Context, items []*BufferedInventory) error
 // flushFunc: function to call when flushing to database
 func NewInventoryBuffer(flushInterval time.Duration, flushFunc FlushFunc) *InventoryBuffer {
 	b := &InventoryBuffer{
-		pending:     make(map[string]*BufferedInventory),
-		flushFunc:   flushFunc,
-		flushTicker: time.NewTicker(flushInterval),
-		stopFlush:   make(chan struct{}),
+		pending:       make(map[string]*BufferedInventory),
+		flushFunc:     flushFunc,
+		flushInterval: flushInterval,
+		flushTicker:   time.NewTicker(flushInterval),
+		stopFlush:     make(chan struct{}),
+		logger:        logging.Component("InventoryBuffer"),
 	}
 
 	// Start background flush goroutine
-	go b.backgroundFlush()
+	lifecycle.Go("buffer.memory_flush", b.backgroundFlush)
 
-	log.Printf("[InventoryBuffer] Started with %v flush interval", flushInterval)
+	b.logger.Info("Started", "flush_interval", flushInterval)
 	return b
 }
 
-// Add adds or updates an inventory entry in the buffer.
+// Add adds or updates an inventory entry (default section) in the buffer.
 // This is very fast - no database hit!
 func (b *InventoryBuffer) Add(keyAccountID int64, robloxUserID string, rawJSON []byte) {
+	b.AddEntry(context.Background(), &BufferedInventory{
+		KeyAccountID: keyAccountID,
+		RobloxUserID: robloxUserID,
+		RawJSON:      rawJSON,
+	})
+}
+
+// AddEntry buffers a fully described entry. UpdatedAt is always set to now.
+func (b *InventoryBuffer) AddEntry(_ context.Context, data *BufferedInventory) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	b.put(data)
+	return nil
+}
+
+// put stores a copy of data, so callers may reuse their buffers.
+// Callers hold b.mu.
+func (b *InventoryBuffer) put(data *BufferedInventory) {
+	entry := *data
+	entry.RawJSON = make([]byte, len(data.RawJSON))
+	copy(entry.RawJSON, data.RawJSON)
+	entry.UpdatedAt = time.Now()
+	data.UpdatedAt = entry.UpdatedAt
+	b.pending[BufferField(entry.RobloxUserID, entry.Section)] = &entry
+}
 
-	// Make a copy of the JSON data
-	jsonCopy := make([]byte, len(rawJSON))
-	copy(jsonCopy, rawJSON)
+// AddEntryIfChanged buffers an entry unless the buffered copy has the same
+// content and key account or, with nothing buffered, persisted returns the
+// entry's Fingerprint. persisted may be nil. Entries asking for a callback
+// are always written. Returns false when the write was skipped.
+func (b *InventoryBuffer) AddEntryIfChanged(_ context.Context, data *BufferedInventory, persisted func() (string, error)) (bool, error) {
+	fingerprint := fingerprintOf(data)
+	field := BufferField(data.RobloxUserID, data.Section)
 
-	b.pending[robloxUserID] = &BufferedInventory{
-		KeyAccountID: keyAccountID,
-		RobloxUserID: robloxUserID,
-		RawJSON:      jsonCopy,
-		UpdatedAt:    time.Now(),
+	b.mu.RLock()
+	current, buffered := b.pending[field]
+	b.mu.RUnlock()
+	if !data.Callback {
+		if buffered && fingerprintOf(current) == fingerprint {
+			return false, nil
+		}
+		if !buffered && persisted != nil {
+			if stored, err := persisted(); err == nil && stored == fingerprint {
+				return false, nil
+			}
+		}
 	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.put(data)
+	return true, nil
 }
 
-// Get retrieves a buffered inventory (for read-through).
+// Get retrieves a buffered inventory (default section, for read-through).
 func (b *InventoryBuffer) Get(robloxUserID string) (*BufferedInventory, bool) {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
@@ -73,6 +175,84 @@ func (b *InventoryBuffer) Get(robloxUserID string) (*BufferedInventory, bool) {
 	return inv, exists
 }
 
+// GetSection retrieves one buffered section, nil when none is buffered.
+func (b *InventoryBuffer) GetSection(_ context.Context, robloxUserID, section string) (*BufferedInventory, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.pending[BufferField(robloxUserID, section)], nil
+}
+
+// GetSections retrieves several buffered sections of a user.
+// Sections with nothing buffered are absent from the result.
+func (b *InventoryBuffer) GetSections(_ context.Context, robloxUserID string, sections []string) (map[string]*BufferedInventory, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	result := make(map[string]*BufferedInventory, len(sections))
+	for _, section := range sections {
+		if inv, ok := b.pending[BufferField(robloxUserID, section)]; ok {
+			result[section] = inv
+		}
+	}
+	return result, nil
+}
+
+// RemoveSection drops a buffered section without flushing it.
+func (b *InventoryBuffer) RemoveSection(_ context.Context, robloxUserID, section string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.pending, BufferField(robloxUserID, section))
+	return nil
+}
+
+// Take removes and returns every pending entry, for handing them to
+// another buffer.
+func (b *InventoryBuffer) Take() []*BufferedInventory {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	items := make([]*BufferedInventory, 0, len(b.pending))
+	for _, inv := range b.pending {
+		items = append(items, inv)
+	}
+	b.pending = make(map[string]*BufferedInventory)
+	return items
+}
+
+// Restore puts back entries returned by Take, unless a newer write for the
+// same section was buffered meanwhile.
+func (b *InventoryBuffer) Restore(items []*BufferedInventory) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, inv := range items {
+		field := BufferField(inv.RobloxUserID, inv.Section)
+		if _, exists := b.pending[field]; !exists {
+			b.pending[field] = inv
+		}
+	}
+}
+
+// FlushWindow estimates how long until an entry added now is flushed: the
+// whole buffer goes every flush interval.
+func (b *InventoryBuffer) FlushWindow(_ context.Context) (time.Duration, int64) {
+	return b.flushInterval, int64(b.Count())
+}
+
+// Full always reports false; the in-memory buffer has no pending limit.
+func (b *InventoryBuffer) Full() bool {
+	return false
+}
+
+// Backpressure returns the pending count and no limit.
+func (b *InventoryBuffer) Backpressure() (pending, max int64) {
+	return int64(b.Count()), 0
+}
+
+// FlushInterval returns how often the buffer is flushed.
+func (b *InventoryBuffer) FlushInterval() time.Duration {
+	return b.flushInterval
+}
+
 // Count returns the number of pending items.
 func (b *InventoryBuffer) Count() int {
 	b.mu.RLock()
@@ -99,24 +279,17 @@ func (b *InventoryBuffer) Flush(ctx context.Context) error {
 	b.pending = make(map[string]*BufferedInventory)
 	b.mu.Unlock()
 
-	log.Printf("[InventoryBuffer] Flushing %d items to database", len(items))
+	b.logger.InfoContext(ctx, "Flushing to database", "items", len(items))
 
 	// Flush to database
 	if err := b.flushFunc(ctx, items); err != nil {
-		log.Printf("[InventoryBuffer] Flush error: %v", err)
-		// Re-add failed items back to buffer
-		b.mu.Lock()
-		for _, inv := range items {
-			// Only re-add if not already updated
-			if _, exists := b.pending[inv.RobloxUserID]; !exists {
-				b.pending[inv.RobloxUserID] = inv
-			}
-		}
-		b.mu.Unlock()
+		b.logger.ErrorContext(ctx, "Flush failed", "items", len(items), "error", err)
+		// Re-add failed items back to buffer, unless already updated
+		b.Restore(items)
 		return err
 	}
 
-	log.Printf("[InventoryBuffer] Successfully flushed %d items", len(items))
+	b.logger.InfoContext(ctx, "Flushed", "items", len(items))
 	return nil
 }
 
@@ -125,6 +298,7 @@ func (b *InventoryBuffer) backgroundFlush() {
 	for {
 		select {
 		case <-b.flushTicker.C:
+			lifecycle.Touch("buffer.memory_flush")
 			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 			b.Flush(ctx)
 			cancel()
@@ -140,7 +314,9 @@ func (b *InventoryBuffer) backgroundFlush() {
 
 // Close stops the background flush and performs a final flush.
 func (b *InventoryBuffer) Close() error {
-	b.flushTicker.Stop()
-	close(b.stopFlush)
+	b.stopOnce.Do(func() {
+		b.flushTicker.Stop()
+		close(b.stopFlush)
+	})
 	return nil
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"sync"
 	"time"
+
+	"vinzhub-rest-api/internal/lifecycle"
 )
 
 // cacheEntry represents a cached value with expiration.
@@ -38,7 +40,7 @@ func NewMemoryCache() *MemoryCache {
 	}
 
 	// Start background cleanup goroutine
-	go c.cleanup()
+	lifecycle.Go("cache.cleanup", c.cleanup)
 
 	return c
 }
@@ -142,6 +144,7 @@ func (c *MemoryCache) cleanup() {
 	for {
 		select {
 		case <-ticker.C:
+			lifecycle.Touch("cache.cleanup")
 			c.removeExpired()
 		case <-c.stopCleanup:
 			return
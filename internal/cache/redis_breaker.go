@@ -0,0 +1,218 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Default circuit breaker settings for the Redis buffer.
+const (
+	// BreakerThreshold is the number of consecutive Redis errors or
+	// timeouts that open the circuit.
+	BreakerThreshold = 5
+	// BreakerCooldown is how long the circuit stays open before one
+	// command is let through to probe Redis.
+	BreakerCooldown = 15 * time.Second
+)
+
+// ErrCircuitOpen is returned by buffer operations while the circuit
+// breaker is open, instead of waiting out Redis timeouts.
+var ErrCircuitOpen = errors.New("redis circuit breaker open")
+
+// Circuit breaker states.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// BreakerStats is a snapshot of the circuit breaker for admin stats.
+type BreakerStats struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Threshold           int        `json:"threshold"`
+	CooldownSeconds     float64    `json:"cooldown_seconds"`
+	Opens               int64      `json:"opens"`
+	Rejected            int64      `json:"rejected"` // Commands failed fast while open
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// circuitBreaker fails Redis commands fast after threshold consecutive
+// connection errors or timeouts, so a hung Redis costs syncs nothing
+// instead of a read timeout each. After cooldown a single command probes
+// Redis (half-open): success closes the circuit, failure reopens it.
+// Installed as a go-redis hook, it guards every command of the client.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+	opens    int64
+	rejected int64
+	lastErr  string
+
+	onChange func(from, to string, err error)
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		threshold = BreakerThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = BreakerCooldown
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, state: BreakerClosed}
+}
+
+// allow reports whether a command may go to Redis. Once the cooldown is
+// over the first caller becomes the half-open probe; others keep failing
+// fast until it reports back.
+func (cb *circuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case BreakerClosed:
+		return true
+	case BreakerOpen:
+		if time.Since(cb.openedAt) < cb.cooldown {
+			cb.rejected++
+			return false
+		}
+		cb.setState(BreakerHalfOpen, nil)
+		cb.probing = true
+		return true
+	default:
+		if cb.probing {
+			cb.rejected++
+			return false
+		}
+		cb.probing = true
+		return true
+	}
+}
+
+// record notes the outcome of a command let through by allow. A command
+// its caller cancelled says nothing about Redis and changes no state.
+func (cb *circuitBreaker) record(err error) {
+	failed := breakerFailure(err)
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.probing = false
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if !failed {
+		cb.failures = 0
+		if cb.state != BreakerClosed {
+			cb.setState(BreakerClosed, nil)
+		}
+		return
+	}
+
+	cb.failures++
+	cb.lastErr = err.Error()
+	if cb.state == BreakerHalfOpen || (cb.state == BreakerClosed && cb.failures >= cb.threshold) {
+		cb.openedAt = time.Now()
+		cb.opens++
+		cb.setState(BreakerOpen, err)
+	}
+}
+
+// setState switches state and reports the change. Callers hold cb.mu.
+func (cb *circuitBreaker) setState(to string, err error) {
+	from := cb.state
+	cb.state = to
+	if cb.onChange != nil && from != to {
+		cb.onChange(from, to, err)
+	}
+}
+
+// breakerFailure reports whether err says Redis is unreachable or hung:
+// dial and network errors, dropped connections and timeouts. Replies
+// Redis sent (nil, WRONGTYPE, OOM...) and callers cancelling don't count:
+// Redis answered, or nobody waited for it.
+func breakerFailure(err error) bool {
+	if err == nil || err == redis.Nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	var redisErr redis.Error
+	return !errors.As(err, &redisErr)
+}
+
+// logBreaker logs the buffer's circuit breaker changing state.
+func (b *RedisInventoryBuffer) logBreaker(from, to string, err error) {
+	switch to {
+	case BreakerOpen:
+		b.logger.Error("Redis circuit breaker opened, failing buffer operations fast",
+			"from", from, "cooldown", b.breaker.cooldown, "error", err)
+	case BreakerClosed:
+		b.logger.Info("Redis circuit breaker closed", "from", from)
+	}
+}
+
+// Breaker returns the state of the circuit breaker guarding Redis.
+func (b *RedisInventoryBuffer) Breaker() BreakerStats {
+	return b.breaker.Stats()
+}
+
+// Stats returns a snapshot of the breaker.
+func (cb *circuitBreaker) Stats() BreakerStats {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	stats := BreakerStats{
+		State:               cb.state,
+		ConsecutiveFailures: cb.failures,
+		Threshold:           cb.threshold,
+		CooldownSeconds:     cb.cooldown.Seconds(),
+		Opens:               cb.opens,
+		Rejected:            cb.rejected,
+		LastError:           cb.lastErr,
+	}
+	if cb.state != BreakerClosed {
+		at := cb.openedAt
+		stats.OpenedAt = &at
+	}
+	return stats
+}
+
+// DialHook passes dials through; failed dials surface as command errors.
+func (cb *circuitBreaker) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook guards a single command.
+func (cb *circuitBreaker) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !cb.allow() {
+			cmd.SetErr(ErrCircuitOpen)
+			return ErrCircuitOpen
+		}
+		err := next(ctx, cmd)
+		cb.record(err)
+		return err
+	}
+}
+
+// ProcessPipelineHook guards a pipeline as one command.
+func (cb *circuitBreaker) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if !cb.allow() {
+			for _, cmd := range cmds {
+				cmd.SetErr(ErrCircuitOpen)
+			}
+			return ErrCircuitOpen
+		}
+		err := next(ctx, cmds)
+		cb.record(err)
+		return err
+	}
+}
@@ -2,11 +2,23 @@ package cache
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"vinzhub-rest-api/internal/domain"
+	"vinzhub-rest-api/internal/lifecycle"
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/tracing"
+
 	"github.com/redis/go-redis/v9"
 )
 
@@ -14,8 +26,10 @@ import (
 // CONFIGURATION CONSTANTS
 // ============================================================================
 
+// Defaults for the matching RedisBufferConfig fields when left zero.
 const (
-	// MaxBatchSize limits items per flush cycle to prevent SQLite write lock timeout
+	// MaxBatchSize is the default limit of items per flush cycle, preventing
+	// SQLite write lock timeouts. The flush watchdog may lower it at runtime
 	MaxBatchSize = 500
 
 	// FlushTimeout is the max time allowed for a single flush operation
@@ -29,30 +43,105 @@ const (
 	CleanupInterval = 5 * time.Minute
 )
 
-var deleteIfUnchangedScript = redis.NewScript(`
-	if redis.call("HGET", KEYS[1], ARGV[1]) == ARGV[2] then
-		redis.call("HDEL", KEYS[1], ARGV[1])
-		redis.call("SREM", KEYS[2], ARGV[1])
-		return 1
-	else
-		return 0
+// ackFlushedScript clears, in one atomic call, flushed fields whose payload
+// still matches the flushed SHA-1, and deletes their fingerprint and
+// failure count along with them. ARGV holds field, SHA-1 of the flushed
+// payload pairs - hashes keep the call small and are compared server-side.
+// Returns the removed field names.
+//
+// KEYS: buffer, queue, hashes, failures
+var ackFlushedScript = redis.NewScript(`
+	local removed = {}
+	for i = 1, #ARGV, 2 do
+		local current = redis.call("HGET", KEYS[1], ARGV[i])
+		if current and redis.sha1hex(current) == ARGV[i + 1] then
+			redis.call("HDEL", KEYS[1], ARGV[i])
+			redis.call("ZREM", KEYS[2], ARGV[i])
+			redis.call("HDEL", KEYS[3], ARGV[i])
+			redis.call("HDEL", KEYS[4], ARGV[i])
+			removed[#removed + 1] = ARGV[i]
+		end
+	end
+	return removed
+`)
+
+// dropFieldsScript removes pending fields that can never be flushed, each
+// only if nothing rewrote it since it was read: a missing entry leaves the
+// queue while its field is still absent from the buffer, and a corrupt one
+// is deleted with its fingerprint and failure count while its payload still
+// has the SHA-1 that was read. ARGV holds the number of missing fields, the
+// missing fields, then field, SHA-1 of the corrupt payload pairs. Returns
+// the number of corrupt entries deleted.
+//
+// KEYS: buffer, queue, hashes, failures
+var dropFieldsScript = redis.NewScript(`
+	local missing = tonumber(ARGV[1])
+	for i = 2, missing + 1 do
+		if redis.call("HEXISTS", KEYS[1], ARGV[i]) == 0 then
+			redis.call("ZREM", KEYS[2], ARGV[i])
+		end
 	end
+	local dropped = 0
+	for i = missing + 2, #ARGV, 2 do
+		local current = redis.call("HGET", KEYS[1], ARGV[i])
+		if current and redis.sha1hex(current) == ARGV[i + 1] then
+			redis.call("HDEL", KEYS[1], ARGV[i])
+			redis.call("ZREM", KEYS[2], ARGV[i])
+			redis.call("HDEL", KEYS[3], ARGV[i])
+			redis.call("HDEL", KEYS[4], ARGV[i])
+			dropped = dropped + 1
+		end
+	end
+	return dropped
 `)
 
+// cleanupPageSize is the number of pending fields the stale cleanup reads
+// per round trip.
+const cleanupPageSize = 500
+
+// ackChunkFields bounds the fields acknowledged per script call. A normal
+// batch fits in one call; larger ones are split rather than sent as one
+// huge command.
+const ackChunkFields = 1000
+
 // RedisInventoryBuffer uses Redis for write-behind caching.
 // Sync requests are buffered in Redis, then batch-flushed to SQLite.
 // Features:
-// - Batch flush (max 500 items per cycle) to prevent DB overload
-// - Auto-cleanup of stale data (>1 hour old)
-// - Graceful shutdown with final flush
+// - Batch flush (MaxBatchSize items per cycle by default) to prevent DB overload
+// - Auto-cleanup of stale data (StaleDataThreshold by default)
+// - Graceful shutdown with final flush (see Shutdown)
 type RedisInventoryBuffer struct {
 	client        *redis.Client
 	flushFunc     FlushFunc
 	flushTicker   *time.Ticker
 	cleanupTicker *time.Ticker
 	stopFlush     chan struct{}
-	stopOnce      sync.Once
+	flushDone     chan struct{} // Closed when backgroundFlush returns
+	cleanupDone   chan struct{} // Closed when backgroundCleanup returns
+	shutdownOnce  sync.Once
+	shutdownErr   error
 	keyPrefix     string
+	flushInterval time.Duration
+	flushTimeout  time.Duration
+	staleAfter    time.Duration
+	deadAfter     int
+	alertAfter    int
+	maxPending    int64
+	hold          func() bool
+	held          bool // Last hold state seen by the flush loop
+	watchdog      *FlushWatchdog
+	logger        *slog.Logger
+	flushes       flushRecord
+	flushMu       sync.Mutex // One flush at a time: background, drain or shutdown
+	breaker       *circuitBreaker
+	bus           *InvalidationBus // nil: nobody to tell
+
+	// Spool takes writes Redis can't: out of memory, or pending bytes over
+	// spoolBudget (0 = only on OOM)
+	spool        *DiskSpool
+	spoolBudget  int64
+	pendingBytes atomic.Int64 // Estimate: refreshed every flush tick, plus bytes added since
+	pendingCount atomic.Int64 // Refreshed every flush and cleanup cycle
 }
 
 // RedisBufferConfig holds configuration for Redis buffer.
@@ -62,18 +151,81 @@ type RedisBufferConfig struct {
 	DB            int           // Redis database number (use different DB per app)
 	FlushInterval time.Duration // How often to flush to SQLite
 	KeyPrefix     string        // Optional custom key prefix
+	TLS           RedisTLSConfig
+
+	// DeadLetterAfter moves an entry to the dead-letter hash after this
+	// many consecutive failed flushes while others succeed (0 = never)
+	DeadLetterAfter int
+
+	// Zero values fall back to the package constants of the same name
+	MaxBatchSize       int           // Items per flush cycle; caps Watchdog.MaxBatch
+	FlushTimeout       time.Duration // Deadline for one background flush
+	StaleDataThreshold time.Duration // Buffered entries older than this are dropped
+	CleanupInterval    time.Duration // How often stale entries are looked for
+	FlushAlertAfter    int           // Consecutive failed flushes that log an alert
+	BreakerThreshold   int           // Consecutive Redis errors that open the circuit
+	BreakerCooldown    time.Duration // How long the circuit stays open
+
+	// MaxPending refuses syncs (see Full) while more entries than this
+	// wait to be flushed (0 = never)
+	MaxPending int64
+
+	// Watchdog shrinks the flush batch below MaxBatch while flushes are
+	// slow. A zero SoftLimit keeps the batch at MaxBatch
+	Watchdog FlushWatchdogConfig
+
+	// Logger defaults to logging.Component("RedisInventoryBuffer")
+	Logger *slog.Logger
+}
+
+// withDefaults fills in the zero settings: the package constants, a 30s
+// flush interval and the default key prefix. The watchdog may not start
+// above MaxBatchSize.
+func (cfg RedisBufferConfig) withDefaults() RedisBufferConfig {
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = "vinzhub:fishit:inventory"
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 30 * time.Second
+	}
+	if cfg.MaxBatchSize <= 0 {
+		cfg.MaxBatchSize = MaxBatchSize
+	}
+	if cfg.Watchdog.MaxBatch <= 0 || cfg.Watchdog.MaxBatch > cfg.MaxBatchSize {
+		cfg.Watchdog.MaxBatch = cfg.MaxBatchSize
+	}
+	if cfg.FlushTimeout <= 0 {
+		cfg.FlushTimeout = FlushTimeout
+	}
+	if cfg.StaleDataThreshold <= 0 {
+		cfg.StaleDataThreshold = StaleDataThreshold
+	}
+	if cfg.CleanupInterval <= 0 {
+		cfg.CleanupInterval = CleanupInterval
+	}
+	if cfg.FlushAlertAfter <= 0 {
+		cfg.FlushAlertAfter = FlushAlertAfter
+	}
+	return cfg
 }
 
 // NewRedisInventoryBuffer creates a Redis-backed inventory buffer.
 func NewRedisInventoryBuffer(cfg RedisBufferConfig, flushFunc FlushFunc) (*RedisInventoryBuffer, error) {
+	tlsConfig, err := cfg.TLS.ClientConfig(cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
 	client := redis.NewClient(&redis.Options{
 		Addr:         cfg.Addr,
 		Password:     cfg.Password,
 		DB:           cfg.DB,
 		PoolSize:     20,  // Increased for high concurrency
-		MinIdleConns: 5,   // Keep more idle connections ready
+		// No MinIdleConns: the pool would start dialing inside NewClient,
+		// racing the breaker hook added below. Used connections still stay
+		// idle in the pool
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
+		TLSConfig:    tlsConfig,
 	})
 
 	// Test connection
@@ -81,69 +233,217 @@ func NewRedisInventoryBuffer(cfg RedisBufferConfig, flushFunc FlushFunc) (*Redis
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, err
+		client.Close()
+		return nil, redisConnectError(err, tlsConfig != nil)
 	}
 
-	keyPrefix := cfg.KeyPrefix
-	if keyPrefix == "" {
-		keyPrefix = "vinzhub:fishit:inventory"
+	logger := cfg.Logger
+	if logger == nil {
+		logger = logging.Component("RedisInventoryBuffer")
 	}
 
+	cfg = cfg.withDefaults()
+	keyPrefix := cfg.KeyPrefix
+
 	b := &RedisInventoryBuffer{
 		client:        client,
 		flushFunc:     flushFunc,
 		flushTicker:   time.NewTicker(cfg.FlushInterval),
-		cleanupTicker: time.NewTicker(CleanupInterval),
+		cleanupTicker: time.NewTicker(cfg.CleanupInterval),
 		stopFlush:     make(chan struct{}),
+		flushDone:     make(chan struct{}),
+		cleanupDone:   make(chan struct{}),
 		keyPrefix:     keyPrefix,
+		flushInterval: cfg.FlushInterval,
+		flushTimeout:  cfg.FlushTimeout,
+		staleAfter:    cfg.StaleDataThreshold,
+		deadAfter:     cfg.DeadLetterAfter,
+		alertAfter:    cfg.FlushAlertAfter,
+		maxPending:    cfg.MaxPending,
+		watchdog:      NewFlushWatchdog(cfg.Watchdog),
+		breaker:       newCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+		logger:        logger,
 	}
+	b.breaker.onChange = b.logBreaker
+	client.AddHook(b.breaker)
+	b.flushes.startedAt = time.Now()
+	b.migratePending(ctx)
+	b.refreshPendingCount(ctx)
 
 	// Start background workers
-	go b.backgroundFlush()
-	go b.backgroundCleanup()
+	lifecycle.Go("buffer.flush", b.backgroundFlush)
+	lifecycle.Go("buffer.cleanup", b.backgroundCleanup)
 
-	log.Printf("[RedisInventoryBuffer] Started - DB:%d, prefix:%s, flush:%v, batch:%d, stale:%v",
-		cfg.DB, keyPrefix, cfg.FlushInterval, MaxBatchSize, StaleDataThreshold)
+	logger.Info("Started", "db", cfg.DB, "prefix", keyPrefix, "flush_interval", cfg.FlushInterval,
+		"batch", b.watchdog.BatchSize(), "stale_after", cfg.StaleDataThreshold)
 	return b, nil
 }
 
+// FlushWatchdog returns the watchdog sizing flush batches.
+func (b *RedisInventoryBuffer) FlushWatchdog() *FlushWatchdog {
+	return b.watchdog
+}
+
+// SetHoldFunc sets a check that, while true, keeps the background flush and
+// stale cleanup from touching buffered data (e.g. FlushPipeline.Paused).
+func (b *RedisInventoryBuffer) SetHoldFunc(hold func() bool) {
+	b.hold = hold
+}
+
+// SetInvalidationBus makes every buffered write tell the other instances
+// to evict their cached copies of the user's inventory. A nil bus (one
+// instance, or no channel configured) publishes nothing.
+func (b *RedisInventoryBuffer) SetInvalidationBus(bus *InvalidationBus) {
+	b.bus = bus
+}
+
+// onHold reports whether buffered data must be left alone right now.
+func (b *RedisInventoryBuffer) onHold() bool {
+	return b.hold != nil && b.hold()
+}
+
+// SetSpool attaches a disk spool for writes Redis can't take. budgetBytes
+// spools writes while the buffer holds more than that many bytes; 0 spools
+// only when Redis is out of memory.
+func (b *RedisInventoryBuffer) SetSpool(spool *DiskSpool, budgetBytes int64) {
+	b.spool = spool
+	b.spoolBudget = budgetBytes
+	b.refreshPendingBytes(context.Background())
+}
+
+// DrainSpool flushes spooled entries until the spool is empty, merging
+// each batch with Redis like the flush loop. Used at startup.
+func (b *RedisInventoryBuffer) DrainSpool(ctx context.Context) (int, error) {
+	if b.spool == nil {
+		return 0, nil
+	}
+	start := b.spool.Depth()
+	for depth := start; depth > 0 && ctx.Err() == nil; {
+		if _, err := b.FlushBatch(ctx); err != nil {
+			return start - b.spool.Depth(), err
+		}
+		next := b.spool.Depth()
+		if next >= depth {
+			break // No progress; the flush loop retries
+		}
+		depth = next
+	}
+	return start - b.spool.Depth(), ctx.Err()
+}
+
+// refreshPendingBytes re-reads the buffer's memory use from Redis.
+func (b *RedisInventoryBuffer) refreshPendingBytes(ctx context.Context) {
+	if b.spoolBudget <= 0 {
+		return
+	}
+	used, err := b.client.MemoryUsage(ctx, b.bufferKey()).Result()
+	if err == redis.Nil {
+		used = 0
+	} else if err != nil {
+		return
+	}
+	b.pendingBytes.Store(used)
+}
+
+// isOOM reports whether Redis refused a write for lack of memory.
+func isOOM(err error) bool {
+	var redisErr redis.Error
+	return errors.As(err, &redisErr) && strings.HasPrefix(redisErr.Error(), "OOM")
+}
+
 // bufferKey returns the namespaced buffer key
 func (b *RedisInventoryBuffer) bufferKey() string {
 	return b.keyPrefix + ":buffer"
 }
 
-// pendingKey returns the namespaced pending set key
+// pendingKey returns the namespaced key of the pending queue, a sorted set
+// of fields scored by when they became pending.
 func (b *RedisInventoryBuffer) pendingKey() string {
-	return b.keyPrefix + ":pending"
+	return b.keyPrefix + ":queue"
 }
 
-// Add buffers an inventory update in Redis.
+// hashesKey returns the namespaced key of the fingerprints of buffered
+// entries, used to skip re-buffering unchanged content.
+func (b *RedisInventoryBuffer) hashesKey() string {
+	return b.keyPrefix + ":hashes"
+}
+
+// Add buffers an inventory update (default section) in Redis.
 // This is very fast - no SQLite hit!
 func (b *RedisInventoryBuffer) Add(ctx context.Context, keyAccountID int64, robloxUserID string, rawJSON []byte) error {
-	data := &BufferedInventory{
+	return b.AddSection(ctx, keyAccountID, robloxUserID, domain.DefaultSection, rawJSON)
+}
+
+// AddSection buffers an update for one section of a user's inventory.
+// Each section has its own hash field and pending member, so sections are
+// flushed independently.
+func (b *RedisInventoryBuffer) AddSection(ctx context.Context, keyAccountID int64, robloxUserID, section string, rawJSON []byte) error {
+	return b.AddEntry(ctx, &BufferedInventory{
 		KeyAccountID: keyAccountID,
 		RobloxUserID: robloxUserID,
+		Section:      section,
 		RawJSON:      rawJSON,
-		UpdatedAt:    time.Now(),
+	})
+}
+
+// AddEntry buffers a fully described entry. UpdatedAt is always set to now,
+// and Trace to the span in ctx when traced.
+func (b *RedisInventoryBuffer) AddEntry(ctx context.Context, data *BufferedInventory) error {
+	ctx, span := tracing.Start(ctx, "RedisInventoryBuffer.AddEntry")
+	if span == nil {
+		return b.addEntry(ctx, data)
 	}
+	data.Trace = span.Ref()
+	err := b.addEntry(ctx, data)
+	span.Fail(err)
+	span.End()
+	return err
+}
+
+func (b *RedisInventoryBuffer) addEntry(ctx context.Context, data *BufferedInventory) error {
+	data.UpdatedAt = time.Now()
 
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return err
 	}
 
+	if b.spool != nil && b.spoolBudget > 0 && b.pendingBytes.Load() > b.spoolBudget {
+		return b.spool.Write(ctx, data, "over budget")
+	}
+
+	field := BufferField(data.RobloxUserID, data.Section)
 	pipe := b.client.Pipeline()
-	pipe.HSet(ctx, b.bufferKey(), robloxUserID, jsonData)
-	pipe.SAdd(ctx, b.pendingKey(), robloxUserID)
+	pipe.HSet(ctx, b.bufferKey(), field, jsonData)
+	pipe.ZAddNX(ctx, b.pendingKey(), redis.Z{Score: pendingScore(data.UpdatedAt), Member: field})
+	pipe.HSet(ctx, b.hashesKey(), field, entryFingerprint(data, jsonData))
 	_, err = pipe.Exec(ctx)
-	return err
+	if err != nil {
+		if b.spool != nil && isOOM(err) {
+			return b.spool.Write(ctx, data, "out of memory")
+		}
+		return err
+	}
+
+	b.pendingBytes.Add(int64(len(jsonData)))
+	if b.spool != nil {
+		b.spool.Discard(data.RobloxUserID, data.Section) // Any spooled copy is older
+	}
+	b.bus.Publish(ctx, InvalidateInventory, data.RobloxUserID)
+	return nil
 }
 
-// Get retrieves a buffered inventory from Redis.
+// Get retrieves a buffered inventory (default section) from Redis.
 func (b *RedisInventoryBuffer) Get(ctx context.Context, robloxUserID string) (*BufferedInventory, error) {
-	data, err := b.client.HGet(ctx, b.bufferKey(), robloxUserID).Bytes()
+	return b.GetSection(ctx, robloxUserID, domain.DefaultSection)
+}
+
+// GetSection retrieves one buffered section from Redis.
+// Returns nil (and no error) when nothing is buffered.
+func (b *RedisInventoryBuffer) GetSection(ctx context.Context, robloxUserID, section string) (*BufferedInventory, error) {
+	data, err := b.client.HGet(ctx, b.bufferKey(), BufferField(robloxUserID, section)).Bytes()
 	if err == redis.Nil {
-		return nil, nil
+		return b.spooledNewer(robloxUserID, section, nil), nil
 	}
 	if err != nil {
 		return nil, err
@@ -154,185 +454,505 @@ func (b *RedisInventoryBuffer) Get(ctx context.Context, robloxUserID string) (*B
 		return nil, err
 	}
 
-	return &inv, nil
+	return b.spooledNewer(robloxUserID, section, &inv), nil
+}
+
+// IsPending reports whether a user's section is in the pending queue, i.e.
+// due to be flushed.
+func (b *RedisInventoryBuffer) IsPending(ctx context.Context, robloxUserID, section string) (bool, error) {
+	err := b.client.ZScore(ctx, b.pendingKey(), BufferField(robloxUserID, section)).Err()
+	if err == redis.Nil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// spooledNewer returns the spooled copy of a section when it is newer than
+// the Redis copy (which may be nil), otherwise the Redis copy.
+func (b *RedisInventoryBuffer) spooledNewer(robloxUserID, section string, inv *BufferedInventory) *BufferedInventory {
+	if b.spool == nil || b.spool.Depth() == 0 {
+		return inv
+	}
+	spooled, err := b.spool.Get(robloxUserID, section)
+	if err != nil || spooled == nil {
+		return inv
+	}
+	if inv == nil || spooled.UpdatedAt.After(inv.UpdatedAt) {
+		return spooled
+	}
+	return inv
+}
+
+// GetSections retrieves several buffered sections of a user in one round trip.
+// Sections with nothing buffered are absent from the result.
+func (b *RedisInventoryBuffer) GetSections(ctx context.Context, robloxUserID string, sections []string) (map[string]*BufferedInventory, error) {
+	result := make(map[string]*BufferedInventory, len(sections))
+	if len(sections) == 0 {
+		return result, nil
+	}
+
+	fields := make([]string, len(sections))
+	for i, section := range sections {
+		fields[i] = BufferField(robloxUserID, section)
+	}
+
+	values, err := b.client.HMGet(ctx, b.bufferKey(), fields...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range values {
+		var inv *BufferedInventory
+		if str, ok := v.(string); ok {
+			inv = &BufferedInventory{}
+			if err := json.Unmarshal([]byte(str), inv); err != nil {
+				return nil, err
+			}
+		}
+		if inv = b.spooledNewer(robloxUserID, sections[i], inv); inv != nil {
+			result[sections[i]] = inv
+		}
+	}
+	return result, nil
+}
+
+// RemoveSection drops a buffered section without flushing it. Used when a
+// newer copy is written straight to the database.
+func (b *RedisInventoryBuffer) RemoveSection(ctx context.Context, robloxUserID, section string) error {
+	field := BufferField(robloxUserID, section)
+	pipe := b.client.TxPipeline()
+	pipe.HDel(ctx, b.bufferKey(), field)
+	pipe.ZRem(ctx, b.pendingKey(), field)
+	pipe.HDel(ctx, b.hashesKey(), field)
+	_, err := pipe.Exec(ctx)
+	if b.spool != nil {
+		b.spool.Discard(robloxUserID, section)
+	}
+	return err
+}
+
+// FlushWindow estimates how long until an entry added now is flushed,
+// given the current queue depth: one flush interval per batch of entries
+// ahead of it.
+func (b *RedisInventoryBuffer) FlushWindow(ctx context.Context) (time.Duration, int64) {
+	pending, err := b.Count(ctx)
+	if err != nil {
+		pending = 0
+	}
+	cycles := pending/int64(b.watchdog.BatchSize()) + 1
+	return time.Duration(cycles) * b.flushInterval, pending
 }
 
 // Count returns the number of pending items.
 func (b *RedisInventoryBuffer) Count(ctx context.Context) (int64, error) {
-	return b.client.SCard(ctx, b.pendingKey()).Result()
+	return b.client.ZCard(ctx, b.pendingKey()).Result()
 }
 
-// FlushBatch writes up to MaxBatchSize items to the database.
+// FlushBatch writes up to one batch of items to the database: the configured
+// MaxBatchSize, or less while the watchdog finds flushes slow.
 // Returns the number of items flushed and any error.
 func (b *RedisInventoryBuffer) FlushBatch(ctx context.Context) (int, error) {
-	// Get pending user IDs (limited to batch size)
-	userIDs, err := b.client.SRandMemberN(ctx, b.pendingKey(), MaxBatchSize).Result()
-	if err != nil {
-		return 0, err
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+	flushed, err := b.flushBatch(ctx)
+	b.recordFlush(flushed, err)
+	return flushed, err
+}
+
+func (b *RedisInventoryBuffer) flushBatch(ctx context.Context) (int, error) {
+	batchSize := b.watchdog.BatchSize()
+
+	// Spooled entries take up to half the batch so the spool drains even
+	// while Redis stays busy
+	var spooled []SpooledEntry
+	if b.spool != nil && b.spool.Depth() > 0 {
+		spooled = b.spool.Load(batchSize / 2)
+	}
+
+	// Get the longest pending buffer fields (limited to batch size).
+	// A field is the user ID, suffixed with the section for non-default sections.
+	var (
+		userIDs []string
+		err     error
+	)
+	if n := int64(batchSize - len(spooled)); n > 0 {
+		if userIDs, err = b.client.ZRange(ctx, b.pendingKey(), 0, n-1).Result(); err != nil {
+			return 0, err
+		}
 	}
 
-	if len(userIDs) == 0 {
+	if len(userIDs) == 0 && len(spooled) == 0 {
 		return 0, nil
 	}
 
 	// Get total pending for logging
 	totalPending, _ := b.Count(ctx)
 
-	log.Printf("[RedisInventoryBuffer] Flushing %d/%d items (batch limit: %d)",
-		len(userIDs), totalPending, MaxBatchSize)
+	b.logger.InfoContext(ctx, "Flushing", "items", len(userIDs), "pending", totalPending,
+		"spooled", len(spooled), "batch_limit", batchSize)
 
 	// Collect items to flush
-	items := make([]*BufferedInventory, 0, len(userIDs))
+	items := make([]*BufferedInventory, 0, len(userIDs)+len(spooled))
 	originalData := make(map[string]string)
+	byField := make(map[string]int, len(userIDs))
 
-	for _, userID := range userIDs {
-		data, err := b.client.HGet(ctx, b.bufferKey(), userID).Bytes()
-		if err == redis.Nil {
-			// Already deleted, remove from pending set
-			b.client.SRem(ctx, b.pendingKey(), userID)
-			continue
+	// One round trip for the whole batch, however far away Redis is
+	var payloads []interface{}
+	if len(userIDs) > 0 {
+		if payloads, err = b.client.HMGet(ctx, b.bufferKey(), userIDs...).Result(); err != nil {
+			return 0, err
 		}
-		if err != nil {
-			log.Printf("[RedisInventoryBuffer] Error getting %s: %v", userID, err)
+	}
+	var missing []string
+	corrupt := make(map[string]string)
+	for i, userID := range userIDs {
+		data, ok := payloads[i].(string)
+		if !ok {
+			missing = append(missing, userID) // Already deleted
 			continue
 		}
 
-		originalData[userID] = string(data)
-
 		var inv BufferedInventory
-		if err := json.Unmarshal(data, &inv); err != nil {
-			log.Printf("[RedisInventoryBuffer] Error unmarshaling %s: %v", userID, err)
-			// Remove corrupt data
-			b.client.HDel(ctx, b.bufferKey(), userID)
-			b.client.SRem(ctx, b.pendingKey(), userID)
+		if err := json.Unmarshal([]byte(data), &inv); err != nil {
+			b.logger.ErrorContext(ctx, "Dropping corrupt buffered entry", "field", userID, "error", err)
+			corrupt[userID] = data
 			continue
 		}
+		originalData[userID] = data
+		byField[userID] = len(items)
 		items = append(items, &inv)
 	}
+	if len(missing) > 0 || len(corrupt) > 0 {
+		if _, err := b.dropFields(ctx, missing, corrupt); err != nil {
+			b.logger.ErrorContext(ctx, "Failed to drop unflushable fields", "error", err)
+		}
+	}
+
+	items, spooled = b.mergeSpooled(ctx, items, byField, originalData, spooled)
 
 	if len(items) == 0 {
+		if len(spooled) > 0 {
+			b.spool.Remove(spooled) // All superseded by Redis
+		}
 		return 0, nil
 	}
 
 	// Flush to database
-	if err := b.flushFunc(ctx, items); err != nil {
-		log.Printf("[RedisInventoryBuffer] Flush error: %v", err)
-		return 0, err
+	start := time.Now()
+	err = b.flushFunc(ctx, items)
+	b.watchdog.Observe(len(items), time.Since(start), err)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "Flush failed", "items", len(items), "error", err)
+		if len(items) == 1 || ctx.Err() != nil {
+			return 0, err
+		}
+		fields := make([]string, len(items))
+		for field, i := range byField {
+			fields[i] = field
+		}
+		return b.isolateFailures(ctx, items, fields, originalData, spooled, err)
 	}
 
-	// Clear flushed items atomically
-	pipe := b.client.Pipeline()
-	for userID, rawJSON := range originalData {
-		deleteIfUnchangedScript.Run(ctx, pipe, []string{b.bufferKey(), b.pendingKey()}, userID, rawJSON)
+	// Clear flushed items, keeping any rewritten since they were read
+	if _, err := b.ackFlushed(ctx, originalData); err != nil {
+		b.logger.ErrorContext(ctx, "Failed to clear flushed entries", "error", err)
 	}
-	_, err = pipe.Exec(ctx)
-	if err != nil {
-		log.Printf("[RedisInventoryBuffer] Error clearing Redis: %v", err)
+	if len(spooled) > 0 {
+		b.spool.Remove(spooled)
 	}
 
-	log.Printf("[RedisInventoryBuffer] Successfully flushed %d items", len(items))
+	b.logger.InfoContext(ctx, "Flushed", "items", len(items))
 	return len(items), nil
 }
 
-// Flush writes all buffered items to database (for backward compatibility)
-func (b *RedisInventoryBuffer) Flush(ctx context.Context) error {
-	_, err := b.FlushBatch(ctx)
-	return err
+// dropFields removes, in one atomic call, pending fields whose entry is
+// gone and corrupt entries (field to payload as read) that can never be
+// flushed. Fields rewritten since they were read are kept. Returns the
+// number of corrupt entries removed.
+func (b *RedisInventoryBuffer) dropFields(ctx context.Context, missing []string, corrupt map[string]string) (int, error) {
+	args := make([]interface{}, 0, 1+len(missing)+2*len(corrupt))
+	args = append(args, len(missing))
+	for _, field := range missing {
+		args = append(args, field)
+	}
+	for field, payload := range corrupt {
+		sum := sha1.Sum([]byte(payload))
+		args = append(args, field, hex.EncodeToString(sum[:]))
+	}
+	return dropFieldsScript.Run(ctx, b.client,
+		[]string{b.bufferKey(), b.pendingKey(), b.hashesKey(), b.failuresKey()}, args...).Int()
 }
 
-// CleanupStale removes inventory data older than StaleDataThreshold.
-// This prevents unbounded memory growth in Redis.
-func (b *RedisInventoryBuffer) CleanupStale(ctx context.Context) (int, error) {
-	userIDs, err := b.client.SMembers(ctx, b.pendingKey()).Result()
+// ackFlushed removes flushed fields from the buffer unless they changed
+// since being read. Each chunk is removed atomically, so a failed call
+// leaves all of its fields buffered to be flushed again - never some of
+// them. Returns the number of fields removed.
+func (b *RedisInventoryBuffer) ackFlushed(ctx context.Context, originalData map[string]string) (int, error) {
+	removed, err := b.removeUnchanged(ctx, originalData)
 	if err != nil {
-		return 0, err
+		return removed, err
+	}
+	if kept := len(originalData) - removed; kept > 0 {
+		b.logger.InfoContext(ctx, "Flushed entries updated meanwhile stay buffered", "items", kept)
 	}
+	return removed, nil
+}
 
-	if len(userIDs) == 0 {
-		return 0, nil
+// removeUnchanged removes fields whose buffered payload is still the one
+// given, chunk by chunk, and returns the number removed.
+func (b *RedisInventoryBuffer) removeUnchanged(ctx context.Context, originalData map[string]string) (int, error) {
+	args := make([]interface{}, 0, 2*min(len(originalData), ackChunkFields))
+	removed := 0
+	ack := func() error {
+		res, err := ackFlushedScript.Run(ctx, b.client, []string{b.bufferKey(), b.pendingKey(), b.hashesKey(), b.failuresKey()}, args...).StringSlice()
+		if err != nil {
+			return err
+		}
+		removed += len(res)
+		args = args[:0]
+		return nil
 	}
 
-	staleThreshold := time.Now().Add(-StaleDataThreshold)
-	staleCount := 0
-	pipe := b.client.Pipeline()
+	for field, payload := range originalData {
+		sum := sha1.Sum([]byte(payload))
+		args = append(args, field, hex.EncodeToString(sum[:]))
+		if len(args) == 2*ackChunkFields {
+			if err := ack(); err != nil {
+				return removed, err
+			}
+		}
+	}
+	if len(args) > 0 {
+		if err := ack(); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
 
-	for _, userID := range userIDs {
-		data, err := b.client.HGet(ctx, b.bufferKey(), userID).Bytes()
-		if err == redis.Nil {
-			pipe.SRem(ctx, b.pendingKey(), userID)
-			continue
+// mergeSpooled adds spooled entries to a batch, keeping the newest copy of
+// each user/section. A spooled entry older than the Redis copy is dropped
+// unflushed; an older Redis copy is cleared along with the batch. Returns
+// the batch and the spooled entries to remove once it is persisted.
+func (b *RedisInventoryBuffer) mergeSpooled(ctx context.Context, items []*BufferedInventory, byField map[string]int, originalData map[string]string, spooled []SpooledEntry) ([]*BufferedInventory, []SpooledEntry) {
+	// Entries not sampled this round are compared with Redis directly, all
+	// read in one round trip
+	var unsampled []string
+	for _, entry := range spooled {
+		if _, ok := byField[entry.field]; !ok {
+			unsampled = append(unsampled, entry.field)
 		}
-		if err != nil {
-			continue
+	}
+	inRedis := make(map[string]string, len(unsampled))
+	readFailed := false
+	if len(unsampled) > 0 {
+		payloads, err := b.client.HMGet(ctx, b.bufferKey(), unsampled...).Result()
+		readFailed = err != nil
+		for i, payload := range payloads {
+			if data, ok := payload.(string); ok {
+				inRedis[unsampled[i]] = data
+			}
 		}
+	}
 
-		var inv BufferedInventory
-		if err := json.Unmarshal(data, &inv); err != nil {
-			// Corrupt data, remove it
-			pipe.HDel(ctx, b.bufferKey(), userID)
-			pipe.SRem(ctx, b.pendingKey(), userID)
-			staleCount++
+	done := spooled[:0]
+	for _, entry := range spooled {
+		if i, ok := byField[entry.field]; ok {
+			if entry.UpdatedAt.After(items[i].UpdatedAt) {
+				items[i] = entry.BufferedInventory
+			}
+			done = append(done, entry)
 			continue
 		}
 
-		// Check if data is stale
-		if inv.UpdatedAt.Before(staleThreshold) {
-			pipe.HDel(ctx, b.bufferKey(), userID)
-			pipe.SRem(ctx, b.pendingKey(), userID)
-			staleCount++
+		if readFailed {
+			continue // Retry next flush
 		}
+		if data, ok := inRedis[entry.field]; ok {
+			var inv BufferedInventory
+			if json.Unmarshal([]byte(data), &inv) == nil && inv.UpdatedAt.After(entry.UpdatedAt) {
+				done = append(done, entry) // Superseded by Redis
+				continue
+			}
+			originalData[entry.field] = data
+		}
+		byField[entry.field] = len(items)
+		items = append(items, entry.BufferedInventory)
+		done = append(done, entry)
 	}
+	return items, done
+}
 
-	if staleCount > 0 {
-		_, err = pipe.Exec(ctx)
-		if err != nil {
-			log.Printf("[RedisInventoryBuffer] Cleanup exec error: %v", err)
-			return 0, err
+// ErrFlushOnHold is returned by Drain while flushing is on hold.
+var ErrFlushOnHold = errors.New("flushing is on hold")
+
+// DrainResult describes a Drain call.
+type DrainResult struct {
+	Flushed    int   `json:"flushed"`
+	Batches    int   `json:"batches"`
+	Remaining  int64 `json:"remaining"`
+	Complete   bool  `json:"complete"` // Nothing was left to flush
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// Drain flushes batch after batch until nothing is left or budget runs
+// out, e.g. before a deploy. Batches are serialized with the background
+// flush, so no entry is flushed by both. Refused with ErrFlushOnHold while
+// flushing is on hold.
+func (b *RedisInventoryBuffer) Drain(ctx context.Context, budget time.Duration) (*DrainResult, error) {
+	if b.onHold() {
+		return nil, ErrFlushOnHold
+	}
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+
+	result := &DrainResult{}
+	var err error
+	for ctx.Err() == nil {
+		var flushed int
+		if flushed, err = b.FlushBatch(ctx); err != nil || flushed == 0 {
+			break
 		}
-		log.Printf("[RedisInventoryBuffer] Cleaned up %d stale items (older than %v)", staleCount, StaleDataThreshold)
+		result.Flushed += flushed
+		result.Batches++
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = nil // Budget spent mid-batch: that batch stays buffered
 	}
 
+	result.DurationMs = time.Since(start).Milliseconds()
+	remaining, countErr := b.Count(context.WithoutCancel(ctx))
+	result.Remaining = remaining
+	result.Complete = countErr == nil && remaining == 0
+	b.logger.InfoContext(ctx, "Drained", "flushed", result.Flushed, "batches", result.Batches,
+		"remaining", remaining, "duration_ms", result.DurationMs)
+	return result, err
+}
+
+// Flush writes all buffered items to database (for backward compatibility)
+func (b *RedisInventoryBuffer) Flush(ctx context.Context) error {
+	_, err := b.FlushBatch(ctx)
+	return err
+}
+
+// CleanupStale removes inventory data older than the stale threshold.
+// This prevents unbounded memory growth in Redis. An entry is never
+// updated before it became pending, so only fields pending since before
+// the threshold are looked at, a page at a time with one read per page.
+// Entries rewritten since they were read are kept.
+func (b *RedisInventoryBuffer) CleanupStale(ctx context.Context) (int, error) {
+	start := time.Now()
+	staleThreshold := start.Add(-b.staleAfter)
+	maxScore := strconv.FormatFloat(pendingScore(staleThreshold), 'f', -1, 64)
+
+	scanned, staleCount, kept := 0, 0, 0
+	var err error
+	for {
+		var userIDs []string
+		userIDs, err = b.client.ZRangeByScore(ctx, b.pendingKey(), &redis.ZRangeBy{
+			Min: "-inf", Max: maxScore, Offset: int64(kept), Count: cleanupPageSize,
+		}).Result()
+		if err != nil || len(userIDs) == 0 {
+			break
+		}
+		scanned += len(userIDs)
+
+		var payloads []interface{}
+		if payloads, err = b.client.HMGet(ctx, b.bufferKey(), userIDs...).Result(); err != nil {
+			break
+		}
+		var missing []string
+		corrupt := make(map[string]string)
+		stale := make(map[string]string)
+		for i, userID := range userIDs {
+			data, ok := payloads[i].(string)
+			if !ok {
+				missing = append(missing, userID)
+				continue
+			}
+			var inv BufferedInventory
+			if json.Unmarshal([]byte(data), &inv) != nil {
+				corrupt[userID] = data
+				continue
+			}
+			if inv.UpdatedAt.Before(staleThreshold) {
+				stale[userID] = data
+			} else {
+				kept++ // Still in range: skipped by the next page's offset
+			}
+		}
+
+		if len(missing) > 0 || len(corrupt) > 0 {
+			var dropped int
+			if dropped, err = b.dropFields(ctx, missing, corrupt); err != nil {
+				break
+			}
+			staleCount += dropped
+		}
+		removed, rerr := b.removeUnchanged(ctx, stale)
+		staleCount += removed
+		kept += len(stale) - removed
+		if err = rerr; err != nil || len(userIDs) < cleanupPageSize {
+			break
+		}
+	}
+
+	took := time.Since(start)
+	b.recordCleanup(start, took, scanned, staleCount, err)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "Stale cleanup failed", "scanned", scanned, "removed", staleCount, "took", took, "error", err)
+		return staleCount, err
+	}
+	if staleCount > 0 {
+		b.logger.InfoContext(ctx, "Cleaned up stale entries", "items", staleCount, "scanned", scanned,
+			"older_than", b.staleAfter, "took", took)
+	}
 	return staleCount, nil
 }
 
 // backgroundFlush runs the periodic flush to database.
 func (b *RedisInventoryBuffer) backgroundFlush() {
+	defer close(b.flushDone)
 	for {
 		select {
 		case <-b.flushTicker.C:
-			ctx, cancel := context.WithTimeout(context.Background(), FlushTimeout)
-			if _, err := b.FlushBatch(ctx); err != nil {
-				log.Printf("[RedisInventoryBuffer] Background flush error: %v", err)
-			}
-			cancel()
-		case <-b.stopFlush:
-			// Final flush on shutdown - flush ALL remaining items
-			log.Printf("[RedisInventoryBuffer] Shutdown: flushing remaining items...")
-			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-			for {
-				flushed, err := b.FlushBatch(ctx)
-				if err != nil {
-					log.Printf("[RedisInventoryBuffer] Shutdown flush error: %v", err)
-					break
-				}
-				if flushed == 0 {
-					break
+			lifecycle.Touch("buffer.flush")
+			b.refreshPendingBytes(context.Background())
+			b.refreshPendingCount(context.Background())
+			if held := b.onHold(); held != b.held {
+				b.held = held
+				if held {
+					b.logger.Warn("Flushing on hold - buffered data is kept until resumed")
+				} else {
+					b.logger.Info("Flushing resumed")
 				}
 			}
-			cancel()
-			log.Printf("[RedisInventoryBuffer] Shutdown flush complete")
-			return
+			if b.held {
+				continue
+			}
+			b.flushWithRetry()
+			b.refreshPendingCount(context.Background())
+		case <-b.stopFlush:
+			return // Shutdown runs the final flush
 		}
 	}
 }
 
 // backgroundCleanup runs periodic stale data cleanup.
 func (b *RedisInventoryBuffer) backgroundCleanup() {
+	defer close(b.cleanupDone)
 	for {
 		select {
 		case <-b.cleanupTicker.C:
+			lifecycle.Touch("buffer.cleanup")
+			if b.onHold() {
+				continue // Held data must outlive the stale threshold
+			}
 			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			b.migratePending(ctx)
 			b.CleanupStale(ctx)
+			b.refreshPendingCount(ctx)
 			cancel()
 		case <-b.stopFlush:
 			return
@@ -340,12 +960,69 @@ func (b *RedisInventoryBuffer) backgroundCleanup() {
 	}
 }
 
-// Close stops the buffer and performs a final flush.
-func (b *RedisInventoryBuffer) Close() error {
-	b.stopOnce.Do(func() {
+// Ping checks Redis answers.
+func (b *RedisInventoryBuffer) Ping(ctx context.Context) error {
+	return b.client.Ping(ctx).Err()
+}
+
+// Shutdown stops the background flush and cleanup, waits for them to
+// return, then flushes everything still buffered under ctx before closing
+// the Redis client. Entries left when ctx is done, or while flushing is on
+// hold, stay in Redis for the next start. Later calls return the first
+// call's result.
+func (b *RedisInventoryBuffer) Shutdown(ctx context.Context) error {
+	b.shutdownOnce.Do(func() {
 		b.flushTicker.Stop()
 		b.cleanupTicker.Stop()
 		close(b.stopFlush)
+
+		var err error
+		for _, done := range []chan struct{}{b.flushDone, b.cleanupDone} {
+			select {
+			case <-done:
+			case <-ctx.Done():
+				err = fmt.Errorf("waiting for background flush: %w", ctx.Err())
+			}
+			if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = b.finalFlush(ctx)
+		}
+		b.shutdownErr = errors.Join(err, b.client.Close())
 	})
-	return b.client.Close()
+	return b.shutdownErr
+}
+
+// finalFlush flushes batch after batch until nothing is left, ctx is done
+// or a flush fails.
+func (b *RedisInventoryBuffer) finalFlush(ctx context.Context) error {
+	if b.onHold() {
+		b.logger.Warn("Shutdown: flushing on hold - buffered data is kept in Redis")
+		return nil
+	}
+	b.logger.Info("Shutdown: flushing remaining items")
+	total := 0
+	for {
+		flushed, err := b.FlushBatch(ctx)
+		total += flushed
+		if err != nil {
+			remaining, _ := b.Count(context.WithoutCancel(ctx))
+			b.logger.Error("Shutdown flush failed", "flushed", total, "remaining", remaining, "error", err)
+			return fmt.Errorf("final flush: %w (%d entries left buffered)", err, remaining)
+		}
+		if flushed == 0 {
+			break
+		}
+	}
+	b.logger.Info("Shutdown flush complete", "flushed", total)
+	return nil
+}
+
+// Close shuts the buffer down, allowing the final flush FlushTimeout.
+func (b *RedisInventoryBuffer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), b.flushTimeout)
+	defer cancel()
+	return b.Shutdown(ctx)
 }
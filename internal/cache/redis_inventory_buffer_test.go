@@ -0,0 +1,307 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"vinzhub-rest-api/internal/lifecycle"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisBufferConfigDefaults(t *testing.T) {
+	got := RedisBufferConfig{}.withDefaults()
+	if got.KeyPrefix != "vinzhub:fishit:inventory" || got.FlushInterval != 30*time.Second ||
+		got.MaxBatchSize != MaxBatchSize || got.Watchdog.MaxBatch != MaxBatchSize ||
+		got.FlushTimeout != FlushTimeout || got.StaleDataThreshold != StaleDataThreshold ||
+		got.CleanupInterval != CleanupInterval || got.FlushAlertAfter != FlushAlertAfter {
+		t.Errorf("defaults = %+v", got)
+	}
+
+	// Short intervals for tests are kept as given
+	set := RedisBufferConfig{
+		KeyPrefix:          "test:inventory",
+		FlushInterval:      10 * time.Millisecond,
+		MaxBatchSize:       20,
+		FlushTimeout:       time.Second,
+		StaleDataThreshold: time.Minute,
+		CleanupInterval:    50 * time.Millisecond,
+		FlushAlertAfter:    2,
+		Watchdog:           FlushWatchdogConfig{MaxBatch: 10},
+	}
+	if got := set.withDefaults(); got != set {
+		t.Errorf("withDefaults changed set values: %+v, want %+v", got, set)
+	}
+
+	// The watchdog never starts above the configured batch
+	capped := RedisBufferConfig{MaxBatchSize: 100, Watchdog: FlushWatchdogConfig{MaxBatch: 400}}.withDefaults()
+	if capped.Watchdog.MaxBatch != 100 {
+		t.Errorf("watchdog max batch = %d, want 100", capped.Watchdog.MaxBatch)
+	}
+}
+
+// testRedisClient connects to the Redis server named by REDIS_TEST_ADDR,
+// skipping the test when it is unset.
+func testRedisClient(t testing.TB) *redis.Client {
+	t.Helper()
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_TEST_ADDR not set")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("test Redis at %s: %v", addr, err)
+	}
+	return client
+}
+
+// newTestRedisBuffer returns a buffer under a key prefix of its own on the
+// test Redis, and a client to inspect it with. Background flushes and
+// cleanups are left to the test; the keys are deleted afterwards.
+func newTestRedisBuffer(t testing.TB, cfg RedisBufferConfig, flush FlushFunc) (*RedisInventoryBuffer, *redis.Client) {
+	t.Helper()
+	client := testRedisClient(t)
+	cfg.Addr = client.Options().Addr
+	cfg.KeyPrefix = fmt.Sprintf("vinzhub:test:%s:%d", t.Name(), time.Now().UnixNano())
+	cfg.FlushInterval = time.Hour
+	cfg.CleanupInterval = time.Hour
+	cfg.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	b, err := NewRedisInventoryBuffer(cfg, flush)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		b.Close()
+		ctx := context.Background()
+		if keys, err := client.Keys(ctx, cfg.KeyPrefix+":*").Result(); err == nil && len(keys) > 0 {
+			client.Del(ctx, keys...)
+		}
+	})
+	return b, client
+}
+
+// flushStore stands in for the database: it keeps flushed entries by
+// buffer field until closed, and fails flushes afterwards.
+type flushStore struct {
+	delay time.Duration // Per flush, cut short by the flush's context
+
+	mu     sync.Mutex
+	saved  map[string]string
+	closed bool
+}
+
+func (s *flushStore) flush(ctx context.Context, items []*BufferedInventory) error {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("database is closed")
+	}
+	if s.saved == nil {
+		s.saved = make(map[string]string)
+	}
+	for _, inv := range items {
+		s.saved[BufferField(inv.RobloxUserID, inv.Section)] = string(inv.RawJSON)
+	}
+	return nil
+}
+
+func (s *flushStore) close(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *flushStore) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.saved)
+}
+
+// addUsers buffers one entry each for users 1 to n.
+func addUsers(t *testing.T, b *RedisInventoryBuffer, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		id := strconv.Itoa(i)
+		if err := b.Add(context.Background(), 1, id, []byte(`{"user":`+id+`}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRedisBufferShutdownFlushesBeforeStorageCloses(t *testing.T) {
+	store := &flushStore{delay: time.Millisecond}
+	b, client := newTestRedisBuffer(t, RedisBufferConfig{MaxBatchSize: 100}, store.flush)
+	addUsers(t, b, 250)
+
+	// The flush and storage phases as main wires them
+	var s lifecycle.Shutdown
+	s.Add(lifecycle.PhaseFlush, "redis_buffer", 10*time.Second, b.Shutdown)
+	s.Add(lifecycle.PhaseStorage, "sqlite", 0, store.close)
+	if err := s.Run(); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	if n := store.count(); n != 250 {
+		t.Errorf("%d entries persisted, want all 250", n)
+	}
+	if n := client.ZCard(context.Background(), b.pendingKey()).Val(); n != 0 {
+		t.Errorf("%d entries still queued", n)
+	}
+	if err := b.Shutdown(context.Background()); err != nil {
+		t.Errorf("second Shutdown = %v, want the first call's result", err)
+	}
+}
+
+func TestRedisBufferShutdownBoundedByContext(t *testing.T) {
+	store := &flushStore{delay: time.Hour}
+	b, client := newTestRedisBuffer(t, RedisBufferConfig{}, store.flush)
+	addUsers(t, b, 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	err := b.Shutdown(ctx)
+	if took := time.Since(started); took > 5*time.Second {
+		t.Errorf("Shutdown took %v, want it cut off by its context", took)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "10 entries left buffered") {
+		t.Errorf("Shutdown = %v, want the deadline and what was left", err)
+	}
+	// Left for the next start
+	if n := client.ZCard(context.Background(), b.pendingKey()).Val(); n != 10 {
+		t.Errorf("%d entries queued after the cut-off flush, want 10", n)
+	}
+}
+
+func TestRedisBufferShutdownKeepsHeldEntries(t *testing.T) {
+	store := &flushStore{}
+	b, client := newTestRedisBuffer(t, RedisBufferConfig{}, store.flush)
+	b.SetHoldFunc(func() bool { return true })
+	addUsers(t, b, 3)
+
+	if err := b.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if n := store.count(); n != 0 {
+		t.Errorf("%d entries flushed while on hold", n)
+	}
+	if n := client.ZCard(context.Background(), b.pendingKey()).Val(); n != 3 {
+		t.Errorf("%d entries queued, want the 3 held ones", n)
+	}
+}
+
+// queueRaw buffers payload for field as is, bypassing Add; a nil payload
+// only queues the field.
+func queueRaw(t *testing.T, b *RedisInventoryBuffer, client *redis.Client, field string, payload []byte) {
+	t.Helper()
+	ctx := context.Background()
+	pipe := client.TxPipeline()
+	if payload != nil {
+		pipe.HSet(ctx, b.bufferKey(), field, payload)
+		pipe.HSet(ctx, b.hashesKey(), field, "fingerprint")
+		pipe.HSet(ctx, b.failuresKey(), field, 2)
+	}
+	pipe.ZAdd(ctx, b.pendingKey(), redis.Z{Score: pendingScore(time.Now()), Member: field})
+	if _, err := pipe.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedisBufferFlushBatchMixedFields(t *testing.T) {
+	ctx := context.Background()
+	store := &flushStore{}
+	b, client := newTestRedisBuffer(t, RedisBufferConfig{}, store.flush)
+	addUsers(t, b, 3)
+	queueRaw(t, b, client, "missing", nil)
+	queueRaw(t, b, client, "corrupt", []byte(`{"roblox_user_id":`))
+
+	flushed, err := b.FlushBatch(ctx)
+	if err != nil || flushed != 3 {
+		t.Fatalf("FlushBatch = %d, %v; want the 3 present entries", flushed, err)
+	}
+	if store.count() != 3 || store.saved["2"] != `{"user":2}` {
+		t.Errorf("persisted %v, want users 1 to 3", store.saved)
+	}
+	if n := client.ZCard(ctx, b.pendingKey()).Val(); n != 0 {
+		t.Errorf("%d fields still queued, want the missing and corrupt ones dropped too", n)
+	}
+	for _, key := range []string{b.bufferKey(), b.hashesKey(), b.failuresKey()} {
+		if client.HExists(ctx, key, "corrupt").Val() {
+			t.Errorf("corrupt entry left in %s", key)
+		}
+	}
+}
+
+func TestRedisBufferDropFieldsKeepsRewrites(t *testing.T) {
+	ctx := context.Background()
+	b, client := newTestRedisBuffer(t, RedisBufferConfig{}, (&flushStore{}).flush)
+	corruptPayload := `{"roblox_user_id":`
+	queueRaw(t, b, client, "gone", nil)
+	queueRaw(t, b, client, "bad", []byte(corruptPayload))
+	// Read as missing and corrupt, then rewritten by syncs before the drop
+	queueRaw(t, b, client, "resynced", []byte(`{"user":1}`))
+	queueRaw(t, b, client, "fixed", []byte(`{"user":2}`))
+
+	dropped, err := b.dropFields(ctx, []string{"gone", "resynced"},
+		map[string]string{"bad": corruptPayload, "fixed": corruptPayload})
+	if err != nil || dropped != 1 {
+		t.Fatalf("dropFields = %d, %v; want only the still-corrupt entry dropped", dropped, err)
+	}
+	for field, want := range map[string]bool{"gone": false, "bad": false, "resynced": true, "fixed": true} {
+		if got := client.ZScore(ctx, b.pendingKey(), field).Err() == nil; got != want {
+			t.Errorf("%s queued = %v, want %v", field, got, want)
+		}
+	}
+	for _, key := range []string{b.bufferKey(), b.hashesKey(), b.failuresKey()} {
+		if !client.HExists(ctx, key, "fixed").Val() {
+			t.Errorf("rewritten entry lost its %s field", key)
+		}
+	}
+}
+
+// BenchmarkFlushBatchReads compares reading a full batch with one HGET per
+// field, as flushes did before, against the single HMGET they use now.
+func BenchmarkFlushBatchReads(b *testing.B) {
+	buf, client := newTestRedisBuffer(b, RedisBufferConfig{}, (&flushStore{}).flush)
+	ctx := context.Background()
+	fields := make([]string, MaxBatchSize)
+	for i := range fields {
+		fields[i] = strconv.Itoa(i + 1)
+		if err := buf.Add(ctx, 1, fields[i], []byte(`{"user":`+fields[i]+`}`)); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("hget_per_field", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, field := range fields {
+				if err := client.HGet(ctx, buf.bufferKey(), field).Err(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("hmget", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := client.HMGet(ctx, buf.bufferKey(), fields...).Err(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
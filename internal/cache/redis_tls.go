@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+)
+
+// RedisTLSConfig turns on TLS for Redis connections.
+type RedisTLSConfig struct {
+	Enabled            bool
+	CACertFile         string // PEM bundle trusted instead of the system roots
+	ServerName         string // SNI and verification name; defaults to the address's host
+	InsecureSkipVerify bool   // Accept any server certificate - development only
+}
+
+// ClientConfig returns the tls.Config for connecting to addr, or nil when
+// TLS is off.
+func (c RedisTLSConfig) ClientConfig(addr string) (*tls.Config, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	serverName := c.ServerName
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		serverName = host
+	}
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         serverName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CACertFile != "" {
+		pem, err := os.ReadFile(c.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("redis CA cert: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("redis CA cert %s: no PEM certificates found", c.CACertFile)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// redisConnectError adds a hint to a failed connection attempt that looks
+// like one side speaking TLS and the other not. A TLS-only server drops a
+// plaintext client without a reply, so that case can only be guessed from
+// the connection closing.
+func redisConnectError(err error, tlsEnabled bool) error {
+	var recordErr tls.RecordHeaderError
+	switch {
+	case tlsEnabled && errors.As(err, &recordErr):
+		return fmt.Errorf("%w (the server does not speak TLS; unset REDIS_TLS)", err)
+	case !tlsEnabled && (errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET)):
+		return fmt.Errorf("%w (connection closed without a reply; if the server requires TLS, set REDIS_TLS=true)", err)
+	}
+	return err
+}
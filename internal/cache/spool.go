@@ -0,0 +1,354 @@
+package cache
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vinzhub-rest-api/internal/logging"
+)
+
+const (
+	// spoolFileExt marks complete spool files; partial writes use spoolTempPrefix
+	// and are renamed into place, so a crash never leaves a torn entry
+	spoolFileExt    = ".json"
+	spoolTempPrefix = ".tmp-"
+
+	// spoolAlertInterval rate-limits spill alerts.
+	spoolAlertInterval = time.Minute
+)
+
+// ErrSpoolFull is returned when the spool has reached its size limit.
+var ErrSpoolFull = errors.New("buffer spool is full")
+
+// DiskSpool keeps buffer entries on local disk while Redis can't take them
+// (out of memory, or over the pending byte budget). It holds one file per
+// user/section - a newer write replaces the older one, like the Redis hash -
+// and is drained into the database by the flush loop and at startup.
+// Entries are read from disk on demand; only an index is kept in memory.
+type DiskSpool struct {
+	dir      string
+	maxBytes int64
+	logger   *slog.Logger
+
+	mu      sync.Mutex
+	files   map[string]spoolFile // Buffer field -> file
+	bytes   int64
+	version uint64
+
+	spilled     atomic.Int64
+	drained     atomic.Int64
+	superseded  atomic.Int64
+	rejected    atomic.Int64
+	writeErrors atomic.Int64
+	lastSpill   atomic.Int64 // Unix nanos
+	spillAlert  atomic.Int64 // Unix nanos of the last alert, per kind
+	failAlert   atomic.Int64
+}
+
+// spoolFile is the index entry of one spooled field.
+type spoolFile struct {
+	size    int64
+	updated time.Time // Entry's UpdatedAt (file mtime for entries found at startup)
+	version uint64    // Bumped on every write, so a drain never removes a newer copy
+}
+
+// SpooledEntry is an entry loaded from the spool for flushing.
+type SpooledEntry struct {
+	*BufferedInventory
+	field   string
+	version uint64
+}
+
+// NewDiskSpool opens (creating if needed) a spool directory, dropping
+// partial writes and indexing the entries left by a previous run.
+// maxBytes <= 0 means unlimited.
+func NewDiskSpool(dir string, maxBytes int64) (*DiskSpool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	s := &DiskSpool{dir: dir, maxBytes: maxBytes, logger: logging.Component("DiskSpool"), files: make(map[string]spoolFile)}
+	for _, e := range dirEntries {
+		name := e.Name()
+		if strings.HasPrefix(name, spoolTempPrefix) {
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		field, ok := spoolField(name)
+		if !ok || e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		s.version++
+		s.files[field] = spoolFile{size: info.Size(), updated: info.ModTime(), version: s.version}
+		s.bytes += info.Size()
+	}
+	if len(s.files) > 0 {
+		s.logger.Info("Found spooled entries", "entries", len(s.files), "bytes", s.bytes, "dir", dir)
+	}
+	return s, nil
+}
+
+// SetLogger replaces the spool's logger.
+func (s *DiskSpool) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// spoolFileName returns the file name of a buffer field. Fields contain
+// user-supplied IDs, so they are encoded rather than used as paths.
+func spoolFileName(field string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(field)) + spoolFileExt
+}
+
+// spoolField reverses spoolFileName.
+func spoolField(name string) (string, bool) {
+	encoded, ok := strings.CutSuffix(name, spoolFileExt)
+	if !ok {
+		return "", false
+	}
+	field, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", false
+	}
+	return string(field), true
+}
+
+// Write spools an entry, replacing an older copy of the same user/section.
+func (s *DiskSpool) Write(ctx context.Context, entry *BufferedInventory, reason string) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	field := BufferField(entry.RobloxUserID, entry.Section)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.files[field].size
+	if s.maxBytes > 0 && s.bytes-previous+int64(len(data)) > s.maxBytes {
+		s.rejected.Add(1)
+		s.alert(ctx, &s.failAlert, "Spool is full - rejecting writes", "bytes", s.bytes, "limit", s.maxBytes)
+		return ErrSpoolFull
+	}
+
+	if err := s.writeFile(spoolFileName(field), data); err != nil {
+		s.writeErrors.Add(1)
+		s.alert(ctx, &s.failAlert, "Failed to spool entry", "field", field, "error", err)
+		return fmt.Errorf("failed to spool entry: %w", err)
+	}
+
+	s.version++
+	s.files[field] = spoolFile{size: int64(len(data)), updated: entry.UpdatedAt, version: s.version}
+	s.bytes += int64(len(data)) - previous
+	s.spilled.Add(1)
+	s.lastSpill.Store(time.Now().UnixNano())
+	s.alert(ctx, &s.spillAlert, "Redis buffer under pressure - spooling writes to disk",
+		"reason", reason, "dir", s.dir, "entries", len(s.files), "bytes", s.bytes)
+	return nil
+}
+
+// writeFile writes data atomically: to a temp file that is synced and
+// then renamed over the target.
+func (s *DiskSpool) writeFile(name string, data []byte) error {
+	tmp, err := os.CreateTemp(s.dir, spoolTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, name))
+}
+
+// alert logs an alert, at most once per spoolAlertInterval per kind.
+func (s *DiskSpool) alert(ctx context.Context, lastAt *atomic.Int64, msg string, args ...interface{}) {
+	now := time.Now().UnixNano()
+	last := lastAt.Load()
+	if now-last < int64(spoolAlertInterval) || !lastAt.CompareAndSwap(last, now) {
+		return
+	}
+	s.logger.ErrorContext(ctx, "ALERT: "+msg, args...)
+}
+
+// Depth returns the number of spooled entries.
+func (s *DiskSpool) Depth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.files)
+}
+
+// Get returns the spooled copy of a user's section, or nil.
+func (s *DiskSpool) Get(robloxUserID, section string) (*BufferedInventory, error) {
+	field := BufferField(robloxUserID, section)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.files[field]; !ok {
+		return nil, nil
+	}
+	return s.readFile(field)
+}
+
+// readFile reads one spooled entry. Callers hold s.mu.
+func (s *DiskSpool) readFile(field string) (*BufferedInventory, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, spoolFileName(field)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spooled entry: %w", err)
+	}
+	var inv BufferedInventory
+	if err := json.Unmarshal(data, &inv); err != nil {
+		return nil, fmt.Errorf("failed to decode spooled entry: %w", err)
+	}
+	return &inv, nil
+}
+
+// Load returns up to limit spooled entries, oldest first. Unreadable
+// entries are dropped.
+func (s *DiskSpool) Load(limit int) []SpooledEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fields := make([]string, 0, len(s.files))
+	for field := range s.files {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return s.files[fields[i]].updated.Before(s.files[fields[j]].updated)
+	})
+
+	entries := make([]SpooledEntry, 0, min(limit, len(fields)))
+	for _, field := range fields {
+		if len(entries) >= limit {
+			break
+		}
+		inv, err := s.readFile(field)
+		if err != nil {
+			s.logger.Warn("Dropping unreadable entry", "field", field, "error", err)
+			s.removeLocked(field)
+			continue
+		}
+		entries = append(entries, SpooledEntry{BufferedInventory: inv, field: field, version: s.files[field].version})
+	}
+	return entries
+}
+
+// Remove deletes flushed entries, keeping any that were rewritten since
+// they were loaded.
+func (s *DiskSpool) Remove(entries []SpooledEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range entries {
+		if f, ok := s.files[e.field]; ok && f.version == e.version {
+			s.removeLocked(e.field)
+			s.drained.Add(1)
+		}
+	}
+}
+
+// Discard drops the spooled copy of a user's section, e.g. once a newer
+// copy reached Redis or the database. Reports whether one was spooled.
+func (s *DiskSpool) Discard(robloxUserID, section string) bool {
+	field := BufferField(robloxUserID, section)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.files[field]; ok {
+		s.removeLocked(field)
+		s.superseded.Add(1)
+		return true
+	}
+	return false
+}
+
+// Clear drops every spooled entry and returns how many there were.
+func (s *DiskSpool) Clear() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.files)
+	for field := range s.files {
+		s.removeLocked(field)
+	}
+	return n
+}
+
+// removeLocked deletes a spooled file. Callers hold s.mu.
+func (s *DiskSpool) removeLocked(field string) {
+	if err := os.Remove(filepath.Join(s.dir, spoolFileName(field))); err != nil && !os.IsNotExist(err) {
+		s.logger.Error("Failed to remove spooled entry", "field", field, "error", err)
+	}
+	s.bytes -= s.files[field].size
+	delete(s.files, field)
+}
+
+// Drain flushes every spooled entry straight through flush, in batches.
+// Used at startup when no Redis buffer is running to merge with.
+func (s *DiskSpool) Drain(ctx context.Context, flush FlushFunc) (int, error) {
+	drained := 0
+	for {
+		entries := s.Load(MaxBatchSize)
+		if len(entries) == 0 {
+			return drained, nil
+		}
+		items := make([]*BufferedInventory, len(entries))
+		for i, e := range entries {
+			items[i] = e.BufferedInventory
+		}
+		if err := flush(ctx, items); err != nil {
+			return drained, err
+		}
+		s.Remove(entries)
+		drained += len(entries)
+	}
+}
+
+// Stats returns spool depth and counters for admin stats.
+func (s *DiskSpool) Stats(ctx context.Context) map[string]interface{} {
+	s.mu.Lock()
+	depth, bytes := len(s.files), s.bytes
+	s.mu.Unlock()
+
+	stats := map[string]interface{}{
+		"dir":          s.dir,
+		"depth":        depth,
+		"bytes":        bytes,
+		"max_bytes":    s.maxBytes,
+		"spilled":      s.spilled.Load(),
+		"drained":      s.drained.Load(),
+		"superseded":   s.superseded.Load(),
+		"rejected":     s.rejected.Load(),
+		"write_errors": s.writeErrors.Load(),
+		"alert":        depth > 0, // Spooled data means Redis is or was under pressure
+	}
+	if last := s.lastSpill.Load(); last > 0 {
+		stats["last_spill_at"] = time.Unix(0, last).UTC()
+	}
+	return stats
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ThrottleKeyPrefix namespaces throttle marks in Redis.
+const ThrottleKeyPrefix = "vinzhub:throttle:"
+
+// Throttle admits one action per key per interval.
+type Throttle interface {
+	// Allow records an action for key unless one was recorded less than
+	// interval ago, in which case it returns false and how long until the
+	// next action is allowed.
+	Allow(ctx context.Context, key string, interval time.Duration) (bool, time.Duration, error)
+}
+
+// RedisThrottle keeps throttle marks in Redis with native TTLs, shared by
+// every instance.
+type RedisThrottle struct {
+	redis *redis.Client
+}
+
+// NewRedisThrottle creates a Redis-backed throttle.
+func NewRedisThrottle(redisClient *redis.Client) *RedisThrottle {
+	return &RedisThrottle{redis: redisClient}
+}
+
+// Allow sets a mark expiring after interval only if none is set.
+func (t *RedisThrottle) Allow(ctx context.Context, key string, interval time.Duration) (bool, time.Duration, error) {
+	ok, err := t.redis.SetNX(ctx, ThrottleKeyPrefix+key, 1, interval).Result()
+	if err != nil || ok {
+		return ok || err != nil, 0, err
+	}
+	wait, err := t.redis.PTTL(ctx, ThrottleKeyPrefix+key).Result()
+	if err != nil || wait < 0 {
+		return false, interval, err
+	}
+	return false, wait, nil
+}
+
+// MemoryThrottle keeps throttle marks in process memory. Marks aren't
+// shared between instances - meant for demo and single-instance runs.
+type MemoryThrottle struct {
+	mu        sync.Mutex
+	marks     map[string]time.Time // Key -> when the next action is allowed
+	lastSweep time.Time
+}
+
+// NewMemoryThrottle creates an in-memory throttle.
+func NewMemoryThrottle() *MemoryThrottle {
+	return &MemoryThrottle{marks: make(map[string]time.Time)}
+}
+
+// Allow sets a mark unless an unexpired one is set. Expired marks are
+// swept at most once a minute.
+func (t *MemoryThrottle) Allow(ctx context.Context, key string, interval time.Duration) (bool, time.Duration, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(t.lastSweep) > time.Minute {
+		for k, until := range t.marks {
+			if now.After(until) {
+				delete(t.marks, k)
+			}
+		}
+		t.lastSweep = now
+	}
+
+	if until, ok := t.marks[key]; ok && now.Before(until) {
+		return false, until.Sub(now), nil
+	}
+	t.marks[key] = now.Add(interval)
+	return true, 0, nil
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// unsetEnv removes key for the duration of the test.
+func unsetEnv(t *testing.T, key string) {
+	t.Helper()
+	t.Setenv(key, "") // Restores the original value on cleanup
+	os.Unsetenv(key)
+}
+
+func TestSQLConsoleIsOptIn(t *testing.T) {
+	unsetEnv(t, "SQL_CONSOLE_ENABLED")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Storage.SQLConsoleEnabled {
+		t.Error("SQL console is enabled without SQL_CONSOLE_ENABLED")
+	}
+
+	t.Setenv("SQL_CONSOLE_ENABLED", "true")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.Storage.SQLConsoleEnabled {
+		t.Error("SQL_CONSOLE_ENABLED=true didn't enable the SQL console")
+	}
+}
+
+func TestFingerprints(t *testing.T) {
+	t.Setenv("REDIS_PASSWORD", "first-secret")
+	base, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	before := base.Fingerprints()
+	if again := base.Fingerprints(); again["cache"] != before["cache"] {
+		t.Fatal("fingerprints of the same config differ")
+	}
+
+	// Rotating a secret doesn't show; setting or clearing it does
+	t.Setenv("REDIS_PASSWORD", "second-secret")
+	cfg, _ := Load()
+	if got := cfg.Fingerprints(); got["cache"] != before["cache"] {
+		t.Error("cache fingerprint depends on the secret's value")
+	}
+	t.Setenv("REDIS_PASSWORD", "")
+	cfg, _ = Load()
+	if got := cfg.Fingerprints(); got["cache"] == before["cache"] {
+		t.Error("cache fingerprint ignores whether the password is set")
+	}
+
+	t.Setenv("REDIS_PASSWORD", "first-secret")
+	t.Setenv("SERVER_PORT", "9999")
+	cfg, _ = Load()
+	got := cfg.Fingerprints()
+	if got["server"] == before["server"] {
+		t.Error("server fingerprint ignores the port")
+	}
+	if got["cache"] != before["cache"] || got["storage"] != before["storage"] {
+		t.Error("a server change moved other sections' fingerprints")
+	}
+}
+
+func TestBufferConfig(t *testing.T) {
+	for _, key := range []string{"REDIS_BUFFER_DB", "BUFFER_FLUSH_INTERVAL", "BUFFER_MAX_BATCH", "BUFFER_STALE_THRESHOLD", "REDIS_BUFFER_KEY_PREFIX"} {
+		unsetEnv(t, key)
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	// The defaults are the values main.go used to hardcode
+	if c := cfg.Cache; c.BufferDB != 1 || c.BufferFlushInterval != 30*time.Second || c.BufferMaxBatch != 500 ||
+		c.BufferStaleThreshold != time.Hour || c.BufferKeyPrefix != "vinzhub:fishit:inventory" {
+		t.Errorf("defaults = db %d, flush %v, batch %d, stale %v, prefix %q",
+			c.BufferDB, c.BufferFlushInterval, c.BufferMaxBatch, c.BufferStaleThreshold, c.BufferKeyPrefix)
+	}
+
+	t.Setenv("REDIS_BUFFER_DB", "4")
+	t.Setenv("BUFFER_FLUSH_INTERVAL", "5s")
+	t.Setenv("BUFFER_MAX_BATCH", "200")
+	t.Setenv("BUFFER_STALE_THRESHOLD", "15m")
+	t.Setenv("REDIS_BUFFER_KEY_PREFIX", "game2:inventory")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if c := cfg.Cache; c.BufferDB != 4 || c.BufferFlushInterval != 5*time.Second || c.BufferMaxBatch != 200 ||
+		c.BufferStaleThreshold != 15*time.Minute || c.BufferKeyPrefix != "game2:inventory" {
+		t.Errorf("configured = db %d, flush %v, batch %d, stale %v, prefix %q",
+			c.BufferDB, c.BufferFlushInterval, c.BufferMaxBatch, c.BufferStaleThreshold, c.BufferKeyPrefix)
+	}
+}
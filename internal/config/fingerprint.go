@@ -0,0 +1,43 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Fingerprints returns a short hash of each config section, keyed by the
+// lowercased section name, so instances can be compared and a config change
+// spotted without printing the config. Fields tagged secret:"true" only
+// contribute whether they are set.
+func (c *Config) Fingerprints() map[string]string {
+	out := make(map[string]string)
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.Type.Kind() != reflect.Struct {
+			continue
+		}
+		out[strings.ToLower(field.Name)] = fingerprint(v.Field(i))
+	}
+	return out
+}
+
+// fingerprint hashes the fields of one section.
+func fingerprint(section reflect.Value) string {
+	values := make(map[string]interface{}, section.NumField())
+	for i := 0; i < section.NumField(); i++ {
+		field := section.Type().Field(i)
+		value := section.Field(i)
+		if field.Tag.Get("secret") == "true" {
+			values[field.Name] = value.Len() > 0 // Secrets are strings or lists
+			continue
+		}
+		values[field.Name] = value.Interface()
+	}
+	data, _ := json.Marshal(values) // Map keys are sorted
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
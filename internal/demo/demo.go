@@ -0,0 +1,71 @@
+// Package demo seeds a small self-contained dataset so the API can run
+// without MySQL or Redis (APP_ENV=demo or --bootstrap-demo).
+package demo
+
+import (
+	"context"
+	"fmt"
+
+	"vinzhub-rest-api/internal/repository"
+)
+
+// DataDir is where demo mode keeps its databases, away from real data.
+const DataDir = "./data/demo"
+
+// Account is a seeded key account.
+type Account struct {
+	KeyAccountID   int64
+	Key            string
+	RobloxUserID   string
+	RobloxUsername string
+	HWID           string
+}
+
+// Accounts are the fake key accounts created by Seed.
+var Accounts = []Account{
+	{Key: "DEMO-KEY-0001", RobloxUserID: "100000001", RobloxUsername: "demo_angler", HWID: "DEMO-HWID-0001"},
+	{Key: "DEMO-KEY-0002", RobloxUserID: "100000002", RobloxUsername: "demo_trawler", HWID: "DEMO-HWID-0002"},
+	{Key: "DEMO-KEY-0003", RobloxUserID: "100000003", RobloxUsername: "demo_diver", HWID: "DEMO-HWID-0003"},
+}
+
+// sampleSections returns the sample documents stored for a demo user.
+func sampleSections(i int) map[string]string {
+	return map[string]string{
+		"inventory": fmt.Sprintf(`{"Items":[{"Id":"rod_starter","Name":"Starter Rod","Quantity":1},{"Id":"fish_salmon","Name":"Salmon","Quantity":%d},{"Id":"bait_worm","Name":"Worm","Quantity":%d}],"Coins":%d}`,
+			3+i, 20*(i+1), 1500*(i+1)),
+		"settings": `{"AutoFish":true,"Sell":{"Rarity":"Common"}}`,
+		"stats":    fmt.Sprintf(`{"FishCaught":%d,"Level":%d}`, 120*(i+1), 5+i),
+	}
+}
+
+// Seed creates the demo key accounts and sample inventories. It is
+// idempotent: existing accounts are reused and stored sections are kept.
+// Returns the accounts with their key account IDs filled in.
+func Seed(ctx context.Context, keys *repository.SQLiteKeyAccountRepository, store repository.InventoryRepository) ([]Account, error) {
+	seeded := make([]Account, len(Accounts))
+	for i, acc := range Accounts {
+		id, err := keys.GetKeyAccountByRobloxUser(ctx, acc.RobloxUserID)
+		if err != nil {
+			id, err = keys.CreateKeyAccount(ctx, acc.Key, acc.RobloxUserID, acc.RobloxUsername)
+			if err != nil {
+				return nil, fmt.Errorf("failed to seed key account %s: %w", acc.RobloxUserID, err)
+			}
+		}
+		acc.KeyAccountID = id
+
+		for section, data := range sampleSections(i) {
+			existing, _, err := store.GetRawInventorySection(ctx, acc.RobloxUserID, section)
+			if err != nil {
+				return nil, err
+			}
+			if existing != nil {
+				continue
+			}
+			if err := store.UpsertRawInventorySection(ctx, id, acc.RobloxUserID, section, []byte(data), -1); err != nil {
+				return nil, fmt.Errorf("failed to seed inventory %s/%s: %w", acc.RobloxUserID, section, err)
+			}
+		}
+		seeded[i] = acc
+	}
+	return seeded, nil
+}
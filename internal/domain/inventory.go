@@ -14,6 +14,10 @@ type RawInventory struct {
 	CreatedAt     time.Time  `json:"created_at"`
 }
 
+// DefaultSection is the inventory section used when a client doesn't name one.
+// Clients that predate sections only ever read and write this section.
+const DefaultSection = "inventory"
+
 // Common errors
 var (
 	ErrNotFound = &CustomError{Code: "NOT_FOUND", Message: "Resource not found"}
@@ -0,0 +1,175 @@
+// Package lifecycle tracks the process's background goroutines: which
+// component runs how many, when each last did work, and panics recovered
+// from them.
+package lifecycle
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vinzhub-rest-api/internal/logging"
+)
+
+var logger = logging.Component("Lifecycle")
+
+const (
+	// restartDelay is how long a goroutine that panicked waits before it is
+	// restarted, so a panic on every pass doesn't spin.
+	restartDelay = time.Second
+
+	// ceilingAlertInterval rate-limits the goroutine ceiling warning.
+	ceilingAlertInterval = 5 * time.Minute
+)
+
+// component is the registration of one named background component.
+type component struct {
+	running      atomic.Int64
+	started      atomic.Int64
+	panics       atomic.Int64
+	lastActivity atomic.Int64 // Unix nanos
+	lastPanic    atomic.Value // string
+}
+
+var (
+	mu         sync.Mutex
+	components = make(map[string]*component)
+
+	ceiling      atomic.Int64
+	ceilingAlert atomic.Int64 // Unix nanos of the last warning
+)
+
+// register returns the component registered under name, creating it.
+func register(name string) *component {
+	mu.Lock()
+	defer mu.Unlock()
+	c, ok := components[name]
+	if !ok {
+		c = &component{}
+		components[name] = c
+	}
+	return c
+}
+
+// Go runs fn in a background goroutine registered under name. If fn panics,
+// the panic is logged with its stack and fn is started again, so a bug in
+// one pass can't stop a loop (e.g. buffer flushing) for good. fn returning
+// normally ends the goroutine.
+func Go(name string, fn func()) {
+	c := register(name)
+	c.started.Add(1)
+	c.running.Add(1)
+	c.lastActivity.Store(time.Now().UnixNano())
+
+	go func() {
+		defer c.running.Add(-1)
+		for !run(name, c, fn) {
+			time.Sleep(restartDelay)
+		}
+	}()
+}
+
+// run calls fn and reports whether it returned without panicking.
+func run(name string, c *component, fn func()) (ok bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			c.panics.Add(1)
+			c.lastPanic.Store(time.Now().UTC().Format(time.RFC3339) + ": " + fmt.Sprint(p))
+			logger.Error("ALERT: goroutine panicked, restarting", "name", name, "restart_in", restartDelay,
+				"panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+		}
+	}()
+	fn()
+	return true
+}
+
+// Touch records that a component did work, e.g. once per loop pass.
+func Touch(name string) {
+	register(name).lastActivity.Store(time.Now().UnixNano())
+}
+
+// SetGoroutineCeiling sets the total goroutine count above which Check
+// warns. 0 disables the warning.
+func SetGoroutineCeiling(n int) {
+	ceiling.Store(int64(n))
+}
+
+// Check warns when the process runs more goroutines than the ceiling, at
+// most once per ceilingAlertInterval. Returns the current count.
+func Check() int {
+	n := runtime.NumGoroutine()
+	limit := ceiling.Load()
+	if limit <= 0 || int64(n) <= limit {
+		return n
+	}
+	now := time.Now().UnixNano()
+	last := ceilingAlert.Load()
+	if now-last >= int64(ceilingAlertInterval) && ceilingAlert.CompareAndSwap(last, now) {
+		logger.Warn("Goroutines above the ceiling - check GET /api/v1/admin/goroutines for leaks", "goroutines", n, "ceiling", limit)
+	}
+	return n
+}
+
+// Monitor checks the goroutine ceiling every interval until stop is closed.
+func Monitor(interval time.Duration, stop <-chan struct{}) {
+	Go("lifecycle.monitor", func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				Check()
+				Touch("lifecycle.monitor")
+			case <-stop:
+				return
+			}
+		}
+	})
+}
+
+// Stats returns each registered component and the process goroutine count,
+// for the admin API.
+func Stats() map[string]interface{} {
+	mu.Lock()
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	mu.Unlock()
+	sort.Strings(names)
+
+	tracked := int64(0)
+	list := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		c := register(name)
+		running := c.running.Load()
+		tracked += running
+		entry := map[string]interface{}{
+			"name":       name,
+			"goroutines": running,
+			"started":    c.started.Load(),
+			"panics":     c.panics.Load(),
+		}
+		if last := c.lastActivity.Load(); last > 0 {
+			entry["last_activity_at"] = time.Unix(0, last).UTC()
+		}
+		if p, ok := c.lastPanic.Load().(string); ok {
+			entry["last_panic"] = p
+		}
+		list = append(list, entry)
+	}
+
+	total := runtime.NumGoroutine()
+	limit := ceiling.Load()
+	return map[string]interface{}{
+		"total":         total,
+		"tracked":       tracked,
+		"ceiling":       limit,
+		"above_ceiling": limit > 0 && int64(total) > limit,
+		"components":    list,
+	}
+}
@@ -0,0 +1,138 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects the names of the stages that ran, in order.
+type recorder struct {
+	mu  sync.Mutex
+	ran []string
+}
+
+func (r *recorder) stage(name string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.ran = append(r.ran, name)
+		return nil
+	}
+}
+
+func (r *recorder) order() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.ran, ",")
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+func TestShutdownStageOrder(t *testing.T) {
+	var (
+		s   Shutdown
+		rec recorder
+	)
+	// Registered out of phase order on purpose
+	s.Add(PhaseStorage, "sqlite", 0, rec.stage("sqlite"))
+	s.Add(PhaseFlush, "buffer", 0, rec.stage("buffer"))
+	s.Add(PhaseServer, "http", 0, rec.stage("http"))
+	s.Add(PhaseWorkers, "retention", 0, rec.stage("retention"))
+	s.Add(PhaseWorkers, "consumer", 0, rec.stage("consumer"))
+	s.AddFunc(PhaseStorage, "redis", func() { rec.stage("redis")(context.Background()) })
+	s.AddCloser(PhaseStorage, "mysql", closerFunc(func() error { return rec.stage("mysql")(context.Background()) }))
+
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// Phases in order; within a phase the last registered runs first
+	if got, want := rec.order(), "http,consumer,retention,buffer,mysql,redis,sqlite"; got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+
+	if err := s.Run(); err != nil {
+		t.Errorf("second Run: %v", err)
+	}
+	if got := len(rec.ran); got != 7 {
+		t.Errorf("second Run ran stages again: %d stages ran in total", got)
+	}
+}
+
+func TestShutdownStageTimeout(t *testing.T) {
+	var (
+		s   Shutdown
+		rec recorder
+	)
+	release := make(chan struct{})
+	defer close(release)
+	s.Add(PhaseFlush, "stuck", 20*time.Millisecond, func(ctx context.Context) error {
+		<-release // Ignores its context
+		return nil
+	})
+	sawDeadline := make(chan bool, 1)
+	s.Add(PhaseWorkers, "cooperative", 20*time.Millisecond, func(ctx context.Context) error {
+		deadline, ok := ctx.Deadline()
+		sawDeadline <- ok && time.Until(deadline) <= 20*time.Millisecond
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	s.Add(PhaseStorage, "after", 0, rec.stage("after"))
+
+	started := time.Now()
+	err := s.Run()
+	if took := time.Since(started); took > time.Second {
+		t.Errorf("Run took %v, want the stages cut off at their timeouts", took)
+	}
+	if !<-sawDeadline {
+		t.Error("stage context has no deadline within its timeout")
+	}
+	if err == nil || !strings.Contains(err.Error(), "stuck: timed out after 20ms") {
+		t.Errorf("Run err = %v, want the stuck stage's timeout", err)
+	}
+	// Returning ctx.Err() and the timeout race; either way the stage failed
+	if err == nil || !strings.Contains(err.Error(), "cooperative: ") {
+		t.Errorf("Run err = %v, want the cooperative stage's failure", err)
+	}
+	if rec.order() != "after" {
+		t.Error("stage after the timed-out ones did not run")
+	}
+}
+
+func TestShutdownFailedStageDoesNotStopNext(t *testing.T) {
+	var (
+		s   Shutdown
+		rec recorder
+	)
+	boom := errors.New("boom")
+	s.Add(PhaseWorkers, "last", 0, rec.stage("last"))
+	s.Add(PhaseWorkers, "panics", 0, func(context.Context) error { panic("bad close") })
+	s.Add(PhaseWorkers, "fails", 0, func(context.Context) error { return boom })
+	s.Add(PhaseServer, "first", 0, rec.stage("first"))
+
+	err := s.Run()
+	if !errors.Is(err, boom) {
+		t.Errorf("Run err = %v, want it to wrap the failed stage's error", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "panics: panic: bad close") {
+		t.Errorf("Run err = %v, want the panicking stage reported", err)
+	}
+	if got, want := rec.order(), "first,last"; got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+}
+
+func TestShutdownAddRejectsUnknownPhase(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Add with an unknown phase did not panic")
+		}
+	}()
+	var s Shutdown
+	s.Add(phaseCount, "bad", 0, func(context.Context) error { return nil })
+}
@@ -0,0 +1,274 @@
+// Package logging configures the process-wide slog logger: a level that can
+// be changed at runtime, deterministic request sampling and line counters.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rateWindow is the window lines_per_second is averaged over.
+const rateWindow = 60
+
+var (
+	level     = new(slog.LevelVar)
+	baseLevel atomic.Int64 // configured level, restored when an override expires
+
+	sampleRate atomic.Int64 // keep 1 in N sampled-out-able requests
+
+	overrideMu    sync.Mutex
+	overrideTimer *time.Timer
+	overrideUntil time.Time
+
+	linesTotal   atomic.Int64
+	linesDropped atomic.Int64
+	buckets      [rateWindow]bucket
+)
+
+// bucket counts lines written during one second.
+type bucket struct {
+	second atomic.Int64
+	count  atomic.Int64
+}
+
+// Options configures Setup.
+type Options struct {
+	Level      slog.Level
+	SampleRate int  // Keep 1 in N successful GET requests; <= 1 keeps all
+	JSON       bool // One JSON object per line (production) instead of text
+}
+
+// Setup installs the default slog logger writing to w. Calls to the standard
+// log package are routed through it at INFO, so they obey the level too.
+func Setup(w io.Writer, opts Options) {
+	level.Set(opts.Level)
+	baseLevel.Store(int64(opts.Level))
+	SetSampleRate(opts.SampleRate)
+
+	handlerOpts := &slog.HandlerOptions{
+		Level:     level,
+		AddSource: true,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// file.go:123 like the old log.Lshortfile output
+			if a.Key == slog.SourceKey {
+				if src, ok := a.Value.Any().(*slog.Source); ok {
+					a.Value = slog.StringValue(fmt.Sprintf("%s:%d", filepath.Base(src.File), src.Line))
+				}
+			}
+			return a
+		},
+	}
+	var handler slog.Handler = slog.NewTextHandler(w, handlerOpts)
+	if opts.JSON {
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	}
+	slog.SetDefault(slog.New(&countingHandler{inner: handler}))
+}
+
+// Component returns the logger for one component, tagged with
+// component=name. It writes through the default logger current at each
+// call, so components may create theirs before Setup runs.
+func Component(name string) *slog.Logger {
+	return slog.New(defaultHandler{}).With("component", name)
+}
+
+// ParseLevel parses debug, info, warn or error.
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (use debug, info, warn or error)", s)
+	}
+	return l, nil
+}
+
+// SetLevel changes the global level. With a positive ttl the configured
+// level is restored automatically once it elapses; a later call replaces
+// any pending reversion.
+func SetLevel(l slog.Level, ttl time.Duration) {
+	overrideMu.Lock()
+	defer overrideMu.Unlock()
+
+	if overrideTimer != nil {
+		overrideTimer.Stop()
+		overrideTimer = nil
+	}
+	overrideUntil = time.Time{}
+	level.Set(l)
+
+	if ttl > 0 {
+		overrideUntil = time.Now().Add(ttl)
+		overrideTimer = time.AfterFunc(ttl, func() {
+			overrideMu.Lock()
+			defer overrideMu.Unlock()
+			level.Set(slog.Level(baseLevel.Load()))
+			overrideTimer = nil
+			overrideUntil = time.Time{}
+			slog.Info(fmt.Sprintf("[Logging] Log level override expired, back to %s", level.Level()))
+		})
+	}
+}
+
+// SetSampleRate sets how many successful GET requests share one logged request.
+func SetSampleRate(n int) {
+	if n < 1 {
+		n = 1
+	}
+	sampleRate.Store(int64(n))
+}
+
+// sampledOutKey marks a request context whose low-level lines are dropped.
+type sampledOutKey struct{}
+
+// requestIDKey holds the request ID added to every line logged with a
+// request's context.
+type requestIDKey struct{}
+
+// WithRequestID tags every line logged with the returned context with
+// request_id.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// WithRequest records the sampling decision for a request. The decision
+// only depends on the request ID, so every line logged with the returned
+// context is kept or dropped together. Writes are never sampled out, and
+// WARN/ERROR lines are always kept.
+func WithRequest(ctx context.Context, requestID string, write bool) context.Context {
+	if write || Sampled(requestID) {
+		return ctx
+	}
+	return context.WithValue(ctx, sampledOutKey{}, true)
+}
+
+// Sampled reports whether a request ID falls in the kept 1-in-N sample.
+func Sampled(requestID string) bool {
+	n := sampleRate.Load()
+	if n <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(requestID))
+	return int64(h.Sum32())%n == 0
+}
+
+// countingHandler counts written lines and applies request sampling.
+type countingHandler struct {
+	inner slog.Handler
+}
+
+func (h *countingHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.inner.Enabled(ctx, l)
+}
+
+func (h *countingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if r.Level < slog.LevelWarn {
+			if out, _ := ctx.Value(sampledOutKey{}).(bool); out {
+				linesDropped.Add(1)
+				return nil
+			}
+		}
+		if id, _ := ctx.Value(requestIDKey{}).(string); id != "" {
+			r.AddAttrs(slog.String("request_id", id))
+		}
+	}
+	countLine(r.Time)
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *countingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &countingHandler{inner: h.inner.WithAttrs(attrs)}
+}
+
+func (h *countingHandler) WithGroup(name string) slog.Handler {
+	return &countingHandler{inner: h.inner.WithGroup(name)}
+}
+
+// defaultHandler forwards to the handler of the default logger current at
+// each call, applying the attributes and groups added to it on the way.
+type defaultHandler struct {
+	with []func(slog.Handler) slog.Handler
+}
+
+func (h defaultHandler) handler() slog.Handler {
+	out := slog.Default().Handler()
+	for _, with := range h.with {
+		out = with(out)
+	}
+	return out
+}
+
+func (h defaultHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return slog.Default().Handler().Enabled(ctx, l)
+}
+
+func (h defaultHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+func (h defaultHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.add(func(inner slog.Handler) slog.Handler { return inner.WithAttrs(attrs) })
+}
+
+func (h defaultHandler) WithGroup(name string) slog.Handler {
+	return h.add(func(inner slog.Handler) slog.Handler { return inner.WithGroup(name) })
+}
+
+func (h defaultHandler) add(with func(slog.Handler) slog.Handler) defaultHandler {
+	return defaultHandler{with: append(h.with[:len(h.with):len(h.with)], with)}
+}
+
+// countLine adds a line to the total and to its one-second bucket.
+func countLine(t time.Time) {
+	linesTotal.Add(1)
+	if t.IsZero() {
+		t = time.Now()
+	}
+	sec := t.Unix()
+	b := &buckets[sec%rateWindow]
+	if b.second.Swap(sec) != sec {
+		b.count.Store(0)
+	}
+	b.count.Add(1)
+}
+
+// linesPerSecond averages the line rate over the last rateWindow seconds.
+func linesPerSecond() float64 {
+	now := time.Now().Unix()
+	var total int64
+	for i := range buckets {
+		if now-buckets[i].second.Load() < rateWindow {
+			total += buckets[i].count.Load()
+		}
+	}
+	return float64(total) / rateWindow
+}
+
+// Stats returns the logging state for admin stats.
+func Stats() map[string]interface{} {
+	stats := map[string]interface{}{
+		"level":             level.Level().String(),
+		"base_level":        slog.Level(baseLevel.Load()).String(),
+		"sample_rate":       sampleRate.Load(),
+		"lines_total":       linesTotal.Load(),
+		"lines_sampled_out": linesDropped.Load(),
+		"lines_per_second":  linesPerSecond(),
+	}
+	overrideMu.Lock()
+	if !overrideUntil.IsZero() {
+		stats["override_until"] = overrideUntil.UTC()
+	}
+	overrideMu.Unlock()
+	return stats
+}
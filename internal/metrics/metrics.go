@@ -0,0 +1,155 @@
+// Package metrics keeps process counters and serves them in the Prometheus
+// text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// collector is a metric that can render itself.
+type collector interface {
+	write(w io.Writer)
+}
+
+// registry holds every metric created by this package, in creation order.
+var (
+	registryMu sync.Mutex
+	registry   []collector
+)
+
+// CounterVec is a set of monotonically increasing counters keyed by label values.
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.RWMutex
+	values map[string]*atomic.Int64 // keyed by label values joined with \xff
+}
+
+// NewCounterVec creates and registers a counter vector.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]*atomic.Int64),
+	}
+	registryMu.Lock()
+	registry = append(registry, c)
+	registryMu.Unlock()
+	return c
+}
+
+// Inc adds one to the counter for the given label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds n to the counter for the given label values.
+func (c *CounterVec) Add(n int64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+
+	c.mu.RLock()
+	v, ok := c.values[key]
+	c.mu.RUnlock()
+	if !ok {
+		c.mu.Lock()
+		if v, ok = c.values[key]; !ok {
+			v = new(atomic.Int64)
+			c.values[key] = v
+		}
+		c.mu.Unlock()
+	}
+	v.Add(n)
+}
+
+// Snapshot returns the current counts keyed by label values joined with ",".
+func (c *CounterVec) Snapshot() map[string]int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	out := make(map[string]int64, len(c.values))
+	for key, v := range c.values {
+		out[strings.ReplaceAll(key, "\xff", ",")] = v.Load()
+	}
+	return out
+}
+
+// write renders the counter in the text exposition format.
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.RLock()
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	c.mu.RUnlock()
+	sort.Strings(keys)
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range keys {
+		c.mu.RLock()
+		n := c.values[key].Load()
+		c.mu.RUnlock()
+		fmt.Fprintf(w, "%s%s %d\n", c.name, c.labelString(key), n)
+	}
+}
+
+// labelString formats label values as {a="x",b="y"}.
+func (c *CounterVec) labelString(key string) string {
+	if len(c.labels) == 0 {
+		return ""
+	}
+	values := strings.Split(key, "\xff")
+	pairs := make([]string, len(c.labels))
+	for i, label := range c.labels {
+		var v string
+		if i < len(values) {
+			v = values[i]
+		}
+		pairs[i] = fmt.Sprintf("%s=%q", label, v)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// GaugeFunc is a gauge whose value is read when metrics are scraped.
+type GaugeFunc struct {
+	name string
+	help string
+	fn   func() float64
+}
+
+// NewGaugeFunc creates and registers a gauge reporting fn.
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, fn: fn}
+	registryMu.Lock()
+	registry = append(registry, g)
+	registryMu.Unlock()
+	return g
+}
+
+// write renders the gauge in the text exposition format.
+func (g *GaugeFunc) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", g.name, g.help, g.name, g.name, g.fn())
+}
+
+// Handler serves every registered metric.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		registryMu.Lock()
+		metrics := append([]collector(nil), registry...)
+		registryMu.Unlock()
+
+		for _, c := range metrics {
+			c.write(w)
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrAPIKeyNotFound is returned for an unknown API key ID.
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKey is an API key managed through the admin API. The key itself is
+// never stored, only its SHA-256.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	KeyID      string     `json:"key_id"` // Prefix of the hash: the key's ID in logs and metrics
+	KeyHash    string     `json:"-"`
+	Label      string     `json:"label"`
+	Scopes     []string   `json:"scopes"` // Empty for a full API key
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Revoked reports whether the key was revoked.
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// CreateAPIKey stores a new API key by its hash.
+func (r *SQLiteInventoryRepository) CreateAPIKey(ctx context.Context, keyHash, label string, scopes []string) (*APIKey, error) {
+	if scopes == nil {
+		scopes = []string{}
+	}
+	encoded, err := json.Marshal(scopes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode scopes: %w", err)
+	}
+	key := &APIKey{KeyID: apiKeyID(keyHash), KeyHash: keyHash, Label: label, Scopes: scopes, CreatedAt: time.Now().UTC()}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO api_keys (key_hash, label, scopes, created_at)
+		VALUES (?, ?, ?, ?)`,
+		keyHash, label, string(encoded), key.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert api key: %w", err)
+	}
+	key.ID, _ = res.LastInsertId()
+	return key, nil
+}
+
+// ListAPIKeys returns API keys, oldest first. Revoked keys are left out
+// unless includeRevoked is set.
+func (r *SQLiteInventoryRepository) ListAPIKeys(ctx context.Context, includeRevoked bool) ([]APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	query := `SELECT id, key_hash, label, scopes, created_at, last_used_at, revoked_at FROM api_keys`
+	if !includeRevoked {
+		query += ` WHERE revoked_at IS NULL`
+	}
+	query += ` ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey marks an API key revoked and returns it. Revoking a revoked
+// key keeps its original revocation time.
+func (r *SQLiteInventoryRepository) RevokeAPIKey(ctx context.Context, id int64) (*APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.db.ExecContext(ctx, `UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`,
+		time.Now().UTC(), id); err != nil {
+		return nil, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	row := r.db.QueryRowContext(ctx, `
+		SELECT id, key_hash, label, scopes, created_at, last_used_at, revoked_at
+		FROM api_keys WHERE id = ?`, id)
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: id %d", ErrAPIKeyNotFound, id)
+	}
+	return key, err
+}
+
+// TouchAPIKeys records when API keys were last used, in one transaction.
+func (r *SQLiteInventoryRepository) TouchAPIKeys(ctx context.Context, lastUsed map[int64]time.Time) error {
+	if len(lastUsed) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `UPDATE api_keys SET last_used_at = ? WHERE id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for id, at := range lastUsed {
+		if _, err := stmt.ExecContext(ctx, at.UTC(), id); err != nil {
+			return fmt.Errorf("failed to update last use of api key %d: %w", id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// scanAPIKey scans one api_keys row.
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*APIKey, error) {
+	var (
+		key       APIKey
+		scopes    string
+		lastUsed  sql.NullTime
+		revokedAt sql.NullTime
+	)
+	if err := row.Scan(&key.ID, &key.KeyHash, &key.Label, &scopes, &key.CreatedAt, &lastUsed, &revokedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan api key: %w", err)
+	}
+	key.KeyID = apiKeyID(key.KeyHash)
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return nil, fmt.Errorf("failed to parse scopes of api key %d: %w", key.ID, err)
+	}
+	if lastUsed.Valid {
+		key.LastUsedAt = &lastUsed.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
+
+// apiKeyID is the non-secret ID of a key from its hex SHA-256.
+func apiKeyID(keyHash string) string {
+	if len(keyHash) > 12 {
+		return keyHash[:12]
+	}
+	return keyHash
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// auditRetentionRule keeps a year of audit entries.
+var auditRetentionRule = RetentionRule{
+	Table:      "audit_log",
+	TimeColumn: "at",
+	MaxAge:     365 * 24 * time.Hour,
+	Archive:    true,
+}
+
+// AuditEntry records one administrative change.
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	At        time.Time       `json:"at"`
+	Actor     string          `json:"actor"`  // e.g. "api_key" or "token:<key_account_id>"
+	Action    string          `json:"action"` // e.g. "key_account.create"
+	Target    string          `json:"target"` // e.g. "key_account:42"
+	RequestID string          `json:"request_id,omitempty"`
+	Detail    json.RawMessage `json:"detail,omitempty"` // Action-specific, e.g. before/after
+}
+
+// createAuditTable creates the audit log table.
+func createAuditTable(db *sql.DB) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		at DATETIME NOT NULL,
+		actor TEXT NOT NULL,
+		action TEXT NOT NULL,
+		target TEXT NOT NULL,
+		request_id TEXT NOT NULL DEFAULT '',
+		detail TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_at ON audit_log(at);
+	CREATE INDEX IF NOT EXISTS idx_audit_target ON audit_log(target);
+	`)
+	return err
+}
+
+// InsertAudit records an audit entry. detail is encoded as JSON.
+func (r *SQLiteInventoryRepository) InsertAudit(ctx context.Context, entry *AuditEntry, detail interface{}) error {
+	var encoded interface{}
+	if detail != nil {
+		data, err := json.Marshal(detail)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit detail: %w", err)
+		}
+		encoded = string(data)
+	}
+	if entry.At.IsZero() {
+		entry.At = time.Now().UTC()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO audit_log (at, actor, action, target, request_id, detail)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		entry.At.UTC(), entry.Actor, entry.Action, entry.Target, entry.RequestID, encoded)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+	entry.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// ListAudit returns a page of audit entries, newest first. An empty target
+// lists every entry.
+func (r *SQLiteInventoryRepository) ListAudit(ctx context.Context, target string, page PageQuery) ([]AuditEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var where []string
+	var args []interface{}
+	if target != "" {
+		where = append(where, "target = ?")
+		args = append(args, target)
+	}
+	query, args := page.apply(`SELECT id, at, actor, action, target, request_id, COALESCE(detail, '') FROM audit_log`, where, args)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var (
+			entry  AuditEntry
+			detail string
+		)
+		if err := rows.Scan(&entry.ID, &entry.At, &entry.Actor, &entry.Action, &entry.Target, &entry.RequestID, &detail); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if detail != "" {
+			entry.Detail = json.RawMessage(detail)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// backupStampLayout names backup directories, so they sort by age.
+const backupStampLayout = "20060102-150405"
+
+// ErrBackupRunning refuses a backup while another one is being written.
+var ErrBackupRunning = errors.New("a backup is already running")
+
+// BackupFile is one database file of a backup.
+type BackupFile struct {
+	File  string `json:"file"`
+	Bytes int64  `json:"bytes"`
+}
+
+// BackupResult describes a finished backup.
+type BackupResult struct {
+	Dir        string       `json:"dir"`
+	Files      []BackupFile `json:"files"`
+	Bytes      int64        `json:"bytes"`
+	DurationMs int64        `json:"duration_ms"`
+	Removed    []string     `json:"removed,omitempty"` // Older backups past the keep count
+}
+
+// BackupTo writes a consistent copy of the database to path while it stays
+// online, using VACUUM INTO. The copy is written next to path and renamed
+// into place once complete. Returns the size of the copy.
+func (r *SQLiteInventoryRepository) BackupTo(ctx context.Context, path string) (int64, error) {
+	tmp := path + ".tmp"
+	os.Remove(tmp) // VACUUM INTO refuses an existing file
+
+	r.mu.RLock()
+	_, err := r.db.ExecContext(ctx, "VACUUM INTO ?", tmp)
+	r.mu.RUnlock()
+	if err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("failed to back up %s: %w", filepath.Base(r.path), err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("failed to back up %s: %w", filepath.Base(r.path), err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// Backups writes timestamped copies of every database file into
+// <dir>/<YYYYMMDD-HHMMSS>/ and keeps the newest keep of them.
+type Backups struct {
+	dir   string
+	keep  int
+	files []*SQLiteInventoryRepository
+	mu    sync.Mutex // held while a backup runs
+}
+
+// NewBackups creates (if needed) the backup directory for files.
+// keep <= 0 keeps every backup.
+func NewBackups(dir string, keep int, files []*SQLiteInventoryRepository) (*Backups, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup dir: %w", err)
+	}
+	return &Backups{dir: dir, keep: keep, files: files}, nil
+}
+
+// Dir returns the backup directory.
+func (b *Backups) Dir() string {
+	return b.dir
+}
+
+// Backup copies every file into a new backup directory, then removes the
+// oldest backups past the keep count. A backup directory only appears
+// once all its files are written. Returns ErrBackupRunning while another
+// backup runs.
+func (b *Backups) Backup(ctx context.Context) (*BackupResult, error) {
+	if !b.mu.TryLock() {
+		return nil, ErrBackupRunning
+	}
+	defer b.mu.Unlock()
+
+	start := time.Now()
+	name := start.UTC().Format(backupStampLayout)
+	final := filepath.Join(b.dir, name)
+	if _, err := os.Stat(final); err == nil {
+		return nil, fmt.Errorf("backup %s already exists", name)
+	}
+	partial := final + ".partial"
+	os.RemoveAll(partial)
+	if err := os.Mkdir(partial, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup dir: %w", err)
+	}
+
+	result := &BackupResult{Dir: name}
+	for _, file := range b.files {
+		base := filepath.Base(file.path)
+		size, err := file.BackupTo(ctx, filepath.Join(partial, base))
+		if err != nil {
+			os.RemoveAll(partial)
+			return nil, err
+		}
+		result.Files = append(result.Files, BackupFile{File: base, Bytes: size})
+		result.Bytes += size
+	}
+	if err := os.Rename(partial, final); err != nil {
+		os.RemoveAll(partial)
+		return nil, fmt.Errorf("failed to finish backup: %w", err)
+	}
+
+	removed, err := b.prune()
+	result.Removed = removed
+	result.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		return result, fmt.Errorf("backup written, but pruning old backups failed: %w", err)
+	}
+	return result, nil
+}
+
+// prune removes the oldest backup directories past the keep count.
+// Anything not named like a backup is left alone.
+func (b *Backups) prune() ([]string, error) {
+	if b.keep <= 0 {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, e := range entries {
+		if _, err := time.Parse(backupStampLayout, e.Name()); e.IsDir() && err == nil {
+			backups = append(backups, e.Name())
+		}
+	}
+	if len(backups) <= b.keep {
+		return nil, nil
+	}
+	sort.Strings(backups)
+
+	var removed []string
+	for _, name := range backups[:len(backups)-b.keep] {
+		if err := os.RemoveAll(filepath.Join(b.dir, name)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
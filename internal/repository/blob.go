@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Storage formats of inventory_json, kept in the format column. Rows
+// written before compression existed have the column's default, plain JSON.
+const (
+	blobFormatJSON = 0 // The document as-is, stored as TEXT
+	blobFormatGzip = 1 // gzip of the document, stored as BLOB
+)
+
+// DefaultCompressMinBytes is the smallest document compressed unless
+// configured otherwise. Below it gzip's header costs more than it saves.
+const DefaultCompressMinBytes = 1024
+
+// storedSizeSQL is the size of the document a row holds: raw_size is kept
+// since documents may be compressed, and plain legacy rows have none.
+const storedSizeSQL = `COALESCE(raw_size, length(CAST(inventory_json AS BLOB)))`
+
+// blobWriters reuses compressors, which are costly to allocate.
+var blobWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(io.Discard) },
+}
+
+// encodeBlob returns the value stored for a document and its format.
+// Documents of at least minBytes are gzipped when that makes them smaller;
+// minBytes <= 0 stores everything as-is.
+func encodeBlob(raw []byte, minBytes int) (interface{}, int) {
+	if minBytes <= 0 || len(raw) < minBytes {
+		return string(raw), blobFormatJSON
+	}
+
+	var buf bytes.Buffer
+	gz := blobWriters.Get().(*gzip.Writer)
+	gz.Reset(&buf)
+	_, err := gz.Write(raw)
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	gz.Reset(io.Discard)
+	blobWriters.Put(gz)
+
+	if err != nil || buf.Len() >= len(raw) {
+		return string(raw), blobFormatJSON
+	}
+	return buf.Bytes(), blobFormatGzip
+}
+
+// decodeBlob returns the document stored as data in the given format.
+func decodeBlob(data []byte, format int) ([]byte, error) {
+	switch format {
+	case blobFormatJSON:
+		return data, nil
+	case blobFormatGzip:
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress document: %w", err)
+		}
+		defer gz.Close()
+		raw, err := io.ReadAll(gz)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress document: %w", err)
+		}
+		return raw, nil
+	}
+	return nil, fmt.Errorf("unknown document format %d", format)
+}
+
+// SetCompression gzips documents of at least minBytes on every write from
+// now on; 0 stores them uncompressed. Either way, rows in both formats are
+// read transparently. Existing rows are converted by RecompressBatch.
+func (r *SQLiteInventoryRepository) SetCompression(minBytes int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.compressMinBytes = max(minBytes, 0)
+}
+
+// RecompressStats counts what one RecompressBatch call did.
+type RecompressStats struct {
+	Scanned     int   `json:"scanned"`
+	Rewritten   int   `json:"rewritten"`
+	BytesBefore int64 `json:"bytes_before"` // Stored bytes of rewritten rows before...
+	BytesAfter  int64 `json:"bytes_after"`  // ...and after
+}
+
+// Add accumulates other into s.
+func (s *RecompressStats) Add(other RecompressStats) {
+	s.Scanned += other.Scanned
+	s.Rewritten += other.Rewritten
+	s.BytesBefore += other.BytesBefore
+	s.BytesAfter += other.BytesAfter
+}
+
+// RecompressTables are the tables holding documents, in the order
+// RecompressBatch walks them.
+var RecompressTables = []string{"fishit_inventory_raw", "inventory_history"}
+
+// RecompressBatch rewrites up to limit rows of table with id greater than
+// afterID in the current storage format, in one write transaction. Rows
+// already stored that way are left alone. Returns the last row ID read
+// (afterID when none). The file only shrinks once SQLite reuses or vacuums
+// the freed pages.
+func (r *SQLiteInventoryRepository) RecompressBatch(ctx context.Context, table string, afterID int64, limit int) (int64, RecompressStats, error) {
+	var stats RecompressStats
+	if table != RecompressTables[0] && table != RecompressTables[1] {
+		return afterID, stats, fmt.Errorf("unknown table %q", table)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return afterID, stats, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	type storedRow struct {
+		id     int64
+		data   []byte
+		format int
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT id, inventory_json, format FROM `+table+` WHERE id > ? ORDER BY id LIMIT ?`, afterID, limit)
+	if err != nil {
+		return afterID, stats, fmt.Errorf("failed to read rows to recompress: %w", err)
+	}
+	var page []storedRow
+	for rows.Next() {
+		var row storedRow
+		if err := rows.Scan(&row.id, &row.data, &row.format); err != nil {
+			rows.Close()
+			return afterID, stats, fmt.Errorf("failed to scan row to recompress: %w", err)
+		}
+		page = append(page, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return afterID, stats, fmt.Errorf("failed to read rows to recompress: %w", err)
+	}
+
+	update, err := tx.PrepareContext(ctx, `UPDATE `+table+` SET inventory_json = ?, format = ?, raw_size = ? WHERE id = ?`)
+	if err != nil {
+		return afterID, stats, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer update.Close()
+
+	lastID := afterID
+	for _, row := range page {
+		lastID = row.id
+		stats.Scanned++
+		raw, err := decodeBlob(row.data, row.format)
+		if err != nil {
+			return lastID, stats, fmt.Errorf("row %d: %w", row.id, err)
+		}
+		value, format := encodeBlob(raw, r.compressMinBytes)
+		if format == row.format {
+			continue
+		}
+		if _, err := update.ExecContext(ctx, value, format, len(raw), row.id); err != nil {
+			return lastID, stats, fmt.Errorf("failed to rewrite row %d: %w", row.id, err)
+		}
+		stats.Rewritten++
+		stats.BytesBefore += int64(len(row.data))
+		if b, ok := value.([]byte); ok {
+			stats.BytesAfter += int64(len(b))
+		} else {
+			stats.BytesAfter += int64(len(raw))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return afterID, RecompressStats{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return lastID, stats, nil
+}
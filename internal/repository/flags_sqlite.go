@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// flagRetentionRule drops flags not raised again for 30 days.
+var flagRetentionRule = RetentionRule{
+	Table:      "flagged_inventories",
+	TimeColumn: "last_flagged_at",
+	MaxAge:     30 * 24 * time.Hour,
+}
+
+// InventoryFlag is a stored section that broke an inventory rule. A user
+// breaking the same rule again updates the flag instead of adding one.
+type InventoryFlag struct {
+	ID             int64           `json:"id"`
+	RobloxUserID   string          `json:"roblox_user_id"`
+	Section        string          `json:"section"`
+	Rule           string          `json:"rule"`
+	Game           string          `json:"game"`
+	Offending      json.RawMessage `json:"offending"` // Values that broke the rule, latest flush
+	Hits           int64           `json:"hits"`      // Flushes that broke the rule
+	FirstFlaggedAt time.Time       `json:"first_flagged_at"`
+	LastFlaggedAt  time.Time       `json:"last_flagged_at"`
+	SyncedAt       time.Time       `json:"synced_at"` // Sync of the latest offending document
+}
+
+// createFlagTable creates the flagged inventories table.
+func createFlagTable(db *sql.DB) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS flagged_inventories (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		roblox_user_id TEXT NOT NULL,
+		section TEXT NOT NULL,
+		rule TEXT NOT NULL,
+		game TEXT NOT NULL DEFAULT '',
+		offending TEXT NOT NULL DEFAULT '[]',
+		hits INTEGER NOT NULL DEFAULT 1,
+		first_flagged_at DATETIME NOT NULL,
+		last_flagged_at DATETIME NOT NULL,
+		synced_at DATETIME NOT NULL,
+		UNIQUE(roblox_user_id, section, rule)
+	);
+	CREATE INDEX IF NOT EXISTS idx_flagged_rule ON flagged_inventories(rule);
+	`)
+	return err
+}
+
+// UpsertInventoryFlags records rule violations in one transaction.
+func (r *SQLiteInventoryRepository) UpsertInventoryFlags(ctx context.Context, flags []InventoryFlag) error {
+	if len(flags) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin flag transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO flagged_inventories (roblox_user_id, section, rule, game, offending, first_flagged_at, last_flagged_at, synced_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(roblox_user_id, section, rule) DO UPDATE SET
+			game = excluded.game,
+			offending = excluded.offending,
+			hits = hits + 1,
+			last_flagged_at = excluded.last_flagged_at,
+			synced_at = excluded.synced_at`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare flag upsert: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	for _, f := range flags {
+		if _, err := stmt.ExecContext(ctx, f.RobloxUserID, sectionOrDefault(f.Section), f.Rule, f.Game,
+			string(f.Offending), now, now, f.SyncedAt.UTC()); err != nil {
+			return fmt.Errorf("failed to upsert inventory flag: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ListInventoryFlags returns a page of flags, newest first. Empty rule or
+// robloxUserID don't filter.
+func (r *SQLiteInventoryRepository) ListInventoryFlags(ctx context.Context, rule, robloxUserID string, page PageQuery) ([]InventoryFlag, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var where []string
+	var args []interface{}
+	if rule != "" {
+		where = append(where, "rule = ?")
+		args = append(args, rule)
+	}
+	if robloxUserID != "" {
+		where = append(where, "roblox_user_id = ?")
+		args = append(args, robloxUserID)
+	}
+	query, args := page.apply(`
+		SELECT id, roblox_user_id, section, rule, game, offending, hits, first_flagged_at, last_flagged_at, synced_at
+		FROM flagged_inventories`, where, args)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inventory flags: %w", err)
+	}
+	defer rows.Close()
+
+	flags := []InventoryFlag{}
+	for rows.Next() {
+		var (
+			f         InventoryFlag
+			offending string
+		)
+		if err := rows.Scan(&f.ID, &f.RobloxUserID, &f.Section, &f.Rule, &f.Game, &offending, &f.Hits,
+			&f.FirstFlaggedAt, &f.LastFlaggedAt, &f.SyncedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan inventory flag: %w", err)
+		}
+		f.Offending = json.RawMessage(offending)
+		flags = append(flags, f)
+	}
+	return flags, rows.Err()
+}
+
+// CountInventoryFlags counts flags by rule.
+func (r *SQLiteInventoryRepository) CountInventoryFlags(ctx context.Context) (map[string]int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT rule, COUNT(*) FROM flagged_inventories GROUP BY rule")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count inventory flags: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var rule string
+		var n int64
+		if err := rows.Scan(&rule, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan flag count: %w", err)
+		}
+		counts[rule] = n
+	}
+	return counts, rows.Err()
+}
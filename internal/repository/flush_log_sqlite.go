@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// flushLogRetentionRule keeps a week of flush log rows.
+var flushLogRetentionRule = RetentionRule{
+	Table:      "flush_log",
+	TimeColumn: "started_at",
+	MaxAge:     7 * 24 * time.Hour,
+	Archive:    true,
+}
+
+// FlushStageOutcome is the result of one pipeline stage during a flush.
+type FlushStageOutcome struct {
+	Stage      string   `json:"stage"`
+	Status     string   `json:"status"` // ok, failed, skipped (data_loss_guard: tripped, paused, discarded, resumed)
+	Error      string   `json:"error,omitempty"`
+	Detail     string   `json:"detail,omitempty"`
+	Users      []string `json:"users,omitempty"`   // Affected roblox_user_id/section, for forensics
+	Retried    int      `json:"retried,omitempty"` // Queued batches retried during this flush
+	Queued     int      `json:"queued,omitempty"`  // Batches waiting for retry after this flush
+	DurationMs int64    `json:"duration_ms"`
+}
+
+// FlushLogEntry records one buffer flush.
+type FlushLogEntry struct {
+	ID         int64               `json:"id"`
+	StartedAt  time.Time           `json:"started_at"`
+	DurationMs int64               `json:"duration_ms"`
+	Items      int                 `json:"items"`
+	Persisted  bool                `json:"persisted"`
+	Error      string              `json:"error,omitempty"`
+	Upsert     *UpsertStats        `json:"upsert,omitempty"`
+	Stages     []FlushStageOutcome `json:"stages"`
+	TraceID    string              `json:"trace_id,omitempty"` // Set when the flush was traced
+}
+
+// createFlushLogTable creates the flush log table.
+func createFlushLogTable(db *sql.DB) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS flush_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		started_at DATETIME NOT NULL,
+		duration_ms INTEGER NOT NULL,
+		items INTEGER NOT NULL,
+		persisted INTEGER NOT NULL,
+		error TEXT,
+		stages TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_flush_log_started ON flush_log(started_at);
+	`)
+	if err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "flush_log", "upsert_stats", "TEXT"); err != nil {
+		return err
+	}
+	return addColumnIfMissing(db, "flush_log", "trace_id", "TEXT")
+}
+
+// InsertFlushLog records a flush. Old entries are pruned by the retention engine.
+func (r *SQLiteInventoryRepository) InsertFlushLog(ctx context.Context, entry *FlushLogEntry) error {
+	stages, err := json.Marshal(entry.Stages)
+	if err != nil {
+		return fmt.Errorf("failed to encode flush stages: %w", err)
+	}
+	var upsert interface{}
+	if entry.Upsert != nil {
+		data, _ := json.Marshal(entry.Upsert)
+		upsert = string(data)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO flush_log (started_at, duration_ms, items, persisted, error, stages, upsert_stats, trace_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''))`,
+		entry.StartedAt.UTC(), entry.DurationMs, entry.Items, entry.Persisted, entry.Error, string(stages), upsert, entry.TraceID)
+	if err != nil {
+		return fmt.Errorf("failed to insert flush log: %w", err)
+	}
+	return nil
+}
+
+// ListFlushLog returns a page of flushes, newest first.
+func (r *SQLiteInventoryRepository) ListFlushLog(ctx context.Context, page PageQuery) ([]FlushLogEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	query, args := page.apply(`
+		SELECT id, started_at, duration_ms, items, persisted, COALESCE(error, ''), stages, COALESCE(upsert_stats, ''), COALESCE(trace_id, '')
+		FROM flush_log`, nil, nil)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list flush log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []FlushLogEntry{}
+	for rows.Next() {
+		var (
+			entry  FlushLogEntry
+			stages string
+			upsert string
+		)
+		if err := rows.Scan(&entry.ID, &entry.StartedAt, &entry.DurationMs, &entry.Items, &entry.Persisted, &entry.Error, &stages, &upsert, &entry.TraceID); err != nil {
+			return nil, fmt.Errorf("failed to scan flush log: %w", err)
+		}
+		if err := json.Unmarshal([]byte(stages), &entry.Stages); err != nil {
+			return nil, fmt.Errorf("failed to decode flush stages: %w", err)
+		}
+		if upsert != "" {
+			entry.Upsert = &UpsertStats{}
+			if err := json.Unmarshal([]byte(upsert), entry.Upsert); err != nil {
+				return nil, fmt.Errorf("failed to decode upsert stats: %w", err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// HistoryEntry describes one stored version of a section.
+type HistoryEntry struct {
+	Section     string    `json:"section"`
+	SyncedAt    time.Time `json:"synced_at"`
+	Size        int64     `json:"size"`
+	ContentHash string    `json:"content_hash,omitempty"` // Left out for redacted reads
+}
+
+// createHistoryTable creates the inventory version history table. Rows are
+// only written while history is enabled (see SetHistoryKeep).
+func createHistoryTable(db *sql.DB) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS inventory_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		roblox_user_id TEXT NOT NULL,
+		section TEXT NOT NULL,
+		synced_at DATETIME NOT NULL,
+		inventory_json TEXT NOT NULL,
+		content_hash TEXT NOT NULL DEFAULT '',
+		format INTEGER NOT NULL DEFAULT 0,
+		raw_size INTEGER,
+		UNIQUE(roblox_user_id, section, synced_at)
+	);
+	`)
+	return err
+}
+
+// SetHistoryKeep keeps the last keep versions of every section a user
+// syncs; 0 (the default) stores only the current version. Versions are
+// appended and pruned in the write transaction, so history never lags the
+// stored row.
+func (r *SQLiteInventoryRepository) SetHistoryKeep(keep int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.historyKeep = max(keep, 0)
+}
+
+// HistoryKeep returns how many versions are kept per section, 0 when
+// history is disabled.
+func (r *SQLiteInventoryRepository) HistoryKeep() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.historyKeep
+}
+
+// historyWriter appends versions within a write transaction and prunes
+// each touched section once at the end.
+type historyWriter struct {
+	keep    int
+	insert  *sql.Stmt
+	prune   *sql.Stmt
+	touched map[[2]string]bool
+}
+
+// newHistoryWriter prepares the history statements on tx. Returns nil when
+// history is disabled; a nil writer ignores every call.
+func newHistoryWriter(ctx context.Context, tx *sql.Tx, keep int) (*historyWriter, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	insert, err := tx.PrepareContext(ctx, `
+		INSERT OR IGNORE INTO inventory_history (roblox_user_id, section, synced_at, inventory_json, content_hash, format, raw_size)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare history insert: %w", err)
+	}
+	prune, err := tx.PrepareContext(ctx, `
+		DELETE FROM inventory_history
+		WHERE roblox_user_id = ? AND section = ? AND id NOT IN (
+			SELECT id FROM inventory_history
+			WHERE roblox_user_id = ? AND section = ?
+			ORDER BY synced_at DESC LIMIT ?)`)
+	if err != nil {
+		insert.Close()
+		return nil, fmt.Errorf("failed to prepare history prune: %w", err)
+	}
+	return &historyWriter{keep: keep, insert: insert, prune: prune, touched: make(map[[2]string]bool)}, nil
+}
+
+// append records a new version of a section, stored as the row was (see
+// encodeBlob); size is that of the document.
+func (h *historyWriter) append(ctx context.Context, robloxUserID, section string, stored interface{}, format, size int, syncedAt time.Time, hash string) error {
+	if h == nil {
+		return nil
+	}
+	if _, err := h.insert.ExecContext(ctx, robloxUserID, section, syncedAt, stored, hash, format, size); err != nil {
+		return fmt.Errorf("failed to append history of %s: %w", robloxUserID, err)
+	}
+	h.touched[[2]string{robloxUserID, section}] = true
+	return nil
+}
+
+// finish drops versions past the limit of every touched section.
+func (h *historyWriter) finish(ctx context.Context) error {
+	if h == nil {
+		return nil
+	}
+	for key := range h.touched {
+		if _, err := h.prune.ExecContext(ctx, key[0], key[1], key[0], key[1], h.keep); err != nil {
+			return fmt.Errorf("failed to prune history of %s: %w", key[0], err)
+		}
+	}
+	return nil
+}
+
+// close releases the prepared statements.
+func (h *historyWriter) close() {
+	if h == nil {
+		return
+	}
+	h.insert.Close()
+	h.prune.Close()
+}
+
+// ListInventoryHistory returns the stored versions of a user's section,
+// newest first. Empty section lists every section.
+func (r *SQLiteInventoryRepository) ListInventoryHistory(ctx context.Context, robloxUserID, section string) ([]HistoryEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	query := `
+		SELECT section, synced_at, ` + storedSizeSQL + `, content_hash
+		FROM inventory_history WHERE roblox_user_id = ?`
+	args := []interface{}{robloxUserID}
+	if section != "" {
+		query += ` AND section = ?`
+		args = append(args, section)
+	}
+	query += ` ORDER BY synced_at DESC, section`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inventory history: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []HistoryEntry{}
+	for rows.Next() {
+		var e HistoryEntry
+		if err := rows.Scan(&e.Section, &e.SyncedAt, &e.Size, &e.ContentHash); err != nil {
+			return nil, fmt.Errorf("failed to scan history entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// GetInventoryHistory returns the version of a section synced at syncedAt.
+// Returns nil data (and no error) when no such version is kept.
+func (r *SQLiteInventoryRepository) GetInventoryHistory(ctx context.Context, robloxUserID, section string, syncedAt time.Time) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var (
+		data   []byte
+		format int
+	)
+	err := r.db.QueryRowContext(ctx, `
+		SELECT inventory_json, format FROM inventory_history
+		WHERE roblox_user_id = ? AND section = ? AND synced_at = ?`,
+		robloxUserID, sectionOrDefault(section), syncedAt.UTC()).Scan(&data, &format)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get inventory history: %w", err)
+	}
+	return decodeBlob(data, format)
+}
+
+// SetHistoryKeep sets the history limit of every shard.
+func (r *ShardedInventoryRepository) SetHistoryKeep(keep int) {
+	for _, shard := range r.shards {
+		shard.SetHistoryKeep(keep)
+	}
+}
+
+// HistoryKeep returns the history limit of the shards.
+func (r *ShardedInventoryRepository) HistoryKeep() int {
+	return r.shards[0].HistoryKeep()
+}
+
+// ListInventoryHistory lists versions from the user's shard.
+func (r *ShardedInventoryRepository) ListInventoryHistory(ctx context.Context, robloxUserID, section string) ([]HistoryEntry, error) {
+	return r.shard(robloxUserID).ListInventoryHistory(ctx, robloxUserID, section)
+}
+
+// GetInventoryHistory reads a version from the user's shard.
+func (r *ShardedInventoryRepository) GetInventoryHistory(ctx context.Context, robloxUserID, section string, syncedAt time.Time) ([]byte, error) {
+	return r.shard(robloxUserID).GetInventoryHistory(ctx, robloxUserID, section, syncedAt)
+}
@@ -0,0 +1,310 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Integrity issue kinds and statuses.
+const (
+	IntegrityHashMismatch = "hash_mismatch"
+	IntegrityCheckFailed  = "integrity_check"
+
+	IntegrityOpen         = "open"
+	IntegrityAcknowledged = "acknowledged"
+	IntegrityResolved     = "resolved"
+)
+
+// integrityRetentionRule drops resolved issues after 30 days.
+var integrityRetentionRule = RetentionRule{
+	Table:      "integrity_issues",
+	TimeColumn: "detected_at",
+	MaxAge:     30 * 24 * time.Hour,
+	Where:      "status = 'resolved'",
+	Archive:    true,
+}
+
+// ErrIntegrityIssueNotFound is returned for an unknown integrity issue ID.
+var ErrIntegrityIssueNotFound = errors.New("integrity issue not found")
+
+// IntegrityIssue is a recorded integrity finding. Partition is the index of
+// the inventory file (see InventoryStore.Partitions), or -1 for the primary
+// database when it holds no inventory rows.
+type IntegrityIssue struct {
+	ID           int64      `json:"id"`
+	Kind         string     `json:"kind"`
+	Partition    int        `json:"partition"`
+	RowID        int64      `json:"row_id,omitempty"`
+	RobloxUserID string     `json:"roblox_user_id,omitempty"`
+	Section      string     `json:"section,omitempty"`
+	StoredHash   string     `json:"stored_hash,omitempty"`
+	ActualHash   string     `json:"actual_hash,omitempty"`
+	Detail       string     `json:"detail,omitempty"`
+	Status       string     `json:"status"`
+	DetectedAt   time.Time  `json:"detected_at"`
+	CheckedAt    *time.Time `json:"checked_at,omitempty"`
+}
+
+// HashMismatch is a row whose stored content hash doesn't match its data.
+type HashMismatch struct {
+	RowID        int64
+	RobloxUserID string
+	Section      string
+	StoredHash   string
+	ActualHash   string
+}
+
+// createIntegrityTable creates the integrity issues table.
+func createIntegrityTable(db *sql.DB) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS integrity_issues (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,
+		partition INTEGER NOT NULL,
+		row_id INTEGER NOT NULL DEFAULT 0,
+		roblox_user_id TEXT NOT NULL DEFAULT '',
+		section TEXT NOT NULL DEFAULT '',
+		stored_hash TEXT NOT NULL DEFAULT '',
+		actual_hash TEXT NOT NULL DEFAULT '',
+		detail TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'open',
+		detected_at DATETIME NOT NULL,
+		checked_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_integrity_status ON integrity_issues(status);
+	`)
+	return err
+}
+
+// VerifyHashBatch recomputes the content hash of up to limit rows with id
+// greater than afterID. Rows stored before hashes existed (empty hash) are
+// skipped. Returns the last row ID read (afterID when none) and how many
+// rows were read.
+func (r *SQLiteInventoryRepository) VerifyHashBatch(ctx context.Context, afterID int64, limit int) (int64, int, []HashMismatch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, roblox_user_id, section, inventory_json, format, content_hash
+		FROM fishit_inventory_raw WHERE id > ? ORDER BY id LIMIT ?`, afterID, limit)
+	if err != nil {
+		return afterID, 0, nil, fmt.Errorf("failed to read rows for verification: %w", err)
+	}
+	defer rows.Close()
+
+	lastID := afterID
+	scanned := 0
+	var mismatches []HashMismatch
+	for rows.Next() {
+		var m HashMismatch
+		var data []byte
+		var format int
+		if err := rows.Scan(&m.RowID, &m.RobloxUserID, &m.Section, &data, &format, &m.StoredHash); err != nil {
+			return lastID, scanned, mismatches, fmt.Errorf("failed to scan row for verification: %w", err)
+		}
+		lastID = m.RowID
+		scanned++
+		if m.StoredHash == "" {
+			continue
+		}
+		if m.ActualHash = storedContentHash(data, format); m.ActualHash != m.StoredHash {
+			mismatches = append(mismatches, m)
+		}
+	}
+	return lastID, scanned, mismatches, rows.Err()
+}
+
+// storedContentHash hashes the document a row holds. A row that can't be
+// decompressed hashes to a marker that never matches a stored hash.
+func storedContentHash(data []byte, format int) string {
+	raw, err := decodeBlob(data, format)
+	if err != nil {
+		return "undecodable"
+	}
+	return ContentHash(raw)
+}
+
+// VerifyRow recomputes the content hash of one row. Returns nil when the
+// row matches or no longer exists.
+func (r *SQLiteInventoryRepository) VerifyRow(ctx context.Context, rowID int64) (*HashMismatch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	m := HashMismatch{RowID: rowID}
+	var data []byte
+	var format int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT roblox_user_id, section, inventory_json, format, content_hash
+		FROM fishit_inventory_raw WHERE id = ?`, rowID).Scan(&m.RobloxUserID, &m.Section, &data, &format, &m.StoredHash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read row %d: %w", rowID, err)
+	}
+	if m.StoredHash == "" {
+		return nil, nil
+	}
+	if m.ActualHash = storedContentHash(data, format); m.ActualHash == m.StoredHash {
+		return nil, nil
+	}
+	return &m, nil
+}
+
+// IntegrityCheck runs PRAGMA integrity_check and returns the reported
+// problems (nil when SQLite answers "ok").
+func (r *SQLiteInventoryRepository) IntegrityCheck(ctx context.Context) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rows, err := r.db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, fmt.Errorf("failed to run integrity check: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("failed to read integrity check: %w", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// RecordIntegrityIssue stores a finding. An unresolved issue for the same
+// row (or the same partition, for integrity checks) is updated in place
+// instead of duplicated. Reports whether a new issue was created.
+func (r *SQLiteInventoryRepository) RecordIntegrityIssue(ctx context.Context, issue *IntegrityIssue) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE integrity_issues SET stored_hash = ?, actual_hash = ?, detail = ?, checked_at = ?
+		WHERE kind = ? AND partition = ? AND row_id = ? AND status != ?`,
+		issue.StoredHash, issue.ActualHash, issue.Detail, now,
+		issue.Kind, issue.Partition, issue.RowID, IntegrityResolved)
+	if err != nil {
+		return false, fmt.Errorf("failed to update integrity issue: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return false, nil
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO integrity_issues (kind, partition, row_id, roblox_user_id, section, stored_hash, actual_hash, detail, status, detected_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		issue.Kind, issue.Partition, issue.RowID, issue.RobloxUserID, issue.Section,
+		issue.StoredHash, issue.ActualHash, issue.Detail, IntegrityOpen, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert integrity issue: %w", err)
+	}
+	return true, nil
+}
+
+// ListIntegrityIssues returns a page of issues, newest first. An empty
+// status lists all.
+func (r *SQLiteInventoryRepository) ListIntegrityIssues(ctx context.Context, status string, page PageQuery) ([]IntegrityIssue, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	query, args := page.apply(`
+		SELECT id, kind, partition, row_id, roblox_user_id, section, stored_hash, actual_hash, detail, status, detected_at, checked_at
+		FROM integrity_issues`, []string{"(? = '' OR status = ?)"}, []interface{}{status, status})
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list integrity issues: %w", err)
+	}
+	defer rows.Close()
+
+	issues := []IntegrityIssue{}
+	for rows.Next() {
+		issue, err := scanIntegrityIssue(rows)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, *issue)
+	}
+	return issues, rows.Err()
+}
+
+// GetIntegrityIssue returns one issue or ErrIntegrityIssueNotFound.
+func (r *SQLiteInventoryRepository) GetIntegrityIssue(ctx context.Context, id int64) (*IntegrityIssue, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	row := r.db.QueryRowContext(ctx, `
+		SELECT id, kind, partition, row_id, roblox_user_id, section, stored_hash, actual_hash, detail, status, detected_at, checked_at
+		FROM integrity_issues WHERE id = ?`, id)
+	issue, err := scanIntegrityIssue(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrIntegrityIssueNotFound
+	}
+	return issue, err
+}
+
+// UpdateIntegrityIssue sets an issue's status and latest hash, stamping checked_at.
+func (r *SQLiteInventoryRepository) UpdateIntegrityIssue(ctx context.Context, id int64, status, actualHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE integrity_issues SET status = ?, actual_hash = COALESCE(NULLIF(?, ''), actual_hash), checked_at = ?
+		WHERE id = ?`, status, actualHash, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update integrity issue: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrIntegrityIssueNotFound
+	}
+	return nil
+}
+
+// CountIntegrityIssues counts issues by status.
+func (r *SQLiteInventoryRepository) CountIntegrityIssues(ctx context.Context) (map[string]int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT status, COUNT(*) FROM integrity_issues GROUP BY status")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count integrity issues: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int64{IntegrityOpen: 0, IntegrityAcknowledged: 0, IntegrityResolved: 0}
+	for rows.Next() {
+		var status string
+		var n int64
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
+// scanIntegrityIssue reads one issue from a row or rows cursor.
+func scanIntegrityIssue(row interface{ Scan(...interface{}) error }) (*IntegrityIssue, error) {
+	var issue IntegrityIssue
+	var checkedAt sql.NullTime
+	err := row.Scan(&issue.ID, &issue.Kind, &issue.Partition, &issue.RowID, &issue.RobloxUserID, &issue.Section,
+		&issue.StoredHash, &issue.ActualHash, &issue.Detail, &issue.Status, &issue.DetectedAt, &checkedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan integrity issue: %w", err)
+	}
+	if checkedAt.Valid {
+		issue.CheckedAt = &checkedAt.Time
+	}
+	return &issue, nil
+}
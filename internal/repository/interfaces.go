@@ -7,12 +7,94 @@ import (
 
 // InventoryRepository defines inventory data access methods.
 type InventoryRepository interface {
-	// Raw JSON storage
-	UpsertRawInventory(ctx context.Context, keyAccountID int64, robloxUserID string, rawJSON []byte) error
+	// Raw JSON storage (default section)
+	UpsertRawInventory(ctx context.Context, keyAccountID int64, robloxUserID string, rawJSON []byte, itemCount int) error
 	GetRawInventory(ctx context.Context, robloxUserID string) ([]byte, *time.Time, error)
+
+	// Named sections (inventory, settings, stats, ...)
+	UpsertRawInventorySection(ctx context.Context, keyAccountID int64, robloxUserID, section string, rawJSON []byte, itemCount int) error
+	GetRawInventorySection(ctx context.Context, robloxUserID, section string) ([]byte, *time.Time, error)
+	ListSections(ctx context.Context, robloxUserID string) ([]SectionRecord, error)
+	ListSectionMeta(ctx context.Context, robloxUserID string) ([]SectionMeta, error)
+
+	// Listing of stored users
+	ListInventories(ctx context.Context, q InventoryListQuery) ([]InventorySummary, int64, error)
+
+	// Past versions of sections, kept while history is enabled
+	HistoryKeep() int
+	ListInventoryHistory(ctx context.Context, robloxUserID, section string) ([]HistoryEntry, error)
+	GetInventoryHistory(ctx context.Context, robloxUserID, section string, syncedAt time.Time) ([]byte, error)
+}
+
+// InventoryStore is the full inventory storage surface used by the flush
+// path and admin tooling. Implemented by a single SQLite file or by a set of
+// hash-sharded SQLite files; callers can't tell which.
+type InventoryStore interface {
+	InventoryRepository
+
+	BatchUpsertRawInventory(ctx context.Context, items []InventoryItem) error
+	BatchUpsertRawInventoryStats(ctx context.Context, items []InventoryItem) (*UpsertStats, error)
+	ScanAll(ctx context.Context, fn func(InventoryItem) error) error
+	ScanSince(ctx context.Context, since time.Time, fn func(InventoryItem) error) error
+	GetStats(ctx context.Context) (map[string]interface{}, error)
+	CountUnlinked(ctx context.Context) (int64, error)
+	DeleteUnlinked(ctx context.Context) (int64, error)
+	DeleteOlderThan(ctx context.Context, cutoff time.Time, dryRun bool) (*PruneResult, error)
+	SetHistoryKeep(keep int)
+	SetCompression(minBytes int)
+	// Pragmas returns the SQLite connection settings in effect
+	Pragmas() SQLitePragmas
+	// Partitions returns the files holding inventory rows
+	Partitions() []*SQLiteInventoryRepository
+	Close() error
 }
 
 // KeyAccountRepository defines key account data access methods.
 type KeyAccountRepository interface {
 	GetKeyAccountByRobloxUser(ctx context.Context, robloxUserID string) (int64, error)
 }
+
+// KeyAccountAuthRepository validates license keys when issuing session tokens.
+type KeyAccountAuthRepository interface {
+	KeyAccountRepository
+	ValidateKeyAndHWID(ctx context.Context, key, hwid, robloxUserID string) (*KeyAccountValidation, error)
+}
+
+// KeyAccountSyncRecorder records on key accounts when their inventory was
+// last persisted and how many items it held.
+type KeyAccountSyncRecorder interface {
+	BatchUpdateLastSync(ctx context.Context, syncs []KeyAccountSync) error
+}
+
+// KeyAccountInfoReader reads a key account with its license key and
+// online status.
+type KeyAccountInfoReader interface {
+	GetKeyAccountInfo(ctx context.Context, keyAccountID int64) (map[string]interface{}, error)
+}
+
+// KeyAccountHeartbeats records client heartbeats on key accounts
+// (is_online, last_heartbeat_at).
+type KeyAccountHeartbeats interface {
+	UpdateHeartbeat(ctx context.Context, beats []KeyAccountHeartbeat) error
+	MarkOffline(ctx context.Context, before time.Time) (int64, error)
+}
+
+// KeyAccountHWIDResetter unlocks key accounts from the HWID they were
+// bound to.
+type KeyAccountHWIDResetter interface {
+	ResetHWID(ctx context.Context, id int64) (string, error)
+}
+
+// APIKeyRepository stores API keys managed through the admin API.
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, keyHash, label string, scopes []string) (*APIKey, error)
+	ListAPIKeys(ctx context.Context, includeRevoked bool) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) (*APIKey, error)
+	TouchAPIKeys(ctx context.Context, lastUsed map[int64]time.Time) error
+}
+
+// KeyAccountProvisioner creates and updates key accounts for the license shop.
+type KeyAccountProvisioner interface {
+	CreateLinkedKeyAccount(ctx context.Context, key, robloxUserID, robloxUsername string) (*KeyAccount, error)
+	UpdateKeyAccount(ctx context.Context, id int64, update KeyAccountUpdate) (*KeyAccount, *KeyAccount, error)
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// InventoryListQuery selects one page of stored users, most recently synced
+// first.
+type InventoryListQuery struct {
+	Since  time.Time // Only users synced at or after this; zero for all
+	Limit  int
+	Offset int
+}
+
+// InventorySummary describes one stored user without loading documents.
+type InventorySummary struct {
+	RobloxUserID string    `json:"roblox_user_id"`
+	SyncedAt     time.Time `json:"synced_at"` // Latest sync of any section
+	Size         int64     `json:"size"`      // Bytes of all stored sections
+	Sections     int       `json:"sections"`
+}
+
+// inventorySummarySelect aggregates the section rows of each user. since
+// filters on the latest sync, so a user is listed once with their newest
+// timestamp.
+const inventorySummarySelect = `
+	SELECT roblox_user_id, MAX(synced_at) AS last_synced,
+		SUM(` + storedSizeSQL + `), COUNT(*)
+	FROM fishit_inventory_raw
+	GROUP BY roblox_user_id
+	HAVING MAX(synced_at) >= ?`
+
+// ListInventories returns a page of stored users and how many match in
+// total.
+func (r *SQLiteInventoryRepository) ListInventories(ctx context.Context, q InventoryListQuery) ([]InventorySummary, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	since := q.Since.UTC()
+	var total int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM (`+inventorySummarySelect+`)`, since).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count inventories: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, inventorySummarySelect+` ORDER BY last_synced DESC, roblox_user_id LIMIT ? OFFSET ?`,
+		since, q.Limit, q.Offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list inventories: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := []InventorySummary{}
+	for rows.Next() {
+		var (
+			s      InventorySummary
+			synced string
+		)
+		if err := rows.Scan(&s.RobloxUserID, &synced, &s.Size, &s.Sections); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan inventory summary: %w", err)
+		}
+		if s.SyncedAt, err = parseStoredTime(synced); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan inventory summary: %w", err)
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, total, rows.Err()
+}
+
+// ListInventories pages over the users of every shard. Each shard holds a
+// disjoint set of users, so the page is cut from the merge of each shard's
+// first Offset+Limit users.
+func (r *ShardedInventoryRepository) ListInventories(ctx context.Context, q InventoryListQuery) ([]InventorySummary, int64, error) {
+	perShard := make([][]InventorySummary, len(r.shards))
+	totals := make([]int64, len(r.shards))
+	errs := make([]error, len(r.shards))
+	r.eachShard(func(i int, shard *SQLiteInventoryRepository) {
+		perShard[i], totals[i], errs[i] = shard.ListInventories(ctx, InventoryListQuery{Since: q.Since, Limit: q.Offset + q.Limit})
+		if errs[i] != nil {
+			errs[i] = fmt.Errorf("shard %d: %w", i, errs[i])
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		merged []InventorySummary
+		total  int64
+	)
+	for i := range perShard {
+		merged = append(merged, perShard[i]...)
+		total += totals[i]
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if !merged[i].SyncedAt.Equal(merged[j].SyncedAt) {
+			return merged[i].SyncedAt.After(merged[j].SyncedAt)
+		}
+		return merged[i].RobloxUserID < merged[j].RobloxUserID
+	})
+
+	if q.Offset >= len(merged) {
+		return []InventorySummary{}, total, nil
+	}
+	merged = merged[q.Offset:]
+	if len(merged) > q.Limit {
+		merged = merged[:q.Limit]
+	}
+	return merged, total, nil
+}
+
+// storedTimeLayouts are the forms a DATETIME column comes back in when
+// SQLite hands it over as text, e.g. from an aggregate.
+var storedTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999-07:00",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+}
+
+// parseStoredTime parses a DATETIME value read as text.
+func parseStoredTime(s string) (time.Time, error) {
+	for _, layout := range storedTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", s)
+}
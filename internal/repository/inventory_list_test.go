@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListInventories(t *testing.T) {
+	for _, shards := range []int{0, 3} {
+		t.Run("shards="+strconv.Itoa(shards), func(t *testing.T) {
+			ctx := context.Background()
+			store, primary, err := OpenInventoryStore(t.TempDir(), shards)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				store.Close()
+				primary.Close()
+			})
+
+			// Users 1..7 synced a minute apart; user 3 synced stats last
+			base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+			var items []InventoryItem
+			for i := 1; i <= 7; i++ {
+				items = append(items, InventoryItem{KeyAccountID: 1, RobloxUserID: strconv.Itoa(i),
+					RawJSON: []byte(`{"Items":[1]}`), SyncedAt: base.Add(time.Duration(i) * time.Minute)})
+			}
+			items = append(items, InventoryItem{KeyAccountID: 1, RobloxUserID: "3", Section: "stats",
+				RawJSON: []byte(`{"Level":12}`), SyncedAt: base.Add(time.Hour)})
+			if err := store.BatchUpsertRawInventory(ctx, items); err != nil {
+				t.Fatal(err)
+			}
+
+			list := func(q InventoryListQuery) (string, int64) {
+				t.Helper()
+				users, total, err := store.ListInventories(ctx, q)
+				if err != nil {
+					t.Fatalf("ListInventories(%+v): %v", q, err)
+				}
+				ids := make([]string, len(users))
+				for i, u := range users {
+					ids[i] = u.RobloxUserID
+				}
+				return strings.Join(ids, ","), total
+			}
+
+			tests := []struct {
+				name      string
+				q         InventoryListQuery
+				want      string
+				wantTotal int64
+			}{
+				{"first page", InventoryListQuery{Limit: 3}, "3,7,6", 7},
+				{"second page", InventoryListQuery{Limit: 3, Offset: 3}, "5,4,2", 7},
+				{"last page", InventoryListQuery{Limit: 3, Offset: 6}, "1", 7},
+				{"past the end", InventoryListQuery{Limit: 3, Offset: 9}, "", 7},
+				{"since", InventoryListQuery{Limit: 10, Since: base.Add(5 * time.Minute)}, "3,7,6,5", 4},
+				{"since the latest sync of any section", InventoryListQuery{Limit: 10, Since: base.Add(time.Hour)}, "3", 1},
+			}
+			for _, tt := range tests {
+				if got, total := list(tt.q); got != tt.want || total != tt.wantTotal {
+					t.Errorf("%s: users %q, total %d; want %q, %d", tt.name, got, total, tt.want, tt.wantTotal)
+				}
+			}
+
+			users, _, _ := store.ListInventories(ctx, InventoryListQuery{Limit: 1})
+			want := InventorySummary{RobloxUserID: "3", SyncedAt: base.Add(time.Hour),
+				Size: int64(len(`{"Items":[1]}`) + len(`{"Level":12}`)), Sections: 2}
+			if len(users) != 1 || users[0] != want {
+				t.Errorf("summary = %+v, want %+v", users, want)
+			}
+		})
+	}
+}
@@ -2,20 +2,91 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
+	"vinzhub-rest-api/internal/domain"
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/tracing"
+
 	_ "modernc.org/sqlite" // Pure Go SQLite driver - no CGO required
 )
 
 // InventoryItem represents a single inventory record for batch operations.
 type InventoryItem struct {
-	KeyAccountID int64
-	RobloxUserID string
-	RawJSON      []byte
+	KeyAccountID  int64
+	RobloxUserID  string
+	Section       string // Empty means domain.DefaultSection
+	RawJSON       []byte
+	SyncedAt      time.Time
+	Callback      bool   // Client asked to be told once this row is persisted
+	ClientVersion string // X-Client-Version of the sync, if sent
+	ItemCount     int    // Items in the document, counted at flush time; -1 if never counted
+}
+
+// SectionRecord is one stored section of a user's inventory.
+type SectionRecord struct {
+	Section  string
+	RawJSON  []byte
+	SyncedAt time.Time
+}
+
+// SectionMeta describes a stored section without loading its document.
+type SectionMeta struct {
+	Section      string
 	SyncedAt     time.Time
+	ContentHash  string // Empty for rows stored before hashes were kept
+	Size         int64  // Bytes of the stored document
+	ItemCount    *int   // Nil for buffered copies and rows stored before counts were kept
+	KeyAccountID int64  // 0 when not linked, and for buffered copies
+}
+
+// UpsertStats classifies the rows of one batch upsert.
+type UpsertStats struct {
+	Inserted     int `json:"inserted"`      // New (user, section) rows
+	Updated      int `json:"updated"`       // Rows replaced by newer content
+	SkippedOlder int `json:"skipped_older"` // Incoming synced_at older than stored - not written
+	Unchanged    int `json:"unchanged"`     // Identical content, only synced_at moved
+	Duplicate    int `json:"duplicate"`     // Exact row already stored (re-flushed) - not written
+}
+
+// Add accumulates other into s.
+func (s *UpsertStats) Add(other *UpsertStats) {
+	if other == nil {
+		return
+	}
+	s.Inserted += other.Inserted
+	s.Updated += other.Updated
+	s.SkippedOlder += other.SkippedOlder
+	s.Unchanged += other.Unchanged
+	s.Duplicate += other.Duplicate
+}
+
+// ContentHash returns the hex SHA-256 of a stored document.
+func ContentHash(rawJSON []byte) string {
+	sum := sha256.Sum256(rawJSON)
+	return hex.EncodeToString(sum[:])
+}
+
+// storedItemCount maps an uncounted item (-1) to NULL.
+func storedItemCount(n int) interface{} {
+	if n < 0 {
+		return nil
+	}
+	return n
+}
+
+// sectionOrDefault maps an empty section name to the default section.
+func sectionOrDefault(section string) string {
+	if section == "" {
+		return domain.DefaultSection
+	}
+	return section
 }
 
 // SQLiteInventoryRepository implements InventoryRepository using SQLite.
@@ -23,15 +94,29 @@ type InventoryItem struct {
 type SQLiteInventoryRepository struct {
 	db *sql.DB
 	mu sync.RWMutex // Protect writes
+
+	historyKeep      int // Versions kept per section, 0 for no history
+	compressMinBytes int // Documents this large are gzipped, 0 for never
+	pragmas          SQLitePragmas
+	path             string
+	maint            *maintenance // Set by StartMaintenance
+	logger           *slog.Logger
 }
 
 // NewSQLiteInventoryRepository creates a new SQLite inventory repository.
 // dbPath is the path to the SQLite database file (e.g., "./data/inventory.db")
-func NewSQLiteInventoryRepository(dbPath string) (*SQLiteInventoryRepository, error) {
-	// Open with WAL mode and other optimizations
-	dsn := fmt.Sprintf("%s?_journal_mode=WAL&_synchronous=NORMAL&_cache_size=10000&_busy_timeout=5000", dbPath)
-	
-	db, err := sql.Open("sqlite", dsn)
+// The connection runs in WAL mode with synchronous=NORMAL; opening fails if
+// SQLite doesn't report those settings back.
+func NewSQLiteInventoryRepository(dbPath string, opts ...SQLiteOption) (*SQLiteInventoryRepository, error) {
+	o := sqliteOptions{busyTimeout: defaultBusyTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.logger == nil {
+		o.logger = logging.Component("SQLite")
+	}
+
+	db, err := sql.Open("sqlite", sqliteDSN(dbPath, o))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open SQLite: %w", err)
 	}
@@ -41,55 +126,279 @@ func NewSQLiteInventoryRepository(dbPath string) (*SQLiteInventoryRepository, er
 	db.SetMaxIdleConns(1)
 	db.SetConnMaxLifetime(0) // Keep connection alive
 
+	pragmas, err := verifyPragmas(db, o)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("SQLite %s: %w", dbPath, err)
+	}
+
+	if _, err := migrate(db, dbPath, o.logger); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("SQLite %s: %w", dbPath, err)
+	}
+
+	return &SQLiteInventoryRepository{db: db, pragmas: pragmas, path: dbPath, logger: o.logger}, nil
+}
+
+// baselineSchema creates the schema as it stood before versioned
+// migrations (migration 1), upgrading files created by older releases in
+// place. Every step is idempotent.
+func baselineSchema(db *sql.DB) error {
 	// Create table if not exists
 	if err := createTables(db); err != nil {
-		return nil, fmt.Errorf("failed to create tables: %w", err)
+		return fmt.Errorf("failed to create tables: %w", err)
+	}
+	if err := createFlushLogTable(db); err != nil {
+		return fmt.Errorf("failed to create flush log table: %w", err)
+	}
+	if err := createIntegrityTable(db); err != nil {
+		return fmt.Errorf("failed to create integrity table: %w", err)
+	}
+	if err := createAuditTable(db); err != nil {
+		return fmt.Errorf("failed to create audit table: %w", err)
+	}
+	if err := createSchemaProfileTables(db); err != nil {
+		return fmt.Errorf("failed to create schema profile tables: %w", err)
+	}
+	if err := createFlagTable(db); err != nil {
+		return fmt.Errorf("failed to create flag table: %w", err)
+	}
+	if err := createHistoryTable(db); err != nil {
+		return fmt.Errorf("failed to create history table: %w", err)
 	}
 
-	return &SQLiteInventoryRepository{db: db}, nil
+	// Upgrade databases created before sections existed
+	if err := migrateSections(db); err != nil {
+		return fmt.Errorf("failed to migrate sections: %w", err)
+	}
+	if err := addColumnIfMissing(db, "fishit_inventory_raw", "content_hash", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return fmt.Errorf("failed to migrate content hash: %w", err)
+	}
+	if err := addColumnIfMissing(db, "fishit_inventory_raw", "item_count", "INTEGER"); err != nil {
+		return fmt.Errorf("failed to migrate item count: %w", err)
+	}
+	for _, table := range RecompressTables {
+		if err := addColumnIfMissing(db, table, "format", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return fmt.Errorf("failed to migrate document format: %w", err)
+		}
+		if err := addColumnIfMissing(db, table, "raw_size", "INTEGER"); err != nil {
+			return fmt.Errorf("failed to migrate document size: %w", err)
+		}
+	}
+	return nil
 }
 
 // createTables creates the inventory table.
+// Each (roblox_user_id, section) pair is stored as its own row.
 func createTables(db *sql.DB) error {
 	query := `
 	CREATE TABLE IF NOT EXISTS fishit_inventory_raw (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		key_account_id INTEGER DEFAULT 0,
-		roblox_user_id TEXT NOT NULL UNIQUE,
+		roblox_user_id TEXT NOT NULL,
+		section TEXT NOT NULL DEFAULT 'inventory',
 		inventory_json TEXT NOT NULL,
-		synced_at DATETIME NOT NULL
+		synced_at DATETIME NOT NULL,
+		content_hash TEXT NOT NULL DEFAULT '',
+		item_count INTEGER,
+		format INTEGER NOT NULL DEFAULT 0,
+		raw_size INTEGER,
+		UNIQUE(roblox_user_id, section)
 	);
 	CREATE INDEX IF NOT EXISTS idx_roblox_user ON fishit_inventory_raw(roblox_user_id);
 	CREATE INDEX IF NOT EXISTS idx_synced_at ON fishit_inventory_raw(synced_at);
+
+	CREATE TABLE IF NOT EXISTS app_meta (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);
 	`
 	_, err := db.Exec(query)
 	return err
 }
 
-// UpsertRawInventory inserts or updates raw JSON inventory.
-func (r *SQLiteInventoryRepository) UpsertRawInventory(ctx context.Context, keyAccountID int64, robloxUserID string, rawJSON []byte) error {
+// hasColumn reports whether a table has the named column.
+func hasColumn(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// addColumnIfMissing adds a column to an existing table. Rows written before
+// the column existed get the default value.
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	exists, err := hasColumn(db, table, column)
+	if err != nil || exists {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// migrateSections rebuilds a pre-sections table (roblox_user_id UNIQUE) into
+// the (roblox_user_id, section) layout. SQLite can't drop a UNIQUE constraint
+// in place, so the table is copied inside a single transaction. Existing rows
+// become the default section.
+func migrateSections(db *sql.DB) error {
+	exists, err := hasColumn(db, "fishit_inventory_raw", "section")
+	if err != nil || exists {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`CREATE TABLE fishit_inventory_raw_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			key_account_id INTEGER DEFAULT 0,
+			roblox_user_id TEXT NOT NULL,
+			section TEXT NOT NULL DEFAULT 'inventory',
+			inventory_json TEXT NOT NULL,
+			synced_at DATETIME NOT NULL,
+			UNIQUE(roblox_user_id, section)
+		)`,
+		`INSERT INTO fishit_inventory_raw_new (id, key_account_id, roblox_user_id, section, inventory_json, synced_at)
+			SELECT id, key_account_id, roblox_user_id, 'inventory', inventory_json, synced_at FROM fishit_inventory_raw`,
+		`DROP TABLE fishit_inventory_raw`,
+		`ALTER TABLE fishit_inventory_raw_new RENAME TO fishit_inventory_raw`,
+		`CREATE INDEX IF NOT EXISTS idx_roblox_user ON fishit_inventory_raw(roblox_user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_synced_at ON fishit_inventory_raw(synced_at)`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpsertRawInventory inserts or updates the default section of a raw JSON inventory.
+func (r *SQLiteInventoryRepository) UpsertRawInventory(ctx context.Context, keyAccountID int64, robloxUserID string, rawJSON []byte, itemCount int) error {
+	return r.UpsertRawInventorySection(ctx, keyAccountID, robloxUserID, domain.DefaultSection, rawJSON, itemCount)
+}
+
+// UpsertRawInventorySection inserts or updates one section of a raw JSON
+// inventory. itemCount is stored alongside for reads that report it.
+func (r *SQLiteInventoryRepository) UpsertRawInventorySection(ctx context.Context, keyAccountID int64, robloxUserID, section string, rawJSON []byte, itemCount int) error {
+	ctx, span := tracing.Start(ctx, "SQLite.UpsertRawInventorySection")
+	err := r.upsertRawInventorySection(ctx, keyAccountID, robloxUserID, section, rawJSON, itemCount)
+	span.Fail(err)
+	span.End()
+	return err
+}
+
+func (r *SQLiteInventoryRepository) upsertRawInventorySection(ctx context.Context, keyAccountID int64, robloxUserID, section string, rawJSON []byte, itemCount int) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	query := `
-		INSERT INTO fishit_inventory_raw (key_account_id, roblox_user_id, inventory_json, synced_at)
-		VALUES (?, ?, ?, datetime('now'))
-		ON CONFLICT(roblox_user_id) DO UPDATE SET
+		INSERT INTO fishit_inventory_raw (key_account_id, roblox_user_id, section, inventory_json, synced_at, content_hash, item_count, format, raw_size)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(roblox_user_id, section) DO UPDATE SET
 			key_account_id = COALESCE(excluded.key_account_id, key_account_id),
 			inventory_json = excluded.inventory_json,
-			synced_at = datetime('now')`
+			synced_at = excluded.synced_at,
+			content_hash = excluded.content_hash,
+			item_count = excluded.item_count,
+			format = excluded.format,
+			raw_size = excluded.raw_size`
+
+	section = sectionOrDefault(section)
+	hash := ContentHash(rawJSON)
+	now := time.Now().UTC()
+	stored, format := encodeBlob(rawJSON, r.compressMinBytes)
+	if r.historyKeep == 0 {
+		_, err := r.db.ExecContext(ctx, query, keyAccountID, robloxUserID, section, stored, now, hash, storedItemCount(itemCount), format, len(rawJSON))
+		if err != nil {
+			return fmt.Errorf("failed to upsert raw inventory: %w", err)
+		}
+		return nil
+	}
 
-	_, err := r.db.ExecContext(ctx, query, keyAccountID, robloxUserID, string(rawJSON))
+	// With history, the version is appended in the same transaction unless
+	// the content didn't change
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var storedHash string
+	err = tx.QueryRowContext(ctx, `SELECT content_hash FROM fishit_inventory_raw WHERE roblox_user_id = ? AND section = ?`,
+		robloxUserID, section).Scan(&storedHash)
+	found := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read stored row %s: %w", robloxUserID, err)
+	}
+	if _, err := tx.ExecContext(ctx, query, keyAccountID, robloxUserID, section, stored, now, hash, storedItemCount(itemCount), format, len(rawJSON)); err != nil {
 		return fmt.Errorf("failed to upsert raw inventory: %w", err)
 	}
-	return nil
+	if !found || storedHash != hash {
+		history, err := newHistoryWriter(ctx, tx, r.historyKeep)
+		if err != nil {
+			return err
+		}
+		defer history.close()
+		if err := history.append(ctx, robloxUserID, section, stored, format, len(rawJSON), now, hash); err != nil {
+			return err
+		}
+		if err := history.finish(ctx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 // BatchUpsertRawInventory inserts or updates multiple inventories efficiently.
 func (r *SQLiteInventoryRepository) BatchUpsertRawInventory(ctx context.Context, items []InventoryItem) error {
+	_, err := r.BatchUpsertRawInventoryStats(ctx, items)
+	return err
+}
+
+// BatchUpsertRawInventoryStats writes a batch and classifies every row.
+// Each item is compared with the stored row inside the write transaction:
+// items older than the stored synced_at are skipped so an out-of-order flush
+// can't overwrite newer data, and items with identical content only move
+// synced_at forward instead of rewriting the document.
+func (r *SQLiteInventoryRepository) BatchUpsertRawInventoryStats(ctx context.Context, items []InventoryItem) (*UpsertStats, error) {
+	ctx, span := tracing.Start(ctx, "SQLite.BatchUpsertRawInventory")
+	if span == nil {
+		return r.batchUpsertRawInventory(ctx, items)
+	}
+	span.SetInt("db.rows", int64(len(items)))
+	stats, err := r.batchUpsertRawInventory(ctx, items)
+	if stats != nil {
+		span.SetInt("db.rows.inserted", int64(stats.Inserted))
+		span.SetInt("db.rows.updated", int64(stats.Updated))
+		span.SetInt("db.rows.unchanged", int64(stats.Unchanged))
+	}
+	span.Fail(err)
+	span.End()
+	return stats, err
+}
+
+func (r *SQLiteInventoryRepository) batchUpsertRawInventory(ctx context.Context, items []InventoryItem) (*UpsertStats, error) {
+	stats := &UpsertStats{}
 	if len(items) == 0 {
-		return nil
+		return stats, nil
 	}
 
 	r.mu.Lock()
@@ -98,46 +407,117 @@ func (r *SQLiteInventoryRepository) BatchUpsertRawInventory(ctx context.Context,
 	// Use transaction for batch insert
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO fishit_inventory_raw (key_account_id, roblox_user_id, inventory_json, synced_at)
-		VALUES (?, ?, ?, ?)
-		ON CONFLICT(roblox_user_id) DO UPDATE SET
+	selectStmt, err := tx.PrepareContext(ctx, `
+		SELECT synced_at, content_hash FROM fishit_inventory_raw
+		WHERE roblox_user_id = ? AND section = ?`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer selectStmt.Close()
+
+	upsertStmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO fishit_inventory_raw (key_account_id, roblox_user_id, section, inventory_json, synced_at, content_hash, item_count, format, raw_size)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(roblox_user_id, section) DO UPDATE SET
 			key_account_id = COALESCE(excluded.key_account_id, key_account_id),
 			inventory_json = excluded.inventory_json,
-			synced_at = excluded.synced_at`)
+			synced_at = excluded.synced_at,
+			content_hash = excluded.content_hash,
+			item_count = excluded.item_count,
+			format = excluded.format,
+			raw_size = excluded.raw_size`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer upsertStmt.Close()
+
+	touchStmt, err := tx.PrepareContext(ctx, `
+		UPDATE fishit_inventory_raw SET synced_at = ?, key_account_id = COALESCE(NULLIF(?, 0), key_account_id),
+			item_count = COALESCE(item_count, ?)
+		WHERE roblox_user_id = ? AND section = ?`)
 	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
 	}
-	defer stmt.Close()
+	defer touchStmt.Close()
+
+	history, err := newHistoryWriter(ctx, tx, r.historyKeep)
+	if err != nil {
+		return nil, err
+	}
+	defer history.close()
 
 	for _, item := range items {
-		_, err := stmt.ExecContext(ctx, item.KeyAccountID, item.RobloxUserID, string(item.RawJSON), item.SyncedAt)
+		section := sectionOrDefault(item.Section)
+		hash := ContentHash(item.RawJSON)
+
+		var (
+			storedAt   time.Time
+			storedHash string
+		)
+		err := selectStmt.QueryRowContext(ctx, item.RobloxUserID, section).Scan(&storedAt, &storedHash)
+		switch {
+		case err == sql.ErrNoRows:
+			stats.Inserted++
+		case err != nil:
+			return nil, fmt.Errorf("failed to read stored row %s: %w", item.RobloxUserID, err)
+		case item.SyncedAt.Before(storedAt):
+			stats.SkippedOlder++
+			continue
+		case storedHash == hash && item.SyncedAt.Equal(storedAt):
+			// Flushed before but not cleared from the buffer
+			stats.Duplicate++
+			continue
+		case storedHash == hash:
+			stats.Unchanged++
+			if _, err := touchStmt.ExecContext(ctx, item.SyncedAt, item.KeyAccountID, storedItemCount(item.ItemCount), item.RobloxUserID, section); err != nil {
+				return nil, fmt.Errorf("failed to batch upsert item %s: %w", item.RobloxUserID, err)
+			}
+			continue
+		default:
+			stats.Updated++
+		}
+
+		stored, format := encodeBlob(item.RawJSON, r.compressMinBytes)
+		_, err = upsertStmt.ExecContext(ctx, item.KeyAccountID, item.RobloxUserID, section, stored, item.SyncedAt, hash, storedItemCount(item.ItemCount), format, len(item.RawJSON))
 		if err != nil {
-			return fmt.Errorf("failed to batch upsert item %s: %w", item.RobloxUserID, err)
+			return nil, fmt.Errorf("failed to batch upsert item %s: %w", item.RobloxUserID, err)
+		}
+		if err := history.append(ctx, item.RobloxUserID, section, stored, format, len(item.RawJSON), item.SyncedAt, hash); err != nil {
+			return nil, err
 		}
 	}
+	if err := history.finish(ctx); err != nil {
+		return nil, err
+	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
-	return nil
+	return stats, nil
 }
 
-// GetRawInventory retrieves raw JSON inventory by Roblox user ID.
+// GetRawInventory retrieves the default section of a raw JSON inventory by Roblox user ID.
 func (r *SQLiteInventoryRepository) GetRawInventory(ctx context.Context, robloxUserID string) ([]byte, *time.Time, error) {
+	return r.GetRawInventorySection(ctx, robloxUserID, domain.DefaultSection)
+}
+
+// GetRawInventorySection retrieves one section of a raw JSON inventory.
+// Returns nil data (and no error) when the section has never been synced.
+func (r *SQLiteInventoryRepository) GetRawInventorySection(ctx context.Context, robloxUserID, section string) ([]byte, *time.Time, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	query := `SELECT inventory_json, synced_at FROM fishit_inventory_raw WHERE roblox_user_id = ?`
+	query := `SELECT inventory_json, format, synced_at FROM fishit_inventory_raw WHERE roblox_user_id = ? AND section = ?`
 
-	var rawJSON string
+	var data []byte
+	var format int
 	var syncedAt time.Time
 
-	err := r.db.QueryRowContext(ctx, query, robloxUserID).Scan(&rawJSON, &syncedAt)
+	err := r.db.QueryRowContext(ctx, query, robloxUserID, sectionOrDefault(section)).Scan(&data, &format, &syncedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil, nil
@@ -145,7 +525,292 @@ func (r *SQLiteInventoryRepository) GetRawInventory(ctx context.Context, robloxU
 		return nil, nil, fmt.Errorf("failed to get raw inventory: %w", err)
 	}
 
-	return []byte(rawJSON), &syncedAt, nil
+	rawJSON, err := decodeBlob(data, format)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get raw inventory %s: %w", robloxUserID, err)
+	}
+	return rawJSON, &syncedAt, nil
+}
+
+// ListSections returns every stored section for a Roblox user.
+func (r *SQLiteInventoryRepository) ListSections(ctx context.Context, robloxUserID string) ([]SectionRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	query := `SELECT section, inventory_json, format, synced_at FROM fishit_inventory_raw WHERE roblox_user_id = ? ORDER BY section`
+
+	rows, err := r.db.QueryContext(ctx, query, robloxUserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sections: %w", err)
+	}
+	defer rows.Close()
+
+	var records []SectionRecord
+	for rows.Next() {
+		var rec SectionRecord
+		var data []byte
+		var format int
+		if err := rows.Scan(&rec.Section, &data, &format, &rec.SyncedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan section: %w", err)
+		}
+		if rec.RawJSON, err = decodeBlob(data, format); err != nil {
+			return nil, fmt.Errorf("failed to read section %s: %w", rec.Section, err)
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// ListSectionMeta returns the metadata of every stored section for a Roblox
+// user. The documents themselves are never read, so HEAD requests stay cheap.
+func (r *SQLiteInventoryRepository) ListSectionMeta(ctx context.Context, robloxUserID string) ([]SectionMeta, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	query := `SELECT section, synced_at, content_hash, ` + storedSizeSQL + `, item_count, COALESCE(key_account_id, 0) FROM fishit_inventory_raw WHERE roblox_user_id = ? ORDER BY section`
+
+	rows, err := r.db.QueryContext(ctx, query, robloxUserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list section metadata: %w", err)
+	}
+	defer rows.Close()
+
+	var metas []SectionMeta
+	for rows.Next() {
+		var (
+			meta      SectionMeta
+			itemCount sql.NullInt64
+		)
+		if err := rows.Scan(&meta.Section, &meta.SyncedAt, &meta.ContentHash, &meta.Size, &itemCount, &meta.KeyAccountID); err != nil {
+			return nil, fmt.Errorf("failed to scan section metadata: %w", err)
+		}
+		if itemCount.Valid {
+			n := int(itemCount.Int64)
+			meta.ItemCount = &n
+		}
+		metas = append(metas, meta)
+	}
+	return metas, rows.Err()
+}
+
+// unlinkedDeleteBatch bounds each delete transaction so purges don't hold
+// the write lock long enough to stall a flush.
+const unlinkedDeleteBatch = 1000
+
+// CountUnlinked returns how many rows were stored without a key account.
+func (r *SQLiteInventoryRepository) CountUnlinked(ctx context.Context) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM fishit_inventory_raw WHERE key_account_id = 0 OR key_account_id IS NULL").Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unlinked inventories: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteUnlinked removes rows stored without a key account, in small batches.
+// Returns the number of rows deleted.
+func (r *SQLiteInventoryRepository) DeleteUnlinked(ctx context.Context) (int64, error) {
+	var total int64
+	for {
+		r.mu.Lock()
+		res, err := r.db.ExecContext(ctx, `
+			DELETE FROM fishit_inventory_raw WHERE id IN (
+				SELECT id FROM fishit_inventory_raw
+				WHERE key_account_id = 0 OR key_account_id IS NULL
+				LIMIT ?
+			)`, unlinkedDeleteBatch)
+		r.mu.Unlock()
+		if err != nil {
+			return total, fmt.Errorf("failed to delete unlinked inventories: %w", err)
+		}
+
+		n, _ := res.RowsAffected()
+		total += n
+		if n < unlinkedDeleteBatch {
+			return total, nil
+		}
+	}
+}
+
+// pruneDeleteBatch bounds each prune transaction, as for unlinked rows.
+const pruneDeleteBatch = 1000
+
+// PruneResult reports rows pruned by DeleteOlderThan.
+type PruneResult struct {
+	Rows int64 `json:"rows"`
+	// ReclaimedPages estimates the pages the rows' documents occupy. Freed
+	// pages are reused by new rows; the file only shrinks on VACUUM
+	ReclaimedPages int64 `json:"reclaimed_pages_estimate"`
+	ReclaimedBytes int64 `json:"reclaimed_bytes_estimate"`
+}
+
+// DeleteOlderThan removes inventory rows last synced before cutoff, in
+// small batches, or only counts them when dryRun is set.
+func (r *SQLiteInventoryRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time, dryRun bool) (*PruneResult, error) {
+	r.mu.RLock()
+	var rows, size, pageSize int64
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*), COALESCE(SUM("+storedSizeSQL+"), 0) FROM fishit_inventory_raw WHERE synced_at < ?", cutoff).Scan(&rows, &size)
+	if err == nil {
+		err = r.db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize)
+	}
+	r.mu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count old inventories: %w", err)
+	}
+
+	result := &PruneResult{Rows: rows, ReclaimedBytes: size}
+	if pageSize > 0 {
+		result.ReclaimedPages = (size + pageSize - 1) / pageSize
+	}
+	if dryRun || rows == 0 {
+		return result, nil
+	}
+
+	var total int64
+	for {
+		n, err := r.deleteBatch(ctx, `
+			DELETE FROM fishit_inventory_raw WHERE id IN (
+				SELECT id FROM fishit_inventory_raw WHERE synced_at < ? LIMIT ?
+			)`, cutoff, pruneDeleteBatch)
+		total += n
+		if err != nil {
+			result.Rows = total
+			return result, fmt.Errorf("failed to delete old inventories: %w", err)
+		}
+		if n < pruneDeleteBatch {
+			break
+		}
+	}
+	result.Rows = total
+	return result, nil
+}
+
+// GetMeta reads a value from the app_meta table.
+func (r *SQLiteInventoryRepository) GetMeta(ctx context.Context, key string) (string, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var value string
+	err := r.db.QueryRowContext(ctx, "SELECT value FROM app_meta WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read meta %s: %w", key, err)
+	}
+	return value, true, nil
+}
+
+// SetMeta writes a value to the app_meta table.
+func (r *SQLiteInventoryRepository) SetMeta(ctx context.Context, key, value string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO app_meta (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, value)
+	if err != nil {
+		return fmt.Errorf("failed to write meta %s: %w", key, err)
+	}
+	return nil
+}
+
+// scanPageSize is the number of rows read per page by ScanAll.
+const scanPageSize = 500
+
+// ScanAll calls fn for every stored row in id order. Rows are read in pages
+// so the read lock is never held for the whole table.
+func (r *SQLiteInventoryRepository) ScanAll(ctx context.Context, fn func(InventoryItem) error) error {
+	return r.ScanSince(ctx, time.Time{}, fn)
+}
+
+// ScanSince is ScanAll limited to rows synced at or after since; a zero
+// since scans every row.
+func (r *SQLiteInventoryRepository) ScanSince(ctx context.Context, since time.Time, fn func(InventoryItem) error) error {
+	var lastID int64
+	for {
+		page, err := r.scanPage(ctx, lastID, since)
+		if err != nil {
+			return err
+		}
+		for _, row := range page {
+			if err := fn(row.item); err != nil {
+				return err
+			}
+			lastID = row.id
+		}
+		if len(page) < scanPageSize {
+			return nil
+		}
+	}
+}
+
+type scannedRow struct {
+	id   int64
+	item InventoryItem
+}
+
+// scanPage reads one page of rows with id greater than afterID, synced at
+// or after since unless it is zero.
+func (r *SQLiteInventoryRepository) scanPage(ctx context.Context, afterID int64, since time.Time) ([]scannedRow, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	query := `
+		SELECT id, COALESCE(key_account_id, 0), roblox_user_id, section, inventory_json, format, synced_at, COALESCE(item_count, -1)
+		FROM fishit_inventory_raw WHERE id > ?`
+	args := []interface{}{afterID}
+	if !since.IsZero() {
+		query += ` AND synced_at >= ?`
+		args = append(args, since)
+	}
+	rows, err := r.db.QueryContext(ctx, query+` ORDER BY id LIMIT ?`, append(args, scanPageSize)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan inventories: %w", err)
+	}
+	defer rows.Close()
+
+	var page []scannedRow
+	for rows.Next() {
+		var row scannedRow
+		var data []byte
+		var format int
+		if err := rows.Scan(&row.id, &row.item.KeyAccountID, &row.item.RobloxUserID, &row.item.Section, &data, &format, &row.item.SyncedAt, &row.item.ItemCount); err != nil {
+			return nil, fmt.Errorf("failed to scan inventory row: %w", err)
+		}
+		if row.item.RawJSON, err = decodeBlob(data, format); err != nil {
+			return nil, fmt.Errorf("failed to read inventory row %d: %w", row.id, err)
+		}
+		page = append(page, row)
+	}
+	return page, rows.Err()
+}
+
+// DeleteAllInventories removes every inventory row. Used when resharding
+// moves rows out of this file.
+func (r *SQLiteInventoryRepository) DeleteAllInventories(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res, err := r.db.ExecContext(ctx, "DELETE FROM fishit_inventory_raw")
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete inventories: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// VacuumInto writes a consistent copy of the database to path.
+func (r *SQLiteInventoryRepository) VacuumInto(ctx context.Context, path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to copy database: %w", err)
+	}
+	return nil
 }
 
 // GetStats returns statistics about the inventory database.
@@ -162,12 +827,24 @@ func (r *SQLiteInventoryRepository) GetStats(ctx context.Context) (map[string]in
 	}
 	stats["total_inventories"] = count
 
+	// Distinct users (a user has one row per synced section)
+	var users int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(DISTINCT roblox_user_id) FROM fishit_inventory_raw").Scan(&users); err == nil {
+		stats["total_users"] = users
+	}
+
 	// Last sync time
 	var lastSync sql.NullTime
 	if err := r.db.QueryRowContext(ctx, "SELECT MAX(synced_at) FROM fishit_inventory_raw").Scan(&lastSync); err == nil && lastSync.Valid {
 		stats["last_sync"] = lastSync.Time
 	}
 
+	// Items across default sections, as counted when they were stored
+	var items int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COALESCE(SUM(item_count), 0) FROM fishit_inventory_raw WHERE section = ?", domain.DefaultSection).Scan(&items); err == nil {
+		stats["total_items"] = items
+	}
+
 	// Database file size (approximate from page count)
 	var pageCount, pageSize int64
 	r.db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pageCount)
@@ -177,10 +854,23 @@ func (r *SQLiteInventoryRepository) GetStats(ctx context.Context) (map[string]in
 	return stats, nil
 }
 
-// Close closes the database connection.
+// Partitions returns the repository itself: all rows live in one file.
+func (r *SQLiteInventoryRepository) Partitions() []*SQLiteInventoryRepository {
+	return []*SQLiteInventoryRepository{r}
+}
+
+// Ping checks the database answers a query reading its schema, which fails
+// while another connection holds it locked. It doesn't wait for r.mu.
+func (r *SQLiteInventoryRepository) Ping(ctx context.Context) error {
+	var one int
+	return r.db.QueryRowContext(ctx, "SELECT 1 FROM sqlite_master LIMIT 1").Scan(&one)
+}
+
+// Close stops maintenance and closes the database connection.
 func (r *SQLiteInventoryRepository) Close() error {
+	r.stopMaintenance()
 	return r.db.Close()
 }
 
-// Ensure SQLiteInventoryRepository implements InventoryRepository
-var _ InventoryRepository = (*SQLiteInventoryRepository)(nil)
+// Ensure SQLiteInventoryRepository implements InventoryStore
+var _ InventoryStore = (*SQLiteInventoryRepository)(nil)
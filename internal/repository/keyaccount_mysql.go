@@ -3,10 +3,56 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrKeyAccountNotFound is returned when no active key account matches.
+var ErrKeyAccountNotFound = errors.New("key account not found")
+
+// Key account provisioning errors.
+var (
+	// ErrKeyNotFound is returned when linking to a license key that doesn't exist
+	ErrKeyNotFound = errors.New("license key not found")
+	// ErrKeyAlreadyLinked is returned when the license key already has a key account
+	ErrKeyAlreadyLinked = errors.New("license key is already linked to a key account")
+	// ErrRobloxUserLinked is returned when the roblox user already has an active key account
+	ErrRobloxUserLinked = errors.New("roblox user already has an active key account")
 )
 
+// KeyAccount is a key account as managed by provisioning.
+type KeyAccount struct {
+	ID             int64  `json:"id"`
+	KeyID          int64  `json:"key_id"`
+	RobloxUserID   string `json:"roblox_user_id"`
+	RobloxUsername string `json:"roblox_username"`
+	IsActive       bool   `json:"is_active"`
+}
+
+// KeyAccountUpdate lists the fields to change; nil fields are kept.
+type KeyAccountUpdate struct {
+	RobloxUserID   *string `json:"roblox_user_id,omitempty"`
+	RobloxUsername *string `json:"roblox_username,omitempty"`
+	IsActive       *bool   `json:"is_active,omitempty"`
+}
+
+// apply returns acc with the update applied.
+func (u KeyAccountUpdate) apply(acc KeyAccount) KeyAccount {
+	if u.RobloxUserID != nil {
+		acc.RobloxUserID = *u.RobloxUserID
+	}
+	if u.RobloxUsername != nil {
+		acc.RobloxUsername = *u.RobloxUsername
+	}
+	if u.IsActive != nil {
+		acc.IsActive = *u.IsActive
+	}
+	return acc
+}
+
 // MySQLKeyAccountRepository implements KeyAccountRepository using MySQL.
 type MySQLKeyAccountRepository struct {
 	db *sql.DB
@@ -25,7 +71,7 @@ func (r *MySQLKeyAccountRepository) GetKeyAccountByRobloxUser(ctx context.Contex
 	err := r.db.QueryRowContext(ctx, query, robloxUserID).Scan(&id)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return 0, fmt.Errorf("key account not found for roblox user: %s", robloxUserID)
+			return 0, fmt.Errorf("%w for roblox user: %s", ErrKeyAccountNotFound, robloxUserID)
 		}
 		return 0, fmt.Errorf("failed to get key account: %w", err)
 	}
@@ -61,36 +107,85 @@ func (r *MySQLKeyAccountRepository) UpdateLastSync(ctx context.Context, keyAccou
 	return nil
 }
 
+// KeyAccountSync is the latest persisted inventory of a key account.
+type KeyAccountSync struct {
+	KeyAccountID int64
+	SyncedAt     time.Time
+	ItemCount    int
+}
+
+// BatchUpdateLastSync records the last sync time and item count of many
+// key accounts in one transaction.
+func (r *MySQLKeyAccountRepository) BatchUpdateLastSync(ctx context.Context, syncs []KeyAccountSync) error {
+	return batchUpdateLastSync(ctx, r.db, syncs)
+}
+
+// batchUpdateLastSync applies syncs to key_accounts; the statement is the
+// same for MySQL and SQLite.
+func batchUpdateLastSync(ctx context.Context, db *sql.DB, syncs []KeyAccountSync) error {
+	if len(syncs) == 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		UPDATE key_accounts
+		SET last_inventory_sync = ?, inventory_item_count = ?
+		WHERE id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, sync := range syncs {
+		if _, err := stmt.ExecContext(ctx, sync.SyncedAt.UTC(), sync.ItemCount, sync.KeyAccountID); err != nil {
+			return fmt.Errorf("failed to update last sync of key account %d: %w", sync.KeyAccountID, err)
+		}
+	}
+	return tx.Commit()
+}
+
 // GetKeyAccountInfo returns key account details including key and user info.
 func (r *MySQLKeyAccountRepository) GetKeyAccountInfo(ctx context.Context, keyAccountID int64) (map[string]interface{}, error) {
+	return keyAccountInfo(ctx, r.db, keyAccountID)
+}
+
+// keyAccountInfo reads a key account joined with its license key; the
+// query is the same for MySQL and SQLite.
+func keyAccountInfo(ctx context.Context, db *sql.DB, keyAccountID int64) (map[string]interface{}, error) {
 	query := `
-		SELECT 
+		SELECT
 			ka.id, ka.roblox_user_id, ka.roblox_username, ka.hwid,
 			ka.is_active, ka.is_online, ka.last_heartbeat_at,
 			k.` + "`key`" + ` as license_key, k.status as key_status
 		FROM key_accounts ka
 		JOIN ` + "`keys`" + ` k ON ka.key_id = k.id
 		WHERE ka.id = ?`
-	
+
 	var (
 		id, robloxUserID, robloxUsername, hwid string
-		isActive, isOnline                      bool
-		lastHeartbeat                           sql.NullTime
-		licenseKey, keyStatus                   string
+		isActive, isOnline                     bool
+		lastHeartbeat                          sql.NullTime
+		licenseKey, keyStatus                  string
 	)
-	
-	err := r.db.QueryRowContext(ctx, query, keyAccountID).Scan(
+
+	err := db.QueryRowContext(ctx, query, keyAccountID).Scan(
 		&id, &robloxUserID, &robloxUsername, &hwid,
 		&isActive, &isOnline, &lastHeartbeat,
 		&licenseKey, &keyStatus,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("key account not found")
+			return nil, fmt.Errorf("%w: id %d", ErrKeyAccountNotFound, keyAccountID)
 		}
 		return nil, err
 	}
-	
+
 	result := map[string]interface{}{
 		"id":              id,
 		"roblox_user_id":  robloxUserID,
@@ -101,14 +196,76 @@ func (r *MySQLKeyAccountRepository) GetKeyAccountInfo(ctx context.Context, keyAc
 		"license_key":     licenseKey,
 		"key_status":      keyStatus,
 	}
-	
+
 	if lastHeartbeat.Valid {
 		result["last_heartbeat_at"] = lastHeartbeat.Time
 	}
-	
+
 	return result, nil
 }
 
+// KeyAccountHeartbeat is the latest heartbeat of a key account's client.
+type KeyAccountHeartbeat struct {
+	KeyAccountID int64
+	At           time.Time
+}
+
+// UpdateHeartbeat marks key accounts online as of their latest
+// heartbeat, in one transaction.
+func (r *MySQLKeyAccountRepository) UpdateHeartbeat(ctx context.Context, beats []KeyAccountHeartbeat) error {
+	return batchUpdateHeartbeat(ctx, r.db, beats)
+}
+
+// MarkOffline marks online key accounts without a heartbeat since before
+// as offline and returns how many it marked.
+func (r *MySQLKeyAccountRepository) MarkOffline(ctx context.Context, before time.Time) (int64, error) {
+	return markOffline(ctx, r.db, before)
+}
+
+// batchUpdateHeartbeat applies heartbeats to key_accounts; the statement is
+// the same for MySQL and SQLite. Times are stored to the second so SQLite's
+// text timestamps compare in order.
+func batchUpdateHeartbeat(ctx context.Context, db *sql.DB, beats []KeyAccountHeartbeat) error {
+	if len(beats) == 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		UPDATE key_accounts
+		SET is_online = 1, last_heartbeat_at = ?
+		WHERE id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, beat := range beats {
+		if _, err := stmt.ExecContext(ctx, beat.At.UTC().Truncate(time.Second), beat.KeyAccountID); err != nil {
+			return fmt.Errorf("failed to update heartbeat of key account %d: %w", beat.KeyAccountID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// markOffline clears is_online on accounts whose last heartbeat is older
+// than before.
+func markOffline(ctx context.Context, db *sql.DB, before time.Time) (int64, error) {
+	res, err := db.ExecContext(ctx, `
+		UPDATE key_accounts SET is_online = 0
+		WHERE is_online = 1 AND (last_heartbeat_at IS NULL OR last_heartbeat_at < ?)`,
+		before.UTC().Truncate(time.Second))
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark key accounts offline: %w", err)
+	}
+	return res.RowsAffected()
+}
+
 // KeyAccountValidation contains the result of key+hwid validation.
 type KeyAccountValidation struct {
 	KeyAccountID   int64
@@ -177,3 +334,145 @@ func (r *MySQLKeyAccountRepository) ValidateKeyAndHWID(ctx context.Context, key,
 	return &result, nil
 }
 
+
+// CreateLinkedKeyAccount creates an active key account for an existing
+// license key. Fails with ErrKeyNotFound, ErrKeyAlreadyLinked or
+// ErrRobloxUserLinked; the checks and the insert share one transaction with
+// the rows locked, so concurrent provisioning can't link twice.
+func (r *MySQLKeyAccountRepository) CreateLinkedKeyAccount(ctx context.Context, key, robloxUserID, robloxUsername string) (*KeyAccount, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var keyID int64
+	err = tx.QueryRowContext(ctx, "SELECT id FROM `keys` WHERE `key` = ? FOR UPDATE", key).Scan(&keyID)
+	if err == sql.ErrNoRows {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+
+	var linked int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM key_accounts WHERE key_id = ? FOR UPDATE`, keyID).Scan(&linked); err != nil {
+		return nil, fmt.Errorf("failed to check key link: %w", err)
+	}
+	if linked > 0 {
+		return nil, ErrKeyAlreadyLinked
+	}
+	if err := mysqlCheckRobloxUserFree(ctx, tx, robloxUserID, 0); err != nil {
+		return nil, err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO key_accounts (key_id, roblox_user_id, roblox_username, hwid, is_active)
+		VALUES (?, ?, ?, '', 1)`, keyID, robloxUserID, robloxUsername)
+	if err != nil {
+		return nil, mysqlProvisioningError("failed to create key account", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, mysqlProvisioningError("failed to commit key account", err)
+	}
+
+	return &KeyAccount{ID: id, KeyID: keyID, RobloxUserID: robloxUserID, RobloxUsername: robloxUsername, IsActive: true}, nil
+}
+
+// UpdateKeyAccount changes a key account's roblox user, username or active
+// status and returns it before and after the change. Moving an active
+// account to a roblox user that already has one fails with ErrRobloxUserLinked.
+func (r *MySQLKeyAccountRepository) UpdateKeyAccount(ctx context.Context, id int64, update KeyAccountUpdate) (*KeyAccount, *KeyAccount, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var before KeyAccount
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, key_id, roblox_user_id, roblox_username, is_active
+		FROM key_accounts WHERE id = ? FOR UPDATE`, id).Scan(
+		&before.ID, &before.KeyID, &before.RobloxUserID, &before.RobloxUsername, &before.IsActive)
+	if err == sql.ErrNoRows {
+		return nil, nil, fmt.Errorf("%w: id %d", ErrKeyAccountNotFound, id)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read key account: %w", err)
+	}
+
+	after := update.apply(before)
+	if after.IsActive && (after.RobloxUserID != before.RobloxUserID || !before.IsActive) {
+		if err := mysqlCheckRobloxUserFree(ctx, tx, after.RobloxUserID, id); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE key_accounts SET roblox_user_id = ?, roblox_username = ?, is_active = ? WHERE id = ?`,
+		after.RobloxUserID, after.RobloxUsername, after.IsActive, id)
+	if err != nil {
+		return nil, nil, mysqlProvisioningError("failed to update key account", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, mysqlProvisioningError("failed to commit key account", err)
+	}
+	return &before, &after, nil
+}
+
+// ResetHWID clears the HWID a key account is locked to, so the next token
+// request binds a new one. Returns the HWID cleared ("" if none was bound).
+func (r *MySQLKeyAccountRepository) ResetHWID(ctx context.Context, id int64) (string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var hwid string
+	err = tx.QueryRowContext(ctx, `SELECT hwid FROM key_accounts WHERE id = ? FOR UPDATE`, id).Scan(&hwid)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("%w: id %d", ErrKeyAccountNotFound, id)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read key account: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE key_accounts SET hwid = '' WHERE id = ?`, id); err != nil {
+		return "", fmt.Errorf("failed to reset hwid: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit hwid reset: %w", err)
+	}
+	return hwid, nil
+}
+
+// mysqlCheckRobloxUserFree fails with ErrRobloxUserLinked when another
+// active key account (other than exceptID) uses robloxUserID.
+func mysqlCheckRobloxUserFree(ctx context.Context, tx *sql.Tx, robloxUserID string, exceptID int64) error {
+	var count int
+	err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM key_accounts
+		WHERE roblox_user_id = ? AND is_active = 1 AND id <> ? FOR UPDATE`, robloxUserID, exceptID).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to check roblox user: %w", err)
+	}
+	if count > 0 {
+		return ErrRobloxUserLinked
+	}
+	return nil
+}
+
+// mysqlProvisioningError maps a duplicate-key error (a unique index won a
+// race the checks didn't see) to ErrRobloxUserLinked.
+func mysqlProvisioningError(msg string, err error) error {
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) && myErr.Number == 1062 {
+		return ErrRobloxUserLinked
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
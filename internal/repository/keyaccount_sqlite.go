@@ -0,0 +1,315 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SQLiteKeyAccountRepository is an embedded key account store with the same
+// behaviour as the MySQL one. Used by demo mode and local runs without MySQL.
+type SQLiteKeyAccountRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteKeyAccountRepository opens (or creates) a key account database.
+func NewSQLiteKeyAccountRepository(dbPath string) (*SQLiteKeyAccountRepository, error) {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	// Mirrors the columns of the MySQL keys/key_accounts tables that the API uses
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		key TEXT NOT NULL UNIQUE,
+		status TEXT NOT NULL DEFAULT 'active'
+	);
+
+	CREATE TABLE IF NOT EXISTS key_accounts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		key_id INTEGER NOT NULL REFERENCES keys(id),
+		roblox_user_id TEXT NOT NULL,
+		roblox_username TEXT NOT NULL DEFAULT '',
+		hwid TEXT NOT NULL DEFAULT '',
+		is_active INTEGER NOT NULL DEFAULT 1,
+		is_online INTEGER NOT NULL DEFAULT 0,
+		last_heartbeat_at DATETIME,
+		last_inventory_sync DATETIME,
+		inventory_item_count INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_key_accounts_roblox ON key_accounts(roblox_user_id);
+	`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create key account tables: %w", err)
+	}
+
+	return &SQLiteKeyAccountRepository{db: db}, nil
+}
+
+// CreateKeyAccount inserts a license key (if new) and a key account bound to it.
+func (r *SQLiteKeyAccountRepository) CreateKeyAccount(ctx context.Context, key, robloxUserID, robloxUsername string) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO keys (key) VALUES (?) ON CONFLICT(key) DO NOTHING`, key); err != nil {
+		return 0, fmt.Errorf("failed to create key: %w", err)
+	}
+	var keyID int64
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM keys WHERE key = ?`, key).Scan(&keyID); err != nil {
+		return 0, fmt.Errorf("failed to read key: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO key_accounts (key_id, roblox_user_id, roblox_username) VALUES (?, ?, ?)`,
+		keyID, robloxUserID, robloxUsername)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create key account: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// GetKeyAccountByRobloxUser finds key_account by roblox_user_id.
+func (r *SQLiteKeyAccountRepository) GetKeyAccountByRobloxUser(ctx context.Context, robloxUserID string) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id FROM key_accounts WHERE roblox_user_id = ? AND is_active = 1 LIMIT 1`, robloxUserID).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("%w for roblox user: %s", ErrKeyAccountNotFound, robloxUserID)
+		}
+		return 0, fmt.Errorf("failed to get key account: %w", err)
+	}
+	return id, nil
+}
+
+// ValidateKeyAccount checks if key_account_id exists and is active.
+func (r *SQLiteKeyAccountRepository) ValidateKeyAccount(ctx context.Context, keyAccountID int64) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM key_accounts WHERE id = ? AND is_active = 1`, keyAccountID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to validate key account: %w", err)
+	}
+	return count > 0, nil
+}
+
+// UpdateLastSync updates last_inventory_sync timestamp and item count.
+func (r *SQLiteKeyAccountRepository) UpdateLastSync(ctx context.Context, keyAccountID int64, itemCount int) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE key_accounts SET last_inventory_sync = ?, inventory_item_count = ? WHERE id = ?`,
+		time.Now().UTC(), itemCount, keyAccountID)
+	if err != nil {
+		return fmt.Errorf("failed to update last sync: %w", err)
+	}
+	return nil
+}
+
+// BatchUpdateLastSync records the last sync time and item count of many
+// key accounts in one transaction.
+func (r *SQLiteKeyAccountRepository) BatchUpdateLastSync(ctx context.Context, syncs []KeyAccountSync) error {
+	return batchUpdateLastSync(ctx, r.db, syncs)
+}
+
+// GetKeyAccountInfo returns key account details including key and user info.
+func (r *SQLiteKeyAccountRepository) GetKeyAccountInfo(ctx context.Context, keyAccountID int64) (map[string]interface{}, error) {
+	return keyAccountInfo(ctx, r.db, keyAccountID)
+}
+
+// UpdateHeartbeat marks key accounts online as of their latest heartbeat.
+func (r *SQLiteKeyAccountRepository) UpdateHeartbeat(ctx context.Context, beats []KeyAccountHeartbeat) error {
+	return batchUpdateHeartbeat(ctx, r.db, beats)
+}
+
+// MarkOffline marks accounts without a recent heartbeat offline.
+func (r *SQLiteKeyAccountRepository) MarkOffline(ctx context.Context, before time.Time) (int64, error) {
+	return markOffline(ctx, r.db, before)
+}
+
+// ValidateKeyAndHWID validates a key+hwid+roblox_id combination for token
+// generation, binding the HWID on first use like the MySQL repository.
+func (r *SQLiteKeyAccountRepository) ValidateKeyAndHWID(ctx context.Context, key, hwid, robloxUserID string) (*KeyAccountValidation, error) {
+	var result KeyAccountValidation
+	err := r.db.QueryRowContext(ctx, `
+		SELECT ka.id, ka.key_id, ka.roblox_user_id, ka.roblox_username, ka.hwid, k.status
+		FROM key_accounts ka
+		JOIN keys k ON ka.key_id = k.id
+		WHERE k.key = ? AND ka.roblox_user_id = ? AND ka.is_active = 1 AND LOWER(k.status) = 'active'
+		LIMIT 1`, key, robloxUserID).Scan(
+		&result.KeyAccountID,
+		&result.KeyID,
+		&result.RobloxUserID,
+		&result.RobloxUsername,
+		&result.HWID,
+		&result.KeyStatus,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("invalid key or account not found")
+		}
+		return nil, fmt.Errorf("failed to validate key: %w", err)
+	}
+
+	if result.HWID != "" && result.HWID != hwid {
+		return nil, fmt.Errorf("hwid mismatch")
+	}
+
+	if result.HWID == "" && hwid != "" {
+		if _, err := r.db.ExecContext(ctx, `UPDATE key_accounts SET hwid = ? WHERE id = ?`, hwid, result.KeyAccountID); err != nil {
+			return nil, fmt.Errorf("failed to bind hwid: %w", err)
+		}
+		result.HWID = hwid
+	}
+
+	return &result, nil
+}
+
+// CreateLinkedKeyAccount creates an active key account for an existing
+// license key, with the same checks as the MySQL repository.
+func (r *SQLiteKeyAccountRepository) CreateLinkedKeyAccount(ctx context.Context, key, robloxUserID, robloxUsername string) (*KeyAccount, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var keyID int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM keys WHERE key = ?`, key).Scan(&keyID)
+	if err == sql.ErrNoRows {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+
+	var linked int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM key_accounts WHERE key_id = ?`, keyID).Scan(&linked); err != nil {
+		return nil, fmt.Errorf("failed to check key link: %w", err)
+	}
+	if linked > 0 {
+		return nil, ErrKeyAlreadyLinked
+	}
+	if err := sqliteCheckRobloxUserFree(ctx, tx, robloxUserID, 0); err != nil {
+		return nil, err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO key_accounts (key_id, roblox_user_id, roblox_username) VALUES (?, ?, ?)`,
+		keyID, robloxUserID, robloxUsername)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key account: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &KeyAccount{ID: id, KeyID: keyID, RobloxUserID: robloxUserID, RobloxUsername: robloxUsername, IsActive: true}, nil
+}
+
+// UpdateKeyAccount changes a key account like the MySQL repository.
+func (r *SQLiteKeyAccountRepository) UpdateKeyAccount(ctx context.Context, id int64, update KeyAccountUpdate) (*KeyAccount, *KeyAccount, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var before KeyAccount
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, key_id, roblox_user_id, roblox_username, is_active
+		FROM key_accounts WHERE id = ?`, id).Scan(
+		&before.ID, &before.KeyID, &before.RobloxUserID, &before.RobloxUsername, &before.IsActive)
+	if err == sql.ErrNoRows {
+		return nil, nil, fmt.Errorf("%w: id %d", ErrKeyAccountNotFound, id)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read key account: %w", err)
+	}
+
+	after := update.apply(before)
+	if after.IsActive && (after.RobloxUserID != before.RobloxUserID || !before.IsActive) {
+		if err := sqliteCheckRobloxUserFree(ctx, tx, after.RobloxUserID, id); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE key_accounts SET roblox_user_id = ?, roblox_username = ?, is_active = ? WHERE id = ?`,
+		after.RobloxUserID, after.RobloxUsername, after.IsActive, id); err != nil {
+		return nil, nil, fmt.Errorf("failed to update key account: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return &before, &after, nil
+}
+
+// ResetHWID clears a key account's HWID like the MySQL repository.
+func (r *SQLiteKeyAccountRepository) ResetHWID(ctx context.Context, id int64) (string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var hwid string
+	err = tx.QueryRowContext(ctx, `SELECT hwid FROM key_accounts WHERE id = ?`, id).Scan(&hwid)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("%w: id %d", ErrKeyAccountNotFound, id)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read key account: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE key_accounts SET hwid = '' WHERE id = ?`, id); err != nil {
+		return "", fmt.Errorf("failed to reset hwid: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return hwid, nil
+}
+
+// sqliteCheckRobloxUserFree fails with ErrRobloxUserLinked when another
+// active key account (other than exceptID) uses robloxUserID.
+func sqliteCheckRobloxUserFree(ctx context.Context, tx *sql.Tx, robloxUserID string, exceptID int64) error {
+	var count int
+	err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM key_accounts WHERE roblox_user_id = ? AND is_active = 1 AND id <> ?`,
+		robloxUserID, exceptID).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to check roblox user: %w", err)
+	}
+	if count > 0 {
+		return ErrRobloxUserLinked
+	}
+	return nil
+}
+
+// Close closes the database connection.
+func (r *SQLiteKeyAccountRepository) Close() error {
+	return r.db.Close()
+}
+
+// Ensure both key account repositories implement KeyAccountAuthRepository
+// and KeyAccountProvisioner
+var (
+	_ KeyAccountAuthRepository = (*SQLiteKeyAccountRepository)(nil)
+	_ KeyAccountAuthRepository = (*MySQLKeyAccountRepository)(nil)
+	_ KeyAccountProvisioner    = (*SQLiteKeyAccountRepository)(nil)
+	_ KeyAccountProvisioner    = (*MySQLKeyAccountRepository)(nil)
+)
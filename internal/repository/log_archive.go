@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// logArchiveIndex lists the archive files, one JSON object per line.
+const logArchiveIndex = "index.ndjson"
+
+// LogArchiveFile describes one archive file.
+type LogArchiveFile struct {
+	File      string     `json:"file"` // Relative to the archive directory
+	Table     string     `json:"table"`
+	Rows      int64      `json:"rows"`
+	Bytes     int64      `json:"bytes"`
+	From      *time.Time `json:"from,omitempty"` // Oldest and newest row by the rule's time column
+	To        *time.Time `json:"to,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// LogArchive keeps rows pruned from auxiliary tables as gzipped NDJSON
+// files, <dir>/<YYYY-MM-DD>/<table>-<unix nanos>.ndjson.gz, one row per
+// line, and an index of them.
+type LogArchive struct {
+	dir string
+	mu  sync.Mutex // guards the index
+}
+
+// NewLogArchive opens (creating) the archive directory.
+func NewLogArchive(dir string) (*LogArchive, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log archive dir: %w", err)
+	}
+	return &LogArchive{dir: dir}, nil
+}
+
+// Dir returns the archive directory.
+func (a *LogArchive) Dir() string {
+	return a.dir
+}
+
+// archiveWriter writes one archive file.
+type archiveWriter struct {
+	info LogArchiveFile
+	path string
+	f    *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+}
+
+// create starts an archive file for rows of table.
+func (a *LogArchive) create(table string, now time.Time) (*archiveWriter, error) {
+	rel := filepath.Join(now.Format("2006-01-02"), fmt.Sprintf("%s-%d.ndjson.gz", table, now.UnixNano()))
+	path := filepath.Join(a.dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log archive dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log archive: %w", err)
+	}
+	gz := gzip.NewWriter(f)
+	enc := json.NewEncoder(gz)
+	enc.SetEscapeHTML(false)
+	return &archiveWriter{
+		info: LogArchiveFile{File: rel, Table: table, CreatedAt: now},
+		path: path,
+		f:    f,
+		gz:   gz,
+		enc:  enc,
+	}, nil
+}
+
+// write appends one row, widening the file's time range by timeColumn.
+func (w *archiveWriter) write(row map[string]interface{}, timeColumn string) error {
+	if err := w.enc.Encode(row); err != nil {
+		return fmt.Errorf("failed to write log archive: %w", err)
+	}
+	w.info.Rows++
+	if t, ok := archiveTime(row[timeColumn]); ok {
+		if w.info.From == nil || t.Before(*w.info.From) {
+			w.info.From = &t
+		}
+		if w.info.To == nil || t.After(*w.info.To) {
+			w.info.To = &t
+		}
+	}
+	return nil
+}
+
+// abort closes and removes a file that won't be finished.
+func (w *archiveWriter) abort() {
+	if w == nil {
+		return
+	}
+	w.gz.Close()
+	w.f.Close()
+	os.Remove(w.path)
+}
+
+// finish closes the file, reads it back to check it holds every row
+// written, and adds it to the index. The rows may be deleted once it
+// returns without error.
+func (a *LogArchive) finish(w *archiveWriter) (*LogArchiveFile, error) {
+	if err := w.gz.Close(); err != nil {
+		w.abort()
+		return nil, fmt.Errorf("failed to write log archive: %w", err)
+	}
+	if err := w.f.Sync(); err != nil {
+		w.abort()
+		return nil, fmt.Errorf("failed to write log archive: %w", err)
+	}
+	if err := w.f.Close(); err != nil {
+		os.Remove(w.path)
+		return nil, fmt.Errorf("failed to write log archive: %w", err)
+	}
+
+	var rows int64
+	err := ReadLogArchive(w.path, func(line []byte) error {
+		rows++
+		return nil
+	})
+	if err == nil && rows != w.info.Rows {
+		err = fmt.Errorf("archive holds %d rows, %d written", rows, w.info.Rows)
+	}
+	if err != nil {
+		os.Remove(w.path)
+		return nil, fmt.Errorf("failed to verify log archive %s: %w", w.info.File, err)
+	}
+	if st, err := os.Stat(w.path); err == nil {
+		w.info.Bytes = st.Size()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	index, err := os.OpenFile(filepath.Join(a.dir, logArchiveIndex), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update log archive index: %w", err)
+	}
+	defer index.Close()
+	if err := json.NewEncoder(index).Encode(w.info); err != nil {
+		return nil, fmt.Errorf("failed to update log archive index: %w", err)
+	}
+	return &w.info, nil
+}
+
+// List returns the archive files, oldest first.
+func (a *LogArchive) List() ([]LogArchiveFile, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.Open(filepath.Join(a.dir, logArchiveIndex))
+	if os.IsNotExist(err) {
+		return []LogArchiveFile{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log archive index: %w", err)
+	}
+	defer f.Close()
+
+	files := []LogArchiveFile{}
+	dec := json.NewDecoder(f)
+	for {
+		var file LogArchiveFile
+		if err := dec.Decode(&file); err == io.EOF {
+			return files, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read log archive index: %w", err)
+		}
+		files = append(files, file)
+	}
+}
+
+// ReadLogArchive calls fn with each row (a JSON line) of an archive file.
+func ReadLogArchive(path string, fn func(line []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// archiveTime reads a time column value as SQLite hands it back.
+func archiveTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC(), true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05", "2006-01-02"} {
+			if parsed, err := time.Parse(layout, t); err == nil {
+				return parsed.UTC(), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the versioned schema migrations, named
+// NNNN_description.sql. Version 1 is the baseline in Go (baselineSchema);
+// files start at 2. Each file runs in one transaction and is never edited
+// once released - change the schema with a new file.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migration is one schema version.
+type migration struct {
+	version int
+	name    string
+	sql     string              // Run in a transaction, or
+	run     func(*sql.DB) error // run outside one (the baseline)
+}
+
+// AppliedMigration is a row of schema_migrations.
+type AppliedMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// MigrationStatus reports a database file's schema version.
+type MigrationStatus struct {
+	File    string             `json:"file"`
+	Version int                `json:"version"`
+	Latest  int                `json:"latest"` // Newest version this binary knows
+	Applied []AppliedMigration `json:"applied"`
+}
+
+// migrations returns every known migration in version order.
+func migrations() ([]migration, error) {
+	list := []migration{{version: 1, name: "baseline", run: baselineSchema}}
+
+	paths, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		base := strings.TrimSuffix(filepath.Base(path), ".sql")
+		num, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil || version < 2 {
+			return nil, fmt.Errorf("migration %s must be named NNNN_name.sql with NNNN >= 2", path)
+		}
+		data, err := migrationFiles.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, migration{version: version, name: name, sql: string(data)})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].version < list[j].version })
+	for i := 1; i < len(list); i++ {
+		if list[i].version == list[i-1].version {
+			return nil, fmt.Errorf("duplicate migration version %d", list[i].version)
+		}
+	}
+	return list, nil
+}
+
+// LatestSchemaVersion returns the newest schema version this binary knows.
+func LatestSchemaVersion() int {
+	list, err := migrations()
+	if err != nil || len(list) == 0 {
+		return 0
+	}
+	return list[len(list)-1].version
+}
+
+// migrate brings the database up to the latest schema version, applying
+// pending migrations in order, and returns the versions it applied. A file
+// from a newer binary (a version this one doesn't know) is refused.
+func migrate(db *sql.DB, dbPath string, logger *slog.Logger) ([]int, error) {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)`); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	list, err := migrations()
+	if err != nil {
+		return nil, err
+	}
+	var current int
+	if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if latest := list[len(list)-1].version; current > latest {
+		return nil, fmt.Errorf("schema version %d is newer than this binary's %d; upgrade the binary", current, latest)
+	}
+
+	var applied []int
+	for _, m := range list {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return applied, fmt.Errorf("migration %04d_%s failed: %w", m.version, m.name, err)
+		}
+		logger.Info("Applied migration", "version", m.version, "name", m.name, "file", filepath.Base(dbPath))
+		applied = append(applied, m.version)
+	}
+	return applied, nil
+}
+
+// applyMigration runs one migration and records it.
+func applyMigration(db *sql.DB, m migration) error {
+	record := `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`
+	if m.run != nil {
+		if err := m.run(db); err != nil {
+			return err
+		}
+		_, err := db.Exec(record, m.version, m.name, time.Now().UTC())
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(m.sql); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(record, m.version, m.name, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrationStatus reports the file's schema version and applied migrations.
+func (r *SQLiteInventoryRepository) MigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT version, name, applied_at FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	status := &MigrationStatus{File: filepath.Base(r.path), Latest: LatestSchemaVersion(), Applied: []AppliedMigration{}}
+	for rows.Next() {
+		var m AppliedMigration
+		if err := rows.Scan(&m.Version, &m.Name, &m.AppliedAt); err != nil {
+			return nil, err
+		}
+		status.Applied = append(status.Applied, m)
+		status.Version = m.Version
+	}
+	return status, rows.Err()
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"bytes"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrationsLogThroughWithLogger(t *testing.T) {
+	var logs bytes.Buffer
+	repo, err := NewSQLiteInventoryRepository(filepath.Join(t.TempDir(), "inventory.db"),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	list, err := migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range list {
+		if !strings.Contains(logs.String(), "name="+m.name) {
+			t.Errorf("migration %04d_%s not logged:\n%s", m.version, m.name, logs.String())
+		}
+	}
+}
@@ -0,0 +1,5 @@
+-- Rows stored without a key account, counted on every admin stats call and
+-- purged by DELETE /api/v1/admin/unlinked. The WHERE clause must match the
+-- queries' for SQLite to use the index.
+CREATE INDEX IF NOT EXISTS idx_unlinked ON fishit_inventory_raw(id)
+	WHERE key_account_id = 0 OR key_account_id IS NULL;
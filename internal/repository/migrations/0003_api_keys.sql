@@ -0,0 +1,12 @@
+-- API keys managed through the admin API, checked alongside API_KEYS.
+-- Only the SHA-256 of a key is stored; scopes is a JSON array, empty for a
+-- full key. Revoked keys are kept for the audit trail.
+CREATE TABLE IF NOT EXISTS api_keys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	key_hash TEXT NOT NULL UNIQUE,
+	label TEXT NOT NULL DEFAULT '',
+	scopes TEXT NOT NULL DEFAULT '[]',
+	created_at DATETIME NOT NULL,
+	last_used_at DATETIME,
+	revoked_at DATETIME
+);
@@ -0,0 +1,13 @@
+-- Flush side-effect batches waiting for retry (Open Cloud callbacks and
+-- the other best-effort stages), so a restart doesn't lose them. items is
+-- the JSON-encoded batch; rows are deleted once the side effect succeeds
+-- or gives up, and the rest are retried on startup.
+CREATE TABLE IF NOT EXISTS side_effect_outbox (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	effect TEXT NOT NULL,
+	items TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_side_effect_outbox_effect ON side_effect_outbox(effect, id);
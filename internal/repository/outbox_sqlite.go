@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// OutboxBatch is a flush side-effect batch waiting for retry.
+type OutboxBatch struct {
+	ID        int64
+	Effect    string
+	Items     []InventoryItem
+	Attempts  int
+	LastError string
+	CreatedAt time.Time
+}
+
+// InsertOutboxBatch stores a batch and returns its ID.
+func (r *SQLiteInventoryRepository) InsertOutboxBatch(ctx context.Context, batch *OutboxBatch) (int64, error) {
+	items, err := json.Marshal(batch.Items)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode outbox items: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO side_effect_outbox (effect, items, attempts, last_error, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		batch.Effect, string(items), batch.Attempts, batch.LastError, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert outbox batch: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// UpdateOutboxBatch records another failed attempt of a stored batch.
+func (r *SQLiteInventoryRepository) UpdateOutboxBatch(ctx context.Context, id int64, attempts int, lastError string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE side_effect_outbox SET attempts = ?, last_error = ? WHERE id = ?`,
+		attempts, lastError, id)
+	if err != nil {
+		return fmt.Errorf("failed to update outbox batch: %w", err)
+	}
+	return nil
+}
+
+// DeleteOutboxBatch removes a batch that succeeded or was given up on.
+func (r *SQLiteInventoryRepository) DeleteOutboxBatch(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM side_effect_outbox WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete outbox batch: %w", err)
+	}
+	return nil
+}
+
+// ListOutboxBatches returns every stored batch, oldest first.
+func (r *SQLiteInventoryRepository) ListOutboxBatches(ctx context.Context) ([]*OutboxBatch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, effect, items, attempts, last_error, created_at
+		FROM side_effect_outbox ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var batches []*OutboxBatch
+	for rows.Next() {
+		var (
+			batch OutboxBatch
+			items string
+		)
+		if err := rows.Scan(&batch.ID, &batch.Effect, &items, &batch.Attempts, &batch.LastError, &batch.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox batch: %w", err)
+		}
+		if err := json.Unmarshal([]byte(items), &batch.Items); err != nil {
+			return nil, fmt.Errorf("failed to decode outbox batch %d: %w", batch.ID, err)
+		}
+		batches = append(batches, &batch)
+	}
+	return batches, rows.Err()
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOutboxRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewSQLiteInventoryRepository(filepath.Join(t.TempDir(), "inventory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	synced := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	items := []InventoryItem{{KeyAccountID: 7, RobloxUserID: "100", Section: "settings", RawJSON: []byte(`{"a":1}`), SyncedAt: synced, Callback: true}}
+	first, err := repo.InsertOutboxBatch(ctx, &OutboxBatch{Effect: "opencloud_callback", Items: items, Attempts: 1, LastError: "429"})
+	if err != nil {
+		t.Fatalf("InsertOutboxBatch: %v", err)
+	}
+	second, _ := repo.InsertOutboxBatch(ctx, &OutboxBatch{Effect: "key_account_sync", Items: items})
+
+	if err := repo.UpdateOutboxBatch(ctx, first, 2, "timeout"); err != nil {
+		t.Fatalf("UpdateOutboxBatch: %v", err)
+	}
+	batches, err := repo.ListOutboxBatches(ctx)
+	if err != nil || len(batches) != 2 {
+		t.Fatalf("ListOutboxBatches = %d, %v; want 2", len(batches), err)
+	}
+	got := batches[0]
+	if got.ID != first || got.Effect != "opencloud_callback" || got.Attempts != 2 || got.LastError != "timeout" {
+		t.Errorf("first batch = %+v", got)
+	}
+	if len(got.Items) != 1 || string(got.Items[0].RawJSON) != `{"a":1}` || !got.Items[0].SyncedAt.Equal(synced) || !got.Items[0].Callback {
+		t.Errorf("items = %+v, want them as stored", got.Items)
+	}
+
+	if err := repo.DeleteOutboxBatch(ctx, first); err != nil {
+		t.Fatalf("DeleteOutboxBatch: %v", err)
+	}
+	batches, _ = repo.ListOutboxBatches(ctx)
+	if len(batches) != 1 || batches[0].ID != second {
+		t.Errorf("after delete: %+v, want only batch %d", batches, second)
+	}
+}
@@ -0,0 +1,35 @@
+package repository
+
+import "strings"
+
+// PageQuery selects one page of a listing ordered by ID, newest first.
+type PageQuery struct {
+	Limit int
+
+	// BeforeID continues after a previous page: only rows with a lower ID
+	// are returned. 0 starts at the newest row.
+	BeforeID int64
+
+	// Offset skips rows. Deprecated: kept for one release for old clients;
+	// ignored when BeforeID is set.
+	Offset int
+}
+
+// apply appends the keyset predicate and the ORDER/LIMIT clause to a query
+// whose WHERE conditions are in where.
+func (p PageQuery) apply(query string, where []string, args []interface{}) (string, []interface{}) {
+	if p.BeforeID > 0 {
+		where = append(where, "id < ?")
+		args = append(args, p.BeforeID)
+	}
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, p.Limit)
+	if p.BeforeID == 0 && p.Offset > 0 {
+		query += " OFFSET ?"
+		args = append(args, p.Offset)
+	}
+	return query, args
+}
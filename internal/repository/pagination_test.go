@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeysetPagesSurviveInserts(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewSQLiteInventoryRepository(filepath.Join(t.TempDir(), "inventory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	insert := func(n int) {
+		for i := 0; i < n; i++ {
+			if err := repo.InsertAudit(ctx, &AuditEntry{Actor: "api_key", Action: "test", Target: "key_account:1"}, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	insert(7)
+
+	first, err := repo.ListAudit(ctx, "", PageQuery{Limit: 3})
+	if err != nil || len(first) != 3 {
+		t.Fatalf("first page = %d, %v; want 3", len(first), err)
+	}
+	insert(2) // Newer rows land between requests
+
+	seen := map[int64]bool{}
+	for _, e := range first {
+		seen[e.ID] = true
+	}
+	before := first[len(first)-1].ID
+	for {
+		page, err := repo.ListAudit(ctx, "", PageQuery{Limit: 3, BeforeID: before})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, e := range page {
+			if seen[e.ID] {
+				t.Errorf("entry %d listed twice", e.ID)
+			}
+			if e.ID >= before {
+				t.Errorf("entry %d not below the cursor %d", e.ID, before)
+			}
+			seen[e.ID] = true
+		}
+		before = page[len(page)-1].ID
+	}
+	if len(seen) != 7 {
+		t.Errorf("paged through %d entries, want the 7 present at the first page", len(seen))
+	}
+
+	// The deprecated offset still works when no cursor is given
+	page, _ := repo.ListAudit(ctx, "", PageQuery{Limit: 2, Offset: 8})
+	if len(page) != 1 {
+		t.Errorf("offset page = %d entries, want 1", len(page))
+	}
+}
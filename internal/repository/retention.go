@@ -0,0 +1,425 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"vinzhub-rest-api/internal/lifecycle"
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/metrics"
+)
+
+// retentionMaxBatchesPerRule bounds how many batches one rule may delete in
+// a single run, so a large backlog is worked off over several runs.
+const retentionMaxBatchesPerRule = 20
+
+var retentionDeleted = metrics.NewCounterVec("vinzhub_retention_deleted_total",
+	"Rows deleted by retention rules.", "table")
+
+var retentionArchived = metrics.NewCounterVec("vinzhub_retention_archived_total",
+	"Rows written to the log archive before retention deleted them.", "table")
+
+// RetentionRule is the pruning rule of one auxiliary table. Set MaxAge to
+// delete rows whose TimeColumn is older than it, KeepPerUser to keep only
+// the newest N rows per UserColumn (ordered by OrderColumn), or both.
+type RetentionRule struct {
+	Table string
+
+	TimeColumn string
+	MaxAge     time.Duration
+
+	UserColumn  string
+	OrderColumn string // Defaults to "id"
+	KeepPerUser int
+
+	// Where optionally limits the rule to matching rows, e.g. "status = 'resolved'"
+	Where string
+
+	// RowKey identifies rows for tables created WITHOUT ROWID, e.g.
+	// "day, client_version, path". Defaults to "rowid"
+	RowKey string
+
+	// Archive writes rows to the log archive before deleting them, when the
+	// engine has one (see SetArchive). Tables keyed by rowid only
+	Archive bool
+}
+
+// auxiliaryRetentionRules lists the rules of the tables kept in the primary
+// database. Tables add their rule here next to their definition.
+func auxiliaryRetentionRules() []RetentionRule {
+	return []RetentionRule{
+		flushLogRetentionRule,
+		integrityRetentionRule,
+		auditRetentionRule,
+		schemaProfileRetentionRule,
+		schemaSamplesRetentionRule,
+		flagRetentionRule,
+	}
+}
+
+// RetentionEngine runs every table's pruning rule in short, bounded delete
+// transactions so SQLite writers are never blocked for long.
+type RetentionEngine struct {
+	repo      *SQLiteInventoryRepository
+	batchSize int
+	busy      func() bool
+	archive   *LogArchive
+	logger    *slog.Logger
+
+	runMu sync.Mutex // serializes runs
+	mu    sync.Mutex // guards rules and stats
+	rules []RetentionRule
+	stats map[string]*retentionTableStats
+
+	lastRunAt time.Time
+	runs      int64
+	skipped   int64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+type retentionTableStats struct {
+	Deleted   int64     `json:"deleted"`
+	Archived  int64     `json:"archived,omitempty"`
+	LastRunAt time.Time `json:"last_run_at"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// NewRetentionEngine creates an engine over the primary database with the
+// auxiliary table rules registered.
+func NewRetentionEngine(repo *SQLiteInventoryRepository, batchSize int) *RetentionEngine {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	e := &RetentionEngine{
+		repo:      repo,
+		batchSize: batchSize,
+		stats:     make(map[string]*retentionTableStats),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		logger:    logging.Component("Retention"),
+	}
+	for _, rule := range auxiliaryRetentionRules() {
+		e.Register(rule)
+	}
+	return e
+}
+
+// Register adds a table's pruning rule.
+func (e *RetentionEngine) Register(rule RetentionRule) {
+	if rule.OrderColumn == "" {
+		rule.OrderColumn = "id"
+	}
+	if rule.RowKey == "" {
+		rule.RowKey = "rowid"
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = append(e.rules, rule)
+	if _, ok := e.stats[rule.Table]; !ok {
+		e.stats[rule.Table] = &retentionTableStats{}
+	}
+}
+
+// SetBusyFunc sets the check that makes scheduled runs skip, e.g.
+// FlushPipeline.Active.
+func (e *RetentionEngine) SetBusyFunc(busy func() bool) {
+	e.busy = busy
+}
+
+// SetArchive keeps the rows of rules with Archive set in archive files
+// before they are deleted.
+func (e *RetentionEngine) SetArchive(archive *LogArchive) {
+	e.archive = archive
+}
+
+// SetLogger replaces the engine's logger.
+func (e *RetentionEngine) SetLogger(logger *slog.Logger) {
+	e.logger = logger
+}
+
+// Archive returns the log archive, or nil.
+func (e *RetentionEngine) Archive() *LogArchive {
+	return e.archive
+}
+
+// Start runs every rule each interval until Close.
+func (e *RetentionEngine) Start(interval time.Duration) {
+	lifecycle.Go("retention", func() {
+		e.loop(interval)
+		close(e.done) // Not deferred: a panicking loop is restarted
+	})
+	e.logger.Info("Started", "interval", interval, "batch", e.batchSize, "rules", len(e.rules))
+}
+
+// loop runs every rule each interval until Close.
+func (e *RetentionEngine) loop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			lifecycle.Touch("retention")
+			if e.busy != nil && e.busy() {
+				e.mu.Lock()
+				e.skipped++
+				e.mu.Unlock()
+				continue
+			}
+			if _, err := e.Run(context.Background()); err != nil {
+				e.logger.Error("Run failed", "error", err)
+			}
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// Close stops the background loop and waits for a running pass.
+func (e *RetentionEngine) Close() {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+		<-e.done
+	})
+}
+
+// Run executes every rule once and returns rows deleted per table. A failing
+// rule is recorded and doesn't stop the others; the first error is returned.
+func (e *RetentionEngine) Run(ctx context.Context) (map[string]int64, error) {
+	e.runMu.Lock()
+	defer e.runMu.Unlock()
+
+	e.mu.Lock()
+	rules := append([]RetentionRule(nil), e.rules...)
+	e.mu.Unlock()
+
+	now := time.Now().UTC()
+	deleted := make(map[string]int64, len(rules))
+	var firstErr error
+	for _, rule := range rules {
+		n, archived, err := e.apply(ctx, rule, now)
+		deleted[rule.Table] += n
+
+		e.mu.Lock()
+		st := e.stats[rule.Table]
+		st.Deleted += n
+		st.Archived += archived
+		st.LastRunAt = now
+		st.LastError = ""
+		if err != nil {
+			st.LastError = err.Error()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("retention for %s: %w", rule.Table, err)
+			}
+		}
+		e.mu.Unlock()
+		if n > 0 {
+			retentionDeleted.Add(n, rule.Table)
+		}
+		if archived > 0 {
+			retentionArchived.Add(archived, rule.Table)
+		}
+	}
+	e.mu.Lock()
+	e.runs++
+	e.lastRunAt = now
+	e.mu.Unlock()
+	return deleted, firstErr
+}
+
+// apply deletes rows matching one rule, batchSize rows per transaction,
+// archiving them first if the rule asks for it. Returns rows deleted and
+// rows archived.
+func (e *RetentionEngine) apply(ctx context.Context, rule RetentionRule, now time.Time) (int64, int64, error) {
+	var total, archived int64
+	for _, query := range retentionQueries(rule) {
+		args := []interface{}{}
+		if rule.MaxAge > 0 && query.byAge {
+			args = append(args, now.Add(-rule.MaxAge))
+		}
+
+		if rule.Archive && e.archive != nil && rule.RowKey == "rowid" {
+			if e.busy != nil && e.busy() {
+				return total, archived, nil
+			}
+			n, err := e.archiveAndDelete(ctx, rule, query, args, now)
+			total += n
+			archived += n
+			if err != nil {
+				return total, archived, err
+			}
+			continue
+		}
+
+		args = append(args, e.batchSize)
+		for i := 0; i < retentionMaxBatchesPerRule; i++ {
+			if e.busy != nil && e.busy() {
+				return total, archived, nil // Yield to the flush; the rest waits for the next run
+			}
+			n, err := e.repo.deleteBatch(ctx, query.deleteSQL(), args...)
+			total += n
+			if err != nil {
+				return total, archived, err
+			}
+			if n < int64(e.batchSize) {
+				break
+			}
+		}
+	}
+	return total, archived, nil
+}
+
+// archiveAndDelete writes up to one run's worth of a query's rows to an
+// archive file, checks the file holds every row, and only then deletes the
+// rows, batchSize per transaction. Rows whose delete fails are archived
+// again by the next run.
+func (e *RetentionEngine) archiveAndDelete(ctx context.Context, rule RetentionRule, query retentionQuery, args []interface{}, now time.Time) (int64, error) {
+	limit := e.batchSize * retentionMaxBatchesPerRule
+	rows, err := e.repo.db.QueryContext(ctx,
+		fmt.Sprintf(`SELECT rowid, * FROM %s WHERE rowid IN (%s)`, rule.Table, query.selectSQL),
+		append(args, limit)...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	var (
+		w      *archiveWriter
+		rowids []interface{}
+	)
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			w.abort()
+			return 0, err
+		}
+		if w == nil {
+			if w, err = e.archive.create(rule.Table, now); err != nil {
+				return 0, err
+			}
+		}
+		record := make(map[string]interface{}, len(columns)-1)
+		for i, col := range columns[1:] {
+			v := values[i+1]
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			record[col] = v
+		}
+		if err := w.write(record, rule.TimeColumn); err != nil {
+			w.abort()
+			return 0, err
+		}
+		rowids = append(rowids, values[0])
+	}
+	if err := rows.Err(); err != nil {
+		w.abort()
+		return 0, err
+	}
+	rows.Close()
+	if w == nil {
+		return 0, nil // Nothing aged out
+	}
+
+	if _, err := e.archive.finish(w); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for start := 0; start < len(rowids); start += e.batchSize {
+		chunk := rowids[start:min(start+e.batchSize, len(rowids))]
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(chunk)), ",")
+		n, err := e.repo.deleteBatch(ctx,
+			fmt.Sprintf(`DELETE FROM %s WHERE rowid IN (%s)`, rule.Table, placeholders), chunk...)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+type retentionQuery struct {
+	table     string
+	key       string
+	selectSQL string // Picks the keys of at most LIMIT ? rows
+	byAge     bool
+}
+
+// deleteSQL deletes the rows the query picks.
+func (q retentionQuery) deleteSQL() string {
+	return fmt.Sprintf(`DELETE FROM %s WHERE (%s) IN (%s)`, q.table, q.key, q.selectSQL)
+}
+
+// retentionQueries builds the batch statements of a rule. Each one picks at
+// most LIMIT ? rows by RowKey.
+func retentionQueries(rule RetentionRule) []retentionQuery {
+	where := "1=1"
+	if rule.Where != "" {
+		where = rule.Where
+	}
+
+	var queries []retentionQuery
+	if rule.MaxAge > 0 && rule.TimeColumn != "" {
+		queries = append(queries, retentionQuery{table: rule.Table, key: rule.RowKey, byAge: true, selectSQL: fmt.Sprintf(
+			`SELECT %[4]s FROM %[1]s WHERE (%[2]s) AND %[3]s < ? LIMIT ?`,
+			rule.Table, where, rule.TimeColumn, rule.RowKey)})
+	}
+	if rule.KeepPerUser > 0 && rule.UserColumn != "" {
+		queries = append(queries, retentionQuery{table: rule.Table, key: rule.RowKey, selectSQL: fmt.Sprintf(
+			`SELECT %[6]s FROM (
+					SELECT %[6]s, ROW_NUMBER() OVER (PARTITION BY %[3]s ORDER BY %[4]s DESC) AS rn
+					FROM %[1]s WHERE (%[2]s)
+				) WHERE rn > %[5]d LIMIT ?`,
+			rule.Table, where, rule.UserColumn, rule.OrderColumn, rule.KeepPerUser, rule.RowKey)})
+	}
+	return queries
+}
+
+// deleteBatch runs one bounded delete in its own short write transaction.
+func (r *SQLiteInventoryRepository) deleteBatch(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Stats returns per-table counters for admin stats.
+func (e *RetentionEngine) Stats(ctx context.Context) map[string]interface{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	tables := make(map[string]interface{}, len(e.stats))
+	for table, st := range e.stats {
+		tables[table] = *st
+	}
+	stats := map[string]interface{}{
+		"runs":         e.runs,
+		"skipped_busy": e.skipped,
+		"batch_size":   e.batchSize,
+		"tables":       tables,
+	}
+	if !e.lastRunAt.IsZero() {
+		stats["last_run_at"] = e.lastRunAt
+	}
+	return stats
+}
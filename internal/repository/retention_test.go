@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newRetentionFixture returns an engine with no rules over a database
+// holding an empty fake_history table.
+func newRetentionFixture(t *testing.T, batchSize int) (*SQLiteInventoryRepository, *RetentionEngine) {
+	t.Helper()
+	repo, err := NewSQLiteInventoryRepository(filepath.Join(t.TempDir(), "inventory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	if _, err := repo.db.Exec(`CREATE TABLE fake_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	)`); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewRetentionEngine(repo, batchSize)
+	e.rules = nil // Only the rules under test
+	return repo, e
+}
+
+// insertHistory adds n rows for user created at at.
+func insertHistory(t *testing.T, repo *SQLiteInventoryRepository, user string, n int, at time.Time) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := repo.db.Exec(`INSERT INTO fake_history (user_id, created_at) VALUES (?, ?)`, user, at.UTC()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func countHistory(t *testing.T, repo *SQLiteInventoryRepository, where string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := repo.db.QueryRow(`SELECT COUNT(*) FROM fake_history WHERE `+where, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestRetentionMaxAgeIsBatchBounded(t *testing.T) {
+	ctx := context.Background()
+	repo, e := newRetentionFixture(t, 5)
+	e.Register(RetentionRule{Table: "fake_history", TimeColumn: "created_at", MaxAge: time.Hour})
+
+	old := time.Now().Add(-2 * time.Hour)
+	perRun := 5 * retentionMaxBatchesPerRule
+	insertHistory(t, repo, "100", perRun+7, old)
+	insertHistory(t, repo, "100", 3, time.Now())
+
+	deleted, err := e.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// One run stops after retentionMaxBatchesPerRule batches
+	if deleted["fake_history"] != int64(perRun) {
+		t.Errorf("first run deleted %d, want %d", deleted["fake_history"], perRun)
+	}
+	deleted, _ = e.Run(ctx)
+	if deleted["fake_history"] != 7 {
+		t.Errorf("second run deleted %d, want the remaining 7", deleted["fake_history"])
+	}
+	if n := countHistory(t, repo, "1=1"); n != 3 {
+		t.Errorf("%d rows left, want the 3 recent ones", n)
+	}
+
+	stats := e.Stats(ctx)
+	table := stats["tables"].(map[string]interface{})["fake_history"].(retentionTableStats)
+	if table.Deleted != int64(perRun+7) || stats["runs"] != int64(2) {
+		t.Errorf("stats = %+v, runs %v; want %d deleted over 2 runs", table, stats["runs"], perRun+7)
+	}
+}
+
+func TestRetentionKeepPerUser(t *testing.T) {
+	ctx := context.Background()
+	repo, e := newRetentionFixture(t, 2)
+	e.Register(RetentionRule{Table: "fake_history", UserColumn: "user_id", KeepPerUser: 3})
+
+	insertHistory(t, repo, "100", 8, time.Now())
+	insertHistory(t, repo, "200", 2, time.Now())
+	var newest []int64
+	rows, err := repo.db.Query(`SELECT id FROM fake_history WHERE user_id = '100' ORDER BY id DESC LIMIT 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var id int64
+		rows.Scan(&id)
+		newest = append(newest, id)
+	}
+	rows.Close()
+
+	if _, err := e.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if n := countHistory(t, repo, "user_id = '100'"); n != 3 {
+		t.Errorf("user 100 has %d rows, want 3", n)
+	}
+	if n := countHistory(t, repo, "user_id = '100' AND id >= ?", newest[len(newest)-1]); n != 3 {
+		t.Error("user 100 kept older rows instead of the newest 3")
+	}
+	if n := countHistory(t, repo, "user_id = '200'"); n != 2 {
+		t.Errorf("user 200 has %d rows, want its 2 untouched", n)
+	}
+}
+
+func TestRetentionYieldsWhileBusy(t *testing.T) {
+	ctx := context.Background()
+	repo, e := newRetentionFixture(t, 5)
+	e.Register(RetentionRule{Table: "fake_history", TimeColumn: "created_at", MaxAge: time.Hour})
+	insertHistory(t, repo, "100", 4, time.Now().Add(-2*time.Hour))
+
+	busy := true
+	e.SetBusyFunc(func() bool { return busy })
+	if deleted, _ := e.Run(ctx); deleted["fake_history"] != 0 {
+		t.Errorf("deleted %d while a flush was active, want 0", deleted["fake_history"])
+	}
+	busy = false
+	if deleted, _ := e.Run(ctx); deleted["fake_history"] != 4 {
+		t.Errorf("deleted %d once idle, want 4", deleted["fake_history"])
+	}
+}
+
+func TestRetentionFailingRuleDoesNotStopOthers(t *testing.T) {
+	ctx := context.Background()
+	repo, e := newRetentionFixture(t, 5)
+	e.Register(RetentionRule{Table: "missing_table", TimeColumn: "created_at", MaxAge: time.Hour})
+	e.Register(RetentionRule{Table: "fake_history", TimeColumn: "created_at", MaxAge: time.Hour})
+	insertHistory(t, repo, "100", 2, time.Now().Add(-2*time.Hour))
+
+	deleted, err := e.Run(ctx)
+	if err == nil || !strings.Contains(err.Error(), "missing_table") {
+		t.Errorf("Run err = %v, want the missing table's failure", err)
+	}
+	if deleted["fake_history"] != 2 {
+		t.Errorf("fake_history deleted %d, want 2", deleted["fake_history"])
+	}
+	tables := e.Stats(ctx)["tables"].(map[string]interface{})
+	if st := tables["missing_table"].(retentionTableStats); st.LastError == "" {
+		t.Error("missing_table stats carry no last_error")
+	}
+}
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// schemaProfileRetentionRule keeps 90 days of payload schema profiles.
+// day is "YYYY-MM-DD", which sorts before the cutoff timestamp of that day.
+var schemaProfileRetentionRule = RetentionRule{
+	Table:      "schema_profile",
+	TimeColumn: "day",
+	MaxAge:     90 * 24 * time.Hour,
+	RowKey:     "day, client_version, path", // WITHOUT ROWID
+}
+
+var schemaSamplesRetentionRule = RetentionRule{
+	Table:      "schema_samples",
+	TimeColumn: "day",
+	MaxAge:     90 * 24 * time.Hour,
+}
+
+// JSON types a schema path was seen with, stored as a bitmask.
+const (
+	SchemaTypeNull = 1 << iota
+	SchemaTypeBool
+	SchemaTypeNumber
+	SchemaTypeString
+	SchemaTypeArray
+	SchemaTypeObject
+)
+
+var schemaTypeNames = []string{"null", "bool", "number", "string", "array", "object"}
+
+// SchemaTypeNames lists the type names of a type bitmask.
+func SchemaTypeNames(mask int) []string {
+	names := []string{}
+	for i, name := range schemaTypeNames {
+		if mask&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// SchemaPathStat is how often a path was present in sampled payloads.
+type SchemaPathStat struct {
+	Types int   // Bitmask of SchemaType*
+	Seen  int64 // Samples the path was present in
+}
+
+// SchemaProfile is the field-frequency profile of the payloads sampled on
+// one day, for one client version or all of them.
+type SchemaProfile struct {
+	Day           string // YYYY-MM-DD (UTC)
+	ClientVersion string // Empty for all versions
+	Samples       int64
+	Paths         map[string]SchemaPathStat
+	Versions      map[string]int64 // Samples per client version
+}
+
+// Presence returns the fraction of samples a path was present in.
+func (p *SchemaProfile) Presence(path string) float64 {
+	if p.Samples == 0 {
+		return 0
+	}
+	return float64(p.Paths[path].Seen) / float64(p.Samples)
+}
+
+// createSchemaProfileTables creates the schema profile tables: one row per
+// (day, client version, path), plus the sample count they are relative to.
+func createSchemaProfileTables(db *sql.DB) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_samples (
+		day TEXT NOT NULL,
+		client_version TEXT NOT NULL,
+		samples INTEGER NOT NULL,
+		PRIMARY KEY (day, client_version)
+	);
+	CREATE TABLE IF NOT EXISTS schema_profile (
+		day TEXT NOT NULL,
+		client_version TEXT NOT NULL,
+		path TEXT NOT NULL,
+		types INTEGER NOT NULL,
+		seen INTEGER NOT NULL,
+		PRIMARY KEY (day, client_version, path)
+	) WITHOUT ROWID;
+	`)
+	return err
+}
+
+// MergeSchemaProfile adds a profile of one day and client version to the
+// stored one.
+func (r *SQLiteInventoryRepository) MergeSchemaProfile(ctx context.Context, p *SchemaProfile) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO schema_samples (day, client_version, samples) VALUES (?, ?, ?)
+		ON CONFLICT(day, client_version) DO UPDATE SET samples = samples + excluded.samples`,
+		p.Day, p.ClientVersion, p.Samples); err != nil {
+		return fmt.Errorf("failed to merge schema samples: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO schema_profile (day, client_version, path, types, seen) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(day, client_version, path) DO UPDATE SET
+			types = types | excluded.types,
+			seen = seen + excluded.seen`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for path, stat := range p.Paths {
+		if _, err := stmt.ExecContext(ctx, p.Day, p.ClientVersion, path, stat.Types, stat.Seen); err != nil {
+			return fmt.Errorf("failed to merge schema path %s: %w", path, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetSchemaProfile returns the stored profile of a day. An empty client
+// version combines every version.
+func (r *SQLiteInventoryRepository) GetSchemaProfile(ctx context.Context, day, clientVersion string) (*SchemaProfile, error) {
+	p := &SchemaProfile{Day: day, ClientVersion: clientVersion, Paths: map[string]SchemaPathStat{}, Versions: map[string]int64{}}
+
+	where, args := "day = ?", []interface{}{day}
+	if clientVersion != "" {
+		where += " AND client_version = ?"
+		args = append(args, clientVersion)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT client_version, samples FROM schema_samples WHERE `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema samples: %w", err)
+	}
+	for rows.Next() {
+		var (
+			version string
+			samples int64
+		)
+		if err := rows.Scan(&version, &samples); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan schema samples: %w", err)
+		}
+		p.Versions[version] = samples
+		p.Samples += samples
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.db.QueryContext(ctx, `SELECT path, types, seen FROM schema_profile WHERE `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema profile: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			path string
+			stat SchemaPathStat
+		)
+		if err := rows.Scan(&path, &stat.Types, &stat.Seen); err != nil {
+			return nil, fmt.Errorf("failed to scan schema profile: %w", err)
+		}
+		// Combine versions: types accumulate, presence adds up
+		merged := p.Paths[path]
+		merged.Types |= stat.Types
+		merged.Seen += stat.Seen
+		p.Paths[path] = merged
+	}
+	return p, rows.Err()
+}
@@ -0,0 +1,415 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// PrimaryDBName is the main database file. In sharded mode it keeps the
+	// shard metadata and auxiliary tables while inventory rows live in shards.
+	PrimaryDBName = "inventory.db"
+
+	// shardCountMetaKey records the shard count chosen at first initialization.
+	shardCountMetaKey = "shard_count"
+)
+
+// ShardFileName returns the file name of shard i, e.g. inventory_03.db.
+func ShardFileName(i int) string {
+	return fmt.Sprintf("inventory_%02d.db", i)
+}
+
+// ShardFor returns the shard index for a Roblox user. The hash is stable
+// across restarts and versions; changing it requires a reshard.
+func ShardFor(robloxUserID string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(robloxUserID))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// ReadShardCount returns the shard count recorded in the primary database,
+// or 0 when the data directory is unsharded.
+func ReadShardCount(ctx context.Context, primary *SQLiteInventoryRepository) (int, error) {
+	value, ok, err := primary.GetMeta(ctx, shardCountMetaKey)
+	if err != nil || !ok {
+		return 0, err
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid recorded shard count %q: %w", value, err)
+	}
+	return n, nil
+}
+
+// WriteShardCount records the shard count in the primary database.
+func WriteShardCount(ctx context.Context, primary *SQLiteInventoryRepository, shards int) error {
+	return primary.SetMeta(ctx, shardCountMetaKey, strconv.Itoa(shards))
+}
+
+// OpenInventoryStore opens the inventory store in dataDir. shards == 0 uses
+// the single inventory.db file; shards > 0 uses hash-sharded files. The shard
+// count is fixed at first initialization: opening with a different count
+// than the one recorded fails and points at the reshard command.
+// The primary repository is returned as well for auxiliary tables.
+func OpenInventoryStore(dataDir string, shards int, opts ...SQLiteOption) (InventoryStore, *SQLiteInventoryRepository, error) {
+	if shards < 0 {
+		return nil, nil, fmt.Errorf("invalid shard count %d", shards)
+	}
+
+	primary, err := NewSQLiteInventoryRepository(filepath.Join(dataDir, PrimaryDBName), opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := context.Background()
+	recorded, err := ReadShardCount(ctx, primary)
+	if err != nil {
+		primary.Close()
+		return nil, nil, err
+	}
+
+	if recorded == 0 && shards > 0 {
+		// First sharded start. Refuse if the primary already holds rows,
+		// otherwise they would silently disappear from reads.
+		var existing int64
+		if err := primary.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM fishit_inventory_raw").Scan(&existing); err != nil {
+			primary.Close()
+			return nil, nil, fmt.Errorf("failed to count inventories: %w", err)
+		}
+		if existing > 0 {
+			primary.Close()
+			return nil, nil, fmt.Errorf("%s holds %d unsharded rows; run `api reshard --to %d` to enable sharding", PrimaryDBName, existing, shards)
+		}
+		if err := WriteShardCount(ctx, primary, shards); err != nil {
+			primary.Close()
+			return nil, nil, err
+		}
+		recorded = shards
+	}
+
+	if recorded != shards {
+		primary.Close()
+		return nil, nil, fmt.Errorf("data directory was initialized with %d shards but %d are configured; run `api reshard --to %d` or set SQLITE_SHARDS=%d", recorded, shards, shards, recorded)
+	}
+
+	if shards == 0 {
+		return primary, primary, nil
+	}
+
+	sharded, err := openShards(dataDir, shards, primary, opts...)
+	if err != nil {
+		primary.Close()
+		return nil, nil, err
+	}
+	return sharded, primary, nil
+}
+
+// OpenRecordedInventoryStore opens dataDir with whatever shard count it was
+// initialized with. Used by offline tooling that shouldn't need the config.
+func OpenRecordedInventoryStore(dataDir string) (InventoryStore, *SQLiteInventoryRepository, error) {
+	primary, err := NewSQLiteInventoryRepository(filepath.Join(dataDir, PrimaryDBName))
+	if err != nil {
+		return nil, nil, err
+	}
+	shards, err := ReadShardCount(context.Background(), primary)
+	primary.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	return OpenInventoryStore(dataDir, shards)
+}
+
+// ShardedInventoryRepository spreads inventory rows over N SQLite files
+// selected by a stable hash of roblox_user_id. Each shard has its own write
+// handle, so flushes to different shards don't serialize on one file.
+type ShardedInventoryRepository struct {
+	primary *SQLiteInventoryRepository
+	shards  []*SQLiteInventoryRepository
+}
+
+// openShards opens every shard file in dataDir.
+func openShards(dataDir string, n int, primary *SQLiteInventoryRepository, opts ...SQLiteOption) (*ShardedInventoryRepository, error) {
+	shards := make([]*SQLiteInventoryRepository, 0, n)
+	for i := 0; i < n; i++ {
+		shard, err := NewSQLiteInventoryRepository(filepath.Join(dataDir, ShardFileName(i)), opts...)
+		if err != nil {
+			for _, s := range shards {
+				s.Close()
+			}
+			return nil, fmt.Errorf("failed to open shard %d: %w", i, err)
+		}
+		shards = append(shards, shard)
+	}
+	return &ShardedInventoryRepository{primary: primary, shards: shards}, nil
+}
+
+// OpenShardSet opens shard files without the primary database. Used by the
+// reshard command to build a new shard set next to the live one.
+func OpenShardSet(dir string, n int) (*ShardedInventoryRepository, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid shard count %d", n)
+	}
+	return openShards(dir, n, nil)
+}
+
+// Pragmas returns the connection settings of the shards, which are all
+// opened alike.
+func (r *ShardedInventoryRepository) Pragmas() SQLitePragmas {
+	return r.shards[0].Pragmas()
+}
+
+// ShardCount returns the number of shards.
+func (r *ShardedInventoryRepository) ShardCount() int {
+	return len(r.shards)
+}
+
+// Partitions returns the shard files, in shard order.
+func (r *ShardedInventoryRepository) Partitions() []*SQLiteInventoryRepository {
+	return r.shards
+}
+
+func (r *ShardedInventoryRepository) shard(robloxUserID string) *SQLiteInventoryRepository {
+	return r.shards[ShardFor(robloxUserID, len(r.shards))]
+}
+
+// SetCompression sets the compression threshold of every shard.
+func (r *ShardedInventoryRepository) SetCompression(minBytes int) {
+	for _, shard := range r.shards {
+		shard.SetCompression(minBytes)
+	}
+}
+
+// UpsertRawInventory stores the default section in the user's shard.
+func (r *ShardedInventoryRepository) UpsertRawInventory(ctx context.Context, keyAccountID int64, robloxUserID string, rawJSON []byte, itemCount int) error {
+	return r.shard(robloxUserID).UpsertRawInventory(ctx, keyAccountID, robloxUserID, rawJSON, itemCount)
+}
+
+// UpsertRawInventorySection stores one section in the user's shard.
+func (r *ShardedInventoryRepository) UpsertRawInventorySection(ctx context.Context, keyAccountID int64, robloxUserID, section string, rawJSON []byte, itemCount int) error {
+	return r.shard(robloxUserID).UpsertRawInventorySection(ctx, keyAccountID, robloxUserID, section, rawJSON, itemCount)
+}
+
+// GetRawInventory reads the default section from the user's shard.
+func (r *ShardedInventoryRepository) GetRawInventory(ctx context.Context, robloxUserID string) ([]byte, *time.Time, error) {
+	return r.shard(robloxUserID).GetRawInventory(ctx, robloxUserID)
+}
+
+// GetRawInventorySection reads one section from the user's shard.
+func (r *ShardedInventoryRepository) GetRawInventorySection(ctx context.Context, robloxUserID, section string) ([]byte, *time.Time, error) {
+	return r.shard(robloxUserID).GetRawInventorySection(ctx, robloxUserID, section)
+}
+
+// ListSections lists the user's sections from their shard.
+func (r *ShardedInventoryRepository) ListSections(ctx context.Context, robloxUserID string) ([]SectionRecord, error) {
+	return r.shard(robloxUserID).ListSections(ctx, robloxUserID)
+}
+
+// ListSectionMeta lists the user's section metadata from their shard.
+func (r *ShardedInventoryRepository) ListSectionMeta(ctx context.Context, robloxUserID string) ([]SectionMeta, error) {
+	return r.shard(robloxUserID).ListSectionMeta(ctx, robloxUserID)
+}
+
+// BatchUpsertRawInventory partitions items by shard and writes the
+// partitions in parallel. On error some shards may have committed; callers
+// retry the whole batch, which is safe because upserts are idempotent.
+func (r *ShardedInventoryRepository) BatchUpsertRawInventory(ctx context.Context, items []InventoryItem) error {
+	_, err := r.BatchUpsertRawInventoryStats(ctx, items)
+	return err
+}
+
+// BatchUpsertRawInventoryStats is BatchUpsertRawInventory with the per-shard
+// outcome counts merged.
+func (r *ShardedInventoryRepository) BatchUpsertRawInventoryStats(ctx context.Context, items []InventoryItem) (*UpsertStats, error) {
+	total := &UpsertStats{}
+	if len(items) == 0 {
+		return total, nil
+	}
+
+	parts := make([][]InventoryItem, len(r.shards))
+	for _, item := range items {
+		i := ShardFor(item.RobloxUserID, len(r.shards))
+		parts[i] = append(parts[i], item)
+	}
+
+	stats := make([]*UpsertStats, len(r.shards))
+	errs := make([]error, len(r.shards))
+	r.eachShard(func(i int, shard *SQLiteInventoryRepository) {
+		if len(parts[i]) == 0 {
+			return
+		}
+		stats[i], errs[i] = shard.BatchUpsertRawInventoryStats(ctx, parts[i])
+		if errs[i] != nil {
+			errs[i] = fmt.Errorf("shard %d: %w", i, errs[i])
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	for _, s := range stats {
+		total.Add(s)
+	}
+	return total, nil
+}
+
+// ScanAll walks every shard in order.
+func (r *ShardedInventoryRepository) ScanAll(ctx context.Context, fn func(InventoryItem) error) error {
+	return r.ScanSince(ctx, time.Time{}, fn)
+}
+
+// ScanSince walks every shard in order, limited to rows synced at or after
+// since.
+func (r *ShardedInventoryRepository) ScanSince(ctx context.Context, since time.Time, fn func(InventoryItem) error) error {
+	for i, shard := range r.shards {
+		if err := shard.ScanSince(ctx, since, fn); err != nil {
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// GetStats merges the statistics of every shard.
+func (r *ShardedInventoryRepository) GetStats(ctx context.Context) (map[string]interface{}, error) {
+	perShard := make([]map[string]interface{}, len(r.shards))
+	errs := make([]error, len(r.shards))
+	r.eachShard(func(i int, shard *SQLiteInventoryRepository) {
+		perShard[i], errs[i] = shard.GetStats(ctx)
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	var (
+		totalInventories int64
+		totalUsers       int64
+		totalItems       int64
+		totalSize        int64
+		lastSync         time.Time
+	)
+	for _, stats := range perShard {
+		totalInventories += toInt64(stats["total_inventories"])
+		totalUsers += toInt64(stats["total_users"])
+		totalItems += toInt64(stats["total_items"])
+		totalSize += toInt64(stats["db_size_bytes"])
+		if t, ok := stats["last_sync"].(time.Time); ok && t.After(lastSync) {
+			lastSync = t
+		}
+	}
+
+	if r.primary != nil {
+		if stats, err := r.primary.GetStats(ctx); err == nil {
+			totalSize += toInt64(stats["db_size_bytes"])
+		}
+	}
+
+	result := map[string]interface{}{
+		"total_inventories": totalInventories,
+		"total_users":       totalUsers,
+		"total_items":       totalItems,
+		"db_size_bytes":     totalSize,
+		"shards":            len(r.shards),
+	}
+	if !lastSync.IsZero() {
+		result["last_sync"] = lastSync
+	}
+	return result, nil
+}
+
+// CountUnlinked sums unlinked rows over all shards.
+func (r *ShardedInventoryRepository) CountUnlinked(ctx context.Context) (int64, error) {
+	return r.sumShards(func(shard *SQLiteInventoryRepository) (int64, error) {
+		return shard.CountUnlinked(ctx)
+	})
+}
+
+// DeleteUnlinked deletes unlinked rows from all shards.
+func (r *ShardedInventoryRepository) DeleteUnlinked(ctx context.Context) (int64, error) {
+	return r.sumShards(func(shard *SQLiteInventoryRepository) (int64, error) {
+		return shard.DeleteUnlinked(ctx)
+	})
+}
+
+// DeleteOlderThan prunes old rows from all shards.
+func (r *ShardedInventoryRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time, dryRun bool) (*PruneResult, error) {
+	results := make([]*PruneResult, len(r.shards))
+	errs := make([]error, len(r.shards))
+	r.eachShard(func(i int, shard *SQLiteInventoryRepository) {
+		results[i], errs[i] = shard.DeleteOlderThan(ctx, cutoff, dryRun)
+		if errs[i] != nil {
+			errs[i] = fmt.Errorf("shard %d: %w", i, errs[i])
+		}
+	})
+
+	total := &PruneResult{}
+	for _, res := range results {
+		if res != nil {
+			total.Rows += res.Rows
+			total.ReclaimedPages += res.ReclaimedPages
+			total.ReclaimedBytes += res.ReclaimedBytes
+		}
+	}
+	return total, errors.Join(errs...)
+}
+
+// Close closes every shard. The primary is closed by whoever opened it.
+func (r *ShardedInventoryRepository) Close() error {
+	var errs []error
+	for _, shard := range r.shards {
+		if err := shard.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// eachShard runs fn for every shard in parallel and waits for all of them.
+func (r *ShardedInventoryRepository) eachShard(fn func(i int, shard *SQLiteInventoryRepository)) {
+	var wg sync.WaitGroup
+	for i, shard := range r.shards {
+		wg.Add(1)
+		go func(i int, shard *SQLiteInventoryRepository) {
+			defer wg.Done()
+			fn(i, shard)
+		}(i, shard)
+	}
+	wg.Wait()
+}
+
+// sumShards runs fn on every shard in parallel and adds up the results.
+func (r *ShardedInventoryRepository) sumShards(fn func(shard *SQLiteInventoryRepository) (int64, error)) (int64, error) {
+	counts := make([]int64, len(r.shards))
+	errs := make([]error, len(r.shards))
+	r.eachShard(func(i int, shard *SQLiteInventoryRepository) {
+		counts[i], errs[i] = fn(shard)
+		if errs[i] != nil {
+			errs[i] = fmt.Errorf("shard %d: %w", i, errs[i])
+		}
+	})
+
+	var total int64
+	for _, c := range counts {
+		total += c
+	}
+	return total, errors.Join(errs...)
+}
+
+// toInt64 converts the numeric values found in stats maps.
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
+
+// Ensure ShardedInventoryRepository implements InventoryStore
+var _ InventoryStore = (*ShardedInventoryRepository)(nil)
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrQueryNotAllowed is returned for anything but a single read-only SELECT.
+var ErrQueryNotAllowed = errors.New("only a single SELECT statement is allowed")
+
+// writeKeywords can't appear in a console query, even inside a WITH clause.
+// REPLACE is missing on purpose: replace() is a common string function, and
+// a REPLACE statement can't start a query anyway.
+var writeKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "UPSERT": true,
+	"CREATE": true, "DROP": true, "ALTER": true, "ATTACH": true, "DETACH": true,
+	"PRAGMA": true, "VACUUM": true, "REINDEX": true, "ANALYZE": true,
+	"BEGIN": true, "COMMIT": true, "ROLLBACK": true, "SAVEPOINT": true, "RELEASE": true,
+}
+
+// SQLConsoleOptions limits console queries.
+type SQLConsoleOptions struct {
+	MaxRows  int           // Rows returned at most; the result is marked truncated beyond
+	MaxBytes int           // Approximate encoded size of the returned rows
+	Timeout  time.Duration // Statement timeout
+}
+
+// QueryResult is the result of a console query.
+type QueryResult struct {
+	Columns    []string        `json:"columns"`
+	Rows       [][]interface{} `json:"rows"`
+	RowCount   int             `json:"row_count"`
+	Truncated  bool            `json:"truncated"`
+	DurationMs int64           `json:"duration_ms"`
+}
+
+// SQLConsole runs ad-hoc read queries for admins on a dedicated read-only
+// connection, so a query that slips past validation still can't write.
+type SQLConsole struct {
+	db   *sql.DB
+	opts SQLConsoleOptions
+}
+
+// OpenSQLConsole opens a read-only connection to a SQLite database.
+func OpenSQLConsole(dbPath string, opts SQLConsoleOptions) (*SQLConsole, error) {
+	dsn := fmt.Sprintf("file:%s?mode=ro&_pragma=query_only(1)&_pragma=busy_timeout(5000)", dbPath)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open read-only SQLite: %w", err)
+	}
+	db.SetMaxOpenConns(2)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open read-only SQLite: %w", err)
+	}
+	return &SQLConsole{db: db, opts: opts}, nil
+}
+
+// Close closes the console connection.
+func (c *SQLConsole) Close() error {
+	return c.db.Close()
+}
+
+// Query validates and runs a console query.
+func (c *SQLConsole) Query(ctx context.Context, query string) (*QueryResult, error) {
+	if err := ValidateReadOnlyQuery(query); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+	start := time.Now()
+
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	result := &QueryResult{Columns: columns, Rows: [][]interface{}{}}
+	size := 0
+	for rows.Next() {
+		if len(result.Rows) >= c.opts.MaxRows || size >= c.opts.MaxBytes {
+			result.Truncated = true
+			break
+		}
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b) // TEXT/BLOB as text rather than base64
+			}
+		}
+		encoded, _ := json.Marshal(values)
+		size += len(encoded)
+		result.Rows = append(result.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	result.RowCount = len(result.Rows)
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result, nil
+}
+
+// queryError reports a timed-out query as context.DeadlineExceeded, which
+// the driver surfaces as a plain "interrupted" error.
+func queryError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("query failed: %w", ctx.Err())
+	}
+	return fmt.Errorf("query failed: %w", err)
+}
+
+// ValidateReadOnlyQuery accepts a single SELECT, WITH ... SELECT or VALUES
+// statement. Comments and string literals are skipped, so keywords inside
+// them don't count.
+func ValidateReadOnlyQuery(query string) error {
+	words, statements, err := sqlWords(query)
+	if err != nil {
+		return err
+	}
+	if statements != 1 || len(words) == 0 {
+		return ErrQueryNotAllowed
+	}
+	if words[0] != "SELECT" && words[0] != "WITH" && words[0] != "VALUES" {
+		return ErrQueryNotAllowed
+	}
+	for _, w := range words {
+		if writeKeywords[w] {
+			return fmt.Errorf("%w: %s is not allowed", ErrQueryNotAllowed, w)
+		}
+	}
+	return nil
+}
+
+// sqlWords returns the upper-cased bare words of a query and its number of
+// non-empty statements.
+func sqlWords(query string) ([]string, int, error) {
+	var words []string
+	statements, current := 0, false
+	rs := []rune(query)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == '-' && i+1 < len(rs) && rs[i+1] == '-':
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '*':
+			j := i + 2
+			for j+1 < len(rs) && !(rs[j] == '*' && rs[j+1] == '/') {
+				j++
+			}
+			if j+1 >= len(rs) {
+				return nil, 0, fmt.Errorf("%w: unterminated comment", ErrQueryNotAllowed)
+			}
+			i = j + 1
+		case r == '\'' || r == '"' || r == '`' || r == '[':
+			closing := r
+			if r == '[' {
+				closing = ']'
+			}
+			j := i + 1
+			for ; j < len(rs); j++ {
+				if rs[j] == closing {
+					if closing != ']' && j+1 < len(rs) && rs[j+1] == closing {
+						j++ // Doubled quote
+						continue
+					}
+					break
+				}
+			}
+			if j >= len(rs) {
+				return nil, 0, fmt.Errorf("%w: unterminated literal", ErrQueryNotAllowed)
+			}
+			i = j
+			current = true
+		case r == ';':
+			if current {
+				statements++
+			}
+			current = false
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_') {
+				j++
+			}
+			words = append(words, strings.ToUpper(string(rs[i:j])))
+			i = j - 1
+			current = true
+		case !unicode.IsSpace(r):
+			current = true
+		}
+	}
+	if current {
+		statements++
+	}
+	return words, statements, nil
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"vinzhub-rest-api/internal/lifecycle"
+)
+
+// maintenanceTimeout bounds one scheduled maintenance pass.
+const maintenanceTimeout = 10 * time.Minute
+
+// autoVacuumModes maps PRAGMA auto_vacuum values to their names.
+var autoVacuumModes = map[int]string{0: "NONE", 1: "FULL", 2: "INCREMENTAL"}
+
+// MaintenanceResult reports one maintenance pass over a database file.
+type MaintenanceResult struct {
+	File        string `json:"file"`
+	AutoVacuum  string `json:"auto_vacuum"`
+	PagesBefore int64  `json:"pages_before"`
+	PagesAfter  int64  `json:"pages_after"`
+	FreedPages  int64  `json:"freed_pages"`
+	// FreePages is what the freelist still holds. Without incremental
+	// auto_vacuum (files created before it was enabled) pages are only
+	// reused, never returned, until a full VACUUM converts the file
+	FreePages  int64 `json:"free_pages"`
+	Vacuumed   bool  `json:"vacuumed"` // Full VACUUM converting the file to incremental auto_vacuum
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// maintenance is the state of a repository's maintenance goroutine.
+type maintenance struct {
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// StartMaintenance runs Maintain every interval until Close.
+func (r *SQLiteInventoryRepository) StartMaintenance(interval time.Duration) {
+	m := &maintenance{stop: make(chan struct{}), done: make(chan struct{})}
+	r.maint = m
+	lifecycle.Go("sqlite.maintenance", func() {
+		r.maintenanceLoop(m, interval)
+		close(m.done) // Not deferred: a panicking loop is restarted
+	})
+}
+
+// maintenanceLoop runs Maintain every interval until stopped.
+func (r *SQLiteInventoryRepository) maintenanceLoop(m *maintenance, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			lifecycle.Touch("sqlite.maintenance")
+			ctx, cancel := context.WithTimeout(context.Background(), maintenanceTimeout)
+			result, err := r.Maintain(ctx, false)
+			cancel()
+			if err != nil {
+				r.logger.Error("Maintenance failed", "file", filepath.Base(r.path), "error", err)
+				continue
+			}
+			r.logger.Info("Maintenance done", "file", result.File, "freed_pages", result.FreedPages, "duration_ms", result.DurationMs)
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// stopMaintenance stops the maintenance goroutine, if started, and waits
+// for a running pass.
+func (r *SQLiteInventoryRepository) stopMaintenance() {
+	if m := r.maint; m != nil {
+		m.stopOnce.Do(func() {
+			close(m.stop)
+			<-m.done
+		})
+	}
+}
+
+// Maintain returns free pages to the filesystem with PRAGMA
+// incremental_vacuum and refreshes query planner statistics with ANALYZE.
+// It holds the write lock, so it never overlaps a flush. convert runs a
+// full VACUUM first when the file predates incremental auto_vacuum, which
+// rewrites the whole file and blocks writes for as long.
+func (r *SQLiteInventoryRepository) Maintain(ctx context.Context, convert bool) (*MaintenanceResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	start := time.Now()
+	result := &MaintenanceResult{File: filepath.Base(r.path)}
+
+	var mode int
+	if err := r.db.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return nil, fmt.Errorf("failed to read auto_vacuum: %w", err)
+	}
+	if err := r.db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&result.PagesBefore); err != nil {
+		return nil, fmt.Errorf("failed to read page count: %w", err)
+	}
+
+	if convert && autoVacuumModes[mode] != "INCREMENTAL" {
+		// The mode only takes effect on an empty file or through VACUUM
+		if _, err := r.db.ExecContext(ctx, "PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+			return nil, fmt.Errorf("failed to set auto_vacuum: %w", err)
+		}
+		if _, err := r.db.ExecContext(ctx, "VACUUM"); err != nil {
+			return nil, fmt.Errorf("failed to vacuum: %w", err)
+		}
+		result.Vacuumed = true
+		if err := r.db.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&mode); err != nil {
+			return nil, fmt.Errorf("failed to read auto_vacuum: %w", err)
+		}
+	}
+	result.AutoVacuum = autoVacuumModes[mode]
+
+	// incremental_vacuum returns a row per page freed; drain them all
+	rows, err := r.db.QueryContext(ctx, "PRAGMA incremental_vacuum")
+	if err != nil {
+		return nil, fmt.Errorf("failed to run incremental_vacuum: %w", err)
+	}
+	for rows.Next() {
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run incremental_vacuum: %w", err)
+	}
+
+	// Counted before ANALYZE, whose statistics table takes pages of its own
+	if err := r.db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&result.PagesAfter); err != nil {
+		return nil, fmt.Errorf("failed to read page count: %w", err)
+	}
+	if err := r.db.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&result.FreePages); err != nil {
+		return nil, fmt.Errorf("failed to read freelist: %w", err)
+	}
+	result.FreedPages = max(result.PagesBefore-result.PagesAfter, 0)
+
+	if _, err := r.db.ExecContext(ctx, "ANALYZE"); err != nil {
+		return nil, fmt.Errorf("failed to analyze: %w", err)
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result, nil
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// defaultBusyTimeout is how long a statement waits for a lock held by
+// another connection before failing with "database is locked".
+const defaultBusyTimeout = 5 * time.Second
+
+// SQLitePragmas are the connection settings a database was opened with,
+// as read back from SQLite.
+type SQLitePragmas struct {
+	JournalMode   string `json:"journal_mode"`
+	Synchronous   string `json:"synchronous"`
+	BusyTimeoutMs int64  `json:"busy_timeout_ms"`
+}
+
+// synchronousNames maps PRAGMA synchronous values to their names.
+var synchronousNames = map[int]string{0: "OFF", 1: "NORMAL", 2: "FULL", 3: "EXTRA"}
+
+// SQLiteOption tunes how a database is opened.
+type SQLiteOption func(*sqliteOptions)
+
+type sqliteOptions struct {
+	busyTimeout time.Duration
+	logger      *slog.Logger
+}
+
+// WithBusyTimeout sets how long statements wait on a locked database.
+// Values <= 0 keep the default of 5s.
+func WithBusyTimeout(d time.Duration) SQLiteOption {
+	return func(o *sqliteOptions) {
+		if d > 0 {
+			o.busyTimeout = d
+		}
+	}
+}
+
+// WithLogger sets the logger of the repository, which logs migrations and
+// maintenance. Defaults to logging.Component("SQLite").
+func WithLogger(logger *slog.Logger) SQLiteOption {
+	return func(o *sqliteOptions) {
+		o.logger = logger
+	}
+}
+
+// sqliteDSN builds the connection string. Pragmas are passed as _pragma
+// parameters so the driver applies them to every new connection.
+// auto_vacuum only applies to files created with it (see Maintain).
+func sqliteDSN(dbPath string, o sqliteOptions) string {
+	return fmt.Sprintf("%s?_pragma=busy_timeout(%d)&_pragma=auto_vacuum(INCREMENTAL)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=cache_size(10000)",
+		dbPath, o.busyTimeout.Milliseconds())
+}
+
+// verifyPragmas reads the connection settings back and fails unless they
+// are the ones asked for, e.g. when WAL can't be enabled on the filesystem.
+func verifyPragmas(db *sql.DB, o sqliteOptions) (SQLitePragmas, error) {
+	var p SQLitePragmas
+	var synchronous int
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&p.JournalMode); err != nil {
+		return p, fmt.Errorf("failed to read journal_mode: %w", err)
+	}
+	if err := db.QueryRow("PRAGMA synchronous").Scan(&synchronous); err != nil {
+		return p, fmt.Errorf("failed to read synchronous: %w", err)
+	}
+	if err := db.QueryRow("PRAGMA busy_timeout").Scan(&p.BusyTimeoutMs); err != nil {
+		return p, fmt.Errorf("failed to read busy_timeout: %w", err)
+	}
+	p.Synchronous = synchronousNames[synchronous]
+
+	if !strings.EqualFold(p.JournalMode, "wal") {
+		return p, fmt.Errorf("journal_mode is %s, want wal", p.JournalMode)
+	}
+	if p.Synchronous != "NORMAL" {
+		return p, fmt.Errorf("synchronous is %d, want NORMAL (1)", synchronous)
+	}
+	if p.BusyTimeoutMs != o.busyTimeout.Milliseconds() {
+		return p, fmt.Errorf("busy_timeout is %dms, want %dms", p.BusyTimeoutMs, o.busyTimeout.Milliseconds())
+	}
+	return p, nil
+}
+
+// Pragmas returns the connection settings verified at open.
+func (r *SQLiteInventoryRepository) Pragmas() SQLitePragmas {
+	return r.pragmas
+}
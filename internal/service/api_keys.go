@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"vinzhub-rest-api/internal/lifecycle"
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/repository"
+)
+
+const (
+	// APIKeyPrefix is the prefix of API keys created through the admin API.
+	APIKeyPrefix = "vhk_"
+	// APIKeyRefreshInterval is how often the key cache is reloaded, so keys
+	// created or revoked on another instance apply here.
+	APIKeyRefreshInterval = 30 * time.Second
+	// apiKeyTimeout bounds one reload.
+	apiKeyTimeout = 10 * time.Second
+)
+
+// HashAPIKey returns the hex SHA-256 an API key is stored by.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyStore checks API keys managed through the admin API. Active keys
+// are cached in process and reloaded every refresh interval; when keys were
+// last used is buffered and written on the same tick.
+type APIKeyStore struct {
+	repo   repository.APIKeyRepository
+	every  time.Duration
+	logger *slog.Logger
+
+	mu        sync.RWMutex
+	keys      map[string]*repository.APIKey // Active keys by hash
+	loadedAt  time.Time
+	lastError string
+
+	usedMu sync.Mutex
+	used   map[int64]time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewAPIKeyStore creates a store reloading every `every`
+// (APIKeyRefreshInterval when <= 0). Call Load before serving requests.
+func NewAPIKeyStore(repo repository.APIKeyRepository, every time.Duration) *APIKeyStore {
+	if every <= 0 {
+		every = APIKeyRefreshInterval
+	}
+	return &APIKeyStore{
+		repo:   repo,
+		every:  every,
+		logger: logging.Component("APIKeys"),
+		keys:   make(map[string]*repository.APIKey),
+		used:   make(map[int64]time.Time),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start reloads the cache and records key use in the background until Close.
+func (s *APIKeyStore) Start() {
+	lifecycle.Go("api_keys", func() {
+		s.loop()
+		close(s.done) // Not deferred: a panicking loop is restarted
+	})
+}
+
+// loop reloads the cache every interval until Close.
+func (s *APIKeyStore) loop() {
+	ticker := time.NewTicker(s.every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			lifecycle.Touch("api_keys")
+			ctx, cancel := context.WithTimeout(context.Background(), apiKeyTimeout)
+			if err := s.flushUsage(ctx); err != nil {
+				s.logger.Error("Recording key use failed", "error", err)
+			}
+			if err := s.Load(ctx); err != nil {
+				s.logger.Error("Reload failed, keeping cached keys", "error", err)
+			}
+			cancel()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Load replaces the cache with the active keys in the database.
+func (s *APIKeyStore) Load(ctx context.Context) error {
+	list, err := s.repo.ListAPIKeys(ctx, false)
+	if err != nil {
+		s.mu.Lock()
+		s.lastError = err.Error()
+		s.mu.Unlock()
+		return err
+	}
+
+	keys := make(map[string]*repository.APIKey, len(list))
+	for i := range list {
+		keys[list[i].KeyHash] = &list[i]
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.loadedAt = time.Now()
+	s.lastError = ""
+	s.mu.Unlock()
+	return nil
+}
+
+// LookupAPIKey returns the scopes of an active key (empty for a full key)
+// and records its use.
+func (s *APIKeyStore) LookupAPIKey(key string) ([]string, bool) {
+	s.mu.RLock()
+	found, ok := s.keys[HashAPIKey(key)]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	s.usedMu.Lock()
+	s.used[found.ID] = time.Now()
+	s.usedMu.Unlock()
+	return found.Scopes, true
+}
+
+// CreateAPIKey generates a key and stores its hash. The key is returned
+// only here; it can't be recovered later.
+func (s *APIKeyStore) CreateAPIKey(ctx context.Context, label string, scopes []string) (string, *repository.APIKey, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+	key := APIKeyPrefix + hex.EncodeToString(raw)
+
+	created, err := s.repo.CreateAPIKey(ctx, HashAPIKey(key), label, scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	s.mu.Lock()
+	s.keys[created.KeyHash] = created
+	s.mu.Unlock()
+	return key, created, nil
+}
+
+// ListAPIKeys returns the keys in the database, revoked ones too when asked.
+func (s *APIKeyStore) ListAPIKeys(ctx context.Context, includeRevoked bool) ([]repository.APIKey, error) {
+	return s.repo.ListAPIKeys(ctx, includeRevoked)
+}
+
+// RevokeAPIKey revokes a key. It stops working here at once, and on other
+// instances by their next reload.
+func (s *APIKeyStore) RevokeAPIKey(ctx context.Context, id int64) (*repository.APIKey, error) {
+	revoked, err := s.repo.RevokeAPIKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	delete(s.keys, revoked.KeyHash)
+	s.mu.Unlock()
+	return revoked, nil
+}
+
+// flushUsage writes when keys were last used. On failure the times are put
+// back, unless a newer use was recorded meanwhile.
+func (s *APIKeyStore) flushUsage(ctx context.Context) error {
+	s.usedMu.Lock()
+	used := s.used
+	s.used = make(map[int64]time.Time, len(used))
+	s.usedMu.Unlock()
+
+	if err := s.repo.TouchAPIKeys(ctx, used); err != nil {
+		s.usedMu.Lock()
+		for id, at := range used {
+			if _, newer := s.used[id]; !newer {
+				s.used[id] = at
+			}
+		}
+		s.usedMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Close stops the background loop and writes the buffered key use.
+func (s *APIKeyStore) Close() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+		ctx, cancel := context.WithTimeout(context.Background(), apiKeyTimeout)
+		defer cancel()
+		if err := s.flushUsage(ctx); err != nil {
+			s.logger.Error("Final key use flush failed", "error", err)
+		}
+	})
+}
+
+// Stats returns key cache counters for admin stats.
+func (s *APIKeyStore) Stats(ctx context.Context) map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stats := map[string]interface{}{
+		"active":          len(s.keys),
+		"refresh_seconds": s.every.Seconds(),
+	}
+	if !s.loadedAt.IsZero() {
+		stats["loaded_at"] = s.loadedAt
+	}
+	if s.lastError != "" {
+		stats["last_error"] = s.lastError
+	}
+	return stats
+}
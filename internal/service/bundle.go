@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"vinzhub-rest-api/internal/bundle"
+	"vinzhub-rest-api/internal/cache"
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/repository"
+)
+
+// Outcomes of importing one user from a bundle.
+const (
+	BundleApplied = "applied" // Every section was newer and written
+	BundlePartial = "partial" // Some sections were written, the rest kept
+	BundleSkipped = "skipped" // Nothing in the bundle was newer
+	BundleFailed  = "failed"
+)
+
+// BundleUserOutcome is what importing one user did, or would do on a dry run.
+type BundleUserOutcome struct {
+	RobloxUserID string   `json:"roblox_user_id"`
+	Status       string   `json:"status"`
+	Applied      []string `json:"applied,omitempty"` // Sections newer than the stored copy
+	Kept         []string `json:"kept,omitempty"`    // Sections where the stored copy is as new or newer
+	Ignored      []string `json:"ignored,omitempty"` // Sections not configured on this deployment
+	Error        string   `json:"error,omitempty"`
+}
+
+// BundleImportResult reports an import.
+type BundleImportResult struct {
+	DryRun    bool                `json:"dry_run"`
+	Source    string              `json:"source,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+	Users     int                 `json:"users"`
+	Counts    map[string]int      `json:"counts"` // Users per status
+	Outcomes  []BundleUserOutcome `json:"outcomes"`
+}
+
+// BundleService moves users between deployments as encrypted bundles.
+type BundleService struct {
+	inventory *InventoryService
+	store     repository.InventoryStore
+	key       []byte
+	source    string
+	spoolDir  string
+	logger    *slog.Logger
+}
+
+// NewBundleService creates a bundle service. key is the shared bundle key,
+// source names this deployment in exported manifests, and uploaded bundles
+// are staged in spoolDir until verified.
+func NewBundleService(inventory *InventoryService, store repository.InventoryStore, key []byte, source, spoolDir string) (*BundleService, error) {
+	if len(key) != bundle.KeySize {
+		return nil, fmt.Errorf("bundle key must be %d bytes", bundle.KeySize)
+	}
+	if err := os.MkdirAll(spoolDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create bundle spool dir: %w", err)
+	}
+	return &BundleService{inventory: inventory, store: store, key: key, source: source, spoolDir: spoolDir,
+		logger: logging.Component("Bundle")}, nil
+}
+
+// Export writes the current inventory of each user, buffered writes
+// included, to w as a bundle. Users are read one at a time.
+func (s *BundleService) Export(ctx context.Context, w io.Writer, robloxUserIDs []string) (*bundle.Manifest, error) {
+	bw, err := bundle.NewWriter(w, s.key, s.source)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range robloxUserIDs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		all, err := s.inventory.GetAllSections(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", id, err)
+		}
+		if len(all) == 0 {
+			bw.Missing(id)
+			continue
+		}
+
+		rec := bundle.Record{RobloxUserID: id, Sections: make(map[string]bundle.Section, len(all))}
+		for name, sec := range all {
+			section, err := bundle.NewSection(sec.RawJSON, *sec.SyncedAt)
+			if err != nil {
+				return nil, fmt.Errorf("failed to export %s/%s: %w", id, name, err)
+			}
+			rec.Sections[name] = section
+		}
+		if err := bw.Add(rec); err != nil {
+			return nil, fmt.Errorf("failed to write bundle: %w", err)
+		}
+	}
+	return bw.Close()
+}
+
+// Import verifies a bundle and then applies it, newer copy wins per
+// section. The upload is staged (still encrypted) on disk: nothing is
+// applied until the whole bundle has been read and matched its manifest.
+// Errors wrapping bundle.ErrInvalid mean the bundle was rejected.
+func (s *BundleService) Import(ctx context.Context, r io.Reader, dryRun bool) (*BundleImportResult, error) {
+	staged, err := os.CreateTemp(s.spoolDir, "import-*.bundle")
+	if err != nil {
+		return nil, fmt.Errorf("failed to stage bundle: %w", err)
+	}
+	defer os.Remove(staged.Name())
+	defer staged.Close()
+
+	// Pass 1: verify while staging
+	manifest, err := s.readAll(io.TeeReader(r, staged), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pass 2: apply from the staged copy
+	if _, err := staged.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read staged bundle: %w", err)
+	}
+	result := &BundleImportResult{
+		DryRun:    dryRun,
+		Source:    manifest.Source,
+		CreatedAt: manifest.CreatedAt,
+		Users:     manifest.Users,
+		Counts:    map[string]int{},
+		Outcomes:  make([]BundleUserOutcome, 0, manifest.Users),
+	}
+	_, err = s.readAll(staged, func(rec *bundle.Record) error {
+		outcome := s.importUser(ctx, rec, dryRun)
+		result.Counts[outcome.Status]++
+		result.Outcomes = append(result.Outcomes, outcome)
+		return ctx.Err()
+	})
+	if err != nil {
+		return result, err
+	}
+
+	s.logger.InfoContext(ctx, "Imported bundle", "source", manifest.Source, "dry_run", dryRun,
+		"users", manifest.Users, "counts", result.Counts)
+	return result, nil
+}
+
+// readAll reads a bundle to the end, passing each record to fn (if set),
+// and returns its verified manifest.
+func (s *BundleService) readAll(r io.Reader, fn func(*bundle.Record) error) (*bundle.Manifest, error) {
+	br, err := bundle.NewReader(r, s.key)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		rec, err := br.Next()
+		if err == io.EOF {
+			return br.Manifest(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if fn != nil {
+			if err := fn(rec); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// importUser applies the sections of one record that are newer than what
+// this deployment holds, buffered writes included.
+func (s *BundleService) importUser(ctx context.Context, rec *bundle.Record, dryRun bool) BundleUserOutcome {
+	outcome := BundleUserOutcome{RobloxUserID: rec.RobloxUserID}
+
+	current, err := s.inventory.GetAllSectionMeta(ctx, rec.RobloxUserID)
+	if err != nil {
+		outcome.Status = BundleFailed
+		outcome.Error = err.Error()
+		return outcome
+	}
+
+	var items []repository.InventoryItem
+	for name, sec := range rec.Sections {
+		if _, err := s.inventory.resolveSection(name); err != nil {
+			outcome.Ignored = append(outcome.Ignored, name)
+			continue
+		}
+		if stored, ok := current[name]; ok && !sec.SyncedAt.After(stored.SyncedAt) {
+			outcome.Kept = append(outcome.Kept, name)
+			continue
+		}
+		outcome.Applied = append(outcome.Applied, name)
+		items = append(items, repository.InventoryItem{
+			RobloxUserID: rec.RobloxUserID,
+			Section:      name,
+			RawJSON:      sec.Data,
+			SyncedAt:     sec.SyncedAt,
+		})
+	}
+	sort.Strings(outcome.Applied)
+	sort.Strings(outcome.Kept)
+	sort.Strings(outcome.Ignored)
+
+	switch {
+	case len(items) == 0:
+		outcome.Status = BundleSkipped
+	case len(outcome.Kept) > 0 || len(outcome.Ignored) > 0:
+		outcome.Status = BundlePartial
+	default:
+		outcome.Status = BundleApplied
+	}
+	if dryRun || len(items) == 0 {
+		return outcome
+	}
+
+	if err := s.apply(ctx, rec.RobloxUserID, items); err != nil {
+		outcome.Status = BundleFailed
+		outcome.Error = err.Error()
+	}
+	return outcome
+}
+
+// apply writes imported sections straight to the store, linked to the
+// user's key account here. An older buffered copy is left alone: its flush
+// loses to the imported row on synced_at, and dropping it here could race
+// with a newer sync landing in the buffer.
+func (s *BundleService) apply(ctx context.Context, robloxUserID string, items []repository.InventoryItem) error {
+	keyAccountID, err := s.inventory.lookupKeyAccount(ctx, robloxUserID)
+	if err != nil && !errors.Is(err, repository.ErrKeyAccountNotFound) {
+		s.logger.WarnContext(ctx, "Key account lookup failed, importing unlinked", "roblox_user_id", robloxUserID, "error", err)
+	}
+	for i := range items {
+		items[i].KeyAccountID = keyAccountID
+		items[i].ItemCount = s.inventory.itemCounter.Count(items[i].RawJSON)
+	}
+
+	if _, err := s.store.BatchUpsertRawInventoryStats(ctx, items); err != nil {
+		return err
+	}
+	for _, item := range items {
+		s.inventory.reads.forget(ctx, robloxUserID, item.Section)
+	}
+	s.inventory.forgetExistence(ctx, robloxUserID)
+	s.inventory.bus.Publish(ctx, cache.InvalidateInventory, robloxUserID)
+	return nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"vinzhub-rest-api/internal/cache"
+)
+
+// existsCachePrefix namespaces cached Exists answers in the lookup cache.
+const existsCachePrefix = "inventory:exists:"
+
+// existenceCache caches Exists answers, which are looked up far more often
+// than they change.
+type existenceCache struct {
+	cache       cache.Cache // nil disables caching
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// SetExistenceCache caches Exists answers: ttl for users that have synced,
+// negativeTTL for users that haven't. A sync here drops the user's entry.
+func (s *InventoryService) SetExistenceCache(c cache.Cache, ttl, negativeTTL time.Duration) {
+	s.existence.cache = c
+	s.existence.ttl = ttl
+	s.existence.negativeTTL = negativeTTL
+}
+
+// Exists reports whether a roblox user has ever synced, and the day (UTC)
+// of their latest sync. The day resolution is deliberate: callers learn
+// that someone uses the product, not when they play. Only section metadata
+// is read, never the documents.
+func (s *InventoryService) Exists(ctx context.Context, robloxUserID string) (bool, time.Time, error) {
+	key := existsCachePrefix + robloxUserID
+	if s.existence.cache != nil {
+		if cached, err := s.existence.cache.Get(ctx, key); err == nil {
+			s.existence.hits.Add(1)
+			day, _ := strconv.ParseInt(string(cached), 10, 64)
+			if day == 0 {
+				return false, time.Time{}, nil
+			}
+			return true, time.Unix(day, 0).UTC(), nil
+		}
+		s.existence.misses.Add(1)
+	}
+
+	metas, err := s.GetAllSectionMeta(ctx, robloxUserID)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	var latest time.Time
+	for _, meta := range metas {
+		if meta.SyncedAt.After(latest) {
+			latest = meta.SyncedAt
+		}
+	}
+
+	exists := len(metas) > 0
+	var day time.Time
+	if exists {
+		day = latest.UTC().Truncate(24 * time.Hour)
+	}
+	if s.existence.cache != nil {
+		if exists && s.existence.ttl > 0 {
+			s.existence.cache.Set(ctx, key, []byte(strconv.FormatInt(day.Unix(), 10)), s.existence.ttl)
+		} else if !exists && s.existence.negativeTTL > 0 {
+			s.existence.cache.Set(ctx, key, []byte("0"), s.existence.negativeTTL)
+		}
+	}
+	return exists, day, nil
+}
+
+// forgetExistence drops a cached Exists answer after a sync.
+func (s *InventoryService) forgetExistence(ctx context.Context, robloxUserID string) {
+	if s.existence.cache != nil {
+		s.existence.cache.Delete(ctx, existsCachePrefix+robloxUserID)
+	}
+}
+
+// stats returns existence cache counters.
+func (c *existenceCache) stats() map[string]interface{} {
+	return map[string]interface{}{
+		"enabled": c.cache != nil,
+		"hits":    c.hits.Load(),
+		"misses":  c.misses.Load(),
+	}
+}
@@ -0,0 +1,506 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vinzhub-rest-api/internal/cache"
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/tracing"
+)
+
+const (
+	// sideEffectMaxAttempts is how many times a failed side-effect batch is
+	// tried (including the first attempt) before it is dropped.
+	sideEffectMaxAttempts = 5
+
+	// sideEffectMaxQueued bounds the retry queue of each side effect. When
+	// full, the oldest batch is dropped.
+	sideEffectMaxQueued = 100
+)
+
+// SideEffectOutbox stores side-effect retry queues so they survive a
+// restart.
+type SideEffectOutbox interface {
+	InsertOutboxBatch(ctx context.Context, batch *repository.OutboxBatch) (int64, error)
+	UpdateOutboxBatch(ctx context.Context, id int64, attempts int, lastError string) error
+	DeleteOutboxBatch(ctx context.Context, id int64) error
+	ListOutboxBatches(ctx context.Context) ([]*repository.OutboxBatch, error)
+}
+
+// PersistFunc writes a batch to storage and reports how each row was handled.
+type PersistFunc func(ctx context.Context, items []repository.InventoryItem) (*repository.UpsertStats, error)
+
+// FlushStageFunc runs one stage of the flush pipeline on a batch.
+type FlushStageFunc func(ctx context.Context, items []repository.InventoryItem) error
+
+// FlushLogWriter records flush outcomes.
+type FlushLogWriter interface {
+	InsertFlushLog(ctx context.Context, entry *repository.FlushLogEntry) error
+}
+
+// FlushPipeline persists buffered inventories and then runs best-effort side
+// effects. Only the persist stage decides whether a flush failed: once rows
+// are in SQLite the buffer may delete them, and a failing side effect is
+// queued for retry on later flushes instead of re-flushing the batch.
+type FlushPipeline struct {
+	persist  PersistFunc
+	effects  []*sideEffect
+	outbox   SideEffectOutbox
+	flushLog FlushLogWriter
+	guard    *FlushGuard
+	counter  *ItemCounter
+	logger   *slog.Logger
+
+	flushes         atomic.Int64
+	persistFailures atomic.Int64
+	lastFlushAt     atomic.Int64 // unix seconds
+	running         atomic.Int32 // flushes in progress
+
+	upsertMu    sync.Mutex
+	upsertTotal repository.UpsertStats
+}
+
+// sideEffect is a best-effort stage with its own retry queue and counters.
+// With an outbox, the queue is written through to it.
+type sideEffect struct {
+	name   string
+	run    FlushStageFunc
+	outbox SideEffectOutbox
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	queue []*pendingBatch
+
+	succeeded atomic.Int64
+	failed    atomic.Int64
+	retried   atomic.Int64
+	dropped   atomic.Int64
+}
+
+// pendingBatch is a side-effect batch waiting to be retried.
+type pendingBatch struct {
+	id       int64 // Outbox row, 0 until stored
+	items    []repository.InventoryItem
+	attempts int
+	lastErr  string
+}
+
+// NewFlushPipeline creates a pipeline around the required persist stage.
+func NewFlushPipeline(persist PersistFunc) *FlushPipeline {
+	return &FlushPipeline{persist: persist, logger: logging.Component("FlushPipeline")}
+}
+
+// SetLogger replaces the pipeline's logger, side effects included.
+func (p *FlushPipeline) SetLogger(logger *slog.Logger) {
+	p.logger = logger
+	for _, e := range p.effects {
+		e.logger = logger
+	}
+}
+
+// AddSideEffect appends a best-effort stage. Side effects run in the order
+// they were added, after a successful persist.
+func (p *FlushPipeline) AddSideEffect(name string, run FlushStageFunc) {
+	p.effects = append(p.effects, &sideEffect{name: name, run: run, outbox: p.outbox, logger: p.logger})
+}
+
+// SetOutbox keeps side-effect retry queues in a durable outbox. Call
+// DrainOutbox once every side effect is added to pick up earlier retries.
+func (p *FlushPipeline) SetOutbox(o SideEffectOutbox) {
+	p.outbox = o
+	for _, e := range p.effects {
+		e.outbox = o
+	}
+}
+
+// DrainOutbox loads batches left in the outbox by an earlier run and
+// retries them, returning how many were loaded. Batches that fail again
+// stay queued for later flushes; batches of side effects that are no
+// longer configured are kept for when they are.
+func (p *FlushPipeline) DrainOutbox(ctx context.Context) (int, error) {
+	if p.outbox == nil {
+		return 0, nil
+	}
+	batches, err := p.outbox.ListOutboxBatches(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	byName := make(map[string]*sideEffect, len(p.effects))
+	for _, e := range p.effects {
+		byName[e.name] = e
+	}
+	loaded, orphaned := 0, 0
+	for _, b := range batches {
+		e, ok := byName[b.Effect]
+		if !ok {
+			orphaned++
+			continue
+		}
+		e.mu.Lock()
+		e.queue = append(e.queue, &pendingBatch{id: b.ID, items: b.Items, attempts: b.Attempts, lastErr: b.LastError})
+		e.mu.Unlock()
+		loaded++
+	}
+	if orphaned > 0 {
+		p.logger.WarnContext(ctx, "Keeping outbox batches of side effects that aren't configured", "batches", orphaned)
+	}
+
+	for _, e := range p.effects {
+		e.mu.Lock()
+		for len(e.queue) > sideEffectMaxQueued {
+			e.drop(ctx, e.queue[0])
+			e.queue = e.queue[1:]
+		}
+		e.retryQueued(ctx)
+		e.mu.Unlock()
+	}
+	return loaded, nil
+}
+
+// SetFlushLog enables recording every flush with its stage outcomes.
+func (p *FlushPipeline) SetFlushLog(w FlushLogWriter) {
+	p.flushLog = w
+}
+
+// SetGuard enables the data-loss guard, which checks every batch before it
+// is persisted and can pause flushing.
+func (p *FlushPipeline) SetGuard(g *FlushGuard) {
+	p.guard = g
+}
+
+// SetItemCounter sets how items are counted before a batch is persisted.
+// Without one, the top-level members of each document are counted.
+func (p *FlushPipeline) SetItemCounter(counter *ItemCounter) {
+	p.counter = counter
+}
+
+// Paused reports whether the data-loss guard holds flushes back.
+func (p *FlushPipeline) Paused() bool {
+	return p.guard != nil && p.guard.Paused()
+}
+
+// Resume lifts a data-loss guard pause and records the decision in the
+// flush log.
+func (p *FlushPipeline) Resume(ctx context.Context, discard bool) (*FlushTrip, error) {
+	if p.guard == nil {
+		return nil, ErrFlushNotPaused
+	}
+	trip, err := p.guard.Resume(ctx, discard)
+	if err != nil {
+		return nil, err
+	}
+
+	decision := "resumed"
+	detail := "buffered writes made before the resume were accepted"
+	if discard {
+		decision = "discarded"
+		detail = "buffered writes made before the resume that shrank drastically are discarded"
+	}
+	p.logFlush(ctx, &repository.FlushLogEntry{
+		StartedAt: time.Now().UTC(),
+		Stages: []repository.FlushStageOutcome{{
+			Stage:  "data_loss_guard",
+			Status: decision,
+			Detail: fmt.Sprintf("%s (trip at %s: %d of %d drops)", detail, trip.At.Format(time.RFC3339), trip.Dropped, trip.Checked),
+			Users:  trip.Users,
+		}},
+	})
+	return trip, nil
+}
+
+// Flush implements cache.FlushFunc.
+func (p *FlushPipeline) Flush(ctx context.Context, buffered []*cache.BufferedInventory) error {
+	items := make([]repository.InventoryItem, len(buffered))
+	for i, item := range buffered {
+		items[i] = repository.InventoryItem{
+			KeyAccountID:  item.KeyAccountID,
+			RobloxUserID:  item.RobloxUserID,
+			Section:       item.SectionName(),
+			RawJSON:       item.RawJSON,
+			SyncedAt:      item.UpdatedAt,
+			Callback:      item.Callback,
+			ClientVersion: item.ClientVersion,
+			ItemCount:     p.counter.Count(item.RawJSON),
+		}
+	}
+
+	ctx, span := tracing.StartTrace(ctx, "FlushPipeline.Flush")
+	if span != nil {
+		// Link the syncs whose entries this flush persists
+		for _, item := range buffered {
+			if link, ok := tracing.ParseLink(item.Trace); ok {
+				span.AddLink(link)
+			}
+		}
+	}
+	err := p.run(ctx, items, span)
+	span.Fail(err)
+	span.End()
+	return err
+}
+
+// Run persists items and runs the side effects. The returned error is the
+// persist error only; side-effect failures are counted, queued and logged.
+func (p *FlushPipeline) Run(ctx context.Context, items []repository.InventoryItem) error {
+	ctx, span := tracing.StartTrace(ctx, "FlushPipeline.Run")
+	err := p.run(ctx, items, span)
+	span.Fail(err)
+	span.End()
+	return err
+}
+
+// run is Run under span, whose trace ID is recorded in the flush log.
+func (p *FlushPipeline) run(ctx context.Context, items []repository.InventoryItem, span *tracing.Span) error {
+	start := time.Now()
+	p.running.Add(1)
+	defer p.running.Add(-1)
+	p.flushes.Add(1)
+	p.lastFlushAt.Store(start.Unix())
+
+	entry := &repository.FlushLogEntry{
+		StartedAt: start.UTC(),
+		Items:     len(items),
+		Stages:    make([]repository.FlushStageOutcome, 0, len(p.effects)+1),
+		TraceID:   span.TraceID(),
+	}
+	span.SetInt("flush.items", int64(len(items)))
+
+	if p.guard != nil {
+		kept, outcome, err := p.guard.Check(ctx, items)
+		entry.Stages = append(entry.Stages, outcome)
+		if err != nil {
+			// Nothing is persisted; the buffer keeps the batch
+			entry.Error = err.Error()
+			entry.DurationMs = time.Since(start).Milliseconds()
+			p.logFlush(ctx, entry)
+			return err
+		}
+		items = kept
+	}
+
+	stageStart := time.Now()
+	var upsert *repository.UpsertStats
+	err := runStage(ctx, func(ctx context.Context, items []repository.InventoryItem) error {
+		if len(items) == 0 {
+			return nil // Everything was discarded by the guard
+		}
+		var err error
+		upsert, err = p.persist(ctx, items)
+		return err
+	}, items)
+	persist := repository.FlushStageOutcome{Stage: "persist", Status: "ok", DurationMs: time.Since(stageStart).Milliseconds()}
+	if err != nil {
+		p.persistFailures.Add(1)
+		persist.Status = "failed"
+		persist.Error = err.Error()
+		entry.Error = err.Error()
+	} else {
+		entry.Upsert = upsert
+		p.recordUpsert(ctx, upsert)
+	}
+	entry.Stages = append(entry.Stages, persist)
+	entry.Persisted = err == nil
+
+	for _, effect := range p.effects {
+		if err != nil {
+			// Nothing was persisted, so there is nothing to react to.
+			// Queued retries wait for the next successful flush.
+			entry.Stages = append(entry.Stages, repository.FlushStageOutcome{Stage: effect.name, Status: "skipped"})
+			continue
+		}
+		entry.Stages = append(entry.Stages, effect.apply(ctx, items))
+	}
+
+	entry.DurationMs = time.Since(start).Milliseconds()
+	p.logFlush(ctx, entry)
+	return err
+}
+
+// logFlush records a flush log entry when the flush log is enabled.
+func (p *FlushPipeline) logFlush(ctx context.Context, entry *repository.FlushLogEntry) {
+	if p.flushLog == nil {
+		return
+	}
+	if err := p.flushLog.InsertFlushLog(ctx, entry); err != nil {
+		p.logger.ErrorContext(ctx, "Failed to record flush log", "error", err)
+	}
+}
+
+// Active reports whether a flush is in progress.
+func (p *FlushPipeline) Active() bool {
+	return p.running.Load() > 0
+}
+
+// recordUpsert accumulates upsert outcomes and flags ordering anomalies.
+func (p *FlushPipeline) recordUpsert(ctx context.Context, upsert *repository.UpsertStats) {
+	if upsert == nil {
+		return
+	}
+	p.upsertMu.Lock()
+	p.upsertTotal.Add(upsert)
+	p.upsertMu.Unlock()
+
+	// Buffered entries are always newer than what was flushed before them,
+	// so an older incoming row means something upstream reordered writes
+	if upsert.SkippedOlder > 0 {
+		p.logger.ErrorContext(ctx, "ALERT: rows older than stored data were skipped - upstream ordering bug?",
+			"rows", upsert.SkippedOlder)
+	}
+	// Rows already stored with the same content and sync time were flushed
+	// before but never cleared from the buffer
+	if upsert.Duplicate > 0 {
+		p.logger.WarnContext(ctx, "Rows were re-flushed duplicates of stored rows", "rows", upsert.Duplicate)
+	}
+}
+
+// apply retries queued batches, then runs the stage on the current batch.
+func (e *sideEffect) apply(ctx context.Context, items []repository.InventoryItem) repository.FlushStageOutcome {
+	start := time.Now()
+	outcome := repository.FlushStageOutcome{Stage: e.name, Status: "ok"}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// Retry earlier failures first, keeping the ones that fail again
+	outcome.Retried = e.retryQueued(ctx)
+
+	if err := runStage(ctx, e.run, items); err != nil {
+		e.failed.Add(1)
+		outcome.Status = "failed"
+		outcome.Error = err.Error()
+		e.logger.WarnContext(ctx, "Side effect failed, queued for retry", "effect", e.name, "items", len(items), "error", err)
+		e.requeue(ctx, &pendingBatch{items: items}, err)
+	} else {
+		e.succeeded.Add(1)
+	}
+
+	outcome.Queued = len(e.queue)
+	outcome.DurationMs = time.Since(start).Milliseconds()
+	return outcome
+}
+
+// retryQueued runs the queued batches again, keeping the ones that fail,
+// and returns how many it retried. Caller holds e.mu.
+func (e *sideEffect) retryQueued(ctx context.Context) int {
+	queued := e.queue
+	e.queue = nil
+	for _, batch := range queued {
+		e.retried.Add(1)
+		if err := runStage(ctx, e.run, batch.items); err != nil {
+			e.requeue(ctx, batch, err)
+			continue
+		}
+		e.succeeded.Add(1)
+		e.forget(ctx, batch)
+	}
+	return len(queued)
+}
+
+// requeue records a failed attempt and keeps the batch unless it ran out of
+// attempts or the queue is full. Caller holds e.mu.
+func (e *sideEffect) requeue(ctx context.Context, batch *pendingBatch, err error) {
+	batch.attempts++
+	batch.lastErr = err.Error()
+	if batch.attempts >= sideEffectMaxAttempts {
+		e.logger.ErrorContext(ctx, "Side effect dropped a batch", "effect", e.name, "items", len(batch.items),
+			"attempts", batch.attempts, "error", err)
+		e.drop(ctx, batch)
+		return
+	}
+	if len(e.queue) >= sideEffectMaxQueued {
+		e.drop(ctx, e.queue[0])
+		e.queue = e.queue[1:]
+	}
+	e.queue = append(e.queue, batch)
+	e.store(ctx, batch)
+}
+
+// store writes a queued batch to the outbox. A batch the outbox can't take
+// is still retried from memory.
+func (e *sideEffect) store(ctx context.Context, batch *pendingBatch) {
+	if e.outbox == nil {
+		return
+	}
+	var err error
+	if batch.id == 0 {
+		batch.id, err = e.outbox.InsertOutboxBatch(ctx, &repository.OutboxBatch{
+			Effect:    e.name,
+			Items:     batch.items,
+			Attempts:  batch.attempts,
+			LastError: batch.lastErr,
+		})
+	} else {
+		err = e.outbox.UpdateOutboxBatch(ctx, batch.id, batch.attempts, batch.lastErr)
+	}
+	if err != nil {
+		e.logger.ErrorContext(ctx, "Failed to store retry in outbox", "effect", e.name, "error", err)
+	}
+}
+
+// drop gives up on a batch.
+func (e *sideEffect) drop(ctx context.Context, batch *pendingBatch) {
+	e.dropped.Add(1)
+	e.forget(ctx, batch)
+}
+
+// forget removes a batch from the outbox.
+func (e *sideEffect) forget(ctx context.Context, batch *pendingBatch) {
+	if e.outbox == nil || batch.id == 0 {
+		return
+	}
+	if err := e.outbox.DeleteOutboxBatch(ctx, batch.id); err != nil {
+		e.logger.ErrorContext(ctx, "Failed to remove batch from outbox", "effect", e.name, "error", err)
+	}
+}
+
+// runStage runs a stage, turning a panic into an error so one broken stage
+// can't take down the flush loop.
+func runStage(ctx context.Context, fn FlushStageFunc, items []repository.InventoryItem) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx, items)
+}
+
+// Stats returns pipeline counters for admin stats.
+func (p *FlushPipeline) Stats(ctx context.Context) map[string]interface{} {
+	stats := map[string]interface{}{
+		"flushes":          p.flushes.Load(),
+		"persist_failures": p.persistFailures.Load(),
+	}
+	if last := p.lastFlushAt.Load(); last > 0 {
+		stats["last_flush_at"] = time.Unix(last, 0).UTC()
+	}
+
+	p.upsertMu.Lock()
+	stats["upsert"] = p.upsertTotal
+	p.upsertMu.Unlock()
+
+	effects := make(map[string]interface{}, len(p.effects))
+	for _, e := range p.effects {
+		e.mu.Lock()
+		queued := len(e.queue)
+		e.mu.Unlock()
+		effects[e.name] = map[string]interface{}{
+			"succeeded": e.succeeded.Load(),
+			"failed":    e.failed.Load(),
+			"retried":   e.retried.Load(),
+			"dropped":   e.dropped.Load(),
+			"queued":    queued,
+		}
+	}
+	stats["side_effects"] = effects
+	if p.guard != nil {
+		stats["data_loss_guard"] = p.guard.Stats(ctx)
+	}
+	return stats
+}
@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/repository"
+)
+
+const (
+	// flushGuardMetaKey persists an unresolved trip so a restart stays paused.
+	flushGuardMetaKey = "flush_guard_trip"
+
+	// flushGuardMaxUsers caps the user list kept per trip and per flush log stage.
+	flushGuardMaxUsers = 500
+
+	// flushGuardMinStoredItems is the smallest stored item count for which an
+	// item-count drop is considered; tiny documents fluctuate naturally.
+	flushGuardMinStoredItems = 5
+)
+
+var (
+	// ErrFlushPaused is returned while the guard holds flushes back. The
+	// batch stays buffered in Redis.
+	ErrFlushPaused = errors.New("flushing paused by data-loss guard")
+
+	// ErrFlushNotPaused is returned when resuming a guard that isn't paused.
+	ErrFlushNotPaused = errors.New("flushing is not paused")
+)
+
+// FlushGuardConfig configures the data-loss guard.
+type FlushGuardConfig struct {
+	// DropRatio is how much smaller (size or item count) a payload must be
+	// than the stored one to count as a drop, e.g. 0.8 for an 80% drop
+	DropRatio float64
+	// TripFraction pauses flushing when more than this fraction of the
+	// checked items in Window were drops
+	TripFraction float64
+	Window       time.Duration
+	// MinItems is the number of checked items needed in Window before the
+	// guard can trip, so a few players clearing inventories can't
+	MinItems int
+	// MinStoredBytes skips items whose stored payload is smaller than this
+	MinStoredBytes int
+}
+
+// SectionReader reads the stored copy of a section.
+type SectionReader interface {
+	GetRawInventorySection(ctx context.Context, robloxUserID, section string) ([]byte, *time.Time, error)
+}
+
+// MetaStore keeps small pieces of persistent state.
+type MetaStore interface {
+	GetMeta(ctx context.Context, key string) (string, bool, error)
+	SetMeta(ctx context.Context, key, value string) error
+}
+
+// FlushTrip describes why the guard paused flushing.
+type FlushTrip struct {
+	At       time.Time `json:"at"`
+	Checked  int       `json:"checked"`
+	Dropped  int       `json:"dropped"`
+	Fraction float64   `json:"fraction"`
+	Users    []string  `json:"users"` // roblox_user_id/section of the drops in the tripping batch
+}
+
+// FlushGuard compares each flush against stored data and pauses flushing
+// when too many payloads shrink drastically at once - the signature of a
+// client release wiping inventories. Paused data stays in the Redis buffer
+// until an admin resumes, either accepting or discarding the writes that
+// were buffered before the resume.
+type FlushGuard struct {
+	cfg    FlushGuardConfig
+	stored SectionReader
+	meta   MetaStore
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	samples []guardSample
+	trip    *FlushTrip // Non-nil while paused
+
+	// Writes buffered before resumeAt are approved (skip the check) or, with
+	// discardBefore, dropped when they are drops
+	resumeAt      time.Time
+	discardBefore bool
+
+	checked   atomic.Int64
+	drops     atomic.Int64
+	trips     atomic.Int64
+	discarded atomic.Int64
+}
+
+// guardSample is one flush's contribution to the window.
+type guardSample struct {
+	at      time.Time
+	checked int
+	dropped int
+}
+
+// NewFlushGuard creates a guard and restores an unresolved pause.
+func NewFlushGuard(cfg FlushGuardConfig, stored SectionReader, meta MetaStore) *FlushGuard {
+	g := &FlushGuard{cfg: cfg, stored: stored, meta: meta, logger: logging.Component("FlushGuard")}
+
+	value, ok, err := meta.GetMeta(context.Background(), flushGuardMetaKey)
+	if err != nil {
+		g.logger.Error("Failed to read saved state", "error", err)
+	}
+	if ok && value != "" {
+		var trip FlushTrip
+		if err := json.Unmarshal([]byte(value), &trip); err == nil {
+			g.trip = &trip
+			g.logger.Error("ALERT: flushing is still paused - POST /api/v1/admin/flush/resume",
+				"since", trip.At.Format(time.RFC3339), "dropped", trip.Dropped, "checked", trip.Checked)
+		}
+	}
+	return g
+}
+
+// SetLogger replaces the guard's logger.
+func (g *FlushGuard) SetLogger(logger *slog.Logger) {
+	g.logger = logger
+}
+
+// Paused reports whether flushing is held back.
+func (g *FlushGuard) Paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.trip != nil
+}
+
+// Check inspects a batch before it is persisted and returns the items to
+// persist. ErrFlushPaused means nothing may be persisted.
+func (g *FlushGuard) Check(ctx context.Context, items []repository.InventoryItem) ([]repository.InventoryItem, repository.FlushStageOutcome, error) {
+	start := time.Now()
+	outcome := repository.FlushStageOutcome{Stage: "data_loss_guard", Status: "ok"}
+
+	g.mu.Lock()
+	paused := g.trip != nil
+	resumeAt, discardBefore := g.resumeAt, g.discardBefore
+	g.mu.Unlock()
+	if paused {
+		outcome.Status = "paused"
+		return nil, outcome, ErrFlushPaused
+	}
+
+	keep := make([]repository.InventoryItem, 0, len(items))
+	var checked, dropped int
+	var dropUsers, discardedUsers []string
+	for _, item := range items {
+		decided := !resumeAt.IsZero() && !item.SyncedAt.After(resumeAt)
+		if decided && !discardBefore {
+			keep = append(keep, item) // Approved on resume
+			continue
+		}
+
+		counted, isDrop := g.compare(ctx, item)
+		switch {
+		case isDrop && decided:
+			discardedUsers = appendCapped(discardedUsers, item.RobloxUserID+"/"+item.Section)
+			continue
+		case isDrop:
+			dropped++
+			dropUsers = appendCapped(dropUsers, item.RobloxUserID+"/"+item.Section)
+		}
+		if counted {
+			checked++
+		}
+		keep = append(keep, item)
+	}
+
+	g.checked.Add(int64(checked))
+	g.drops.Add(int64(dropped))
+	g.discarded.Add(int64(len(discardedUsers)))
+
+	outcome.Users = dropUsers
+	outcome.Detail = fmt.Sprintf("checked %d, dropped %d", checked, dropped)
+	if len(discardedUsers) > 0 {
+		outcome.Status = "discarded"
+		outcome.Users = append(outcome.Users, discardedUsers...)
+		outcome.Detail += fmt.Sprintf(", discarded %d", len(discardedUsers))
+	}
+
+	if trip := g.observe(ctx, checked, dropped, dropUsers); trip != nil {
+		outcome.Status = "tripped"
+		outcome.Detail = fmt.Sprintf("%d of %d checked items in %v were drops (%.0f%%) - flushing paused",
+			trip.Dropped, trip.Checked, g.cfg.Window, trip.Fraction*100)
+		outcome.DurationMs = time.Since(start).Milliseconds()
+		return nil, outcome, ErrFlushPaused
+	}
+	outcome.DurationMs = time.Since(start).Milliseconds()
+	return keep, outcome, nil
+}
+
+// compare reports whether an item could be compared with a stored copy and
+// whether it is a drastic shrink of it.
+func (g *FlushGuard) compare(ctx context.Context, item repository.InventoryItem) (counted, isDrop bool) {
+	stored, _, err := g.stored.GetRawInventorySection(ctx, item.RobloxUserID, item.Section)
+	if err != nil || stored == nil || len(stored) < g.cfg.MinStoredBytes {
+		return false, false
+	}
+
+	keep := 1 - g.cfg.DropRatio
+	if float64(len(item.RawJSON)) < float64(len(stored))*keep {
+		return true, true
+	}
+	storedCount := countElements(stored)
+	if storedCount >= flushGuardMinStoredItems && float64(countElements(item.RawJSON)) < float64(storedCount)*keep {
+		return true, true
+	}
+	return true, false
+}
+
+// observe adds a flush to the window and trips when the drop fraction
+// exceeds the threshold.
+func (g *FlushGuard) observe(ctx context.Context, checked, dropped int, users []string) *FlushTrip {
+	now := time.Now().UTC()
+
+	g.mu.Lock()
+	cutoff := now.Add(-g.cfg.Window)
+	kept := g.samples[:0]
+	for _, s := range g.samples {
+		if s.at.After(cutoff) {
+			kept = append(kept, s)
+		}
+	}
+	g.samples = append(kept, guardSample{at: now, checked: checked, dropped: dropped})
+
+	var totalChecked, totalDropped int
+	for _, s := range g.samples {
+		totalChecked += s.checked
+		totalDropped += s.dropped
+	}
+	if totalChecked < g.cfg.MinItems || totalChecked == 0 {
+		g.mu.Unlock()
+		return nil
+	}
+	fraction := float64(totalDropped) / float64(totalChecked)
+	if fraction <= g.cfg.TripFraction {
+		g.mu.Unlock()
+		return nil
+	}
+
+	trip := &FlushTrip{At: now, Checked: totalChecked, Dropped: totalDropped, Fraction: fraction, Users: users}
+	g.trip = trip
+	g.samples = nil
+	g.mu.Unlock()
+
+	g.trips.Add(1)
+	g.logger.ErrorContext(ctx, "ALERT: CRITICAL: too many items shrank - flushing PAUSED, data is held in Redis. Resume with POST /api/v1/admin/flush/resume",
+		"dropped", totalDropped, "checked", totalChecked, "window", g.cfg.Window, "drop_ratio", g.cfg.DropRatio)
+	g.save(ctx, trip)
+	return trip
+}
+
+// Resume lifts a pause. Writes buffered before now are accepted as they
+// are, or with discard, every one of them that is a drop is thrown away.
+func (g *FlushGuard) Resume(ctx context.Context, discard bool) (*FlushTrip, error) {
+	g.mu.Lock()
+	trip := g.trip
+	if trip == nil {
+		g.mu.Unlock()
+		return nil, ErrFlushNotPaused
+	}
+	g.trip = nil
+	g.samples = nil
+	g.resumeAt = time.Now().UTC()
+	g.discardBefore = discard
+	g.mu.Unlock()
+
+	g.save(ctx, nil)
+	g.logger.WarnContext(ctx, "Flushing resumed by admin", "discard", discard, "trip_at", trip.At.Format(time.RFC3339))
+	return trip, nil
+}
+
+// save persists the current trip, or clears it.
+func (g *FlushGuard) save(ctx context.Context, trip *FlushTrip) {
+	value := ""
+	if trip != nil {
+		data, _ := json.Marshal(trip)
+		value = string(data)
+	}
+	if err := g.meta.SetMeta(ctx, flushGuardMetaKey, value); err != nil {
+		g.logger.ErrorContext(ctx, "Failed to save state", "error", err)
+	}
+}
+
+// Stats returns guard counters for admin stats.
+func (g *FlushGuard) Stats(ctx context.Context) map[string]interface{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stats := map[string]interface{}{
+		"paused":         g.trip != nil,
+		"items_checked":  g.checked.Load(),
+		"drops":          g.drops.Load(),
+		"trips":          g.trips.Load(),
+		"discarded":      g.discarded.Load(),
+		"drop_ratio":     g.cfg.DropRatio,
+		"trip_fraction":  g.cfg.TripFraction,
+		"window_seconds": int64(g.cfg.Window.Seconds()),
+		"min_items":      g.cfg.MinItems,
+	}
+	if g.trip != nil {
+		stats["trip"] = g.trip
+	}
+	if !g.resumeAt.IsZero() {
+		stats["last_resume_at"] = g.resumeAt
+		stats["last_resume_discarded"] = g.discardBefore
+	}
+	return stats
+}
+
+// countElements counts array elements at any depth of a JSON document, a
+// format-agnostic stand-in for "number of items". Invalid JSON counts 0.
+func countElements(raw []byte) int {
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return 0
+	}
+	var count func(v interface{}) int
+	count = func(v interface{}) int {
+		n := 0
+		switch t := v.(type) {
+		case []interface{}:
+			n += len(t)
+			for _, e := range t {
+				n += count(e)
+			}
+		case map[string]interface{}:
+			for _, e := range t {
+				n += count(e)
+			}
+		}
+		return n
+	}
+	return count(doc)
+}
+
+// appendCapped appends unless the list is full.
+func appendCapped(list []string, v string) []string {
+	if len(list) >= flushGuardMaxUsers {
+		return list
+	}
+	return append(list, v)
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vinzhub-rest-api/internal/repository"
+)
+
+// newTestOutbox returns a SQLite repository in a temporary file, path
+// included so a test can reopen it as after a restart.
+func newTestOutbox(t *testing.T, path string) *repository.SQLiteInventoryRepository {
+	t.Helper()
+	repo, err := repository.NewSQLiteInventoryRepository(path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+func persistNothing(ctx context.Context, items []repository.InventoryItem) (*repository.UpsertStats, error) {
+	return &repository.UpsertStats{}, nil
+}
+
+// recordingEffect is a side effect failing while fail is set and recording
+// the users of the batches it handled.
+type recordingEffect struct {
+	fail  bool
+	calls int
+	users []string
+}
+
+func (e *recordingEffect) run(ctx context.Context, items []repository.InventoryItem) error {
+	e.calls++
+	if e.fail {
+		return errors.New("open cloud unavailable")
+	}
+	for _, item := range items {
+		e.users = append(e.users, item.RobloxUserID)
+	}
+	return nil
+}
+
+func TestSideEffectRetriesSurviveRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "inventory.db")
+	repo := newTestOutbox(t, path)
+
+	down := &recordingEffect{fail: true}
+	p := NewFlushPipeline(persistNothing)
+	p.AddSideEffect("opencloud_callback", down.run)
+	p.SetOutbox(repo)
+	if err := p.Run(ctx, []repository.InventoryItem{{RobloxUserID: "100", Callback: true}}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	stored, err := repo.ListOutboxBatches(ctx)
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("outbox = %d batches (err %v), want the failed batch", len(stored), err)
+	}
+	if stored[0].Effect != "opencloud_callback" || stored[0].Attempts != 1 || stored[0].LastError == "" {
+		t.Errorf("stored batch = %+v", stored[0])
+	}
+	repo.Close()
+
+	// A new process over the same file delivers the batch on startup
+	repo = newTestOutbox(t, path)
+	up := &recordingEffect{}
+	p = NewFlushPipeline(persistNothing)
+	p.SetOutbox(repo)
+	p.AddSideEffect("opencloud_callback", up.run)
+	loaded, err := p.DrainOutbox(ctx)
+	if err != nil || loaded != 1 {
+		t.Fatalf("DrainOutbox = %d, %v; want 1, nil", loaded, err)
+	}
+	if len(up.users) != 1 || up.users[0] != "100" {
+		t.Errorf("delivered users = %v, want [100]", up.users)
+	}
+	if left, _ := repo.ListOutboxBatches(ctx); len(left) != 0 {
+		t.Errorf("outbox still holds %d batches after a successful retry", len(left))
+	}
+}
+
+func TestSideEffectOutboxForgetsDroppedBatches(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestOutbox(t, filepath.Join(t.TempDir(), "inventory.db"))
+
+	down := &recordingEffect{fail: true}
+	p := NewFlushPipeline(persistNothing)
+	p.SetOutbox(repo)
+	p.AddSideEffect("opencloud_callback", down.run)
+
+	// The first batch is retried on every run until it runs out of attempts
+	p.Run(ctx, []repository.InventoryItem{{RobloxUserID: "100"}})
+	for i := 1; i < sideEffectMaxAttempts; i++ {
+		p.Run(ctx, nil)
+	}
+	batches, err := repo.ListOutboxBatches(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range batches {
+		if len(b.Items) == 1 && b.Items[0].RobloxUserID == "100" {
+			t.Errorf("batch dropped after %d attempts is still in the outbox", b.Attempts)
+		}
+	}
+	if got := p.effects[0].dropped.Load(); got != 1 {
+		t.Errorf("dropped = %d, want 1", got)
+	}
+}
+
+func TestDrainOutboxKeepsUnconfiguredEffects(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestOutbox(t, filepath.Join(t.TempDir(), "inventory.db"))
+	if _, err := repo.InsertOutboxBatch(ctx, &repository.OutboxBatch{Effect: "retired", Items: []repository.InventoryItem{{RobloxUserID: "1"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewFlushPipeline(persistNothing)
+	p.SetOutbox(repo)
+	if loaded, err := p.DrainOutbox(ctx); err != nil || loaded != 0 {
+		t.Fatalf("DrainOutbox = %d, %v; want 0, nil", loaded, err)
+	}
+	if left, _ := repo.ListOutboxBatches(ctx); len(left) != 1 {
+		t.Errorf("outbox = %d batches, want the unconfigured one kept", len(left))
+	}
+}
+
+// flushLogRecorder keeps flush log entries in memory.
+type flushLogRecorder struct {
+	entries []*repository.FlushLogEntry
+}
+
+func (r *flushLogRecorder) InsertFlushLog(ctx context.Context, entry *repository.FlushLogEntry) error {
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func TestFlushPipelineFailureCombinations(t *testing.T) {
+	failing := func(ctx context.Context, items []repository.InventoryItem) error { return errors.New("mysql down") }
+	panicking := func(ctx context.Context, items []repository.InventoryItem) error { panic("nil map") }
+	ok := func(ctx context.Context, items []repository.InventoryItem) error { return nil }
+
+	tests := []struct {
+		name       string
+		persistErr error
+		lastSync   FlushStageFunc
+		callback   FlushStageFunc
+		wantErr    bool
+		wantStatus [3]string // persist, key_account_sync, opencloud_callback
+		wantQueued [2]int
+	}{
+		{"all succeed", nil, ok, ok, false, [3]string{"ok", "ok", "ok"}, [2]int{0, 0}},
+		{"persist fails", errors.New("disk full"), ok, ok, true, [3]string{"failed", "skipped", "skipped"}, [2]int{0, 0}},
+		{"last sync fails", nil, failing, ok, false, [3]string{"ok", "failed", "ok"}, [2]int{1, 0}},
+		{"callback fails", nil, ok, failing, false, [3]string{"ok", "ok", "failed"}, [2]int{0, 1}},
+		{"both side effects fail", nil, failing, failing, false, [3]string{"ok", "failed", "failed"}, [2]int{1, 1}},
+		{"side effect panics", nil, panicking, ok, false, [3]string{"ok", "failed", "ok"}, [2]int{1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			persisted := 0
+			p := NewFlushPipeline(func(ctx context.Context, items []repository.InventoryItem) (*repository.UpsertStats, error) {
+				if tt.persistErr != nil {
+					return nil, tt.persistErr
+				}
+				persisted += len(items)
+				return &repository.UpsertStats{}, nil
+			})
+			flushLog := &flushLogRecorder{}
+			p.SetFlushLog(flushLog)
+			p.AddSideEffect("key_account_sync", tt.lastSync)
+			p.AddSideEffect("opencloud_callback", tt.callback)
+
+			err := p.Run(context.Background(), []repository.InventoryItem{{KeyAccountID: 1, RobloxUserID: "100"}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run err = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && persisted != 1 {
+				t.Errorf("persisted %d items, want 1", persisted)
+			}
+
+			if len(flushLog.entries) != 1 {
+				t.Fatalf("%d flush log entries, want 1", len(flushLog.entries))
+			}
+			entry := flushLog.entries[0]
+			if entry.Persisted == tt.wantErr {
+				t.Errorf("flush log persisted = %v", entry.Persisted)
+			}
+			if len(entry.Stages) != 3 {
+				t.Fatalf("stages = %+v, want 3", entry.Stages)
+			}
+			for i, stage := range entry.Stages {
+				if stage.Status != tt.wantStatus[i] {
+					t.Errorf("stage %s = %s, want %s", stage.Stage, stage.Status, tt.wantStatus[i])
+				}
+			}
+			for i, e := range p.effects {
+				if got := len(e.queue); got != tt.wantQueued[i] {
+					t.Errorf("%s queued %d batches, want %d", e.name, got, tt.wantQueued[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSideEffectRetriedOnNextFlush(t *testing.T) {
+	effect := &recordingEffect{fail: true}
+	p := NewFlushPipeline(persistNothing)
+	p.AddSideEffect("key_account_sync", effect.run)
+	ctx := context.Background()
+
+	p.Run(ctx, []repository.InventoryItem{{RobloxUserID: "100"}})
+	effect.fail = false
+	p.Run(ctx, []repository.InventoryItem{{RobloxUserID: "200"}})
+
+	if len(effect.users) != 2 || effect.users[0] != "100" || effect.users[1] != "200" {
+		t.Errorf("delivered users = %v, want the queued batch retried before the new one", effect.users)
+	}
+	if queued := len(p.effects[0].queue); queued != 0 {
+		t.Errorf("%d batches still queued", queued)
+	}
+}
+
+func TestFlushPipelineLogsThroughSetLogger(t *testing.T) {
+	var logs bytes.Buffer
+	effect := &recordingEffect{fail: true}
+	p := NewFlushPipeline(persistNothing)
+	p.AddSideEffect("opencloud_callback", effect.run)
+	p.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	p.Run(context.Background(), []repository.InventoryItem{{RobloxUserID: "100"}})
+
+	out := logs.String()
+	if !strings.Contains(out, "Side effect failed") || !strings.Contains(out, "effect=opencloud_callback") {
+		t.Errorf("side effect added before SetLogger didn't log through it:\n%s", out)
+	}
+}
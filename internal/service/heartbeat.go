@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"vinzhub-rest-api/internal/lifecycle"
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/repository"
+)
+
+const (
+	// HeartbeatFlushInterval is how often buffered heartbeats are written.
+	HeartbeatFlushInterval = time.Minute
+	// HeartbeatOfflineAfter is how long without a heartbeat marks an
+	// account offline.
+	HeartbeatOfflineAfter = 3 * time.Minute
+	// heartbeatTimeout bounds one flush or sweep.
+	heartbeatTimeout = 30 * time.Second
+)
+
+// HeartbeatRecorder keeps key accounts' is_online and last_heartbeat_at
+// current. Heartbeats are buffered in memory, latest per account, and
+// written in one batch per flush interval, so clients beating every few
+// seconds cost one write per account per interval. After each flush, a
+// sweep marks accounts offline whose last heartbeat is older than the
+// offline window.
+type HeartbeatRecorder struct {
+	repo         repository.KeyAccountHeartbeats
+	every        time.Duration
+	offlineAfter time.Duration
+	logger       *slog.Logger
+
+	mu          sync.Mutex
+	pending     map[int64]time.Time
+	beats       int64
+	written     int64
+	markedOff   int64
+	lastFlushAt time.Time
+	lastError   string
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewHeartbeatRecorder creates a recorder flushing every `every`
+// (HeartbeatFlushInterval when <= 0) and marking accounts offline after
+// offlineAfter without a heartbeat (HeartbeatOfflineAfter when <= 0). The
+// window is kept above the flush interval, or accounts would flap offline
+// between flushes.
+func NewHeartbeatRecorder(repo repository.KeyAccountHeartbeats, every, offlineAfter time.Duration) *HeartbeatRecorder {
+	if every <= 0 {
+		every = HeartbeatFlushInterval
+	}
+	if offlineAfter <= 0 {
+		offlineAfter = HeartbeatOfflineAfter
+	}
+	if offlineAfter < 2*every {
+		offlineAfter = 2 * every
+	}
+	return &HeartbeatRecorder{
+		repo:         repo,
+		every:        every,
+		offlineAfter: offlineAfter,
+		logger:       logging.Component("Heartbeat"),
+		pending:      make(map[int64]time.Time),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Start flushes and sweeps in the background until Close.
+func (h *HeartbeatRecorder) Start() {
+	lifecycle.Go("heartbeat", func() {
+		h.loop()
+		close(h.done) // Not deferred: a panicking loop is restarted
+	})
+	h.logger.Info("Started", "flush_every", h.every, "offline_after", h.offlineAfter)
+}
+
+// Beat records a heartbeat of a key account, written on the next flush.
+func (h *HeartbeatRecorder) Beat(keyAccountID int64) {
+	h.mu.Lock()
+	h.pending[keyAccountID] = time.Now()
+	h.beats++
+	h.mu.Unlock()
+}
+
+// loop flushes and sweeps every interval until Close.
+func (h *HeartbeatRecorder) loop() {
+	ticker := time.NewTicker(h.every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			lifecycle.Touch("heartbeat")
+			ctx, cancel := context.WithTimeout(context.Background(), heartbeatTimeout)
+			if err := h.Flush(ctx); err != nil {
+				h.logger.Error("Flush failed", "error", err)
+			} else if err := h.sweep(ctx); err != nil {
+				h.logger.Error("Offline sweep failed", "error", err)
+			}
+			cancel()
+		case <-h.stop:
+			return
+		}
+	}
+}
+
+// Flush writes the buffered heartbeats. On failure they are put back,
+// unless a newer heartbeat arrived meanwhile.
+func (h *HeartbeatRecorder) Flush(ctx context.Context) error {
+	h.mu.Lock()
+	pending := h.pending
+	h.pending = make(map[int64]time.Time, len(pending))
+	h.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	beats := make([]repository.KeyAccountHeartbeat, 0, len(pending))
+	for id, at := range pending {
+		beats = append(beats, repository.KeyAccountHeartbeat{KeyAccountID: id, At: at})
+	}
+	err := h.repo.UpdateHeartbeat(ctx, beats)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastFlushAt = time.Now()
+	if err != nil {
+		h.lastError = err.Error()
+		for id, at := range pending {
+			if _, newer := h.pending[id]; !newer {
+				h.pending[id] = at
+			}
+		}
+		return err
+	}
+	h.lastError = ""
+	h.written += int64(len(beats))
+	return nil
+}
+
+// sweep marks accounts offline after the offline window without a
+// heartbeat.
+func (h *HeartbeatRecorder) sweep(ctx context.Context) error {
+	n, err := h.repo.MarkOffline(ctx, time.Now().Add(-h.offlineAfter))
+	if err != nil {
+		h.mu.Lock()
+		h.lastError = err.Error()
+		h.mu.Unlock()
+		return err
+	}
+	if n > 0 {
+		h.mu.Lock()
+		h.markedOff += n
+		h.mu.Unlock()
+		h.logger.DebugContext(ctx, "Marked key accounts offline", "accounts", n)
+	}
+	return nil
+}
+
+// Close stops the background loop and writes the heartbeats still buffered.
+func (h *HeartbeatRecorder) Close() {
+	h.stopOnce.Do(func() {
+		close(h.stop)
+		<-h.done
+		ctx, cancel := context.WithTimeout(context.Background(), heartbeatTimeout)
+		defer cancel()
+		if err := h.Flush(ctx); err != nil {
+			h.logger.Error("Final flush failed", "error", err)
+		}
+	})
+}
+
+// Stats returns heartbeat counters for admin stats.
+func (h *HeartbeatRecorder) Stats(ctx context.Context) map[string]interface{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stats := map[string]interface{}{
+		"flush_every_seconds":   h.every.Seconds(),
+		"offline_after_seconds": h.offlineAfter.Seconds(),
+		"pending":               len(h.pending),
+		"beats":                 h.beats,
+		"written":               h.written,
+		"marked_offline":        h.markedOff,
+	}
+	if !h.lastFlushAt.IsZero() {
+		stats["last_flush_at"] = h.lastFlushAt
+	}
+	if h.lastError != "" {
+		stats["last_error"] = h.lastError
+	}
+	return stats
+}
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vinzhub-rest-api/internal/lifecycle"
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/repository"
+)
+
+const (
+	// integrityCursorMetaKey persists the scan position as "partition:row_id"
+	// so a restart resumes where the last run stopped.
+	integrityCursorMetaKey = "integrity_cursor"
+
+	// integrityCheckMetaKey records when PRAGMA integrity_check last ran.
+	integrityCheckMetaKey = "integrity_check_at"
+
+	// integrityCheckEvery is how often PRAGMA integrity_check runs.
+	integrityCheckEvery = 7 * 24 * time.Hour
+
+	// integrityStepTimeout bounds one verification batch.
+	integrityStepTimeout = 30 * time.Second
+)
+
+// IntegrityVerifier walks stored rows in small batches, recomputes each
+// row's content hash and records mismatches. It also runs SQLite's own
+// integrity check weekly. Batches are skipped while a flush is running so
+// verification never competes with writes.
+type IntegrityVerifier struct {
+	store     repository.InventoryStore
+	primary   *repository.SQLiteInventoryRepository
+	batchSize int
+	busy      func() bool
+	logger    *slog.Logger
+
+	mu       sync.Mutex // serializes batches, checks and re-verification
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	scanned     atomic.Int64
+	mismatches  atomic.Int64
+	skippedBusy atomic.Int64
+	passes      atomic.Int64
+	lastPassAt  atomic.Int64 // unix seconds
+}
+
+// NewIntegrityVerifier creates a verifier over the inventory store. Issues
+// and the scan position are kept in the primary database.
+func NewIntegrityVerifier(store repository.InventoryStore, primary *repository.SQLiteInventoryRepository, batchSize int) *IntegrityVerifier {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	return &IntegrityVerifier{
+		store:     store,
+		primary:   primary,
+		batchSize: batchSize,
+		logger:    logging.Component("IntegrityVerifier"),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// SetLogger replaces the verifier's logger.
+func (v *IntegrityVerifier) SetLogger(logger *slog.Logger) {
+	v.logger = logger
+}
+
+// SetBusyFunc sets the check that makes the verifier skip a batch, e.g.
+// FlushPipeline.Active.
+func (v *IntegrityVerifier) SetBusyFunc(busy func() bool) {
+	v.busy = busy
+}
+
+// Start verifies one batch every interval until Close.
+func (v *IntegrityVerifier) Start(interval time.Duration) {
+	lifecycle.Go("integrity", func() {
+		v.loop(interval)
+		close(v.done) // Not deferred: a panicking loop is restarted
+	})
+	v.logger.Info("Started", "interval", interval, "batch", v.batchSize)
+}
+
+// loop verifies one batch every interval until Close.
+func (v *IntegrityVerifier) loop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			lifecycle.Touch("integrity")
+			if v.busy != nil && v.busy() {
+				v.skippedBusy.Add(1)
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), integrityStepTimeout)
+			if err := v.Step(ctx); err != nil {
+				v.logger.ErrorContext(ctx, "Batch failed", "error", err)
+			}
+			if err := v.checkIfDue(ctx); err != nil {
+				v.logger.ErrorContext(ctx, "Integrity check failed", "error", err)
+			}
+			cancel()
+		case <-v.stop:
+			return
+		}
+	}
+}
+
+// Close stops the background loop and waits for a running batch.
+func (v *IntegrityVerifier) Close() {
+	v.stopOnce.Do(func() {
+		close(v.stop)
+		<-v.done
+	})
+}
+
+// Step verifies the next batch of rows and advances the stored cursor,
+// moving to the next partition (and wrapping around) at the end of one.
+func (v *IntegrityVerifier) Step(ctx context.Context) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	partitions := v.store.Partitions()
+	partition, afterID := v.readCursor(ctx)
+	if partition >= len(partitions) {
+		partition, afterID = 0, 0 // Shard count changed since the cursor was saved
+	}
+
+	lastID, scanned, mismatches, err := partitions[partition].VerifyHashBatch(ctx, afterID, v.batchSize)
+	if err != nil {
+		return err
+	}
+	v.scanned.Add(int64(scanned))
+
+	for _, m := range mismatches {
+		v.mismatches.Add(1)
+		created, err := v.primary.RecordIntegrityIssue(ctx, &repository.IntegrityIssue{
+			Kind:         repository.IntegrityHashMismatch,
+			Partition:    partition,
+			RowID:        m.RowID,
+			RobloxUserID: m.RobloxUserID,
+			Section:      m.Section,
+			StoredHash:   m.StoredHash,
+			ActualHash:   m.ActualHash,
+		})
+		if err != nil {
+			return err
+		}
+		if created {
+			v.logger.ErrorContext(ctx, "ALERT: hash mismatch", "partition", partition, "row_id", m.RowID,
+				"roblox_user_id", m.RobloxUserID, "section", m.Section, "stored_hash", m.StoredHash, "actual_hash", m.ActualHash)
+		}
+	}
+
+	if scanned < v.batchSize {
+		// End of this partition - continue with the next one
+		partition, lastID = partition+1, 0
+		if partition >= len(partitions) {
+			partition = 0
+			v.passes.Add(1)
+			v.lastPassAt.Store(time.Now().Unix())
+		}
+	}
+	return v.primary.SetMeta(ctx, integrityCursorMetaKey, fmt.Sprintf("%d:%d", partition, lastID))
+}
+
+// readCursor returns the stored scan position, or the start when unset.
+func (v *IntegrityVerifier) readCursor(ctx context.Context) (int, int64) {
+	value, ok, err := v.primary.GetMeta(ctx, integrityCursorMetaKey)
+	if err != nil || !ok {
+		return 0, 0
+	}
+	p, id, found := strings.Cut(value, ":")
+	if !found {
+		return 0, 0
+	}
+	partition, err1 := strconv.Atoi(p)
+	afterID, err2 := strconv.ParseInt(id, 10, 64)
+	if err1 != nil || err2 != nil || partition < 0 {
+		return 0, 0
+	}
+	return partition, afterID
+}
+
+// checkIfDue runs PRAGMA integrity_check when the last run is older than a week.
+func (v *IntegrityVerifier) checkIfDue(ctx context.Context) error {
+	value, ok, err := v.primary.GetMeta(ctx, integrityCheckMetaKey)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if last, err := time.Parse(time.RFC3339, value); err == nil && time.Since(last) < integrityCheckEvery {
+			return nil
+		}
+	}
+	return v.RunIntegrityCheck(ctx)
+}
+
+// RunIntegrityCheck runs PRAGMA integrity_check on every database file and
+// records any problems it reports.
+func (v *IntegrityVerifier) RunIntegrityCheck(ctx context.Context) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for partition, db := range v.databases() {
+		problems, err := db.IntegrityCheck(ctx)
+		if err != nil {
+			return err
+		}
+		if len(problems) == 0 {
+			continue
+		}
+		created, err := v.primary.RecordIntegrityIssue(ctx, &repository.IntegrityIssue{
+			Kind:      repository.IntegrityCheckFailed,
+			Partition: partition,
+			Detail:    strings.Join(problems, "\n"),
+		})
+		if err != nil {
+			return err
+		}
+		if created {
+			v.logger.ErrorContext(ctx, "ALERT: integrity_check reported problems", "problems", len(problems),
+				"partition", partition, "first", problems[0])
+		}
+	}
+	return v.primary.SetMeta(ctx, integrityCheckMetaKey, time.Now().UTC().Format(time.RFC3339))
+}
+
+// databases returns every database file keyed by partition, with the
+// primary as -1 when it isn't one of the partitions.
+func (v *IntegrityVerifier) databases() map[int]*repository.SQLiteInventoryRepository {
+	dbs := make(map[int]*repository.SQLiteInventoryRepository)
+	primaryIsPartition := false
+	for i, db := range v.store.Partitions() {
+		dbs[i] = db
+		if db == v.primary {
+			primaryIsPartition = true
+		}
+	}
+	if !primaryIsPartition {
+		dbs[-1] = v.primary
+	}
+	return dbs
+}
+
+// Issues lists a page of recorded issues, newest first. An empty status lists all.
+func (v *IntegrityVerifier) Issues(ctx context.Context, status string, page repository.PageQuery) ([]repository.IntegrityIssue, error) {
+	return v.primary.ListIntegrityIssues(ctx, status, page)
+}
+
+// Reverify checks an issue again. Issues that no longer reproduce (row
+// fixed, rewritten or deleted) are resolved.
+func (v *IntegrityVerifier) Reverify(ctx context.Context, id int64) (*repository.IntegrityIssue, error) {
+	issue, err := v.primary.GetIntegrityIssue(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mu.Lock()
+	db, ok := v.databases()[issue.Partition]
+	status, actualHash := issue.Status, ""
+	switch {
+	case !ok:
+		status = repository.IntegrityResolved // Partition no longer exists
+	case issue.Kind == repository.IntegrityHashMismatch:
+		var m *repository.HashMismatch
+		m, err = db.VerifyRow(ctx, issue.RowID)
+		if m == nil {
+			status = repository.IntegrityResolved
+		} else {
+			actualHash = m.ActualHash
+		}
+	default:
+		var problems []string
+		problems, err = db.IntegrityCheck(ctx)
+		if len(problems) == 0 {
+			status = repository.IntegrityResolved
+		}
+	}
+	v.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := v.primary.UpdateIntegrityIssue(ctx, id, status, actualHash); err != nil {
+		return nil, err
+	}
+	return v.primary.GetIntegrityIssue(ctx, id)
+}
+
+// Acknowledge marks an issue as seen. It stays listed until re-verified clean.
+func (v *IntegrityVerifier) Acknowledge(ctx context.Context, id int64) (*repository.IntegrityIssue, error) {
+	if err := v.primary.UpdateIntegrityIssue(ctx, id, repository.IntegrityAcknowledged, ""); err != nil {
+		return nil, err
+	}
+	return v.primary.GetIntegrityIssue(ctx, id)
+}
+
+// Stats returns verifier counters for admin stats.
+func (v *IntegrityVerifier) Stats(ctx context.Context) map[string]interface{} {
+	stats := map[string]interface{}{
+		"rows_scanned":         v.scanned.Load(),
+		"mismatches_found":     v.mismatches.Load(),
+		"batches_skipped_busy": v.skippedBusy.Load(),
+		"passes":               v.passes.Load(),
+	}
+	if last := v.lastPassAt.Load(); last > 0 {
+		stats["last_pass_at"] = time.Unix(last, 0).UTC()
+	}
+	partition, afterID := v.readCursor(ctx)
+	stats["cursor"] = map[string]interface{}{"partition": partition, "after_row_id": afterID}
+	if value, ok, err := v.primary.GetMeta(ctx, integrityCheckMetaKey); err == nil && ok {
+		stats["last_integrity_check_at"] = value
+	}
+	if counts, err := v.primary.CountIntegrityIssues(ctx); err == nil {
+		stats["issues"] = counts
+	}
+	return stats
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"vinzhub-rest-api/internal/cache"
+)
+
+// tokenHashPrefixLen is how many hex digits of a token's SHA-256 identify
+// it in token invalidations: enough to never evict the wrong token in
+// practice, without publishing anything a token could be matched against.
+const tokenHashPrefixLen = 16
+
+// tokenHashPrefix returns the invalidation key of a token.
+func tokenHashPrefix(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])[:tokenHashPrefixLen]
+}
+
+// SetInvalidationBus tells other instances about syncs and key-account
+// changes made here, and evicts this instance's tombstones, existence
+// answers and key-account lookups on theirs. bus may be nil.
+func (s *InventoryService) SetInvalidationBus(bus *cache.InvalidationBus) {
+	s.bus = bus
+	bus.Subscribe(cache.InvalidateInventory, s.forgetUser)
+	bus.Subscribe(cache.InvalidateKeyAccount, s.forgetKeyAccount)
+	bus.OnFlush(s.flushCaches)
+}
+
+// forgetUser drops every cached read answer about a user.
+func (s *InventoryService) forgetUser(ctx context.Context, robloxUserID string) {
+	for _, section := range s.sections {
+		s.reads.forget(ctx, robloxUserID, section)
+	}
+	s.forgetExistence(ctx, robloxUserID)
+}
+
+// forgetKeyAccount drops a cached key-account lookup.
+func (s *InventoryService) forgetKeyAccount(ctx context.Context, robloxUserID string) {
+	if s.lookupCache != nil {
+		s.lookupCache.Delete(ctx, keyAccountCachePrefix+robloxUserID)
+	}
+}
+
+// flushCaches empties every cache the service reads from, each once even
+// when they share a store.
+func (s *InventoryService) flushCaches(ctx context.Context) {
+	if s.reads.sections != nil {
+		s.reads.sections.clear()
+	}
+	cleared := make(map[cache.Cache]bool, 3)
+	for _, c := range []cache.Cache{s.lookupCache, s.reads.cache, s.existence.cache} {
+		if c != nil && !cleared[c] {
+			c.Clear(ctx)
+			cleared[c] = true
+		}
+	}
+}
+
+// SetInvalidationBus tells other instances about revocations made here and
+// evicts this instance's cached validations on theirs. bus may be nil.
+func (s *TokenService) SetInvalidationBus(bus *cache.InvalidationBus) {
+	s.bus = bus
+	bus.Subscribe(cache.InvalidateToken, func(ctx context.Context, prefix string) {
+		if s.cache != nil {
+			s.cache.forgetPrefix(prefix)
+		}
+		s.requestRevocationRefresh()
+	})
+	bus.OnFlush(func(ctx context.Context) {
+		if s.cache != nil {
+			s.cache.clear()
+		}
+	})
+}
+
+// publishRevoked tells other instances that tokens were revoked.
+func (s *TokenService) publishRevoked(ctx context.Context, tokens ...string) {
+	for _, token := range tokens {
+		s.bus.Publish(ctx, cache.InvalidateToken, tokenHashPrefix(token))
+	}
+}
@@ -2,17 +2,119 @@ package service
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"strconv"
 	"time"
 
 	"vinzhub-rest-api/internal/cache"
+	"vinzhub-rest-api/internal/domain"
+	"vinzhub-rest-api/internal/logging"
 	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/tracing"
 )
 
+// ErrUnknownSection is returned when a sync or read names a section that
+// isn't in the configured section list.
+var ErrUnknownSection = errors.New("unknown inventory section")
+
+// ErrInvalidRobloxUserID is returned for a roblox user ID that isn't a
+// decimal number. Buffer fields join the ID and section with ":", so an ID
+// like "123:settings" would address another user's section.
+var ErrInvalidRobloxUserID = errors.New("roblox user ID must be numeric")
+
+// ValidRobloxUserID reports whether id looks like a roblox user ID.
+func ValidRobloxUserID(id string) bool {
+	if id == "" || len(id) > 20 {
+		return false
+	}
+	_, err := strconv.ParseUint(id, 10, 64)
+	return err == nil
+}
+
+// ErrNoKeyAccount is returned in strict mode when a sync has no active key account.
+var ErrNoKeyAccount = errors.New("no active key account for this roblox user")
+
+// ErrKeyAccountUnavailable is returned in strict mode when the key account
+// lookup fails and outages are configured to reject rather than allow.
+var ErrKeyAccountUnavailable = errors.New("key account lookup unavailable")
+
+const (
+	// negativeKeyAccountTTL is how long a "no key account" answer is cached.
+	// Kept short so a newly linked account can sync almost immediately.
+	negativeKeyAccountTTL = 30 * time.Second
+
+	keyAccountCachePrefix = "keyaccount:roblox:"
+)
+
+// KeyAccountPolicy controls how syncs are associated with key accounts.
+type KeyAccountPolicy struct {
+	// Required rejects syncs for roblox users without an active key account.
+	Required bool
+	// AllowOnError lets syncs through (unlinked) when the lookup itself
+	// fails, so an auth-DB outage doesn't block every sync.
+	AllowOnError bool
+	// CacheTTL is how long a positive lookup is cached.
+	CacheTTL time.Duration
+}
+
 // InventoryService handles inventory business logic.
 type InventoryService struct {
 	inventoryRepo  repository.InventoryRepository
 	keyAccountRepo repository.KeyAccountRepository
-	buffer         *cache.RedisInventoryBuffer
+	buffer         cache.Buffer
+	sections       []string
+	lookupCache    cache.Cache
+	keyPolicy      KeyAccountPolicy
+	payloadPolicy  PayloadPolicy
+	payloadSchema  *PayloadSchema
+	itemCounter    *ItemCounter
+	throttle       cache.Throttle // nil disables the per-user sync throttle
+	throttleEvery  time.Duration
+	reads          readCache
+	existence      existenceCache
+	bus            *cache.InvalidationBus // nil in single-instance deployments
+	logger         *slog.Logger
+}
+
+// SyncRequest describes a single inventory sync.
+type SyncRequest struct {
+	RobloxUserID string
+	Section       string // Empty means domain.DefaultSection
+	RawJSON       []byte
+	ClientVersion string // Optional, recorded with the buffered entry
+	// KeyAccountID skips the lookup when the caller already knows the
+	// account (token-authenticated syncs carry it in the token).
+	KeyAccountID int64
+	// Durable writes straight to the database, bypassing the buffer.
+	Durable bool
+	// Callback asks for a persisted notification to the game after the
+	// buffered entry is flushed.
+	Callback bool
+	// AllowEmpty lets an empty object or array overwrite stored data.
+	AllowEmpty bool
+	// Unthrottled skips the per-user sync throttle.
+	Unthrottled bool
+}
+
+// SyncResult describes where an accepted sync landed.
+type SyncResult struct {
+	// Buffered is true when the write is only in the Redis buffer and
+	// will be persisted by a later flush.
+	Buffered bool
+	// FlushWindow estimates how long until a buffered write is persisted.
+	FlushWindow time.Duration
+	// QueueDepth is the number of buffered entries when the write landed.
+	QueueDepth int64
+	// Unchanged is true when the section already held this document (and
+	// key account), so nothing was written.
+	Unchanged bool
+}
+
+// SectionData is one section of a user's inventory as seen by readers.
+type SectionData struct {
+	RawJSON  []byte
+	SyncedAt *time.Time
 }
 
 // NewInventoryService creates a new inventory service.
@@ -28,15 +130,18 @@ func NewInventoryService(
 	return &InventoryService{
 		inventoryRepo:  inventoryRepo,
 		keyAccountRepo: keyAccountRepo, // Optional, can be nil
+		sections:       []string{domain.DefaultSection},
+		logger:         logging.Component("InventoryService"),
 	}
 }
 
-// NewInventoryServiceWithBuffer creates a new inventory service with Redis buffer.
-// Redis buffer is REQUIRED. inventoryRepo can be nil (Redis-only mode).
+// NewInventoryServiceWithBuffer creates a new inventory service with a
+// write-behind buffer (Redis, or Redis with an in-memory fallback).
+// The buffer is REQUIRED. inventoryRepo can be nil (Redis-only mode).
 func NewInventoryServiceWithBuffer(
 	inventoryRepo repository.InventoryRepository,
 	keyAccountRepo repository.KeyAccountRepository,
-	buffer *cache.RedisInventoryBuffer,
+	buffer cache.Buffer,
 ) *InventoryService {
 	if buffer == nil {
 		return nil // Redis buffer is required for high-traffic
@@ -45,43 +150,470 @@ func NewInventoryServiceWithBuffer(
 		inventoryRepo:  inventoryRepo, // Can be nil - flush will skip
 		keyAccountRepo: keyAccountRepo,
 		buffer:         buffer,
+		sections:       []string{domain.DefaultSection},
+		logger:         logging.Component("InventoryService"),
 	}
 }
 
-// SetBuffer sets the Redis buffer for write-behind caching.
-func (s *InventoryService) SetBuffer(buffer *cache.RedisInventoryBuffer) {
+// SetLogger replaces the service's logger.
+func (s *InventoryService) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// SetBuffer sets the buffer for write-behind caching.
+func (s *InventoryService) SetBuffer(buffer cache.Buffer) {
 	s.buffer = buffer
 }
 
-// SyncRawInventory stores raw JSON inventory data.
+// SetSections sets the section names clients may sync and read.
+// The default section is always allowed, whether listed or not.
+func (s *InventoryService) SetSections(sections []string) {
+	allowed := []string{domain.DefaultSection}
+	for _, section := range sections {
+		if section != "" && section != domain.DefaultSection {
+			allowed = append(allowed, section)
+		}
+	}
+	s.sections = allowed
+}
+
+// SetKeyAccountPolicy configures key-account association for syncs.
+// lookupCache caches lookups and may be nil.
+func (s *InventoryService) SetKeyAccountPolicy(policy KeyAccountPolicy, lookupCache cache.Cache) {
+	s.keyPolicy = policy
+	s.lookupCache = lookupCache
+}
+
+// SetNegativeCache enables tombstones for reads that found nothing, so
+// repeat misses skip Redis and SQLite for ttl. Keep ttl to a few seconds: a
+// sync on another instance isn't seen here until the tombstone expires,
+// unless an invalidation bus is set.
+func (s *InventoryService) SetNegativeCache(c cache.Cache, ttl time.Duration) {
+	if ttl <= 0 {
+		c = nil
+	}
+	s.reads.cache = c
+	s.reads.ttl = ttl
+}
+
+// SetReadCache serves repeat section reads from memory for cfg.TTL, so
+// consumers polling the same users skip Redis and SQLite. A sync clears
+// the section here, and on other instances through the invalidation bus.
+// A zero TTL disables it.
+func (s *InventoryService) SetReadCache(cfg InventoryCacheConfig) {
+	if cfg.TTL <= 0 {
+		s.reads.sections = nil
+		return
+	}
+	s.reads.sections = newInventoryCache(cfg)
+}
+
+// Stats returns read cache counters for admin stats.
+func (s *InventoryService) Stats(ctx context.Context) map[string]interface{} {
+	stats := s.reads.stats()
+	stats["existence_cache"] = s.existence.stats()
+	return stats
+}
+
+// InvalidateKeyAccount drops the cached key-account lookup for a roblox user.
+func (s *InventoryService) InvalidateKeyAccount(ctx context.Context, robloxUserID string) {
+	s.forgetKeyAccount(ctx, robloxUserID)
+	s.bus.Publish(ctx, cache.InvalidateKeyAccount, robloxUserID)
+}
+
+// lookupKeyAccount resolves a roblox user's key account, using the cache.
+// Returns repository.ErrKeyAccountNotFound when there is none.
+func (s *InventoryService) lookupKeyAccount(ctx context.Context, robloxUserID string) (int64, error) {
+	if s.keyAccountRepo == nil {
+		return 0, errors.New("key account repository not configured")
+	}
+
+	cacheKey := keyAccountCachePrefix + robloxUserID
+	if s.lookupCache != nil {
+		if cached, err := s.lookupCache.Get(ctx, cacheKey); err == nil {
+			id, _ := strconv.ParseInt(string(cached), 10, 64)
+			if id == 0 {
+				return 0, repository.ErrKeyAccountNotFound
+			}
+			return id, nil
+		}
+	}
+
+	id, err := s.keyAccountRepo.GetKeyAccountByRobloxUser(ctx, robloxUserID)
+	switch {
+	case err == nil:
+		if s.lookupCache != nil && s.keyPolicy.CacheTTL > 0 {
+			s.lookupCache.Set(ctx, cacheKey, []byte(strconv.FormatInt(id, 10)), s.keyPolicy.CacheTTL)
+		}
+		return id, nil
+	case errors.Is(err, repository.ErrKeyAccountNotFound):
+		if s.lookupCache != nil {
+			s.lookupCache.Set(ctx, cacheKey, []byte("0"), negativeKeyAccountTTL)
+		}
+		return 0, err
+	default:
+		return 0, err
+	}
+}
+
+// resolveKeyAccount returns the key account ID to store with a sync,
+// enforcing the strict-mode policy.
+func (s *InventoryService) resolveKeyAccount(ctx context.Context, req SyncRequest) (int64, error) {
+	if req.KeyAccountID != 0 {
+		return req.KeyAccountID, nil
+	}
+
+	id, err := s.lookupKeyAccount(ctx, req.RobloxUserID)
+	switch {
+	case err == nil:
+		return id, nil
+	case errors.Is(err, repository.ErrKeyAccountNotFound):
+		if s.keyPolicy.Required {
+			return 0, ErrNoKeyAccount
+		}
+		return 0, nil
+	default:
+		if s.keyPolicy.Required && !s.keyPolicy.AllowOnError {
+			return 0, ErrKeyAccountUnavailable
+		}
+		if s.keyPolicy.Required {
+			s.logger.WarnContext(ctx, "Key account lookup failed, allowing unlinked sync", "roblox_user_id", req.RobloxUserID, "error", err)
+		}
+		return 0, nil
+	}
+}
+
+// Sections returns the configured section names, default section first.
+func (s *InventoryService) Sections() []string {
+	return s.sections
+}
+
+// resolveSection validates a section name, mapping empty to the default.
+func (s *InventoryService) resolveSection(section string) (string, error) {
+	if section == "" {
+		return domain.DefaultSection, nil
+	}
+	for _, allowed := range s.sections {
+		if section == allowed {
+			return section, nil
+		}
+	}
+	return "", ErrUnknownSection
+}
+
+// SyncRawInventory stores raw JSON inventory data in the default section.
 // If buffer is set, writes to Redis first (fast), otherwise direct to DB.
 // Safe to call even if keyAccountRepo is nil.
 func (s *InventoryService) SyncRawInventory(ctx context.Context, robloxUserID string, rawJSON []byte) error {
-	// Get key account ID (optional - can be 0 if not linked or repo unavailable)
-	var keyAccountID int64
-	if s.keyAccountRepo != nil {
-		keyAccountID, _ = s.keyAccountRepo.GetKeyAccountByRobloxUser(ctx, robloxUserID)
+	_, err := s.Sync(ctx, SyncRequest{RobloxUserID: robloxUserID, RawJSON: rawJSON})
+	return err
+}
+
+// Sync stores one section of a user's inventory.
+// Each section is buffered and persisted independently. Durable requests
+// (and services without a buffer) write straight to the database.
+func (s *InventoryService) Sync(ctx context.Context, req SyncRequest) (*SyncResult, error) {
+	ctx, span := tracing.Start(ctx, "InventoryService.Sync")
+	if span == nil {
+		return s.sync(ctx, req)
+	}
+	span.SetString("roblox.user_id", req.RobloxUserID)
+	span.SetString("inventory.section", req.Section)
+	span.SetInt("inventory.size", int64(len(req.RawJSON)))
+	result, err := s.sync(ctx, req)
+	if result != nil {
+		span.SetBool("inventory.buffered", result.Buffered)
+		span.SetBool("inventory.unchanged", result.Unchanged)
+	}
+	span.Fail(err)
+	span.End()
+	return result, err
+}
+
+func (s *InventoryService) sync(ctx context.Context, req SyncRequest) (*SyncResult, error) {
+	if !ValidRobloxUserID(req.RobloxUserID) {
+		return nil, ErrInvalidRobloxUserID
+	}
+	section, err := s.resolveSection(req.Section)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkPayload(ctx, req, section); err != nil {
+		return nil, err
+	}
+	if err := s.checkSchema(ctx, req, section); err != nil {
+		return nil, err
+	}
+	if err := s.checkThrottle(ctx, req, section); err != nil {
+		return nil, err
+	}
+
+	// Get key account ID (0 if not linked or repo unavailable, unless strict)
+	keyAccountID, err := s.resolveKeyAccount(ctx, req)
+	if err != nil {
+		return nil, err
 	}
-	
+	defer s.reads.forget(ctx, req.RobloxUserID, section)
+	defer s.forgetExistence(ctx, req.RobloxUserID)
+
 	// If buffer is available, use write-behind caching
+	if s.buffer != nil && !(req.Durable && s.inventoryRepo != nil) {
+		if err := s.checkBackpressure(); err != nil {
+			return nil, err
+		}
+		written, err := s.buffer.AddEntryIfChanged(ctx, &cache.BufferedInventory{
+			KeyAccountID:  keyAccountID,
+			RobloxUserID:  req.RobloxUserID,
+			Section:       section,
+			RawJSON:       req.RawJSON,
+			ClientVersion: req.ClientVersion,
+			Callback:      req.Callback,
+		}, s.persistedFingerprint(ctx, req.RobloxUserID, section))
+		switch {
+		case errors.Is(err, cache.ErrCircuitOpen) && s.inventoryRepo != nil:
+			// Redis is failing fast - write straight to the database below
+			s.logger.WarnContext(ctx, "Buffer circuit open, writing directly", "roblox_user_id", req.RobloxUserID, "section", section)
+		case err != nil:
+			return nil, err
+		case !written:
+			return &SyncResult{Unchanged: true}, nil
+		default:
+			// The buffer told the other instances itself
+			window, depth := s.buffer.FlushWindow(ctx)
+			return &SyncResult{Buffered: true, FlushWindow: window, QueueDepth: depth}, nil
+		}
+	}
+
+	// A buffered copy is older than this write - drop it first so a later
+	// flush or read can't resurrect it. With the circuit open it can't be
+	// dropped, but a flush skips rows older than the stored one anyway.
 	if s.buffer != nil {
-		return s.buffer.Add(ctx, keyAccountID, robloxUserID, rawJSON)
+		if err := s.buffer.RemoveSection(ctx, req.RobloxUserID, section); err != nil && !errors.Is(err, cache.ErrCircuitOpen) {
+			return nil, err
+		}
+	}
+
+	// Direct DB write, skipped when the stored copy is identical
+	stored, err := s.persistedFingerprint(ctx, req.RobloxUserID, section)()
+	unchanged := err == nil && stored == cache.Fingerprint(repository.ContentHash(req.RawJSON), keyAccountID)
+	if !unchanged {
+		if err := s.inventoryRepo.UpsertRawInventorySection(ctx, keyAccountID, req.RobloxUserID, section, req.RawJSON, s.itemCounter.Count(req.RawJSON)); err != nil {
+			return nil, err
+		}
 	}
-	
-	// Fallback to direct DB write
-	return s.inventoryRepo.UpsertRawInventory(ctx, keyAccountID, robloxUserID, rawJSON)
+	s.bus.Publish(ctx, cache.InvalidateInventory, req.RobloxUserID)
+	return &SyncResult{Unchanged: unchanged}, nil
 }
 
-// GetRawInventory retrieves raw JSON inventory data.
+// persistedFingerprint returns a lookup of the cache.Fingerprint of the
+// persisted copy of a section: empty when there is none or it predates
+// content hashes, and always empty without a database.
+func (s *InventoryService) persistedFingerprint(ctx context.Context, robloxUserID, section string) func() (string, error) {
+	return func() (string, error) {
+		if s.inventoryRepo == nil {
+			return "", nil
+		}
+		metas, err := s.inventoryRepo.ListSectionMeta(ctx, robloxUserID)
+		if err != nil {
+			return "", err
+		}
+		for _, meta := range metas {
+			if meta.Section == section && meta.ContentHash != "" {
+				return cache.Fingerprint(meta.ContentHash, meta.KeyAccountID), nil
+			}
+		}
+		return "", nil
+	}
+}
+
+// GetRawInventory retrieves the default section of a raw JSON inventory.
 // Checks Redis buffer first, then falls back to database.
 func (s *InventoryService) GetRawInventory(ctx context.Context, robloxUserID string) ([]byte, *time.Time, error) {
+	return s.GetSection(ctx, robloxUserID, domain.DefaultSection)
+}
+
+// GetSection retrieves one section of a raw JSON inventory.
+// Checks Redis buffer first, then falls back to database.
+func (s *InventoryService) GetSection(ctx context.Context, robloxUserID, section string) ([]byte, *time.Time, error) {
+	section, err := s.resolveSection(section)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Recently looked up and not found
+	key := missKey(robloxUserID, section)
+	if s.reads.tombstoned(ctx, key) {
+		return nil, nil, nil
+	}
+
+	// Recently read
+	if sec, ok := s.reads.cached(robloxUserID, section); ok {
+		return sec.RawJSON, sec.SyncedAt, nil
+	}
+	started, gen := time.Now(), s.reads.generation(key)
+
 	// Check buffer first
 	if s.buffer != nil {
-		if inv, err := s.buffer.Get(ctx, robloxUserID); err == nil && inv != nil {
-			return inv.RawJSON, &inv.UpdatedAt, nil
+		if inv, err := s.buffer.GetSection(ctx, robloxUserID, section); err == nil && inv != nil {
+			updatedAt := inv.UpdatedAt
+			s.reads.keep(robloxUserID, section, SectionData{RawJSON: inv.RawJSON, SyncedAt: &updatedAt}, started)
+			return inv.RawJSON, &updatedAt, nil
+		}
+	}
+
+	// Fall back to database, one query for concurrent identical reads
+	v, err := s.reads.do(ctx, "section|"+key, func(ctx context.Context) (interface{}, error) {
+		data, syncedAt, err := s.inventoryRepo.GetRawInventorySection(ctx, robloxUserID, section)
+		return SectionData{RawJSON: data, SyncedAt: syncedAt}, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sec, _ := v.(SectionData)
+	if sec.RawJSON == nil {
+		s.reads.remember(ctx, key, gen)
+	} else {
+		s.reads.keep(robloxUserID, section, sec, started)
+	}
+	return sec.RawJSON, sec.SyncedAt, nil
+}
+
+// GetAllSections returns every configured section that has data for a user,
+// keyed by section name. Buffered copies win over persisted ones.
+func (s *InventoryService) GetAllSections(ctx context.Context, robloxUserID string) (map[string]SectionData, error) {
+	result := make(map[string]SectionData, len(s.sections))
+
+	key := missKey(robloxUserID, "")
+	if s.reads.tombstoned(ctx, key) {
+		return result, nil
+	}
+	if all, ok := s.reads.cachedAll(robloxUserID); ok {
+		for section, sec := range all {
+			result[section] = sec
+		}
+		return result, nil
+	}
+	started, gen := time.Now(), s.reads.generation(key)
+
+	// Persisted sections first, then overlay anything newer in the buffer
+	v, err := s.reads.do(ctx, "all|"+key, func(ctx context.Context) (interface{}, error) {
+		return s.inventoryRepo.ListSections(ctx, robloxUserID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	records, _ := v.([]repository.SectionRecord)
+	for _, rec := range records {
+		if _, err := s.resolveSection(rec.Section); err != nil {
+			continue // Section no longer configured
+		}
+		syncedAt := rec.SyncedAt
+		result[rec.Section] = SectionData{RawJSON: rec.RawJSON, SyncedAt: &syncedAt}
+	}
+
+	if s.buffer != nil {
+		if buffered, err := s.buffer.GetSections(ctx, robloxUserID, s.sections); err == nil {
+			for section, inv := range buffered {
+				updatedAt := inv.UpdatedAt
+				result[section] = SectionData{RawJSON: inv.RawJSON, SyncedAt: &updatedAt}
+			}
+		}
+	}
+
+	if len(result) == 0 {
+		s.reads.remember(ctx, key, gen)
+	} else {
+		s.reads.keepAll(robloxUserID, result, started)
+	}
+	return result, nil
+}
+
+// GetAllSectionMeta returns what GetAllSections would return, described by
+// metadata only: the persisted documents are never loaded. Buffered copies
+// win over persisted ones, as they do for reads.
+func (s *InventoryService) GetAllSectionMeta(ctx context.Context, robloxUserID string) (map[string]repository.SectionMeta, error) {
+	result := make(map[string]repository.SectionMeta, len(s.sections))
+
+	if s.reads.tombstoned(ctx, missKey(robloxUserID, "")) {
+		return result, nil
+	}
+
+	metas, err := s.inventoryRepo.ListSectionMeta(ctx, robloxUserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, meta := range metas {
+		if _, err := s.resolveSection(meta.Section); err != nil {
+			continue // Section no longer configured
+		}
+		result[meta.Section] = meta
+	}
+
+	if s.buffer != nil {
+		if buffered, err := s.buffer.GetSections(ctx, robloxUserID, s.sections); err == nil {
+			for section, inv := range buffered {
+				result[section] = repository.SectionMeta{
+					Section:      section,
+					SyncedAt:     inv.UpdatedAt,
+					ContentHash:  repository.ContentHash(inv.RawJSON),
+					Size:         int64(len(inv.RawJSON)),
+					KeyAccountID: inv.KeyAccountID,
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// GetSectionMeta describes one section the way GetSection would read it,
+// without loading the persisted document. Returns nil when there is no data.
+func (s *InventoryService) GetSectionMeta(ctx context.Context, robloxUserID, section string) (*repository.SectionMeta, error) {
+	section, err := s.resolveSection(section)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := s.GetAllSectionMeta(ctx, robloxUserID)
+	if err != nil {
+		return nil, err
+	}
+	meta, ok := all[section]
+	if !ok {
+		return nil, nil
+	}
+	return &meta, nil
+}
+
+// ListInventories returns a page of persisted users. Writes still in the
+// buffer show up once they are flushed.
+func (s *InventoryService) ListInventories(ctx context.Context, q repository.InventoryListQuery) ([]repository.InventorySummary, int64, error) {
+	return s.inventoryRepo.ListInventories(ctx, q)
+}
+
+// HistoryEnabled reports whether past versions of sections are kept.
+func (s *InventoryService) HistoryEnabled() bool {
+	return s.inventoryRepo.HistoryKeep() > 0
+}
+
+// History lists the kept versions of a user's section, newest first. Empty
+// section lists every section. Versions are recorded as writes are
+// persisted, so a write still in the buffer isn't listed yet.
+func (s *InventoryService) History(ctx context.Context, robloxUserID, section string) ([]repository.HistoryEntry, error) {
+	if section != "" {
+		var err error
+		if section, err = s.resolveSection(section); err != nil {
+			return nil, err
 		}
 	}
-	
-	// Fall back to database
-	return s.inventoryRepo.GetRawInventory(ctx, robloxUserID)
+	return s.inventoryRepo.ListInventoryHistory(ctx, robloxUserID, section)
+}
+
+// HistoryVersion returns the version of a section synced at syncedAt, nil
+// when it isn't kept.
+func (s *InventoryService) HistoryVersion(ctx context.Context, robloxUserID, section string, syncedAt time.Time) ([]byte, error) {
+	section, err := s.resolveSection(section)
+	if err != nil {
+		return nil, err
+	}
+	return s.inventoryRepo.GetInventoryHistory(ctx, robloxUserID, section, syncedAt)
 }
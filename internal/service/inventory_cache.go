@@ -0,0 +1,179 @@
+package service
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"vinzhub-rest-api/internal/metrics"
+)
+
+// inventoryCacheLookups counts read cache lookups by result (hit, miss);
+// the hit rate is hits over the total.
+var inventoryCacheLookups = metrics.NewCounterVec("vinzhub_inventory_cache_lookups_total",
+	"Inventory read cache lookups by result.", "result")
+
+// InventoryCacheConfig bounds the in-process inventory read cache.
+type InventoryCacheConfig struct {
+	TTL        time.Duration // How long a read is served from memory (0 disables)
+	MaxEntries int
+	MaxBytes   int64 // Payload bytes held at most
+}
+
+// inventoryCache is an LRU of reads (payloads and synced_at), keyed by
+// user and section, or user alone for all of a user's sections. It is
+// bounded by entries and payload bytes so a scan of many users evicts
+// instead of growing the heap.
+//
+// forget leaves a marker instead of just deleting: a read that started
+// before the sync can't then cache what it fetched once the sync is done.
+type inventoryCache struct {
+	cfg InventoryCacheConfig
+
+	mu        sync.Mutex
+	entries   map[string]*list.Element
+	lru       *list.List // Front is most recently used
+	bytes     int64
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+type inventoryCacheEntry struct {
+	key       string
+	value     interface{} // SectionData or map[string]SectionData; nil for a forget marker
+	size      int64
+	stored    time.Time // When the read was started, or the key forgotten
+	expires   time.Time
+	forgotten bool
+}
+
+func newInventoryCache(cfg InventoryCacheConfig) *inventoryCache {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 5000
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 64 << 20
+	}
+	return &inventoryCache{
+		cfg:     cfg,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// inventoryCacheKey is the cache key of a user's section, or of all their
+// sections when section is empty.
+func inventoryCacheKey(robloxUserID, section string) string {
+	return robloxUserID + "|" + section
+}
+
+// get returns a cached read.
+func (c *inventoryCache) get(key string, now time.Time) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if ok {
+		entry := el.Value.(*inventoryCacheEntry)
+		if !now.Before(entry.expires) {
+			c.remove(el)
+		} else if entry.value != nil {
+			c.lru.MoveToFront(el)
+			c.hits++
+			inventoryCacheLookups.Inc("hit")
+			return entry.value, true
+		}
+	}
+	c.misses++
+	inventoryCacheLookups.Inc("miss")
+	return nil, false
+}
+
+// put caches a read of size payload bytes started at started, unless the
+// key was forgotten since or the read alone takes a quarter of the bound.
+func (c *inventoryCache) put(key string, value interface{}, size int64, started time.Time) {
+	if size > c.cfg.MaxBytes/4 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*inventoryCacheEntry)
+		if entry.forgotten && started.Before(entry.stored) && time.Now().Before(entry.expires) {
+			return // Read older than the last sync
+		}
+		c.remove(el)
+	}
+	c.entries[key] = c.lru.PushFront(&inventoryCacheEntry{
+		key:     key,
+		value:   value,
+		size:    size,
+		stored:  started,
+		expires: started.Add(c.cfg.TTL),
+	})
+	c.bytes += size
+	c.evict()
+}
+
+// forget drops a cached read after a sync changed it.
+func (c *inventoryCache) forget(key string) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	c.entries[key] = c.lru.PushFront(&inventoryCacheEntry{
+		key:       key,
+		stored:    now,
+		expires:   now.Add(c.cfg.TTL),
+		forgotten: true,
+	})
+	c.evict()
+}
+
+// clear drops every entry.
+func (c *inventoryCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	c.bytes = 0
+}
+
+// evict drops least recently used entries while over a bound. Callers
+// hold mu.
+func (c *inventoryCache) evict() {
+	for c.lru.Len() > c.cfg.MaxEntries || c.bytes > c.cfg.MaxBytes {
+		c.remove(c.lru.Back())
+		c.evictions++
+	}
+}
+
+// remove drops an element. Callers hold mu.
+func (c *inventoryCache) remove(el *list.Element) {
+	entry := el.Value.(*inventoryCacheEntry)
+	c.lru.Remove(el)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size
+}
+
+// stats returns the cache size and counters for admin stats.
+func (c *inventoryCache) stats() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return map[string]interface{}{
+		"enabled":     true,
+		"ttl_ms":      c.cfg.TTL.Milliseconds(),
+		"entries":     c.lru.Len(),
+		"max_entries": c.cfg.MaxEntries,
+		"bytes":       c.bytes,
+		"max_bytes":   c.cfg.MaxBytes,
+		"hits":        c.hits,
+		"misses":      c.misses,
+		"evictions":   c.evictions,
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"vinzhub-rest-api/pkg/jsondiff"
+)
+
+var (
+	// ErrNotBuffered is returned by DiffBuffered when nothing is waiting
+	// to be flushed for the section.
+	ErrNotBuffered = errors.New("no buffered copy")
+	// ErrNotPersisted is returned by DiffBuffered when the section was
+	// never flushed.
+	ErrNotPersisted = errors.New("no persisted copy")
+)
+
+// InventoryDiff lists the top-level keys that differ between the persisted
+// copy of a section and the buffered copy the next flush writes.
+type InventoryDiff struct {
+	Section     string    `json:"section"`
+	PersistedAt time.Time `json:"persisted_at"`
+	BufferedAt  time.Time `json:"buffered_at"`
+	Equal       bool      `json:"equal"`
+	Added       []string  `json:"added"`
+	Removed     []string  `json:"removed"`
+	Changed     []string  `json:"changed"`
+	// TypeChanged is set when the documents aren't both objects or both
+	// arrays; they are then reported as a whole and the key lists are empty
+	TypeChanged bool `json:"type_changed,omitempty"`
+	// Truncated is set when more keys differ than jsondiff.DefaultMaxChanges
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// DiffBuffered compares the buffered and persisted copies of a section,
+// reading both directly rather than through the read caches. filter, when
+// set, is applied to both documents first so that redacted fields don't
+// show up as keys.
+func (s *InventoryService) DiffBuffered(ctx context.Context, robloxUserID, section string, filter func(section string, raw []byte) ([]byte, bool)) (*InventoryDiff, error) {
+	section, err := s.resolveSection(section)
+	if err != nil {
+		return nil, err
+	}
+	if s.buffer == nil {
+		return nil, ErrNotBuffered
+	}
+
+	buffered, err := s.buffer.GetSection(ctx, robloxUserID, section)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read buffer: %w", err)
+	}
+	if buffered == nil {
+		return nil, ErrNotBuffered
+	}
+	if s.inventoryRepo == nil {
+		return nil, ErrNotPersisted
+	}
+	persisted, persistedAt, err := s.inventoryRepo.GetRawInventorySection(ctx, robloxUserID, section)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database: %w", err)
+	}
+	if persisted == nil {
+		return nil, ErrNotPersisted
+	}
+
+	newDoc := buffered.RawJSON
+	if filter != nil {
+		persisted, _ = filter(section, persisted)
+		newDoc, _ = filter(section, newDoc)
+	}
+	diff, err := DiffTopLevel(persisted, newDoc)
+	if err != nil {
+		return nil, err
+	}
+	diff.Section = section
+	diff.PersistedAt = *persistedAt
+	diff.BufferedAt = buffered.UpdatedAt
+	return diff, nil
+}
+
+// DiffTopLevel reports which top-level keys (or array indexes) of newDoc
+// were added, removed or changed compared to oldDoc. A change anywhere
+// below a key marks the key as changed.
+func DiffTopLevel(oldDoc, newDoc []byte) (*InventoryDiff, error) {
+	result, err := jsondiff.Compare(oldDoc, newDoc, jsondiff.Options{MaxDepth: 1, OmitValues: true})
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &InventoryDiff{Equal: result.Equal, Truncated: result.Truncated, Added: []string{}, Removed: []string{}, Changed: []string{}}
+	for _, change := range result.Changes {
+		if change.Path == "/" {
+			diff.TypeChanged = true
+			continue
+		}
+		key := strings.TrimPrefix(change.Path, "/")
+		key = strings.ReplaceAll(strings.ReplaceAll(key, "~1", "/"), "~0", "~")
+		switch change.Op {
+		case jsondiff.OpAdded:
+			diff.Added = append(diff.Added, key)
+		case jsondiff.OpRemoved:
+			diff.Removed = append(diff.Removed, key)
+		default:
+			diff.Changed = append(diff.Changed, key)
+		}
+	}
+	return diff, nil
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"vinzhub-rest-api/internal/cache"
+	"vinzhub-rest-api/internal/repository"
+)
+
+func TestDiffTopLevel(t *testing.T) {
+	tests := []struct {
+		name        string
+		old, new    string
+		added       []string
+		removed     []string
+		changed     []string
+		equal       bool
+		typeChanged bool
+	}{
+		{name: "equal", old: `{"a":1,"b":[1,2]}`, new: `{"b":[1,2],"a":1}`,
+			added: []string{}, removed: []string{}, changed: []string{}, equal: true},
+		{name: "keys", old: `{"a":1,"b":2}`, new: `{"a":1,"c":3}`,
+			added: []string{"c"}, removed: []string{"b"}, changed: []string{}},
+		{name: "nested object change marks the top key", old: `{"Stats":{"Fish":{"Caught":1}},"Coins":5}`, new: `{"Stats":{"Fish":{"Caught":2}},"Coins":5}`,
+			added: []string{}, removed: []string{}, changed: []string{"Stats"}},
+		{name: "array inside a key", old: `{"Items":[1,2,3]}`, new: `{"Items":[1,2]}`,
+			added: []string{}, removed: []string{}, changed: []string{"Items"}},
+		{name: "top-level arrays by index", old: `[{"Id":1},{"Id":2}]`, new: `[{"Id":1},{"Id":3},{"Id":4}]`,
+			added: []string{"2"}, removed: []string{}, changed: []string{"1"}},
+		{name: "value type change", old: `{"Level":"12","Pet":null}`, new: `{"Level":12,"Pet":{}}`,
+			added: []string{}, removed: []string{}, changed: []string{"Level", "Pet"}},
+		{name: "escaped keys", old: `{"a/b":1,"c~d":1}`, new: `{"a/b":2,"c~d":2}`,
+			added: []string{}, removed: []string{}, changed: []string{"a/b", "c~d"}},
+		{name: "document type change", old: `{"a":1}`, new: `[1]`,
+			added: []string{}, removed: []string{}, changed: []string{}, typeChanged: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := DiffTopLevel([]byte(tt.old), []byte(tt.new))
+			if err != nil {
+				t.Fatalf("DiffTopLevel: %v", err)
+			}
+			if !reflect.DeepEqual(diff.Added, tt.added) || !reflect.DeepEqual(diff.Removed, tt.removed) || !reflect.DeepEqual(diff.Changed, tt.changed) {
+				t.Errorf("added %q, removed %q, changed %q; want %q, %q, %q",
+					diff.Added, diff.Removed, diff.Changed, tt.added, tt.removed, tt.changed)
+			}
+			if diff.TypeChanged != tt.typeChanged || diff.Equal != tt.equal {
+				t.Errorf("type_changed %v, equal %v; want %v, %v", diff.TypeChanged, diff.Equal, tt.typeChanged, tt.equal)
+			}
+		})
+	}
+
+	if _, err := DiffTopLevel([]byte(`{"a":`), []byte(`{}`)); err == nil {
+		t.Error("invalid document diffed without an error")
+	}
+}
+
+func TestDiffBuffered(t *testing.T) {
+	ctx := context.Background()
+	repo, err := repository.NewSQLiteInventoryRepository(filepath.Join(t.TempDir(), "inventory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	buffer := cache.NewInventoryBuffer(time.Hour, func(context.Context, []*cache.BufferedInventory) error { return nil })
+	t.Cleanup(func() { buffer.Close() })
+	svc := NewInventoryServiceWithBuffer(repo, nil, buffer)
+
+	if err := repo.UpsertRawInventory(ctx, 1, "100", []byte(`{"Coins":5,"Items":[1],"Secret":"a"}`), 1); err != nil {
+		t.Fatal(err)
+	}
+	buffer.Add(1, "100", []byte(`{"Coins":6,"Pets":[],"Secret":"b"}`))
+	buffer.Add(1, "200", []byte(`{}`))
+
+	diff, err := svc.DiffBuffered(ctx, "100", "", nil)
+	if err != nil {
+		t.Fatalf("DiffBuffered: %v", err)
+	}
+	if diff.Section != "inventory" || diff.PersistedAt.IsZero() || diff.BufferedAt.IsZero() {
+		t.Errorf("diff = %+v, want the default section with both timestamps", diff)
+	}
+	if !reflect.DeepEqual(diff.Added, []string{"Pets"}) || !reflect.DeepEqual(diff.Removed, []string{"Items"}) ||
+		!reflect.DeepEqual(diff.Changed, []string{"Coins", "Secret"}) {
+		t.Errorf("diff = %+v", diff)
+	}
+
+	// Redacted fields don't show up as keys
+	redact := NewRedactionPolicy([]string{"/Secret"}, 0)
+	if diff, _ := svc.DiffBuffered(ctx, "100", "", redact.Redact); !reflect.DeepEqual(diff.Changed, []string{"Coins"}) {
+		t.Errorf("redacted diff changed = %q, want [Coins]", diff.Changed)
+	}
+
+	if _, err := svc.DiffBuffered(ctx, "200", "", nil); !errors.Is(err, ErrNotPersisted) {
+		t.Errorf("never flushed: err = %v, want ErrNotPersisted", err)
+	}
+	if _, err := svc.DiffBuffered(ctx, "300", "", nil); !errors.Is(err, ErrNotBuffered) {
+		t.Errorf("nothing buffered: err = %v, want ErrNotBuffered", err)
+	}
+	if _, err := NewInventoryService(repo, nil).DiffBuffered(ctx, "100", "", nil); !errors.Is(err, ErrNotBuffered) {
+		t.Errorf("no buffer: err = %v, want ErrNotBuffered", err)
+	}
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"vinzhub-rest-api/internal/lifecycle"
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/repository"
+)
+
+const (
+	// inventoryRetentionEvery is how often the inventory retention job runs.
+	inventoryRetentionEvery = 24 * time.Hour
+	// inventoryRetentionFirstRun delays the first run after startup, so
+	// frequent restarts don't postpone pruning indefinitely.
+	inventoryRetentionFirstRun = 5 * time.Minute
+	// inventoryRetentionTimeout bounds one run.
+	inventoryRetentionTimeout = 30 * time.Minute
+)
+
+// InventoryRetention deletes inventories not synced within maxAge, daily,
+// in the store's small delete batches (see DeleteOlderThan).
+type InventoryRetention struct {
+	store  repository.InventoryStore
+	maxAge time.Duration
+	logger *slog.Logger
+
+	runMu        sync.Mutex // serializes runs
+	mu           sync.Mutex // guards the fields below
+	runs         int64
+	totalDeleted int64
+	lastDeleted  int64
+	lastRunAt    time.Time
+	lastError    string
+	nextRunAt    time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewInventoryRetention creates the job for rows older than maxAge.
+func NewInventoryRetention(store repository.InventoryStore, maxAge time.Duration) *InventoryRetention {
+	return &InventoryRetention{
+		store:  store,
+		maxAge: maxAge,
+		logger: logging.Component("InventoryRetention"),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start runs the job shortly after startup, then daily until Close.
+func (j *InventoryRetention) Start() {
+	lifecycle.Go("inventory_retention", func() {
+		j.loop()
+		close(j.done) // Not deferred: a panicking loop is restarted
+	})
+	j.logger.Info("Started", "max_age", j.maxAge, "every", inventoryRetentionEvery)
+}
+
+// loop runs the job on a timer until Close.
+func (j *InventoryRetention) loop() {
+	timer := time.NewTimer(j.schedule(inventoryRetentionFirstRun))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			lifecycle.Touch("inventory_retention")
+			ctx, cancel := context.WithTimeout(context.Background(), inventoryRetentionTimeout)
+			if _, err := j.Run(ctx); err != nil {
+				j.logger.Error("Run failed", "error", err)
+			}
+			cancel()
+			timer.Reset(j.schedule(inventoryRetentionEvery))
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+// schedule records when the next run is due and returns the wait.
+func (j *InventoryRetention) schedule(wait time.Duration) time.Duration {
+	j.mu.Lock()
+	j.nextRunAt = time.Now().Add(wait)
+	j.mu.Unlock()
+	return wait
+}
+
+// Close stops the background loop and waits for a running pass.
+func (j *InventoryRetention) Close() {
+	j.stopOnce.Do(func() {
+		close(j.stop)
+		<-j.done
+	})
+}
+
+// Run deletes inventories last synced more than maxAge ago and returns how
+// many rows it deleted.
+func (j *InventoryRetention) Run(ctx context.Context) (int64, error) {
+	j.runMu.Lock()
+	defer j.runMu.Unlock()
+
+	now := time.Now().UTC()
+	result, err := j.store.DeleteOlderThan(ctx, now.Add(-j.maxAge), false)
+	var deleted int64
+	if result != nil {
+		deleted = result.Rows
+	}
+
+	j.mu.Lock()
+	j.runs++
+	j.totalDeleted += deleted
+	j.lastDeleted = deleted
+	j.lastRunAt = now
+	j.lastError = ""
+	if err != nil {
+		j.lastError = err.Error()
+	}
+	j.mu.Unlock()
+
+	j.logger.InfoContext(ctx, "Deleted old inventories", "rows", deleted, "max_age", j.maxAge)
+	return deleted, err
+}
+
+// Stats returns run counters for admin stats.
+func (j *InventoryRetention) Stats(ctx context.Context) map[string]interface{} {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	stats := map[string]interface{}{
+		"max_age":       j.maxAge.String(),
+		"runs":          j.runs,
+		"deleted_total": j.totalDeleted,
+		"last_deleted":  j.lastDeleted,
+	}
+	if !j.lastRunAt.IsZero() {
+		stats["last_run_at"] = j.lastRunAt
+	}
+	if j.lastError != "" {
+		stats["last_error"] = j.lastError
+	}
+	if !j.nextRunAt.IsZero() {
+		stats["next_run_at"] = j.nextRunAt.UTC()
+	}
+	return stats
+}
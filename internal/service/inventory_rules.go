@@ -0,0 +1,496 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vinzhub-rest-api/internal/domain"
+	"vinzhub-rest-api/internal/lifecycle"
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/metrics"
+	"vinzhub-rest-api/internal/repository"
+)
+
+// Inventory rule types.
+const (
+	RuleMaxValue  = "max_value"  // Numbers at Path stay within Min and Max
+	RuleMaxLength = "max_length" // Arrays or objects at Path have at most Max entries
+	RuleRequired  = "required"   // Path resolves to at least one value
+	RuleUnique    = "unique"     // Values at Path are distinct, e.g. item UUIDs
+)
+
+const (
+	// DefaultRulesMaxBytes is the largest document the rules will parse.
+	DefaultRulesMaxBytes = 1 << 20
+
+	// rulesQueueSize bounds the items waiting for evaluation; items arriving
+	// while it is full are dropped.
+	rulesQueueSize = 1024
+
+	// rulesNodeBudget bounds the values visited per document across all
+	// rules, so a huge inventory can't hold the evaluator up.
+	rulesNodeBudget = 100000
+
+	// rulesMaxOffending is how many offending values a flag keeps.
+	rulesMaxOffending = 10
+)
+
+// inventoryFlagsRaised counts rule violations by rule.
+var inventoryFlagsRaised = metrics.NewCounterVec("vinzhub_inventory_flags_total",
+	"Flushed inventory sections that broke an inventory rule.", "rule")
+
+// InventoryFlagStore records and lists rule violations.
+type InventoryFlagStore interface {
+	UpsertInventoryFlags(ctx context.Context, flags []repository.InventoryFlag) error
+	ListInventoryFlags(ctx context.Context, rule, robloxUserID string, page repository.PageQuery) ([]repository.InventoryFlag, error)
+	CountInventoryFlags(ctx context.Context) (map[string]int64, error)
+}
+
+// InventoryRule is one rule of a rules file. Path is a JSON Pointer where
+// "*" matches every array element or object member, as in redaction rules.
+type InventoryRule struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Section string   `json:"section,omitempty"` // Empty applies to every section
+	Path    string   `json:"path"`
+	Min     *float64 `json:"min,omitempty"`
+	Max     *float64 `json:"max,omitempty"`
+
+	tokens []string
+}
+
+// inventoryRuleFile is the rules file: rule lists keyed by game, so one
+// file can serve every game's deployment.
+//
+//	{"games": {"fishit": [
+//	  {"name": "coins_cap", "type": "max_value", "path": "/Coins", "max": 1e9},
+//	  {"name": "unique_uuids", "type": "unique", "path": "/Items/*/UUID"}
+//	]}}
+type inventoryRuleFile struct {
+	Games map[string][]InventoryRule `json:"games"`
+}
+
+// InventoryRulesConfig configures rule evaluation.
+type InventoryRulesConfig struct {
+	Path     string // Rules file
+	Game     string // Rule set of the file to use
+	MaxBytes int    // Larger documents are skipped
+}
+
+// LoadInventoryRules reads and checks the rules of one game.
+func LoadInventoryRules(path, game string) ([]InventoryRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var file inventoryRuleFile
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse rules file: %w", err)
+	}
+	rules, ok := file.Games[game]
+	if !ok {
+		return nil, fmt.Errorf("rules file has no rules for game %q", game)
+	}
+
+	names := make(map[string]bool, len(rules))
+	for i := range rules {
+		rule := &rules[i]
+		if err := rule.parse(); err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i, rule.Name, err)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("rule %d: name %q used twice", i, rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return rules, nil
+}
+
+// parse checks a rule and splits its path.
+func (r *InventoryRule) parse() error {
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if !strings.HasPrefix(r.Path, "/") {
+		return errors.New("path must be a JSON Pointer starting with /")
+	}
+	switch r.Type {
+	case RuleMaxValue:
+		if r.Min == nil && r.Max == nil {
+			return errors.New("max_value needs min, max or both")
+		}
+	case RuleMaxLength:
+		if r.Max == nil || *r.Max < 0 {
+			return errors.New("max_length needs a non-negative max")
+		}
+	case RuleRequired, RuleUnique:
+	default:
+		return fmt.Errorf("unknown type %q", r.Type)
+	}
+
+	r.tokens = strings.Split(r.Path[1:], "/")
+	for i, t := range r.tokens {
+		r.tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return nil
+}
+
+// appliesTo reports whether the rule checks a section.
+func (r *InventoryRule) appliesTo(section string) bool {
+	return r.Section == "" || r.Section == section
+}
+
+// ruleMatch is a value found at a rule's path.
+type ruleMatch struct {
+	pointer string // Concrete path, wildcards resolved
+	value   interface{}
+}
+
+// match walks the rule's path through doc, calling fn with every value it
+// resolves to. It stops once budget runs out and reports whether it did.
+func (r *InventoryRule) match(doc interface{}, budget *int, fn func(ruleMatch)) bool {
+	return walkRulePath(doc, r.tokens, "", budget, fn)
+}
+
+func walkRulePath(node interface{}, tokens []string, pointer string, budget *int, fn func(ruleMatch)) bool {
+	if *budget <= 0 {
+		return false
+	}
+	*budget--
+	if len(tokens) == 0 {
+		fn(ruleMatch{pointer: pointer, value: node})
+		return true
+	}
+
+	token, rest := tokens[0], tokens[1:]
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if token != "*" {
+			child, ok := v[token]
+			return !ok || walkRulePath(child, rest, pointer+"/"+escapePointer(token), budget, fn)
+		}
+		// Sorted so flags list the same offending values on every flush
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if !walkRulePath(v[k], rest, pointer+"/"+escapePointer(k), budget, fn) {
+				return false
+			}
+		}
+	case []interface{}:
+		if token != "*" {
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return true
+			}
+			return walkRulePath(v[i], rest, pointer+"/"+token, budget, fn)
+		}
+		for i, child := range v {
+			if !walkRulePath(child, rest, pointer+"/"+strconv.Itoa(i), budget, fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// escapePointer escapes one JSON Pointer token.
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// Evaluate checks one document against the rule. It returns the offending
+// values (at most rulesMaxOffending) and whether the rule was broken.
+// A walk cut short by budget only reports what it saw.
+func (r *InventoryRule) Evaluate(doc interface{}, budget *int) ([]map[string]interface{}, bool) {
+	var offending []map[string]interface{}
+	broken := false
+	add := func(entry map[string]interface{}) {
+		broken = true
+		if len(offending) < rulesMaxOffending {
+			offending = append(offending, entry)
+		}
+	}
+
+	switch r.Type {
+	case RuleMaxValue:
+		r.match(doc, budget, func(m ruleMatch) {
+			n, ok := m.value.(float64)
+			if ok && ((r.Max != nil && n > *r.Max) || (r.Min != nil && n < *r.Min)) {
+				add(map[string]interface{}{"path": m.pointer, "value": n})
+			}
+		})
+	case RuleMaxLength:
+		r.match(doc, budget, func(m ruleMatch) {
+			length := -1
+			switch v := m.value.(type) {
+			case []interface{}:
+				length = len(v)
+			case map[string]interface{}:
+				length = len(v)
+			}
+			if length >= 0 && float64(length) > *r.Max {
+				add(map[string]interface{}{"path": m.pointer, "length": length})
+			}
+		})
+	case RuleRequired:
+		found := false
+		complete := r.match(doc, budget, func(ruleMatch) { found = true })
+		if !found && complete {
+			add(map[string]interface{}{"path": r.Path, "missing": true})
+		}
+	case RuleUnique:
+		seen := make(map[string][]string)
+		var order []string
+		r.match(doc, budget, func(m ruleMatch) {
+			switch m.value.(type) {
+			case map[string]interface{}, []interface{}, nil:
+				return // Only scalars identify anything
+			}
+			key, _ := json.Marshal(m.value)
+			if _, ok := seen[string(key)]; !ok {
+				order = append(order, string(key))
+			}
+			seen[string(key)] = append(seen[string(key)], m.pointer)
+		})
+		for _, key := range order {
+			if paths := seen[key]; len(paths) > 1 {
+				add(map[string]interface{}{"value": json.RawMessage(key), "count": len(paths), "paths": paths[:min(len(paths), 3)]})
+			}
+		}
+	}
+	return offending, broken
+}
+
+// InventoryRules flags flushed inventories that break a game's rules, for
+// moderators to review. It runs as a flush side effect that only queues
+// items: evaluation happens on its own goroutine, never blocks ingestion
+// and never fails a flush. Violations don't stop anything from being
+// stored. Reload picks up an edited rules file.
+type InventoryRules struct {
+	store  InventoryFlagStore
+	cfg    InventoryRulesConfig
+	logger *slog.Logger
+
+	mu    sync.RWMutex
+	rules []InventoryRule
+
+	queue    chan repository.InventoryItem
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	evaluated      atomic.Int64
+	flagged        atomic.Int64
+	skipped        atomic.Int64 // Too large
+	invalid        atomic.Int64 // Not JSON
+	truncated      atomic.Int64 // Node budget ran out
+	dropped        atomic.Int64
+	storeFailures  atomic.Int64
+	reloads        atomic.Int64
+	reloadFailures atomic.Int64
+	loadedAt       atomic.Int64 // unix seconds
+}
+
+// NewInventoryRules loads the rules file. Start it to begin evaluation.
+func NewInventoryRules(store InventoryFlagStore, cfg InventoryRulesConfig) (*InventoryRules, error) {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultRulesMaxBytes
+	}
+	rules, err := LoadInventoryRules(cfg.Path, cfg.Game)
+	if err != nil {
+		return nil, err
+	}
+	e := &InventoryRules{
+		store:  store,
+		cfg:    cfg,
+		logger: logging.Component("InventoryRules"),
+		rules:  rules,
+		queue:  make(chan repository.InventoryItem, rulesQueueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	e.loadedAt.Store(time.Now().Unix())
+	return e, nil
+}
+
+// Reload reads the rules file again. A file that fails to load leaves the
+// current rules in place.
+func (e *InventoryRules) Reload() error {
+	rules, err := LoadInventoryRules(e.cfg.Path, e.cfg.Game)
+	if err != nil {
+		e.reloadFailures.Add(1)
+		e.logger.Warn("Reload failed, keeping current rules", "rules", e.ruleCount(), "error", err)
+		return err
+	}
+	e.mu.Lock()
+	e.rules = rules
+	e.mu.Unlock()
+	e.reloads.Add(1)
+	e.loadedAt.Store(time.Now().Unix())
+	e.logger.Info("Reloaded", "rules", len(rules), "game", e.cfg.Game)
+	return nil
+}
+
+func (e *InventoryRules) ruleCount() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return len(e.rules)
+}
+
+// Observe is a flush side effect: it queues items for evaluation. It never
+// blocks the flush and never fails.
+func (e *InventoryRules) Observe(ctx context.Context, items []repository.InventoryItem) error {
+	for _, item := range items {
+		select {
+		case e.queue <- item:
+		default:
+			e.dropped.Add(1)
+		}
+	}
+	return nil
+}
+
+// Start evaluates queued items until Close.
+func (e *InventoryRules) Start() {
+	lifecycle.Go("inventory.rules", func() {
+		e.loop()
+		close(e.done) // Not deferred: a panicking loop is restarted
+	})
+	e.logger.Info("Started", "rules", e.ruleCount(), "game", e.cfg.Game)
+}
+
+// loop evaluates queued items, storing each item's flags as it goes.
+func (e *InventoryRules) loop() {
+	for {
+		select {
+		case item := <-e.queue:
+			lifecycle.Touch("inventory.rules")
+			flags := e.evaluate(item)
+			if len(flags) == 0 {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := e.store.UpsertInventoryFlags(ctx, flags); err != nil {
+				e.storeFailures.Add(1)
+				e.logger.ErrorContext(ctx, "Failed to store flags", "flags", len(flags), "roblox_user_id", item.RobloxUserID, "error", err)
+			}
+			cancel()
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// Close stops evaluation. Items still queued are not evaluated.
+func (e *InventoryRules) Close() {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+		<-e.done
+	})
+}
+
+// evaluate checks one flushed item against the rules of its section.
+func (e *InventoryRules) evaluate(item repository.InventoryItem) []repository.InventoryFlag {
+	section := item.Section
+	if section == "" {
+		section = domain.DefaultSection
+	}
+	e.mu.RLock()
+	var rules []InventoryRule
+	for _, rule := range e.rules {
+		if rule.appliesTo(section) {
+			rules = append(rules, rule)
+		}
+	}
+	e.mu.RUnlock()
+	if len(rules) == 0 {
+		return nil
+	}
+
+	if len(item.RawJSON) > e.cfg.MaxBytes {
+		e.skipped.Add(1)
+		return nil
+	}
+	var doc interface{}
+	if err := json.Unmarshal(item.RawJSON, &doc); err != nil {
+		e.invalid.Add(1)
+		return nil
+	}
+	e.evaluated.Add(1)
+
+	budget := rulesNodeBudget
+	var flags []repository.InventoryFlag
+	for i := range rules {
+		offending, broken := rules[i].Evaluate(doc, &budget)
+		if !broken {
+			continue
+		}
+		encoded, _ := json.Marshal(offending)
+		flags = append(flags, repository.InventoryFlag{
+			RobloxUserID: item.RobloxUserID,
+			Section:      section,
+			Rule:         rules[i].Name,
+			Game:         e.cfg.Game,
+			Offending:    encoded,
+			SyncedAt:     item.SyncedAt,
+		})
+		inventoryFlagsRaised.Inc(rules[i].Name)
+	}
+	if budget <= 0 {
+		e.truncated.Add(1)
+	}
+	e.flagged.Add(int64(len(flags)))
+	return flags
+}
+
+// Flags returns a page of stored flags, newest first.
+func (e *InventoryRules) Flags(ctx context.Context, rule, robloxUserID string, page repository.PageQuery) ([]repository.InventoryFlag, error) {
+	return e.store.ListInventoryFlags(ctx, rule, robloxUserID, page)
+}
+
+// Stats returns evaluation counters and stored flags per rule.
+func (e *InventoryRules) Stats(ctx context.Context) map[string]interface{} {
+	e.mu.RLock()
+	names := make([]string, len(e.rules))
+	for i, rule := range e.rules {
+		names[i] = rule.Name
+	}
+	e.mu.RUnlock()
+
+	stats := map[string]interface{}{
+		"game":            e.cfg.Game,
+		"rules":           names,
+		"evaluated":       e.evaluated.Load(),
+		"flagged":         e.flagged.Load(),
+		"skipped_large":   e.skipped.Load(),
+		"invalid":         e.invalid.Load(),
+		"truncated":       e.truncated.Load(),
+		"dropped":         e.dropped.Load(),
+		"queued":          len(e.queue),
+		"store_failures":  e.storeFailures.Load(),
+		"reloads":         e.reloads.Load(),
+		"reload_failures": e.reloadFailures.Load(),
+		"loaded_at":       time.Unix(e.loadedAt.Load(), 0).UTC(),
+	}
+	if counts, err := e.store.CountInventoryFlags(ctx); err == nil {
+		stats["stored"] = counts
+	}
+	return stats
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// parseRule decodes and checks one rule.
+func parseRule(t *testing.T, rule string) InventoryRule {
+	t.Helper()
+	var r InventoryRule
+	if err := json.Unmarshal([]byte(rule), &r); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.parse(); err != nil {
+		t.Fatalf("parse %s: %v", rule, err)
+	}
+	return r
+}
+
+func TestInventoryRuleEvaluate(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   string
+		doc    string
+		budget int // 0 = rulesNodeBudget
+		want   string
+	}{
+		{"max_value above max", `{"name":"r","type":"max_value","path":"/Coins","max":3}`,
+			`{"Coins":5}`, 0, `[{"path":"/Coins","value":5}]`},
+		{"max_value below min", `{"name":"r","type":"max_value","path":"/Coins","min":0}`,
+			`{"Coins":-1}`, 0, `[{"path":"/Coins","value":-1}]`},
+		{"max_value within bounds", `{"name":"r","type":"max_value","path":"/Coins","min":0,"max":3}`,
+			`{"Coins":3}`, 0, ``},
+		{"max_value ignores non-numbers", `{"name":"r","type":"max_value","path":"/Coins","max":3}`,
+			`{"Coins":"9"}`, 0, ``},
+		{"wildcard over array", `{"name":"r","type":"max_value","path":"/Items/*/Count","max":10}`,
+			`{"Items":[{"Count":5},{"Count":11},{}]}`, 0, `[{"path":"/Items/1/Count","value":11}]`},
+		{"wildcard over object in key order", `{"name":"r","type":"max_value","path":"/Pets/*/Level","max":10}`,
+			`{"Pets":{"dog":{"Level":20},"cat":{"Level":30}}}`, 0,
+			`[{"path":"/Pets/cat/Level","value":30},{"path":"/Pets/dog/Level","value":20}]`},
+		{"escaped tokens", `{"name":"r","type":"max_value","path":"/a~1b/c~0d","max":5}`,
+			`{"a/b":{"c~d":7}}`, 0, `[{"path":"/a~1b/c~0d","value":7}]`},
+		{"escaped keys under a wildcard", `{"name":"r","type":"max_value","path":"/*","max":5}`,
+			`{"x/y~z":6}`, 0, `[{"path":"/x~1y~0z","value":6}]`},
+		{"max_length of array", `{"name":"r","type":"max_length","path":"/Items","max":2}`,
+			`{"Items":[1,2,3]}`, 0, `[{"length":3,"path":"/Items"}]`},
+		{"max_length of object", `{"name":"r","type":"max_length","path":"/Pets","max":1}`,
+			`{"Pets":{"a":1,"b":2}}`, 0, `[{"length":2,"path":"/Pets"}]`},
+		{"max_length at the limit", `{"name":"r","type":"max_length","path":"/Items","max":3}`,
+			`{"Items":[1,2,3]}`, 0, ``},
+		{"required present", `{"name":"r","type":"required","path":"/Stats/Level"}`,
+			`{"Stats":{"Level":0}}`, 0, ``},
+		{"required missing", `{"name":"r","type":"required","path":"/Stats/Level"}`,
+			`{"Stats":{}}`, 0, `[{"missing":true,"path":"/Stats/Level"}]`},
+		{"required under a wildcard missing", `{"name":"r","type":"required","path":"/Items/*/UUID"}`,
+			`{"Items":[{},{}]}`, 0, `[{"missing":true,"path":"/Items/*/UUID"}]`},
+		{"required walk cut short by the budget", `{"name":"r","type":"required","path":"/Items/*/UUID"}`,
+			`{"Items":[{},{},{},{}]}`, 3, ``},
+		{"unique duplicates", `{"name":"r","type":"unique","path":"/Items/*/UUID"}`,
+			`{"Items":[{"UUID":"a"},{"UUID":"b"},{"UUID":"a"}]}`, 0,
+			`[{"count":2,"paths":["/Items/0/UUID","/Items/2/UUID"],"value":"a"}]`},
+		{"unique tells numbers from strings", `{"name":"r","type":"unique","path":"/Items/*"}`,
+			`{"Items":[1,"1"]}`, 0, ``},
+		{"unique ignores objects and nulls", `{"name":"r","type":"unique","path":"/Items/*"}`,
+			`{"Items":[{},{},null,null]}`, 0, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := parseRule(t, tt.rule)
+			var doc interface{}
+			if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+				t.Fatal(err)
+			}
+			budget := tt.budget
+			if budget == 0 {
+				budget = rulesNodeBudget
+			}
+
+			offending, broken := rule.Evaluate(doc, &budget)
+			if broken != (tt.want != "") {
+				t.Fatalf("broken = %v, want %v (offending %v)", broken, tt.want != "", offending)
+			}
+			if tt.want == "" {
+				return
+			}
+			got, _ := json.Marshal(offending)
+			if string(got) != tt.want {
+				t.Errorf("offending = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInventoryRuleOffendingCapped(t *testing.T) {
+	rule := parseRule(t, `{"name":"r","type":"max_value","path":"/*","max":0}`)
+	doc := make(map[string]interface{})
+	for i := 0; i < 2*rulesMaxOffending; i++ {
+		doc[string(rune('a'+i))] = float64(1)
+	}
+	budget := rulesNodeBudget
+	offending, broken := rule.Evaluate(doc, &budget)
+	if !broken || len(offending) != rulesMaxOffending {
+		t.Errorf("broken = %v with %d offending values, want %d", broken, len(offending), rulesMaxOffending)
+	}
+}
+
+// writeRules writes a rules file and returns its path.
+func writeRules(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "rules.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadInventoryRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{"valid", `{"games":{"fishit":[
+			{"name":"coins","type":"max_value","path":"/Coins","max":1e9},
+			{"name":"uuids","type":"unique","section":"inventory","path":"/Items/*/UUID"}]}}`, ""},
+		{"unknown type", `{"games":{"fishit":[{"name":"a","type":"max_count","path":"/X"}]}}`, `unknown type "max_count"`},
+		{"duplicate name", `{"games":{"fishit":[
+			{"name":"a","type":"required","path":"/X"},
+			{"name":"a","type":"required","path":"/Y"}]}}`, `name "a" used twice`},
+		{"missing game", `{"games":{"other":[]}}`, `no rules for game "fishit"`},
+		{"unknown rule field", `{"games":{"fishit":[{"name":"a","type":"required","path":"/X","limit":1}]}}`, `unknown field "limit"`},
+		{"unknown top-level field", `{"games":{"fishit":[]},"version":2}`, `unknown field "version"`},
+		{"missing name", `{"games":{"fishit":[{"type":"required","path":"/X"}]}}`, "name is required"},
+		{"relative path", `{"games":{"fishit":[{"name":"a","type":"required","path":"X"}]}}`, "JSON Pointer"},
+		{"max_value without bounds", `{"games":{"fishit":[{"name":"a","type":"max_value","path":"/X"}]}}`, "needs min, max or both"},
+		{"negative max_length", `{"games":{"fishit":[{"name":"a","type":"max_length","path":"/X","max":-1}]}}`, "non-negative max"},
+		{"not JSON", `{"games":`, "failed to parse rules file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := LoadInventoryRules(writeRules(t, t.TempDir(), tt.file), "fishit")
+			if tt.wantErr == "" {
+				if err != nil || len(rules) != 2 {
+					t.Fatalf("LoadInventoryRules = %d rules, %v; want 2", len(rules), err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := LoadInventoryRules(filepath.Join(t.TempDir(), "missing.json"), "fishit"); err == nil {
+		t.Error("a missing rules file loaded")
+	}
+}
+
+func TestInventoryRulesReloadKeepsRulesOnError(t *testing.T) {
+	dir := t.TempDir()
+	path := writeRules(t, dir, `{"games":{"fishit":[
+		{"name":"coins","type":"max_value","path":"/Coins","max":10},
+		{"name":"level","type":"required","path":"/Level"}]}}`)
+	e, err := NewInventoryRules(nil, InventoryRulesConfig{Path: path, Game: "fishit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, broken := range []string{`{"games":`, `{"games":{"fishit":[{"name":"x","type":"bogus","path":"/X"}]}}`} {
+		writeRules(t, dir, broken)
+		if err := e.Reload(); err == nil {
+			t.Fatalf("Reload of %s succeeded", broken)
+		}
+		if n := e.ruleCount(); n != 2 {
+			t.Fatalf("%d rules after a failed reload, want the 2 loaded", n)
+		}
+	}
+	if got := e.reloadFailures.Load(); got != 2 {
+		t.Errorf("reload failures = %d, want 2", got)
+	}
+
+	writeRules(t, dir, `{"games":{"fishit":[{"name":"coins","type":"max_value","path":"/Coins","max":10}]}}`)
+	if err := e.Reload(); err != nil || e.ruleCount() != 1 {
+		t.Errorf("Reload = %v with %d rules, want the edited file's 1 rule", err, e.ruleCount())
+	}
+}
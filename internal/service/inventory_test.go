@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"vinzhub-rest-api/internal/cache"
+)
+
+func TestValidRobloxUserID(t *testing.T) {
+	tests := map[string]bool{
+		"1":                     true,
+		"100000001":             true,
+		"18446744073709551615":  true,
+		"":                      false,
+		"123:settings":          false,
+		"abc":                   false,
+		"-1":                    false,
+		"+1":                    false,
+		" 1":                    false,
+		"123456789012345678901": false,
+	}
+	for id, want := range tests {
+		if got := ValidRobloxUserID(id); got != want {
+			t.Errorf("ValidRobloxUserID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestSyncRejectsIDThatAliasesAnotherUsersSection(t *testing.T) {
+	buffer := cache.NewInventoryBuffer(time.Hour, func(context.Context, []*cache.BufferedInventory) error { return nil })
+	t.Cleanup(func() { buffer.Close() })
+	svc := NewInventoryServiceWithBuffer(nil, nil, buffer)
+	svc.SetSections([]string{"inventory", "settings"})
+
+	_, err := svc.Sync(context.Background(), SyncRequest{RobloxUserID: "123:settings", RawJSON: []byte(`{"evil":true}`)})
+	if !errors.Is(err, ErrInvalidRobloxUserID) {
+		t.Fatalf("Sync err = %v, want ErrInvalidRobloxUserID", err)
+	}
+	if got, _ := buffer.GetSection(context.Background(), "123", "settings"); got != nil {
+		t.Errorf("user 123's settings were written through an aliased ID: %s", got.RawJSON)
+	}
+}
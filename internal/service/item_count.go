@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"vinzhub-rest-api/internal/domain"
+	"vinzhub-rest-api/internal/repository"
+)
+
+// ItemCounter counts the items of a synced document so reads and key
+// accounts can report them without parsing the stored blob again.
+type ItemCounter struct {
+	path []string
+}
+
+// NewItemCounter counts the members of the value at a dot-separated path,
+// e.g. "Items" or "Data.Items": the elements of an array or the keys of an
+// object. An empty path counts the top level of the document.
+func NewItemCounter(path string) *ItemCounter {
+	c := &ItemCounter{}
+	if path = strings.TrimSpace(path); path != "" {
+		c.path = strings.Split(path, ".")
+	}
+	return c
+}
+
+// Count returns the number of items in raw; 0 when the path is missing or
+// leads to a scalar, and for invalid JSON. A nil counter counts the top
+// level.
+func (c *ItemCounter) Count(raw []byte) int {
+	value := json.RawMessage(raw)
+	if c != nil {
+		for _, key := range c.path {
+			var obj map[string]json.RawMessage
+			if err := json.Unmarshal(value, &obj); err != nil {
+				return 0
+			}
+			next, ok := obj[key]
+			if !ok {
+				return 0
+			}
+			value = next
+		}
+	}
+
+	var arr []json.RawMessage
+	if err := json.Unmarshal(value, &arr); err == nil {
+		return len(arr)
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(value, &obj); err == nil {
+		return len(obj)
+	}
+	return 0
+}
+
+// SetItemCounter sets how synced documents are counted. Without one, the
+// top-level members of a document are counted.
+func (s *InventoryService) SetItemCounter(counter *ItemCounter) {
+	s.itemCounter = counter
+}
+
+// RecordKeyAccountSyncs is a flush side effect that stores, on each linked
+// key account, when its default section was last persisted and how many
+// items it held. Rows without a key account are skipped, and an account
+// flushed more than once in a batch is written once with its newest row.
+func RecordKeyAccountSyncs(recorder repository.KeyAccountSyncRecorder) FlushStageFunc {
+	return func(ctx context.Context, items []repository.InventoryItem) error {
+		latest := make(map[int64]int)
+		syncs := make([]repository.KeyAccountSync, 0, len(items))
+		for _, item := range items {
+			if item.KeyAccountID == 0 || item.ItemCount < 0 || (item.Section != "" && item.Section != domain.DefaultSection) {
+				continue
+			}
+			sync := repository.KeyAccountSync{KeyAccountID: item.KeyAccountID, SyncedAt: item.SyncedAt, ItemCount: item.ItemCount}
+			if i, ok := latest[item.KeyAccountID]; ok {
+				if item.SyncedAt.After(syncs[i].SyncedAt) {
+					syncs[i] = sync
+				}
+				continue
+			}
+			latest[item.KeyAccountID] = len(syncs)
+			syncs = append(syncs, sync)
+		}
+		return recorder.BatchUpdateLastSync(ctx, syncs)
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vinzhub-rest-api/internal/repository"
+)
+
+// syncRecorder keeps the last-sync updates it receives.
+type syncRecorder struct {
+	syncs []repository.KeyAccountSync
+}
+
+func (r *syncRecorder) BatchUpdateLastSync(ctx context.Context, syncs []repository.KeyAccountSync) error {
+	r.syncs = append(r.syncs, syncs...)
+	return nil
+}
+
+func TestRecordKeyAccountSyncs(t *testing.T) {
+	older := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Minute)
+	recorder := &syncRecorder{}
+
+	err := RecordKeyAccountSyncs(recorder)(context.Background(), []repository.InventoryItem{
+		{KeyAccountID: 1, SyncedAt: newer, ItemCount: 12},
+		{KeyAccountID: 1, SyncedAt: older, ItemCount: 10},                     // Superseded
+		{KeyAccountID: 1, Section: "settings", SyncedAt: newer, ItemCount: 3}, // Not the inventory
+		{KeyAccountID: 2, Section: "inventory", SyncedAt: older, ItemCount: 5},
+		{KeyAccountID: 0, SyncedAt: newer, ItemCount: 1},  // Unlinked
+		{KeyAccountID: 3, SyncedAt: newer, ItemCount: -1}, // Never counted
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[int64]repository.KeyAccountSync{
+		1: {KeyAccountID: 1, SyncedAt: newer, ItemCount: 12},
+		2: {KeyAccountID: 2, SyncedAt: older, ItemCount: 5},
+	}
+	if len(recorder.syncs) != len(want) {
+		t.Fatalf("syncs = %+v, want %+v", recorder.syncs, want)
+	}
+	for _, got := range recorder.syncs {
+		if w := want[got.KeyAccountID]; !got.SyncedAt.Equal(w.SyncedAt) || got.ItemCount != w.ItemCount {
+			t.Errorf("sync for %d = %+v, want %+v", got.KeyAccountID, got, w)
+		}
+	}
+}
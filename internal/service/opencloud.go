@@ -0,0 +1,181 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"vinzhub-rest-api/internal/metrics"
+	"vinzhub-rest-api/internal/repository"
+)
+
+const (
+	// openCloudBaseURL is the Open Cloud MessagingService publish endpoint.
+	openCloudBaseURL = "https://apis.roblox.com/messaging-service/v1/universes/%s/topics/%s"
+
+	// openCloudMaxMessage is MessagingService's message size limit.
+	openCloudMaxMessage = 1024
+)
+
+// ErrPublishRateLimited is returned when the local publish budget is spent
+// (or Roblox answered 429). The batch is retried on a later flush.
+var ErrPublishRateLimited = errors.New("open cloud publish rate limited")
+
+var openCloudPublishes = metrics.NewCounterVec("vinzhub_opencloud_publish_total",
+	"Open Cloud MessagingService publishes by result.", "result")
+
+// OpenCloudConfig configures persisted notifications to the game.
+type OpenCloudConfig struct {
+	APIKey     string
+	UniverseID string
+	Topic      string
+	// PerMinute caps publishes per minute, below the universe quota
+	// (150 + 60 x players per minute) shared with the game servers.
+	PerMinute int
+}
+
+// PersistedNotifier publishes "inventory persisted" messages to the game
+// through Open Cloud MessagingService, for users that asked for a callback
+// on sync. Users are packed into as few messages as the size limit allows.
+// It runs as a flush side effect, so failed publishes are retried with the
+// pipeline's retry queue, kept in the outbox across restarts (delivery is
+// at least once).
+type PersistedNotifier struct {
+	cfg    OpenCloudConfig
+	url    string
+	client *http.Client
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// persistedMessage is the payload subscribers receive.
+type persistedMessage struct {
+	Type  string              `json:"type"`
+	At    int64               `json:"at"`
+	Users map[string][]string `json:"users"` // roblox_user_id -> persisted sections
+}
+
+// NewPersistedNotifier creates a notifier.
+func NewPersistedNotifier(cfg OpenCloudConfig) *PersistedNotifier {
+	if cfg.PerMinute <= 0 {
+		cfg.PerMinute = 60
+	}
+	return &PersistedNotifier{
+		cfg:    cfg,
+		url:    fmt.Sprintf(openCloudBaseURL, cfg.UniverseID, cfg.Topic),
+		client: &http.Client{Timeout: 10 * time.Second},
+		tokens: float64(cfg.PerMinute),
+		last:   time.Now(),
+	}
+}
+
+// Notify implements FlushStageFunc.
+func (n *PersistedNotifier) Notify(ctx context.Context, items []repository.InventoryItem) error {
+	users := make(map[string][]string)
+	for _, item := range items {
+		if item.Callback {
+			users[item.RobloxUserID] = append(users[item.RobloxUserID], item.Section)
+		}
+	}
+	if len(users) == 0 {
+		return nil
+	}
+
+	for _, msg := range packPersisted(users, time.Now().Unix()) {
+		if !n.take() {
+			openCloudPublishes.Inc("rate_limited")
+			return ErrPublishRateLimited
+		}
+		if err := n.publish(ctx, msg); err != nil {
+			if errors.Is(err, ErrPublishRateLimited) {
+				openCloudPublishes.Inc("rate_limited")
+			} else {
+				openCloudPublishes.Inc("failed")
+			}
+			return err
+		}
+		openCloudPublishes.Inc("ok")
+	}
+	return nil
+}
+
+// packPersisted splits users over messages that fit the size limit.
+func packPersisted(users map[string][]string, at int64) [][]byte {
+	ids := make([]string, 0, len(users))
+	for id := range users {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var out [][]byte
+	current := persistedMessage{Type: "inventory_persisted", At: at, Users: map[string][]string{}}
+	var encoded []byte
+	for _, id := range ids {
+		current.Users[id] = users[id]
+		data, _ := json.Marshal(current)
+		if len(data) > openCloudMaxMessage && len(current.Users) > 1 {
+			// Doesn't fit - ship what we had and start a new message
+			delete(current.Users, id)
+			out = append(out, encoded)
+			current.Users = map[string][]string{id: users[id]}
+			data, _ = json.Marshal(current)
+		}
+		encoded = data
+	}
+	return append(out, encoded)
+}
+
+// take spends one publish from the per-minute budget.
+func (n *PersistedNotifier) take() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := time.Now()
+	perMinute := float64(n.cfg.PerMinute)
+	n.tokens += now.Sub(n.last).Minutes() * perMinute
+	if n.tokens > perMinute {
+		n.tokens = perMinute
+	}
+	n.last = now
+
+	if n.tokens < 1 {
+		return false
+	}
+	n.tokens--
+	return true
+}
+
+// publish sends one message to the topic.
+func (n *PersistedNotifier) publish(ctx context.Context, message []byte) error {
+	body, _ := json.Marshal(map[string]string{"message": string(message)})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", n.cfg.APIKey)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to publish to open cloud: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return ErrPublishRateLimited
+	case resp.StatusCode >= 300:
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("open cloud publish failed: %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrEmptyPayload is returned for a null document, and for an empty object
+// or array that would overwrite stored data without an explicit override.
+var ErrEmptyPayload = errors.New("empty payload")
+
+// PayloadPolicy sets the minimum content of a synced document. Empty
+// uploads are the usual way a broken client wipes a player's inventory.
+type PayloadPolicy struct {
+	// RejectNull rejects a literal null
+	RejectNull bool
+	// RejectEmpty rejects {} and [] when the section already holds data,
+	// unless the request sets AllowEmpty. A first sync may be empty.
+	RejectEmpty bool
+}
+
+// SetPayloadPolicy configures the minimum-content rules.
+func (s *InventoryService) SetPayloadPolicy(policy PayloadPolicy) {
+	s.payloadPolicy = policy
+}
+
+// checkPayload applies the payload policy to a sync.
+func (s *InventoryService) checkPayload(ctx context.Context, req SyncRequest, section string) error {
+	switch payloadShape(req.RawJSON) {
+	case "null":
+		if s.payloadPolicy.RejectNull {
+			return fmt.Errorf("%w: null is not a valid document", ErrEmptyPayload)
+		}
+	case "empty":
+		if !s.payloadPolicy.RejectEmpty || req.AllowEmpty {
+			return nil
+		}
+		stored, _, err := s.GetSection(ctx, req.RobloxUserID, section)
+		if err != nil {
+			return err
+		}
+		if stored != nil && payloadShape(stored) == "" {
+			return fmt.Errorf("%w: an empty document would overwrite stored data (pass allow_empty=true to clear it)", ErrEmptyPayload)
+		}
+	}
+	return nil
+}
+
+// payloadShape returns "null" for null, "empty" for {} or [] and "" for
+// anything else.
+func payloadShape(raw []byte) string {
+	trimmed := bytes.TrimSpace(raw)
+	switch {
+	case bytes.Equal(trimmed, []byte("null")):
+		return "null"
+	case len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && ElementCount(trimmed) == 0:
+		return "empty"
+	}
+	return ""
+}
+
+// ElementCount returns the number of top-level members of an object or
+// elements of an array; 0 for scalars and invalid JSON.
+func ElementCount(raw []byte) int {
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return 0
+	}
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		return len(v)
+	case []interface{}:
+		return len(v)
+	}
+	return 0
+}
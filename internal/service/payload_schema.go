@@ -0,0 +1,689 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"vinzhub-rest-api/internal/domain"
+	"vinzhub-rest-api/internal/metrics"
+)
+
+const (
+	// maxSchemaViolations caps the violations reported for one document.
+	maxSchemaViolations = 20
+	// maxSchemaDepth bounds subschema nesting during validation, so a $ref
+	// cycle that never descends into the document can't recurse forever.
+	maxSchemaDepth = 256
+)
+
+// schemaRejections counts syncs refused by the payload schema.
+var schemaRejections = metrics.NewCounterVec("vinzhub_sync_schema_rejections_total",
+	"Inventory syncs rejected by the payload schema, by section.", "section")
+
+// ErrSchemaViolation is wrapped by the *SchemaError of a rejected sync.
+var ErrSchemaViolation = errors.New("payload violates the inventory schema")
+
+// SchemaViolation is one way a document breaks the schema, at a JSON
+// Pointer into the document.
+type SchemaViolation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// SchemaError lists the violations of a rejected document.
+type SchemaError struct {
+	Violations []SchemaViolation
+	Truncated  bool // More violations than were listed
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s (%d violations)", ErrSchemaViolation, len(e.Violations))
+}
+
+func (e *SchemaError) Unwrap() error { return ErrSchemaViolation }
+
+// PayloadSchema validates synced documents against a JSON Schema. It
+// covers the keywords that describe a document's shape: type, enum, const,
+// properties, required, additionalProperties, patternProperties,
+// min/maxProperties, items, prefixItems, min/maxItems, uniqueItems,
+// min/maxLength, pattern, minimum, maximum, exclusiveMinimum/Maximum,
+// multipleOf, allOf, anyOf, oneOf, not and local $ref ("#/$defs/...",
+// "#/definitions/..."). Other keywords are ignored, as the spec asks of
+// unknown ones. A schema is immutable once loaded and safe for concurrent
+// use.
+type PayloadSchema struct {
+	path string
+	root *schemaNode
+}
+
+// schemaNode is a compiled (sub)schema.
+type schemaNode struct {
+	always *bool // true/false schema
+
+	ref   string
+	types []string
+	enum  []interface{}
+	cnst  *interface{}
+
+	properties        map[string]*schemaNode
+	required          []string
+	additional        *schemaNode
+	patternProperties []patternSchema
+	minProperties     *int
+	maxProperties     *int
+
+	items       *schemaNode
+	prefixItems []*schemaNode
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	minimum          *big.Float
+	maximum          *big.Float
+	exclusiveMinimum *big.Float
+	exclusiveMaximum *big.Float
+	multipleOf       *big.Float
+
+	allOf []*schemaNode
+	anyOf []*schemaNode
+	oneOf []*schemaNode
+	not   *schemaNode
+}
+
+type patternSchema struct {
+	re     *regexp.Regexp
+	schema *schemaNode
+}
+
+// LoadPayloadSchema reads and compiles a schema file.
+func LoadPayloadSchema(path string) (*PayloadSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	schema, err := ParsePayloadSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	schema.path = path
+	return schema, nil
+}
+
+// ParsePayloadSchema compiles a schema document.
+func ParsePayloadSchema(data []byte) (*PayloadSchema, error) {
+	doc, err := decodeJSONNumbers(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema JSON: %w", err)
+	}
+	c := &schemaCompiler{root: doc, refs: make(map[string]*schemaNode)}
+	root, err := c.compile(doc, "#")
+	if err != nil {
+		return nil, err
+	}
+	root.resolve(c.refs, make(map[*schemaNode]bool))
+	return &PayloadSchema{root: root}, nil
+}
+
+// Path returns the file the schema was loaded from.
+func (p *PayloadSchema) Path() string {
+	return p.path
+}
+
+// Validate checks a document. Returns a *SchemaError listing violations,
+// or nil when the document conforms.
+func (p *PayloadSchema) Validate(rawJSON []byte) error {
+	doc, err := decodeJSONNumbers(rawJSON)
+	if err != nil {
+		return &SchemaError{Violations: []SchemaViolation{{Path: "", Message: "invalid JSON"}}}
+	}
+	v := &schemaValidator{}
+	v.check(p.root, doc, "")
+	if len(v.violations) == 0 {
+		return nil
+	}
+	return &SchemaError{Violations: v.violations, Truncated: v.truncated}
+}
+
+// SetPayloadSchema validates syncs of the default section against schema
+// before they are stored. nil turns validation off.
+func (s *InventoryService) SetPayloadSchema(schema *PayloadSchema) {
+	s.payloadSchema = schema
+}
+
+// checkSchema applies the payload schema to a sync.
+func (s *InventoryService) checkSchema(ctx context.Context, req SyncRequest, section string) error {
+	if s.payloadSchema == nil || section != domain.DefaultSection {
+		return nil
+	}
+	if err := s.payloadSchema.Validate(req.RawJSON); err != nil {
+		schemaRejections.Inc(section)
+		return err
+	}
+	return nil
+}
+
+// decodeJSONNumbers decodes JSON keeping numbers exact.
+func decodeJSONNumbers(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// schemaCompiler turns a decoded schema into schemaNodes. refs maps every
+// "$ref" seen to its target, filled in as the definitions are compiled.
+type schemaCompiler struct {
+	root interface{}
+	refs map[string]*schemaNode
+}
+
+func (c *schemaCompiler) compile(raw interface{}, at string) (*schemaNode, error) {
+	if b, ok := raw.(bool); ok {
+		return &schemaNode{always: &b}, nil
+	}
+	obj, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: schema must be an object or boolean", at)
+	}
+	n := &schemaNode{}
+	var err error
+
+	if ref, ok := obj["$ref"].(string); ok {
+		if !strings.HasPrefix(ref, "#") {
+			return nil, fmt.Errorf("%s: only local $ref is supported, got %s", at, ref)
+		}
+		n.ref = ref
+		if _, seen := c.refs[ref]; !seen {
+			c.refs[ref] = nil
+			target, err := c.lookup(ref)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", at, err)
+			}
+			if c.refs[ref], err = c.compile(target, ref); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	switch t := obj["type"].(type) {
+	case string:
+		n.types = []string{t}
+	case []interface{}:
+		for _, v := range t {
+			name, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s/type: must list type names", at)
+			}
+			n.types = append(n.types, name)
+		}
+	case nil:
+	default:
+		return nil, fmt.Errorf("%s/type: must be a string or array", at)
+	}
+	for _, name := range n.types {
+		switch name {
+		case "null", "boolean", "object", "array", "number", "integer", "string":
+		default:
+			return nil, fmt.Errorf("%s/type: unknown type %q", at, name)
+		}
+	}
+
+	if enum, ok := obj["enum"].([]interface{}); ok {
+		n.enum = enum
+	}
+	if v, ok := obj["const"]; ok {
+		n.cnst = &v
+	}
+
+	if props, ok := obj["properties"].(map[string]interface{}); ok {
+		n.properties = make(map[string]*schemaNode, len(props))
+		for name, sub := range props {
+			if n.properties[name], err = c.compile(sub, at+"/properties/"+escapePathSegment(name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if req, ok := obj["required"].([]interface{}); ok {
+		for _, v := range req {
+			name, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s/required: must list property names", at)
+			}
+			n.required = append(n.required, name)
+		}
+	}
+	if sub, ok := obj["additionalProperties"]; ok {
+		if n.additional, err = c.compile(sub, at+"/additionalProperties"); err != nil {
+			return nil, err
+		}
+	}
+	if pp, ok := obj["patternProperties"].(map[string]interface{}); ok {
+		for expr, sub := range pp {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("%s/patternProperties: %w", at, err)
+			}
+			node, err := c.compile(sub, at+"/patternProperties/"+escapePathSegment(expr))
+			if err != nil {
+				return nil, err
+			}
+			n.patternProperties = append(n.patternProperties, patternSchema{re: re, schema: node})
+		}
+	}
+
+	if sub, ok := obj["items"]; ok {
+		if n.items, err = c.compile(sub, at+"/items"); err != nil {
+			return nil, err
+		}
+	}
+	if prefix, ok := obj["prefixItems"].([]interface{}); ok {
+		for i, sub := range prefix {
+			node, err := c.compile(sub, at+"/prefixItems/"+strconv.Itoa(i))
+			if err != nil {
+				return nil, err
+			}
+			n.prefixItems = append(n.prefixItems, node)
+		}
+	}
+	n.uniqueItems, _ = obj["uniqueItems"].(bool)
+
+	for key, dst := range map[string]**int{
+		"minProperties": &n.minProperties, "maxProperties": &n.maxProperties,
+		"minItems": &n.minItems, "maxItems": &n.maxItems,
+		"minLength": &n.minLength, "maxLength": &n.maxLength,
+	} {
+		if v, ok := obj[key]; ok {
+			num, ok := v.(json.Number)
+			i, err := num.Int64()
+			if !ok || err != nil || i < 0 {
+				return nil, fmt.Errorf("%s/%s: must be a non-negative integer", at, key)
+			}
+			limit := int(i)
+			*dst = &limit
+		}
+	}
+	for key, dst := range map[string]**big.Float{
+		"minimum": &n.minimum, "maximum": &n.maximum,
+		"exclusiveMinimum": &n.exclusiveMinimum, "exclusiveMaximum": &n.exclusiveMaximum,
+		"multipleOf": &n.multipleOf,
+	} {
+		if v, ok := obj[key]; ok {
+			num, ok := v.(json.Number)
+			f, _, err := big.ParseFloat(string(num), 10, 128, big.ToNearestEven)
+			if !ok || err != nil {
+				return nil, fmt.Errorf("%s/%s: must be a number", at, key)
+			}
+			*dst = f
+		}
+	}
+	if n.multipleOf != nil && n.multipleOf.Sign() <= 0 {
+		return nil, fmt.Errorf("%s/multipleOf: must be positive", at)
+	}
+
+	if expr, ok := obj["pattern"].(string); ok {
+		if n.pattern, err = regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("%s/pattern: %w", at, err)
+		}
+	}
+
+	for key, dst := range map[string]*[]*schemaNode{"allOf": &n.allOf, "anyOf": &n.anyOf, "oneOf": &n.oneOf} {
+		list, ok := obj[key].([]interface{})
+		if !ok {
+			continue
+		}
+		for i, sub := range list {
+			node, err := c.compile(sub, at+"/"+key+"/"+strconv.Itoa(i))
+			if err != nil {
+				return nil, err
+			}
+			*dst = append(*dst, node)
+		}
+	}
+	if sub, ok := obj["not"]; ok {
+		if n.not, err = c.compile(sub, at+"/not"); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// lookup finds the schema a local $ref points at.
+func (c *schemaCompiler) lookup(ref string) (interface{}, error) {
+	node := c.root
+	pointer := strings.TrimPrefix(ref, "#")
+	if pointer == "" {
+		return node, nil
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch v := node.(type) {
+		case map[string]interface{}:
+			next, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("$ref %s not found", ref)
+			}
+			node = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("$ref %s not found", ref)
+			}
+			node = v[i]
+		default:
+			return nil, fmt.Errorf("$ref %s not found", ref)
+		}
+	}
+	return node, nil
+}
+
+// resolve links every $ref node to its compiled target, once per node so
+// recursive schemas terminate.
+func (n *schemaNode) resolve(refs map[string]*schemaNode, seen map[*schemaNode]bool) {
+	if n == nil || seen[n] {
+		return
+	}
+	seen[n] = true
+	if n.ref != "" {
+		n.allOf = append(n.allOf, refs[n.ref])
+		n.ref = ""
+	}
+	for _, sub := range n.properties {
+		sub.resolve(refs, seen)
+	}
+	for _, p := range n.patternProperties {
+		p.schema.resolve(refs, seen)
+	}
+	for _, list := range [][]*schemaNode{n.prefixItems, n.allOf, n.anyOf, n.oneOf} {
+		for _, sub := range list {
+			sub.resolve(refs, seen)
+		}
+	}
+	n.additional.resolve(refs, seen)
+	n.items.resolve(refs, seen)
+	n.not.resolve(refs, seen)
+}
+
+// schemaValidator collects violations of one document.
+type schemaValidator struct {
+	violations []SchemaViolation
+	truncated  bool
+	depth      int
+}
+
+func (v *schemaValidator) fail(path, format string, args ...interface{}) {
+	if len(v.violations) >= maxSchemaViolations {
+		v.truncated = true
+		return
+	}
+	v.violations = append(v.violations, SchemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// matches reports whether value conforms to n, without recording anything.
+func (v *schemaValidator) matches(n *schemaNode, value interface{}) bool {
+	probe := &schemaValidator{depth: v.depth}
+	probe.check(n, value, "")
+	return len(probe.violations) == 0 && !probe.truncated
+}
+
+func (v *schemaValidator) check(n *schemaNode, value interface{}, path string) {
+	if v.truncated {
+		return
+	}
+	if v.depth >= maxSchemaDepth {
+		v.fail(path, "schema nests too deeply")
+		return
+	}
+	v.depth++
+	defer func() { v.depth-- }()
+	if n.always != nil {
+		if !*n.always {
+			v.fail(path, "not allowed")
+		}
+		return
+	}
+
+	if len(n.types) > 0 {
+		actual := jsonTypeOf(value)
+		ok := false
+		for _, t := range n.types {
+			ok = ok || t == actual || (t == "number" && actual == "integer")
+		}
+		if !ok {
+			v.fail(path, "expected %s, got %s", strings.Join(n.types, " or "), actual)
+			return
+		}
+	}
+	if n.enum != nil {
+		found := false
+		for _, allowed := range n.enum {
+			found = found || jsonEqual(allowed, value)
+		}
+		if !found {
+			v.fail(path, "not one of the allowed values")
+		}
+	}
+	if n.cnst != nil && !jsonEqual(*n.cnst, value) {
+		v.fail(path, "must equal the constant value")
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		v.checkObject(n, val, path)
+	case []interface{}:
+		v.checkArray(n, val, path)
+	case string:
+		length := utf8.RuneCountInString(val)
+		if n.minLength != nil && length < *n.minLength {
+			v.fail(path, "shorter than %d characters", *n.minLength)
+		}
+		if n.maxLength != nil && length > *n.maxLength {
+			v.fail(path, "longer than %d characters", *n.maxLength)
+		}
+		if n.pattern != nil && !n.pattern.MatchString(val) {
+			v.fail(path, "does not match pattern %s", n.pattern)
+		}
+	case json.Number:
+		v.checkNumber(n, val, path)
+	}
+
+	for _, sub := range n.allOf {
+		v.check(sub, value, path)
+	}
+	if len(n.anyOf) > 0 {
+		ok := false
+		for _, sub := range n.anyOf {
+			if ok = v.matches(sub, value); ok {
+				break
+			}
+		}
+		if !ok {
+			v.fail(path, "matches none of anyOf")
+		}
+	}
+	if len(n.oneOf) > 0 {
+		count := 0
+		for _, sub := range n.oneOf {
+			if v.matches(sub, value) {
+				count++
+			}
+		}
+		if count != 1 {
+			v.fail(path, "matches %d of oneOf, expected exactly 1", count)
+		}
+	}
+	if n.not != nil && v.matches(n.not, value) {
+		v.fail(path, "must not match the not schema")
+	}
+}
+
+func (v *schemaValidator) checkObject(n *schemaNode, obj map[string]interface{}, path string) {
+	for _, name := range n.required {
+		if _, ok := obj[name]; !ok {
+			v.fail(path+"/"+escapePathSegment(name), "required property missing")
+		}
+	}
+	if n.minProperties != nil && len(obj) < *n.minProperties {
+		v.fail(path, "fewer than %d properties", *n.minProperties)
+	}
+	if n.maxProperties != nil && len(obj) > *n.maxProperties {
+		v.fail(path, "more than %d properties", *n.maxProperties)
+	}
+
+	// Sorted so the reported violations are stable
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		child := path + "/" + escapePathSegment(name)
+		matched := false
+		if sub, ok := n.properties[name]; ok {
+			matched = true
+			v.check(sub, obj[name], child)
+		}
+		for _, p := range n.patternProperties {
+			if p.re.MatchString(name) {
+				matched = true
+				v.check(p.schema, obj[name], child)
+			}
+		}
+		if !matched && n.additional != nil {
+			if n.additional.always != nil && !*n.additional.always {
+				v.fail(child, "additional property not allowed")
+				continue
+			}
+			v.check(n.additional, obj[name], child)
+		}
+	}
+}
+
+func (v *schemaValidator) checkArray(n *schemaNode, arr []interface{}, path string) {
+	if n.minItems != nil && len(arr) < *n.minItems {
+		v.fail(path, "fewer than %d items", *n.minItems)
+	}
+	if n.maxItems != nil && len(arr) > *n.maxItems {
+		v.fail(path, "more than %d items", *n.maxItems)
+	}
+	for i, item := range arr {
+		child := path + "/" + strconv.Itoa(i)
+		switch {
+		case i < len(n.prefixItems):
+			v.check(n.prefixItems[i], item, child)
+		case n.items != nil:
+			v.check(n.items, item, child)
+		}
+	}
+	if n.uniqueItems {
+		for i := 1; i < len(arr); i++ {
+			for j := 0; j < i; j++ {
+				if jsonEqual(arr[i], arr[j]) {
+					v.fail(path+"/"+strconv.Itoa(i), "duplicate of item %d", j)
+					break
+				}
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) checkNumber(n *schemaNode, num json.Number, path string) {
+	f, _, err := big.ParseFloat(string(num), 10, 128, big.ToNearestEven)
+	if err != nil {
+		v.fail(path, "unreadable number")
+		return
+	}
+	if n.minimum != nil && f.Cmp(n.minimum) < 0 {
+		v.fail(path, "less than minimum %s", n.minimum.Text('g', -1))
+	}
+	if n.maximum != nil && f.Cmp(n.maximum) > 0 {
+		v.fail(path, "greater than maximum %s", n.maximum.Text('g', -1))
+	}
+	if n.exclusiveMinimum != nil && f.Cmp(n.exclusiveMinimum) <= 0 {
+		v.fail(path, "not greater than %s", n.exclusiveMinimum.Text('g', -1))
+	}
+	if n.exclusiveMaximum != nil && f.Cmp(n.exclusiveMaximum) >= 0 {
+		v.fail(path, "not less than %s", n.exclusiveMaximum.Text('g', -1))
+	}
+	if n.multipleOf != nil {
+		quo := new(big.Float).Quo(f, n.multipleOf)
+		if !quo.IsInt() {
+			v.fail(path, "not a multiple of %s", n.multipleOf.Text('g', -1))
+		}
+	}
+}
+
+// jsonTypeOf names a decoded value's JSON Schema type.
+func jsonTypeOf(value interface{}) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		if f, _, err := big.ParseFloat(string(val), 10, 128, big.ToNearestEven); err == nil && f.IsInt() {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+// jsonEqual compares decoded values, numbers by value (1 equals 1.0).
+func jsonEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, _, errX := big.ParseFloat(string(x), 10, 128, big.ToNearestEven)
+		fy, _, errY := big.ParseFloat(string(y), 10, 128, big.ToNearestEven)
+		return errX == nil && errY == nil && fx.Cmp(fy) == 0
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			if w, ok := y[k]; !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
@@ -0,0 +1,29 @@
+package service
+
+import "testing"
+
+func TestPayloadShapeAndElementCount(t *testing.T) {
+	// Bodies reach the service already validated as JSON
+	tests := []struct {
+		raw      string
+		shape    string
+		elements int
+	}{
+		{`null`, "null", 0},
+		{` null `, "null", 0},
+		{`{}`, "empty", 0},
+		{` [ ] `, "empty", 0},
+		{`{"Items":[],"Coins":0}`, "", 2},
+		{`[1,2,3]`, "", 3},
+		{`0`, "", 0},
+		{`""`, "", 0},
+	}
+	for _, tt := range tests {
+		if got := payloadShape([]byte(tt.raw)); got != tt.shape {
+			t.Errorf("payloadShape(%s) = %q, want %q", tt.raw, got, tt.shape)
+		}
+		if got := ElementCount([]byte(tt.raw)); got != tt.elements {
+			t.Errorf("ElementCount(%s) = %d, want %d", tt.raw, got, tt.elements)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vinzhub-rest-api/internal/cache"
+)
+
+// missCachePrefix namespaces negative-cache tombstones in the lookup cache.
+const missCachePrefix = "inventory:miss:"
+
+// missStripes is how many generation counters tombstone keys are hashed
+// over. Keys sharing a stripe only cost each other a skipped tombstone.
+const missStripes = 256
+
+// readCache answers repeat misses from short-lived tombstones, repeat
+// section reads from an in-process LRU, and shares one storage lookup
+// between concurrent identical reads.
+type readCache struct {
+	cache cache.Cache // nil disables tombstones
+	ttl   time.Duration
+
+	sections *inventoryCache // nil disables the section read cache
+
+	flights flightGroup
+
+	// generations is bumped by forget, so a miss found by a lookup that a
+	// sync overtook is not remembered.
+	generations [missStripes]atomic.Uint64
+
+	tombstoneHits     atomic.Int64
+	tombstonesSet     atomic.Int64
+	tombstonesSkipped atomic.Int64
+	invalidations     atomic.Int64
+	coalesced         atomic.Int64
+}
+
+// missKey is the tombstone key of one section, or of the whole user when
+// section is empty.
+func missKey(robloxUserID, section string) string {
+	if section == "" {
+		return missCachePrefix + robloxUserID
+	}
+	return missCachePrefix + robloxUserID + ":" + section
+}
+
+// tombstoned reports whether a recent lookup found nothing for key.
+func (c *readCache) tombstoned(ctx context.Context, key string) bool {
+	if c.cache == nil {
+		return false
+	}
+	if ok, _ := c.cache.Exists(ctx, key); ok {
+		c.tombstoneHits.Add(1)
+		return true
+	}
+	return false
+}
+
+// missStripe returns the generation stripe of a tombstone key.
+func missStripe(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % missStripes)
+}
+
+// stripe returns the generation counter of key.
+func (c *readCache) stripe(key string) *atomic.Uint64 {
+	return &c.generations[missStripe(key)]
+}
+
+// generation returns key's generation, taken before a lookup whose miss
+// may be remembered.
+func (c *readCache) generation(key string) uint64 {
+	return c.stripe(key).Load()
+}
+
+// remember records a miss for key found by a lookup started at generation
+// gen. A forget since then means a sync may have landed after the lookup,
+// so the miss is dropped instead of hiding that sync for the tombstone TTL.
+func (c *readCache) remember(ctx context.Context, key string, gen uint64) {
+	if c.cache == nil {
+		return
+	}
+	stripe := c.stripe(key)
+	if stripe.Load() != gen {
+		c.tombstonesSkipped.Add(1)
+		return
+	}
+	c.cache.Set(ctx, key, []byte{1}, c.ttl)
+	if stripe.Load() != gen {
+		// forget ran during Set and may have deleted the key before it
+		c.cache.Delete(ctx, key)
+		c.tombstonesSkipped.Add(1)
+		return
+	}
+	c.tombstonesSet.Add(1)
+}
+
+// forget drops the tombstones and cached read a sync of this section
+// makes stale.
+func (c *readCache) forget(ctx context.Context, robloxUserID, section string) {
+	if c.sections != nil {
+		c.sections.forget(inventoryCacheKey(robloxUserID, section))
+		c.sections.forget(inventoryCacheKey(robloxUserID, ""))
+	}
+	if c.cache == nil {
+		return
+	}
+	// Bump before deleting: a remember that sets after the delete then
+	// sees the new generation and deletes its own tombstone
+	c.stripe(missKey(robloxUserID, section)).Add(1)
+	c.stripe(missKey(robloxUserID, "")).Add(1)
+	c.cache.Delete(ctx, missKey(robloxUserID, section))
+	c.cache.Delete(ctx, missKey(robloxUserID, ""))
+	c.invalidations.Add(1)
+}
+
+// sharedReadTimeout bounds a coalesced read, which no longer follows any
+// one caller's deadline.
+const sharedReadTimeout = 30 * time.Second
+
+// do runs fn once for all concurrent callers with the same key. fn gets a
+// context detached from the caller's, so one caller going away doesn't
+// fail the read for the others; each caller still returns as soon as its
+// own context is done.
+func (c *readCache) do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	v, err, shared := c.flights.Do(ctx, key, func() (interface{}, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedReadTimeout)
+		defer cancel()
+		return fn(fetchCtx)
+	})
+	if shared {
+		c.coalesced.Add(1)
+	}
+	return v, err
+}
+
+// cached returns a section read from the read cache.
+func (c *readCache) cached(robloxUserID, section string) (SectionData, bool) {
+	if c.sections == nil {
+		return SectionData{}, false
+	}
+	v, ok := c.sections.get(inventoryCacheKey(robloxUserID, section), time.Now())
+	sec, _ := v.(SectionData)
+	return sec, ok
+}
+
+// keep caches a section read started at started.
+func (c *readCache) keep(robloxUserID, section string, sec SectionData, started time.Time) {
+	if c.sections != nil && sec.RawJSON != nil {
+		c.sections.put(inventoryCacheKey(robloxUserID, section), sec, int64(len(sec.RawJSON)), started)
+	}
+}
+
+// cachedAll returns a read of all of a user's sections from the read
+// cache. The map is shared: callers copy it before handing it out.
+func (c *readCache) cachedAll(robloxUserID string) (map[string]SectionData, bool) {
+	if c.sections == nil {
+		return nil, false
+	}
+	v, ok := c.sections.get(inventoryCacheKey(robloxUserID, ""), time.Now())
+	all, _ := v.(map[string]SectionData)
+	return all, ok
+}
+
+// keepAll caches a read of all of a user's sections started at started.
+func (c *readCache) keepAll(robloxUserID string, all map[string]SectionData, started time.Time) {
+	if c.sections == nil || len(all) == 0 {
+		return
+	}
+	kept := make(map[string]SectionData, len(all))
+	var size int64
+	for section, sec := range all {
+		kept[section] = sec
+		size += int64(len(sec.RawJSON))
+	}
+	c.sections.put(inventoryCacheKey(robloxUserID, ""), kept, size, started)
+}
+
+// stats returns read cache, negative cache and coalescing counters.
+func (c *readCache) stats() map[string]interface{} {
+	sections := map[string]interface{}{"enabled": false}
+	if c.sections != nil {
+		sections = c.sections.stats()
+	}
+	return map[string]interface{}{
+		"section_cache": sections,
+		"negative_cache": map[string]interface{}{
+			"enabled":            c.cache != nil,
+			"ttl_ms":             c.ttl.Milliseconds(),
+			"tombstone_hits":     c.tombstoneHits.Load(),
+			"tombstones_set":     c.tombstonesSet.Load(),
+			"tombstones_skipped": c.tombstonesSkipped.Load(),
+			"invalidations":      c.invalidations.Load(),
+		},
+		"coalesced_reads": c.coalesced.Load(),
+	}
+}
+
+// flightGroup deduplicates concurrent calls by key.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// Do runs fn for key unless a call for key is already running, in which
+// case it waits for that call and returns its result with shared = true.
+// fn runs in its own goroutine, so it completes for the other callers when
+// ctx is done; the caller then gets ctx's error.
+func (g *flightGroup) Do(ctx context.Context, key string, fn func() (interface{}, error)) (interface{}, error, bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	call, shared := g.calls[key]
+	if !shared {
+		call = &flightCall{done: make(chan struct{})}
+		g.calls[key] = call
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					call.err = fmt.Errorf("coalesced read panicked: %v", r)
+				}
+				g.mu.Lock()
+				delete(g.calls, key)
+				g.mu.Unlock()
+				close(call.done)
+			}()
+			call.val, call.err = fn()
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.val, call.err, shared
+	case <-ctx.Done():
+		return nil, ctx.Err(), shared
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vinzhub-rest-api/internal/cache"
+)
+
+// racingCache runs beforeSet ahead of each Set, standing in for a sync
+// that lands while a tombstone is being written.
+type racingCache struct {
+	cache.Cache
+	beforeSet func()
+}
+
+func (c *racingCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if c.beforeSet != nil {
+		c.beforeSet()
+	}
+	return c.Cache.Set(ctx, key, value, ttl)
+}
+
+func TestReadCacheTombstoneGeneration(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		key  string
+		// forget runs between the lookup starting and its miss being
+		// remembered; duringSet runs inside the tombstone write.
+		forget, duringSet func(c *readCache)
+		want              bool
+	}{
+		{
+			name: "no sync",
+			key:  missKey("100", "inventory"),
+			want: true,
+		},
+		{
+			name:   "sync of the section before remember",
+			key:    missKey("100", "inventory"),
+			forget: func(c *readCache) { c.forget(ctx, "100", "inventory") },
+		},
+		{
+			name:   "sync of another section clears the whole-user miss",
+			key:    missKey("100", ""),
+			forget: func(c *readCache) { c.forget(ctx, "100", "settings") },
+		},
+		{
+			name:      "sync during the tombstone write",
+			key:       missKey("100", "inventory"),
+			duringSet: func(c *readCache) { c.forget(ctx, "100", "inventory") },
+		},
+		{
+			name:   "another user's sync",
+			key:    missKey("100", "inventory"),
+			forget: func(c *readCache) { c.forget(ctx, "200", "inventory") },
+			// Skipped only when the keys share a stripe
+			want: missStripe(missKey("100", "inventory")) != missStripe(missKey("200", "inventory")) &&
+				missStripe(missKey("100", "inventory")) != missStripe(missKey("200", "")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := cache.NewMemoryCache()
+			t.Cleanup(func() { mem.Close() })
+			racing := &racingCache{Cache: mem}
+			c := &readCache{cache: racing, ttl: time.Minute}
+
+			gen := c.generation(tt.key)
+			if tt.forget != nil {
+				tt.forget(c)
+			}
+			if tt.duringSet != nil {
+				racing.beforeSet = func() { tt.duringSet(c) }
+			}
+			c.remember(ctx, tt.key, gen)
+
+			if got := c.tombstoned(ctx, tt.key); got != tt.want {
+				t.Errorf("tombstoned = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"vinzhub-rest-api/internal/repository"
+)
+
+// gatedRepo serves every section read once release is closed, counting
+// the reads and recording the context each one finished with.
+type gatedRepo struct {
+	repository.InventoryRepository
+	release chan struct{}
+	started chan struct{} // receives once per read
+	calls   atomic.Int64
+
+	mu      sync.Mutex
+	ctxErrs []error
+}
+
+func newGatedRepo() *gatedRepo {
+	return &gatedRepo{release: make(chan struct{}), started: make(chan struct{}, 16)}
+}
+
+func (r *gatedRepo) GetRawInventorySection(ctx context.Context, robloxUserID, section string) ([]byte, *time.Time, error) {
+	r.calls.Add(1)
+	r.started <- struct{}{}
+	<-r.release
+	r.mu.Lock()
+	r.ctxErrs = append(r.ctxErrs, ctx.Err())
+	r.mu.Unlock()
+	now := time.Now()
+	return []byte(`{"user":"` + robloxUserID + `"}`), &now, nil
+}
+
+// readAll runs n concurrent GetRawInventory calls for user, the first one
+// on ctx0 when set and the rest on a background context. It returns once
+// every goroutine has started; wait collects the results.
+func readAll(s *InventoryService, user string, n int, ctx0 context.Context) (wait func() ([][]byte, []error)) {
+	data, errs := make([][]byte, n), make([]error, n)
+	var ready, done sync.WaitGroup
+	ready.Add(n)
+	done.Add(n)
+	for i := 0; i < n; i++ {
+		ctx := context.Background()
+		if i == 0 && ctx0 != nil {
+			ctx = ctx0
+		}
+		go func(i int, ctx context.Context) {
+			defer done.Done()
+			ready.Done()
+			data[i], _, errs[i] = s.GetRawInventory(ctx, user)
+		}(i, ctx)
+	}
+	ready.Wait()
+	return func() ([][]byte, []error) {
+		done.Wait()
+		return data, errs
+	}
+}
+
+func TestGetRawInventoryCoalescesConcurrentReads(t *testing.T) {
+	repo := newGatedRepo()
+	s := NewInventoryService(repo, nil)
+
+	wait := readAll(s, "100", 100, nil)
+	<-repo.started
+	// Give the other callers time to join the running read
+	time.Sleep(50 * time.Millisecond)
+	close(repo.release)
+	data, errs := wait()
+
+	if n := repo.calls.Load(); n != 1 {
+		t.Errorf("repository read %d times for 100 concurrent calls, want 1", n)
+	}
+	for i := range data {
+		if errs[i] != nil || string(data[i]) != `{"user":"100"}` {
+			t.Fatalf("call %d = %s, %v; want the shared result", i, data[i], errs[i])
+		}
+	}
+	if got := s.reads.stats()["coalesced_reads"]; got != int64(99) {
+		t.Errorf("coalesced_reads = %v, want 99", got)
+	}
+}
+
+func TestGetRawInventoryCancelledCallerKeepsSharedRead(t *testing.T) {
+	repo := newGatedRepo()
+	s := NewInventoryService(repo, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wait := readAll(s, "100", 100, ctx)
+	<-repo.started
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	// The cancelled caller returns while the read is still blocked
+	time.Sleep(20 * time.Millisecond)
+	close(repo.release)
+	data, errs := wait()
+
+	if n := repo.calls.Load(); n != 1 {
+		t.Errorf("repository read %d times, want 1", n)
+	}
+	if !errors.Is(errs[0], context.Canceled) || data[0] != nil {
+		t.Errorf("cancelled call = %s, %v; want context.Canceled", data[0], errs[0])
+	}
+	for i := 1; i < len(data); i++ {
+		if errs[i] != nil || string(data[i]) != `{"user":"100"}` {
+			t.Fatalf("call %d = %s, %v; want the shared result", i, data[i], errs[i])
+		}
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if len(repo.ctxErrs) != 1 || repo.ctxErrs[0] != nil {
+		t.Errorf("shared read finished with context errors %v, want it uncancelled", repo.ctxErrs)
+	}
+}
+
+func TestGetRawInventoryDoesNotCoalesceUsers(t *testing.T) {
+	repo := newGatedRepo()
+	s := NewInventoryService(repo, nil)
+
+	waitA := readAll(s, "100", 1, nil)
+	waitB := readAll(s, "200", 1, nil)
+	<-repo.started
+	<-repo.started
+	close(repo.release)
+	a, _ := waitA()
+	b, _ := waitB()
+
+	if n := repo.calls.Load(); n != 2 {
+		t.Errorf("repository read %d times for two users, want 2", n)
+	}
+	if string(a[0]) != `{"user":"100"}` || string(b[0]) != `{"user":"200"}` {
+		t.Errorf("results = %s, %s; want each user's own", a[0], b[0])
+	}
+}
+
+func TestFlightGroupPanicBecomesError(t *testing.T) {
+	var g flightGroup
+	_, err, _ := g.Do(context.Background(), "k", func() (interface{}, error) { panic("boom") })
+	if err == nil || !strings.Contains(err.Error(), "coalesced read panicked: boom") {
+		t.Fatalf("err = %v, want the panic reported", err)
+	}
+	// The key is released, so the next call runs again
+	v, err, shared := g.Do(context.Background(), "k", func() (interface{}, error) { return 1, nil })
+	if v != 1 || err != nil || shared {
+		t.Errorf("Do after panic = %v, %v, %v; want a fresh run", v, err, shared)
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"vinzhub-rest-api/internal/lifecycle"
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/repository"
+)
+
+// recompressPause separates batches so flushes get the write lock between
+// them.
+const recompressPause = 50 * time.Millisecond
+
+// ErrRecompressRunning is returned when a recompression is started while
+// one is in progress.
+var ErrRecompressRunning = errors.New("recompression already running")
+
+// RecompressProgress describes the current or last recompression.
+type RecompressProgress struct {
+	Running    bool       `json:"running"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Partition  int        `json:"partition"`
+	Table      string     `json:"table,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	repository.RecompressStats
+}
+
+// Recompressor converts stored documents to the storage format the store
+// writes now, a batch at a time in the background. It is started by an
+// admin once after compression is turned on (or off); rows written since
+// are already in the new format and are skipped.
+type Recompressor struct {
+	store     repository.InventoryStore
+	batchSize int
+	logger    *slog.Logger
+
+	mu       sync.Mutex
+	progress RecompressProgress
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewRecompressor creates a recompressor over the inventory store.
+func NewRecompressor(store repository.InventoryStore, batchSize int) *Recompressor {
+	if batchSize <= 0 {
+		batchSize = 200
+	}
+	return &Recompressor{store: store, batchSize: batchSize, logger: logging.Component("Recompress"), stop: make(chan struct{})}
+}
+
+// Start begins a recompression of every partition and returns its initial
+// progress.
+func (c *Recompressor) Start() (RecompressProgress, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.progress.Running {
+		return c.progress, ErrRecompressRunning
+	}
+	now := time.Now().UTC()
+	c.progress = RecompressProgress{Running: true, StartedAt: &now}
+	lifecycle.Go("storage.recompress", c.run)
+	return c.progress, nil
+}
+
+// Progress returns the state of the current or last recompression.
+func (c *Recompressor) Progress() RecompressProgress {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.progress
+}
+
+// Close stops a running recompression after its current batch.
+func (c *Recompressor) Close() {
+	c.stopOnce.Do(func() { close(c.stop) })
+}
+
+func (c *Recompressor) run() {
+	err := c.walk()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now().UTC()
+	c.progress.Running = false
+	c.progress.FinishedAt = &now
+	if err != nil {
+		c.progress.Error = err.Error()
+		c.logger.Error("Stopped", "scanned", c.progress.Scanned, "error", err)
+		return
+	}
+	c.logger.Info("Done", "scanned", c.progress.Scanned, "rewritten", c.progress.Rewritten,
+		"bytes_before", c.progress.BytesBefore, "bytes_after", c.progress.BytesAfter)
+}
+
+// walk recompresses every table of every partition.
+func (c *Recompressor) walk() error {
+	for i, partition := range c.store.Partitions() {
+		for _, table := range repository.RecompressTables {
+			c.mu.Lock()
+			c.progress.Partition, c.progress.Table = i, table
+			c.mu.Unlock()
+
+			var afterID int64
+			for {
+				select {
+				case <-c.stop:
+					return errors.New("stopped by shutdown")
+				default:
+				}
+
+				lastID, stats, err := partition.RecompressBatch(context.Background(), table, afterID, c.batchSize)
+				c.mu.Lock()
+				c.progress.RecompressStats.Add(stats)
+				c.mu.Unlock()
+				if err != nil {
+					return fmt.Errorf("partition %d, %s: %w", i, table, err)
+				}
+				if lastID == afterID {
+					break
+				}
+				afterID = lastID
+				time.Sleep(recompressPause)
+			}
+		}
+	}
+	return nil
+}
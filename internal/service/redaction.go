@@ -0,0 +1,196 @@
+package service
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultRedactMaxBytes is the largest document redaction will parse.
+	DefaultRedactMaxBytes = 1 << 20
+
+	// redactMaxDepth is the deepest nesting redaction will walk.
+	redactMaxDepth = 64
+)
+
+// redactRule is one parsed pointer, optionally limited to a section.
+type redactRule struct {
+	section string // Empty applies to every section
+	tokens  []string
+}
+
+// RedactionPolicy strips configured fields from documents shown to callers
+// who don't own them. Rules are JSON Pointers (RFC 6901), optionally
+// prefixed with "section:" and using "*" to match every array element or
+// object member, e.g. "stats:/TradeChat" or "/Items/*/Note".
+type RedactionPolicy struct {
+	rules    []redactRule
+	maxBytes int
+}
+
+// NewRedactionPolicy parses rules. Malformed entries (not starting with "/")
+// are ignored. maxBytes <= 0 uses DefaultRedactMaxBytes.
+func NewRedactionPolicy(pointers []string, maxBytes int) *RedactionPolicy {
+	if maxBytes <= 0 {
+		maxBytes = DefaultRedactMaxBytes
+	}
+	p := &RedactionPolicy{maxBytes: maxBytes}
+	for _, raw := range pointers {
+		raw = strings.TrimSpace(raw)
+		var section string
+		if i := strings.Index(raw, ":"); i > 0 && !strings.HasPrefix(raw, "/") {
+			section, raw = raw[:i], raw[i+1:]
+		}
+		if !strings.HasPrefix(raw, "/") {
+			continue
+		}
+		tokens := strings.Split(raw[1:], "/")
+		for i, t := range tokens {
+			tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+		}
+		p.rules = append(p.rules, redactRule{section: section, tokens: tokens})
+	}
+	return p
+}
+
+// Enabled reports whether any rule is configured.
+func (p *RedactionPolicy) Enabled() bool {
+	return p != nil && len(p.rules) > 0
+}
+
+// Redact removes the policy's fields from one section document. It returns
+// the filtered document and whether anything was withheld. Documents over
+// the size or depth budget, or that fail to parse, are withheld entirely
+// (returned as null) rather than shown unfiltered.
+func (p *RedactionPolicy) Redact(section string, raw []byte) ([]byte, bool) {
+	if !p.Enabled() || len(raw) == 0 {
+		return raw, false
+	}
+
+	var rules []redactRule
+	for _, rule := range p.rules {
+		if rule.section == "" || rule.section == section {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return raw, false
+	}
+
+	if len(raw) > p.maxBytes {
+		return nil, true
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil || depthExceeds(doc, redactMaxDepth) {
+		return nil, true
+	}
+
+	removed := false
+	for _, rule := range rules {
+		var hit bool
+		doc, hit = removePointer(doc, rule.tokens)
+		removed = removed || hit
+	}
+	if !removed {
+		return raw, false
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, true
+	}
+	return out, true
+}
+
+// removePointer deletes the value addressed by tokens and returns the
+// (possibly new) node and whether anything was removed.
+func removePointer(node interface{}, tokens []string) (interface{}, bool) {
+	if len(tokens) == 0 {
+		return node, false
+	}
+	token, rest := tokens[0], tokens[1:]
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if token == "*" {
+			if len(rest) == 0 {
+				return n, deleteAll(n)
+			}
+			removed := false
+			for k, v := range n {
+				var hit bool
+				n[k], hit = removePointer(v, rest)
+				removed = removed || hit
+			}
+			return n, removed
+		}
+		v, ok := n[token]
+		if !ok {
+			return n, false
+		}
+		if len(rest) == 0 {
+			delete(n, token)
+			return n, true
+		}
+		var hit bool
+		n[token], hit = removePointer(v, rest)
+		return n, hit
+
+	case []interface{}:
+		if token == "*" {
+			if len(rest) == 0 {
+				return []interface{}{}, len(n) > 0
+			}
+			removed := false
+			for i, v := range n {
+				var hit bool
+				n[i], hit = removePointer(v, rest)
+				removed = removed || hit
+			}
+			return n, removed
+		}
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i >= len(n) {
+			return n, false
+		}
+		if len(rest) == 0 {
+			return append(n[:i:i], n[i+1:]...), true
+		}
+		var hit bool
+		n[i], hit = removePointer(n[i], rest)
+		return n, hit
+	}
+	return node, false
+}
+
+// deleteAll empties an object and reports whether it had members.
+func deleteAll(m map[string]interface{}) bool {
+	had := len(m) > 0
+	for k := range m {
+		delete(m, k)
+	}
+	return had
+}
+
+// depthExceeds reports whether a decoded document nests deeper than max.
+func depthExceeds(node interface{}, max int) bool {
+	if max < 0 {
+		return true
+	}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for _, v := range n {
+			if depthExceeds(v, max-1) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, v := range n {
+			if depthExceeds(v, max-1) {
+				return true
+			}
+		}
+	}
+	return false
+}
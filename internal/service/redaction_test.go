@@ -0,0 +1,60 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactionPolicyRedact(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []string
+		section  string
+		doc      string
+		want     string
+		redacted bool
+	}{
+		{"member", []string{"/Secret"}, "inventory", `{"Coins":1,"Secret":"x"}`, `{"Coins":1}`, true},
+		{"nested member", []string{"/Stats/Chat"}, "inventory", `{"Stats":{"Chat":"hi","Level":3}}`, `{"Stats":{"Level":3}}`, true},
+		{"array element", []string{"/Items/1"}, "inventory", `{"Items":["a","b","c"]}`, `{"Items":["a","c"]}`, true},
+		{"wildcard over array", []string{"/Items/*/Note"}, "inventory",
+			`{"Items":[{"Id":1,"Note":"a"},{"Id":2}]}`, `{"Items":[{"Id":1},{"Id":2}]}`, true},
+		{"wildcard over object", []string{"/Pets/*/Owner"}, "inventory",
+			`{"Pets":{"cat":{"Owner":"x"},"dog":{"Owner":"y"}}}`, `{"Pets":{"cat":{},"dog":{}}}`, true},
+		{"escaped tokens", []string{"/a~1b/c~0d"}, "inventory", `{"a/b":{"c~d":1,"e":2}}`, `{"a/b":{"e":2}}`, true},
+		{"section-scoped rule applies", []string{"stats:/TradeChat"}, "stats", `{"TradeChat":"x","Level":1}`, `{"Level":1}`, true},
+		{"section-scoped rule skips others", []string{"stats:/TradeChat"}, "inventory", `{"TradeChat":"x"}`, `{"TradeChat":"x"}`, false},
+		{"nothing to remove", []string{"/Secret"}, "inventory", `{"Coins":1}`, `{"Coins":1}`, false},
+		{"out-of-range index", []string{"/Items/5"}, "inventory", `{"Items":[1]}`, `{"Items":[1]}`, false},
+		{"malformed rule ignored", []string{"Secret"}, "inventory", `{"Secret":"x"}`, `{"Secret":"x"}`, false},
+		{"unparsable document withheld", []string{"/Secret"}, "inventory", `{"Secret":`, ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, redacted := NewRedactionPolicy(tt.rules, 0).Redact(tt.section, []byte(tt.doc))
+			if string(got) != tt.want || redacted != tt.redacted {
+				t.Errorf("Redact = %s, %v; want %s, %v", got, redacted, tt.want, tt.redacted)
+			}
+		})
+	}
+}
+
+func TestRedactionPolicyBudget(t *testing.T) {
+	p := NewRedactionPolicy([]string{"/Secret"}, 64)
+
+	big := `{"Secret":"x","Pad":"` + strings.Repeat("a", 64) + `"}`
+	if got, redacted := p.Redact("inventory", []byte(big)); got != nil || !redacted {
+		t.Errorf("document over the size budget: got %s, %v; want withheld", got, redacted)
+	}
+
+	deep := strings.Repeat(`[`, redactMaxDepth+2) + strings.Repeat(`]`, redactMaxDepth+2)
+	p = NewRedactionPolicy([]string{"/Secret"}, 0)
+	if got, redacted := p.Redact("inventory", []byte(deep)); got != nil || !redacted {
+		t.Errorf("document over the depth budget: got %s, %v; want withheld", got, redacted)
+	}
+
+	var disabled *RedactionPolicy
+	if got, redacted := disabled.Redact("inventory", []byte(big)); string(got) != big || redacted {
+		t.Error("nil policy changed the document")
+	}
+}
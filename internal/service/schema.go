@@ -0,0 +1,456 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vinzhub-rest-api/internal/lifecycle"
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/repository"
+)
+
+const (
+	// schemaQueueSize bounds the samples waiting for inference; samples
+	// arriving while it is full are dropped.
+	schemaQueueSize = 256
+
+	// schemaUbiquitous is the presence above which a path counts as
+	// ubiquitous for the drop alert.
+	schemaUbiquitous = 0.95
+
+	// schemaAlertMinSamples is how many samples a day needs before its
+	// presence is compared against the previous day.
+	schemaAlertMinSamples = 50
+
+	// schemaDayFormat keys profiles by UTC day.
+	schemaDayFormat = "2006-01-02"
+)
+
+// SchemaProfileStore persists payload schema profiles.
+type SchemaProfileStore interface {
+	MergeSchemaProfile(ctx context.Context, p *repository.SchemaProfile) error
+	GetSchemaProfile(ctx context.Context, day, clientVersion string) (*repository.SchemaProfile, error)
+}
+
+// SchemaProfilerConfig bounds schema inference.
+type SchemaProfilerConfig struct {
+	SampleRate    int     // Profile 1 in N flushed payloads
+	MaxDepth      int     // Paths deeper than this aren't descended into
+	MaxPaths      int     // Distinct paths kept per day and client version
+	PresenceAlert float64 // Alert when a ubiquitous path drops below this presence
+}
+
+// SchemaProfiler samples flushed payloads and builds a field-frequency
+// profile (paths, types, presence) per client version per day, to catch
+// clients renaming or dropping fields. Inference runs on its own background
+// goroutine; the flush only hands over sampled payloads.
+type SchemaProfiler struct {
+	store  SchemaProfileStore
+	cfg    SchemaProfilerConfig
+	logger *slog.Logger
+
+	queue    chan schemaSample
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	mu      sync.Mutex
+	pending map[schemaKey]*repository.SchemaProfile // Not yet persisted
+	alerted map[string]bool                         // day + path, alerted once per day
+
+	seen      atomic.Int64
+	sampled   atomic.Int64
+	dropped   atomic.Int64
+	truncated atomic.Int64
+	invalid   atomic.Int64
+	lastSaved atomic.Int64 // unix seconds
+}
+
+// schemaSample is a payload waiting for inference.
+type schemaSample struct {
+	day     string
+	version string
+	raw     []byte
+}
+
+// schemaKey identifies a profile.
+type schemaKey struct {
+	day     string
+	version string
+}
+
+// NewSchemaProfiler creates a profiler. Start it to begin inference.
+func NewSchemaProfiler(store SchemaProfileStore, cfg SchemaProfilerConfig) *SchemaProfiler {
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = 1
+	}
+	if cfg.MaxDepth <= 0 {
+		cfg.MaxDepth = 8
+	}
+	if cfg.MaxPaths <= 0 {
+		cfg.MaxPaths = 500
+	}
+	return &SchemaProfiler{
+		store:   store,
+		cfg:     cfg,
+		logger:  logging.Component("SchemaProfiler"),
+		queue:   make(chan schemaSample, schemaQueueSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		pending: make(map[schemaKey]*repository.SchemaProfile),
+		alerted: make(map[string]bool),
+	}
+}
+
+// Observe is a flush side effect: it samples 1 in SampleRate payloads onto
+// the inference queue. It never blocks the flush and never fails.
+func (p *SchemaProfiler) Observe(ctx context.Context, items []repository.InventoryItem) error {
+	for _, item := range items {
+		if p.seen.Add(1)%int64(p.cfg.SampleRate) != 0 {
+			continue
+		}
+		version := item.ClientVersion
+		if version == "" {
+			version = "unknown"
+		}
+		sample := schemaSample{day: item.SyncedAt.UTC().Format(schemaDayFormat), version: version, raw: item.RawJSON}
+		select {
+		case p.queue <- sample:
+			p.sampled.Add(1)
+		default:
+			p.dropped.Add(1)
+		}
+	}
+	return nil
+}
+
+// Start runs inference and persists profiles every interval until Close.
+func (p *SchemaProfiler) Start(interval time.Duration) {
+	lifecycle.Go("schema.profiler", func() {
+		p.loop(interval)
+		close(p.done) // Not deferred: a panicking loop is restarted
+	})
+	p.logger.Info("Started", "sample_rate", p.cfg.SampleRate, "max_depth", p.cfg.MaxDepth,
+		"max_paths", p.cfg.MaxPaths, "save_interval", interval)
+}
+
+// loop infers queued samples and persists profiles every interval.
+func (p *SchemaProfiler) loop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case sample := <-p.queue:
+			p.infer(sample)
+		case <-ticker.C:
+			lifecycle.Touch("schema.profiler")
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			p.persist(ctx)
+			p.checkDrift(ctx)
+			cancel()
+		case <-p.stop:
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			p.persist(ctx)
+			cancel()
+			return
+		}
+	}
+}
+
+// Close stops inference and saves what was profiled so far.
+func (p *SchemaProfiler) Close() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+		<-p.done
+	})
+}
+
+// infer adds one payload to its day's profile. Each path counts once per
+// payload; arrays are collapsed into "*".
+func (p *SchemaProfiler) infer(sample schemaSample) {
+	var doc interface{}
+	if err := json.Unmarshal(sample.raw, &doc); err != nil {
+		p.invalid.Add(1)
+		return
+	}
+
+	paths := make(map[string]int)
+	p.walk(doc, "", 0, paths)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := schemaKey{day: sample.day, version: sample.version}
+	profile := p.pending[key]
+	if profile == nil {
+		profile = &repository.SchemaProfile{Day: sample.day, ClientVersion: sample.version, Paths: make(map[string]repository.SchemaPathStat)}
+		p.pending[key] = profile
+	}
+	profile.Samples++
+	for path, types := range paths {
+		stat, ok := profile.Paths[path]
+		if !ok && len(profile.Paths) >= p.cfg.MaxPaths {
+			p.truncated.Add(1)
+			continue
+		}
+		stat.Types |= types
+		stat.Seen++
+		profile.Paths[path] = stat
+	}
+}
+
+// walk records the type of every path under v, down to MaxDepth and at
+// most MaxPaths paths.
+func (p *SchemaProfiler) walk(v interface{}, path string, depth int, paths map[string]int) {
+	if path != "" {
+		if _, ok := paths[path]; !ok && len(paths) >= p.cfg.MaxPaths {
+			return
+		}
+		paths[path] |= schemaType(v)
+	}
+	if depth >= p.cfg.MaxDepth {
+		return
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			p.walk(child, path+"/"+escapePathSegment(k), depth+1, paths)
+		}
+	case []interface{}:
+		for _, child := range t {
+			p.walk(child, path+"/*", depth+1, paths)
+		}
+	}
+}
+
+// escapePathSegment escapes a member name as in a JSON Pointer.
+func escapePathSegment(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+// schemaType returns the type bit of a decoded JSON value.
+func schemaType(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return repository.SchemaTypeNull
+	case bool:
+		return repository.SchemaTypeBool
+	case float64:
+		return repository.SchemaTypeNumber
+	case string:
+		return repository.SchemaTypeString
+	case []interface{}:
+		return repository.SchemaTypeArray
+	default:
+		return repository.SchemaTypeObject
+	}
+}
+
+// persist merges the pending profiles into the store. Profiles that fail
+// to save are kept and retried next time.
+func (p *SchemaProfiler) persist(ctx context.Context) {
+	p.mu.Lock()
+	pending := p.pending
+	p.pending = make(map[schemaKey]*repository.SchemaProfile)
+	p.mu.Unlock()
+
+	for key, profile := range pending {
+		if err := p.store.MergeSchemaProfile(ctx, profile); err != nil {
+			p.logger.ErrorContext(ctx, "Failed to save profile", "day", key.day, "version", key.version, "error", err)
+			p.mu.Lock()
+			if newer := p.pending[key]; newer != nil {
+				mergeSchemaProfiles(profile, newer)
+			}
+			p.pending[key] = profile
+			p.mu.Unlock()
+		}
+	}
+	p.lastSaved.Store(time.Now().Unix())
+}
+
+// mergeSchemaProfiles adds src into dst.
+func mergeSchemaProfiles(dst, src *repository.SchemaProfile) {
+	dst.Samples += src.Samples
+	for path, stat := range src.Paths {
+		merged := dst.Paths[path]
+		merged.Types |= stat.Types
+		merged.Seen += stat.Seen
+		dst.Paths[path] = merged
+	}
+}
+
+// checkDrift alerts once per day for every path that was ubiquitous
+// yesterday and is present in fewer than PresenceAlert of today's samples.
+func (p *SchemaProfiler) checkDrift(ctx context.Context) {
+	if p.cfg.PresenceAlert <= 0 {
+		return
+	}
+	now := time.Now().UTC()
+	today := now.Format(schemaDayFormat)
+	yesterday := now.AddDate(0, 0, -1).Format(schemaDayFormat)
+
+	current, err := p.store.GetSchemaProfile(ctx, today, "")
+	if err != nil || current.Samples < schemaAlertMinSamples {
+		return
+	}
+	previous, err := p.store.GetSchemaProfile(ctx, yesterday, "")
+	if err != nil || previous.Samples < schemaAlertMinSamples {
+		return
+	}
+
+	for path := range previous.Paths {
+		before := previous.Presence(path)
+		after := current.Presence(path)
+		if before < schemaUbiquitous || after >= p.cfg.PresenceAlert {
+			continue
+		}
+		p.mu.Lock()
+		key := today + path
+		already := p.alerted[key]
+		p.alerted[key] = true
+		p.mu.Unlock()
+		if !already {
+			p.logger.ErrorContext(ctx, "ALERT: path became rare - renamed or dropped by a client release?", "path", path,
+				"presence_yesterday", before, "presence_today", after, "versions_today", current.Versions)
+		}
+	}
+
+	// Forget alerts of earlier days
+	p.mu.Lock()
+	for key := range p.alerted {
+		if !strings.HasPrefix(key, today) {
+			delete(p.alerted, key)
+		}
+	}
+	p.mu.Unlock()
+}
+
+// SchemaPathChange is one path in a schema report.
+type SchemaPathChange struct {
+	Path         string   `json:"path"`
+	FromPresence float64  `json:"from_presence_percent"`
+	ToPresence   float64  `json:"to_presence_percent"`
+	FromTypes    []string `json:"from_types,omitempty"`
+	ToTypes      []string `json:"to_types,omitempty"`
+}
+
+// SchemaReport compares the profiles of two days.
+type SchemaReport struct {
+	From            SchemaReportDay    `json:"from"`
+	To              SchemaReportDay    `json:"to"`
+	Appeared        []SchemaPathChange `json:"appeared"`
+	Disappeared     []SchemaPathChange `json:"disappeared"`
+	TypeChanged     []SchemaPathChange `json:"type_changed"`
+	PresenceDropped []SchemaPathChange `json:"presence_dropped"`
+}
+
+// SchemaReportDay summarizes one side of a report.
+type SchemaReportDay struct {
+	Day      string           `json:"day"`
+	Samples  int64            `json:"samples"`
+	Paths    int              `json:"paths"`
+	Versions map[string]int64 `json:"client_versions"`
+}
+
+// Report compares the stored profiles of two days (YYYY-MM-DD). An empty
+// client version combines every version.
+func (p *SchemaProfiler) Report(ctx context.Context, from, to, clientVersion string) (*SchemaReport, error) {
+	for _, day := range []string{from, to} {
+		if _, err := time.Parse(schemaDayFormat, day); err != nil {
+			return nil, fmt.Errorf("invalid day %q, expected YYYY-MM-DD", day)
+		}
+	}
+
+	a, err := p.store.GetSchemaProfile(ctx, from, clientVersion)
+	if err != nil {
+		return nil, err
+	}
+	b, err := p.store.GetSchemaProfile(ctx, to, clientVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SchemaReport{
+		From:            SchemaReportDay{Day: from, Samples: a.Samples, Paths: len(a.Paths), Versions: a.Versions},
+		To:              SchemaReportDay{Day: to, Samples: b.Samples, Paths: len(b.Paths), Versions: b.Versions},
+		Appeared:        []SchemaPathChange{},
+		Disappeared:     []SchemaPathChange{},
+		TypeChanged:     []SchemaPathChange{},
+		PresenceDropped: []SchemaPathChange{},
+	}
+
+	paths := make(map[string]bool, len(a.Paths)+len(b.Paths))
+	for path := range a.Paths {
+		paths[path] = true
+	}
+	for path := range b.Paths {
+		paths[path] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	for _, path := range sorted {
+		before, inA := a.Paths[path]
+		after, inB := b.Paths[path]
+		change := SchemaPathChange{
+			Path:         path,
+			FromPresence: presencePercent(a, path),
+			ToPresence:   presencePercent(b, path),
+		}
+		if inA {
+			change.FromTypes = repository.SchemaTypeNames(before.Types)
+		}
+		if inB {
+			change.ToTypes = repository.SchemaTypeNames(after.Types)
+		}
+
+		switch {
+		case !inA:
+			report.Appeared = append(report.Appeared, change)
+		case !inB:
+			report.Disappeared = append(report.Disappeared, change)
+		case before.Types != after.Types:
+			report.TypeChanged = append(report.TypeChanged, change)
+		case p.cfg.PresenceAlert > 0 && a.Presence(path) >= schemaUbiquitous && b.Presence(path) < p.cfg.PresenceAlert:
+			report.PresenceDropped = append(report.PresenceDropped, change)
+		}
+	}
+	return report, nil
+}
+
+// presencePercent returns a path's presence rounded to 0.1%.
+func presencePercent(p *repository.SchemaProfile, path string) float64 {
+	return float64(int64(p.Presence(path)*1000+0.5)) / 10
+}
+
+// Stats returns sampling counters for admin stats.
+func (p *SchemaProfiler) Stats(ctx context.Context) map[string]interface{} {
+	p.mu.Lock()
+	pending := len(p.pending)
+	p.mu.Unlock()
+
+	stats := map[string]interface{}{
+		"sample_rate":      p.cfg.SampleRate,
+		"sampled":          p.sampled.Load(),
+		"dropped":          p.dropped.Load(),
+		"invalid":          p.invalid.Load(),
+		"paths_truncated":  p.truncated.Load(),
+		"queued":           len(p.queue),
+		"pending_profiles": pending,
+	}
+	if last := p.lastSaved.Load(); last > 0 {
+		stats["last_saved_at"] = time.Unix(last, 0).UTC()
+	}
+	return stats
+}
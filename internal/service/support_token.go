@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// ScopeInventoryRead limits a token to reading one user's inventory.
+	ScopeInventoryRead = "inventory:read"
+	// ScopeInventoryExists limits a caller to asking whether users have
+	// synced (GET /api/v1/inventory/{id}/exists).
+	ScopeInventoryExists = "inventory:exists"
+	// ScopeInventoryWrite lets an API key sync inventories.
+	ScopeInventoryWrite = "inventory:write"
+	// ScopeAdmin lets an API key call the admin API (/api/v1/admin/*).
+	ScopeAdmin = "admin"
+
+	// DefaultSupportTokenTTL and MaxSupportTokenTTL bound support token lifetimes.
+	DefaultSupportTokenTTL = 30 * time.Minute
+	MaxSupportTokenTTL     = 8 * time.Hour
+
+	// supportTokenAccountID indexes support tokens. Key account IDs start at
+	// 1, so the index never mixes with a real account's sessions.
+	supportTokenAccountID int64 = 0
+)
+
+var (
+	// ErrInvalidTokenTTL is returned for a support token TTL out of range.
+	ErrInvalidTokenTTL = errors.New("ttl must be between 1m and 8h")
+)
+
+// SupportToken describes an active support token, without the token itself.
+type SupportToken struct {
+	SessionID    string    `json:"session_id"`
+	TokenHint    string    `json:"token_hint"`
+	RobloxUserID string    `json:"roblox_user_id"`
+	Scope        string    `json:"scope"`
+	IssuedBy     string    `json:"issued_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	LastUsedAt   time.Time `json:"last_used_at,omitempty"`
+}
+
+// GenerateSupportToken mints a read-only token bound to one roblox user, for
+// support staff. It expires after ttl and can't be refreshed.
+func (s *TokenService) GenerateSupportToken(ctx context.Context, robloxUserID, issuedBy string, ttl time.Duration) (string, *TokenData, error) {
+	if ttl < time.Minute || ttl > MaxSupportTokenTTL {
+		return "", nil, ErrInvalidTokenTTL
+	}
+
+	now := time.Now()
+	data := TokenData{
+		KeyAccountID: supportTokenAccountID,
+		RobloxUserID: robloxUserID,
+		Scope:        ScopeInventoryRead,
+		IssuedBy:     issuedBy,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(ttl),
+		LastUsedAt:   now,
+	}
+	token, err := s.newToken(&data)
+	if err != nil {
+		return "", nil, err
+	}
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to serialize token data: %w", err)
+	}
+
+	// The index lives as long as the longest possible support token, so a
+	// short-lived token never expires the index under a longer one; the
+	// token itself then gets its own TTL
+	if err := s.store.SaveToken(ctx, token, jsonData, supportTokenAccountID, data.SessionID, MaxSupportTokenTTL); err != nil {
+		return "", nil, fmt.Errorf("failed to store token: %w", err)
+	}
+	if err := s.store.SetToken(ctx, token, jsonData, ttl); err != nil {
+		s.deleteSessions(ctx, supportTokenAccountID, map[string]string{data.SessionID: token})
+		return "", nil, fmt.Errorf("failed to store token: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "Support token issued", "session_id", data.SessionID, "issued_by", issuedBy,
+		"roblox_user_id", robloxUserID, "expires", data.ExpiresAt)
+	return token, &data, nil
+}
+
+// ListSupportTokens returns the active support tokens, oldest first.
+func (s *TokenService) ListSupportTokens(ctx context.Context) ([]SupportToken, error) {
+	index, err := s.store.SessionIndex(ctx, supportTokenAccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list support tokens: %w", err)
+	}
+
+	tokens := make([]SupportToken, 0, len(index))
+	for sessionID, token := range index {
+		jsonData, err := s.store.GetToken(ctx, token)
+		if err == errTokenNotFound {
+			s.deleteSessions(ctx, supportTokenAccountID, map[string]string{sessionID: token})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get support token: %w", err)
+		}
+
+		var data TokenData
+		if err := json.Unmarshal(jsonData, &data); err != nil || !data.Restricted() {
+			continue
+		}
+		tokens = append(tokens, SupportToken{
+			SessionID:    sessionID,
+			TokenHint:    tokenHint(token),
+			RobloxUserID: data.RobloxUserID,
+			Scope:        data.Scope,
+			IssuedBy:     data.IssuedBy,
+			CreatedAt:    data.CreatedAt,
+			ExpiresAt:    data.ExpiresAt,
+			LastUsedAt:   data.LastUsedAt,
+		})
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+// RevokeSupportToken revokes a support token by session ID.
+// Returns false if there is no such token.
+func (s *TokenService) RevokeSupportToken(ctx context.Context, sessionID string) (bool, error) {
+	return s.RevokeSession(ctx, supportTokenAccountID, sessionID)
+}
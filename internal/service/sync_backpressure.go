@@ -0,0 +1,36 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBufferFull is wrapped by the *BufferFullError of a sync refused while
+// the write buffer is too deep.
+var ErrBufferFull = errors.New("inventory buffer full")
+
+// BufferFullError refuses a sync while more entries wait to be flushed than
+// the buffer's MaxPending, so a broken flush path shows up as refused
+// syncs rather than as entries the stale cleanup deletes.
+type BufferFullError struct {
+	Pending    int64
+	MaxPending int64
+	RetryAfter time.Duration // One flush interval
+}
+
+func (e *BufferFullError) Error() string {
+	return fmt.Sprintf("%s (%d of %d pending), retry in %s", ErrBufferFull, e.Pending, e.MaxPending, e.RetryAfter)
+}
+
+func (e *BufferFullError) Unwrap() error { return ErrBufferFull }
+
+// checkBackpressure refuses a buffered write with a *BufferFullError while
+// the buffer is full.
+func (s *InventoryService) checkBackpressure() error {
+	if !s.buffer.Full() {
+		return nil
+	}
+	pending, max := s.buffer.Backpressure()
+	return &BufferFullError{Pending: pending, MaxPending: max, RetryAfter: s.buffer.FlushInterval()}
+}
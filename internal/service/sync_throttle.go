@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"vinzhub-rest-api/internal/cache"
+	"vinzhub-rest-api/internal/metrics"
+)
+
+// ScopeSyncUnthrottled marks API keys whose syncs skip the per-user sync
+// throttle, e.g. for migration or support tooling. Such keys may only sync.
+const ScopeSyncUnthrottled = "inventory:sync:unthrottled"
+
+// syncThrottled counts syncs refused for coming too soon after the last.
+var syncThrottled = metrics.NewCounterVec("vinzhub_sync_throttled_total",
+	"Inventory syncs refused by the per-user throttle, by section.", "section")
+
+// ErrTooFrequent is wrapped by the *TooFrequentError of a throttled sync.
+var ErrTooFrequent = errors.New("syncs too frequent")
+
+// TooFrequentError refuses a sync that came too soon after the last sync of
+// the same section.
+type TooFrequentError struct {
+	Interval   time.Duration // The throttle's interval
+	RetryAfter time.Duration // Until the next sync is allowed
+}
+
+func (e *TooFrequentError) Error() string {
+	return fmt.Sprintf("%s, retry in %s", ErrTooFrequent, e.RetryAfter.Round(time.Millisecond))
+}
+
+func (e *TooFrequentError) Unwrap() error { return ErrTooFrequent }
+
+// SetSyncThrottle allows one sync per user and section every interval,
+// tracked in throttle (Redis when shared between instances). Each section
+// is throttled separately so multi-section clients can sync them together.
+// interval <= 0 or a nil throttle disables throttling.
+func (s *InventoryService) SetSyncThrottle(throttle cache.Throttle, interval time.Duration) {
+	if interval <= 0 {
+		throttle = nil
+	}
+	s.throttle = throttle
+	s.throttleEvery = interval
+}
+
+// checkThrottle refuses req with a *TooFrequentError when its section was
+// synced less than the throttle interval ago. A failing throttle store lets
+// syncs through.
+func (s *InventoryService) checkThrottle(ctx context.Context, req SyncRequest, section string) error {
+	if s.throttle == nil || req.Unthrottled {
+		return nil
+	}
+	ok, wait, err := s.throttle.Allow(ctx, "sync:"+req.RobloxUserID+":"+section, s.throttleEvery)
+	if err != nil {
+		s.logger.WarnContext(ctx, "Sync throttle unavailable, not throttling", "error", err)
+		return nil
+	}
+	if !ok {
+		syncThrottled.Inc(section)
+		return &TooFrequentError{Interval: s.throttleEvery, RetryAfter: wait}
+	}
+	return nil
+}
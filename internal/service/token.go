@@ -2,25 +2,38 @@ package service
 
 import (
 	"context"
-	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"vinzhub-rest-api/internal/cache"
+	"vinzhub-rest-api/internal/logging"
 )
 
 const (
 	// TokenPrefix is the prefix for all session tokens
 	TokenPrefix = "vht_"
 	
-	// TokenTTL is the default token lifetime (1 hour)
+	// TokenTTL is the default token lifetime (1 hour, see SetExpiry)
 	TokenTTL = 1 * time.Hour
 	
 	// TokenRedisKeyPrefix is the Redis key prefix for tokens
 	TokenRedisKeyPrefix = "vinzhub:token:"
+
+	// SessionIndexKeyPrefix is the Redis key prefix for the per-account
+	// session index (hash of session ID -> token)
+	SessionIndexKeyPrefix = "vinzhub:sessions:"
+
+	// LastUsedInterval throttles last-used tracking to one write per session
+	// per interval, so validation doesn't add a Redis write to every request
+	LastUsedInterval = 1 * time.Minute
 )
 
 // TokenData contains the data stored with a session token.
@@ -30,35 +43,141 @@ type TokenData struct {
 	RobloxUserID   string    `json:"roblox_user_id"`
 	RobloxUsername string    `json:"roblox_username"`
 	HWID           string    `json:"hwid"`
+	SessionID      string    `json:"session_id,omitempty"`
+	ClientVersion  string    `json:"client_version,omitempty"`
 	CreatedAt      time.Time `json:"created_at"`
 	ExpiresAt      time.Time `json:"expires_at"`
+	LastUsedAt     time.Time `json:"last_used_at,omitempty"`
+	Scope          string    `json:"scope,omitempty"`     // Empty for full session tokens
+	IssuedBy       string    `json:"issued_by,omitempty"` // Admin who minted a support token
+	FamilyID       string    `json:"family_id,omitempty"` // Session family of rotated refresh tokens
+}
+
+// Restricted reports whether the token is limited to a scope (support tokens).
+func (d *TokenData) Restricted() bool {
+	return d.Scope != ""
+}
+
+// Session describes one active token of a key account, without the token itself.
+type Session struct {
+	SessionID     string    `json:"session_id"`
+	TokenHint     string    `json:"token_hint"`
+	HWID          string    `json:"hwid,omitempty"`
+	ClientVersion string    `json:"client_version,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	LastUsedAt    time.Time `json:"last_used_at,omitempty"`
+	Current       bool      `json:"current,omitempty"`
+}
+
+// SessionIDForToken derives the public session ID of a token.
+// It identifies a session without revealing anything usable as a credential.
+func SessionIDForToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// tokenHint returns a truncated token safe to show to users. Signed tokens
+// all start with the same header, so their hint is taken from the end.
+func tokenHint(token string) string {
+	if len(token) <= len(TokenPrefix)+6 {
+		return token
+	}
+	if strings.Contains(token, ".") {
+		return TokenPrefix + "…" + token[len(token)-6:]
+	}
+	return token[:len(TokenPrefix)+6] + "…"
+}
+
+// sessionIndexKey returns the session index key for a key account.
+func sessionIndexKey(keyAccountID int64) string {
+	return fmt.Sprintf("%s%d", SessionIndexKeyPrefix, keyAccountID)
 }
 
 // TokenService handles session token generation and validation.
 type TokenService struct {
-	redis *redis.Client
+	store TokenStore
+	cache *tokenCache            // nil when validation isn't cached
+	bus   *cache.InvalidationBus // nil in single-instance deployments
+	jwt   *jwtTokens             // nil unless tokens are signed (UseJWT)
+
+	ttl        time.Duration
+	sliding    bool // Extend tokens on use (SetExpiry)
+	refreshTTL time.Duration
+
+	logger *slog.Logger
 }
 
-// NewTokenService creates a new token service.
+// NewTokenService creates a new token service backed by Redis.
 func NewTokenService(redisClient *redis.Client) *TokenService {
+	return NewTokenServiceWithStore(NewRedisTokenStore(redisClient))
+}
+
+// NewTokenServiceWithStore creates a token service on any token store.
+func NewTokenServiceWithStore(store TokenStore) *TokenService {
 	return &TokenService{
-		redis: redisClient,
+		store:      store,
+		ttl:        TokenTTL,
+		refreshTTL: RefreshTokenTTL,
+		logger:     logging.Component("TokenService"),
 	}
 }
 
-// GenerateToken creates a new session token and stores it in Redis.
-func (s *TokenService) GenerateToken(ctx context.Context, data TokenData) (string, error) {
-	// Generate random token
-	tokenBytes := make([]byte, 32)
-	if _, err := rand.Read(tokenBytes); err != nil {
-		return "", fmt.Errorf("failed to generate token: %w", err)
+// SetExpiry sets the lifetime of new tokens (TokenTTL when <= 0). With
+// sliding, a token used within its lifetime is extended to ttl from its
+// last use; the extension is written along with last-used, so at most
+// once per LastUsedInterval. Support tokens and signed tokens (UseJWT)
+// keep their expiry.
+func (s *TokenService) SetExpiry(ttl time.Duration, sliding bool) {
+	if ttl <= 0 {
+		ttl = TokenTTL
 	}
-	
-	token := TokenPrefix + hex.EncodeToString(tokenBytes)
-	
+	s.ttl = ttl
+	s.sliding = sliding
+}
+
+// TTL returns the lifetime of new session tokens.
+func (s *TokenService) TTL() time.Duration {
+	return s.ttl
+}
+
+// SetValidationCache caches validation results in memory for cfg.TTL, so
+// bursts of requests don't each cost a store round trip. Revocations through
+// this service take effect at once; keep the TTL short, as revocations on
+// other instances are only seen once it runs out unless an invalidation bus
+// is set.
+func (s *TokenService) SetValidationCache(cfg TokenCacheConfig) {
+	if cfg.TTL <= 0 {
+		s.cache = nil
+		return
+	}
+	s.cache = newTokenCache(cfg)
+}
+
+// Stats returns validation cache stats for admin stats.
+func (s *TokenService) Stats(ctx context.Context) map[string]interface{} {
+	stats := map[string]interface{}{"enabled": false}
+	if s.cache != nil {
+		stats = s.cache.stats()
+		stats["enabled"] = true
+	}
+	if s.jwt != nil {
+		stats["jwt"] = s.jwt.stats()
+	}
+	return stats
+}
+
+// GenerateToken creates a new session token and stores it.
+func (s *TokenService) GenerateToken(ctx context.Context, data TokenData) (string, error) {
 	// Set timestamps
 	data.CreatedAt = time.Now()
-	data.ExpiresAt = data.CreatedAt.Add(TokenTTL)
+	data.ExpiresAt = data.CreatedAt.Add(s.ttl)
+	data.LastUsedAt = data.CreatedAt
+
+	token, err := s.newToken(&data)
+	if err != nil {
+		return "", err
+	}
 	
 	// Serialize token data
 	jsonData, err := json.Marshal(data)
@@ -66,15 +185,14 @@ func (s *TokenService) GenerateToken(ctx context.Context, data TokenData) (strin
 		return "", fmt.Errorf("failed to serialize token data: %w", err)
 	}
 	
-	// Store in Redis with TTL
-	key := TokenRedisKeyPrefix + token
-	err = s.redis.Set(ctx, key, jsonData, TokenTTL).Err()
-	if err != nil {
+	// Store with TTL, and index it under the key account so the
+	// account's sessions can be listed and revoked
+	if err := s.store.SaveToken(ctx, token, jsonData, data.KeyAccountID, data.SessionID, s.ttl); err != nil {
 		return "", fmt.Errorf("failed to store token: %w", err)
 	}
 	
-	log.Printf("[TokenService] Generated token for key_account_id=%d, roblox_id=%s, expires=%v",
-		data.KeyAccountID, data.RobloxUserID, data.ExpiresAt)
+	s.logger.InfoContext(ctx, "Generated token", "key_account_id", data.KeyAccountID,
+		"roblox_user_id", data.RobloxUserID, "expires", data.ExpiresAt)
 	
 	return token, nil
 }
@@ -90,10 +208,29 @@ func (s *TokenService) ValidateToken(ctx context.Context, token string) (*TokenD
 		return nil, fmt.Errorf("invalid token format")
 	}
 	
-	// Get from Redis
-	key := TokenRedisKeyPrefix + token
-	jsonData, err := s.redis.Get(ctx, key).Bytes()
-	if err == redis.Nil {
+	now := time.Now()
+	if s.jwt != nil {
+		return s.validateJWT(ctx, token, now)
+	}
+	if s.cache != nil {
+		// A hit due a last-used write goes to the store instead: writing
+		// back a cached copy could resurrect a token revoked elsewhere
+		if data, found := s.cache.get(token, now); found {
+			if data == nil {
+				return nil, fmt.Errorf("token not found or expired")
+			}
+			if now.Sub(data.LastUsedAt) < LastUsedInterval {
+				return data, nil
+			}
+		}
+	}
+
+	// Get from the store
+	jsonData, err := s.store.GetToken(ctx, token)
+	if err == errTokenNotFound {
+		if s.cache != nil {
+			s.cache.put(token, nil, now)
+		}
 		return nil, fmt.Errorf("token not found or expired")
 	}
 	if err != nil {
@@ -107,38 +244,198 @@ func (s *TokenService) ValidateToken(ctx context.Context, token string) (*TokenD
 	}
 	
 	// Check expiry (double-check even though Redis TTL should handle it)
-	if time.Now().After(data.ExpiresAt) {
-		s.redis.Del(ctx, key)
+	if now.After(data.ExpiresAt) {
+		s.deleteToken(ctx, token)
 		return nil, fmt.Errorf("token expired")
 	}
+
+	// Sampled last-used tracking: at most one write per LastUsedInterval,
+	// extending sliding tokens along the way
+	if now.Sub(data.LastUsedAt) >= LastUsedInterval {
+		if s.sliding && !data.Restricted() {
+			data.ExpiresAt = now.Add(s.ttl)
+		}
+		if !s.touch(ctx, token, data, now) {
+			// Revoked since it was read above
+			if s.cache != nil {
+				s.cache.put(token, nil, now)
+			}
+			return nil, fmt.Errorf("token not found or expired")
+		}
+		data.LastUsedAt = now
+	}
+	if s.cache != nil {
+		s.cache.put(token, &data, now)
+	}
 	
 	return &data, nil
 }
 
-// RevokeToken deletes a token from Redis.
-func (s *TokenService) RevokeToken(ctx context.Context, token string) error {
-	key := TokenRedisKeyPrefix + token
-	return s.redis.Del(ctx, key).Err()
+// touch records last use of a token, and its expiry if sliding extended it.
+// The token is only rewritten if it still exists: a plain write would bring
+// back a token revoked since ValidateToken read it. touch returns false only
+// when the token is gone. Other failures are ignored - last-used is
+// informational only, and a sliding token missing one extension still has
+// until its previous expiry.
+func (s *TokenService) touch(ctx context.Context, token string, data TokenData, now time.Time) bool {
+	data.LastUsedAt = now
+	ttl := data.ExpiresAt.Sub(now)
+	if ttl <= 0 {
+		return true
+	}
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return true
+	}
+	sessionID := ""
+	if s.sliding && !data.Restricted() {
+		// Keep the session index alive as long as the token
+		sessionID = data.SessionID
+	}
+	exists, err := s.store.TouchToken(ctx, token, jsonData, data.KeyAccountID, sessionID, ttl)
+	return exists || err != nil
 }
 
-// RefreshToken extends the TTL of an existing token.
-func (s *TokenService) RefreshToken(ctx context.Context, token string) error {
-	key := TokenRedisKeyPrefix + token
-	
-	// Get existing data
-	jsonData, err := s.redis.Get(ctx, key).Bytes()
-	if err != nil {
-		return fmt.Errorf("token not found: %w", err)
+// RevokeToken deletes a token and drops it from its account's session index.
+// The refresh tokens of its session family are revoked with it.
+func (s *TokenService) RevokeToken(ctx context.Context, token string) error {
+	if err := s.revokeFamilies(ctx, token); err != nil {
+		return err
 	}
-	
-	// Update expiry
+
+	// Look up the owning account so the index entry can be removed too
 	var data TokenData
-	if err := json.Unmarshal(jsonData, &data); err != nil {
+	if jsonData, err := s.store.GetToken(ctx, token); err == nil && json.Unmarshal(jsonData, &data) == nil {
+		return s.deleteSessions(ctx, data.KeyAccountID, map[string]string{SessionIDForToken(token): token})
+	}
+
+	return s.deleteToken(ctx, token)
+}
+
+// deleteToken deletes a token and drops it from the validation cache.
+func (s *TokenService) deleteToken(ctx context.Context, token string) error {
+	if s.cache != nil {
+		defer s.cache.forget(token)
+	}
+	if err := s.revokeJWTs(ctx, token); err != nil {
 		return err
 	}
-	
-	data.ExpiresAt = time.Now().Add(TokenTTL)
-	
-	newJSON, _ := json.Marshal(data)
-	return s.redis.Set(ctx, key, newJSON, TokenTTL).Err()
+	if err := s.store.DeleteToken(ctx, token); err != nil {
+		return err
+	}
+	s.publishRevoked(ctx, token)
+	return nil
+}
+
+// deleteSessions deletes sessions (session ID -> token) and drops their
+// tokens from the validation cache, here and on other instances. Every
+// revocation goes through here.
+func (s *TokenService) deleteSessions(ctx context.Context, keyAccountID int64, sessions map[string]string) error {
+	tokens := make([]string, 0, len(sessions))
+	for _, token := range sessions {
+		tokens = append(tokens, token)
+	}
+	if s.cache != nil {
+		// Forget after the delete too, in case a validation re-cached the
+		// token while it was running
+		s.cache.forget(tokens...)
+		defer s.cache.forget(tokens...)
+	}
+	if err := s.revokeJWTs(ctx, tokens...); err != nil {
+		return err
+	}
+	if err := s.store.DeleteSessions(ctx, keyAccountID, sessions); err != nil {
+		return err
+	}
+	s.publishRevoked(ctx, tokens...)
+	return nil
+}
+
+// ListSessions returns the active sessions of a key account, oldest first.
+// Index entries whose token has already expired are pruned along the way.
+func (s *TokenService) ListSessions(ctx context.Context, keyAccountID int64) ([]Session, error) {
+	index, err := s.store.SessionIndex(ctx, keyAccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	sessions := make([]Session, 0, len(index))
+	for sessionID, token := range index {
+		jsonData, err := s.store.GetToken(ctx, token)
+		if err == errTokenNotFound {
+			s.deleteSessions(ctx, keyAccountID, map[string]string{sessionID: token})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get session: %w", err)
+		}
+
+		var data TokenData
+		if err := json.Unmarshal(jsonData, &data); err != nil {
+			continue
+		}
+		sessions = append(sessions, Session{
+			SessionID:     sessionID,
+			TokenHint:     tokenHint(token),
+			HWID:          data.HWID,
+			ClientVersion: data.ClientVersion,
+			CreatedAt:     data.CreatedAt,
+			ExpiresAt:     data.ExpiresAt,
+			LastUsedAt:    data.LastUsedAt,
+		})
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].CreatedAt.Equal(sessions[j].CreatedAt) {
+			return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+		}
+		return sessions[i].SessionID < sessions[j].SessionID
+	})
+	return sessions, nil
+}
+
+// RevokeSession revokes one session of a key account by session ID.
+// Returns false if the account has no such session.
+func (s *TokenService) RevokeSession(ctx context.Context, keyAccountID int64, sessionID string) (bool, error) {
+	index, err := s.store.SessionIndex(ctx, keyAccountID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get session: %w", err)
+	}
+	token, ok := index[sessionID]
+	if !ok {
+		return false, nil
+	}
+
+	if err := s.revokeFamilies(ctx, token); err != nil {
+		return false, err
+	}
+	if err := s.deleteSessions(ctx, keyAccountID, map[string]string{sessionID: token}); err != nil {
+		return false, fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return true, nil
+}
+
+// RevokeOtherSessions revokes every session of a key account except keepSessionID.
+// Returns the number of sessions revoked.
+func (s *TokenService) RevokeOtherSessions(ctx context.Context, keyAccountID int64, keepSessionID string) (int, error) {
+	index, err := s.store.SessionIndex(ctx, keyAccountID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	delete(index, keepSessionID)
+	if len(index) == 0 {
+		return 0, nil
+	}
+	tokens := make([]string, 0, len(index))
+	for _, token := range index {
+		tokens = append(tokens, token)
+	}
+	if err := s.revokeFamilies(ctx, tokens...); err != nil {
+		return 0, err
+	}
+	if err := s.deleteSessions(ctx, keyAccountID, index); err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return len(index), nil
 }
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"vinzhub-rest-api/internal/metrics"
+)
+
+// tokenCacheLookups counts validation cache lookups by result (hit,
+// negative_hit, miss); the hit rate is hits over the total.
+var tokenCacheLookups = metrics.NewCounterVec("vinzhub_token_cache_lookups_total",
+	"Token validation cache lookups by result.", "result")
+
+// TokenCacheConfig bounds the token validation cache.
+type TokenCacheConfig struct {
+	TTL         time.Duration // How long a valid token is served from memory
+	NegativeTTL time.Duration // How long an unknown token is remembered (0 = never)
+	MaxEntries  int
+}
+
+// tokenCache is an LRU of validation results keyed by token hash, so a
+// burst of requests with one token costs one store lookup. Entries never
+// outlive the token: a valid one expires at the earlier of TTL and the
+// token's own expiry.
+type tokenCache struct {
+	cfg TokenCacheConfig
+
+	mu      sync.Mutex
+	entries map[[32]byte]*list.Element
+	lru     *list.List // Front is most recently used
+}
+
+type tokenCacheEntry struct {
+	key     [32]byte
+	data    *TokenData // nil for a negative entry
+	expires time.Time
+}
+
+func newTokenCache(cfg TokenCacheConfig) *tokenCache {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 10000
+	}
+	return &tokenCache{
+		cfg:     cfg,
+		entries: make(map[[32]byte]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// get returns a cached result: found reports whether the cache has an
+// answer, and data is nil when the answer is "not a valid token".
+func (c *tokenCache) get(token string, now time.Time) (data *TokenData, found bool) {
+	key := sha256.Sum256([]byte(token))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		tokenCacheLookups.Inc("miss")
+		return nil, false
+	}
+	entry := el.Value.(*tokenCacheEntry)
+	if !now.Before(entry.expires) {
+		c.remove(el)
+		tokenCacheLookups.Inc("miss")
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	if entry.data == nil {
+		tokenCacheLookups.Inc("negative_hit")
+		return nil, true
+	}
+	tokenCacheLookups.Inc("hit")
+	copied := *entry.data
+	return &copied, true
+}
+
+// put caches a validation result; data nil caches a negative one.
+func (c *tokenCache) put(token string, data *TokenData, now time.Time) {
+	expires := now.Add(c.cfg.TTL)
+	if data == nil {
+		if c.cfg.NegativeTTL <= 0 {
+			return
+		}
+		expires = now.Add(c.cfg.NegativeTTL)
+	} else {
+		if c.cfg.TTL <= 0 {
+			return
+		}
+		if data.ExpiresAt.Before(expires) {
+			expires = data.ExpiresAt
+		}
+		copied := *data
+		data = &copied
+	}
+
+	key := sha256.Sum256([]byte(token))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*tokenCacheEntry)
+		entry.data, entry.expires = data, expires
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&tokenCacheEntry{key: key, data: data, expires: expires})
+	for c.lru.Len() > c.cfg.MaxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// forget drops a token, after it was revoked or changed.
+func (c *tokenCache) forget(tokens ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, token := range tokens {
+		if el, ok := c.entries[sha256.Sum256([]byte(token))]; ok {
+			c.remove(el)
+		}
+	}
+}
+
+// forgetPrefix drops the tokens whose hash starts with the hex prefix, for
+// revocations made on other instances.
+func (c *tokenCache) forgetPrefix(prefix string) {
+	want, err := hex.DecodeString(prefix)
+	if err != nil || len(want) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, el := range c.entries {
+		if bytes.HasPrefix(key[:], want) {
+			c.remove(el)
+		}
+	}
+}
+
+// clear drops every entry.
+func (c *tokenCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[[32]byte]*list.Element)
+	c.lru.Init()
+}
+
+// remove drops an element. Callers hold mu.
+func (c *tokenCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*tokenCacheEntry).key)
+}
+
+// stats returns the cache size for admin stats.
+func (c *tokenCache) stats() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return map[string]interface{}{
+		"entries":         c.lru.Len(),
+		"max_entries":     c.cfg.MaxEntries,
+		"ttl_ms":          c.cfg.TTL.Milliseconds(),
+		"negative_ttl_ms": c.cfg.NegativeTTL.Milliseconds(),
+	}
+}
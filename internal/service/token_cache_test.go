@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenCacheEntryLifetime(t *testing.T) {
+	now := time.Now()
+	c := newTokenCache(TokenCacheConfig{TTL: 30 * time.Second, NegativeTTL: 5 * time.Second})
+
+	c.put("vht_long", &TokenData{RobloxUserID: "100", ExpiresAt: now.Add(time.Hour)}, now)
+	c.put("vht_short", &TokenData{RobloxUserID: "200", ExpiresAt: now.Add(10 * time.Second)}, now)
+	c.put("vht_unknown", nil, now)
+
+	tests := []struct {
+		token     string
+		at        time.Duration
+		wantFound bool
+		wantData  bool
+	}{
+		{"vht_long", 29 * time.Second, true, true},
+		{"vht_long", 30 * time.Second, false, false}, // TTL ran out
+		{"vht_short", 9 * time.Second, true, true},
+		{"vht_short", 10 * time.Second, false, false}, // Never past the token's expiry
+		{"vht_unknown", 4 * time.Second, true, false},
+		{"vht_unknown", 5 * time.Second, false, false},
+	}
+	for _, tt := range tests {
+		data, found := c.get(tt.token, now.Add(tt.at))
+		if found != tt.wantFound || (data != nil) != tt.wantData {
+			t.Errorf("%s after %v: data %v, found %v; want data %v, found %v", tt.token, tt.at, data, found, tt.wantData, tt.wantFound)
+		}
+	}
+
+	// Callers get a copy they can't change the cached entry through
+	c.put("vht_long", &TokenData{RobloxUserID: "100", ExpiresAt: now.Add(time.Hour)}, now)
+	data, _ := c.get("vht_long", now)
+	data.RobloxUserID = "999"
+	if data, _ := c.get("vht_long", now); data.RobloxUserID != "100" {
+		t.Error("cached entry changed through a returned copy")
+	}
+}
+
+func TestTokenCacheNegativeTTLZero(t *testing.T) {
+	now := time.Now()
+	c := newTokenCache(TokenCacheConfig{TTL: time.Minute})
+	c.put("vht_unknown", nil, now)
+	if _, found := c.get("vht_unknown", now); found {
+		t.Error("negative result cached without a negative TTL")
+	}
+}
+
+func TestTokenCacheBounded(t *testing.T) {
+	now := time.Now()
+	c := newTokenCache(TokenCacheConfig{TTL: time.Minute, MaxEntries: 2})
+	data := &TokenData{ExpiresAt: now.Add(time.Hour)}
+	c.put("vht_a", data, now)
+	c.put("vht_b", data, now)
+	c.get("vht_a", now) // a is now more recently used than b
+	c.put("vht_c", data, now)
+
+	if _, found := c.get("vht_b", now); found {
+		t.Error("least recently used entry was kept")
+	}
+	for _, token := range []string{"vht_a", "vht_c"} {
+		if _, found := c.get(token, now); !found {
+			t.Errorf("%s evicted", token)
+		}
+	}
+	if n := c.stats()["entries"]; n != 2 {
+		t.Errorf("entries = %v, want 2", n)
+	}
+
+	c.forgetPrefix(tokenHashPrefix("vht_a"))
+	if _, found := c.get("vht_a", now); found {
+		t.Error("token kept after an invalidation of its hash prefix")
+	}
+}
+
+// countingStore counts token reads.
+type countingStore struct {
+	*MemoryTokenStore
+	gets atomic.Int64
+}
+
+func (s *countingStore) GetToken(ctx context.Context, token string) ([]byte, error) {
+	s.gets.Add(1)
+	return s.MemoryTokenStore.GetToken(ctx, token)
+}
+
+func TestValidateTokenCached(t *testing.T) {
+	ctx := context.Background()
+	store := &countingStore{MemoryTokenStore: NewMemoryTokenStore()}
+	svc := NewTokenServiceWithStore(store)
+	svc.SetValidationCache(TokenCacheConfig{TTL: time.Minute, NegativeTTL: time.Minute})
+	token, err := svc.GenerateToken(ctx, TokenData{KeyAccountID: 7, RobloxUserID: "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := tokenCacheLookups.Snapshot()
+	for i := 0; i < 5; i++ {
+		if _, err := svc.ValidateToken(ctx, token); err != nil {
+			t.Fatalf("ValidateToken: %v", err)
+		}
+		if _, err := svc.ValidateToken(ctx, "vht_unknown"); err == nil {
+			t.Fatal("unknown token validated")
+		}
+	}
+	if n := store.gets.Load(); n != 2 {
+		t.Errorf("%d store reads for a burst of 10 validations, want 2", n)
+	}
+	after := tokenCacheLookups.Snapshot()
+	if hits := after["hit"] - before["hit"]; hits != 4 {
+		t.Errorf("hits = %d, want 4", hits)
+	}
+	if hits := after["negative_hit"] - before["negative_hit"]; hits != 4 {
+		t.Errorf("negative hits = %d, want 4", hits)
+	}
+}
+
+func TestValidateTokenRevocationBound(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTokenStore()
+	ttl := 50 * time.Millisecond
+	svc := NewTokenServiceWithStore(store)
+	svc.SetValidationCache(TokenCacheConfig{TTL: ttl})
+	// Another instance sharing the store, without an invalidation bus
+	other := NewTokenServiceWithStore(store)
+
+	local, _ := svc.GenerateToken(ctx, TokenData{KeyAccountID: 7, RobloxUserID: "100"})
+	remote, _ := svc.GenerateToken(ctx, TokenData{KeyAccountID: 7, RobloxUserID: "100"})
+	for _, token := range []string{local, remote} {
+		if _, err := svc.ValidateToken(ctx, token); err != nil {
+			t.Fatalf("ValidateToken: %v", err)
+		}
+	}
+
+	// Revoked here: at once
+	if err := svc.RevokeToken(ctx, local); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ValidateToken(ctx, local); err == nil {
+		t.Error("token revoked on this instance still validates")
+	}
+
+	// Revoked elsewhere: within the TTL
+	revokedAt := time.Now()
+	if err := other.RevokeToken(ctx, remote); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		since := time.Since(revokedAt)
+		if _, err := svc.ValidateToken(ctx, remote); err != nil {
+			break
+		}
+		if since > ttl {
+			t.Fatalf("token revoked elsewhere still validates %v later, past the %v cache TTL", since, ttl)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestValidateTokenCacheDoesNotOutliveToken(t *testing.T) {
+	ctx := context.Background()
+	svc := NewTokenServiceWithStore(NewMemoryTokenStore())
+	svc.SetExpiry(50*time.Millisecond, false)
+	svc.SetValidationCache(TokenCacheConfig{TTL: time.Hour})
+	token, _ := svc.GenerateToken(ctx, TokenData{KeyAccountID: 7, RobloxUserID: "100"})
+
+	data, err := svc.ValidateToken(ctx, token)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	time.Sleep(time.Until(data.ExpiresAt))
+	if _, err := svc.ValidateToken(ctx, token); err == nil {
+		t.Error("cached token validated past its expiry")
+	}
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"vinzhub-rest-api/internal/lifecycle"
+)
+
+// Token backends, selected by TOKEN_BACKEND.
+const (
+	TokenBackendRedis = "redis" // Opaque tokens, validated against the store
+	TokenBackendJWT   = "jwt"   // Signed tokens, validated locally
+)
+
+// MinSigningKeyLen is the shortest HS256 signing key accepted.
+const MinSigningKeyLen = 32
+
+// RevokedTokensKey is the Redis sorted set of revoked JWT session IDs,
+// scored by when the token would have expired anyway.
+const RevokedTokensKey = "vinzhub:token:revoked"
+
+// revocationRefreshInterval is how often the revocation set is re-read, so
+// revocations on other instances are seen without an invalidation bus.
+const revocationRefreshInterval = 10 * time.Second
+
+// ErrInvalidSigningKey is returned for a JWT signing key that is too short.
+var ErrInvalidSigningKey = fmt.Errorf("token signing key must be at least %d bytes", MinSigningKeyLen)
+
+// jwtHeader is the only header tokens are signed with or accepted under.
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// jwtClaims are the claims of a signed session token: the token data plus
+// the registered subject, issued-at and expiry claims. The session ID is
+// derived from the token, so it isn't a claim.
+type jwtClaims struct {
+	TokenData
+	Subject  string `json:"sub"`
+	IssuedAt int64  `json:"iat"`
+	Expiry   int64  `json:"exp"`
+}
+
+// jwtTokens signs and verifies session tokens and tracks revoked ones in
+// memory, refreshed from the store.
+type jwtTokens struct {
+	key []byte
+
+	mu      sync.RWMutex
+	revoked map[string]time.Time // session ID -> token expiry
+	refresh chan struct{}
+
+	usesMu sync.Mutex
+	uses   map[string]jwtUse // session ID -> last last-used write here
+}
+
+// jwtUse is the last time this instance wrote a signed session's last use.
+// The claims can't carry it, so it is kept in memory until the token expires.
+type jwtUse struct {
+	at      time.Time
+	expires time.Time
+}
+
+// UseJWT switches the service to signed tokens (HS256 with key): tokens
+// are validated without a store round trip, and revocations are kept in a
+// small set in the store, loaded into memory and re-read every 10s or as
+// soon as the invalidation bus reports one. Sessions are still recorded in
+// the store, so listing and revoking them work as before; last use is
+// written there at most once per LastUsedInterval per instance.
+func (s *TokenService) UseJWT(key []byte) error {
+	if len(key) < MinSigningKeyLen {
+		return ErrInvalidSigningKey
+	}
+	s.jwt = &jwtTokens{
+		key:     key,
+		revoked: make(map[string]time.Time),
+		refresh: make(chan struct{}, 1),
+		uses:    make(map[string]jwtUse),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.loadRevocations(ctx); err != nil {
+		s.logger.Warn("Failed to load revoked tokens", "error", err)
+	}
+	lifecycle.Go("token.revocations", s.refreshRevocations)
+	return nil
+}
+
+// newToken creates a token for data, which has its expiry set, and sets
+// data's session ID: a random opaque token, or a signed one in JWT mode.
+func (s *TokenService) newToken(data *TokenData) (string, error) {
+	var token string
+	if s.jwt != nil {
+		signed, err := s.jwt.sign(*data)
+		if err != nil {
+			return "", fmt.Errorf("failed to sign token: %w", err)
+		}
+		token = TokenPrefix + signed
+	} else {
+		tokenBytes := make([]byte, 32)
+		if _, err := rand.Read(tokenBytes); err != nil {
+			return "", fmt.Errorf("failed to generate token: %w", err)
+		}
+		token = TokenPrefix + hex.EncodeToString(tokenBytes)
+	}
+	data.SessionID = SessionIDForToken(token)
+	return token, nil
+}
+
+// sign returns the signed JWT of data.
+func (j *jwtTokens) sign(data TokenData) (string, error) {
+	data.SessionID = ""
+	data.LastUsedAt = time.Time{}
+	payload, err := json.Marshal(jwtClaims{
+		TokenData: data,
+		Subject:   data.RobloxUserID,
+		IssuedAt:  data.CreatedAt.Unix(),
+		Expiry:    data.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + j.signature(unsigned), nil
+}
+
+// signature returns the encoded HS256 signature of an unsigned token.
+func (j *jwtTokens) signature(unsigned string) string {
+	mac := hmac.New(sha256.New, j.key)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks a signed token (without TokenPrefix) and returns its data.
+// Expiry is checked by the caller.
+func (j *jwtTokens) verify(signed string) (*TokenData, error) {
+	parts := strings.Split(signed, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return nil, fmt.Errorf("invalid token format")
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(j.signature(parts[0]+"."+parts[1]))) {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token format")
+	}
+	var claims jwtClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse token data: %w", err)
+	}
+	data := claims.TokenData
+	data.ExpiresAt = time.Unix(claims.Expiry, 0)
+	return &data, nil
+}
+
+// validateJWT checks a signed token locally: signature, expiry and the
+// in-memory revocation set.
+func (s *TokenService) validateJWT(ctx context.Context, token string, now time.Time) (*TokenData, error) {
+	data, err := s.jwt.verify(token[len(TokenPrefix):])
+	if err != nil {
+		return nil, err
+	}
+	if !now.Before(data.ExpiresAt) {
+		return nil, fmt.Errorf("token expired")
+	}
+	data.SessionID = SessionIDForToken(token)
+	if s.jwt.isRevoked(data.SessionID) {
+		return nil, fmt.Errorf("token not found or expired")
+	}
+	data.LastUsedAt = s.touchJWT(ctx, token, *data, now)
+	return data, nil
+}
+
+// touchJWT records last use of a signed token in the store once
+// LastUsedInterval has passed since this instance last did (or since the
+// token was created), and returns the last use recorded here. As with
+// opaque tokens, a failed write is ignored.
+func (s *TokenService) touchJWT(ctx context.Context, token string, data TokenData, now time.Time) time.Time {
+	j := s.jwt
+	j.usesMu.Lock()
+	last, ok := j.uses[data.SessionID]
+	if !ok {
+		last = jwtUse{at: data.CreatedAt, expires: data.ExpiresAt}
+	}
+	due := now.Sub(last.at) >= LastUsedInterval
+	if due {
+		j.uses[data.SessionID] = jwtUse{at: now, expires: data.ExpiresAt}
+	}
+	j.usesMu.Unlock()
+	if !due {
+		return last.at
+	}
+
+	data.LastUsedAt = now
+	if jsonData, err := json.Marshal(data); err == nil {
+		// Only rewrites a session still in the store; validity comes from
+		// the signature and the revocation set either way
+		s.store.TouchToken(ctx, token, jsonData, data.KeyAccountID, "", data.ExpiresAt.Sub(now))
+	}
+	return now
+}
+
+// pruneUses forgets the last-used writes of expired tokens.
+func (j *jwtTokens) pruneUses(now time.Time) {
+	j.usesMu.Lock()
+	defer j.usesMu.Unlock()
+	for sessionID, use := range j.uses {
+		if !now.Before(use.expires) {
+			delete(j.uses, sessionID)
+		}
+	}
+}
+
+// isRevoked reports whether a session was revoked.
+func (j *jwtTokens) isRevoked(sessionID string) bool {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	_, revoked := j.revoked[sessionID]
+	return revoked
+}
+
+// revokeJWTs records revoked signed tokens in memory and in the store.
+// Entries are kept until the token would have expired; tokens that aren't
+// validly signed can't be validated anyway and are skipped.
+func (s *TokenService) revokeJWTs(ctx context.Context, tokens ...string) error {
+	if s.jwt == nil {
+		return nil
+	}
+	for _, token := range tokens {
+		if !strings.HasPrefix(token, TokenPrefix) {
+			continue
+		}
+		data, err := s.jwt.verify(token[len(TokenPrefix):])
+		if err != nil || !time.Now().Before(data.ExpiresAt) {
+			continue
+		}
+		sessionID := SessionIDForToken(token)
+		s.jwt.mu.Lock()
+		s.jwt.revoked[sessionID] = data.ExpiresAt
+		s.jwt.mu.Unlock()
+		if err := s.store.AddRevoked(ctx, sessionID, data.ExpiresAt); err != nil {
+			return fmt.Errorf("failed to record revocation: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadRevocations replaces the in-memory revocation set with the store's,
+// keeping local entries the store doesn't have yet.
+func (s *TokenService) loadRevocations(ctx context.Context) error {
+	revoked, err := s.store.Revoked(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	s.jwt.mu.Lock()
+	defer s.jwt.mu.Unlock()
+	for sessionID, until := range s.jwt.revoked {
+		if _, ok := revoked[sessionID]; !ok && now.Before(until) {
+			revoked[sessionID] = until
+		}
+	}
+	s.jwt.revoked = revoked
+	return nil
+}
+
+// refreshRevocations re-reads the revocation set periodically, and when
+// another instance reports a revocation.
+func (s *TokenService) refreshRevocations() {
+	ticker := time.NewTicker(revocationRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			lifecycle.Touch("token.revocations")
+			s.jwt.pruneUses(time.Now())
+		case <-s.jwt.refresh:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := s.loadRevocations(ctx); err != nil {
+			s.logger.Warn("Failed to refresh revoked tokens", "error", err)
+		}
+		cancel()
+	}
+}
+
+// requestRevocationRefresh asks for the revocation set to be re-read soon.
+func (s *TokenService) requestRevocationRefresh() {
+	if s.jwt == nil {
+		return
+	}
+	select {
+	case s.jwt.refresh <- struct{}{}:
+	default: // One is already pending
+	}
+}
+
+// stats reports the JWT backend for admin stats.
+func (j *jwtTokens) stats() map[string]interface{} {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return map[string]interface{}{
+		"algorithm": "HS256",
+		"revoked":   len(j.revoked),
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sessionLastUsed returns the last use the store records for the account's
+// only session.
+func sessionLastUsed(t *testing.T, svc *TokenService, keyAccountID int64) time.Time {
+	t.Helper()
+	sessions, err := svc.ListSessions(context.Background(), keyAccountID)
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("ListSessions = %v, %v; want one session", sessions, err)
+	}
+	return sessions[0].LastUsedAt
+}
+
+func TestJWTSessionsRecordLastUse(t *testing.T) {
+	ctx := context.Background()
+	svc := NewTokenServiceWithStore(NewMemoryTokenStore())
+	if err := svc.UseJWT([]byte(strings.Repeat("k", MinSigningKeyLen))); err != nil {
+		t.Fatal(err)
+	}
+	token, err := svc.GenerateToken(ctx, TokenData{KeyAccountID: 7, RobloxUserID: "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := sessionLastUsed(t, svc, 7)
+
+	steps := []struct {
+		name  string
+		at    time.Duration // After creation
+		wrote bool
+	}{
+		{"within the interval of creation", LastUsedInterval / 2, false},
+		{"first use after the interval", 2 * LastUsedInterval, true},
+		{"again within the interval", 2*LastUsedInterval + LastUsedInterval/2, false},
+		{"next interval", 3*LastUsedInterval + time.Second, true},
+	}
+	want := created
+	for _, step := range steps {
+		now := created.Add(step.at)
+		data, err := svc.validateJWT(ctx, token, now)
+		if err != nil {
+			t.Fatalf("%s: validateJWT: %v", step.name, err)
+		}
+		if step.wrote {
+			want = now
+		}
+		if got := sessionLastUsed(t, svc, 7); !got.Equal(want) {
+			t.Errorf("%s: stored last_used_at = %v, want %v", step.name, got, want)
+		}
+		if !data.LastUsedAt.Equal(want) {
+			t.Errorf("%s: LastUsedAt = %v, want %v", step.name, data.LastUsedAt, want)
+		}
+	}
+
+	// Revoked tokens don't validate, so they don't write either
+	if err := svc.RevokeToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.validateJWT(ctx, token, created.Add(5*LastUsedInterval)); err == nil {
+		t.Error("revoked token validated")
+	}
+
+	svc.jwt.pruneUses(created.Add(2 * TokenTTL))
+	if n := len(svc.jwt.uses); n != 0 {
+		t.Errorf("%d last-used entries kept past their token's expiry", n)
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// RefreshTokenPrefix is the prefix for all refresh tokens
+	RefreshTokenPrefix = "vhr_"
+
+	// RefreshTokenTTL is the default refresh token lifetime (30 days)
+	RefreshTokenTTL = 30 * 24 * time.Hour
+
+	// RefreshRedisKeyPrefix is the Redis key prefix for refresh tokens
+	RefreshRedisKeyPrefix = "vinzhub:refresh:"
+
+	// RefreshFamilyKeyPrefix is the Redis key prefix marking revoked
+	// session families
+	RefreshFamilyKeyPrefix = "vinzhub:refresh_family:"
+)
+
+var (
+	// ErrRefreshTokenInvalid is returned for unknown, expired or revoked
+	// refresh tokens.
+	ErrRefreshTokenInvalid = errors.New("refresh token not found or expired")
+	// ErrRefreshTokenReused is returned when a refresh token is used twice;
+	// its whole session family is revoked.
+	ErrRefreshTokenReused = errors.New("refresh token already used, session revoked")
+)
+
+// TokenPair is an access token with the single-use refresh token that
+// replaces it.
+type TokenPair struct {
+	AccessToken      string
+	RefreshToken     string
+	ExpiresIn        time.Duration
+	RefreshExpiresIn time.Duration
+}
+
+// SetRefreshTTL sets the lifetime of refresh tokens (RefreshTokenTTL when
+// <= 0). A session family stays alive as long as it is refreshed within it.
+func (s *TokenService) SetRefreshTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = RefreshTokenTTL
+	}
+	s.refreshTTL = ttl
+}
+
+// GenerateTokenPair creates an access token and a refresh token for a new
+// session family: every pair rotated from this one belongs to it.
+func (s *TokenService) GenerateTokenPair(ctx context.Context, data TokenData) (*TokenPair, error) {
+	familyBytes := make([]byte, 16)
+	if _, err := rand.Read(familyBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	data.FamilyID = hex.EncodeToString(familyBytes)
+	return s.generatePair(ctx, data)
+}
+
+// generatePair creates an access token and a refresh token for data's family.
+// The refresh token records the access token's session, retired on rotation.
+func (s *TokenService) generatePair(ctx context.Context, data TokenData) (*TokenPair, error) {
+	access, err := s.GenerateToken(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshBytes := make([]byte, 32)
+	if _, err := rand.Read(refreshBytes); err != nil {
+		s.RevokeToken(ctx, access)
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	refresh := RefreshTokenPrefix + hex.EncodeToString(refreshBytes)
+
+	data.SessionID = SessionIDForToken(access)
+	data.CreatedAt = time.Now()
+	data.ExpiresAt = data.CreatedAt.Add(s.refreshTTL)
+	data.LastUsedAt = time.Time{}
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		s.RevokeToken(ctx, access)
+		return nil, fmt.Errorf("failed to serialize token data: %w", err)
+	}
+	if err := s.store.SaveRefreshToken(ctx, refresh, jsonData, s.refreshTTL); err != nil {
+		s.RevokeToken(ctx, access)
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return &TokenPair{
+		AccessToken:      access,
+		RefreshToken:     refresh,
+		ExpiresIn:        s.ttl,
+		RefreshExpiresIn: s.refreshTTL,
+	}, nil
+}
+
+// RotateRefreshToken consumes a refresh token and issues a new pair in the
+// same family, retiring the access token issued with the old one. A refresh
+// token used a second time means it leaked: the whole family is revoked.
+func (s *TokenService) RotateRefreshToken(ctx context.Context, refresh string) (*TokenPair, error) {
+	if !strings.HasPrefix(refresh, RefreshTokenPrefix) {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	jsonData, uses, err := s.store.ConsumeRefreshToken(ctx, refresh)
+	if err == errTokenNotFound {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	var data TokenData
+	if err := json.Unmarshal(jsonData, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse token data: %w", err)
+	}
+
+	if uses > 1 {
+		s.logger.WarnContext(ctx, "Refresh token reused, revoking the session family",
+			"key_account_id", data.KeyAccountID, "family", data.FamilyID)
+		if err := s.revokeFamily(ctx, data.KeyAccountID, data.FamilyID); err != nil {
+			return nil, fmt.Errorf("failed to revoke session family: %w", err)
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	revoked, err := s.store.FamilyRevoked(ctx, data.FamilyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	if revoked {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	// Retire the previous access token; a failure only leaves it to expire
+	if index, err := s.store.SessionIndex(ctx, data.KeyAccountID); err == nil {
+		if token, ok := index[data.SessionID]; ok {
+			s.deleteSessions(ctx, data.KeyAccountID, map[string]string{data.SessionID: token})
+		}
+	}
+
+	data.SessionID = ""
+	return s.generatePair(ctx, data)
+}
+
+// revokeFamily blocks a session family's refresh tokens and deletes the
+// access tokens issued in it.
+func (s *TokenService) revokeFamily(ctx context.Context, keyAccountID int64, familyID string) error {
+	if err := s.store.RevokeFamily(ctx, familyID, s.refreshTTL); err != nil {
+		return err
+	}
+
+	index, err := s.store.SessionIndex(ctx, keyAccountID)
+	if err != nil {
+		return err
+	}
+	family := make(map[string]string)
+	for sessionID, token := range index {
+		jsonData, err := s.store.GetToken(ctx, token)
+		if err != nil {
+			continue
+		}
+		var data TokenData
+		if json.Unmarshal(jsonData, &data) == nil && data.FamilyID == familyID {
+			family[sessionID] = token
+		}
+	}
+	return s.deleteSessions(ctx, keyAccountID, family)
+}
+
+// revokeFamilies blocks the refresh tokens of the families access tokens
+// belong to, so revoking a session also logs its device out for good.
+// Tokens already expired have no data left; their family is left alone.
+func (s *TokenService) revokeFamilies(ctx context.Context, tokens ...string) error {
+	for _, token := range tokens {
+		jsonData, err := s.store.GetToken(ctx, token)
+		if err != nil {
+			continue
+		}
+		var data TokenData
+		if json.Unmarshal(jsonData, &data) != nil || data.FamilyID == "" {
+			continue
+		}
+		if err := s.store.RevokeFamily(ctx, data.FamilyID, s.refreshTTL); err != nil {
+			return fmt.Errorf("failed to revoke session family: %w", err)
+		}
+	}
+	return nil
+}
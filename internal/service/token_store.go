@@ -0,0 +1,416 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// errTokenNotFound is returned by a TokenStore for missing or expired tokens.
+var errTokenNotFound = errors.New("token not found")
+
+// TokenStore persists session tokens and the per-account session index.
+type TokenStore interface {
+	// SaveToken stores token data and indexes the session under its key account.
+	SaveToken(ctx context.Context, token string, data []byte, keyAccountID int64, sessionID string, ttl time.Duration) error
+	// SetToken overwrites token data without touching the index.
+	SetToken(ctx context.Context, token string, data []byte, ttl time.Duration) error
+	// TouchToken overwrites token data only if the token still exists, so a
+	// token revoked since it was read stays revoked; with a session ID it
+	// refreshes the index entry too. Returns false when the token is gone.
+	TouchToken(ctx context.Context, token string, data []byte, keyAccountID int64, sessionID string, ttl time.Duration) (bool, error)
+	// GetToken returns errTokenNotFound when the token is missing or expired.
+	GetToken(ctx context.Context, token string) ([]byte, error)
+	DeleteToken(ctx context.Context, token string) error
+	// SessionIndex returns session ID -> token for a key account.
+	SessionIndex(ctx context.Context, keyAccountID int64) (map[string]string, error)
+	// DeleteSessions removes the given sessions (session ID -> token) and their tokens.
+	DeleteSessions(ctx context.Context, keyAccountID int64, sessions map[string]string) error
+	// AddRevoked records a revoked signed token's session ID until the
+	// token would have expired anyway.
+	AddRevoked(ctx context.Context, sessionID string, until time.Time) error
+	// Revoked returns the revoked session IDs that haven't expired yet.
+	Revoked(ctx context.Context) (map[string]time.Time, error)
+	// SaveRefreshToken stores an unused refresh token.
+	SaveRefreshToken(ctx context.Context, token string, data []byte, ttl time.Duration) error
+	// ConsumeRefreshToken marks a refresh token used and returns its data and
+	// how many times it has now been used; errTokenNotFound when missing or
+	// expired. Used tokens are kept until they expire, to catch reuse.
+	ConsumeRefreshToken(ctx context.Context, token string) ([]byte, int64, error)
+	// RevokeFamily blocks a session family's refresh tokens for ttl.
+	RevokeFamily(ctx context.Context, familyID string, ttl time.Duration) error
+	FamilyRevoked(ctx context.Context, familyID string) (bool, error)
+}
+
+// consumeRefreshScript bumps a refresh token's use count and returns its
+// data with the new count, or nil for a missing token - atomically, so two
+// concurrent refreshes can't both see a first use.
+var consumeRefreshScript = redis.NewScript(`
+local data = redis.call('HGET', KEYS[1], 'data')
+if not data then
+	return false
+end
+local uses = redis.call('HINCRBY', KEYS[1], 'uses', 1)
+return {data, uses}
+`)
+
+// touchTokenScript rewrites a token only if its key still exists, and then
+// refreshes its session index entry when a session ID is given - atomically,
+// so a revocation between the read and the write can't be undone.
+var touchTokenScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+if ARGV[3] ~= '' then
+	redis.call('HSET', KEYS[2], ARGV[3], ARGV[4])
+	redis.call('PEXPIRE', KEYS[2], ARGV[2])
+end
+return 1
+`)
+
+// RedisTokenStore keeps tokens in Redis with native TTLs.
+type RedisTokenStore struct {
+	redis *redis.Client
+}
+
+// NewRedisTokenStore creates a Redis-backed token store.
+func NewRedisTokenStore(redisClient *redis.Client) *RedisTokenStore {
+	return &RedisTokenStore{redis: redisClient}
+}
+
+// SaveToken stores the token and its index entry in one transaction.
+func (s *RedisTokenStore) SaveToken(ctx context.Context, token string, data []byte, keyAccountID int64, sessionID string, ttl time.Duration) error {
+	indexKey := sessionIndexKey(keyAccountID)
+	pipe := s.redis.TxPipeline()
+	pipe.Set(ctx, TokenRedisKeyPrefix+token, data, ttl)
+	pipe.HSet(ctx, indexKey, sessionID, token)
+	pipe.Expire(ctx, indexKey, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SetToken overwrites the token data.
+func (s *RedisTokenStore) SetToken(ctx context.Context, token string, data []byte, ttl time.Duration) error {
+	return s.redis.Set(ctx, TokenRedisKeyPrefix+token, data, ttl).Err()
+}
+
+// TouchToken rewrites the token data if the token still exists.
+func (s *RedisTokenStore) TouchToken(ctx context.Context, token string, data []byte, keyAccountID int64, sessionID string, ttl time.Duration) (bool, error) {
+	keys := []string{TokenRedisKeyPrefix + token, sessionIndexKey(keyAccountID)}
+	n, err := touchTokenScript.Run(ctx, s.redis, keys, data, ttl.Milliseconds(), sessionID, token).Int()
+	return n == 1, err
+}
+
+// GetToken reads the token data.
+func (s *RedisTokenStore) GetToken(ctx context.Context, token string) ([]byte, error) {
+	data, err := s.redis.Get(ctx, TokenRedisKeyPrefix+token).Bytes()
+	if err == redis.Nil {
+		return nil, errTokenNotFound
+	}
+	return data, err
+}
+
+// DeleteToken deletes the token data.
+func (s *RedisTokenStore) DeleteToken(ctx context.Context, token string) error {
+	return s.redis.Del(ctx, TokenRedisKeyPrefix+token).Err()
+}
+
+// SessionIndex reads the account's session index hash.
+func (s *RedisTokenStore) SessionIndex(ctx context.Context, keyAccountID int64) (map[string]string, error) {
+	return s.redis.HGetAll(ctx, sessionIndexKey(keyAccountID)).Result()
+}
+
+// DeleteSessions deletes tokens and index entries in one transaction.
+func (s *RedisTokenStore) DeleteSessions(ctx context.Context, keyAccountID int64, sessions map[string]string) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+	indexKey := sessionIndexKey(keyAccountID)
+	pipe := s.redis.TxPipeline()
+	for sessionID, token := range sessions {
+		pipe.Del(ctx, TokenRedisKeyPrefix+token)
+		pipe.HDel(ctx, indexKey, sessionID)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// AddRevoked adds the session to the revocation set, scored by expiry, and
+// prunes entries that have expired.
+func (s *RedisTokenStore) AddRevoked(ctx context.Context, sessionID string, until time.Time) error {
+	pipe := s.redis.TxPipeline()
+	pipe.ZAdd(ctx, RevokedTokensKey, redis.Z{Score: float64(until.Unix()), Member: sessionID})
+	pipe.ZRemRangeByScore(ctx, RevokedTokensKey, "-inf", strconv.FormatInt(time.Now().Unix(), 10))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Revoked reads the unexpired part of the revocation set.
+func (s *RedisTokenStore) Revoked(ctx context.Context) (map[string]time.Time, error) {
+	entries, err := s.redis.ZRangeByScoreWithScores(ctx, RevokedTokensKey, &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(time.Now().Unix(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	revoked := make(map[string]time.Time, len(entries))
+	for _, entry := range entries {
+		if sessionID, ok := entry.Member.(string); ok {
+			revoked[sessionID] = time.Unix(int64(entry.Score), 0)
+		}
+	}
+	return revoked, nil
+}
+
+// SaveRefreshToken stores the refresh token hash with a use count of 0.
+func (s *RedisTokenStore) SaveRefreshToken(ctx context.Context, token string, data []byte, ttl time.Duration) error {
+	key := RefreshRedisKeyPrefix + token
+	pipe := s.redis.TxPipeline()
+	pipe.HSet(ctx, key, "data", data, "uses", 0)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ConsumeRefreshToken bumps the use count in one script call.
+func (s *RedisTokenStore) ConsumeRefreshToken(ctx context.Context, token string) ([]byte, int64, error) {
+	res, err := consumeRefreshScript.Run(ctx, s.redis, []string{RefreshRedisKeyPrefix + token}).Slice()
+	if err == redis.Nil {
+		return nil, 0, errTokenNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	data, _ := res[0].(string)
+	uses, _ := res[1].(int64)
+	return []byte(data), uses, nil
+}
+
+// RevokeFamily sets the family's revoked marker.
+func (s *RedisTokenStore) RevokeFamily(ctx context.Context, familyID string, ttl time.Duration) error {
+	return s.redis.Set(ctx, RefreshFamilyKeyPrefix+familyID, 1, ttl).Err()
+}
+
+// FamilyRevoked checks the family's revoked marker.
+func (s *RedisTokenStore) FamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	n, err := s.redis.Exists(ctx, RefreshFamilyKeyPrefix+familyID).Result()
+	return n > 0, err
+}
+
+// MemoryTokenStore keeps tokens in process memory. Tokens don't survive a
+// restart and aren't shared between instances - meant for demo and local runs.
+type MemoryTokenStore struct {
+	mu      sync.Mutex
+	tokens  map[string]memoryToken
+	indexes map[int64]map[string]string
+	revoked map[string]time.Time
+	refresh map[string]*memoryRefresh
+	family  map[string]time.Time // Revoked family -> marker expiry
+}
+
+type memoryRefresh struct {
+	data      []byte
+	uses      int64
+	expiresAt time.Time
+}
+
+type memoryToken struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+// NewMemoryTokenStore creates an in-memory token store.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		tokens:  make(map[string]memoryToken),
+		indexes: make(map[int64]map[string]string),
+		revoked: make(map[string]time.Time),
+		refresh: make(map[string]*memoryRefresh),
+		family:  make(map[string]time.Time),
+	}
+}
+
+// SaveToken stores the token and its index entry.
+func (s *MemoryTokenStore) SaveToken(ctx context.Context, token string, data []byte, keyAccountID int64, sessionID string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token] = memoryToken{data: data, expiresAt: time.Now().Add(ttl)}
+	index := s.indexes[keyAccountID]
+	if index == nil {
+		index = make(map[string]string)
+		s.indexes[keyAccountID] = index
+	}
+	index[sessionID] = token
+	return nil
+}
+
+// SetToken overwrites the token data.
+func (s *MemoryTokenStore) SetToken(ctx context.Context, token string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token] = memoryToken{data: data, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// TouchToken rewrites the token data if the token still exists.
+func (s *MemoryTokenStore) TouchToken(ctx context.Context, token string, data []byte, keyAccountID int64, sessionID string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[token]
+	if !ok || time.Now().After(t.expiresAt) {
+		return false, nil
+	}
+	s.tokens[token] = memoryToken{data: data, expiresAt: time.Now().Add(ttl)}
+	if sessionID != "" {
+		index := s.indexes[keyAccountID]
+		if index == nil {
+			index = make(map[string]string)
+			s.indexes[keyAccountID] = index
+		}
+		index[sessionID] = token
+	}
+	return true, nil
+}
+
+// GetToken reads the token data, dropping it if expired.
+func (s *MemoryTokenStore) GetToken(ctx context.Context, token string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[token]
+	if !ok {
+		return nil, errTokenNotFound
+	}
+	if time.Now().After(t.expiresAt) {
+		delete(s.tokens, token)
+		return nil, errTokenNotFound
+	}
+	return t.data, nil
+}
+
+// DeleteToken deletes the token data.
+func (s *MemoryTokenStore) DeleteToken(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tokens, token)
+	return nil
+}
+
+// SessionIndex returns a copy of the account's session index.
+func (s *MemoryTokenStore) SessionIndex(ctx context.Context, keyAccountID int64) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := make(map[string]string, len(s.indexes[keyAccountID]))
+	for sessionID, token := range s.indexes[keyAccountID] {
+		index[sessionID] = token
+	}
+	return index, nil
+}
+
+// DeleteSessions deletes tokens and index entries.
+func (s *MemoryTokenStore) DeleteSessions(ctx context.Context, keyAccountID int64, sessions map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := s.indexes[keyAccountID]
+	for sessionID, token := range sessions {
+		delete(s.tokens, token)
+		delete(index, sessionID)
+	}
+	if len(index) == 0 {
+		delete(s.indexes, keyAccountID)
+	}
+	return nil
+}
+
+// AddRevoked records a revoked session until it expires.
+func (s *MemoryTokenStore) AddRevoked(ctx context.Context, sessionID string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revoked[sessionID] = until
+	return nil
+}
+
+// Revoked returns a copy of the unexpired revocations, dropping the rest.
+func (s *MemoryTokenStore) Revoked(ctx context.Context) (map[string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	revoked := make(map[string]time.Time, len(s.revoked))
+	for sessionID, until := range s.revoked {
+		if !now.Before(until) {
+			delete(s.revoked, sessionID)
+			continue
+		}
+		revoked[sessionID] = until
+	}
+	return revoked, nil
+}
+
+// SaveRefreshToken stores an unused refresh token.
+func (s *MemoryTokenStore) SaveRefreshToken(ctx context.Context, token string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refresh[token] = &memoryRefresh{data: data, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// ConsumeRefreshToken bumps the use count, dropping the token if expired.
+func (s *MemoryTokenStore) ConsumeRefreshToken(ctx context.Context, token string) ([]byte, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.refresh[token]
+	if !ok {
+		return nil, 0, errTokenNotFound
+	}
+	if time.Now().After(r.expiresAt) {
+		delete(s.refresh, token)
+		return nil, 0, errTokenNotFound
+	}
+	r.uses++
+	return r.data, r.uses, nil
+}
+
+// RevokeFamily records the family as revoked until ttl from now.
+func (s *MemoryTokenStore) RevokeFamily(ctx context.Context, familyID string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.family[familyID] = time.Now().Add(ttl)
+	return nil
+}
+
+// FamilyRevoked reports whether the family is revoked, dropping expired markers.
+func (s *MemoryTokenStore) FamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, ok := s.family[familyID]
+	if ok && time.Now().After(until) {
+		delete(s.family, familyID)
+		return false, nil
+	}
+	return ok, nil
+}
+
+// Ensure both stores implement TokenStore
+var (
+	_ TokenStore = (*RedisTokenStore)(nil)
+	_ TokenStore = (*MemoryTokenStore)(nil)
+)
+
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// revokingStore runs onGet once, right after the next GetToken, to land a
+// revocation between ValidateToken's read and its last-used write.
+type revokingStore struct {
+	*MemoryTokenStore
+	onGet func()
+}
+
+func (s *revokingStore) GetToken(ctx context.Context, token string) ([]byte, error) {
+	data, err := s.MemoryTokenStore.GetToken(ctx, token)
+	if f := s.onGet; f != nil {
+		s.onGet = nil
+		f()
+	}
+	return data, err
+}
+
+// staleToken generates a token and backdates its last use, so the next
+// validation writes last-used.
+func staleToken(t *testing.T, svc *TokenService, store TokenStore) string {
+	t.Helper()
+	ctx := context.Background()
+	token, err := svc.GenerateToken(ctx, TokenData{KeyAccountID: 7, RobloxUserID: "100"})
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	raw, err := store.GetToken(ctx, token)
+	if err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	var data TokenData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatal(err)
+	}
+	data.LastUsedAt = time.Now().Add(-2 * LastUsedInterval)
+	raw, _ = json.Marshal(data)
+	if err := store.SetToken(ctx, token, raw, time.Until(data.ExpiresAt)); err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestValidateTokenRecordsLastUse(t *testing.T) {
+	store := NewMemoryTokenStore()
+	svc := NewTokenServiceWithStore(store)
+	token := staleToken(t, svc, store)
+
+	data, err := svc.ValidateToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if time.Since(data.LastUsedAt) > time.Second {
+		t.Errorf("LastUsedAt = %v, want now", data.LastUsedAt)
+	}
+}
+
+func TestValidateTokenDoesNotResurrectRevokedToken(t *testing.T) {
+	for _, sliding := range []bool{false, true} {
+		store := &revokingStore{MemoryTokenStore: NewMemoryTokenStore()}
+		svc := NewTokenServiceWithStore(store)
+		svc.SetExpiry(time.Hour, sliding)
+		token := staleToken(t, svc, store)
+
+		ctx := context.Background()
+		store.onGet = func() {
+			if err := svc.RevokeToken(ctx, token); err != nil {
+				t.Errorf("RevokeToken: %v", err)
+			}
+		}
+		if _, err := svc.ValidateToken(ctx, token); err == nil {
+			t.Errorf("sliding=%v: token revoked during validation was accepted", sliding)
+		}
+		if _, err := store.GetToken(ctx, token); err != errTokenNotFound {
+			t.Errorf("sliding=%v: revoked token is back in the store (err=%v)", sliding, err)
+		}
+		if _, err := svc.ValidateToken(ctx, token); err == nil {
+			t.Errorf("sliding=%v: revoked token still validates", sliding)
+		}
+	}
+}
+
+func TestMemoryTouchTokenRequiresExistingToken(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTokenStore()
+
+	ok, err := store.TouchToken(ctx, "vht_missing", []byte("{}"), 1, "s1", time.Minute)
+	if err != nil || ok {
+		t.Fatalf("TouchToken(missing) = %v, %v; want false, nil", ok, err)
+	}
+	if _, err := store.GetToken(ctx, "vht_missing"); err != errTokenNotFound {
+		t.Errorf("TouchToken created a missing token")
+	}
+	if index, _ := store.SessionIndex(ctx, 1); len(index) != 0 {
+		t.Errorf("TouchToken indexed a missing token: %v", index)
+	}
+
+	if err := store.SaveToken(ctx, "vht_a", []byte(`{"v":1}`), 1, "s1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	ok, err = store.TouchToken(ctx, "vht_a", []byte(`{"v":2}`), 1, "s1", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("TouchToken(existing) = %v, %v; want true, nil", ok, err)
+	}
+	if data, _ := store.GetToken(ctx, "vht_a"); string(data) != `{"v":2}` {
+		t.Errorf("data = %s, want the touched copy", data)
+	}
+}
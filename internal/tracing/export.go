@@ -0,0 +1,327 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"vinzhub-rest-api/internal/lifecycle"
+	"vinzhub-rest-api/internal/logging"
+)
+
+const (
+	// exportBatchSize is the most spans sent in one export request.
+	exportBatchSize = 512
+	// exportInterval is the longest a finished span waits to be exported.
+	exportInterval = 5 * time.Second
+	// exportTimeout bounds one export request.
+	exportTimeout = 10 * time.Second
+	// exportQueueSize bounds finished spans waiting for export; spans
+	// finished while it is full are dropped.
+	exportQueueSize = 4096
+)
+
+// Config configures the exporter.
+type Config struct {
+	// Endpoint is the collector's OTLP/HTTP base URL, e.g.
+	// http://otel-collector:4318; spans are posted to Endpoint/v1/traces
+	Endpoint string
+	// Headers are sent with every export, e.g. for collector auth
+	Headers map[string]string
+	// ServiceName is reported as the service.name resource attribute
+	ServiceName string
+	// SampleRatio is the share of new traces recorded, from 0 to 1.
+	// Incoming traceparent headers keep the caller's decision
+	SampleRatio float64
+}
+
+// ParseHeaders parses OTLP headers given as "key=value,key2=value2";
+// malformed pairs are skipped.
+func ParseHeaders(s string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if key = strings.TrimSpace(key); ok && key != "" {
+			headers[key] = strings.TrimSpace(value)
+		}
+	}
+	return headers
+}
+
+// Tracer records spans and exports them in batches.
+type Tracer struct {
+	cfg       Config
+	url       string
+	threshold uint64 // Trace IDs below it are sampled
+	client    *http.Client
+	logger    *slog.Logger
+
+	queue    chan *Span
+	stop     chan struct{}
+	done     chan struct{}
+	exported atomic.Int64
+	dropped  atomic.Int64
+	failures atomic.Int64
+}
+
+// Init starts tracing with cfg and returns the tracer, which must be
+// closed to export the last spans.
+func Init(cfg Config) (*Tracer, error) {
+	endpoint := strings.TrimRight(strings.TrimSpace(cfg.Endpoint), "/")
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("OTLP endpoint %q must be an http(s) URL", cfg.Endpoint)
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("sample ratio %v must be between 0 and 1", cfg.SampleRatio)
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "vinzhub-api"
+	}
+	t := &Tracer{
+		cfg:       cfg,
+		url:       endpoint + "/v1/traces",
+		threshold: uint64(cfg.SampleRatio * math.MaxInt64),
+		client:    &http.Client{Timeout: exportTimeout},
+		logger:    logging.Component("Tracing"),
+		queue:     make(chan *Span, exportQueueSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if cfg.SampleRatio >= 1 {
+		t.threshold = math.MaxUint64
+	}
+	lifecycle.Go("tracing.export", t.run)
+	tracer.Store(t)
+	return t, nil
+}
+
+// Close stops recording spans and exports the ones already finished.
+func (t *Tracer) Close() {
+	tracer.CompareAndSwap(t, nil)
+	close(t.stop)
+	<-t.done
+}
+
+// Stats returns export counters.
+func (t *Tracer) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"endpoint":     t.url,
+		"sample_ratio": t.cfg.SampleRatio,
+		"exported":     t.exported.Load(),
+		"dropped":      t.dropped.Load(),
+		"failures":     t.failures.Load(),
+		"queued":       len(t.queue),
+	}
+}
+
+func (t *Tracer) sampled(id TraceID) bool {
+	return traceIDBits(id) < t.threshold
+}
+
+func (t *Tracer) newSpan(name string, kind int, traceID TraceID, parent SpanID) *Span {
+	return &Span{
+		tracer:  t,
+		traceID: traceID,
+		spanID:  randomSpanID(),
+		parent:  parent,
+		kind:    kind,
+		start:   time.Now(),
+		name:    name,
+	}
+}
+
+func (t *Tracer) enqueue(s *Span) {
+	select {
+	case t.queue <- s:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// run exports queued spans every exportInterval or exportBatchSize spans,
+// and everything left once stopped.
+func (t *Tracer) run() {
+	select {
+	case <-t.done:
+		return // Restarted after a panic once already closed
+	default:
+	}
+
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, exportBatchSize)
+	for {
+		select {
+		case s := <-t.queue:
+			batch = append(batch, s)
+			if len(batch) < exportBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		case <-t.stop:
+			for {
+				select {
+				case s := <-t.queue:
+					batch = append(batch, s)
+					if len(batch) == exportBatchSize {
+						t.export(batch)
+						batch = batch[:0]
+					}
+					continue
+				default:
+				}
+				break
+			}
+			t.export(batch)
+			close(t.done)
+			return
+		}
+		t.export(batch)
+		batch = batch[:0]
+	}
+}
+
+// export posts spans to the collector. Failed batches are dropped.
+func (t *Tracer) export(spans []*Span) {
+	if len(spans) == 0 {
+		return
+	}
+	body, err := json.Marshal(t.encode(spans))
+	if err != nil {
+		t.failures.Add(1)
+		t.logger.Error("Failed to encode spans", "spans", len(spans), "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		t.failures.Add(1)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		t.failures.Add(1)
+		t.logger.Warn("Export failed", "spans", len(spans), "error", err)
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		t.failures.Add(1)
+		t.logger.Warn("Collector refused spans", "spans", len(spans), "status", resp.StatusCode)
+		return
+	}
+	t.exported.Add(int64(len(spans)))
+}
+
+// OTLP/JSON request shapes (opentelemetry-proto, JSON mapping).
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Links             []otlpLink     `json:"links,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+	otlpLink struct {
+		TraceID string `json:"traceId"`
+		SpanID  string `json:"spanId"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"` // 2 = error
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string `json:"stringValue,omitempty"`
+		IntValue    *string `json:"intValue,omitempty"`
+		BoolValue   *bool   `json:"boolValue,omitempty"`
+	}
+)
+
+func (t *Tracer) encode(spans []*Span) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           s.traceID.String(),
+			SpanID:            s.spanID.String(),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parent != (SpanID{}) {
+			span.ParentSpanID = s.parent.String()
+		}
+		for _, a := range s.attrs {
+			span.Attributes = append(span.Attributes, keyValue(a.key, a.value))
+		}
+		for _, l := range s.links {
+			span.Links = append(span.Links, otlpLink{TraceID: l.TraceID.String(), SpanID: l.SpanID.String()})
+		}
+		if s.failed {
+			span.Status = &otlpStatus{Code: 2, Message: s.errorMsg}
+		}
+		s.mu.Unlock()
+		out = append(out, span)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{keyValue("service.name", t.cfg.ServiceName)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "vinzhub-rest-api"}, Spans: out}},
+	}}}
+}
+
+func keyValue(key string, value interface{}) otlpKeyValue {
+	kv := otlpKeyValue{Key: key}
+	switch v := value.(type) {
+	case string:
+		kv.Value.StringValue = &v
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		kv.Value.IntValue = &s
+	case bool:
+		kv.Value.BoolValue = &v
+	}
+	return kv
+}
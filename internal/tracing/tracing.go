@@ -0,0 +1,342 @@
+// Package tracing records request and flush spans and exports them to an
+// OpenTelemetry collector over OTLP/HTTP (JSON encoding). Tracing is off
+// until Init is called; while off, starting a span is a nil check and every
+// Span method is a no-op on the nil span.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Span kinds, as numbered by OTLP.
+const (
+	KindInternal = 1
+	KindServer   = 2
+)
+
+// maxLinks bounds the links of one span (a flush links the syncs it
+// persisted).
+const maxLinks = 128
+
+// tracer is the active tracer; nil while tracing is off.
+var tracer atomic.Pointer[Tracer]
+
+// Enabled reports whether spans are recorded.
+func Enabled() bool {
+	return tracer.Load() != nil
+}
+
+// TraceID is a W3C trace ID.
+type TraceID [16]byte
+
+// SpanID is a W3C span ID.
+type SpanID [8]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// IsZero reports an unset ID.
+func (id TraceID) IsZero() bool { return id == TraceID{} }
+
+// Link points at a span of another trace.
+type Link struct {
+	TraceID TraceID
+	SpanID  SpanID
+}
+
+// ParseLink parses a link kept as "traceid-spanid" (see Span.Ref).
+func ParseLink(ref string) (Link, bool) {
+	traceHex, spanHex, ok := strings.Cut(ref, "-")
+	var link Link
+	if !ok || hex.DecodedLen(len(traceHex)) != 16 || hex.DecodedLen(len(spanHex)) != 8 {
+		return link, false
+	}
+	if _, err := hex.Decode(link.TraceID[:], []byte(traceHex)); err != nil {
+		return link, false
+	}
+	if _, err := hex.Decode(link.SpanID[:], []byte(spanHex)); err != nil {
+		return link, false
+	}
+	return link, true
+}
+
+type attribute struct {
+	key   string
+	value interface{} // string, int64 or bool
+}
+
+// Span is one timed operation. A nil *Span is valid and records nothing.
+type Span struct {
+	tracer  *Tracer
+	traceID TraceID
+	spanID  SpanID
+	parent  SpanID
+	kind    int
+	start   time.Time
+
+	mu       sync.Mutex
+	name     string
+	end      time.Time
+	attrs    []attribute
+	links    []Link
+	errorMsg string
+	failed   bool
+	ended    bool
+}
+
+type spanKey struct{}
+
+// FromContext returns the span in ctx, or nil.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Start begins a child of the span in ctx. Without one - tracing off, the
+// trace not sampled, or work outside any traced request - it returns ctx
+// and a nil span.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	t := tracer.Load()
+	if t == nil {
+		return ctx, nil
+	}
+	parent := FromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	s := t.newSpan(name, KindInternal, parent.traceID, parent.spanID)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// StartTrace begins a span of a new trace, subject to sampling, e.g. for a
+// background flush - or a child when ctx already carries a span.
+func StartTrace(ctx context.Context, name string) (context.Context, *Span) {
+	t := tracer.Load()
+	if t == nil {
+		return ctx, nil
+	}
+	if parent := FromContext(ctx); parent != nil {
+		s := t.newSpan(name, KindInternal, parent.traceID, parent.spanID)
+		return context.WithValue(ctx, spanKey{}, s), s
+	}
+	traceID := randomTraceID()
+	if !t.sampled(traceID) {
+		return ctx, nil
+	}
+	s := t.newSpan(name, KindInternal, traceID, SpanID{})
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// StartServer begins the span of an incoming request. A valid traceparent
+// header continues the caller's trace (and its sampling decision);
+// otherwise the trace ID is derived from requestID - the request ID itself
+// when it is a UUID - so a request ID from a log finds its trace.
+func StartServer(ctx context.Context, name, traceparent, requestID string) (context.Context, *Span) {
+	t := tracer.Load()
+	if t == nil {
+		return ctx, nil
+	}
+	traceID, parent, sampled, ok := parseTraceparent(traceparent)
+	if !ok {
+		traceID = traceIDFromRequestID(requestID)
+		sampled = t.sampled(traceID)
+	}
+	if !sampled {
+		return ctx, nil
+	}
+	s := t.newSpan(name, KindServer, traceID, parent)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// TraceIDFromContext returns the hex trace ID of the span in ctx, or "".
+func TraceIDFromContext(ctx context.Context) string {
+	if s := FromContext(ctx); s != nil {
+		return s.traceID.String()
+	}
+	return ""
+}
+
+// RefFromContext returns the Ref of the span in ctx, or "".
+func RefFromContext(ctx context.Context) string {
+	return FromContext(ctx).Ref()
+}
+
+// TraceID returns the span's hex trace ID, "" for a nil span.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.traceID.String()
+}
+
+// Ref identifies the span as "traceid-spanid", for links kept with data
+// (e.g. a buffered entry). "" for a nil span.
+func (s *Span) Ref() string {
+	if s == nil {
+		return ""
+	}
+	return s.traceID.String() + "-" + s.spanID.String()
+}
+
+// Traceparent returns the span's W3C traceparent header value.
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return "00-" + s.traceID.String() + "-" + s.spanID.String() + "-01"
+}
+
+// SetName renames the span, e.g. once the route is known.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+// SetString records a string attribute.
+func (s *Span) SetString(key, value string) {
+	if s == nil {
+		return
+	}
+	s.setAttr(key, value)
+}
+
+// SetInt records an integer attribute.
+func (s *Span) SetInt(key string, value int64) {
+	if s == nil {
+		return
+	}
+	s.setAttr(key, value)
+}
+
+// SetBool records a boolean attribute.
+func (s *Span) SetBool(key string, value bool) {
+	if s == nil {
+		return
+	}
+	s.setAttr(key, value)
+}
+
+func (s *Span) setAttr(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.attrs {
+		if s.attrs[i].key == key {
+			s.attrs[i].value = value
+			return
+		}
+	}
+	s.attrs = append(s.attrs, attribute{key: key, value: value})
+}
+
+// AddLink links the span to another trace's span; links past maxLinks are
+// dropped.
+func (s *Span) AddLink(link Link) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.links) < maxLinks {
+		s.links = append(s.links, link)
+	}
+}
+
+// Fail marks the span as failed with err. A nil err does nothing.
+func (s *Span) Fail(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.failed = true
+	s.errorMsg = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and queues it for export. Later calls do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.enqueue(s)
+}
+
+// parseTraceparent parses a W3C traceparent header ("00-trace-span-flags").
+func parseTraceparent(header string) (TraceID, SpanID, bool, bool) {
+	var (
+		traceID TraceID
+		spanID  SpanID
+	)
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return traceID, spanID, false, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil || traceID.IsZero() {
+		return traceID, spanID, false, false
+	}
+	if _, err := hex.Decode(spanID[:], []byte(parts[2])); err != nil || spanID == (SpanID{}) {
+		return traceID, spanID, false, false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return traceID, spanID, false, false
+	}
+	return traceID, spanID, flags&1 == 1, true
+}
+
+// traceIDFromRequestID reuses a UUID request ID as the trace ID and hashes
+// anything else; an empty one gets a random trace ID.
+func traceIDFromRequestID(requestID string) TraceID {
+	if requestID == "" {
+		return randomTraceID()
+	}
+	if id, err := uuid.Parse(requestID); err == nil {
+		return TraceID(id)
+	}
+	sum := sha256.Sum256([]byte(requestID))
+	var id TraceID
+	copy(id[:], sum[:16])
+	return id
+}
+
+func randomTraceID() TraceID {
+	var id TraceID
+	rand.Read(id[:])
+	return id
+}
+
+func randomSpanID() SpanID {
+	var id SpanID
+	for id == (SpanID{}) {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+// traceIDBits maps a trace ID onto [0, 2^63) for ratio sampling; W3C trace
+// IDs are random in their last bytes.
+func traceIDBits(id TraceID) uint64 {
+	return binary.BigEndian.Uint64(id[8:]) >> 1
+}
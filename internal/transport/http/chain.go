@@ -0,0 +1,204 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/transport/http/middleware"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Route groups. Every route belongs to exactly one group, and each group
+// declares its middleware chain below - ordering is load-bearing, so keep
+// new middleware in these declarations rather than in ad-hoc r.Use calls.
+const (
+	groupGlobal    = "global"    // Wraps every request, including 404s and CORS preflights
+	groupPublic    = "public"    // Health, token issuance, dashboard assets
+	groupClient    = "client"    // Session token or API key callers
+	groupAdmin     = "admin"     // Admin API and metrics
+	groupStreaming = "streaming" // Long-lived responses (SSE); never buffered or compressed
+)
+
+// routeGroupOrder is the order groups are listed in.
+var routeGroupOrder = []string{groupGlobal, groupPublic, groupClient, groupAdmin, groupStreaming}
+
+// mandatoryMiddleware can't be disabled through RouterOptions.
+var mandatoryMiddleware = map[string]bool{"auth": true, "admin_auth": true, "ip_allowlist": true}
+
+// namedMiddleware is a middleware with a name used in configuration and logs.
+type namedMiddleware struct {
+	name    string
+	handler func(http.Handler) http.Handler
+}
+
+// middlewareChain is an ordered list of middleware, outermost first.
+type middlewareChain []namedMiddleware
+
+// chain builds a middlewareChain.
+func chain(mws ...namedMiddleware) middlewareChain {
+	return mws
+}
+
+// mw names a middleware.
+func mw(name string, handler func(http.Handler) http.Handler) namedMiddleware {
+	return namedMiddleware{name: name, handler: handler}
+}
+
+// names returns the chain's middleware names, outermost first.
+func (c middlewareChain) names() []string {
+	names := make([]string, len(c))
+	for i, m := range c {
+		names[i] = m.name
+	}
+	return names
+}
+
+// without returns the chain minus the middleware disabled for group.
+func (c middlewareChain) without(group string, disabled []string) middlewareChain {
+	out := make(middlewareChain, 0, len(c))
+	for _, m := range c {
+		if isDisabled(group, m.name, disabled) {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// use installs the chain on a router.
+func (c middlewareChain) use(r chi.Router) {
+	for _, m := range c {
+		r.Use(m.handler)
+	}
+}
+
+// isDisabled matches "name" (every group) and "group:name" entries.
+func isDisabled(group, name string, disabled []string) bool {
+	if mandatoryMiddleware[name] {
+		return false
+	}
+	for _, entry := range disabled {
+		entry = strings.TrimSpace(entry)
+		if entry == name || entry == group+":"+name {
+			return true
+		}
+	}
+	return false
+}
+
+// routeGroupChains declares the middleware chain of every group. The
+// global chain runs first for every request; a group's chain runs inside it.
+func routeGroupChains(opts RouterOptions) map[string]middlewareChain {
+	auth := opts.Auth
+	if auth == nil {
+		auth = middleware.APIKeyAuth
+	}
+	adminAllowlist := middleware.IPAllowlist(opts.AdminIPAllowlist, opts.TrustProxy)
+	chains := map[string]middlewareChain{
+		groupGlobal: chain(
+			mw("recovery", middleware.Recovery), // Outermost: catches panics in everything below
+			mw("request_id", middleware.RequestID),
+			mw("logging", middleware.AccessLog(opts.AccessLogExclude)),
+			mw("tracing", middleware.Tracing), // After logging: shares its request timing
+			mw("cors", corsHandler(opts.CORS)),
+		),
+		groupPublic: chain(
+			mw("body_limit", middleware.BodyLimit(opts.MaxBodyBytes)),
+			mw("metrics", middleware.Metrics),
+		),
+		groupClient: chain(
+			mw("body_limit", middleware.BodyLimit(opts.MaxBodyBytes)),
+			mw("auth", auth),
+			mw("metrics", middleware.Metrics), // After auth: SLIs are labelled by principal
+		),
+		groupAdmin: chain(
+			mw("ip_allowlist", adminAllowlist), // Before auth: outsiders can't probe keys
+			mw("body_limit", middleware.BodyLimit(opts.MaxBodyBytes)),
+			mw("auth", auth),
+			mw("admin_auth", middleware.RequireAdmin), // Regular keys and session tokens stop here
+		),
+		groupStreaming: chain(
+			mw("ip_allowlist", adminAllowlist),                             // Admin bundles and profiling only
+			mw("body_limit", middleware.BodyLimit(opts.BatchMaxBodyBytes)), // Bundle uploads
+			mw("auth", auth),
+			mw("admin_auth", middleware.RequireAdmin),
+		),
+	}
+
+	// Innermost, so the middleware above sees the uncompressed response
+	if opts.CompressMinBytes > 0 {
+		compress := mw("compress", middleware.Compress(opts.CompressMinBytes))
+		for _, group := range []string{groupPublic, groupClient, groupAdmin} {
+			chains[group] = append(chains[group], compress)
+		}
+	}
+	return chains
+}
+
+// effectiveChains returns each group's chain after RouterOptions are applied.
+func effectiveChains(opts RouterOptions) map[string]middlewareChain {
+	chains := routeGroupChains(opts)
+	for group, c := range chains {
+		chains[group] = c.without(group, opts.DisabledMiddleware)
+	}
+	return chains
+}
+
+// DescribeChains renders the effective middleware order of every group,
+// global middleware included, e.g. "client: recovery > request_id > ... > auth".
+func DescribeChains(opts RouterOptions) []string {
+	chains := effectiveChains(opts)
+	global := chains[groupGlobal].names()
+
+	lines := make([]string, 0, len(routeGroupOrder))
+	for _, group := range routeGroupOrder {
+		names := chains[group].names()
+		if group != groupGlobal {
+			names = append(append([]string{}, global...), names...)
+		}
+		if len(names) == 0 {
+			names = []string{"(none)"}
+		}
+		lines = append(lines, group+": "+strings.Join(names, " > "))
+	}
+	return lines
+}
+
+var routerLog = logging.Component("Router")
+
+// logDisabled reports middleware turned off through RouterOptions, and
+// entries that match nothing.
+func logDisabled(opts RouterOptions) {
+	chains := routeGroupChains(opts)
+	for _, entry := range opts.DisabledMiddleware {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		group, name, scoped := strings.Cut(entry, ":")
+		if !scoped {
+			group, name = "", entry
+		}
+		if mandatoryMiddleware[name] {
+			routerLog.Warn("Ignoring HTTP_DISABLED_MIDDLEWARE entry - the middleware can't be disabled", "entry", entry, "middleware", name)
+			continue
+		}
+
+		matched := false
+		for g, c := range chains {
+			if group != "" && g != group {
+				continue
+			}
+			for _, m := range c {
+				matched = matched || m.name == name
+			}
+		}
+		if !matched {
+			routerLog.Warn("HTTP_DISABLED_MIDDLEWARE entry matches no middleware (global middleware is disabled as \"global:name\" or \"name\")", "entry", entry)
+			continue
+		}
+		routerLog.Warn("Middleware disabled", "entry", entry)
+	}
+}
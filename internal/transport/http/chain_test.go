@@ -0,0 +1,97 @@
+package http
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDescribeChains(t *testing.T) {
+	global := "recovery > request_id > logging > tracing > cors"
+	tests := []struct {
+		name string
+		opts RouterOptions
+		want []string
+	}{
+		{
+			name: "defaults",
+			opts: RouterOptions{},
+			want: []string{
+				"global: " + global,
+				"public: " + global + " > body_limit > metrics",
+				"client: " + global + " > body_limit > auth > metrics",
+				"admin: " + global + " > ip_allowlist > body_limit > auth > admin_auth",
+				"streaming: " + global + " > ip_allowlist > body_limit > auth > admin_auth",
+			},
+		},
+		{
+			name: "compression is innermost and never on streaming",
+			opts: RouterOptions{CompressMinBytes: 1024},
+			want: []string{
+				"global: " + global,
+				"public: " + global + " > body_limit > metrics > compress",
+				"client: " + global + " > body_limit > auth > metrics > compress",
+				"admin: " + global + " > ip_allowlist > body_limit > auth > admin_auth > compress",
+				"streaming: " + global + " > ip_allowlist > body_limit > auth > admin_auth",
+			},
+		},
+		{
+			name: "disabled per group and everywhere",
+			opts: RouterOptions{DisabledMiddleware: []string{"client:metrics", " tracing ", "body_limit"}},
+			want: []string{
+				"global: recovery > request_id > logging > cors",
+				"public: recovery > request_id > logging > cors > metrics",
+				"client: recovery > request_id > logging > cors > auth",
+				"admin: recovery > request_id > logging > cors > ip_allowlist > auth > admin_auth",
+				"streaming: recovery > request_id > logging > cors > ip_allowlist > auth > admin_auth",
+			},
+		},
+		{
+			name: "mandatory middleware can't be disabled",
+			opts: RouterOptions{DisabledMiddleware: []string{"auth", "admin:admin_auth", "ip_allowlist"}},
+			want: []string{
+				"global: " + global,
+				"public: " + global + " > body_limit > metrics",
+				"client: " + global + " > body_limit > auth > metrics",
+				"admin: " + global + " > ip_allowlist > body_limit > auth > admin_auth",
+				"streaming: " + global + " > ip_allowlist > body_limit > auth > admin_auth",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DescribeChains(tt.opts)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("DescribeChains =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestChainOrderInvariants(t *testing.T) {
+	chains := effectiveChains(RouterOptions{CompressMinBytes: 1})
+	index := func(group, name string) int {
+		for i, n := range chains[group].names() {
+			if n == name {
+				return i
+			}
+		}
+		return -1
+	}
+
+	if index(groupGlobal, "recovery") != 0 {
+		t.Error("recovery is not the outermost middleware")
+	}
+	for _, group := range []string{groupClient, groupAdmin, groupStreaming} {
+		if b, a := index(group, "body_limit"), index(group, "auth"); b < 0 || a < 0 || b > a {
+			t.Errorf("%s: body_limit (%d) must run before auth (%d)", group, b, a)
+		}
+	}
+	for _, group := range []string{groupAdmin, groupStreaming} {
+		if index(group, "ip_allowlist") != 0 {
+			t.Errorf("%s: ip_allowlist is not first", group)
+		}
+	}
+	if index(groupStreaming, "compress") >= 0 {
+		t.Error("streaming responses are compressed")
+	}
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/go-chi/cors"
+)
+
+// CORSOptions is the cross-origin policy applied to every route.
+type CORSOptions struct {
+	// AllowedOrigins lists origins browsers may call the API from, e.g.
+	// "https://app.example" or "https://*.example"; "*" allows any. Empty
+	// denies every cross-origin request.
+	AllowedOrigins []string
+
+	// AllowCredentials lets browsers send cookies and HTTP auth. Browsers
+	// refuse it together with "*", so it is dropped in that case.
+	AllowCredentials bool
+
+	// MaxAge is how many seconds browsers may cache a preflight.
+	MaxAge int
+}
+
+// AllowsAnyOrigin reports whether the policy allows every origin.
+func (o *CORSOptions) AllowsAnyOrigin() bool {
+	for _, origin := range o.AllowedOrigins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// corsHandler builds the CORS middleware. A nil policy allows any origin,
+// without credentials (development).
+func corsHandler(policy *CORSOptions) func(http.Handler) http.Handler {
+	options := cors.Options{
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Accept", "Accept-Version", "Authorization", "Content-Type", "X-Request-ID", "X-API-Key", "X-Token", "X-Client-Version", "X-Sync-Callback", "Idempotency-Key", "traceparent"},
+		ExposedHeaders: []string{"X-Request-ID", "traceparent"},
+		MaxAge:         300,
+	}
+	switch {
+	case policy == nil:
+		options.AllowedOrigins = []string{"*"}
+	case len(policy.AllowedOrigins) == 0:
+		// An empty list means "any" to the cors package
+		options.AllowOriginFunc = func(*http.Request, string) bool { return false }
+		options.MaxAge = policy.MaxAge
+	default:
+		options.AllowedOrigins = policy.AllowedOrigins
+		options.AllowCredentials = policy.AllowCredentials && !policy.AllowsAnyOrigin()
+		options.MaxAge = policy.MaxAge
+	}
+	return cors.Handler(options)
+}
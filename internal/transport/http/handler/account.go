@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/transport/http/middleware"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+)
+
+var accountLog = logging.Component("Account")
+
+// SetAccountInfo enables GET /api/v1/account.
+func (h *AuthHandler) SetAccountInfo(accounts repository.KeyAccountInfoReader) {
+	h.accounts = accounts
+}
+
+// GetAccount handles GET /api/v1/account
+// Returns the caller's key account and license status (X-Token required),
+// with the license key masked. When the key account database can't be
+// read, the details held in the token are returned with "degraded": true.
+func (h *AuthHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	tokenData := middleware.GetTokenDataFromContext(r.Context())
+	if tokenData == nil {
+		response.Error(w, apierror.Unauthorized("X-Token required"))
+		return
+	}
+
+	if h.accounts != nil {
+		info, err := h.accounts.GetKeyAccountInfo(r.Context(), tokenData.KeyAccountID)
+		if err == nil {
+			if key, ok := info["license_key"].(string); ok {
+				info["license_key"] = maskLicenseKey(key)
+			}
+			info["degraded"] = false
+			response.OK(w, info)
+			return
+		}
+		if errors.Is(err, repository.ErrKeyAccountNotFound) {
+			response.Error(w, apierror.NotFound("key account not found"))
+			return
+		}
+		accountLog.WarnContext(r.Context(), "Account info unavailable, serving token data",
+			"key_account_id", tokenData.KeyAccountID, "error", err)
+	}
+
+	response.OK(w, map[string]interface{}{
+		"id":              strconv.FormatInt(tokenData.KeyAccountID, 10),
+		"roblox_user_id":  tokenData.RobloxUserID,
+		"roblox_username": tokenData.RobloxUsername,
+		"hwid":            tokenData.HWID,
+		"degraded":        true,
+	})
+}
+
+// maskLicenseKey hides all but the last 4 characters of a license key.
+func maskLicenseKey(key string) string {
+	if len(key) <= 4 {
+		return strings.Repeat("*", len(key))
+	}
+	return strings.Repeat("*", len(key)-4) + key[len(key)-4:]
+}
+
+// HeartbeatRecorder buffers client heartbeats of key accounts.
+type HeartbeatRecorder interface {
+	Beat(keyAccountID int64)
+}
+
+// SetHeartbeats enables POST /api/v1/account/heartbeat.
+func (h *AuthHandler) SetHeartbeats(heartbeats HeartbeatRecorder) {
+	h.heartbeats = heartbeats
+}
+
+// Heartbeat handles POST /api/v1/account/heartbeat
+// Marks the caller's key account online (X-Token required). Heartbeats are
+// written in batches, so last_heartbeat_at trails by up to a flush interval.
+func (h *AuthHandler) Heartbeat(w http.ResponseWriter, r *http.Request) {
+	if h.heartbeats == nil {
+		componentMissing(w, "heartbeat")
+		return
+	}
+	tokenData := middleware.GetTokenDataFromContext(r.Context())
+	if tokenData == nil {
+		response.Error(w, apierror.Unauthorized("X-Token required"))
+		return
+	}
+	if tokenData.Restricted() {
+		response.Error(w, apierror.Forbidden("support tokens can't send heartbeats"))
+		return
+	}
+
+	h.heartbeats.Beat(tokenData.KeyAccountID)
+	response.OK(w, map[string]string{"status": "ok"})
+}
@@ -1,28 +1,151 @@
 package handler
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"runtime"
+	"strconv"
 	"time"
 
 	"vinzhub-rest-api/internal/cache"
+	"vinzhub-rest-api/internal/lifecycle"
+	"vinzhub-rest-api/internal/logging"
 	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/service"
+	"vinzhub-rest-api/internal/transport/http/middleware"
 	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+	"vinzhub-rest-api/pkg/jsondiff"
+
+	"github.com/go-chi/chi/v5"
 )
 
-// AdminHandler handles admin-related HTTP requests.
+// adminLog logs admin actions and failures.
+var adminLog = logging.Component("Admin")
+
+// StatsProvider is an optional component that reports its own admin stats.
+type StatsProvider interface {
+	Stats(ctx context.Context) map[string]interface{}
+}
+
+// FlushLogReader lists recorded flushes.
+type FlushLogReader interface {
+	ListFlushLog(ctx context.Context, page repository.PageQuery) ([]repository.FlushLogEntry, error)
+}
+
+// IntegrityVerifier lists and acts on integrity findings.
+type IntegrityVerifier interface {
+	StatsProvider
+	Issues(ctx context.Context, status string, page repository.PageQuery) ([]repository.IntegrityIssue, error)
+	Reverify(ctx context.Context, id int64) (*repository.IntegrityIssue, error)
+	Acknowledge(ctx context.Context, id int64) (*repository.IntegrityIssue, error)
+}
+
+// RetentionRunner prunes auxiliary tables on demand.
+type RetentionRunner interface {
+	StatsProvider
+	Run(ctx context.Context) (map[string]int64, error)
+}
+
+// Recompressor converts stored documents to the current storage format.
+type Recompressor interface {
+	Start() (service.RecompressProgress, error)
+	Progress() service.RecompressProgress
+}
+
+// LogArchiveLister lists the files rows were archived to before retention
+// deleted them.
+type LogArchiveLister interface {
+	List() ([]repository.LogArchiveFile, error)
+}
+
+// FlushResumer lifts a data-loss guard pause.
+type FlushResumer interface {
+	Resume(ctx context.Context, discard bool) (*service.FlushTrip, error)
+}
+
+// AdminBuffer is the write buffer as seen by admin endpoints.
+type AdminBuffer interface {
+	Count(ctx context.Context) (int64, error)
+	Get(ctx context.Context, robloxUserID string) (*cache.BufferedInventory, error)
+	GetSection(ctx context.Context, robloxUserID, section string) (*cache.BufferedInventory, error)
+	IsPending(ctx context.Context, robloxUserID, section string) (bool, error)
+	Drop(ctx context.Context, robloxUserID, section string) (bool, error)
+	Clear(ctx context.Context) (int64, int, error)
+	DeadLetters(ctx context.Context) ([]cache.DeadLetter, error)
+	DeadLetterCount(ctx context.Context) (int64, error)
+	Requeue(ctx context.Context, robloxUserID, section string) (bool, error)
+	KeyPrefix() string
+	FlushWatchdog() *cache.FlushWatchdog
+	Backpressure() (pending, max int64)
+	LastCleanup() *cache.CleanupStats
+	Breaker() cache.BreakerStats
+	RekeyFrom(ctx context.Context, oldPrefix string, dryRun bool, progress func(cache.RekeyResult)) (*cache.RekeyResult, error)
+	Drain(ctx context.Context, budget time.Duration) (*cache.DrainResult, error)
+}
+
+// AdminStore is the inventory store as seen by admin endpoints.
+type AdminStore interface {
+	GetStats(ctx context.Context) (map[string]interface{}, error)
+	GetRawInventory(ctx context.Context, robloxUserID string) ([]byte, *time.Time, error)
+	CountUnlinked(ctx context.Context) (int64, error)
+	DeleteUnlinked(ctx context.Context) (int64, error)
+	DeleteOlderThan(ctx context.Context, cutoff time.Time, dryRun bool) (*repository.PruneResult, error)
+	ScanSince(ctx context.Context, since time.Time, fn func(repository.InventoryItem) error) error
+	BatchUpsertRawInventoryStats(ctx context.Context, items []repository.InventoryItem) (*repository.UpsertStats, error)
+	Pragmas() repository.SQLitePragmas
+}
+
+// AdminHandler handles admin-related HTTP requests. Every dependency is
+// optional: stats report absent components as unavailable and endpoints
+// that need one answer 501 naming it.
 type AdminHandler struct {
-	redisBuffer   *cache.RedisInventoryBuffer
-	sqliteRepo    *repository.SQLiteInventoryRepository
-	startTime     time.Time
-	requestCount  int64
-	lastRequestAt time.Time
+	redisBuffer     AdminBuffer
+	sqliteRepo      AdminStore
+	ingest          StatsProvider
+	spool           StatsProvider
+	fallback        StatsProvider
+	flush           StatsProvider
+	flushLog        FlushLogReader
+	flushResumer    FlushResumer
+	integrity       IntegrityVerifier
+	retention       RetentionRunner
+	recompressor    Recompressor
+	logArchive      LogArchiveLister
+	reads           StatsProvider
+	tokenCache      StatsProvider
+	invalidation    StatsProvider
+	invRetention    StatsProvider
+	heartbeats      StatsProvider
+	sqliteFiles     []SQLiteFile
+	backups         BackupRunner
+	keyAccounts     repository.KeyAccountProvisioner
+	keyAccountCache KeyAccountCacheInvalidator
+	audit           AuditLog
+	sqlConsole      SQLConsole
+	supportTokens   SupportTokenIssuer
+	apiKeys         APIKeyManager
+	sessions        AccountSessions
+	hwidResets      repository.KeyAccountHWIDResetter
+	hwidCooldown    time.Duration
+	schema          SchemaReporter
+	inventoryRules  InventoryFlagReader
+	bundles         BundleTransfer
+	startTime       time.Time
+	requestCount    int64
+	lastRequestAt   time.Time
 }
 
-// NewAdminHandler creates a new admin handler.
+// NewAdminHandler creates a new admin handler. Either dependency may be nil;
+// pass an untyped nil, not a nil pointer, for an absent component.
 func NewAdminHandler(
-	redisBuffer *cache.RedisInventoryBuffer,
-	sqliteRepo *repository.SQLiteInventoryRepository,
+	redisBuffer AdminBuffer,
+	sqliteRepo AdminStore,
 ) *AdminHandler {
 	return &AdminHandler{
 		redisBuffer: redisBuffer,
@@ -31,6 +154,101 @@ func NewAdminHandler(
 	}
 }
 
+// SetIngestConsumer attaches the queue consumer so its counters and lag
+// appear in admin stats.
+func (h *AdminHandler) SetIngestConsumer(consumer StatsProvider) {
+	h.ingest = consumer
+}
+
+// SetBufferSpool attaches the disk spool so its depth appears in admin stats.
+func (h *AdminHandler) SetBufferSpool(spool StatsProvider) {
+	h.spool = spool
+}
+
+// SetBufferFallback attaches the in-memory buffer fallback so whether it
+// is active appears in admin stats.
+func (h *AdminHandler) SetBufferFallback(fallback StatsProvider) {
+	h.fallback = fallback
+}
+
+// SetFlushPipeline attaches the flush pipeline counters and its flush log.
+func (h *AdminHandler) SetFlushPipeline(pipeline StatsProvider, flushLog FlushLogReader) {
+	h.flush = pipeline
+	h.flushLog = flushLog
+}
+
+// SetFlushResumer attaches the pipeline's data-loss guard controls.
+func (h *AdminHandler) SetFlushResumer(resumer FlushResumer) {
+	h.flushResumer = resumer
+}
+
+// SetIntegrityVerifier attaches the background integrity verifier.
+func (h *AdminHandler) SetIntegrityVerifier(verifier IntegrityVerifier) {
+	h.integrity = verifier
+}
+
+// SetInventoryService attaches the inventory read cache counters.
+func (h *AdminHandler) SetInventoryService(svc StatsProvider) {
+	h.reads = svc
+}
+
+// SetTokenCache attaches the token validation cache counters.
+func (h *AdminHandler) SetTokenCache(tokens StatsProvider) {
+	h.tokenCache = tokens
+}
+
+// SetInvalidationBus attaches the cross-instance invalidation bus.
+func (h *AdminHandler) SetInvalidationBus(bus StatsProvider) {
+	h.invalidation = bus
+}
+
+// SetRetentionEngine attaches the auxiliary table retention engine.
+func (h *AdminHandler) SetRetentionEngine(engine RetentionRunner) {
+	h.retention = engine
+}
+
+// SetInventoryRetention attaches the inventory retention job.
+func (h *AdminHandler) SetInventoryRetention(job StatsProvider) {
+	h.invRetention = job
+}
+
+// SetHeartbeats attaches the heartbeat recorder so its counters appear in
+// admin stats.
+func (h *AdminHandler) SetHeartbeats(recorder StatsProvider) {
+	h.heartbeats = recorder
+}
+
+// SQLiteFile is one database file, for maintenance and schema reports.
+type SQLiteFile interface {
+	Maintain(ctx context.Context, convert bool) (*repository.MaintenanceResult, error)
+	MigrationStatus(ctx context.Context) (*repository.MigrationStatus, error)
+}
+
+// SetSQLiteFiles attaches the database files maintained on demand.
+func (h *AdminHandler) SetSQLiteFiles(files []SQLiteFile) {
+	h.sqliteFiles = files
+}
+
+// BackupRunner writes backups of the database files.
+type BackupRunner interface {
+	Backup(ctx context.Context) (*repository.BackupResult, error)
+}
+
+// SetBackups enables the backup endpoint.
+func (h *AdminHandler) SetBackups(backups BackupRunner) {
+	h.backups = backups
+}
+
+// SetRecompressor attaches the stored document recompressor.
+func (h *AdminHandler) SetRecompressor(recompressor Recompressor) {
+	h.recompressor = recompressor
+}
+
+// SetLogArchive attaches the retention log archive.
+func (h *AdminHandler) SetLogArchive(archive LogArchiveLister) {
+	h.logArchive = archive
+}
+
 // GetStats handles GET /api/v1/admin/stats
 // Returns system statistics for the admin dashboard.
 func (h *AdminHandler) GetStats(w http.ResponseWriter, r *http.Request) {
@@ -55,44 +273,39 @@ func (h *AdminHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 		"goroutines":     runtime.NumGoroutine(),
 	}
 
-	// Redis buffer stats
+	// Component sections - an absent or failing component reports itself
+	// unavailable without failing the rest of the response
+	var buffer, sqlite StatsProvider
 	if h.redisBuffer != nil {
-		count, err := h.redisBuffer.Count(ctx)
-		if err == nil {
-			stats["redis_buffer"] = map[string]interface{}{
-				"pending_items": count,
-				"status":        "connected",
-			}
-		} else {
-			stats["redis_buffer"] = map[string]interface{}{
-				"status": "error",
-				"error":  err.Error(),
-			}
-		}
-	} else {
-		stats["redis_buffer"] = map[string]interface{}{
-			"status": "not_configured",
-		}
+		buffer = statsFunc(h.bufferStats)
 	}
-
-	// SQLite stats
 	if h.sqliteRepo != nil {
-		sqliteStats, err := h.sqliteRepo.GetStats(ctx)
-		if err == nil {
-			stats["sqlite"] = sqliteStats
-			stats["sqlite"].(map[string]interface{})["status"] = "connected"
-		} else {
-			stats["sqlite"] = map[string]interface{}{
-				"status": "error",
-				"error":  err.Error(),
-			}
-		}
-	} else {
-		stats["sqlite"] = map[string]interface{}{
-			"status": "not_configured",
-		}
+		sqlite = statsFunc(h.sqliteStats)
 	}
 
+	stats["redis_buffer"] = statsSection(ctx, "redis_buffer", buffer)
+	stats["sqlite"] = statsSection(ctx, "sqlite", sqlite)
+	stats["ingest"] = statsSection(ctx, "ingest", h.ingest)
+	stats["buffer_spool"] = statsSection(ctx, "buffer_spool", h.spool)
+	stats["buffer_fallback"] = statsSection(ctx, "buffer_fallback", h.fallback)
+	stats["flush"] = statsSection(ctx, "flush", h.flush)
+	stats["integrity"] = statsSection(ctx, "integrity", h.integrity)
+	stats["read_cache"] = statsSection(ctx, "read_cache", h.reads)
+	stats["token_cache"] = statsSection(ctx, "token_cache", h.tokenCache)
+	stats["invalidation_bus"] = statsSection(ctx, "invalidation_bus", h.invalidation)
+	stats["retention"] = statsSection(ctx, "retention", h.retention)
+	stats["inventory_retention"] = statsSection(ctx, "inventory_retention", h.invRetention)
+	stats["heartbeat"] = statsSection(ctx, "heartbeat", h.heartbeats)
+	stats["api_keys"] = statsSection(ctx, "api_keys", h.apiKeys)
+	stats["schema_profile"] = statsSection(ctx, "schema_profile", h.schema)
+	stats["inventory_rules"] = statsSection(ctx, "inventory_rules", h.inventoryRules)
+
+	// Logging level, sampling and volume
+	stats["logging"] = logging.Stats()
+
+	// SLO compliance over the last hour
+	stats["slo"] = middleware.SLOStats()
+
 	// Runtime info
 	stats["runtime"] = map[string]interface{}{
 		"go_version": runtime.Version(),
@@ -104,11 +317,738 @@ func (h *AdminHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	response.OK(w, stats)
 }
 
+// statsFunc adapts a function to StatsProvider.
+type statsFunc func(ctx context.Context) map[string]interface{}
+
+func (f statsFunc) Stats(ctx context.Context) map[string]interface{} {
+	return f(ctx)
+}
+
+// statsSection collects one component's stats section, marking it
+// "available". A nil provider reports unavailable and a panicking one is
+// reported as an error instead of failing the whole response.
+func statsSection(ctx context.Context, name string, provider StatsProvider) (section map[string]interface{}) {
+	if provider == nil {
+		return map[string]interface{}{
+			"available": false,
+			"status":    "not_configured",
+		}
+	}
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			adminLog.ErrorContext(ctx, "Stats section panicked", "section", name, "panic", fmt.Sprint(rec))
+			section = map[string]interface{}{
+				"available": true,
+				"status":    "error",
+				"error":     fmt.Sprintf("stats failed: %v", rec),
+			}
+		}
+	}()
+
+	section = provider.Stats(ctx)
+	if section == nil {
+		section = map[string]interface{}{}
+	}
+	section["available"] = true
+	return section
+}
+
+// bufferStats reports the Redis buffer backlog.
+func (h *AdminHandler) bufferStats(ctx context.Context) map[string]interface{} {
+	count, err := h.redisBuffer.Count(ctx)
+	if err != nil {
+		return map[string]interface{}{
+			"status":  "error",
+			"error":   err.Error(),
+			"breaker": h.redisBuffer.Breaker(),
+		}
+	}
+	deadLettered, err := h.redisBuffer.DeadLetterCount(ctx)
+	if err != nil {
+		return map[string]interface{}{
+			"status":  "error",
+			"error":   err.Error(),
+			"breaker": h.redisBuffer.Breaker(),
+		}
+	}
+	_, maxPending := h.redisBuffer.Backpressure()
+	return map[string]interface{}{
+		"pending_items":     count,
+		"max_pending":       maxPending,
+		"full":              maxPending > 0 && count >= maxPending,
+		"dead_letter_items": deadLettered,
+		"status":            "connected",
+		"flush_watchdog":    h.redisBuffer.FlushWatchdog().Stats(ctx),
+		"last_cleanup":      h.redisBuffer.LastCleanup(),
+		"breaker":           h.redisBuffer.Breaker(),
+	}
+}
+
+// sqliteStats reports database size and row counts.
+func (h *AdminHandler) sqliteStats(ctx context.Context) map[string]interface{} {
+	sqliteStats, err := h.sqliteRepo.GetStats(ctx)
+	if err != nil {
+		return map[string]interface{}{
+			"status": "error",
+			"error":  err.Error(),
+		}
+	}
+	sqliteStats["status"] = "connected"
+	return sqliteStats
+}
+
+// componentMissing answers 501 for an endpoint whose component isn't
+// configured in this deployment.
+func componentMissing(w http.ResponseWriter, component string) {
+	response.Error(w, apierror.NotImplemented(component+" is not configured on this server").
+		WithDetails(apierror.FieldError{Field: "component", Message: component}))
+}
+
 // GetHealth handles GET /api/v1/admin/health
 // Quick health check for monitoring.
 func (h *AdminHandler) GetHealth(w http.ResponseWriter, r *http.Request) {
-	response.OK(w, map[string]string{
+	health := map[string]interface{}{
 		"status": "healthy",
 		"time":   time.Now().Format(time.RFC3339),
+	}
+	if h.sqliteRepo != nil {
+		health["sqlite_pragmas"] = h.sqliteRepo.Pragmas()
+	}
+	response.OK(w, health)
+}
+
+// copySummary describes one copy of a user's inventory in a comparison.
+type copySummary struct {
+	Present      bool       `json:"present"`
+	Timestamp    *time.Time `json:"timestamp,omitempty"`
+	Size         int        `json:"size,omitempty"`
+	SHA256       string     `json:"sha256,omitempty"`
+	KeyAccountID int64      `json:"key_account_id,omitempty"`
+}
+
+func summarize(data []byte, ts *time.Time) copySummary {
+	sum := sha256.Sum256(data)
+	return copySummary{
+		Present:   true,
+		Timestamp: ts,
+		Size:      len(data),
+		SHA256:    hex.EncodeToString(sum[:]),
+	}
+}
+
+// CompareUser handles GET /api/v1/admin/users/{roblox_user_id}/compare
+// Reports the buffered (Redis) and persisted (SQLite) copies of a user's
+// inventory side by side. Read-only: never flushes or mutates anything.
+// With ?diff=true a structural diff is included when both copies exist.
+func (h *AdminHandler) CompareUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	robloxUserID := chi.URLParam(r, "roblox_user_id")
+	if !validRobloxUserID(robloxUserID) {
+		response.Error(w, errInvalidRobloxUserID)
+		return
+	}
+	if h.redisBuffer == nil && h.sqliteRepo == nil {
+		componentMissing(w, "sqlite")
+		return
+	}
+
+	var (
+		bufferData []byte
+		dbData     []byte
+		buffer     = copySummary{}
+		database   = copySummary{}
+	)
+
+	// Lookup errors are surfaced rather than treated as misses - a Redis
+	// hiccup must not be reported as "nothing buffered".
+	if h.redisBuffer != nil {
+		inv, err := h.redisBuffer.Get(ctx, robloxUserID)
+		if err != nil {
+			response.Error(w, apierror.ServiceUnavailable("failed to read buffer: "+err.Error()))
+			return
+		}
+		if inv != nil {
+			bufferData = inv.RawJSON
+			buffer = summarize(inv.RawJSON, &inv.UpdatedAt)
+			buffer.KeyAccountID = inv.KeyAccountID
+		}
+	}
+
+	if h.sqliteRepo != nil {
+		data, syncedAt, err := h.sqliteRepo.GetRawInventory(ctx, robloxUserID)
+		if err != nil {
+			response.Error(w, apierror.ServiceUnavailable("failed to read database: "+err.Error()))
+			return
+		}
+		if data != nil {
+			dbData = data
+			database = summarize(data, syncedAt)
+		}
+	}
+
+	result := map[string]interface{}{
+		"roblox_user_id":      robloxUserID,
+		"buffer":              buffer,
+		"database":            database,
+		"buffer_configured":   h.redisBuffer != nil,
+		"database_configured": h.sqliteRepo != nil,
+	}
+
+	switch {
+	case buffer.Present && database.Present:
+		match := buffer.SHA256 == database.SHA256
+		result["presence"] = "both"
+		result["match"] = match
+		if !match && r.URL.Query().Get("diff") == "true" {
+			// Old = persisted copy, New = buffered copy (what the next flush writes)
+			diff, err := jsondiff.Compare(dbData, bufferData, jsondiff.Options{})
+			if err != nil {
+				result["diff_error"] = err.Error()
+			} else {
+				result["diff"] = diff
+			}
+		}
+	case buffer.Present:
+		result["presence"] = "buffer_only"
+		result["match"] = false
+	case database.Present:
+		result["presence"] = "db_only"
+		result["match"] = false
+	default:
+		result["presence"] = "neither"
+		result["match"] = false
+	}
+
+	response.OK(w, result)
+}
+
+// maxLogLevelTTL caps how long a runtime log level override may last.
+const maxLogLevelTTL = 24 * time.Hour
+
+// LogLevelRequest is the body of PUT /api/v1/admin/log-level.
+type LogLevelRequest struct {
+	Level string `json:"level"`
+	TTL   string `json:"ttl,omitempty"` // Go duration, default 15m
+}
+
+// SetLogLevel handles PUT /api/v1/admin/log-level
+// Changes the global log level until the TTL elapses, then reverts to LOG_LEVEL.
+func (h *AdminHandler) SetLogLevel(w http.ResponseWriter, r *http.Request) {
+	var req LogLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, bodyError(err, apierror.BadRequest("Invalid JSON body")))
+		return
+	}
+
+	level, err := logging.ParseLevel(req.Level)
+	if err != nil {
+		response.Error(w, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	ttl := 15 * time.Minute
+	if req.TTL != "" {
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 || ttl > maxLogLevelTTL {
+			response.Error(w, apierror.BadRequest("ttl must be a duration between 1s and 24h"))
+			return
+		}
+	}
+
+	logging.SetLevel(level, ttl)
+	adminLog.InfoContext(r.Context(), "Log level set", "level", level.String(), "ttl", ttl)
+
+	response.OK(w, logging.Stats())
+}
+
+// GetGoroutines handles GET /api/v1/admin/goroutines
+// Lists background components with their goroutine counts and last
+// activity, alongside the process total.
+func (h *AdminHandler) GetGoroutines(w http.ResponseWriter, r *http.Request) {
+	lifecycle.Check()
+	response.OK(w, lifecycle.Stats())
+}
+
+// GetFlushLog handles GET /api/v1/admin/flush-log?limit=50&cursor=...
+// Lists recent flushes with the outcome of every pipeline stage.
+func (h *AdminHandler) GetFlushLog(w http.ResponseWriter, r *http.Request) {
+	if h.flushLog == nil {
+		componentMissing(w, "flush_log")
+		return
+	}
+
+	page, ok := pageRequest(w, r, "flush_log", 50)
+	if !ok {
+		return
+	}
+
+	entries, err := h.flushLog.ListFlushLog(r.Context(), page)
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+	n, next := nextPage(len(entries), page, "flush_log", func(i int) int64 { return entries[i].ID })
+
+	response.OK(w, map[string]interface{}{
+		"flushes":     entries[:n],
+		"next_cursor": next,
+	})
+}
+
+// GetUnlinked handles GET /api/v1/admin/unlinked
+// Counts inventories stored without a key account (key_account_id = 0).
+func (h *AdminHandler) GetUnlinked(w http.ResponseWriter, r *http.Request) {
+	if h.sqliteRepo == nil {
+		componentMissing(w, "sqlite")
+		return
+	}
+
+	count, err := h.sqliteRepo.CountUnlinked(r.Context())
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+
+	response.OK(w, map[string]interface{}{
+		"unlinked": count,
+	})
+}
+
+// PurgeUnlinked handles DELETE /api/v1/admin/unlinked?confirm=true
+// Deletes every inventory stored without a key account.
+func (h *AdminHandler) PurgeUnlinked(w http.ResponseWriter, r *http.Request) {
+	if h.sqliteRepo == nil {
+		componentMissing(w, "sqlite")
+		return
+	}
+	if r.URL.Query().Get("confirm") != "true" {
+		response.Error(w, apierror.BadRequest("?confirm=true is required"))
+		return
+	}
+
+	deleted, err := h.sqliteRepo.DeleteUnlinked(r.Context())
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+
+	adminLog.InfoContext(r.Context(), "Purged unlinked inventories", "deleted", deleted)
+	response.OK(w, map[string]interface{}{
+		"deleted": deleted,
+	})
+}
+
+// PruneRequest is the body of POST /api/v1/admin/prune.
+type PruneRequest struct {
+	OlderThan string `json:"older_than"` // Go duration, e.g. "720h"
+	DryRun    bool   `json:"dry_run"`
+}
+
+// PruneInventories handles POST /api/v1/admin/prune
+// Deletes inventory rows last synced longer ago than older_than, or counts
+// them with dry_run.
+func (h *AdminHandler) PruneInventories(w http.ResponseWriter, r *http.Request) {
+	if h.sqliteRepo == nil {
+		componentMissing(w, "sqlite")
+		return
+	}
+
+	var req PruneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, bodyError(err, apierror.BadRequest("Invalid JSON body")))
+		return
+	}
+	olderThan, err := time.ParseDuration(req.OlderThan)
+	if err != nil || olderThan <= 0 {
+		response.Error(w, apierror.BadRequest("older_than must be a positive duration, e.g. 720h"))
+		return
+	}
+
+	cutoff := time.Now().UTC().Add(-olderThan)
+	result, err := h.sqliteRepo.DeleteOlderThan(r.Context(), cutoff, req.DryRun)
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+
+	adminLog.InfoContext(r.Context(), "Pruned old inventories", "older_than", olderThan, "dry_run", req.DryRun,
+		"rows", result.Rows, "reclaimed_pages", result.ReclaimedPages)
+	response.OK(w, map[string]interface{}{
+		"older_than":               olderThan.String(),
+		"cutoff":                   cutoff,
+		"dry_run":                  req.DryRun,
+		"rows":                     result.Rows,
+		"reclaimed_pages_estimate": result.ReclaimedPages,
+		"reclaimed_bytes_estimate": result.ReclaimedBytes,
+	})
+}
+
+// RunMaintenance handles POST /api/v1/admin/maintenance?convert=true
+// Runs incremental_vacuum and ANALYZE on every database file, one at a
+// time. convert first rewrites files created before incremental
+// auto_vacuum with a full VACUUM, which blocks writes to each file while
+// it runs.
+func (h *AdminHandler) RunMaintenance(w http.ResponseWriter, r *http.Request) {
+	if len(h.sqliteFiles) == 0 {
+		componentMissing(w, "sqlite")
+		return
+	}
+	convert := r.URL.Query().Get("convert") == "true"
+
+	// A full VACUUM of a large file outlives the server write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	start := time.Now()
+	results := make([]*repository.MaintenanceResult, 0, len(h.sqliteFiles))
+	var freed int64
+	for _, file := range h.sqliteFiles {
+		result, err := file.Maintain(r.Context(), convert)
+		if err != nil {
+			adminLog.ErrorContext(r.Context(), "Maintenance failed", "completed", len(results), "error", err)
+			response.Error(w, apierror.InternalError(fmt.Sprintf("maintenance failed after %d files: %v", len(results), err)))
+			return
+		}
+		freed += result.FreedPages
+		results = append(results, result)
+	}
+
+	duration := time.Since(start)
+	adminLog.InfoContext(r.Context(), "Ran SQLite maintenance", "files", len(results), "freed_pages", freed,
+		"convert", convert, "duration_ms", duration.Milliseconds())
+	response.OK(w, map[string]interface{}{
+		"files":       results,
+		"freed_pages": freed,
+		"duration_ms": duration.Milliseconds(),
+	})
+}
+
+// GetMigrations handles GET /api/v1/admin/migrations
+// Reports the schema version and applied migrations of every database file.
+func (h *AdminHandler) GetMigrations(w http.ResponseWriter, r *http.Request) {
+	if len(h.sqliteFiles) == 0 {
+		componentMissing(w, "sqlite")
+		return
+	}
+
+	files := make([]*repository.MigrationStatus, 0, len(h.sqliteFiles))
+	for _, file := range h.sqliteFiles {
+		status, err := file.MigrationStatus(r.Context())
+		if err != nil {
+			response.Error(w, apierror.InternalError(err.Error()))
+			return
+		}
+		files = append(files, status)
+	}
+	response.OK(w, map[string]interface{}{
+		"latest": repository.LatestSchemaVersion(),
+		"files":  files,
+	})
+}
+
+// CreateBackup handles POST /api/v1/admin/backup
+// Writes a timestamped copy of every database file into the backup
+// directory while the server keeps running. One backup at a time.
+func (h *AdminHandler) CreateBackup(w http.ResponseWriter, r *http.Request) {
+	if h.backups == nil {
+		componentMissing(w, "backups")
+		return
+	}
+
+	// Large databases outlive the server write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	result, err := h.backups.Backup(r.Context())
+	if errors.Is(err, repository.ErrBackupRunning) {
+		response.Error(w, apierror.Conflict(err.Error()))
+		return
+	}
+	if err != nil && result == nil {
+		adminLog.ErrorContext(r.Context(), "Backup failed", "error", err)
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+	if err != nil {
+		adminLog.WarnContext(r.Context(), "Backup written with errors", "dir", result.Dir, "error", err)
+	}
+
+	h.recordAudit(r, "backup.create", result.Dir, map[string]interface{}{
+		"bytes":   result.Bytes,
+		"removed": result.Removed,
+	})
+	adminLog.InfoContext(r.Context(), "Backup written", "dir", result.Dir, "files", len(result.Files),
+		"bytes", result.Bytes, "duration_ms", result.DurationMs, "removed", len(result.Removed))
+	response.OK(w, result)
+}
+
+// GetIntegrity handles GET /api/v1/admin/integrity?status=open&limit=100&cursor=...
+// Lists integrity findings with the verifier's progress.
+func (h *AdminHandler) GetIntegrity(w http.ResponseWriter, r *http.Request) {
+	if h.integrity == nil {
+		componentMissing(w, "integrity")
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", repository.IntegrityOpen, repository.IntegrityAcknowledged, repository.IntegrityResolved:
+	default:
+		response.Error(w, apierror.BadRequest("status must be open, acknowledged or resolved"))
+		return
+	}
+
+	page, ok := pageRequest(w, r, "integrity", 100)
+	if !ok {
+		return
+	}
+
+	issues, err := h.integrity.Issues(r.Context(), status, page)
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+	n, next := nextPage(len(issues), page, "integrity", func(i int) int64 { return issues[i].ID })
+
+	response.OK(w, map[string]interface{}{
+		"issues":      issues[:n],
+		"next_cursor": next,
+		"verifier":    h.integrity.Stats(r.Context()),
+	})
+}
+
+// ReverifyIntegrityIssue handles POST /api/v1/admin/integrity/{id}/reverify
+// Checks the row (or file) again; clean results resolve the issue.
+func (h *AdminHandler) ReverifyIntegrityIssue(w http.ResponseWriter, r *http.Request) {
+	h.integrityAction(w, r, func(ctx context.Context, id int64) (*repository.IntegrityIssue, error) {
+		return h.integrity.Reverify(ctx, id)
+	})
+}
+
+// AcknowledgeIntegrityIssue handles POST /api/v1/admin/integrity/{id}/acknowledge
+func (h *AdminHandler) AcknowledgeIntegrityIssue(w http.ResponseWriter, r *http.Request) {
+	h.integrityAction(w, r, func(ctx context.Context, id int64) (*repository.IntegrityIssue, error) {
+		return h.integrity.Acknowledge(ctx, id)
+	})
+}
+
+// integrityAction runs a per-issue action and returns the updated issue.
+func (h *AdminHandler) integrityAction(w http.ResponseWriter, r *http.Request, action func(context.Context, int64) (*repository.IntegrityIssue, error)) {
+	if h.integrity == nil {
+		componentMissing(w, "integrity")
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		response.Error(w, apierror.BadRequest("invalid issue id"))
+		return
+	}
+
+	issue, err := action(r.Context(), id)
+	if errors.Is(err, repository.ErrIntegrityIssueNotFound) {
+		response.Error(w, apierror.NotFound("integrity issue not found"))
+		return
+	}
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+
+	adminLog.InfoContext(r.Context(), "Integrity issue updated", "issue_id", issue.ID, "status", issue.Status)
+	response.OK(w, issue)
+}
+
+// RunRetention handles POST /api/v1/admin/retention/run
+// Prunes auxiliary tables now instead of waiting for the next scheduled run.
+func (h *AdminHandler) RunRetention(w http.ResponseWriter, r *http.Request) {
+	if h.retention == nil {
+		componentMissing(w, "retention")
+		return
+	}
+
+	deleted, err := h.retention.Run(r.Context())
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+
+	adminLog.InfoContext(r.Context(), "Retention run finished", "deleted", deleted)
+	response.OK(w, map[string]interface{}{
+		"deleted":   deleted,
+		"retention": h.retention.Stats(r.Context()),
+	})
+}
+
+// StartRecompress handles POST /api/v1/admin/storage/recompress
+// Starts converting every stored document to the format writes use now
+// (see INVENTORY_COMPRESS_MIN_BYTES), in batches in the background. 409
+// while a run is in progress.
+func (h *AdminHandler) StartRecompress(w http.ResponseWriter, r *http.Request) {
+	if h.recompressor == nil {
+		componentMissing(w, "recompressor")
+		return
+	}
+
+	progress, err := h.recompressor.Start()
+	if errors.Is(err, service.ErrRecompressRunning) {
+		response.Error(w, apierror.Conflict("a recompression is already running, see GET /api/v1/admin/storage/recompress"))
+		return
+	}
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+
+	adminLog.InfoContext(r.Context(), "Recompression of stored documents started")
+	response.Accepted(w, progress)
+}
+
+// GetRecompress handles GET /api/v1/admin/storage/recompress
+// Reports the progress of the current or last recompression.
+func (h *AdminHandler) GetRecompress(w http.ResponseWriter, r *http.Request) {
+	if h.recompressor == nil {
+		componentMissing(w, "recompressor")
+		return
+	}
+	response.OK(w, h.recompressor.Progress())
+}
+
+// GetLogArchives handles GET /api/v1/admin/log-archives?table=audit_log
+// Lists the archive files pruned rows were written to, with the time range
+// and size of each. Search them with `api grep-archive`.
+func (h *AdminHandler) GetLogArchives(w http.ResponseWriter, r *http.Request) {
+	if h.logArchive == nil {
+		componentMissing(w, "log_archive")
+		return
+	}
+
+	files, err := h.logArchive.List()
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+	table := r.URL.Query().Get("table")
+	matched := make([]repository.LogArchiveFile, 0, len(files))
+	var rows, bytes int64
+	for _, f := range files {
+		if table != "" && f.Table != table {
+			continue
+		}
+		matched = append(matched, f)
+		rows += f.Rows
+		bytes += f.Bytes
+	}
+
+	response.OK(w, map[string]interface{}{
+		"files":       matched,
+		"total_files": len(matched),
+		"total_rows":  rows,
+		"total_bytes": bytes,
+	})
+}
+
+// RekeyBufferRequest is the body of POST /api/v1/admin/buffer/rekey.
+type RekeyBufferRequest struct {
+	From   string `json:"from"`
+	DryRun bool   `json:"dry_run"`
+}
+
+// RekeyBuffer handles POST /api/v1/admin/buffer/rekey
+// Moves entries buffered under an old key prefix into the current one.
+func (h *AdminHandler) RekeyBuffer(w http.ResponseWriter, r *http.Request) {
+	if h.redisBuffer == nil {
+		componentMissing(w, "redis_buffer")
+		return
+	}
+
+	var req RekeyBufferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, bodyError(err, apierror.BadRequest("Invalid JSON body")))
+		return
+	}
+	if req.From == "" || req.From == h.redisBuffer.KeyPrefix() {
+		response.Error(w, apierror.BadRequest("from must be set and differ from the current prefix"))
+		return
+	}
+
+	result, err := h.redisBuffer.RekeyFrom(r.Context(), req.From, req.DryRun, nil)
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+
+	adminLog.InfoContext(r.Context(), "Buffer rekeyed", "from", result.From, "to", result.To, "dry_run", result.DryRun,
+		"moved", result.Moved, "replaced", result.ReplacedNew, "dropped_older", result.DroppedOlder, "changed", result.Changed)
+	response.OK(w, result)
+}
+
+// Drain budgets for POST /api/v1/admin/flush.
+const (
+	defaultDrainBudget = 30 * time.Second
+	maxDrainBudget     = 5 * time.Minute
+)
+
+// FlushNow handles POST /api/v1/admin/flush?budget=30s
+// Drains the buffer before a deploy: flushes batch after batch until
+// nothing is pending or the budget runs out, alongside the background
+// flush. Answers 409 while flushing is on hold.
+func (h *AdminHandler) FlushNow(w http.ResponseWriter, r *http.Request) {
+	if h.redisBuffer == nil {
+		componentMissing(w, "redis_buffer")
+		return
+	}
+
+	budget := defaultDrainBudget
+	if raw := r.URL.Query().Get("budget"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 || parsed > maxDrainBudget {
+			response.Error(w, apierror.BadRequest("budget must be a duration between 1s and 5m"))
+			return
+		}
+		budget = parsed
+	}
+	// The drain may outlast the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(budget + 30*time.Second))
+
+	result, err := h.redisBuffer.Drain(r.Context(), budget)
+	if errors.Is(err, cache.ErrFlushOnHold) {
+		response.Error(w, apierror.Conflict("flushing is on hold; resume it first"))
+		return
+	}
+	if err != nil {
+		response.Error(w, apierror.InternalError(fmt.Sprintf("drain stopped after %d items: %v", result.Flushed, err)))
+		return
+	}
+
+	adminLog.InfoContext(r.Context(), "Buffer drained", "flushed", result.Flushed, "remaining", result.Remaining,
+		"duration_ms", result.DurationMs)
+	response.OK(w, result)
+}
+
+// ResumeFlush handles POST /api/v1/admin/flush/resume?discard=true
+// Lifts a data-loss guard pause. By default the held writes are accepted;
+// with discard=true the ones that shrank drastically are thrown away.
+func (h *AdminHandler) ResumeFlush(w http.ResponseWriter, r *http.Request) {
+	if h.flushResumer == nil {
+		componentMissing(w, "flush")
+		return
+	}
+
+	discard := r.URL.Query().Get("discard") == "true"
+	trip, err := h.flushResumer.Resume(r.Context(), discard)
+	if errors.Is(err, service.ErrFlushNotPaused) {
+		response.Error(w, apierror.Conflict("flushing is not paused"))
+		return
+	}
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+
+	adminLog.InfoContext(r.Context(), "Flushing resumed", "discard", discard, "dropped", trip.Dropped, "checked", trip.Checked)
+	response.OK(w, map[string]interface{}{
+		"resumed":   true,
+		"discarded": discard,
+		"trip":      trip,
 	})
 }
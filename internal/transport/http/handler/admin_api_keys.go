@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/transport/http/middleware"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// APIKeyManager creates, lists and revokes database-backed API keys.
+type APIKeyManager interface {
+	StatsProvider
+	CreateAPIKey(ctx context.Context, label string, scopes []string) (string, *repository.APIKey, error)
+	ListAPIKeys(ctx context.Context, includeRevoked bool) ([]repository.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) (*repository.APIKey, error)
+}
+
+// SetAPIKeys enables the API key management endpoints.
+func (h *AdminHandler) SetAPIKeys(keys APIKeyManager) {
+	h.apiKeys = keys
+}
+
+// CreateAPIKeyRequest is the body of POST /api/v1/admin/api-keys.
+type CreateAPIKeyRequest struct {
+	Label  string   `json:"label"`
+	Scopes []string `json:"scopes"` // Empty for a full API key
+}
+
+// CreateAPIKey handles POST /api/v1/admin/api-keys
+// Creates an API key with the given scopes, or a full key without any. The
+// key is in this response only; just its hash is stored. Full API key only.
+func (h *AdminHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !h.apiKeysAllowed(w, r) {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, bodyError(err, apierror.BadRequest("Invalid JSON body")))
+		return
+	}
+
+	var details []apierror.FieldError
+	req.Label = strings.TrimSpace(req.Label)
+	if req.Label == "" || len(req.Label) > 100 {
+		details = append(details, apierror.FieldError{Field: "label", Message: "must be 1 to 100 characters"})
+	}
+	for _, scope := range req.Scopes {
+		if !middleware.KnownScope(scope) {
+			details = append(details, apierror.FieldError{Field: "scopes", Message: "unknown scope " + scope})
+		}
+	}
+	if len(details) > 0 {
+		response.Error(w, apierror.ValidationError("Invalid API key", details...))
+		return
+	}
+
+	key, created, err := h.apiKeys.CreateAPIKey(r.Context(), req.Label, req.Scopes)
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+
+	h.recordAudit(r, "api_key.create", apiKeyTarget(created.ID), map[string]interface{}{
+		"label":  created.Label,
+		"scopes": created.Scopes,
+		"key_id": created.KeyID,
+	})
+	response.Created(w, map[string]interface{}{
+		"key":     key,
+		"api_key": created,
+	})
+}
+
+// ListAPIKeys handles GET /api/v1/admin/api-keys
+// ?include_revoked=true lists revoked keys too.
+func (h *AdminHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !h.apiKeysAllowed(w, r) {
+		return
+	}
+
+	keys, err := h.apiKeys.ListAPIKeys(r.Context(), r.URL.Query().Get("include_revoked") == "true")
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+	response.OK(w, map[string]interface{}{
+		"api_keys": keys,
+		"count":    len(keys),
+	})
+}
+
+// RevokeAPIKey handles DELETE /api/v1/admin/api-keys/{id}
+// The key stops working on this instance at once and on others by their
+// next key reload.
+func (h *AdminHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !h.apiKeysAllowed(w, r) {
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		response.Error(w, apierror.BadRequest("invalid api key id"))
+		return
+	}
+
+	revoked, err := h.apiKeys.RevokeAPIKey(r.Context(), id)
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		response.Error(w, apierror.NotFound("api key not found"))
+		return
+	}
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+
+	h.recordAudit(r, "api_key.revoke", apiKeyTarget(id), map[string]interface{}{
+		"label":  revoked.Label,
+		"key_id": revoked.KeyID,
+	})
+	response.OK(w, map[string]interface{}{
+		"status":  "revoked",
+		"api_key": revoked,
+	})
+}
+
+// apiKeysAllowed checks the API key endpoints are configured and the caller
+// used an admin key that isn't scoped: scoped keys could otherwise mint keys
+// beyond their own scopes.
+func (h *AdminHandler) apiKeysAllowed(w http.ResponseWriter, r *http.Request) bool {
+	if h.apiKeys == nil {
+		componentMissing(w, "api_keys")
+		return false
+	}
+	if !middleware.IsAPIKeyAuth(r.Context()) {
+		response.Error(w, apierror.Forbidden("API key management requires a full API key"))
+		return false
+	}
+	return true
+}
+
+// apiKeyTarget is the audit target of an API key.
+func apiKeyTarget(id int64) string {
+	return fmt.Sprintf("api_key:%d", id)
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"vinzhub-rest-api/internal/domain"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// bufferedEntry describes one buffered section for admin inspection.
+type bufferedEntry struct {
+	RobloxUserID  string          `json:"roblox_user_id"`
+	Section       string          `json:"section"`
+	KeyAccountID  int64           `json:"key_account_id"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	Size          int             `json:"size"`
+	ClientVersion string          `json:"client_version,omitempty"`
+	Callback      bool            `json:"callback"`
+	Pending       bool            `json:"pending"` // In the pending queue, i.e. due to be flushed
+	Body          json.RawMessage `json:"body,omitempty"`
+}
+
+// GetBufferedEntry handles GET /api/v1/admin/buffer/{roblox_user_id}?section=&include_body=true
+// Shows what is buffered for a user's section (default section unless
+// given) and whether it is due to be flushed. 404 when nothing is buffered.
+func (h *AdminHandler) GetBufferedEntry(w http.ResponseWriter, r *http.Request) {
+	if h.redisBuffer == nil {
+		componentMissing(w, "redis_buffer")
+		return
+	}
+
+	robloxUserID := chi.URLParam(r, "roblox_user_id")
+	if !validRobloxUserID(robloxUserID) {
+		response.Error(w, errInvalidRobloxUserID)
+		return
+	}
+	section := r.URL.Query().Get("section")
+	if section == "" {
+		section = domain.DefaultSection
+	}
+
+	inv, err := h.redisBuffer.GetSection(r.Context(), robloxUserID, section)
+	if err != nil {
+		response.Error(w, apierror.ServiceUnavailable("failed to read buffer: "+err.Error()))
+		return
+	}
+	if inv == nil {
+		response.Error(w, apierror.NotFound("nothing is buffered for this section"))
+		return
+	}
+	pending, err := h.redisBuffer.IsPending(r.Context(), robloxUserID, section)
+	if err != nil {
+		response.Error(w, apierror.ServiceUnavailable("failed to read pending queue: "+err.Error()))
+		return
+	}
+
+	entry := bufferedEntry{
+		RobloxUserID:  robloxUserID,
+		Section:       inv.SectionName(),
+		KeyAccountID:  inv.KeyAccountID,
+		UpdatedAt:     inv.UpdatedAt,
+		Size:          len(inv.RawJSON),
+		ClientVersion: inv.ClientVersion,
+		Callback:      inv.Callback,
+		Pending:       pending,
+	}
+	if r.URL.Query().Get("include_body") == "true" && json.Valid(inv.RawJSON) {
+		entry.Body = inv.RawJSON
+	}
+	response.OK(w, entry)
+}
+
+// DropBufferedEntry handles DELETE /api/v1/admin/buffer/{roblox_user_id}?section=
+// Drops a user's buffered section (default section unless given) without
+// flushing it, e.g. a corrupted payload that must not be persisted.
+func (h *AdminHandler) DropBufferedEntry(w http.ResponseWriter, r *http.Request) {
+	if h.redisBuffer == nil {
+		componentMissing(w, "redis_buffer")
+		return
+	}
+
+	robloxUserID := chi.URLParam(r, "roblox_user_id")
+	if !validRobloxUserID(robloxUserID) {
+		response.Error(w, errInvalidRobloxUserID)
+		return
+	}
+	section := r.URL.Query().Get("section")
+	if section == "" {
+		section = domain.DefaultSection
+	}
+
+	deleted, err := h.redisBuffer.Drop(r.Context(), robloxUserID, section)
+	if err != nil {
+		response.Error(w, apierror.ServiceUnavailable("failed to drop buffered entry: "+err.Error()))
+		return
+	}
+
+	adminLog.InfoContext(r.Context(), "Dropped buffered entry",
+		"roblox_user_id", robloxUserID, "section", section, "deleted", deleted)
+	response.OK(w, map[string]interface{}{
+		"roblox_user_id": robloxUserID,
+		"section":        section,
+		"deleted":        deleted,
+	})
+}
+
+// ClearBuffer handles DELETE /api/v1/admin/buffer?confirm=true
+// Drops every buffered entry without flushing it.
+func (h *AdminHandler) ClearBuffer(w http.ResponseWriter, r *http.Request) {
+	if h.redisBuffer == nil {
+		componentMissing(w, "redis_buffer")
+		return
+	}
+	if r.URL.Query().Get("confirm") != "true" {
+		response.Error(w, apierror.BadRequest("?confirm=true is required"))
+		return
+	}
+
+	dropped, spooled, err := h.redisBuffer.Clear(r.Context())
+	if err != nil {
+		response.Error(w, apierror.ServiceUnavailable("failed to clear buffer: "+err.Error()))
+		return
+	}
+
+	adminLog.WarnContext(r.Context(), "Cleared inventory buffer", "dropped", dropped, "spooled_dropped", spooled)
+	response.OK(w, map[string]interface{}{
+		"dropped":         dropped,
+		"spooled_dropped": spooled,
+	})
+}
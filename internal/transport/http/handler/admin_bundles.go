@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"vinzhub-rest-api/internal/bundle"
+	"vinzhub-rest-api/internal/service"
+	"vinzhub-rest-api/internal/transport/http/middleware"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+)
+
+// maxBundleUsers bounds the users of one export request.
+const maxBundleUsers = 100000
+
+// BundleTransfer exports users to and imports them from encrypted bundles.
+type BundleTransfer interface {
+	Export(ctx context.Context, w io.Writer, robloxUserIDs []string) (*bundle.Manifest, error)
+	Import(ctx context.Context, r io.Reader, dryRun bool) (*service.BundleImportResult, error)
+}
+
+// SetBundles enables the export-bundle and import-bundle endpoints.
+func (h *AdminHandler) SetBundles(bundles BundleTransfer) {
+	h.bundles = bundles
+}
+
+// ExportBundleRequest is the body of POST /api/v1/admin/export-bundle.
+type ExportBundleRequest struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+// ExportBundle handles POST /api/v1/admin/export-bundle
+// Streams the listed users' current inventories as an encrypted bundle for
+// import-bundle on another deployment. API key only.
+func (h *AdminHandler) ExportBundle(w http.ResponseWriter, r *http.Request) {
+	if !h.bundlesAllowed(w, r) {
+		return
+	}
+
+	var req ExportBundleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, bodyError(err, apierror.BadRequest("Invalid JSON body")))
+		return
+	}
+	if len(req.UserIDs) == 0 || len(req.UserIDs) > maxBundleUsers {
+		response.Error(w, apierror.ValidationError("Invalid export",
+			apierror.FieldError{Field: "user_ids", Message: fmt.Sprintf("must list 1 to %d users", maxBundleUsers)}))
+		return
+	}
+	seen := make(map[string]bool, len(req.UserIDs))
+	ids := make([]string, 0, len(req.UserIDs))
+	for _, id := range req.UserIDs {
+		if !validRobloxUserID(id) {
+			response.Error(w, apierror.ValidationError("Invalid export",
+				apierror.FieldError{Field: "user_ids", Message: "must be numeric roblox user IDs, got " + id}))
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	// Large bundles outlive the server write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="vinzhub-%s.bundle"`, time.Now().UTC().Format("20060102-150405")))
+	w.WriteHeader(http.StatusOK)
+
+	manifest, err := h.bundles.Export(r.Context(), w, ids)
+	if err != nil {
+		// The status is already sent; the missing manifest tells the
+		// importer the bundle is incomplete
+		adminLog.ErrorContext(r.Context(), "Bundle export failed after starting", "error", err)
+		return
+	}
+
+	h.recordAudit(r, "bundle.export", fmt.Sprintf("users:%d", manifest.Users), map[string]interface{}{
+		"requested": len(ids),
+		"exported":  manifest.Users,
+		"sections":  manifest.Sections,
+		"missing":   len(manifest.Missing),
+	})
+	adminLog.InfoContext(r.Context(), "Exported bundle", "users", manifest.Users, "sections", manifest.Sections, "missing", len(manifest.Missing))
+}
+
+// ImportBundle handles POST /api/v1/admin/import-bundle?dry_run=true
+// The body is a bundle from export-bundle. It is verified in full before
+// anything is applied; each section is written only if newer than the copy
+// held here. Reports the outcome per user. API key only.
+func (h *AdminHandler) ImportBundle(w http.ResponseWriter, r *http.Request) {
+	if !h.bundlesAllowed(w, r) {
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	// Large bundles outlive the server read timeout
+	http.NewResponseController(w).SetReadDeadline(time.Time{})
+
+	result, err := h.bundles.Import(r.Context(), r.Body, dryRun)
+	if err != nil {
+		if tooLarge := limitError(r); tooLarge != nil {
+			response.Error(w, tooLarge)
+			return
+		}
+	}
+	if errors.Is(err, bundle.ErrInvalid) {
+		response.Error(w, apierror.BadRequest(err.Error()))
+		return
+	}
+	if err != nil {
+		if result != nil {
+			adminLog.ErrorContext(r.Context(), "Bundle import stopped", "imported", len(result.Outcomes), "users", result.Users, "error", err)
+		}
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+
+	h.recordAudit(r, "bundle.import", fmt.Sprintf("users:%d", result.Users), map[string]interface{}{
+		"dry_run": dryRun,
+		"source":  result.Source,
+		"counts":  result.Counts,
+	})
+	response.OK(w, result)
+}
+
+// bundlesAllowed checks the bundle endpoints are configured and the caller
+// used an API key.
+func (h *AdminHandler) bundlesAllowed(w http.ResponseWriter, r *http.Request) bool {
+	if h.bundles == nil {
+		componentMissing(w, "bundles")
+		return false
+	}
+	if !middleware.IsAdminAuth(r.Context()) {
+		response.Error(w, apierror.Forbidden("bundles require an API key"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"vinzhub-rest-api/internal/cache"
+	"vinzhub-rest-api/internal/domain"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+)
+
+// deadLetterEntry describes one dead-lettered section for admin inspection.
+type deadLetterEntry struct {
+	RobloxUserID string          `json:"roblox_user_id"`
+	Section      string          `json:"section"`
+	KeyAccountID int64           `json:"key_account_id"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	Size         int             `json:"size"`
+	Failures     int64           `json:"failures"`
+	LastError    string          `json:"last_error"`
+	DeadAt       time.Time       `json:"dead_at"`
+	Body         json.RawMessage `json:"body,omitempty"`
+}
+
+// RequeueRequest selects dead-lettered sections to flush again: one
+// user's section (default section unless given), or all of them.
+type RequeueRequest struct {
+	RobloxUserID string `json:"roblox_user_id"`
+	Section      string `json:"section"`
+	All          bool   `json:"all"`
+}
+
+// GetDeadLetters handles GET /api/v1/admin/deadletter?include_body=true
+// Lists buffered entries taken out of the flush after failing repeatedly,
+// most recent first.
+func (h *AdminHandler) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if h.redisBuffer == nil {
+		componentMissing(w, "redis_buffer")
+		return
+	}
+
+	letters, err := h.redisBuffer.DeadLetters(r.Context())
+	if err != nil {
+		response.Error(w, apierror.ServiceUnavailable("failed to read dead letters: "+err.Error()))
+		return
+	}
+	sort.Slice(letters, func(i, j int) bool { return letters[i].DeadAt.After(letters[j].DeadAt) })
+
+	includeBody := r.URL.Query().Get("include_body") == "true"
+	entries := make([]deadLetterEntry, 0, len(letters))
+	for _, letter := range letters {
+		if letter.Entry == nil {
+			continue
+		}
+		entry := deadLetterEntry{
+			RobloxUserID: letter.Entry.RobloxUserID,
+			Section:      letter.Entry.SectionName(),
+			KeyAccountID: letter.Entry.KeyAccountID,
+			UpdatedAt:    letter.Entry.UpdatedAt,
+			Size:         len(letter.Entry.RawJSON),
+			Failures:     letter.Failures,
+			LastError:    letter.LastError,
+			DeadAt:       letter.DeadAt,
+		}
+		if includeBody && json.Valid(letter.Entry.RawJSON) {
+			entry.Body = letter.Entry.RawJSON
+		}
+		entries = append(entries, entry)
+	}
+	response.OK(w, map[string]interface{}{
+		"count":   len(entries),
+		"entries": entries,
+	})
+}
+
+// RequeueDeadLetters handles POST /api/v1/admin/deadletter
+// Moves dead-lettered sections back into the buffer with a fresh failure
+// count. A section rewritten by a newer sync meanwhile keeps the newer
+// write and its dead letter is dropped.
+func (h *AdminHandler) RequeueDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if h.redisBuffer == nil {
+		componentMissing(w, "redis_buffer")
+		return
+	}
+
+	var req RequeueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, bodyError(err, apierror.BadRequest("Invalid JSON body")))
+		return
+	}
+	if req.All == (req.RobloxUserID != "") {
+		response.Error(w, apierror.BadRequest("give either roblox_user_id or all"))
+		return
+	}
+
+	var targets [][2]string
+	if req.All {
+		letters, err := h.redisBuffer.DeadLetters(r.Context())
+		if err != nil {
+			response.Error(w, apierror.ServiceUnavailable("failed to read dead letters: "+err.Error()))
+			return
+		}
+		for _, letter := range letters {
+			if letter.Entry != nil {
+				targets = append(targets, [2]string{letter.Entry.RobloxUserID, letter.Entry.SectionName()})
+			}
+		}
+	} else {
+		section := req.Section
+		if section == "" {
+			section = domain.DefaultSection
+		}
+		targets = append(targets, [2]string{req.RobloxUserID, section})
+	}
+
+	requeued, superseded := 0, 0
+	for _, target := range targets {
+		ok, err := h.redisBuffer.Requeue(r.Context(), target[0], target[1])
+		switch {
+		case errors.Is(err, cache.ErrNotDeadLettered):
+			if !req.All {
+				response.Error(w, apierror.NotFound("this section is not dead-lettered"))
+				return
+			}
+		case err != nil:
+			response.Error(w, apierror.ServiceUnavailable("failed to requeue: "+err.Error()))
+			return
+		case ok:
+			requeued++
+		default:
+			superseded++
+		}
+	}
+
+	target := req.RobloxUserID
+	if req.All {
+		target = "all"
+	}
+	adminLog.InfoContext(r.Context(), "Requeued dead letters", "target", target, "requeued", requeued, "superseded", superseded)
+	h.recordAudit(r, "deadletter.requeue", target, map[string]interface{}{
+		"requeued":   requeued,
+		"superseded": superseded,
+	})
+	response.OK(w, map[string]interface{}{
+		"requeued":   requeued,
+		"superseded": superseded,
+	})
+}
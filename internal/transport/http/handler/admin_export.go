@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"vinzhub-rest-api/internal/domain"
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/transport/http/middleware"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+)
+
+// exportLine is one NDJSON line of GET /api/v1/admin/export.
+type exportLine struct {
+	RobloxUserID string          `json:"roblox_user_id"`
+	Section      string          `json:"section"`
+	KeyAccountID int64           `json:"key_account_id"`
+	SyncedAt     time.Time       `json:"synced_at"`
+	Inventory    json.RawMessage `json:"inventory"`
+}
+
+// ExportInventories handles GET /api/v1/admin/export?since=2024-01-01T00:00:00Z
+// Streams every stored section as newline-delimited JSON, read from the
+// database page by page. since exports only sections synced at or after it.
+// API key only.
+func (h *AdminHandler) ExportInventories(w http.ResponseWriter, r *http.Request) {
+	if h.sqliteRepo == nil {
+		componentMissing(w, "sqlite")
+		return
+	}
+	if !middleware.IsAdminAuth(r.Context()) {
+		response.Error(w, apierror.Forbidden("export requires an API key"))
+		return
+	}
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			response.Error(w, apierror.ValidationError("Invalid export",
+				apierror.FieldError{Field: "since", Message: "must be an RFC3339 timestamp"}))
+			return
+		}
+		since = t.UTC()
+	}
+
+	// Large exports outlive the server write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="vinzhub-inventories-%s.ndjson"`, time.Now().UTC().Format("20060102-150405")))
+	w.WriteHeader(http.StatusOK)
+
+	out := bufio.NewWriterSize(w, 64<<10)
+	enc := json.NewEncoder(out)
+	var exported, skipped int64
+	err := h.sqliteRepo.ScanSince(r.Context(), since, func(item repository.InventoryItem) error {
+		if !json.Valid(item.RawJSON) {
+			skipped++
+			return nil
+		}
+		exported++
+		return enc.Encode(exportLine{
+			RobloxUserID: item.RobloxUserID,
+			Section:      item.Section,
+			KeyAccountID: item.KeyAccountID,
+			SyncedAt:     item.SyncedAt,
+			Inventory:    item.RawJSON,
+		})
+	})
+	if err == nil {
+		err = out.Flush()
+	}
+	if err != nil {
+		// The status is already sent; the cut-off stream is all the
+		// client sees
+		adminLog.ErrorContext(r.Context(), "Export failed after starting", "exported", exported, "error", err)
+		return
+	}
+
+	h.recordAudit(r, "inventory.export", fmt.Sprintf("sections:%d", exported), map[string]interface{}{
+		"since":   since,
+		"skipped": skipped,
+	})
+	adminLog.InfoContext(r.Context(), "Exported inventories", "sections", exported, "skipped_invalid", skipped, "since", since)
+}
+
+const (
+	// importChunkSize is the rows upserted per batch by ImportInventories.
+	importChunkSize = 500
+	// maxImportLineBytes bounds one NDJSON line of an import.
+	maxImportLineBytes = 16 << 20
+	// maxImportErrors bounds the line errors listed in an import result;
+	// further ones are only counted.
+	maxImportErrors = 1000
+)
+
+// importLineError reports a rejected line of an import.
+type importLineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// importResult is the response of POST /api/v1/admin/import.
+type importResult struct {
+	Lines    int                    `json:"lines"`
+	Imported int                    `json:"imported"`
+	Rejected int                    `json:"rejected"`
+	Stats    repository.UpsertStats `json:"stats"`
+	Errors   []importLineError      `json:"errors,omitempty"`
+	// Aborted is set when strict mode stopped at a bad line; the lines
+	// before it are still imported
+	Aborted bool `json:"aborted,omitempty"`
+}
+
+// reject records a bad line.
+func (res *importResult) reject(line int, msg string) {
+	res.Rejected++
+	if len(res.Errors) < maxImportErrors {
+		res.Errors = append(res.Errors, importLineError{Line: line, Error: msg})
+	}
+}
+
+// ImportInventories handles POST /api/v1/admin/import?strict=true
+// The body is NDJSON as written by export. Lines are upserted in chunks;
+// a row older than the stored one is skipped. Bad lines are reported with
+// their line number and skipped, or stop the import with strict. API key
+// only.
+func (h *AdminHandler) ImportInventories(w http.ResponseWriter, r *http.Request) {
+	if h.sqliteRepo == nil {
+		componentMissing(w, "sqlite")
+		return
+	}
+	if !middleware.IsAdminAuth(r.Context()) {
+		response.Error(w, apierror.Forbidden("import requires an API key"))
+		return
+	}
+	strict := r.URL.Query().Get("strict") == "true"
+
+	// Large imports outlive the server read timeout
+	http.NewResponseController(w).SetReadDeadline(time.Time{})
+
+	result := &importResult{}
+	chunk := make([]repository.InventoryItem, 0, importChunkSize)
+	write := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		stats, err := h.sqliteRepo.BatchUpsertRawInventoryStats(r.Context(), chunk)
+		if err != nil {
+			return err
+		}
+		result.Stats.Add(stats)
+		result.Imported += len(chunk)
+		chunk = chunk[:0]
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxImportLineBytes)
+	for scanner.Scan() {
+		result.Lines++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		var line exportLine
+		switch err := json.Unmarshal(raw, &line); {
+		case err != nil:
+			result.reject(result.Lines, "invalid JSON: "+err.Error())
+		case line.RobloxUserID == "":
+			result.reject(result.Lines, "roblox_user_id is required")
+		case len(line.Inventory) == 0 || string(line.Inventory) == "null":
+			result.reject(result.Lines, "inventory is required")
+		default:
+			if line.Section == "" {
+				line.Section = domain.DefaultSection
+			}
+			if line.SyncedAt.IsZero() {
+				line.SyncedAt = time.Now().UTC()
+			}
+			chunk = append(chunk, repository.InventoryItem{
+				KeyAccountID: line.KeyAccountID,
+				RobloxUserID: line.RobloxUserID,
+				Section:      line.Section,
+				RawJSON:      append([]byte(nil), line.Inventory...),
+				SyncedAt:     line.SyncedAt,
+				ItemCount:    -1,
+			})
+			if len(chunk) == importChunkSize {
+				if err := write(); err != nil {
+					h.importFailed(w, r, result, err)
+					return
+				}
+			}
+			continue
+		}
+		if strict {
+			result.Aborted = true
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if tooLarge := limitError(r); tooLarge != nil {
+			response.Error(w, tooLarge)
+			return
+		}
+		if errors.Is(err, bufio.ErrTooLong) {
+			err = fmt.Errorf("line %d is longer than %d bytes", result.Lines+1, maxImportLineBytes)
+		}
+		response.Error(w, apierror.BadRequest(fmt.Sprintf("import stopped after %d rows: %v", result.Imported, err)))
+		return
+	}
+	if err := write(); err != nil {
+		h.importFailed(w, r, result, err)
+		return
+	}
+
+	h.recordAudit(r, "inventory.import", fmt.Sprintf("rows:%d", result.Imported), map[string]interface{}{
+		"lines":    result.Lines,
+		"rejected": result.Rejected,
+		"strict":   strict,
+		"aborted":  result.Aborted,
+	})
+	adminLog.InfoContext(r.Context(), "Imported inventories", "lines", result.Lines, "imported", result.Imported,
+		"rejected", result.Rejected, "aborted", result.Aborted)
+	response.OK(w, result)
+}
+
+// importFailed answers an import whose write failed part way.
+func (h *AdminHandler) importFailed(w http.ResponseWriter, r *http.Request, result *importResult, err error) {
+	adminLog.ErrorContext(r.Context(), "Import stopped", "imported", result.Imported, "lines", result.Lines, "error", err)
+	response.Error(w, apierror.InternalError(fmt.Sprintf("import stopped after %d rows: %v", result.Imported, err)))
+}
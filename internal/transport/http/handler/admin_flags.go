@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+)
+
+// InventoryFlagReader lists inventories flagged by the inventory rules.
+type InventoryFlagReader interface {
+	StatsProvider
+	Flags(ctx context.Context, rule, robloxUserID string, page repository.PageQuery) ([]repository.InventoryFlag, error)
+}
+
+// SetInventoryRules enables GET /api/v1/admin/flags/inventory.
+func (h *AdminHandler) SetInventoryRules(rules InventoryFlagReader) {
+	h.inventoryRules = rules
+}
+
+// GetInventoryFlags handles GET /api/v1/admin/flags/inventory
+// Lists inventories that broke an inventory rule, newest first, with
+// ?rule= and ?roblox_user_id= filters and cursor pagination.
+func (h *AdminHandler) GetInventoryFlags(w http.ResponseWriter, r *http.Request) {
+	if h.inventoryRules == nil {
+		componentMissing(w, "inventory_rules")
+		return
+	}
+
+	page, ok := pageRequest(w, r, "inventory_flags", 100)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	flags, err := h.inventoryRules.Flags(r.Context(), q.Get("rule"), q.Get("roblox_user_id"), page)
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+	n, next := nextPage(len(flags), page, "inventory_flags", func(i int) int64 { return flags[i].ID })
+
+	response.OK(w, map[string]interface{}{
+		"flags":       flags[:n],
+		"next_cursor": next,
+		"rules":       h.inventoryRules.Stats(r.Context()),
+	})
+}
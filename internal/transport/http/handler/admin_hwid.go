@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/transport/http/middleware"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// auditHWIDReset is the audit action of an HWID reset; the cooldown is
+// enforced from these entries.
+const auditHWIDReset = "key_account.reset_hwid"
+
+// SetHWIDReset enables POST /api/v1/admin/accounts/{key_account_id}/reset-hwid,
+// allowing one reset per account per cooldown (0 = no cooldown).
+func (h *AdminHandler) SetHWIDReset(resetter repository.KeyAccountHWIDResetter, cooldown time.Duration) {
+	h.hwidResets = resetter
+	h.hwidCooldown = cooldown
+}
+
+// ResetHWIDRequest is the body of POST .../reset-hwid.
+type ResetHWIDRequest struct {
+	Reason string `json:"reason"`
+	Admin  string `json:"admin"` // Optional name of the admin; defaults to the caller
+}
+
+// ResetHWID handles POST /api/v1/admin/accounts/{key_account_id}/reset-hwid
+// Unlocks a key account from its HWID (e.g. after a Windows reinstall) and
+// revokes its sessions, so the next token request binds the new machine.
+// API key only, and at most once per account per HWID_RESET_COOLDOWN.
+func (h *AdminHandler) ResetHWID(w http.ResponseWriter, r *http.Request) {
+	if h.hwidResets == nil {
+		componentMissing(w, "key_accounts")
+		return
+	}
+	if h.audit == nil {
+		componentMissing(w, "audit_log")
+		return
+	}
+	if !middleware.IsAdminAuth(r.Context()) {
+		response.Error(w, apierror.Forbidden("HWID resets require an API key"))
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "key_account_id"), 10, 64)
+	if err != nil || id <= 0 {
+		response.Error(w, apierror.BadRequest("invalid key account id"))
+		return
+	}
+
+	var req ResetHWIDRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, bodyError(err, apierror.BadRequest("Invalid JSON body")))
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" || len(req.Reason) > 500 {
+		response.Error(w, apierror.ValidationError("Invalid HWID reset",
+			apierror.FieldError{Field: "reason", Message: "required, at most 500 characters"}))
+		return
+	}
+
+	if h.hwidCooldown > 0 {
+		last, err := h.lastHWIDReset(r.Context(), id, time.Now().Add(-h.hwidCooldown))
+		if err != nil {
+			response.Error(w, apierror.InternalError(err.Error()))
+			return
+		}
+		if !last.IsZero() {
+			next := last.Add(h.hwidCooldown)
+			w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(next).Seconds())+1))
+			response.Error(w, apierror.TooManyRequests("HWID was reset recently; next reset allowed at "+next.UTC().Format(time.RFC3339)))
+			return
+		}
+	}
+
+	previous, err := h.hwidResets.ResetHWID(r.Context(), id)
+	if err != nil {
+		keyAccountError(w, err)
+		return
+	}
+
+	// The HWID is already unlocked, so the reset is recorded either way
+	revoked := 0
+	var revokeErr error
+	if h.sessions != nil {
+		revoked, revokeErr = h.sessions.RevokeOtherSessions(r.Context(), id, "")
+	}
+
+	admin := auditActor(r)
+	if name := strings.TrimSpace(req.Admin); name != "" {
+		admin += "/" + name
+	}
+	detail := map[string]interface{}{
+		"admin":            admin,
+		"reason":           req.Reason,
+		"previous_hwid":    previous,
+		"sessions_revoked": revoked,
+	}
+	if revokeErr != nil {
+		detail["revoke_error"] = revokeErr.Error()
+	}
+	h.recordAudit(r, auditHWIDReset, keyAccountTarget(id), detail)
+
+	if revokeErr != nil {
+		adminLog.ErrorContext(r.Context(), "HWID reset but revoking sessions failed", "key_account_id", id, "error", revokeErr)
+		response.Error(w, apierror.InternalError("hwid reset, but revoking sessions failed: "+revokeErr.Error()))
+		return
+	}
+	adminLog.InfoContext(r.Context(), "Reset key account HWID", "key_account_id", id, "admin", admin, "sessions_revoked", revoked)
+	response.OK(w, map[string]interface{}{
+		"key_account_id":   id,
+		"previous_hwid":    previous,
+		"sessions_revoked": revoked,
+	})
+}
+
+// lastHWIDReset returns when the account's HWID was last reset after since,
+// or the zero time if it wasn't.
+func (h *AdminHandler) lastHWIDReset(ctx context.Context, id int64, since time.Time) (time.Time, error) {
+	page := repository.PageQuery{Limit: 100}
+	for {
+		entries, err := h.audit.ListAudit(ctx, keyAccountTarget(id), page)
+		if err != nil {
+			return time.Time{}, err
+		}
+		for _, entry := range entries {
+			if entry.At.Before(since) {
+				return time.Time{}, nil // Newest first: the rest are older
+			}
+			if entry.Action == auditHWIDReset {
+				return entry.At, nil
+			}
+		}
+		if len(entries) < page.Limit {
+			return time.Time{}, nil
+		}
+		page.BeforeID = entries[len(entries)-1].ID
+	}
+}
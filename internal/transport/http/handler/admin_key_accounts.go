@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/service"
+	"vinzhub-rest-api/internal/transport/http/middleware"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AuditRecorder records audit entries.
+type AuditRecorder interface {
+	InsertAudit(ctx context.Context, entry *repository.AuditEntry, detail interface{}) error
+}
+
+// AuditLog records and lists administrative changes.
+type AuditLog interface {
+	AuditRecorder
+	ListAudit(ctx context.Context, target string, page repository.PageQuery) ([]repository.AuditEntry, error)
+}
+
+// KeyAccountCacheInvalidator drops cached key-account lookups.
+type KeyAccountCacheInvalidator interface {
+	InvalidateKeyAccount(ctx context.Context, robloxUserID string)
+}
+
+// SetKeyAccountProvisioning enables the key-account provisioning endpoints.
+// invalidator may be nil.
+func (h *AdminHandler) SetKeyAccountProvisioning(provisioner repository.KeyAccountProvisioner, invalidator KeyAccountCacheInvalidator) {
+	h.keyAccounts = provisioner
+	h.keyAccountCache = invalidator
+}
+
+// SetAuditLog attaches the audit log administrative changes are recorded in.
+func (h *AdminHandler) SetAuditLog(audit AuditLog) {
+	h.audit = audit
+}
+
+// CreateKeyAccountRequest is the body of POST /api/v1/admin/key-accounts.
+type CreateKeyAccountRequest struct {
+	Key            string `json:"key"` // Existing license key to link
+	RobloxUserID   string `json:"roblox_user_id"`
+	RobloxUsername string `json:"roblox_username"`
+}
+
+// CreateKeyAccount handles POST /api/v1/admin/key-accounts
+// Creates a key account linked to an existing, unlinked license key.
+// API key only - used by the license shop.
+func (h *AdminHandler) CreateKeyAccount(w http.ResponseWriter, r *http.Request) {
+	if !h.provisioningAllowed(w, r) {
+		return
+	}
+
+	var req CreateKeyAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, bodyError(err, apierror.BadRequest("Invalid JSON body")))
+		return
+	}
+	req.Key = strings.TrimSpace(req.Key)
+	var details []apierror.FieldError
+	if req.Key == "" {
+		details = append(details, apierror.FieldError{Field: "key", Message: "is required"})
+	}
+	if !validRobloxUserID(req.RobloxUserID) {
+		details = append(details, apierror.FieldError{Field: "roblox_user_id", Message: "must be a numeric roblox user ID"})
+	}
+	if len(details) > 0 {
+		response.Error(w, apierror.ValidationError("Invalid key account", details...))
+		return
+	}
+
+	account, err := h.keyAccounts.CreateLinkedKeyAccount(r.Context(), req.Key, req.RobloxUserID, req.RobloxUsername)
+	if err != nil {
+		keyAccountError(w, err)
+		return
+	}
+
+	h.invalidateKeyAccount(r.Context(), account.RobloxUserID)
+	h.recordAudit(r, "key_account.create", keyAccountTarget(account.ID), map[string]interface{}{"after": account})
+	adminLog.InfoContext(r.Context(), "Created key account", "key_account_id", account.ID, "key_id", account.KeyID, "roblox_user_id", account.RobloxUserID)
+	response.Created(w, account)
+}
+
+// UpdateKeyAccount handles PUT /api/v1/admin/key-accounts/{id}
+// Changes roblox_user_id, roblox_username and/or is_active.
+func (h *AdminHandler) UpdateKeyAccount(w http.ResponseWriter, r *http.Request) {
+	if !h.provisioningAllowed(w, r) {
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		response.Error(w, apierror.BadRequest("invalid key account id"))
+		return
+	}
+
+	var update repository.KeyAccountUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		response.Error(w, bodyError(err, apierror.BadRequest("Invalid JSON body")))
+		return
+	}
+	if update.RobloxUserID == nil && update.RobloxUsername == nil && update.IsActive == nil {
+		response.Error(w, apierror.BadRequest("nothing to update: set roblox_user_id, roblox_username or is_active"))
+		return
+	}
+	if update.RobloxUserID != nil && !validRobloxUserID(*update.RobloxUserID) {
+		response.Error(w, apierror.ValidationError("Invalid key account",
+			apierror.FieldError{Field: "roblox_user_id", Message: "must be a numeric roblox user ID"}))
+		return
+	}
+
+	before, after, err := h.keyAccounts.UpdateKeyAccount(r.Context(), id, update)
+	if err != nil {
+		keyAccountError(w, err)
+		return
+	}
+
+	h.invalidateKeyAccount(r.Context(), before.RobloxUserID)
+	if after.RobloxUserID != before.RobloxUserID {
+		h.invalidateKeyAccount(r.Context(), after.RobloxUserID)
+	}
+	h.recordAudit(r, "key_account.update", keyAccountTarget(id), map[string]interface{}{"before": before, "after": after})
+	adminLog.InfoContext(r.Context(), "Updated key account", "key_account_id", id)
+	response.OK(w, after)
+}
+
+// GetAuditLog handles GET /api/v1/admin/audit?target=key_account:42&limit=100&cursor=...
+func (h *AdminHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	if h.audit == nil {
+		componentMissing(w, "audit_log")
+		return
+	}
+
+	page, ok := pageRequest(w, r, "audit", 100)
+	if !ok {
+		return
+	}
+
+	entries, err := h.audit.ListAudit(r.Context(), r.URL.Query().Get("target"), page)
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+	n, next := nextPage(len(entries), page, "audit", func(i int) int64 { return entries[i].ID })
+
+	response.OK(w, map[string]interface{}{
+		"entries":     entries[:n],
+		"next_cursor": next,
+	})
+}
+
+// provisioningAllowed checks the provisioning endpoints are configured and
+// the caller used an API key (session tokens belong to players).
+func (h *AdminHandler) provisioningAllowed(w http.ResponseWriter, r *http.Request) bool {
+	if h.keyAccounts == nil {
+		componentMissing(w, "key_accounts")
+		return false
+	}
+	if !middleware.IsAdminAuth(r.Context()) {
+		response.Error(w, apierror.Forbidden("key account provisioning requires an API key"))
+		return false
+	}
+	return true
+}
+
+// invalidateKeyAccount drops the cached lookup of a roblox user.
+func (h *AdminHandler) invalidateKeyAccount(ctx context.Context, robloxUserID string) {
+	if h.keyAccountCache != nil && robloxUserID != "" {
+		h.keyAccountCache.InvalidateKeyAccount(ctx, robloxUserID)
+	}
+}
+
+// recordAudit writes an audit entry. The audited action already happened,
+// so a failure is logged rather than returned.
+func (h *AdminHandler) recordAudit(r *http.Request, action, target string, detail interface{}) {
+	if h.audit == nil {
+		return
+	}
+	entry := &repository.AuditEntry{
+		Actor:     auditActor(r),
+		Action:    action,
+		Target:    target,
+		RequestID: middleware.GetRequestID(r.Context()),
+	}
+	if err := h.audit.InsertAudit(r.Context(), entry, detail); err != nil {
+		adminLog.ErrorContext(r.Context(), "ALERT: failed to record audit entry", "action", action, "target", entry.Target, "error", err)
+	}
+}
+
+// keyAccountTarget is the audit target of a key account.
+func keyAccountTarget(id int64) string {
+	return fmt.Sprintf("key_account:%d", id)
+}
+
+// auditActor describes who made a request.
+func auditActor(r *http.Request) string {
+	if data := middleware.GetTokenDataFromContext(r.Context()); data != nil {
+		if data.Restricted() {
+			return "support:" + data.IssuedBy
+		}
+		return fmt.Sprintf("token:%d", data.KeyAccountID)
+	}
+	if middleware.IsAPIKeyAuth(r.Context()) {
+		return "api_key"
+	}
+	if key := middleware.GetScopedKeyFromContext(r.Context()); key != nil {
+		return "scoped_key:" + key.ID
+	}
+	return "anonymous"
+}
+
+// keyAccountError maps provisioning errors to responses.
+func keyAccountError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrKeyNotFound):
+		response.Error(w, apierror.NotFound("license key not found"))
+	case errors.Is(err, repository.ErrKeyAccountNotFound):
+		response.Error(w, apierror.NotFound("key account not found"))
+	case errors.Is(err, repository.ErrKeyAlreadyLinked):
+		response.Error(w, apierror.Conflict(err.Error()))
+	case errors.Is(err, repository.ErrRobloxUserLinked):
+		response.Error(w, apierror.Conflict(err.Error()))
+	default:
+		response.Error(w, apierror.InternalError(err.Error()))
+	}
+}
+
+// errInvalidRobloxUserID refuses a roblox_user_id path parameter that isn't
+// numeric.
+var errInvalidRobloxUserID = apierror.BadRequest("roblox_user_id must be a numeric roblox user ID")
+
+// validRobloxUserID reports whether id looks like a roblox user ID.
+func validRobloxUserID(id string) bool {
+	return service.ValidRobloxUserID(id)
+}
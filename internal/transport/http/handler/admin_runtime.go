@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"vinzhub-rest-api/internal/transport/http/response"
+)
+
+// GetHeap handles GET /api/v1/admin/heap
+// A cheap allocation and GC snapshot for the dashboard to poll; take a heap
+// profile from /debug/pprof/heap to see what is allocating. Counters are
+// cumulative, so rates come from the difference between two polls.
+func (h *AdminHandler) GetHeap(w http.ResponseWriter, r *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	var lastGC interface{}
+	if m.LastGC > 0 {
+		lastGC = time.Unix(0, int64(m.LastGC)).UTC().Format(time.RFC3339Nano)
+	}
+	response.OK(w, map[string]interface{}{
+		"taken_at":            time.Now().UTC().Format(time.RFC3339Nano),
+		"gomaxprocs":          runtime.GOMAXPROCS(0),
+		"goroutines":          runtime.NumGoroutine(),
+		"heap_alloc_bytes":    m.HeapAlloc,
+		"heap_inuse_bytes":    m.HeapInuse,
+		"heap_idle_bytes":     m.HeapIdle,
+		"heap_released_bytes": m.HeapReleased,
+		"heap_objects":        m.HeapObjects,
+		"sys_bytes":           m.Sys,
+		"total_alloc_bytes":   m.TotalAlloc,
+		"mallocs":             m.Mallocs,
+		"frees":               m.Frees,
+		"next_gc_bytes":       m.NextGC,
+		"num_gc":              m.NumGC,
+		"last_gc":             lastGC,
+		"last_gc_pause_ns":    m.PauseNs[(m.NumGC+255)%256],
+		"gc_pause_total_ns":   m.PauseTotalNs,
+		"gc_cpu_fraction":     m.GCCPUFraction,
+	})
+}
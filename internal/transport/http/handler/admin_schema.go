@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"vinzhub-rest-api/internal/service"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+)
+
+// SchemaReporter compares sampled payload schemas between days.
+type SchemaReporter interface {
+	StatsProvider
+	Report(ctx context.Context, from, to, clientVersion string) (*service.SchemaReport, error)
+}
+
+// SetSchemaProfiler enables GET /api/v1/admin/schema-report.
+func (h *AdminHandler) SetSchemaProfiler(profiler SchemaReporter) {
+	h.schema = profiler
+}
+
+// GetSchemaReport handles GET /api/v1/admin/schema-report?compare=2024-06-01,2024-06-08
+// Lists payload paths that appeared, disappeared, changed type or stopped
+// being ubiquitous between two days. compare defaults to a week ago vs
+// today; ?client_version= limits both days to one client version.
+func (h *AdminHandler) GetSchemaReport(w http.ResponseWriter, r *http.Request) {
+	if h.schema == nil {
+		componentMissing(w, "schema_profiler")
+		return
+	}
+
+	now := time.Now().UTC()
+	from, to := now.AddDate(0, 0, -7).Format("2006-01-02"), now.Format("2006-01-02")
+	if compare := r.URL.Query().Get("compare"); compare != "" {
+		days := strings.Split(compare, ",")
+		if len(days) != 2 {
+			response.Error(w, apierror.BadRequest("compare must be two days, e.g. 2024-06-01,2024-06-08"))
+			return
+		}
+		from, to = strings.TrimSpace(days[0]), strings.TrimSpace(days[1])
+	}
+
+	report, err := h.schema.Report(r.Context(), from, to, r.URL.Query().Get("client_version"))
+	if err != nil {
+		response.Error(w, apierror.BadRequest(err.Error()))
+		return
+	}
+	response.OK(w, report)
+}
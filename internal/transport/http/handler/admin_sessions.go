@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"vinzhub-rest-api/internal/service"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AccountSessions lists and revokes the sessions of key accounts.
+type AccountSessions interface {
+	ListSessions(ctx context.Context, keyAccountID int64) ([]service.Session, error)
+	RevokeOtherSessions(ctx context.Context, keyAccountID int64, keepSessionID string) (int, error)
+}
+
+// SetAccountSessions enables GET /api/v1/admin/accounts/{key_account_id}/sessions.
+func (h *AdminHandler) SetAccountSessions(sessions AccountSessions) {
+	h.sessions = sessions
+}
+
+// GetAccountSessions handles GET /api/v1/admin/accounts/{key_account_id}/sessions
+// Lists the active sessions of a key account - devices it is logged in
+// from - oldest first, with ?limit and cursor pagination.
+func (h *AdminHandler) GetAccountSessions(w http.ResponseWriter, r *http.Request) {
+	if h.sessions == nil {
+		componentMissing(w, "token_service")
+		return
+	}
+
+	keyAccountID, err := strconv.ParseInt(chi.URLParam(r, "key_account_id"), 10, 64)
+	if err != nil || keyAccountID <= 0 {
+		response.Error(w, apierror.BadRequest("key_account_id must be a positive integer"))
+		return
+	}
+
+	scope := "account_sessions:" + strconv.FormatInt(keyAccountID, 10)
+	q := r.URL.Query()
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxPageLimit {
+			response.Error(w, apierror.BadRequest("limit must be between 1 and 1000"))
+			return
+		}
+		limit = n
+	}
+	var after *response.Cursor
+	if token := q.Get("cursor"); token != "" {
+		after, err = response.DecodeCursor(scope, token)
+		if errors.Is(err, response.ErrCursorExpired) {
+			response.Error(w, apierror.BadRequest("cursor expired - restart from the first page"))
+			return
+		}
+		if err != nil {
+			response.Error(w, apierror.BadRequest("invalid cursor"))
+			return
+		}
+	}
+
+	sessions, err := h.sessions.ListSessions(r.Context(), keyAccountID)
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+	total := len(sessions)
+
+	// Sessions are ordered by creation time, then session ID; the cursor
+	// holds both of the last session returned
+	if after != nil {
+		createdAt := time.Unix(0, after.ID)
+		start := 0
+		for start < len(sessions) {
+			s := sessions[start]
+			if s.CreatedAt.After(createdAt) || (s.CreatedAt.Equal(createdAt) && s.SessionID > after.SortKey) {
+				break
+			}
+			start++
+		}
+		sessions = sessions[start:]
+	}
+	next := ""
+	if len(sessions) > limit {
+		sessions = sessions[:limit]
+		last := sessions[limit-1]
+		next = response.EncodeCursor(scope, last.SessionID, last.CreatedAt.UnixNano())
+	}
+
+	response.OK(w, map[string]interface{}{
+		"key_account_id": keyAccountID,
+		"sessions":       sessions,
+		"count":          total,
+		"next_cursor":    next,
+	})
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/transport/http/middleware"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+)
+
+// maxSQLQueryLength caps the query text accepted by the SQL console.
+const maxSQLQueryLength = 10000
+
+// SQLConsole runs read-only ad-hoc queries.
+type SQLConsole interface {
+	Query(ctx context.Context, query string) (*repository.QueryResult, error)
+}
+
+// SetSQLConsole enables POST /api/v1/admin/sql. Leave unset to disable it.
+func (h *AdminHandler) SetSQLConsole(console SQLConsole) {
+	h.sqlConsole = console
+}
+
+// SQLQueryRequest is the body of POST /api/v1/admin/sql.
+type SQLQueryRequest struct {
+	Query string `json:"query"`
+}
+
+// RunSQL handles POST /api/v1/admin/sql
+// Runs a single read-only SELECT against the primary SQLite database.
+// API key only; every query is recorded in the audit log.
+func (h *AdminHandler) RunSQL(w http.ResponseWriter, r *http.Request) {
+	if h.sqlConsole == nil {
+		componentMissing(w, "sql_console")
+		return
+	}
+	if !middleware.IsAdminAuth(r.Context()) {
+		response.Error(w, apierror.Forbidden("the SQL console requires an API key"))
+		return
+	}
+
+	var req SQLQueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, bodyError(err, apierror.BadRequest("Invalid JSON body")))
+		return
+	}
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" || len(req.Query) > maxSQLQueryLength {
+		response.Error(w, apierror.ValidationError("Invalid query",
+			apierror.FieldError{Field: "query", Message: "must be between 1 and 10000 characters"}))
+		return
+	}
+
+	result, err := h.sqlConsole.Query(r.Context(), req.Query)
+
+	detail := map[string]interface{}{"query": req.Query}
+	if err != nil {
+		detail["error"] = err.Error()
+	} else {
+		detail["rows"] = result.RowCount
+		detail["duration_ms"] = result.DurationMs
+	}
+	h.recordAudit(r, "sql.query", "sqlite:"+repository.PrimaryDBName, detail)
+	adminLog.InfoContext(r.Context(), "SQL console query", "actor", auditActor(r), "query", req.Query)
+
+	switch {
+	case errors.Is(err, repository.ErrQueryNotAllowed):
+		response.Error(w, apierror.BadRequest(err.Error()))
+	case errors.Is(err, context.DeadlineExceeded):
+		response.Error(w, apierror.BadRequest("query exceeded the statement timeout"))
+	case err != nil:
+		response.Error(w, apierror.BadRequest(err.Error()))
+	default:
+		response.OK(w, result)
+	}
+}
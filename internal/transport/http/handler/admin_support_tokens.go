@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"vinzhub-rest-api/internal/service"
+	"vinzhub-rest-api/internal/transport/http/middleware"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SupportTokenIssuer mints, lists and revokes support tokens.
+type SupportTokenIssuer interface {
+	GenerateSupportToken(ctx context.Context, robloxUserID, issuedBy string, ttl time.Duration) (string, *service.TokenData, error)
+	ListSupportTokens(ctx context.Context) ([]service.SupportToken, error)
+	RevokeSupportToken(ctx context.Context, sessionID string) (bool, error)
+}
+
+// SetSupportTokens enables the support token endpoints.
+func (h *AdminHandler) SetSupportTokens(issuer SupportTokenIssuer) {
+	h.supportTokens = issuer
+}
+
+// CreateSupportTokenRequest is the body of POST /api/v1/admin/support-tokens.
+type CreateSupportTokenRequest struct {
+	RobloxUserID string `json:"roblox_user_id"`
+	TTL          string `json:"ttl"`       // Go duration, default 30m, at most 8h
+	IssuedBy     string `json:"issued_by"` // Optional name of the admin; defaults to the caller
+}
+
+// CreateSupportToken handles POST /api/v1/admin/support-tokens
+// Mints a token that can only read one user's inventory (X-Token header)
+// until it expires. API key only.
+func (h *AdminHandler) CreateSupportToken(w http.ResponseWriter, r *http.Request) {
+	if !h.supportTokensAllowed(w, r) {
+		return
+	}
+
+	var req CreateSupportTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, bodyError(err, apierror.BadRequest("Invalid JSON body")))
+		return
+	}
+
+	var details []apierror.FieldError
+	if !validRobloxUserID(req.RobloxUserID) {
+		details = append(details, apierror.FieldError{Field: "roblox_user_id", Message: "must be a numeric roblox user ID"})
+	}
+	ttl := service.DefaultSupportTokenTTL
+	if req.TTL != "" {
+		parsed, err := time.ParseDuration(req.TTL)
+		if err != nil || parsed < time.Minute || parsed > service.MaxSupportTokenTTL {
+			details = append(details, apierror.FieldError{Field: "ttl", Message: service.ErrInvalidTokenTTL.Error()})
+		}
+		ttl = parsed
+	}
+	if len(details) > 0 {
+		response.Error(w, apierror.ValidationError("Invalid support token", details...))
+		return
+	}
+
+	issuedBy := auditActor(r)
+	if name := strings.TrimSpace(req.IssuedBy); name != "" {
+		issuedBy += "/" + name
+	}
+
+	token, data, err := h.supportTokens.GenerateSupportToken(r.Context(), req.RobloxUserID, issuedBy, ttl)
+	if errors.Is(err, service.ErrInvalidTokenTTL) {
+		response.Error(w, apierror.BadRequest(err.Error()))
+		return
+	}
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+
+	h.recordAudit(r, "support_token.create", "roblox_user:"+req.RobloxUserID, map[string]interface{}{
+		"session_id": data.SessionID,
+		"issued_by":  issuedBy,
+		"expires_at": data.ExpiresAt,
+	})
+
+	response.Created(w, map[string]interface{}{
+		"token":          token,
+		"session_id":     data.SessionID,
+		"roblox_user_id": data.RobloxUserID,
+		"scope":          data.Scope,
+		"issued_by":      issuedBy,
+		"expires_at":     data.ExpiresAt,
+		"expires_in":     int(ttl.Seconds()),
+	})
+}
+
+// ListSupportTokens handles GET /api/v1/admin/support-tokens
+func (h *AdminHandler) ListSupportTokens(w http.ResponseWriter, r *http.Request) {
+	if !h.supportTokensAllowed(w, r) {
+		return
+	}
+
+	tokens, err := h.supportTokens.ListSupportTokens(r.Context())
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+	response.OK(w, map[string]interface{}{
+		"tokens": tokens,
+		"count":  len(tokens),
+	})
+}
+
+// RevokeSupportToken handles DELETE /api/v1/admin/support-tokens/{session_id}
+func (h *AdminHandler) RevokeSupportToken(w http.ResponseWriter, r *http.Request) {
+	if !h.supportTokensAllowed(w, r) {
+		return
+	}
+
+	sessionID := chi.URLParam(r, "session_id")
+	found, err := h.supportTokens.RevokeSupportToken(r.Context(), sessionID)
+	if err != nil {
+		response.Error(w, apierror.InternalError(err.Error()))
+		return
+	}
+	if !found {
+		response.Error(w, apierror.NotFound("support token not found"))
+		return
+	}
+
+	h.recordAudit(r, "support_token.revoke", "support_token:"+sessionID, nil)
+	response.OK(w, map[string]interface{}{
+		"status":     "revoked",
+		"session_id": sessionID,
+	})
+}
+
+// supportTokensAllowed checks the support token endpoints are configured
+// and the caller used an API key.
+func (h *AdminHandler) supportTokensAllowed(w http.ResponseWriter, r *http.Request) bool {
+	if h.supportTokens == nil {
+		componentMissing(w, "support_tokens")
+		return false
+	}
+	if !middleware.IsAdminAuth(r.Context()) {
+		response.Error(w, apierror.Forbidden("support tokens require an API key"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vinzhub-rest-api/internal/repository"
+)
+
+// panickingStats is a StatsProvider that fails hard.
+type panickingStats struct{}
+
+func (panickingStats) Stats(ctx context.Context) map[string]interface{} {
+	panic("stats exploded")
+}
+
+// adminStats calls GET /api/v1/admin/stats and returns its data.
+func adminStats(t *testing.T, h *AdminHandler) map[string]interface{} {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.GetStats(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/stats", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("stats status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return body.Data
+}
+
+func available(data map[string]interface{}, section string) interface{} {
+	s, _ := data[section].(map[string]interface{})
+	return s["available"]
+}
+
+func TestAdminStatsWithMissingComponents(t *testing.T) {
+	repo, err := repository.NewSQLiteInventoryRepository(filepath.Join(t.TempDir(), "inventory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	tests := []struct {
+		name       string
+		h          *AdminHandler
+		wantSQLite bool
+	}{
+		{"no components", NewAdminHandler(nil, nil), false},
+		{"sqlite without redis", NewAdminHandler(nil, repo), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := adminStats(t, tt.h)
+			if got := available(data, "redis_buffer"); got != false {
+				t.Errorf("redis_buffer available = %v, want false", got)
+			}
+			if got := available(data, "sqlite"); got != tt.wantSQLite {
+				t.Errorf("sqlite available = %v, want %v", got, tt.wantSQLite)
+			}
+			for _, section := range []string{"ingest", "flush", "integrity", "retention", "api_keys"} {
+				if got := available(data, section); got != false {
+					t.Errorf("%s available = %v, want false", section, got)
+				}
+			}
+			if data["memory"] == nil || data["runtime"] == nil {
+				t.Error("system sections missing")
+			}
+		})
+	}
+}
+
+func TestAdminStatsIsolatesFailingSection(t *testing.T) {
+	h := NewAdminHandler(nil, nil)
+	h.SetHeartbeats(panickingStats{})
+
+	data := adminStats(t, h)
+	heartbeat, _ := data["heartbeat"].(map[string]interface{})
+	if heartbeat["status"] != "error" || !strings.Contains(heartbeat["error"].(string), "stats exploded") {
+		t.Errorf("heartbeat section = %v, want the failure reported", heartbeat)
+	}
+	if data["memory"] == nil {
+		t.Error("a failing section dropped the rest of the response")
+	}
+}
+
+func TestAdminEndpointsNameMissingComponent(t *testing.T) {
+	h := NewAdminHandler(nil, nil)
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		component string
+	}{
+		{"flush log", h.GetFlushLog, "flush_log"},
+		{"maintenance", h.RunMaintenance, "sqlite"},
+		{"migrations", h.GetMigrations, "sqlite"},
+		{"backup", h.CreateBackup, "backups"},
+		{"integrity", h.GetIntegrity, "integrity"},
+		{"retention", h.RunRetention, "retention"},
+		{"recompress", h.GetRecompress, "recompressor"},
+		{"log archives", h.GetLogArchives, "log_archive"},
+		{"flush now", h.FlushNow, "redis_buffer"},
+		{"resume flush", h.ResumeFlush, "flush"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/x", strings.NewReader("{}")))
+			if rec.Code != http.StatusNotImplemented {
+				t.Fatalf("status = %d, want 501: %s", rec.Code, rec.Body)
+			}
+			var body struct {
+				Error struct {
+					Code    string `json:"code"`
+					Details []struct {
+						Field   string `json:"field"`
+						Message string `json:"message"`
+					} `json:"details"`
+				} `json:"error"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			if body.Error.Code != "NOT_IMPLEMENTED" || len(body.Error.Details) != 1 || body.Error.Details[0].Message != tt.component {
+				t.Errorf("body = %s, want NOT_IMPLEMENTED naming %s", rec.Body, tt.component)
+			}
+		})
+	}
+}
@@ -2,22 +2,28 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"vinzhub-rest-api/internal/repository"
 	"vinzhub-rest-api/internal/service"
+	"vinzhub-rest-api/internal/transport/http/middleware"
 	"vinzhub-rest-api/internal/transport/http/response"
 	"vinzhub-rest-api/pkg/apierror"
+
+	"github.com/go-chi/chi/v5"
 )
 
 // AuthHandler handles authentication-related HTTP requests.
 type AuthHandler struct {
 	tokenService   *service.TokenService
-	keyAccountRepo *repository.MySQLKeyAccountRepository
+	keyAccountRepo repository.KeyAccountAuthRepository
+	heartbeats     HeartbeatRecorder
+	accounts       repository.KeyAccountInfoReader
 }
 
 // NewAuthHandler creates a new auth handler.
-func NewAuthHandler(tokenService *service.TokenService, keyAccountRepo *repository.MySQLKeyAccountRepository) *AuthHandler {
+func NewAuthHandler(tokenService *service.TokenService, keyAccountRepo repository.KeyAccountAuthRepository) *AuthHandler {
 	return &AuthHandler{
 		tokenService:   tokenService,
 		keyAccountRepo: keyAccountRepo,
@@ -26,23 +32,42 @@ func NewAuthHandler(tokenService *service.TokenService, keyAccountRepo *reposito
 
 // TokenRequest represents the request body for token generation.
 type TokenRequest struct {
-	Key         string `json:"key"`          // License key
-	HWID        string `json:"hwid"`         // Hardware ID
-	RobloxID    string `json:"roblox_id"`    // Roblox user ID
+	Key           string `json:"key"`            // License key
+	HWID          string `json:"hwid"`           // Hardware ID
+	RobloxID      string `json:"roblox_id"`      // Roblox user ID
+	ClientVersion string `json:"client_version"` // Optional, falls back to X-Client-Version
 }
 
-// TokenResponse represents the response for token generation.
+// TokenResponse represents the response for token generation and refresh.
 type TokenResponse struct {
-	Token     string `json:"token"`
-	ExpiresIn int    `json:"expires_in"` // Seconds until expiry
+	Token            string `json:"token"`
+	ExpiresIn        int    `json:"expires_in"` // Seconds until expiry
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int    `json:"refresh_expires_in"` // Seconds until the refresh token expires
+}
+
+// RefreshRequest represents the request body for token refresh.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// tokenResponse converts a token pair to its response.
+func tokenResponse(pair *service.TokenPair) TokenResponse {
+	return TokenResponse{
+		Token:            pair.AccessToken,
+		ExpiresIn:        int(pair.ExpiresIn.Seconds()),
+		RefreshToken:     pair.RefreshToken,
+		RefreshExpiresIn: int(pair.RefreshExpiresIn.Seconds()),
+	}
 }
 
 // GenerateToken handles POST /auth/token
-// Validates key+hwid+roblox_id and returns a session token.
+// Validates key+hwid+roblox_id and returns a session token with the
+// refresh token that replaces it.
 func (h *AuthHandler) GenerateToken(w http.ResponseWriter, r *http.Request) {
 	var req TokenRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		response.Error(w, apierror.BadRequest("invalid request body"))
+		response.Error(w, bodyError(err, apierror.BadRequest("invalid request body")))
 		return
 	}
 	defer r.Body.Close()
@@ -71,18 +96,19 @@ func (h *AuthHandler) GenerateToken(w http.ResponseWriter, r *http.Request) {
 		RobloxUserID:   validation.RobloxUserID,
 		RobloxUsername: validation.RobloxUsername,
 		HWID:           validation.HWID,
+		ClientVersion:  req.ClientVersion,
+	}
+	if tokenData.ClientVersion == "" {
+		tokenData.ClientVersion = r.Header.Get("X-Client-Version")
 	}
 	
-	token, err := h.tokenService.GenerateToken(r.Context(), tokenData)
+	pair, err := h.tokenService.GenerateTokenPair(r.Context(), tokenData)
 	if err != nil {
 		response.Error(w, apierror.InternalError("failed to generate token"))
 		return
 	}
 	
-	response.OK(w, TokenResponse{
-		Token:     token,
-		ExpiresIn: 3600, // 1 hour in seconds
-	})
+	response.OK(w, tokenResponse(pair))
 }
 
 // RevokeToken handles POST /auth/revoke
@@ -103,21 +129,99 @@ func (h *AuthHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
 }
 
 // RefreshToken handles POST /auth/refresh
-// Extends the TTL of an existing token.
+// Consumes a refresh token and returns a new session token and refresh
+// token. Each refresh token works once; reusing one revokes its session.
 func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
-	token := r.Header.Get("X-Token")
-	if token == "" {
-		response.Error(w, apierror.BadRequest("X-Token header required"))
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, bodyError(err, apierror.BadRequest("invalid request body")))
 		return
 	}
-	
-	if err := h.tokenService.RefreshToken(r.Context(), token); err != nil {
+	if req.RefreshToken == "" {
+		response.Error(w, apierror.BadRequest("refresh_token is required"))
+		return
+	}
+
+	pair, err := h.tokenService.RotateRefreshToken(r.Context(), req.RefreshToken)
+	if errors.Is(err, service.ErrRefreshTokenInvalid) || errors.Is(err, service.ErrRefreshTokenReused) {
 		response.Error(w, apierror.Unauthorized(err.Error()))
 		return
 	}
-	
+	if err != nil {
+		response.Error(w, apierror.InternalError("failed to refresh token"))
+		return
+	}
+
+	response.OK(w, tokenResponse(pair))
+}
+
+// ListSessions handles GET /auth/sessions
+// Lists the active sessions of the caller's key account (X-Token required).
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	tokenData := middleware.GetTokenDataFromContext(r.Context())
+	if tokenData == nil {
+		response.Error(w, apierror.Unauthorized("X-Token required"))
+		return
+	}
+
+	sessions, err := h.tokenService.ListSessions(r.Context(), tokenData.KeyAccountID)
+	if err != nil {
+		response.Error(w, apierror.InternalError("failed to list sessions"))
+		return
+	}
+
+	current := service.SessionIDForToken(r.Header.Get("X-Token"))
+	for i := range sessions {
+		sessions[i].Current = sessions[i].SessionID == current
+	}
+
+	response.OK(w, map[string]interface{}{
+		"sessions": sessions,
+		"count":    len(sessions),
+	})
+}
+
+// RevokeSession handles DELETE /auth/sessions/{session_id}
+// and DELETE /auth/sessions?all=true (revokes everything except the current session).
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	tokenData := middleware.GetTokenDataFromContext(r.Context())
+	if tokenData == nil {
+		response.Error(w, apierror.Unauthorized("X-Token required"))
+		return
+	}
+
+	sessionID := chi.URLParam(r, "session_id")
+	if sessionID == "" {
+		if r.URL.Query().Get("all") != "true" {
+			response.Error(w, apierror.BadRequest("session_id or ?all=true is required"))
+			return
+		}
+
+		current := service.SessionIDForToken(r.Header.Get("X-Token"))
+		revoked, err := h.tokenService.RevokeOtherSessions(r.Context(), tokenData.KeyAccountID, current)
+		if err != nil {
+			response.Error(w, apierror.InternalError("failed to revoke sessions"))
+			return
+		}
+		response.OK(w, map[string]interface{}{
+			"status":  "revoked",
+			"revoked": revoked,
+		})
+		return
+	}
+
+	found, err := h.tokenService.RevokeSession(r.Context(), tokenData.KeyAccountID, sessionID)
+	if err != nil {
+		response.Error(w, apierror.InternalError("failed to revoke session"))
+		return
+	}
+	if !found {
+		response.Error(w, apierror.NotFound("session not found"))
+		return
+	}
+
 	response.OK(w, map[string]interface{}{
-		"status":     "refreshed",
-		"expires_in": 3600,
+		"status":     "revoked",
+		"session_id": sessionID,
 	})
 }
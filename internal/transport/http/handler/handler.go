@@ -1,9 +1,49 @@
 package handler
 
+import (
+	"context"
+	"sync"
+
+	"vinzhub-rest-api/internal/cache"
+)
+
 // Handler contains all HTTP handlers and their dependencies.
-type Handler struct{}
+type Handler struct {
+	mu        sync.RWMutex
+	readiness []readinessCheck
+	buffer    BufferStatsProvider // nil without a Redis buffer
+}
+
+// BufferStatsProvider reports the write buffer's depth and flush lag,
+// e.g. *cache.RedisInventoryBuffer.
+type BufferStatsProvider interface {
+	Stats(ctx context.Context) cache.BufferStats
+}
 
 // New creates a new handler.
 func New(_ interface{}) *Handler {
 	return &Handler{}
 }
+
+// readinessCheck is one dependency checked by GET /api/v1/ready.
+type readinessCheck struct {
+	name     string
+	required bool
+	check    func(ctx context.Context) error
+}
+
+// AddReadinessCheck adds a dependency to the readiness probe. A failing
+// required check fails readiness; a failing optional one (a dependency the
+// service can run without) only reports it degraded.
+func (h *Handler) AddReadinessCheck(name string, required bool, check func(ctx context.Context) error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readiness = append(h.readiness, readinessCheck{name: name, required: required, check: check})
+}
+
+// SetBuffer adds the write buffer to GET /api/v1/health/detail.
+func (h *Handler) SetBuffer(buffer BufferStatsProvider) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.buffer = buffer
+}
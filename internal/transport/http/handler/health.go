@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"context"
 	"net/http"
+	"sync"
 	"time"
 
+	"vinzhub-rest-api/internal/cache"
 	"vinzhub-rest-api/internal/transport/http/response"
 )
 
@@ -26,6 +29,39 @@ func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 	response.OK(w, resp)
 }
 
+// HealthDetailResponse is the health response with the write buffer's
+// depth and flush lag, for alerting on stalled flushes.
+type HealthDetailResponse struct {
+	HealthResponse
+	Buffer *cache.BufferStats `json:"buffer"` // null without a Redis buffer
+}
+
+// HealthDetail handles GET /api/v1/health/detail
+// Health plus the Redis buffer's pending count, the time since the last
+// successful flush and the last flush error. Always 200: alert on
+// buffer.flush_lag_seconds rather than the status.
+func (h *Handler) HealthDetail(w http.ResponseWriter, r *http.Request) {
+	resp := HealthDetailResponse{
+		HealthResponse: HealthResponse{
+			Status:    "healthy",
+			Timestamp: time.Now().UTC(),
+			Version:   "1.0.0",
+		},
+	}
+
+	h.mu.RLock()
+	buffer := h.buffer
+	h.mu.RUnlock()
+	if buffer != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+		stats := buffer.Stats(ctx)
+		resp.Buffer = &stats
+	}
+
+	response.OK(w, resp)
+}
+
 // ReadyResponse represents the readiness check response.
 type ReadyResponse struct {
 	Ready     bool      `json:"ready"`
@@ -35,27 +71,51 @@ type ReadyResponse struct {
 
 // Check represents an individual readiness check.
 type Check struct {
-	Name   string `json:"name"`
-	Status string `json:"status"`
+	Name     string `json:"name"`
+	Status   string `json:"status"` // ok, degraded or failed
+	Required bool   `json:"required"`
+	Error    string `json:"error,omitempty"`
 }
 
+// readinessTimeout bounds each readiness check; checks run concurrently.
+const readinessTimeout = 2 * time.Second
+
 // Ready handles GET /api/v1/ready
 // Used for readiness probes to check if the service can accept traffic.
+// Every dependency added with AddReadinessCheck is checked; the service is
+// ready unless a required one fails.
 func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
-	// In a real implementation, you would check:
-	// - Database connectivity
-	// - Cache connectivity
-	// - External service dependencies
-
-	checks := []Check{
-		{Name: "api", Status: "ok"},
-		// {Name: "database", Status: "ok"},
-		// {Name: "cache", Status: "ok"},
+	h.mu.RLock()
+	deps := h.readiness
+	h.mu.RUnlock()
+
+	checks := make([]Check, len(deps)+1)
+	checks[0] = Check{Name: "api", Status: "ok", Required: true}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i, dep := range deps {
+		wg.Add(1)
+		go func(c *Check, dep readinessCheck) {
+			defer wg.Done()
+			c.Name = dep.name
+			c.Required = dep.required
+			c.Status = "ok"
+			if err := dep.check(ctx); err != nil {
+				c.Status = "degraded"
+				if dep.required {
+					c.Status = "failed"
+				}
+				c.Error = err.Error()
+			}
+		}(&checks[i+1], dep)
 	}
+	wg.Wait()
 
 	allReady := true
 	for _, check := range checks {
-		if check.Status != "ok" {
+		if check.Status == "failed" {
 			allReady = false
 			break
 		}
@@ -67,9 +127,9 @@ func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
 		Checks:    checks,
 	}
 
+	status := http.StatusOK
 	if !allReady {
-		w.WriteHeader(http.StatusServiceUnavailable)
+		status = http.StatusServiceUnavailable
 	}
-
-	response.OK(w, resp)
+	response.JSON(w, status, resp)
 }
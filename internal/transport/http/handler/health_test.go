@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"vinzhub-rest-api/internal/transport/http/response"
+)
+
+func TestHealthHeadMatchesGet(t *testing.T) {
+	down := New(nil)
+	down.AddReadinessCheck("sqlite", true, func(context.Context) error { return errors.New("locked") })
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+	}{
+		{"health", New(nil).Health, http.StatusOK},
+		{"ready", New(nil).Ready, http.StatusOK},
+		{"not ready", down.Ready, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			get := httptest.NewRecorder()
+			tt.handler(get, httptest.NewRequest(http.MethodGet, "/", nil))
+			head := httptest.NewRecorder()
+			response.Head(tt.handler)(head, httptest.NewRequest(http.MethodHead, "/", nil))
+
+			if get.Code != tt.wantStatus || head.Code != get.Code {
+				t.Fatalf("status GET %d, HEAD %d; want %d for both", get.Code, head.Code, tt.wantStatus)
+			}
+			if got, want := head.Header().Get("Content-Type"), get.Header().Get("Content-Type"); got != want {
+				t.Errorf("HEAD Content-Type = %q, GET sent %q", got, want)
+			}
+			// Timestamps make the exact size vary by a few bytes
+			if n, _ := strconv.Atoi(head.Header().Get("Content-Length")); n == 0 || n > get.Body.Len()+10 || n < get.Body.Len()-10 {
+				t.Errorf("HEAD Content-Length = %d, GET body is %d bytes", n, get.Body.Len())
+			}
+			if head.Body.Len() != 0 {
+				t.Errorf("HEAD sent a %d byte body", head.Body.Len())
+			}
+		})
+	}
+}
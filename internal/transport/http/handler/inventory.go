@@ -2,41 +2,251 @@ package handler
 
 import (
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"vinzhub-rest-api/internal/domain"
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/metrics"
+	"vinzhub-rest-api/internal/repository"
 	"vinzhub-rest-api/internal/service"
+	"vinzhub-rest-api/internal/transport/http/middleware"
 	"vinzhub-rest-api/internal/transport/http/response"
 	"vinzhub-rest-api/pkg/apierror"
 
 	"github.com/go-chi/chi/v5"
 )
 
+// inventoryLog logs inventory handler failures.
+var inventoryLog = logging.Component("Inventory")
+
+// syncRequests counts syncs by response semantics, so we can tell when no
+// callers are left on v1 (always 200) and it can be deprecated.
+var syncRequests = metrics.NewCounterVec("vinzhub_sync_requests_total",
+	"Inventory sync requests by API version semantics.", "api_version")
+
+// existsLookups counts existence lookups per caller, for usage metering.
+var existsLookups = metrics.NewCounterVec("vinzhub_exists_lookups_total",
+	"Inventory existence lookups by caller.", "caller")
+
+// apiVersion returns 2 for requests on the /api/v2 routes or sending
+// Accept-Version: 2, and 1 otherwise.
+func apiVersion(r *http.Request) int {
+	if strings.HasPrefix(r.URL.Path, "/api/v2/") || strings.TrimSpace(r.Header.Get("Accept-Version")) == "2" {
+		return 2
+	}
+	return 1
+}
+
 // InventoryHandler handles inventory-related HTTP requests.
 type InventoryHandler struct {
 	inventoryService *service.InventoryService
+	redaction        *service.RedactionPolicy
+	audit            AuditRecorder
+	existsLimit      *keyedLimiter
+	maxDecompressed  int64 // Inflated size cap of gzip sync bodies
 }
 
 // NewInventoryHandler creates a new inventory handler.
 func NewInventoryHandler(inventoryService *service.InventoryService) *InventoryHandler {
 	return &InventoryHandler{
 		inventoryService: inventoryService,
+		maxDecompressed:  defaultMaxDecompressedBytes,
+	}
+}
+
+// SetRedactionPolicy enables field redaction for reads by non-owners.
+func (h *InventoryHandler) SetRedactionPolicy(p *service.RedactionPolicy) {
+	h.redaction = p
+}
+
+// SetAuditLog attaches the audit log support token reads are recorded in.
+func (h *InventoryHandler) SetAuditLog(audit AuditRecorder) {
+	h.audit = audit
+}
+
+// SetExistsLimit rate limits the existence endpoint to perMinute lookups
+// per caller. Callers with a full API key are not limited.
+func (h *InventoryHandler) SetExistsLimit(perMinute int) {
+	if perMinute <= 0 {
+		h.existsLimit = nil
+		return
+	}
+	h.existsLimit = newKeyedLimiter(perMinute)
+}
+
+// authorizeWrite checks that a session token only writes its own user's
+// inventory; support tokens are read-only. API key callers pass.
+func (h *InventoryHandler) authorizeWrite(w http.ResponseWriter, r *http.Request, robloxUserID string) bool {
+	tokenData := middleware.GetTokenDataFromContext(r.Context())
+	switch {
+	case tokenData == nil:
+		return true
+	case tokenData.Restricted():
+		response.Error(w, apierror.Forbidden("support tokens are read-only"))
+		return false
+	case tokenData.RobloxUserID != robloxUserID:
+		response.Error(w, apierror.Forbidden("session token is bound to another user"))
+		return false
+	}
+	return true
+}
+
+// authorizeRead checks that a token is bound to the user being read, and
+// records support token reads under the admin who issued them. API key
+// callers pass; readFilter decides what they see.
+func (h *InventoryHandler) authorizeRead(w http.ResponseWriter, r *http.Request, robloxUserID string) bool {
+	if err := h.checkRead(r, robloxUserID); err != nil {
+		response.Error(w, err)
+		return false
+	}
+	return true
+}
+
+// checkRead is authorizeRead for callers reading several users, which
+// report a refusal per user.
+func (h *InventoryHandler) checkRead(r *http.Request, robloxUserID string) *apierror.Error {
+	tokenData := middleware.GetTokenDataFromContext(r.Context())
+	if tokenData == nil {
+		return nil
+	}
+	if !tokenData.Restricted() {
+		if tokenData.RobloxUserID != robloxUserID {
+			return apierror.Forbidden("session token is bound to another user")
+		}
+		return nil
+	}
+	if tokenData.RobloxUserID != robloxUserID {
+		return apierror.Forbidden("support token is bound to another user")
+	}
+
+	if h.audit != nil {
+		entry := &repository.AuditEntry{
+			Actor:     auditActor(r),
+			Action:    "support.inventory.read",
+			Target:    "roblox_user:" + robloxUserID,
+			RequestID: middleware.GetRequestID(r.Context()),
+		}
+		detail := map[string]interface{}{
+			"session_id": tokenData.SessionID,
+			"issued_by":  tokenData.IssuedBy,
+			"section":    r.URL.Query().Get("section"),
+		}
+		if err := h.audit.InsertAudit(r.Context(), entry, detail); err != nil {
+			inventoryLog.ErrorContext(r.Context(), "ALERT: failed to record support read", "roblox_user_id", robloxUserID, "error", err)
+		}
+	}
+	return nil
+}
+
+// readFilter returns the filter applied to documents returned for a roblox
+// user. Session tokens only get this far for their own user (checkRead) and
+// see everything, as do full and admin API keys. Scoped keys without the
+// admin scope - shared readers such as leaderboards - get the redaction
+// policy.
+func (h *InventoryHandler) readFilter(r *http.Request, robloxUserID string) func(section string, raw []byte) ([]byte, bool) {
+	if !h.redaction.Enabled() || middleware.IsAPIKeyAuth(r.Context()) || middleware.IsAdminAuth(r.Context()) {
+		return nil
+	}
+	if middleware.GetScopedKeyFromContext(r.Context()) == nil {
+		return nil
+	}
+	return h.redaction.Redact
+}
+
+// serviceError maps typed service errors onto API errors.
+// Unknown errors pass through and become a 500.
+func serviceError(err error) error {
+	var (
+		schemaErr   *service.SchemaError
+		tooFrequent *service.TooFrequentError
+		bufferFull  *service.BufferFullError
+	)
+	switch {
+	case errors.As(err, &schemaErr):
+		return schemaViolations(schemaErr)
+	case errors.As(err, &tooFrequent):
+		return apierror.TooManyRequests(fmt.Sprintf("this section was synced less than %s ago, retry in %ds",
+			tooFrequent.Interval, retryAfterSeconds(tooFrequent.RetryAfter)))
+	case errors.As(err, &bufferFull):
+		return apierror.ServiceUnavailable(fmt.Sprintf("too many syncs are waiting to be stored, retry in %ds",
+			retryAfterSeconds(bufferFull.RetryAfter)))
+	case errors.Is(err, service.ErrUnknownSection):
+		return apierror.BadRequest("unknown section")
+	case errors.Is(err, service.ErrInvalidRobloxUserID):
+		return errInvalidRobloxUserID
+	case errors.Is(err, service.ErrNoKeyAccount):
+		return apierror.New(http.StatusForbidden, "NO_KEY_ACCOUNT", "no active key account is linked to this roblox user")
+	case errors.Is(err, service.ErrKeyAccountUnavailable):
+		return apierror.ServiceUnavailable("key account lookup unavailable, try again later")
+	case errors.Is(err, service.ErrEmptyPayload):
+		return apierror.New(http.StatusUnprocessableEntity, "EMPTY_PAYLOAD", err.Error())
+	case errors.Is(err, service.ErrNotBuffered):
+		return apierror.NotFound("nothing is buffered for this section")
+	case errors.Is(err, service.ErrNotPersisted):
+		return apierror.NotFound("this section was never persisted")
 	}
+	return err
+}
+
+// retryAfterSeconds rounds a wait up to the whole seconds of Retry-After.
+func retryAfterSeconds(wait time.Duration) int {
+	return int(wait.Seconds()) + 1
+}
+
+// schemaViolations is the 422 listing why a document broke the schema,
+// each violation as a detail addressed by JSON Pointer.
+func schemaViolations(err *service.SchemaError) *apierror.Error {
+	details := make([]apierror.FieldError, 0, len(err.Violations))
+	for _, v := range err.Violations {
+		field := v.Path
+		if field == "" {
+			field = "/"
+		}
+		details = append(details, apierror.FieldError{Field: field, Message: v.Message})
+	}
+	message := "payload does not match the inventory schema"
+	if err.Truncated {
+		message += fmt.Sprintf(" (first %d violations listed)", len(details))
+	}
+	return apierror.New(http.StatusUnprocessableEntity, "SCHEMA_VIOLATION", message).WithDetails(details...)
 }
 
 // SyncRawInventory handles POST /api/v1/inventory/{roblox_user_id}/sync
-// Accepts any JSON and stores it raw in the database.
+// Accepts any JSON and stores it raw in the database. Session tokens may
+// only sync their own user; API key callers may sync any user.
+// ?section=<name> stores a named section; omitted means the default section.
+// ?durable=true writes straight to the database instead of the buffer.
+// ?allow_empty=true lets {} or [] overwrite stored data (rejected otherwise).
+// Content-Encoding: gzip bodies are inflated (up to a configured size) and
+// stored as plain JSON.
+// X-Sync-Callback: true asks for an Open Cloud message to the game once the
+// buffered write is persisted.
+// Each section may be synced once per throttle interval; sooner syncs get 429
+// with Retry-After, unless made with an unthrottled API key.
+//
+// v1 always answers 200 "synced". v2 (/api/v2 or Accept-Version: 2) answers
+// 200 only once the write is persisted, and 202 "accepted" with the
+// expected flush window while it is only buffered.
 func (h *InventoryHandler) SyncRawInventory(w http.ResponseWriter, r *http.Request) {
 	robloxUserID := chi.URLParam(r, "roblox_user_id")
-	if robloxUserID == "" {
-		response.Error(w, apierror.BadRequest("roblox_user_id is required"))
+	if !validRobloxUserID(robloxUserID) {
+		response.Error(w, errInvalidRobloxUserID)
+		return
+	}
+	if !h.authorizeWrite(w, r, robloxUserID) {
 		return
 	}
 
-	// Read raw body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		response.Error(w, apierror.BadRequest("failed to read request body"))
+	// Read raw body (inflated when gzip-encoded)
+	body, apiErr := h.readSyncBody(r)
+	if apiErr != nil {
+		response.Error(w, apiErr)
 		return
 	}
 	defer r.Body.Close()
@@ -48,39 +258,417 @@ func (h *InventoryHandler) SyncRawInventory(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	section := r.URL.Query().Get("section")
+	if section == "" {
+		section = domain.DefaultSection
+	}
+
+	req := service.SyncRequest{
+		RobloxUserID:  robloxUserID,
+		Section:       section,
+		RawJSON:       body,
+		ClientVersion: r.Header.Get("X-Client-Version"),
+		Durable:       r.URL.Query().Get("durable") == "true",
+		Callback:      r.Header.Get("X-Sync-Callback") == "true" || r.Header.Get("X-Sync-Callback") == "1",
+		AllowEmpty:    r.URL.Query().Get("allow_empty") == "true",
+	}
+	if key := middleware.GetScopedKeyFromContext(r.Context()); key != nil && key.HasScope(service.ScopeSyncUnthrottled) {
+		req.Unthrottled = true
+	}
+
+	// Session tokens already carry the key account - skip the lookup
+	if tokenData := middleware.GetTokenDataFromContext(r.Context()); tokenData != nil {
+		req.KeyAccountID = tokenData.KeyAccountID
+	}
+
+	version := apiVersion(r)
+	syncRequests.Inc(strconv.Itoa(version))
+
 	// Store raw JSON
-	err = h.inventoryService.SyncRawInventory(r.Context(), robloxUserID, body)
+	result, err := h.inventoryService.Sync(r.Context(), req)
 	if err != nil {
-		response.Error(w, err)
+		var (
+			tooFrequent *service.TooFrequentError
+			bufferFull  *service.BufferFullError
+		)
+		if errors.As(err, &tooFrequent) {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(tooFrequent.RetryAfter)))
+		} else if errors.As(err, &bufferFull) {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(bufferFull.RetryAfter)))
+		}
+		response.Error(w, serviceError(err))
 		return
 	}
 
-	response.OK(w, map[string]interface{}{
-		"status":   "synced",
-		"user_id":  robloxUserID,
-		"size":     len(body),
-	})
+	// size and elements let clients sanity-check what the server received
+	elements := service.ElementCount(body)
+	if version < 2 {
+		response.OK(w, map[string]interface{}{
+			"status":   "synced",
+			"user_id":  robloxUserID,
+			"section":  section,
+			"size":     len(body),
+			"elements": elements,
+		})
+		return
+	}
+
+	persistence := map[string]interface{}{"buffered": result.Buffered}
+	data := map[string]interface{}{
+		"status":      syncStatus(result),
+		"user_id":     robloxUserID,
+		"section":     section,
+		"size":        len(body),
+		"elements":    elements,
+		"persistence": persistence,
+	}
+	if !result.Buffered {
+		response.OK(w, data)
+		return
+	}
+
+	data["status"] = "accepted"
+	persistence["queue_depth"] = result.QueueDepth
+	persistence["expected_flush_within_ms"] = result.FlushWindow.Milliseconds()
+	persistence["expected_persisted_by"] = time.Now().Add(result.FlushWindow).UTC()
+	response.Accepted(w, data)
+}
+
+// syncStatus describes a sync that was not buffered. v1 responses always
+// say "synced", since older clients check for it.
+func syncStatus(result *service.SyncResult) string {
+	if result.Unchanged {
+		return "unchanged"
+	}
+	return "synced"
 }
 
 // GetRawInventory handles GET /api/v1/inventory/{roblox_user_id}
 // Returns the raw JSON stored for this user.
+// With ?section=<name> only that section is returned. Without it, the
+// response keeps the default section under "inventory" (for older clients)
+// and adds every stored section under "sections".
 func (h *InventoryHandler) GetRawInventory(w http.ResponseWriter, r *http.Request) {
 	robloxUserID := chi.URLParam(r, "roblox_user_id")
-	if robloxUserID == "" {
-		response.Error(w, apierror.BadRequest("roblox_user_id is required"))
+	if !validRobloxUserID(robloxUserID) {
+		response.Error(w, errInvalidRobloxUserID)
+		return
+	}
+	if !h.authorizeRead(w, r, robloxUserID) {
+		return
+	}
+	if h.notModified(w, r, robloxUserID, r.URL.Query().Get("section"), h.readFilter(r, robloxUserID) != nil) {
+		return
+	}
+
+	if section := r.URL.Query().Get("section"); section != "" {
+		data, syncedAt, err := h.inventoryService.GetSection(r.Context(), robloxUserID, section)
+		if err != nil {
+			response.Error(w, serviceError(err))
+			return
+		}
+
+		resp := map[string]interface{}{
+			"roblox_user_id": robloxUserID,
+			"section":        section,
+			"synced_at":      syncedAt,
+		}
+		filter := h.readFilter(r, robloxUserID)
+		if data != nil {
+			hash := repository.ContentHash(data)
+			setSectionValidators(w, repository.SectionMeta{SyncedAt: *syncedAt, ContentHash: hash}, filter != nil)
+			if count := h.storedItemCounts(r, robloxUserID)[section]; count != nil && count.hash == hash {
+				resp["item_count"] = count.n
+			}
+		}
+		if filter != nil {
+			if filtered, redacted := filter(section, data); redacted {
+				data = filtered
+				resp["redacted"] = true
+				delete(resp, "item_count")
+			}
+		}
+		resp["inventory"] = json.RawMessage(data)
+		response.OK(w, resp)
 		return
 	}
 
-	data, syncedAt, err := h.inventoryService.GetRawInventory(r.Context(), robloxUserID)
+	all, err := h.inventoryService.GetAllSections(r.Context(), robloxUserID)
 	if err != nil {
-		response.Error(w, err)
+		response.Error(w, serviceError(err))
 		return
 	}
 
-	// Return raw JSON as-is
-	response.OK(w, map[string]interface{}{
+	view := h.assembleInventory(r, robloxUserID, all)
+	setInventoryValidators(w, view.metas, view.redacting)
+	response.OK(w, view.resp)
+}
+
+// inventoryView is one user's sections assembled for a response.
+type inventoryView struct {
+	resp      map[string]interface{}
+	metas     map[string]repository.SectionMeta // Of the stored documents
+	redacting bool                              // A redaction filter applied
+}
+
+// assembleInventory builds the all-sections response for a user: the
+// default section under "inventory" (for older clients) and every section
+// under "sections", redacted for callers who aren't the owner. Shared by
+// GET /inventory/{id} and POST /inventory/view.
+func (h *InventoryHandler) assembleInventory(r *http.Request, robloxUserID string, all map[string]service.SectionData) inventoryView {
+	filter := h.readFilter(r, robloxUserID)
+	anyRedacted := false
+
+	metas := make(map[string]repository.SectionMeta, len(all))
+	for name, sec := range all {
+		metas[name] = repository.SectionMeta{SyncedAt: *sec.SyncedAt, ContentHash: repository.ContentHash(sec.RawJSON), Size: int64(len(sec.RawJSON))}
+	}
+
+	counts := h.storedItemCounts(r, robloxUserID)
+	sections := make(map[string]interface{}, len(all))
+	for name, sec := range all {
+		redacted := false
+		if filter != nil {
+			if filtered, ok := filter(name, sec.RawJSON); ok {
+				sec.RawJSON = filtered
+				all[name] = sec
+				redacted = true
+				anyRedacted = true
+			}
+		}
+		entry := map[string]interface{}{
+			"data":      json.RawMessage(sec.RawJSON),
+			"synced_at": sec.SyncedAt,
+		}
+		if count := counts[name]; count != nil && count.hash == metas[name].ContentHash && !redacted {
+			entry["item_count"] = count.n
+		}
+		sections[name] = entry
+	}
+
+	// Return raw JSON as-is (minus redacted fields)
+	def := all[domain.DefaultSection]
+	resp := map[string]interface{}{
 		"roblox_user_id": robloxUserID,
-		"inventory":      json.RawMessage(data),
-		"synced_at":      syncedAt,
-	})
+		"inventory":      json.RawMessage(def.RawJSON),
+		"synced_at":      def.SyncedAt,
+		"sections":       sections,
+	}
+	if def, ok := sections[domain.DefaultSection].(map[string]interface{}); ok && def["item_count"] != nil {
+		resp["item_count"] = def["item_count"]
+	}
+	if anyRedacted {
+		resp["redacted"] = true
+	}
+	return inventoryView{resp: resp, metas: metas, redacting: filter != nil}
+}
+
+// storedItemCount is the item count kept with a persisted section, valid
+// for the document whose content hash it carries.
+type storedItemCount struct {
+	n    int
+	hash string
+}
+
+// storedItemCounts returns the item counts stored with a user's sections.
+// Buffered copies and rows stored before counts were kept have none, and a
+// failed lookup only costs the counts.
+func (h *InventoryHandler) storedItemCounts(r *http.Request, robloxUserID string) map[string]*storedItemCount {
+	metas, err := h.inventoryService.GetAllSectionMeta(r.Context(), robloxUserID)
+	if err != nil {
+		inventoryLog.WarnContext(r.Context(), "Failed to read item counts", "roblox_user_id", robloxUserID, "error", err)
+		return nil
+	}
+	counts := make(map[string]*storedItemCount, len(metas))
+	for name, meta := range metas {
+		if meta.ItemCount != nil {
+			counts[name] = &storedItemCount{n: *meta.ItemCount, hash: meta.ContentHash}
+		}
+	}
+	return counts
+}
+
+// Exists handles GET /api/v1/inventory/{roblox_user_id}/exists
+// Reports whether the user has ever synced and the day of their latest
+// sync, nothing else. Open to API keys (full or inventory:exists scoped);
+// session tokens may not enumerate other users.
+func (h *InventoryHandler) Exists(w http.ResponseWriter, r *http.Request) {
+	robloxUserID := chi.URLParam(r, "roblox_user_id")
+	if !validRobloxUserID(robloxUserID) {
+		response.Error(w, errInvalidRobloxUserID)
+		return
+	}
+
+	var caller string
+	switch tokenData := middleware.GetTokenDataFromContext(r.Context()); {
+	case tokenData != nil && tokenData.Restricted():
+		if tokenData.RobloxUserID != robloxUserID {
+			response.Error(w, apierror.Forbidden("support token is bound to another user"))
+			return
+		}
+		caller = "token"
+	case tokenData != nil:
+		response.Error(w, apierror.Forbidden("existence lookups require an API key"))
+		return
+	case middleware.IsAPIKeyAuth(r.Context()):
+		caller = "api_key"
+	default:
+		key := middleware.GetScopedKeyFromContext(r.Context())
+		if key == nil {
+			response.Error(w, apierror.Forbidden("existence lookups require an API key"))
+			return
+		}
+		caller = "key:" + key.ID
+	}
+
+	if h.existsLimit != nil && caller != "api_key" {
+		if ok, wait := h.existsLimit.allow(caller); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+			response.Error(w, apierror.TooManyRequests("too many existence lookups, slow down"))
+			return
+		}
+	}
+	existsLookups.Inc(caller)
+
+	exists, day, err := h.inventoryService.Exists(r.Context(), robloxUserID)
+	if err != nil {
+		response.Error(w, serviceError(err))
+		return
+	}
+	resp := map[string]interface{}{"exists": exists}
+	if exists {
+		resp["last_synced_at"] = day.Format(time.DateOnly)
+	}
+	response.OK(w, resp)
+}
+
+// HeadRawInventory handles HEAD /api/v1/inventory/{roblox_user_id}
+// Answers with the status and validators (ETag, Last-Modified) the GET
+// would send, from section metadata only, so polling clients can check for
+// changes without the stored documents ever being loaded.
+func (h *InventoryHandler) HeadRawInventory(w http.ResponseWriter, r *http.Request) {
+	response.Head(h.headRawInventory)(w, r)
+}
+
+func (h *InventoryHandler) headRawInventory(w http.ResponseWriter, r *http.Request) {
+	robloxUserID := chi.URLParam(r, "roblox_user_id")
+	if !validRobloxUserID(robloxUserID) {
+		response.Error(w, errInvalidRobloxUserID)
+		return
+	}
+	if !h.authorizeRead(w, r, robloxUserID) {
+		return
+	}
+	redacting := h.readFilter(r, robloxUserID) != nil
+	if h.notModified(w, r, robloxUserID, r.URL.Query().Get("section"), redacting) {
+		return
+	}
+
+	if section := r.URL.Query().Get("section"); section != "" {
+		meta, err := h.inventoryService.GetSectionMeta(r.Context(), robloxUserID, section)
+		if err != nil {
+			response.Error(w, serviceError(err))
+			return
+		}
+		if meta != nil {
+			setSectionValidators(w, *meta, redacting)
+		}
+	} else {
+		metas, err := h.inventoryService.GetAllSectionMeta(r.Context(), robloxUserID)
+		if err != nil {
+			response.Error(w, serviceError(err))
+			return
+		}
+		setInventoryValidators(w, metas, redacting)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+}
+
+// notModified answers 304 when the caller's If-None-Match names the
+// current ETag. The tag is built from section metadata, i.e. the hash
+// stored with each row at flush time (and the buffered copy when it is
+// newer), so a poll that finds nothing new loads no document.
+func (h *InventoryHandler) notModified(w http.ResponseWriter, r *http.Request, robloxUserID, section string, redacting bool) bool {
+	if redacting || r.Header.Get("If-None-Match") == "" {
+		return false
+	}
+
+	var (
+		etag         string
+		lastModified time.Time
+	)
+	if section != "" {
+		meta, err := h.inventoryService.GetSectionMeta(r.Context(), robloxUserID, section)
+		if err != nil || meta == nil {
+			return false // The full read reports it
+		}
+		etag, lastModified = sectionValidators(*meta, false)
+	} else {
+		metas, err := h.inventoryService.GetAllSectionMeta(r.Context(), robloxUserID)
+		if err != nil {
+			return false
+		}
+		etag, lastModified = inventoryValidators(metas, false)
+	}
+	return response.NotModified(w, r, etag, lastModified)
+}
+
+// setSectionValidators sets Last-Modified and ETag for a single-section
+// read. Redacted responses differ per caller, so they get no ETag.
+func setSectionValidators(w http.ResponseWriter, meta repository.SectionMeta, redacting bool) {
+	etag, lastModified := sectionValidators(meta, redacting)
+	response.SetValidators(w, etag, lastModified)
+}
+
+// sectionValidators returns the ETag and Last-Modified of a single-section
+// read.
+func sectionValidators(meta repository.SectionMeta, redacting bool) (string, time.Time) {
+	if redacting {
+		return "", meta.SyncedAt
+	}
+	return meta.ContentHash, meta.SyncedAt
+}
+
+// setInventoryValidators sets Last-Modified (the newest section) and an
+// ETag combining every section's content hash for an all-sections read.
+func setInventoryValidators(w http.ResponseWriter, metas map[string]repository.SectionMeta, redacting bool) {
+	if len(metas) == 0 {
+		return
+	}
+	etag, lastModified := inventoryValidators(metas, redacting)
+	response.SetValidators(w, etag, lastModified)
+}
+
+// inventoryValidators returns the ETag and Last-Modified of an
+// all-sections read.
+func inventoryValidators(metas map[string]repository.SectionMeta, redacting bool) (string, time.Time) {
+
+	names := make([]string, 0, len(metas))
+	var lastModified time.Time
+	for name, meta := range metas {
+		names = append(names, name)
+		if meta.SyncedAt.After(lastModified) {
+			lastModified = meta.SyncedAt
+		}
+	}
+	sort.Strings(names)
+
+	var etag string
+	if !redacting {
+		var combined strings.Builder
+		for _, name := range names {
+			hash := metas[name].ContentHash
+			if hash == "" {
+				combined.Reset() // Row stored before hashes were kept
+				break
+			}
+			combined.WriteString(name + ":" + hash + "\n")
+		}
+		if combined.Len() > 0 {
+			etag = repository.ContentHash([]byte(combined.String()))
+		}
+	}
+	return etag, lastModified
 }
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+)
+
+// DiffInventory handles GET /api/v1/inventory/{roblox_user_id}/diff
+// Reports the top-level keys added, removed or changed by the buffered
+// write of a section (?section=, default otherwise) compared to the
+// persisted copy: what the next flush will change. 404 when either copy is
+// missing.
+func (h *InventoryHandler) DiffInventory(w http.ResponseWriter, r *http.Request) {
+	robloxUserID := chi.URLParam(r, "roblox_user_id")
+	if !validRobloxUserID(robloxUserID) {
+		response.Error(w, errInvalidRobloxUserID)
+		return
+	}
+	if !h.authorizeRead(w, r, robloxUserID) {
+		return
+	}
+
+	diff, err := h.inventoryService.DiffBuffered(r.Context(), robloxUserID, r.URL.Query().Get("section"), h.readFilter(r, robloxUserID))
+	if err != nil {
+		var apiErr *apierror.Error
+		if !errors.As(serviceError(err), &apiErr) {
+			inventoryLog.ErrorContext(r.Context(), "Diff failed", "roblox_user_id", robloxUserID, "error", err)
+			apiErr = apierror.InternalError("failed to diff inventory")
+		}
+		response.Error(w, apiErr)
+		return
+	}
+
+	response.OK(w, map[string]interface{}{
+		"roblox_user_id": robloxUserID,
+		"diff":           diff,
+	})
+}
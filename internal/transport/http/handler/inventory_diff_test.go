@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"vinzhub-rest-api/internal/cache"
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestDiffInventoryEndpoint(t *testing.T) {
+	repo, err := repository.NewSQLiteInventoryRepository(filepath.Join(t.TempDir(), "inventory.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	buffer := cache.NewInventoryBuffer(time.Hour, func(context.Context, []*cache.BufferedInventory) error { return nil })
+	t.Cleanup(func() { buffer.Close() })
+	h := NewInventoryHandler(service.NewInventoryServiceWithBuffer(repo, nil, buffer))
+
+	if err := repo.UpsertRawInventory(context.Background(), 1, "100", []byte(`{"Coins":5}`), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpsertRawInventory(context.Background(), 1, "300", []byte(`{"Coins":5}`), 0); err != nil {
+		t.Fatal(err)
+	}
+	buffer.Add(1, "100", []byte(`{"Coins":6,"Pets":[]}`))
+	buffer.Add(1, "200", []byte(`{"Coins":1}`))
+
+	r := chi.NewRouter()
+	r.Get("/api/v1/inventory/{roblox_user_id}/diff", h.DiffInventory)
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+	}{
+		{"both copies", "/api/v1/inventory/100/diff", http.StatusOK},
+		{"never persisted", "/api/v1/inventory/200/diff", http.StatusNotFound},
+		{"nothing buffered", "/api/v1/inventory/300/diff", http.StatusNotFound},
+		{"unknown section", "/api/v1/inventory/100/diff?section=nope", http.StatusBadRequest},
+		{"invalid user", "/api/v1/inventory/abc/diff", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req = req.WithContext(fullAPIKey(req.Context()))
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Data struct {
+					Diff service.InventoryDiff `json:"diff"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			diff := body.Data.Diff
+			if len(diff.Added) != 1 || diff.Added[0] != "Pets" || len(diff.Changed) != 1 || diff.Changed[0] != "Coins" || len(diff.Removed) != 0 {
+				t.Errorf("diff = %+v, want Pets added and Coins changed", diff)
+			}
+		})
+	}
+}
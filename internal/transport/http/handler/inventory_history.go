@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"vinzhub-rest-api/internal/domain"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+)
+
+// errHistoryDisabled answers history reads while no versions are kept.
+var errHistoryDisabled = apierror.New(http.StatusNotFound, "HISTORY_DISABLED", "inventory history is not enabled")
+
+// GetInventoryHistory handles GET /api/v1/inventory/{roblox_user_id}/history
+// Lists the kept versions of a user's sections, newest first, without the
+// documents. ?section= lists one section.
+func (h *InventoryHandler) GetInventoryHistory(w http.ResponseWriter, r *http.Request) {
+	robloxUserID := chi.URLParam(r, "roblox_user_id")
+	if !validRobloxUserID(robloxUserID) {
+		response.Error(w, errInvalidRobloxUserID)
+		return
+	}
+	if !h.authorizeRead(w, r, robloxUserID) {
+		return
+	}
+	if !h.inventoryService.HistoryEnabled() {
+		response.Error(w, errHistoryDisabled)
+		return
+	}
+
+	versions, err := h.inventoryService.History(r.Context(), robloxUserID, r.URL.Query().Get("section"))
+	if err != nil {
+		var apiErr *apierror.Error
+		if !errors.As(serviceError(err), &apiErr) {
+			inventoryLog.ErrorContext(r.Context(), "History failed", "roblox_user_id", robloxUserID, "error", err)
+			apiErr = apierror.InternalError("failed to read inventory history")
+		}
+		response.Error(w, apiErr)
+		return
+	}
+	if h.readFilter(r, robloxUserID) != nil {
+		// A hash of a redacted document would let callers confirm guesses
+		// of the removed fields
+		for i := range versions {
+			versions[i].ContentHash = ""
+		}
+	}
+
+	response.OK(w, map[string]interface{}{
+		"roblox_user_id": robloxUserID,
+		"versions":       versions,
+	})
+}
+
+// GetInventoryVersion handles GET /api/v1/inventory/{roblox_user_id}/history/{timestamp}
+// Returns the version of a section synced at timestamp (RFC3339, as listed
+// by GET .../history). ?section= picks the section, default otherwise.
+func (h *InventoryHandler) GetInventoryVersion(w http.ResponseWriter, r *http.Request) {
+	robloxUserID := chi.URLParam(r, "roblox_user_id")
+	if !validRobloxUserID(robloxUserID) {
+		response.Error(w, errInvalidRobloxUserID)
+		return
+	}
+	if !h.authorizeRead(w, r, robloxUserID) {
+		return
+	}
+	if !h.inventoryService.HistoryEnabled() {
+		response.Error(w, errHistoryDisabled)
+		return
+	}
+
+	syncedAt, err := time.Parse(time.RFC3339Nano, chi.URLParam(r, "timestamp"))
+	if err != nil {
+		response.Error(w, apierror.ValidationError("Invalid version",
+			apierror.FieldError{Field: "timestamp", Message: "must be an RFC3339 timestamp"}))
+		return
+	}
+	section := r.URL.Query().Get("section")
+	if section == "" {
+		section = domain.DefaultSection
+	}
+
+	data, err := h.inventoryService.HistoryVersion(r.Context(), robloxUserID, section, syncedAt)
+	if err != nil {
+		var apiErr *apierror.Error
+		if !errors.As(serviceError(err), &apiErr) {
+			inventoryLog.ErrorContext(r.Context(), "Version read failed", "roblox_user_id", robloxUserID, "synced_at", syncedAt, "error", err)
+			apiErr = apierror.InternalError("failed to read inventory version")
+		}
+		response.Error(w, apiErr)
+		return
+	}
+	if data == nil {
+		response.Error(w, apierror.NotFound("no version of this section was kept at that time"))
+		return
+	}
+
+	resp := map[string]interface{}{
+		"roblox_user_id": robloxUserID,
+		"section":        section,
+		"synced_at":      syncedAt.UTC(),
+	}
+	if filter := h.readFilter(r, robloxUserID); filter != nil {
+		if filtered, redacted := filter(section, data); redacted {
+			data = filtered
+			resp["redacted"] = true
+		}
+	}
+	resp["inventory"] = json.RawMessage(data)
+	response.OK(w, resp)
+}
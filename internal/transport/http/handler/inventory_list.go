@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/transport/http/middleware"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+)
+
+const (
+	// defaultListPerPage is the page size of the user listing.
+	defaultListPerPage = 50
+	// maxListPerPage caps ?per_page; larger values are clamped.
+	maxListPerPage = 200
+)
+
+// ListInventories handles GET /api/v1/inventory
+// Lists stored users, most recently synced first, with ?page, ?per_page
+// and ?since=<RFC3339> on the latest sync. Only API key callers may list:
+// a session token sees its own user only.
+func (h *InventoryHandler) ListInventories(w http.ResponseWriter, r *http.Request) {
+	if !middleware.IsAPIKeyAuth(r.Context()) {
+		response.Error(w, apierror.Forbidden("listing inventories requires an API key"))
+		return
+	}
+
+	q := r.URL.Query()
+	page := 1
+	if v := q.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			response.Error(w, apierror.ValidationError("Invalid listing",
+				apierror.FieldError{Field: "page", Message: "must be a positive integer"}))
+			return
+		}
+		page = n
+	}
+	perPage := defaultListPerPage
+	if v := q.Get("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			response.Error(w, apierror.ValidationError("Invalid listing",
+				apierror.FieldError{Field: "per_page", Message: "must be a positive integer"}))
+			return
+		}
+		perPage = min(n, maxListPerPage)
+	}
+	var since time.Time
+	if v := q.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			response.Error(w, apierror.ValidationError("Invalid listing",
+				apierror.FieldError{Field: "since", Message: "must be an RFC3339 timestamp"}))
+			return
+		}
+		since = t
+	}
+
+	users, total, err := h.inventoryService.ListInventories(r.Context(), repository.InventoryListQuery{
+		Since:  since,
+		Limit:  perPage,
+		Offset: (page - 1) * perPage,
+	})
+	if err != nil {
+		inventoryLog.ErrorContext(r.Context(), "Listing failed", "error", err)
+		response.Error(w, apierror.InternalError("failed to list inventories"))
+		return
+	}
+	response.Paginated(w, users, page, perPage, total)
+}
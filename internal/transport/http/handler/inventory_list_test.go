@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/service"
+)
+
+func TestListInventoriesEndpoint(t *testing.T) {
+	repo, err := repository.NewSQLiteInventoryRepository(filepath.Join(t.TempDir(), "inventory.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var items []repository.InventoryItem
+	for i := 1; i <= 250; i++ {
+		items = append(items, repository.InventoryItem{KeyAccountID: 1, RobloxUserID: strconv.Itoa(i),
+			RawJSON: []byte(`{}`), SyncedAt: base.Add(time.Duration(i) * time.Second)})
+	}
+	if err := repo.BatchUpsertRawInventory(context.Background(), items); err != nil {
+		t.Fatal(err)
+	}
+	h := NewInventoryHandler(service.NewInventoryService(repo, nil))
+
+	tests := []struct {
+		name       string
+		who        caller
+		query      string
+		wantStatus int
+		wantField  string
+		wantUsers  int
+		wantFirst  string
+		wantMeta   map[string]float64
+	}{
+		{"defaults", fullAPIKey, "", http.StatusOK, "", 50, "250",
+			map[string]float64{"page": 1, "limit": 50, "total": 250, "total_pages": 5}},
+		{"page", fullAPIKey, "?page=2&per_page=100", http.StatusOK, "", 100, "150",
+			map[string]float64{"page": 2, "limit": 100, "total": 250, "total_pages": 3}},
+		{"per_page capped", fullAPIKey, "?per_page=1000", http.StatusOK, "", 200, "250",
+			map[string]float64{"page": 1, "limit": 200, "total": 250, "total_pages": 2}},
+		{"since", fullAPIKey, "?since=" + base.Add(241*time.Second).Format(time.RFC3339), http.StatusOK, "", 10, "250",
+			map[string]float64{"page": 1, "limit": 50, "total": 10, "total_pages": 1}},
+		{"past the end", fullAPIKey, "?page=9", http.StatusOK, "", 0, "",
+			map[string]float64{"page": 9, "total": 250}},
+		{"invalid page", fullAPIKey, "?page=0", http.StatusBadRequest, "page", 0, "", nil},
+		{"invalid per_page", fullAPIKey, "?per_page=x", http.StatusBadRequest, "per_page", 0, "", nil},
+		{"invalid since", fullAPIKey, "?since=yesterday", http.StatusBadRequest, "since", 0, "", nil},
+		{"session token", sessionToken("100"), "", http.StatusForbidden, "", 0, "", nil},
+		{"scoped key", scopedKey(service.ScopeInventoryRead), "", http.StatusForbidden, "", 0, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/inventory"+tt.query, nil)
+			req = req.WithContext(tt.who(req.Context()))
+			rec := httptest.NewRecorder()
+			h.ListInventories(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			var body struct {
+				Data  []repository.InventorySummary `json:"data"`
+				Meta  map[string]float64            `json:"meta"`
+				Error struct {
+					Details []struct {
+						Field string `json:"field"`
+					} `json:"details"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantField != "" && (len(body.Error.Details) != 1 || body.Error.Details[0].Field != tt.wantField) {
+				t.Errorf("error details = %+v, want field %s", body.Error.Details, tt.wantField)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if len(body.Data) != tt.wantUsers || (tt.wantUsers > 0 && body.Data[0].RobloxUserID != tt.wantFirst) {
+				t.Errorf("got %d users starting %+v, want %d starting %s", len(body.Data), body.Data, tt.wantUsers, tt.wantFirst)
+			}
+			for key, want := range tt.wantMeta {
+				if body.Meta[key] != want {
+					t.Errorf("meta %s = %v, want %v", key, body.Meta[key], want)
+				}
+			}
+		})
+	}
+}
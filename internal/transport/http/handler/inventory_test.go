@@ -0,0 +1,444 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"vinzhub-rest-api/internal/cache"
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/service"
+	"vinzhub-rest-api/internal/transport/http/middleware"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// newTestInventory returns an inventory handler over a temporary SQLite
+// file holding user 100's inventory with a "Secret" field.
+func newTestInventory(t *testing.T, redact ...string) *InventoryHandler {
+	t.Helper()
+	repo, err := repository.NewSQLiteInventoryRepository(filepath.Join(t.TempDir(), "inventory.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	if err := repo.UpsertRawInventory(context.Background(), 1, "100", []byte(`{"Items":[1],"Secret":"trade chat"}`), 1); err != nil {
+		t.Fatalf("seed inventory: %v", err)
+	}
+
+	h := NewInventoryHandler(service.NewInventoryService(repo, nil))
+	if len(redact) > 0 {
+		h.SetRedactionPolicy(service.NewRedactionPolicy(redact, 0))
+	}
+	return h
+}
+
+// caller sets up a request's context as the auth middleware would.
+type caller func(ctx context.Context) context.Context
+
+func sessionToken(robloxUserID string) caller {
+	return func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, middleware.ContextKeyTokenData, &service.TokenData{KeyAccountID: 1, RobloxUserID: robloxUserID})
+	}
+}
+
+func fullAPIKey(ctx context.Context) context.Context {
+	return context.WithValue(ctx, middleware.ContextKeyAPIKeyAuth, true)
+}
+
+func scopedKey(scopes ...string) caller {
+	return func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, middleware.ContextKeyScopedKey, &middleware.ScopedKey{ID: "test", Scopes: scopes})
+	}
+}
+
+// getInventory calls GET /api/v1/inventory/{roblox_user_id} as who.
+func getInventory(t *testing.T, h *InventoryHandler, who caller, robloxUserID string) (int, map[string]interface{}) {
+	t.Helper()
+	r := chi.NewRouter()
+	r.Get("/api/v1/inventory/{roblox_user_id}", h.GetRawInventory)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/inventory/"+robloxUserID+"?section=inventory", nil)
+	req = req.WithContext(who(req.Context()))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	return rec.Code, body.Data
+}
+
+func TestGetInventoryRefusesOtherUsersSessionToken(t *testing.T) {
+	for _, redact := range [][]string{nil, {"/Secret"}} {
+		h := newTestInventory(t, redact...)
+		if code, _ := getInventory(t, h, sessionToken("200"), "100"); code != http.StatusForbidden {
+			t.Errorf("redaction %v: stranger's token got %d, want 403", redact, code)
+		}
+	}
+}
+
+func TestGetInventoryRedaction(t *testing.T) {
+	h := newTestInventory(t, "/Secret")
+	tests := []struct {
+		name     string
+		who      caller
+		redacted bool
+	}{
+		{"owner token", sessionToken("100"), false},
+		{"full api key", fullAPIKey, false},
+		{"admin scoped key", scopedKey(service.ScopeAdmin), false},
+		{"shared read key", scopedKey(service.ScopeInventoryRead), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, data := getInventory(t, h, tt.who, "100")
+			if code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", code)
+			}
+			inv, _ := data["inventory"].(map[string]interface{})
+			_, hasSecret := inv["Secret"]
+			if hasSecret == tt.redacted {
+				t.Errorf("Secret present = %v, want %v", hasSecret, !tt.redacted)
+			}
+			if got, _ := data["redacted"].(bool); got != tt.redacted {
+				t.Errorf("redacted flag = %v, want %v", got, tt.redacted)
+			}
+		})
+	}
+}
+
+func TestInventoryRejectsNonNumericRobloxUserID(t *testing.T) {
+	h := newTestInventory(t)
+	r := chi.NewRouter()
+	r.Get("/api/v1/inventory/{roblox_user_id}", h.GetRawInventory)
+	r.Post("/api/v1/inventory/{roblox_user_id}/sync", h.SyncRawInventory)
+
+	for _, id := range []string{"100:settings", "abc", "-1", "1%3Ainventory"} {
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			path := "/api/v1/inventory/" + id
+			var body *strings.Reader
+			if method == http.MethodPost {
+				path += "/sync"
+				body = strings.NewReader(`{"Items":[]}`)
+			} else {
+				body = strings.NewReader("")
+			}
+			req := httptest.NewRequest(method, path, body)
+			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(fullAPIKey(req.Context()))
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("%s %s = %d, want 400", method, path, rec.Code)
+			}
+		}
+	}
+}
+
+func TestSyncStatusByAPIVersion(t *testing.T) {
+	repo, err := repository.NewSQLiteInventoryRepository(filepath.Join(t.TempDir(), "inventory.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	buffer := cache.NewInventoryBuffer(time.Hour, func(context.Context, []*cache.BufferedInventory) error { return nil })
+	t.Cleanup(func() { buffer.Close() })
+
+	buffered := NewInventoryHandler(service.NewInventoryServiceWithBuffer(repo, nil, buffer))
+	direct := NewInventoryHandler(service.NewInventoryService(repo, nil))
+
+	tests := []struct {
+		name       string
+		h          *InventoryHandler
+		path       string
+		header     string
+		wantStatus int
+		wantBody   string
+		wantV2     bool // persistence metadata present
+	}{
+		{"v1 buffered", buffered, "/api/v1/inventory/%s/sync", "", http.StatusOK, "synced", false},
+		{"v1 durable", buffered, "/api/v1/inventory/%s/sync?durable=true", "", http.StatusOK, "synced", false},
+		{"v2 route buffered", buffered, "/api/v2/inventory/%s/sync", "", http.StatusAccepted, "accepted", true},
+		{"v2 header buffered", buffered, "/api/v1/inventory/%s/sync", "2", http.StatusAccepted, "accepted", true},
+		{"v2 durable", buffered, "/api/v2/inventory/%s/sync?durable=true", "", http.StatusOK, "synced", true},
+		{"v2 without buffer", direct, "/api/v2/inventory/%s/sync", "", http.StatusOK, "synced", true},
+		{"unknown version", buffered, "/api/v1/inventory/%s/sync", "3", http.StatusOK, "synced", false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			r.Post("/api/v1/inventory/{roblox_user_id}/sync", tt.h.SyncRawInventory)
+			r.Post("/api/v2/inventory/{roblox_user_id}/sync", tt.h.SyncRawInventory)
+
+			id := strconv.Itoa(1000 + i) // Own user per case, clear of sync throttling
+			before := syncRequests.Snapshot()
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf(tt.path, id), strings.NewReader(`{"Items":[1]}`))
+			if tt.header != "" {
+				req.Header.Set("Accept-Version", tt.header)
+			}
+			req = req.WithContext(sessionToken(id)(req.Context()))
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			var body struct {
+				Data map[string]interface{} `json:"data"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			if body.Data["status"] != tt.wantBody {
+				t.Errorf("status field = %v, want %q", body.Data["status"], tt.wantBody)
+			}
+			persistence, hasPersistence := body.Data["persistence"].(map[string]interface{})
+			if hasPersistence != tt.wantV2 {
+				t.Errorf("persistence present = %v, want %v", hasPersistence, tt.wantV2)
+			}
+			if tt.wantStatus == http.StatusAccepted {
+				if persistence["buffered"] != true || persistence["expected_persisted_by"] == nil {
+					t.Errorf("persistence = %v, want buffered with an expected flush time", persistence)
+				}
+			}
+
+			version := "1"
+			if tt.wantV2 {
+				version = "2"
+			}
+			if got := syncRequests.Snapshot()[version] - before[version]; got != 1 {
+				t.Errorf("vinzhub_sync_requests_total{api_version=%q} grew by %d, want 1", version, got)
+			}
+		})
+	}
+}
+
+func TestAllSectionsAndViewRedaction(t *testing.T) {
+	h := newTestInventory(t, "/Secret")
+	r := chi.NewRouter()
+	r.Get("/api/v1/inventory/{roblox_user_id}", h.GetRawInventory)
+	r.Post("/api/v1/inventory/view", h.ViewInventory)
+
+	tests := []struct {
+		name     string
+		who      caller
+		redacted bool
+	}{
+		{"owner token", sessionToken("100"), false},
+		{"full api key", fullAPIKey, false},
+		{"shared read key", scopedKey(service.ScopeInventoryRead), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body struct {
+				Data map[string]interface{} `json:"data"`
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/inventory/100", nil)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req.WithContext(tt.who(req.Context())))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET status = %d, want 200", rec.Code)
+			}
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			assertRedacted(t, "GET", body.Data, tt.redacted)
+
+			req = httptest.NewRequest(http.MethodPost, "/api/v1/inventory/view", strings.NewReader(`{"users":[{"id":"100"}]}`))
+			rec = httptest.NewRecorder()
+			r.ServeHTTP(rec, req.WithContext(tt.who(req.Context())))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("view status = %d, want 200", rec.Code)
+			}
+			body.Data = nil
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			users, _ := body.Data["users"].([]interface{})
+			if len(users) != 1 {
+				t.Fatalf("view users = %v", body.Data["users"])
+			}
+			user, _ := users[0].(map[string]interface{})
+			assertRedacted(t, "view", user, tt.redacted)
+
+			sections, _ := user["sections"].(map[string]interface{})
+			inv, _ := sections["inventory"].(map[string]interface{})
+			if _, hasHash := inv["content_hash"]; hasHash == tt.redacted {
+				t.Errorf("view content_hash present = %v, want %v", hasHash, !tt.redacted)
+			}
+		})
+	}
+}
+
+// assertRedacted checks an all-sections response for the Secret field and
+// the redacted flag.
+func assertRedacted(t *testing.T, what string, data map[string]interface{}, redacted bool) {
+	t.Helper()
+	inv, _ := data["inventory"].(map[string]interface{})
+	if _, hasSecret := inv["Secret"]; hasSecret == redacted {
+		t.Errorf("%s: Secret present = %v, want %v", what, hasSecret, !redacted)
+	}
+	sections, _ := data["sections"].(map[string]interface{})
+	sec, _ := sections["inventory"].(map[string]interface{})
+	doc, _ := sec["data"].(map[string]interface{})
+	if _, hasSecret := doc["Secret"]; hasSecret == redacted {
+		t.Errorf("%s: sections.inventory Secret present = %v, want %v", what, hasSecret, !redacted)
+	}
+	if got, _ := data["redacted"].(bool); got != redacted {
+		t.Errorf("%s: redacted flag = %v, want %v", what, got, redacted)
+	}
+}
+
+func TestSyncRejectsEmptyOverwrites(t *testing.T) {
+	repo, err := repository.NewSQLiteInventoryRepository(filepath.Join(t.TempDir(), "inventory.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	svc := service.NewInventoryService(repo, nil)
+	svc.SetPayloadPolicy(service.PayloadPolicy{RejectNull: true, RejectEmpty: true})
+	h := NewInventoryHandler(svc)
+
+	r := chi.NewRouter()
+	r.Post("/api/v1/inventory/{roblox_user_id}/sync", h.SyncRawInventory)
+
+	// Steps run in order against user 300
+	steps := []struct {
+		name         string
+		query        string
+		body         string
+		wantStatus   int
+		wantElements int
+	}{
+		{"null on first sync", "", `null`, http.StatusUnprocessableEntity, 0},
+		{"empty first sync", "", `{}`, http.StatusOK, 0},
+		{"real data", "", `{"Items":[1],"Coins":5}`, http.StatusOK, 2},
+		{"empty overwrite", "", `[]`, http.StatusUnprocessableEntity, 0},
+		{"null overwrite with override", "?allow_empty=true", `null`, http.StatusUnprocessableEntity, 0},
+		{"empty overwrite with override", "?allow_empty=true", `{}`, http.StatusOK, 0},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/inventory/300/sync"+step.query, strings.NewReader(step.body))
+		req = req.WithContext(sessionToken("300")(req.Context()))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != step.wantStatus {
+			t.Fatalf("%s: status = %d, want %d: %s", step.name, rec.Code, step.wantStatus, rec.Body)
+		}
+		var body struct {
+			Data  map[string]interface{} `json:"data"`
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		if step.wantStatus != http.StatusOK {
+			if body.Error.Code != "EMPTY_PAYLOAD" {
+				t.Errorf("%s: error code = %q, want EMPTY_PAYLOAD", step.name, body.Error.Code)
+			}
+			continue
+		}
+		if body.Data["elements"] != float64(step.wantElements) || body.Data["size"] != float64(len(step.body)) {
+			t.Errorf("%s: elements %v, size %v; want %d, %d", step.name, body.Data["elements"], body.Data["size"], step.wantElements, len(step.body))
+		}
+	}
+}
+
+// blobCounter counts reads that load stored documents.
+type blobCounter struct {
+	repository.InventoryRepository
+	loads atomic.Int64
+}
+
+func (c *blobCounter) GetRawInventory(ctx context.Context, robloxUserID string) ([]byte, *time.Time, error) {
+	c.loads.Add(1)
+	return c.InventoryRepository.GetRawInventory(ctx, robloxUserID)
+}
+
+func (c *blobCounter) GetRawInventorySection(ctx context.Context, robloxUserID, section string) ([]byte, *time.Time, error) {
+	c.loads.Add(1)
+	return c.InventoryRepository.GetRawInventorySection(ctx, robloxUserID, section)
+}
+
+func (c *blobCounter) ListSections(ctx context.Context, robloxUserID string) ([]repository.SectionRecord, error) {
+	c.loads.Add(1)
+	return c.InventoryRepository.ListSections(ctx, robloxUserID)
+}
+
+func TestHeadMatchesGet(t *testing.T) {
+	repo, err := repository.NewSQLiteInventoryRepository(filepath.Join(t.TempDir(), "inventory.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	if err := repo.UpsertRawInventory(context.Background(), 1, "100", []byte(`{"Items":[1]}`), 1); err != nil {
+		t.Fatalf("seed inventory: %v", err)
+	}
+	counter := &blobCounter{InventoryRepository: repo}
+	h := NewInventoryHandler(service.NewInventoryService(counter, nil))
+
+	r := chi.NewRouter()
+	r.Get("/api/v1/inventory/{roblox_user_id}", h.GetRawInventory)
+	r.Head("/api/v1/inventory/{roblox_user_id}", h.HeadRawInventory)
+	serve := func(method, target, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req = req.WithContext(fullAPIKey(req.Context()))
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	etag := serve(http.MethodGet, "/api/v1/inventory/100", "").Header().Get("ETag")
+	sectionETag := serve(http.MethodGet, "/api/v1/inventory/100?section=inventory", "").Header().Get("ETag")
+	if etag == "" || sectionETag == "" {
+		t.Fatal("GET sent no ETag to revalidate with")
+	}
+
+	tests := []struct {
+		name        string
+		target      string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{"hit", "/api/v1/inventory/100", "", http.StatusOK},
+		{"section hit", "/api/v1/inventory/100?section=inventory", "", http.StatusOK},
+		{"stale etag", "/api/v1/inventory/100", `"stale"`, http.StatusOK},
+		{"not modified", "/api/v1/inventory/100", etag, http.StatusNotModified},
+		{"section not modified", "/api/v1/inventory/100?section=inventory", sectionETag, http.StatusNotModified},
+		{"miss", "/api/v1/inventory/999", "", http.StatusOK},
+		{"section miss", "/api/v1/inventory/999?section=inventory", "", http.StatusOK},
+		{"unknown section", "/api/v1/inventory/100?section=nope", "", http.StatusBadRequest},
+		{"invalid user", "/api/v1/inventory/abc", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			get := serve(http.MethodGet, tt.target, tt.ifNoneMatch)
+			loads := counter.loads.Load()
+			head := serve(http.MethodHead, tt.target, tt.ifNoneMatch)
+
+			if get.Code != tt.wantStatus || head.Code != get.Code {
+				t.Fatalf("status GET %d, HEAD %d; want %d for both", get.Code, head.Code, tt.wantStatus)
+			}
+			for _, name := range []string{"ETag", "Last-Modified", "Content-Type"} {
+				if got, want := head.Header().Get(name), get.Header().Get(name); got != want {
+					t.Errorf("HEAD %s = %q, GET sent %q", name, got, want)
+				}
+			}
+			if head.Body.Len() != 0 {
+				t.Errorf("HEAD sent a %d byte body", head.Body.Len())
+			}
+			if n := counter.loads.Load() - loads; n != 0 {
+				t.Errorf("HEAD loaded %d stored documents, want metadata only", n)
+			}
+		})
+	}
+}
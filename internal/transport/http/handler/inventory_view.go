@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+)
+
+const (
+	// maxViewUsers bounds the users of one view request.
+	maxViewUsers = 10
+	// maxViewHistory bounds the history entries asked for per user.
+	maxViewHistory = 10
+	// viewWorkers is how many users are read in parallel.
+	viewWorkers = 4
+	// maxViewBytes caps the documents in one view response. Users past the
+	// cap get metadata only and a truncated flag.
+	maxViewBytes = 4 << 20
+)
+
+// ViewRequest is the body of POST /api/v1/inventory/view.
+type ViewRequest struct {
+	Users []ViewUser `json:"users"`
+}
+
+// ViewUser is one user of a view request.
+type ViewUser struct {
+	ID      string `json:"id"`
+	History int    `json:"history"` // Past versions wanted, 0 for none
+}
+
+// ViewInventory handles POST /api/v1/inventory/view
+// Returns the current inventory of several users in one response, for the
+// trading UI: each user as GET /inventory/{id} would return it, plus
+// content hashes. Read access is checked per user; a refused or failed
+// user carries an error without failing the rest.
+func (h *InventoryHandler) ViewInventory(w http.ResponseWriter, r *http.Request) {
+	var req ViewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, bodyError(err, apierror.BadRequest("Invalid JSON body")))
+		return
+	}
+	if len(req.Users) == 0 || len(req.Users) > maxViewUsers {
+		response.Error(w, apierror.ValidationError("Invalid view",
+			apierror.FieldError{Field: "users", Message: fmt.Sprintf("must list 1 to %d users", maxViewUsers)}))
+		return
+	}
+	seen := make(map[string]bool, len(req.Users))
+	for _, u := range req.Users {
+		if !validRobloxUserID(u.ID) {
+			response.Error(w, apierror.ValidationError("Invalid view",
+				apierror.FieldError{Field: "users.id", Message: "must be a numeric roblox user ID, got " + u.ID}))
+			return
+		}
+		if seen[u.ID] {
+			response.Error(w, apierror.ValidationError("Invalid view",
+				apierror.FieldError{Field: "users.id", Message: "listed twice: " + u.ID}))
+			return
+		}
+		seen[u.ID] = true
+		if u.History < 0 || u.History > maxViewHistory {
+			response.Error(w, apierror.ValidationError("Invalid view",
+				apierror.FieldError{Field: "users.history", Message: fmt.Sprintf("must be 0 to %d", maxViewHistory)}))
+			return
+		}
+	}
+
+	// Read users in parallel; results keep request order
+	views := make([]map[string]interface{}, len(req.Users))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for n := 0; n < min(viewWorkers, len(req.Users)); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				views[i] = h.viewUser(r, req.Users[i])
+			}
+		}()
+	}
+	for i := range req.Users {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Earlier users get the size budget first
+	budget := maxViewBytes
+	truncated := false
+	for _, view := range views {
+		sections, ok := view["sections"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		size := viewDocumentBytes(view, sections)
+		if size <= budget {
+			budget -= size
+			continue
+		}
+		truncateView(view, sections)
+		truncated = true
+	}
+
+	response.OK(w, map[string]interface{}{
+		"users":     views,
+		"truncated": truncated,
+	})
+}
+
+// viewUser reads one user of a view request.
+func (h *InventoryHandler) viewUser(r *http.Request, u ViewUser) map[string]interface{} {
+	if err := h.checkRead(r, u.ID); err != nil {
+		return viewError(u.ID, err)
+	}
+
+	all, err := h.inventoryService.GetAllSections(r.Context(), u.ID)
+	if err != nil {
+		var apiErr *apierror.Error
+		if !errors.As(serviceError(err), &apiErr) {
+			inventoryLog.ErrorContext(r.Context(), "View failed", "roblox_user_id", u.ID, "error", err)
+			apiErr = apierror.InternalError("failed to read inventory")
+		}
+		return viewError(u.ID, apiErr)
+	}
+
+	view := h.assembleInventory(r, u.ID, all)
+	resp := view.resp
+	resp["found"] = len(all) > 0
+	sections := resp["sections"].(map[string]interface{})
+	for name, meta := range view.metas {
+		entry := sections[name].(map[string]interface{})
+		entry["size"] = meta.Size
+		if !view.redacting {
+			// A hash of a redacted document would let callers confirm
+			// guesses of the removed fields
+			entry["content_hash"] = meta.ContentHash
+		}
+	}
+	if u.History > 0 {
+		h.viewHistory(r, u, resp, view.redacting)
+	}
+	return resp
+}
+
+// viewHistory adds a user's latest kept versions, across sections, to
+// their entry.
+func (h *InventoryHandler) viewHistory(r *http.Request, u ViewUser, resp map[string]interface{}, redacting bool) {
+	if !h.inventoryService.HistoryEnabled() {
+		// No version history is kept; say so rather than return nothing
+		resp["history"] = []interface{}{}
+		resp["history_available"] = false
+		return
+	}
+
+	versions, err := h.inventoryService.History(r.Context(), u.ID, "")
+	if err != nil {
+		inventoryLog.WarnContext(r.Context(), "View history failed", "roblox_user_id", u.ID, "error", err)
+		resp["history"] = []interface{}{}
+		resp["history_available"] = false
+		return
+	}
+	versions = versions[:min(u.History, len(versions))]
+	if redacting {
+		for i := range versions {
+			versions[i].ContentHash = ""
+		}
+	}
+	resp["history"] = versions
+	resp["history_available"] = true
+}
+
+// viewError is the entry of a user that could not be read.
+func viewError(robloxUserID string, err *apierror.Error) map[string]interface{} {
+	return map[string]interface{}{
+		"roblox_user_id": robloxUserID,
+		"error":          map[string]interface{}{"code": err.Code, "message": err.Message},
+	}
+}
+
+// viewDocumentBytes is the size of the documents in a user's entry, the
+// default section counted twice as it is sent twice.
+func viewDocumentBytes(view, sections map[string]interface{}) int {
+	size := 0
+	if data, ok := view["inventory"].(json.RawMessage); ok {
+		size += len(data)
+	}
+	for _, sec := range sections {
+		if data, ok := sec.(map[string]interface{})["data"].(json.RawMessage); ok {
+			size += len(data)
+		}
+	}
+	return size
+}
+
+// truncateView drops a user's documents, keeping their metadata.
+func truncateView(view, sections map[string]interface{}) {
+	for _, sec := range sections {
+		delete(sec.(map[string]interface{}), "data")
+	}
+	delete(view, "inventory")
+	view["truncated"] = true
+}
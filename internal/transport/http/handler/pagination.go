@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+)
+
+// maxPageLimit caps ?limit on paginated listings.
+const maxPageLimit = 1000
+
+// pageRequest parses ?limit, ?cursor and the deprecated ?offset of a
+// listing ordered by ID. The query asks for one row past the limit so
+// nextPage can tell whether another page exists.
+func pageRequest(w http.ResponseWriter, r *http.Request, scope string, defaultLimit int) (repository.PageQuery, bool) {
+	q := r.URL.Query()
+
+	limit := defaultLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxPageLimit {
+			response.Error(w, apierror.BadRequest("limit must be between 1 and 1000"))
+			return repository.PageQuery{}, false
+		}
+		limit = n
+	}
+	page := repository.PageQuery{Limit: limit + 1}
+
+	if token := q.Get("cursor"); token != "" {
+		cursor, err := response.DecodeCursor(scope, token)
+		if errors.Is(err, response.ErrCursorExpired) {
+			response.Error(w, apierror.BadRequest("cursor expired - restart from the first page"))
+			return repository.PageQuery{}, false
+		}
+		if err != nil {
+			response.Error(w, apierror.BadRequest("invalid cursor"))
+			return repository.PageQuery{}, false
+		}
+		page.BeforeID = cursor.ID
+		return page, true
+	}
+
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			response.Error(w, apierror.BadRequest("offset must be a non-negative integer"))
+			return repository.PageQuery{}, false
+		}
+		// Offset pages skip or repeat rows written between requests; kept
+		// for one release, use next_cursor instead
+		w.Header().Set("Deprecation", "true")
+		page.Offset = n
+	}
+	return page, true
+}
+
+// nextPage trims the extra row requested by pageRequest. It returns how
+// many rows to keep and the cursor of the next page ("" on the last page).
+func nextPage(fetched int, page repository.PageQuery, scope string, idAt func(i int) int64) (int, string) {
+	limit := page.Limit - 1
+	if fetched <= limit {
+		return fetched, ""
+	}
+	return limit, response.EncodeCursor(scope, "", idAt(limit-1))
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"vinzhub-rest-api/internal/transport/http/response"
+)
+
+func TestPageRequest(t *testing.T) {
+	cursor := response.EncodeCursor("audit", "", 50)
+	tests := []struct {
+		name           string
+		query          string
+		wantOK         bool
+		wantLimit      int
+		wantBefore     int64
+		wantOffset     int
+		wantDeprecated bool
+	}{
+		{"defaults", "", true, 21, 0, 0, false},
+		{"limit", "?limit=5", true, 6, 0, 0, false},
+		{"cursor", "?limit=5&cursor=" + cursor, true, 6, 50, 0, false},
+		{"cursor wins over offset", "?cursor=" + cursor + "&offset=10", true, 21, 50, 0, false},
+		{"deprecated offset", "?offset=10", true, 21, 0, 10, true},
+		{"cursor of another listing", "?cursor=" + response.EncodeCursor("flush_log", "", 50), false, 0, 0, 0, false},
+		{"tampered cursor", "?cursor=" + cursor + "x", false, 0, 0, 0, false},
+		{"limit too large", "?limit=1001", false, 0, 0, 0, false},
+		{"negative offset", "?offset=-1", false, 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			page, ok := pageRequest(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/audit"+tt.query, nil), "audit", 20)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v (status %d)", ok, tt.wantOK, rec.Code)
+			}
+			if !ok {
+				if rec.Code != http.StatusBadRequest {
+					t.Errorf("status = %d, want 400", rec.Code)
+				}
+				return
+			}
+			if page.Limit != tt.wantLimit || page.BeforeID != tt.wantBefore || page.Offset != tt.wantOffset {
+				t.Errorf("page = %+v", page)
+			}
+			if got := rec.Header().Get("Deprecation") != ""; got != tt.wantDeprecated {
+				t.Errorf("Deprecation header set = %v, want %v", got, tt.wantDeprecated)
+			}
+		})
+	}
+}
+
+func TestNextPage(t *testing.T) {
+	ids := []int64{90, 80, 70, 60}
+	idAt := func(i int) int64 { return ids[i] }
+
+	rec := httptest.NewRecorder()
+	page, _ := pageRequest(rec, httptest.NewRequest(http.MethodGet, "/?limit=3", nil), "audit", 20)
+
+	keep, next := nextPage(len(ids), page, "audit", idAt)
+	if keep != 3 || next == "" {
+		t.Fatalf("nextPage = %d, %q; want 3 rows and a cursor", keep, next)
+	}
+	c, err := response.DecodeCursor("audit", next)
+	if err != nil || c.ID != 70 {
+		t.Errorf("next cursor = %+v, %v; want the last kept ID 70", c, err)
+	}
+
+	if keep, next := nextPage(3, page, "audit", idAt); keep != 3 || next != "" {
+		t.Errorf("last page: nextPage = %d, %q; want 3 rows and no cursor", keep, next)
+	}
+}
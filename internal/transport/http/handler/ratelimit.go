@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"sync"
+	"time"
+)
+
+// keyedLimiter is a token bucket per caller key. Buckets idle long enough
+// to have refilled are pruned, so one-off callers don't accumulate.
+type keyedLimiter struct {
+	rate  float64 // Tokens per second
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*limitBucket
+	lastPrune time.Time
+}
+
+// limitBucket is one caller's token bucket.
+type limitBucket struct {
+	tokens float64
+	seen   time.Time
+}
+
+// newKeyedLimiter allows each key perMinute requests a minute, in bursts of
+// up to perMinute.
+func newKeyedLimiter(perMinute int) *keyedLimiter {
+	return &keyedLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(perMinute),
+		buckets: make(map[string]*limitBucket),
+	}
+}
+
+// allow takes a token for key. When none is left it returns false and how
+// long until the next one.
+func (l *keyedLimiter) allow(key string) (bool, time.Duration) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastPrune) > time.Minute {
+		l.prune(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &limitBucket{tokens: l.burst, seen: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.seen).Seconds()*l.rate)
+	b.seen = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// prune drops buckets that would be full by now. Callers hold mu.
+func (l *keyedLimiter) prune(now time.Time) {
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.seen) > refill {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastPrune = now
+}
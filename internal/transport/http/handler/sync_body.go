@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"vinzhub-rest-api/internal/metrics"
+	"vinzhub-rest-api/pkg/apierror"
+)
+
+// defaultMaxDecompressedBytes caps a gzip sync body once inflated, unless
+// configured otherwise.
+const defaultMaxDecompressedBytes = 8 << 20
+
+// syncEncodings counts sync bodies by Content-Encoding, to see how many
+// clients send compressed payloads.
+var syncEncodings = metrics.NewCounterVec("vinzhub_sync_content_encoding_total",
+	"Inventory sync request bodies by Content-Encoding.", "encoding")
+
+// SetMaxDecompressedBytes caps the inflated size of gzip sync bodies.
+// Larger bodies are rejected with 413. 0 keeps the default.
+func (h *InventoryHandler) SetMaxDecompressedBytes(n int64) {
+	if n <= 0 {
+		n = defaultMaxDecompressedBytes
+	}
+	h.maxDecompressed = n
+}
+
+// readSyncBody reads a sync request body, inflating it when sent with
+// Content-Encoding: gzip. The stored document is always the plain JSON.
+func (h *InventoryHandler) readSyncBody(r *http.Request) ([]byte, *apierror.Error) {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		syncEncodings.Inc("identity")
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, bodyError(err, apierror.BadRequest("failed to read request body"))
+		}
+		return body, nil
+
+	case "gzip", "x-gzip":
+		syncEncodings.Inc("gzip")
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, bodyError(err, apierror.BadRequest("invalid gzip body"))
+		}
+		defer gz.Close()
+
+		limit := h.maxDecompressed
+		if limit <= 0 {
+			limit = defaultMaxDecompressedBytes
+		}
+		// Reading to the end of the stream also checks the gzip CRC
+		body, err := io.ReadAll(io.LimitReader(gz, limit+1))
+		if err != nil {
+			return nil, bodyError(err, apierror.BadRequest("invalid gzip body"))
+		}
+		if int64(len(body)) > limit {
+			return nil, apierror.PayloadTooLarge(fmt.Sprintf("decompressed body exceeds %d bytes", limit))
+		}
+		return body, nil
+	}
+
+	syncEncodings.Inc("unsupported")
+	return nil, apierror.New(http.StatusUnsupportedMediaType, "UNSUPPORTED_ENCODING",
+		"unsupported Content-Encoding "+encoding+", send gzip or an uncompressed body")
+}
+
+// bodyError is the error answered for a failed body read or decode: 413
+// when the body ran past the request size limit, fallback otherwise.
+func bodyError(err error, fallback *apierror.Error) *apierror.Error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return apierror.PayloadTooLarge(fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+	}
+	return fallback
+}
+
+// limitError returns the 413 for a request whose body ran past the size
+// limit, or nil. For readers that don't keep the read errors they hit.
+func limitError(r *http.Request) *apierror.Error {
+	_, err := r.Body.Read(nil) // http.MaxBytesReader repeats its error
+	if err == nil {
+		return nil
+	}
+	return bodyError(err, nil)
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSyncBodyEncodings(t *testing.T) {
+	repo, err := repository.NewSQLiteInventoryRepository(filepath.Join(t.TempDir(), "inventory.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	h := NewInventoryHandler(service.NewInventoryService(repo, nil))
+	doc := `{"Items":[` + strings.Repeat(`{"Id":1,"Name":"Rare Fish"},`, 20) + `{"Id":2}]}`
+	h.SetMaxDecompressedBytes(int64(len(doc)))
+
+	r := chi.NewRouter()
+	r.Post("/api/v1/inventory/{roblox_user_id}/sync", h.SyncRawInventory)
+
+	valid := gzipped(t, doc)
+	badCRC := append([]byte(nil), valid...)
+	badCRC[len(badCRC)-8] ^= 0xff // The CRC-32 trailer
+
+	tests := []struct {
+		name       string
+		encoding   string
+		body       []byte
+		wantStatus int
+		wantCode   string
+	}{
+		{"identity", "", []byte(doc), http.StatusOK, ""},
+		{"gzip", "gzip", valid, http.StatusOK, ""},
+		{"x-gzip", " X-GZIP ", valid, http.StatusOK, ""},
+		{"inflated over the cap", "gzip", gzipped(t, doc+" "), http.StatusRequestEntityTooLarge, "PAYLOAD_TOO_LARGE"},
+		{"not gzip", "gzip", []byte(doc), http.StatusBadRequest, "BAD_REQUEST"},
+		{"truncated stream", "gzip", valid[:len(valid)/2], http.StatusBadRequest, "BAD_REQUEST"},
+		{"bad checksum", "gzip", badCRC, http.StatusBadRequest, "BAD_REQUEST"},
+		{"gzip of invalid JSON", "gzip", gzipped(t, `{"Items":`), http.StatusBadRequest, ""},
+		{"unsupported encoding", "br", []byte(doc), http.StatusUnsupportedMediaType, "UNSUPPORTED_ENCODING"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := 1000 + i
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/inventory/"+strconv.Itoa(user)+"/sync", bytes.NewReader(tt.body))
+			req = req.WithContext(sessionToken(strconv.Itoa(user))(req.Context()))
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			stored, _, err := repo.GetRawInventory(context.Background(), strconv.Itoa(user))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantStatus != http.StatusOK {
+				var body struct {
+					Error struct {
+						Code string `json:"code"`
+					} `json:"error"`
+				}
+				json.Unmarshal(rec.Body.Bytes(), &body)
+				if tt.wantCode != "" && body.Error.Code != tt.wantCode {
+					t.Errorf("error code = %q, want %s", body.Error.Code, tt.wantCode)
+				}
+				if stored != nil {
+					t.Errorf("rejected body stored: %s", stored)
+				}
+				return
+			}
+			// Stored inflated, as if it had been sent plain
+			if string(stored) != doc {
+				t.Errorf("stored %q, want the plain JSON", stored)
+			}
+		})
+	}
+}
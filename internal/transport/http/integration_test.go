@@ -0,0 +1,200 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vinzhub-rest-api/internal/demo"
+	"vinzhub-rest-api/internal/repository"
+	"vinzhub-rest-api/internal/service"
+	"vinzhub-rest-api/internal/transport/http/handler"
+	"vinzhub-rest-api/internal/transport/http/middleware"
+)
+
+const (
+	harnessAPIKey   = "integration-key"
+	harnessAdminKey = "integration-admin"
+)
+
+// harness is the full HTTP API over the demo dataset: SQLite key accounts
+// and inventories seeded by demo.Seed, in-memory tokens, no MySQL or Redis.
+type harness struct {
+	server   *httptest.Server
+	accounts []demo.Account
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	keys, err := repository.NewSQLiteKeyAccountRepository(filepath.Join(dir, "key_accounts.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { keys.Close() })
+	store, err := repository.NewSQLiteInventoryRepository(filepath.Join(dir, "inventory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	accounts, err := demo.Seed(ctx, keys, store)
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	tokens := service.NewTokenServiceWithStore(service.NewMemoryTokenStore())
+	auth := middleware.NewAuthMiddleware(tokens, middleware.StaticKeys{harnessAPIKey},
+		middleware.WithAdminKeys(middleware.StaticKeys{harnessAdminKey}))
+	router := NewRouterWithOptions(RouterOptions{Auth: auth},
+		handler.New(nil),
+		handler.NewInventoryHandler(service.NewInventoryService(store, keys)),
+		handler.NewAdminHandler(nil, store),
+		handler.NewAuthHandler(tokens, keys))
+
+	h := &harness{server: httptest.NewServer(router), accounts: accounts}
+	t.Cleanup(h.server.Close)
+	return h
+}
+
+// do sends a request with header name/value pairs and decodes the data
+// member of the response.
+func (h *harness) do(t *testing.T, method, path, body string, headers ...string) (int, map[string]interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(method, h.server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := h.server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+
+	var envelope struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	json.Unmarshal(raw, &envelope)
+	return resp.StatusCode, envelope.Data
+}
+
+// token logs in as a seeded account.
+func (h *harness) token(t *testing.T, acc demo.Account) string {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"key": acc.Key, "hwid": acc.HWID, "roblox_id": acc.RobloxUserID})
+	code, data := h.do(t, http.MethodPost, "/api/v1/auth/token", string(body), "X-API-Key", harnessAPIKey)
+	token, _ := data["token"].(string)
+	if code != http.StatusOK || token == "" {
+		t.Fatalf("token for %s: status %d, data %v", acc.RobloxUserID, code, data)
+	}
+	return token
+}
+
+func TestIntegrationSessionTokenFlow(t *testing.T) {
+	h := newHarness(t)
+	me, other := h.accounts[0], h.accounts[1]
+	token := h.token(t, me)
+
+	code, data := h.do(t, http.MethodGet, "/api/v1/inventory/"+me.RobloxUserID+"?section=inventory", "", "X-Token", token)
+	if code != http.StatusOK || data["inventory"] == nil {
+		t.Fatalf("own seeded inventory: status %d, data %v", code, data)
+	}
+
+	if code, _ := h.do(t, http.MethodGet, "/api/v1/inventory/"+other.RobloxUserID, "", "X-Token", token); code != http.StatusForbidden {
+		t.Errorf("another user's inventory with a session token: status %d, want 403", code)
+	}
+
+	sync := `{"Items":[{"Id":"rod_pro","Quantity":1}],"Coins":9}`
+	if code, data := h.do(t, http.MethodPost, "/api/v1/inventory/"+me.RobloxUserID+"/sync?section=inventory", sync, "X-Token", token); code != http.StatusOK {
+		t.Fatalf("sync: status %d, data %v", code, data)
+	}
+	_, data = h.do(t, http.MethodGet, "/api/v1/inventory/"+me.RobloxUserID+"?section=inventory", "", "X-Token", token)
+	inv, _ := data["inventory"].(map[string]interface{})
+	if inv["Coins"] != float64(9) {
+		t.Errorf("inventory after sync = %v, want the synced document", data["inventory"])
+	}
+
+	if code, _ := h.do(t, http.MethodPost, "/api/v1/auth/revoke", "", "X-Token", token); code != http.StatusOK {
+		t.Fatalf("revoke: status %d", code)
+	}
+	if code, _ := h.do(t, http.MethodGet, "/api/v1/inventory/"+me.RobloxUserID, "", "X-Token", token); code != http.StatusUnauthorized {
+		t.Errorf("revoked token: status %d, want 401", code)
+	}
+}
+
+func TestIntegrationRejectsWrongCredentials(t *testing.T) {
+	h := newHarness(t)
+	acc := h.accounts[0]
+	h.token(t, acc) // Binds the account to its hwid
+
+	body, _ := json.Marshal(map[string]string{"key": acc.Key, "hwid": "OTHER-HWID", "roblox_id": acc.RobloxUserID})
+	if code, _ := h.do(t, http.MethodPost, "/api/v1/auth/token", string(body), "X-API-Key", harnessAPIKey); code != http.StatusUnauthorized {
+		t.Errorf("token with the wrong hwid: status %d, want 401", code)
+	}
+	if code, _ := h.do(t, http.MethodGet, "/api/v1/inventory/"+acc.RobloxUserID, ""); code != http.StatusUnauthorized {
+		t.Errorf("inventory without credentials: status %d, want 401", code)
+	}
+	if code, _ := h.do(t, http.MethodGet, "/api/v1/inventory/not-a-number", "", "X-API-Key", harnessAPIKey); code != http.StatusBadRequest {
+		t.Errorf("non-numeric roblox user ID: status %d, want 400", code)
+	}
+}
+
+func TestIntegrationAdminAPI(t *testing.T) {
+	h := newHarness(t)
+	tests := []struct {
+		name    string
+		headers []string
+		want    int
+	}{
+		{"no key", nil, http.StatusUnauthorized},
+		{"client key", []string{"X-API-Key", harnessAPIKey}, http.StatusForbidden},
+		{"admin key", []string{"X-API-Key", harnessAdminKey}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if code, _ := h.do(t, http.MethodGet, "/api/v1/admin/stats", "", tt.headers...); code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, code, tt.want)
+		}
+	}
+}
+
+func TestIntegrationSyncAPIVersions(t *testing.T) {
+	h := newHarness(t)
+	acc := h.accounts[0]
+	token := h.token(t, acc)
+
+	tests := []struct {
+		name    string
+		path    string
+		headers []string
+		wantV2  bool
+	}{
+		{"v1", "/api/v1/inventory/%s/sync?section=inventory", nil, false},
+		{"v1 with Accept-Version", "/api/v1/inventory/%s/sync?section=inventory", []string{"Accept-Version", "2"}, true},
+		{"v2 route", "/api/v2/inventory/%s/sync?section=inventory", nil, true},
+	}
+	for i, tt := range tests {
+		// Distinct documents so no sync is skipped as unchanged
+		body := fmt.Sprintf(`{"Items":[],"Coins":%d}`, i)
+		headers := append([]string{"X-Token", token}, tt.headers...)
+		code, data := h.do(t, http.MethodPost, fmt.Sprintf(tt.path, acc.RobloxUserID), body, headers...)
+		// No buffer in the harness: every write is persisted, so 200 on both
+		if code != http.StatusOK || data["status"] != "synced" {
+			t.Fatalf("%s: status %d, data %v", tt.name, code, data)
+		}
+		if _, ok := data["persistence"]; ok != tt.wantV2 {
+			t.Errorf("%s: persistence present = %v, want %v", tt.name, ok, tt.wantV2)
+		}
+	}
+}
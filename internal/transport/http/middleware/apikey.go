@@ -2,9 +2,15 @@ package middleware
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"vinzhub-rest-api/internal/service"
 	"vinzhub-rest-api/internal/transport/http/response"
@@ -17,55 +23,282 @@ type ContextKey string
 const (
 	// ContextKeyTokenData is the key for storing token data in request context.
 	ContextKeyTokenData ContextKey = "token_data"
+	// ContextKeyAPIKeyAuth marks requests authenticated with an API key.
+	ContextKeyAPIKeyAuth ContextKey = "api_key_auth"
+	// ContextKeyAdminAuth marks requests authenticated with an admin key.
+	ContextKeyAdminAuth ContextKey = "admin_auth"
+	// ContextKeyScopedKey holds the ScopedKey of requests authenticated
+	// with a scoped API key.
+	ContextKeyScopedKey ContextKey = "scoped_key"
 )
 
-// tokenServiceInstance is set by SetTokenService for token validation.
-var tokenServiceInstance *service.TokenService
+// TokenValidator validates session tokens (X-Token).
+type TokenValidator interface {
+	ValidateToken(ctx context.Context, token string) (*service.TokenData, error)
+}
 
-// SetTokenService sets the token service for middleware to use.
-func SetTokenService(ts *service.TokenService) {
-	tokenServiceInstance = ts
+// KeyValidator checks API keys (X-API-Key or Authorization: Bearer).
+type KeyValidator interface {
+	ValidAPIKey(key string) bool
 }
 
-// APIKeyAuth middleware validates API key or session token.
-// Supports both X-API-Key (for server-to-server) and X-Token (for client sessions).
-func APIKeyAuth(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Skip auth for health check
-		if r.URL.Path == "/api/v1/health" || r.URL.Path == "/api/v1/ready" {
-			next.ServeHTTP(w, r)
-			return
+// StaticKeys is a fixed set of API keys.
+type StaticKeys []string
+
+// ValidAPIKey implements KeyValidator.
+func (k StaticKeys) ValidAPIKey(key string) bool {
+	return isValidKey(key, k)
+}
+
+// EnvKeys reads API_KEYS (comma-separated) or API_KEY on every check, so
+// keys set after startup (demo mode) are picked up.
+type EnvKeys struct{}
+
+// ValidAPIKey implements KeyValidator.
+func (EnvKeys) ValidAPIKey(key string) bool {
+	return isValidKey(key, getValidAPIKeys())
+}
+
+// HashedKeys is a set of API keys known only by their SHA-256
+// (API_KEY_HASHES), so the keys themselves never need to be on the server.
+type HashedKeys [][sha256.Size]byte
+
+// ParseKeyHashes parses hex SHA-256 digests of API keys, as printed by
+// `printf %s "$KEY" | sha256sum`.
+func ParseKeyHashes(hashes []string) (HashedKeys, error) {
+	keys := make(HashedKeys, 0, len(hashes))
+	for _, h := range hashes {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "" {
+			continue
+		}
+		digest, err := hex.DecodeString(h)
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("invalid API key hash %q: want 64 hex characters", h)
 		}
+		var sum [sha256.Size]byte
+		copy(sum[:], digest)
+		keys = append(keys, sum)
+	}
+	return keys, nil
+}
 
-		// Skip auth for admin dashboard and static files
-		if r.URL.Path == "/admin" || strings.HasPrefix(r.URL.Path, "/static/") {
-			next.ServeHTTP(w, r)
-			return
+// ValidAPIKey implements KeyValidator.
+func (k HashedKeys) ValidAPIKey(key string) bool {
+	sum := sha256.Sum256([]byte(key))
+	match := 0
+	for i := range k {
+		match |= subtle.ConstantTimeCompare(sum[:], k[i][:])
+	}
+	return match == 1
+}
+
+// AnyKeys accepts keys any of its validators accepts.
+type AnyKeys []KeyValidator
+
+// ValidAPIKey implements KeyValidator.
+func (k AnyKeys) ValidAPIKey(key string) bool {
+	for _, keys := range k {
+		if keys != nil && keys.ValidAPIKey(key) {
+			return true
 		}
+	}
+	return false
+}
 
-		// Skip auth for docs
-		if strings.HasPrefix(r.URL.Path, "/docs") {
-			next.ServeHTTP(w, r)
-			return
+// KeyStore resolves API keys managed at runtime (the admin API) to their
+// scopes; empty scopes mean a full API key.
+type KeyStore interface {
+	LookupAPIKey(key string) (scopes []string, ok bool)
+}
+
+// ScopedKey describes the scoped API key a request was authenticated with.
+type ScopedKey struct {
+	ID     string // Not secret: identifies the key in metrics and logs
+	Scopes []string
+}
+
+// HasScope reports whether the key was granted scope.
+func (k *ScopedKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyID derives the non-secret ID of an API key.
+func KeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// AuthOption tunes NewAuthMiddleware.
+type AuthOption func(*authMiddleware)
+
+// WithPublicRequests replaces the check for requests that skip auth. The
+// default lets health, dashboard, docs and token issuance through.
+func WithPublicRequests(public func(r *http.Request) bool) AuthOption {
+	return func(a *authMiddleware) {
+		a.public = public
+	}
+}
+
+// WithScopedKeys accepts further API keys that only authorize what scope
+// covers, e.g. service.ScopeInventoryExists for a website that shows who
+// uses the product. These callers don't count as API key auth.
+func WithScopedKeys(scope string, keys KeyValidator) AuthOption {
+	return WithKeyScopes([]string{scope}, keys)
+}
+
+// WithKeyScopes accepts further API keys that authorize what any of scopes
+// covers (API_KEYS_JSON). A key listed in several sets gets all their scopes.
+func WithKeyScopes(scopes []string, keys KeyValidator) AuthOption {
+	return func(a *authMiddleware) {
+		a.scoped = append(a.scoped, scopedKeys{scopes: scopes, keys: keys})
+	}
+}
+
+// WithAdminKeys sets the keys allowed to use the admin API (ADMIN_API_KEY).
+// They are full API keys as well. Without admin keys only API keys scoped
+// to admin can use the admin API.
+func WithAdminKeys(keys KeyValidator) AuthOption {
+	return func(a *authMiddleware) {
+		a.admin = keys
+	}
+}
+
+// WithKeyStore accepts the API keys of store as well, full or scoped.
+func WithKeyStore(store KeyStore) AuthOption {
+	return func(a *authMiddleware) {
+		a.store = store
+	}
+}
+
+// scopedKeys is a set of API keys limited to some scopes.
+type scopedKeys struct {
+	scopes []string
+	keys   KeyValidator
+}
+
+// authMiddleware holds the dependencies of one auth middleware instance.
+type authMiddleware struct {
+	tokens TokenValidator
+	keys   KeyValidator
+	admin  KeyValidator
+	scoped []scopedKeys
+	store  KeyStore
+	public func(r *http.Request) bool
+}
+
+// NewAuthMiddleware returns middleware that validates an API key or session
+// token. Supports both X-API-Key (for server-to-server) and X-Token (for
+// client sessions). tokens may be nil to accept API keys only.
+func NewAuthMiddleware(tokens TokenValidator, keys KeyValidator, opts ...AuthOption) func(http.Handler) http.Handler {
+	a := &authMiddleware{tokens: tokens, keys: keys, public: isPublicRequest}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a.handler
+}
+
+// isPublicRequest reports requests that never need auth.
+func isPublicRequest(r *http.Request) bool {
+	switch {
+	case r.URL.Path == "/api/v1/health" || r.URL.Path == "/api/v1/health/detail" || r.URL.Path == "/api/v1/ready":
+		return true
+	case strings.HasPrefix(r.URL.Path, "/docs"):
+		return true
+	case r.URL.Path == "/api/v1/auth/token" && r.Method == "POST":
+		return true // Token generation
+	}
+	return false
+}
+
+// KnownScope reports whether scope is one scopeAllows understands.
+func KnownScope(scope string) bool {
+	switch scope {
+	case service.ScopeInventoryRead, service.ScopeInventoryWrite, service.ScopeInventoryExists,
+		service.ScopeSyncUnthrottled, service.ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// isAdminPath reports the admin API and Prometheus metrics.
+func isAdminPath(path string) bool {
+	return strings.HasPrefix(path, "/api/v1/admin/") || path == "/metrics" || strings.HasPrefix(path, "/debug/pprof/")
+}
+
+// isSyncRequest reports inventory syncs (v1 and v2).
+func isSyncRequest(r *http.Request) bool {
+	return r.Method == http.MethodPost && (strings.HasPrefix(r.URL.Path, "/api/v1/inventory/") || strings.HasPrefix(r.URL.Path, "/api/v2/inventory/")) &&
+		strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/sync")
+}
+
+// requiredScope names the scope a scoped API key needs for a request, or ""
+// when no scope covers it (full API keys only).
+func requiredScope(r *http.Request) string {
+	switch {
+	case isAdminPath(r.URL.Path):
+		return service.ScopeAdmin
+	case isSyncRequest(r):
+		return service.ScopeInventoryWrite
+	case scopeAllows(service.ScopeInventoryRead, r):
+		return service.ScopeInventoryRead
+	}
+	return ""
+}
+
+// scopeAllows reports whether a restricted token's or API key's scope
+// covers a request. Handlers still check which user a token is bound to.
+func scopeAllows(scope string, r *http.Request) bool {
+	switch scope {
+	case service.ScopeInventoryRead:
+		if r.Method == http.MethodPost && r.URL.Path == "/api/v1/inventory/view" {
+			return true // The handler checks each user against the binding
 		}
+		read := r.Method == http.MethodGet || r.Method == http.MethodHead
+		return read && (strings.HasPrefix(r.URL.Path, "/api/v1/inventory/") || strings.HasPrefix(r.URL.Path, "/api/v2/inventory/"))
+	case service.ScopeInventoryWrite, service.ScopeSyncUnthrottled:
+		return isSyncRequest(r)
+	case service.ScopeAdmin:
+		return isAdminPath(r.URL.Path)
+	case service.ScopeInventoryExists:
+		return r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/api/v1/inventory/") &&
+			strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/exists")
+	}
+	return false
+}
 
-		// Skip auth for token generation endpoint
-		if r.URL.Path == "/api/v1/auth/token" && r.Method == "POST" {
+func (a *authMiddleware) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.public != nil && a.public(r) {
 			next.ServeHTTP(w, r)
 			return
 		}
 
 		// Try X-Token first (session tokens)
 		token := r.Header.Get("X-Token")
-		if token != "" && tokenServiceInstance != nil {
-			tokenData, err := tokenServiceInstance.ValidateToken(r.Context(), token)
+		if token != "" && a.tokens != nil {
+			tokenData, err := a.tokens.ValidateToken(r.Context(), token)
 			if err != nil {
 				response.Error(w, apierror.Unauthorized("Invalid or expired token"))
 				return
 			}
-			
+			if tokenData.Restricted() && !scopeAllows(tokenData.Scope, r) {
+				response.Error(w, apierror.Forbidden("token scope "+tokenData.Scope+" does not allow this request"))
+				return
+			}
+
 			// Store token data in context for handlers to use
 			ctx := context.WithValue(r.Context(), ContextKeyTokenData, tokenData)
+			if tokenData.Restricted() {
+				recordCaller(ctx, "support_token", "issued_by:"+tokenData.IssuedBy)
+			} else {
+				recordCaller(ctx, "token", "key_account:"+strconv.FormatInt(tokenData.KeyAccountID, 10))
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 			return
 		}
@@ -85,17 +318,106 @@ func APIKeyAuth(next http.Handler) http.Handler {
 			return
 		}
 
-		// Validate API key
-		validKeys := getValidAPIKeys()
-		if !isValidKey(apiKey, validKeys) {
+		adminKey := a.admin != nil && a.admin.ValidAPIKey(apiKey)
+		fullKey := adminKey || (a.keys != nil && a.keys.ValidAPIKey(apiKey))
+		var scopes []string
+		if !fullKey && a.store != nil {
+			if stored, ok := a.store.LookupAPIKey(apiKey); ok {
+				fullKey = len(stored) == 0
+				scopes = append(scopes, stored...)
+			}
+		}
+		if fullKey {
+			ctx := context.WithValue(r.Context(), ContextKeyAPIKeyAuth, true)
+			if adminKey {
+				ctx = context.WithValue(ctx, ContextKeyAdminAuth, true)
+			}
+			recordCaller(ctx, "api_key", "key:"+KeyID(apiKey))
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		for _, set := range a.scoped {
+			if set.keys.ValidAPIKey(apiKey) {
+				scopes = append(scopes, set.scopes...)
+			}
+		}
+		if len(scopes) == 0 {
 			response.Error(w, apierror.Unauthorized("Invalid API key"))
 			return
 		}
+		allowed := false
+		for _, scope := range scopes {
+			if scopeAllows(scope, r) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			missingScope(w, r)
+			return
+		}
+		ctx := context.WithValue(r.Context(), ContextKeyScopedKey, &ScopedKey{ID: KeyID(apiKey), Scopes: scopes})
+		recordCaller(ctx, "scoped_key", "key:"+KeyID(apiKey))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
+// missingScope answers 403 naming the scope a scoped API key lacks.
+func missingScope(w http.ResponseWriter, r *http.Request) {
+	scope := requiredScope(r)
+	if scope == "" {
+		response.Error(w, apierror.Forbidden("this endpoint requires a full API key"))
+		return
+	}
+	response.Error(w, apierror.Forbidden("API key lacks the "+scope+" scope").
+		WithDetails(apierror.FieldError{Field: "scope", Message: scope}))
+}
+
+// RequireAdmin refuses requests not authenticated with an admin key or an
+// API key scoped to admin (session tokens, regular and other scoped keys).
+// It runs after the auth middleware.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsAdminAuth(r.Context()) {
+			response.Error(w, apierror.Forbidden("this endpoint requires an admin API key"))
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// RequireAPIKey is RequireAdmin.
+//
+// Deprecated: use RequireAdmin. Kept for one release.
+func RequireAPIKey(next http.Handler) http.Handler {
+	return RequireAdmin(next)
+}
+
+// defaultTokenService backs the deprecated SetTokenService/APIKeyAuth pair.
+var defaultTokenService atomic.Pointer[service.TokenService]
+
+// SetTokenService sets the token service used by APIKeyAuth.
+//
+// Deprecated: pass the token service to NewAuthMiddleware. Kept for one release.
+func SetTokenService(ts *service.TokenService) {
+	defaultTokenService.Store(ts)
+}
+
+// APIKeyAuth is auth middleware using the token service set through
+// SetTokenService and keys from the environment.
+//
+// Deprecated: use NewAuthMiddleware. Kept for one release.
+func APIKeyAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var tokens TokenValidator
+		if ts := defaultTokenService.Load(); ts != nil {
+			tokens = ts
+		}
+		NewAuthMiddleware(tokens, EnvKeys{})(next).ServeHTTP(w, r)
+	})
+}
+
 // getValidAPIKeys returns list of valid API keys from environment.
 func getValidAPIKeys() []string {
 	// Get from environment variable (comma-separated)
@@ -116,14 +438,20 @@ func getValidAPIKeys() []string {
 	return keys
 }
 
-// isValidKey checks if the provided key is in the valid keys list.
+// isValidKey checks if the provided key is in the valid keys list. Keys are
+// compared as SHA-256 digests in constant time, so neither a key's content
+// nor its length leaks through response timing.
 func isValidKey(key string, validKeys []string) bool {
+	sum := sha256.Sum256([]byte(key))
+	match := 0
 	for _, valid := range validKeys {
-		if key == valid {
-			return true
+		if valid == "" {
+			continue
 		}
+		validSum := sha256.Sum256([]byte(valid))
+		match |= subtle.ConstantTimeCompare(sum[:], validSum[:])
 	}
-	return false
+	return match == 1
 }
 
 // GetTokenDataFromContext retrieves token data from request context.
@@ -134,3 +462,28 @@ func GetTokenDataFromContext(ctx context.Context) *service.TokenData {
 	return nil
 }
 
+// GetScopedKeyFromContext returns the scoped API key a request was
+// authenticated with, or nil.
+func GetScopedKeyFromContext(ctx context.Context) *ScopedKey {
+	if key, ok := ctx.Value(ContextKeyScopedKey).(*ScopedKey); ok {
+		return key
+	}
+	return nil
+}
+
+// IsAPIKeyAuth reports whether the request was authenticated with an API key
+// (server-to-server callers, which see every inventory field).
+func IsAPIKeyAuth(ctx context.Context) bool {
+	ok, _ := ctx.Value(ContextKeyAPIKeyAuth).(bool)
+	return ok
+}
+
+// IsAdminAuth reports whether the request was authenticated with an admin
+// key or one scoped to admin.
+func IsAdminAuth(ctx context.Context) bool {
+	if ok, _ := ctx.Value(ContextKeyAdminAuth).(bool); ok {
+		return true
+	}
+	key := GetScopedKeyFromContext(ctx)
+	return key != nil && key.HasScope(service.ScopeAdmin)
+}
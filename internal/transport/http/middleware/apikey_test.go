@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"vinzhub-rest-api/internal/service"
+)
+
+// adminStatus returns the status of an admin API request made with apiKey
+// through auth and RequireAdmin.
+func adminStatus(t *testing.T, auth func(http.Handler) http.Handler, apiKey string) int {
+	t.Helper()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/stats", nil)
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	rec := httptest.NewRecorder()
+	auth(RequireAdmin(ok)).ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestRequireAdmin(t *testing.T) {
+	withAdmin := NewAuthMiddleware(nil, StaticKeys{"regular"},
+		WithAdminKeys(StaticKeys{"admin"}),
+		WithKeyScopes([]string{"admin"}, StaticKeys{"scoped-admin"}),
+		WithKeyScopes([]string{"inventory:read"}, StaticKeys{"scoped-read"}))
+	withoutAdmin := NewAuthMiddleware(nil, StaticKeys{"regular"},
+		WithKeyScopes([]string{"admin"}, StaticKeys{"scoped-admin"}))
+
+	tests := []struct {
+		name string
+		auth func(http.Handler) http.Handler
+		key  string
+		want int
+	}{
+		{"admin key", withAdmin, "admin", http.StatusOK},
+		{"admin scoped key", withAdmin, "scoped-admin", http.StatusOK},
+		{"regular key", withAdmin, "regular", http.StatusForbidden},
+		{"read scoped key", withAdmin, "scoped-read", http.StatusForbidden},
+		{"unknown key", withAdmin, "nope", http.StatusUnauthorized},
+		{"no key", withAdmin, "", http.StatusUnauthorized},
+		{"regular key, no admin keys set", withoutAdmin, "regular", http.StatusForbidden},
+		{"admin scoped key, no admin keys set", withoutAdmin, "scoped-admin", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := adminStatus(t, tt.auth, tt.key); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeTokens is a TokenValidator over a fixed set of session tokens.
+type fakeTokens map[string]*service.TokenData
+
+func (f fakeTokens) ValidateToken(ctx context.Context, token string) (*service.TokenData, error) {
+	if data, ok := f[token]; ok {
+		return data, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+// authStatus returns the status of an inventory read through auth with the
+// given header set.
+func authStatus(auth func(http.Handler) http.Handler, header, value string) int {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/inventory/100", nil)
+	req.Header.Set(header, value)
+	rec := httptest.NewRecorder()
+	auth(ok).ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestAuthMiddlewareInstancesAreIsolated(t *testing.T) {
+	gameA := NewAuthMiddleware(fakeTokens{"token-a": {RobloxUserID: "100"}}, StaticKeys{"key-a"})
+	gameB := NewAuthMiddleware(fakeTokens{"token-b": {RobloxUserID: "100"}}, StaticKeys{"key-b"})
+
+	tests := []struct {
+		auth   func(http.Handler) http.Handler
+		header string
+		value  string
+		want   int
+	}{
+		{gameA, "X-API-Key", "key-a", http.StatusOK},
+		{gameA, "X-API-Key", "key-b", http.StatusUnauthorized},
+		{gameA, "X-Token", "token-a", http.StatusOK},
+		{gameA, "X-Token", "token-b", http.StatusUnauthorized},
+		{gameB, "X-API-Key", "key-b", http.StatusOK},
+		{gameB, "X-API-Key", "key-a", http.StatusUnauthorized},
+		{gameB, "Authorization", "Bearer key-b", http.StatusOK},
+		{gameB, "X-Token", "token-b", http.StatusOK},
+		{gameB, "X-Token", "token-a", http.StatusUnauthorized},
+	}
+
+	// Both instances serve at once; run with -race to catch shared state
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for _, tt := range tests {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if got := authStatus(tt.auth, tt.header, tt.value); got != tt.want {
+					t.Errorf("%s %q: status = %d, want %d", tt.header, tt.value, got, tt.want)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+func TestAPIKeyAuthShim(t *testing.T) {
+	t.Setenv("API_KEYS", "")
+	t.Setenv("API_KEY", "env-key")
+
+	if got := authStatus(APIKeyAuth, "X-API-Key", "env-key"); got != http.StatusOK {
+		t.Errorf("env key: status = %d, want 200", got)
+	}
+	// The environment is read on every request
+	t.Setenv("API_KEY", "rotated")
+	if got := authStatus(APIKeyAuth, "X-API-Key", "env-key"); got != http.StatusUnauthorized {
+		t.Errorf("old env key: status = %d, want 401", got)
+	}
+	if got := authStatus(APIKeyAuth, "X-API-Key", "rotated"); got != http.StatusOK {
+		t.Errorf("rotated env key: status = %d, want 200", got)
+	}
+}
@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCompressMinBytes is the smallest response body compressed unless
+// configured otherwise. Health checks, errors and most admin answers stay
+// below it and are sent as-is.
+const DefaultCompressMinBytes = 4096
+
+// gzipWriters reuses compressors, which are costly to allocate.
+var gzipWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(io.Discard) },
+}
+
+// compressibleTypes are the Content-Type prefixes worth compressing.
+var compressibleTypes = []string{
+	"application/json",
+	"application/x-ndjson",
+	"application/javascript",
+	"image/svg+xml",
+	"text/",
+}
+
+// Compress gzips responses of at least minBytes for clients that send
+// Accept-Encoding: gzip. The body is held back until it reaches minBytes,
+// so small responses go out unchanged and uncompressed; Vary:
+// Accept-Encoding is set on every response either way. HEAD requests and
+// responses that already carry a Content-Encoding are passed through.
+func Compress(minBytes int) func(http.Handler) http.Handler {
+	if minBytes <= 0 {
+		minBytes = DefaultCompressMinBytes
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, minBytes: minBytes}
+			next.ServeHTTP(cw, r)
+			cw.close() // Not deferred: after a panic, Recovery answers with nothing sent yet
+		})
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		_, q, found := strings.Cut(strings.ReplaceAll(params, " ", ""), "q=")
+		if !found {
+			return true
+		}
+		if v, err := strconv.ParseFloat(q, 64); err == nil && v > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter buffers the start of a response until it knows whether
+// the body is large enough to compress.
+type compressWriter struct {
+	http.ResponseWriter
+	minBytes int
+
+	status  int
+	buf     bytes.Buffer
+	decided bool
+	gz      *gzip.Writer // Set once compressing
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.status == 0 && !w.decided {
+		w.status = status
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf.Write(b)
+	if w.buf.Len() >= w.minBytes {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// decide sends the status line, compressing from here on when large is set
+// and the response is compressible, then writes out what was held back.
+func (w *compressWriter) decide(large bool) error {
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	h := w.Header()
+	if large && w.compressible() {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", "gzip")
+		w.gz = gzipWriters.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf = bytes.Buffer{}
+	return err
+}
+
+// compressible reports whether the response may be gzipped.
+func (w *compressWriter) compressible() bool {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || w.status < 200 ||
+		w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+	contentType := h.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(w.buf.Bytes())
+	}
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Flush sends what is buffered. A handler that flushes is streaming a body
+// of unknown size, so it is compressed regardless of the threshold.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			return
+		}
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets protocol upgrades through before anything was written.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := w.ResponseWriter.(http.Hijacker); ok && !w.decided {
+		w.decided = true
+		return hj.Hijack()
+	}
+	return nil, nil, errors.New("response can't be hijacked")
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController, e.g. to
+// extend the write deadline of a long admin call.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close finishes the response: a body that stayed under the threshold is
+// sent uncompressed, a compressed one gets its gzip trailer.
+func (w *compressWriter) close() {
+	if !w.decided {
+		if w.status == 0 {
+			return // Nothing written; net/http sends 200 with no body
+		}
+		w.decide(false)
+		return
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		w.gz.Reset(io.Discard)
+		gzipWriters.Put(w.gz)
+		w.gz = nil
+	}
+}
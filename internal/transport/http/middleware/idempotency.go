@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"vinzhub-rest-api/internal/cache"
+	"vinzhub-rest-api/internal/metrics"
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+)
+
+const (
+	// IdempotencyKeyHeader names the header carrying a client's key.
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// maxIdempotencyKeyLength bounds keys, which are stored as given.
+	maxIdempotencyKeyLength = 255
+
+	// idempotencyWait is how long a duplicate waits for the first request
+	// with its key to finish before answering 409.
+	idempotencyWait = 5 * time.Second
+	// idempotencyPoll is how often a waiting duplicate checks on it.
+	idempotencyPoll = 50 * time.Millisecond
+	// idempotencyClaimTTL bounds how long a claim outlives an instance that
+	// died while running the request.
+	idempotencyClaimTTL = time.Minute
+)
+
+// idempotentRequests counts requests carrying an idempotency key by outcome:
+// first (ran), replayed, in_progress (gave up waiting), mismatch (key reused
+// for another request) and unavailable (store failed; ran without a key).
+var idempotentRequests = metrics.NewCounterVec("vinzhub_idempotent_requests_total",
+	"Requests carrying an Idempotency-Key, by outcome.", "outcome")
+
+// Idempotency makes requests carrying an Idempotency-Key header safe to
+// retry. The first request with a key runs; a successful (2xx) response is
+// remembered for ttl and replayed to any request repeating the key, with an
+// Idempotent-Replayed header. A duplicate arriving while the first is still
+// running waits for its response, answering 409 if it takes over 5s.
+// Failed requests forget their key so they can be retried. Keys are scoped
+// by the route's param (e.g. roblox_user_id), so different users may use
+// the same value. Requests
+// without the header, and every request when ttl <= 0, run as usual; when
+// the store fails, requests run without idempotency rather than fail.
+func Idempotency(store cache.IdempotencyStore, ttl time.Duration, param string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if store == nil || ttl <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				response.Error(w, apierror.BadRequest("Idempotency-Key must be at most 255 characters"))
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					response.Error(w, apierror.PayloadTooLarge(""))
+				} else {
+					response.Error(w, apierror.BadRequest("Failed to read request body"))
+				}
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			sum := sha256.Sum256(body)
+			requestHash := r.Method + " " + r.URL.Path + " " + hex.EncodeToString(sum[:])
+
+			scoped := chi.URLParam(r, param) + ":" + key
+			record, err := claimIdempotencyKey(r.Context(), store, scoped, requestHash, min(ttl, idempotencyClaimTTL))
+			switch {
+			case err != nil:
+				logger.WarnContext(r.Context(), "Idempotency store unavailable, running request without its key", "error", err)
+				idempotentRequests.Inc("unavailable")
+				next.ServeHTTP(w, r)
+			case record == nil:
+				idempotentRequests.Inc("first")
+				runIdempotent(w, r, next, store, scoped, requestHash, ttl)
+			case record.RequestHash != requestHash:
+				idempotentRequests.Inc("mismatch")
+				response.Error(w, apierror.New(http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED",
+					"Idempotency-Key was already used for a different request"))
+			case !record.Done:
+				idempotentRequests.Inc("in_progress")
+				response.Error(w, apierror.New(http.StatusConflict, "IDEMPOTENCY_IN_PROGRESS",
+					"a request with this Idempotency-Key is still being processed; retry later"))
+			default:
+				idempotentRequests.Inc("replayed")
+				if record.ContentType != "" {
+					w.Header().Set("Content-Type", record.ContentType)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(record.Status)
+				w.Write(record.Body)
+			}
+		})
+	}
+}
+
+// claimIdempotencyKey claims key, or returns its record once the request
+// holding it finishes (or after idempotencyWait). A key released by a
+// failed request is claimed again.
+func claimIdempotencyKey(ctx context.Context, store cache.IdempotencyStore, key, requestHash string, claimTTL time.Duration) (*cache.IdempotencyRecord, error) {
+	deadline := time.Now().Add(idempotencyWait)
+	record, err := store.Claim(ctx, key, requestHash, claimTTL)
+	for err == nil && record != nil && !record.Done && record.RequestHash == requestHash && time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(idempotencyPoll):
+		}
+		record, err = store.Get(ctx, key)
+		if err == nil && record == nil {
+			record, err = store.Claim(ctx, key, requestHash, claimTTL)
+		}
+	}
+	return record, err
+}
+
+// runIdempotent runs the first request with a key and remembers a
+// successful response, or forgets the key when it fails (or panics).
+func runIdempotent(w http.ResponseWriter, r *http.Request, next http.Handler, store cache.IdempotencyStore, key, requestHash string, ttl time.Duration) {
+	rec := &recordingWriter{ResponseWriter: w}
+	completed := false
+	defer func() {
+		if !completed {
+			store.Release(context.WithoutCancel(r.Context()), key)
+		}
+	}()
+
+	next.ServeHTTP(rec, r)
+
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status < 200 || status >= 300 {
+		return
+	}
+	err := store.Complete(context.WithoutCancel(r.Context()), key, &cache.IdempotencyRecord{
+		RequestHash: requestHash,
+		Done:        true,
+		Status:      status,
+		ContentType: w.Header().Get("Content-Type"),
+		Body:        rec.body.Bytes(),
+	}, ttl)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Failed to remember idempotent response", "error", err)
+		return
+	}
+	completed = true
+}
+
+// recordingWriter passes a response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
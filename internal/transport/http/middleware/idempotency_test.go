@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"vinzhub-rest-api/internal/cache"
+)
+
+// idempotentSync serves POST /sync behind Idempotency with a handler that
+// counts its runs and answers with the run number.
+type idempotentSync struct {
+	router http.Handler
+	runs   atomic.Int64
+	status atomic.Int64 // Status the handler answers with
+	delay  time.Duration
+}
+
+func newIdempotentSync(store cache.IdempotencyStore) *idempotentSync {
+	s := &idempotentSync{delay: 100 * time.Millisecond}
+	s.status.Store(http.StatusAccepted)
+	r := chi.NewRouter()
+	r.With(Idempotency(store, time.Minute, "roblox_user_id")).Post("/api/v1/inventory/{roblox_user_id}/sync",
+		func(w http.ResponseWriter, r *http.Request) {
+			run := s.runs.Add(1)
+			time.Sleep(s.delay)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(int(s.status.Load()))
+			fmt.Fprintf(w, `{"run":%d}`, run)
+		})
+	s.router = r
+	return s
+}
+
+func (s *idempotentSync) sync(user, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/inventory/"+user+"/sync", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyConcurrentDuplicates(t *testing.T) {
+	s := newIdempotentSync(cache.NewMemoryIdempotencyStore())
+
+	const retries = 10
+	recs := make([]*httptest.ResponseRecorder, retries)
+	var wg sync.WaitGroup
+	for i := range recs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recs[i] = s.sync("100", "retry-1", `{"Items":[1]}`)
+		}()
+	}
+	wg.Wait()
+
+	if n := s.runs.Load(); n != 1 {
+		t.Fatalf("handler ran %d times for one key, want 1", n)
+	}
+	replayed := 0
+	for _, rec := range recs {
+		if rec.Code != http.StatusAccepted || rec.Body.String() != `{"run":1}` {
+			t.Errorf("response = %d %s, want the first request's 202", rec.Code, rec.Body)
+		}
+		if rec.Header().Get("Idempotent-Replayed") == "true" {
+			replayed++
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("replayed Content-Type = %q", ct)
+			}
+		}
+	}
+	if replayed != retries-1 {
+		t.Errorf("%d responses replayed, want %d", replayed, retries-1)
+	}
+
+	// A later retry is replayed as well
+	if rec := s.sync("100", "retry-1", `{"Items":[1]}`); rec.Body.String() != `{"run":1}` || s.runs.Load() != 1 {
+		t.Errorf("later retry = %s after %d runs, want the replay", rec.Body, s.runs.Load())
+	}
+}
+
+func TestIdempotencyKeyScoping(t *testing.T) {
+	s := newIdempotentSync(cache.NewMemoryIdempotencyStore())
+	s.delay = 0
+
+	s.sync("100", "same", `{}`)
+	if rec := s.sync("200", "same", `{}`); rec.Body.String() != `{"run":2}` {
+		t.Errorf("another user's request with the same key = %s, want it run", rec.Body)
+	}
+	if rec := s.sync("100", "same", `{"Items":[2]}`); rec.Code != http.StatusUnprocessableEntity ||
+		!strings.Contains(rec.Body.String(), "IDEMPOTENCY_KEY_REUSED") {
+		t.Errorf("key reused with another body = %d %s, want 422 IDEMPOTENCY_KEY_REUSED", rec.Code, rec.Body)
+	}
+	for i := 0; i < 2; i++ {
+		s.sync("100", "", `{}`)
+	}
+	if n := s.runs.Load(); n != 4 {
+		t.Errorf("handler ran %d times, want requests without a key to always run", n)
+	}
+	if rec := s.sync("100", strings.Repeat("k", 256), `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("overlong key = %d, want 400", rec.Code)
+	}
+}
+
+func TestIdempotencyFailedRequestReleasesKey(t *testing.T) {
+	s := newIdempotentSync(cache.NewMemoryIdempotencyStore())
+	s.delay = 0
+
+	s.status.Store(http.StatusServiceUnavailable)
+	if rec := s.sync("100", "k", `{}`); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	s.status.Store(http.StatusAccepted)
+	if rec := s.sync("100", "k", `{}`); rec.Code != http.StatusAccepted || rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("retry after a failure = %d (replayed %q), want it run", rec.Code, rec.Header().Get("Idempotent-Replayed"))
+	}
+	if n := s.runs.Load(); n != 2 {
+		t.Errorf("handler ran %d times, want 2", n)
+	}
+}
+
+// failingIdempotencyStore is an IdempotencyStore that is down.
+type failingIdempotencyStore struct{ cache.IdempotencyStore }
+
+func (failingIdempotencyStore) Claim(context.Context, string, string, time.Duration) (*cache.IdempotencyRecord, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestIdempotencyStoreDown(t *testing.T) {
+	s := newIdempotentSync(failingIdempotencyStore{})
+	s.delay = 0
+	for i := 0; i < 2; i++ {
+		if rec := s.sync("100", "k", `{}`); rec.Code != http.StatusAccepted {
+			t.Errorf("status = %d, want the request run without idempotency", rec.Code)
+		}
+	}
+	if n := s.runs.Load(); n != 2 {
+		t.Errorf("handler ran %d times, want 2", n)
+	}
+}
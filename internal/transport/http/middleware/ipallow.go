@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"vinzhub-rest-api/internal/transport/http/response"
+	"vinzhub-rest-api/pkg/apierror"
+)
+
+// ParseIPAllowlist parses CIDRs ("10.0.0.0/8", "2001:db8::/32"); a bare
+// address allows just itself.
+func ParseIPAllowlist(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q: %w", entry, err)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// IPAllowlist refuses requests whose client IP is outside allowed with 403.
+// An empty allowlist allows everyone.
+//
+// With trustProxy the client IP is the last X-Forwarded-For entry, the one
+// the reverse proxy in front of the API appended; earlier entries come from
+// the client and are ignored. Without it the header is ignored altogether.
+func IPAllowlist(allowed []netip.Prefix, trustProxy bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(allowed) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addr, ok := clientAddr(r, trustProxy)
+			if !ok || !ipAllowed(allowed, addr) {
+				response.Error(w, apierror.Forbidden("client IP is not allowed to use this endpoint"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientAddr returns the address a request came from, per IPAllowlist.
+func clientAddr(r *http.Request, trustProxy bool) (netip.Addr, bool) {
+	host := r.RemoteAddr
+	if trustProxy {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			host = strings.TrimSpace(hops[len(hops)-1])
+		}
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// ipAllowed reports whether addr is in one of the prefixes.
+func ipAllowed(allowed []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
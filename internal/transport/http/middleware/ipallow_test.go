@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseIPAllowlist(t *testing.T) {
+	tests := []struct {
+		entries []string
+		want    []string
+		wantErr bool
+	}{
+		{[]string{"10.0.0.0/8", " 192.168.1.7 ", ""}, []string{"10.0.0.0/8", "192.168.1.7/32"}, false},
+		{[]string{"10.1.2.3/8"}, []string{"10.0.0.0/8"}, false}, // Host bits masked
+		{[]string{"2001:db8::/32", "2001:db8::1"}, []string{"2001:db8::/32", "2001:db8::1/128"}, false},
+		{[]string{"::ffff:10.0.0.1"}, []string{"10.0.0.1/32"}, false}, // IPv4-mapped unmapped
+		{[]string{"10.0.0.0/33"}, nil, true},
+		{[]string{"not-an-ip"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseIPAllowlist(tt.entries)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseIPAllowlist(%q) err = %v, want error %v", tt.entries, err, tt.wantErr)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseIPAllowlist(%q) = %v, want %v", tt.entries, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i].String() != tt.want[i] {
+				t.Errorf("ParseIPAllowlist(%q)[%d] = %s, want %s", tt.entries, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestIPAllowlist(t *testing.T) {
+	allowed, err := ParseIPAllowlist([]string{"10.0.0.0/8", "2001:db8::/32", "203.0.113.9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string // X-Forwarded-For header lines
+		trustProxy bool
+		want       int
+	}{
+		{"IPv4 in CIDR", "10.1.2.3:5000", nil, false, http.StatusOK},
+		{"IPv4 outside CIDR", "11.0.0.1:5000", nil, false, http.StatusForbidden},
+		{"single address", "203.0.113.9:5000", nil, false, http.StatusOK},
+		{"next to single address", "203.0.113.10:5000", nil, false, http.StatusForbidden},
+		{"IPv6 in CIDR", "[2001:db8:1::5]:5000", nil, false, http.StatusOK},
+		{"IPv6 with zone", "[2001:db8::5%eth0]:5000", nil, false, http.StatusOK},
+		{"IPv6 outside CIDR", "[2001:db9::5]:5000", nil, false, http.StatusForbidden},
+		{"IPv4-mapped IPv6", "[::ffff:10.0.0.1]:5000", nil, false, http.StatusOK},
+		{"unparsable remote address", "somewhere", nil, false, http.StatusForbidden},
+
+		{"untrusted header ignored", "11.0.0.1:5000", []string{"10.0.0.1"}, false, http.StatusForbidden},
+		{"untrusted header can't deny", "10.0.0.1:5000", []string{"11.0.0.1"}, false, http.StatusOK},
+		{"trusted proxy hop", "127.0.0.1:5000", []string{"10.0.0.1"}, true, http.StatusOK},
+		{"trusted proxy hop outside", "10.0.0.1:5000", []string{"11.0.0.1"}, true, http.StatusForbidden},
+		{"client-sent entries ignored", "127.0.0.1:5000", []string{"10.0.0.1, 11.0.0.1"}, true, http.StatusForbidden},
+		{"last entry wins", "127.0.0.1:5000", []string{"11.0.0.1, 10.0.0.1"}, true, http.StatusOK},
+		{"last header line wins", "127.0.0.1:5000", []string{"10.0.0.1", "11.0.0.1"}, true, http.StatusForbidden},
+		{"trusted IPv6 hop", "127.0.0.1:5000", []string{"2001:db8::7"}, true, http.StatusOK},
+		{"trusted hop with port", "127.0.0.1:5000", []string{"[2001:db8::7]:443"}, true, http.StatusOK},
+		{"trusted hop garbage", "10.0.0.1:5000", []string{"unknown"}, true, http.StatusForbidden},
+		{"trust without header", "10.0.0.1:5000", nil, true, http.StatusOK},
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/stats", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, line := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", line)
+			}
+			rec := httptest.NewRecorder()
+			IPAllowlist(allowed, tt.trustProxy)(ok).ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusForbidden {
+				var body struct {
+					Success bool `json:"success"`
+				}
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Success {
+					t.Errorf("403 body = %s, want an error envelope", rec.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestIPAllowlistEmptyAllowsEveryone(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "198.51.100.1:5000"
+	rec := httptest.NewRecorder()
+	IPAllowlist(nil, false)(ok).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+}
@@ -1,42 +1,125 @@
 package middleware
 
 import (
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"vinzhub-rest-api/internal/logging"
 )
 
-// Logging is a middleware that logs HTTP requests.
+// logger is the logger of the HTTP middleware.
+var logger = logging.Component("http")
+
+// Logging is AccessLog without excluded paths.
 func Logging(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+	return AccessLog(nil)(next)
+}
+
+// AccessLog logs one structured line per request: method, route pattern,
+// status, bytes written, duration, remote IP and the authenticated caller
+// (request_id comes from the request context). Successful reads are sampled
+// per request ID (LOG_SAMPLE_RATE); writes are always logged and failures
+// are logged at WARN/ERROR so they bypass sampling. Requests to exclude
+// paths (e.g. health checks and metrics scrapes) are only logged when they
+// fail with a 5xx.
+func AccessLog(exclude []string) func(http.Handler) http.Handler {
+	excluded := make(map[string]bool, len(exclude))
+	for _, path := range exclude {
+		if path != "" {
+			excluded[path] = true
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			write := r.Method != http.MethodGet && r.Method != http.MethodHead
+			ctx := logging.WithRequest(r.Context(), GetRequestID(r.Context()), write)
+			r = r.WithContext(ctx)
+
+			// Timing (and the status code) is shared with the metrics middleware
+			timing, w, r, owner := timeRequest(w, r)
+			timing.onDone(func(status int, duration time.Duration) {
+				if excluded[r.URL.Path] && status < 500 {
+					return
+				}
+				level := slog.LevelInfo
+				switch {
+				case status >= 500:
+					level = slog.LevelError
+				case status >= 400:
+					level = slog.LevelWarn
+				}
 
-		// Wrap response writer to capture status code
-		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+				attrs := make([]slog.Attr, 0, 10)
+				attrs = append(attrs,
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+				)
+				if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+					attrs = append(attrs, slog.String("route", rctx.RoutePattern()))
+				}
+				attrs = append(attrs,
+					slog.Int("status", status),
+					slog.Int64("bytes", timing.writer.bytes),
+					slog.Float64("duration_ms", float64(duration.Microseconds())/1000),
+					slog.String("remote_ip", remoteIP(r)),
+					slog.String("principal", timing.principal),
+				)
+				if timing.identity != "" {
+					attrs = append(attrs, slog.String("identity", timing.identity))
+				}
+				logger.LogAttrs(ctx, level, "request", attrs...)
+			})
 
-		// Process request
-		next.ServeHTTP(wrapped, r)
+			// Process request
+			next.ServeHTTP(w, r)
+			if owner {
+				timing.finish()
+			}
+		})
+	}
+}
 
-		// Log request details
-		duration := time.Since(start)
-		log.Printf(
-			"[%s] %s %s %d %s",
-			r.Method,
-			r.URL.Path,
-			r.RemoteAddr,
-			wrapped.statusCode,
-			duration,
-		)
-	})
+// remoteIP is the address the request came from, without the port.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
 
-// responseWriter wraps http.ResponseWriter to capture status code.
+// responseWriter wraps http.ResponseWriter to capture the status code and
+// the bytes written.
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	bytes      int64
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Flush lets streaming handlers flush through the wrapper.
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"vinzhub-rest-api/internal/logging"
+)
+
+// requestIDs returns one request ID kept and one dropped by sampling.
+func requestIDs(t *testing.T) (kept, dropped string) {
+	t.Helper()
+	for i := 0; kept == "" || dropped == ""; i++ {
+		id := fmt.Sprintf("req-%d", i)
+		if logging.Sampled(id) {
+			kept = id
+		} else {
+			dropped = id
+		}
+	}
+	return kept, dropped
+}
+
+func TestAccessLogSamplesWholeRequest(t *testing.T) {
+	previous := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(previous)
+		logging.SetSampleRate(1)
+	})
+	var buf bytes.Buffer
+	logging.Setup(&buf, logging.Options{Level: slog.LevelInfo, SampleRate: 4})
+	kept, dropped := requestIDs(t)
+
+	component := logging.Component("Test")
+	handler := RequestID(AccessLog(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		component.InfoContext(r.Context(), "Handled")
+		component.WarnContext(r.Context(), "Slow")
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	tests := []struct {
+		name      string
+		method    string
+		requestID string
+		wantInfo  bool
+	}{
+		{"sampled read", http.MethodGet, kept, true},
+		{"sampled-out read", http.MethodGet, dropped, false},
+		{"sampled-out write", http.MethodPost, dropped, true},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		req := httptest.NewRequest(tt.method, "/api/v1/inventory/1", nil)
+		req.Header.Set("X-Request-ID", tt.requestID)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		lines := map[string]string{}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			for _, msg := range []string{"msg=Handled", "msg=Slow", "msg=request"} {
+				if strings.Contains(line, msg) {
+					lines[msg] = line
+				}
+			}
+		}
+		for _, msg := range []string{"msg=Handled", "msg=request"} {
+			if _, ok := lines[msg]; ok != tt.wantInfo {
+				t.Errorf("%s: %s logged = %v, want %v", tt.name, msg, ok, tt.wantInfo)
+			}
+		}
+		slow, ok := lines["msg=Slow"]
+		if !ok {
+			t.Errorf("%s: WARN line dropped", tt.name)
+		} else if !strings.Contains(slow, "request_id="+tt.requestID) {
+			t.Errorf("%s: WARN line %q lacks request_id", tt.name, slow)
+		}
+	}
+}
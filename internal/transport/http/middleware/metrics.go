@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vinzhub-rest-api/internal/metrics"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Route classes SLIs are recorded for. Other routes aren't recorded.
+const (
+	RouteClassSync = "sync"
+	RouteClassRead = "read"
+	RouteClassAuth = "auth"
+)
+
+var routeClasses = []string{RouteClassSync, RouteClassRead, RouteClassAuth}
+
+// sloWindow is the rolling window compliance is reported over, in minutes.
+const sloWindow = 60
+
+var sliRequests = metrics.NewCounterVec("vinzhub_sli_requests_total",
+	"Requests by route class and principal, by whether they met the configured latency objective (latency=met|missed) and succeeded (result=success|failure; failure is a 5xx).",
+	"class", "principal", "latency", "result")
+
+// SLOObjectives are the latency objectives per route class and the target
+// fraction of good (successful and fast enough) requests.
+type SLOObjectives struct {
+	Sync   time.Duration
+	Read   time.Duration
+	Auth   time.Duration
+	Target float64
+}
+
+var (
+	sloMu         sync.RWMutex
+	sloObjectives = SLOObjectives{
+		Sync:   500 * time.Millisecond,
+		Read:   300 * time.Millisecond,
+		Auth:   500 * time.Millisecond,
+		Target: 0.99,
+	}
+
+	// sloMinutes holds per-minute good/total counts of each class
+	sloMinutes = map[string]*[sloWindow]sloBucket{
+		RouteClassSync: {},
+		RouteClassRead: {},
+		RouteClassAuth: {},
+	}
+)
+
+// sloBucket counts one minute of requests of a class.
+type sloBucket struct {
+	minute atomic.Int64
+	total  atomic.Int64
+	good   atomic.Int64
+}
+
+// SetSLOObjectives sets the objectives requests are recorded against.
+// Zero fields keep their current value.
+func SetSLOObjectives(o SLOObjectives) {
+	sloMu.Lock()
+	defer sloMu.Unlock()
+	if o.Sync > 0 {
+		sloObjectives.Sync = o.Sync
+	}
+	if o.Read > 0 {
+		sloObjectives.Read = o.Read
+	}
+	if o.Auth > 0 {
+		sloObjectives.Auth = o.Auth
+	}
+	if o.Target > 0 && o.Target < 1 {
+		sloObjectives.Target = o.Target
+	}
+}
+
+// objective returns the latency objective of a route class.
+func (o SLOObjectives) objective(class string) time.Duration {
+	switch class {
+	case RouteClassSync:
+		return o.Sync
+	case RouteClassRead:
+		return o.Read
+	default:
+		return o.Auth
+	}
+}
+
+// Metrics records SLI counters for sync, read and auth routes. It must run
+// after auth so the principal is known; timing is shared with Logging.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timing, w, r, owner := timeRequest(w, r)
+		principal := principalOf(r)
+		timing.onDone(func(status int, duration time.Duration) {
+			if class := routeClass(r); class != "" {
+				recordSLI(class, principal, status, duration)
+			}
+		})
+
+		next.ServeHTTP(w, r)
+		if owner {
+			timing.finish()
+		}
+	})
+}
+
+// principalOf names the kind of caller, never the caller itself, to keep
+// label cardinality fixed.
+func principalOf(r *http.Request) string {
+	switch {
+	case GetTokenDataFromContext(r.Context()) != nil:
+		return "token"
+	case IsAPIKeyAuth(r.Context()):
+		return "api_key"
+	case GetScopedKeyFromContext(r.Context()) != nil:
+		return "scoped_key"
+	default:
+		return "anonymous"
+	}
+}
+
+// routeClass classifies a request by its matched route pattern. Called once
+// the request is served, when chi has recorded the full pattern.
+func routeClass(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return ""
+	}
+	pattern := rctx.RoutePattern()
+	switch {
+	case strings.HasPrefix(pattern, "/api/v1/auth/"):
+		return RouteClassAuth
+	case strings.Contains(pattern, "/inventory/") && strings.HasSuffix(pattern, "/sync"):
+		return RouteClassSync
+	case strings.Contains(pattern, "/inventory/") && r.Method == http.MethodGet:
+		return RouteClassRead
+	}
+	return ""
+}
+
+// recordSLI counts a finished request against its class objective.
+func recordSLI(class, principal string, status int, duration time.Duration) {
+	sloMu.RLock()
+	objective := sloObjectives.objective(class)
+	sloMu.RUnlock()
+
+	latency, result := "met", "success"
+	if duration > objective {
+		latency = "missed"
+	}
+	if status >= 500 {
+		result = "failure"
+	}
+	sliRequests.Inc(class, principal, latency, result)
+
+	minute := time.Now().Unix() / 60
+	b := &sloMinutes[class][minute%sloWindow]
+	if b.minute.Swap(minute) != minute {
+		b.total.Store(0)
+		b.good.Store(0)
+	}
+	b.total.Add(1)
+	if latency == "met" && result == "success" {
+		b.good.Add(1)
+	}
+}
+
+// SLOStats returns each class's objective and its compliance over the last
+// hour, for admin stats. burn_rate is how fast the error budget is spent:
+// 1 spends exactly the budget, above 1 misses the target.
+func SLOStats() map[string]interface{} {
+	sloMu.RLock()
+	objectives := sloObjectives
+	sloMu.RUnlock()
+
+	now := time.Now().Unix() / 60
+	stats := map[string]interface{}{
+		"target_percent": objectives.Target * 100,
+		"window_minutes": sloWindow,
+	}
+	for _, class := range routeClasses {
+		var total, good int64
+		for i := range sloMinutes[class] {
+			b := &sloMinutes[class][i]
+			if now-b.minute.Load() < sloWindow {
+				total += b.total.Load()
+				good += b.good.Load()
+			}
+		}
+
+		section := map[string]interface{}{
+			"objective_ms": objectives.objective(class).Milliseconds(),
+			"requests":     total,
+			"good":         good,
+		}
+		if total > 0 {
+			compliance := float64(good) / float64(total)
+			section["compliance_percent"] = math.Round(compliance*10000) / 100
+			section["burn_rate"] = math.Round((1-compliance)/(1-objectives.Target)*100) / 100
+			section["meeting_target"] = compliance >= objectives.Target
+		}
+		stats[class] = section
+	}
+	return stats
+}
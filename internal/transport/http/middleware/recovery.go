@@ -1,7 +1,7 @@
 package middleware
 
 import (
-	"log"
+	"fmt"
 	"net/http"
 	"runtime/debug"
 
@@ -13,8 +13,11 @@ func Recovery(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				// Log the panic with stack trace
-				log.Printf("PANIC: %v\n%s", err, debug.Stack())
+				// Log the panic with stack trace. Recovery runs before
+				// RequestID, so the ID is read back from the response
+				logger.ErrorContext(r.Context(), "Panic serving request", "panic", fmt.Sprint(err),
+					"method", r.Method, "path", r.URL.Path,
+					"request_id", w.Header().Get("X-Request-ID"), "stack", string(debug.Stack()))
 
 				// Return internal server error
 				w.Header().Set("Content-Type", "application/json")
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"vinzhub-rest-api/internal/logging"
 	"vinzhub-rest-api/pkg/uid"
 )
 
@@ -24,8 +25,9 @@ func RequestID(next http.Handler) http.Handler {
 		// Add to response header
 		w.Header().Set("X-Request-ID", requestID)
 
-		// Add to context
+		// Add to context, and to every line logged with it
 		ctx := context.WithValue(r.Context(), RequestIDKey, requestID)
+		ctx = logging.WithRequestID(ctx, requestID)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type timingKey struct{}
+
+// requestTiming is the single measurement of a request shared by every
+// middleware that reports on it (logging, metrics), so a request is timed
+// once however many of them run.
+type requestTiming struct {
+	start  time.Time
+	writer *responseWriter
+	done   []func(status int, duration time.Duration)
+
+	// The authenticated caller, recorded by the auth middleware (which runs
+	// inside the middleware that reports) - see principalOf
+	principal string
+	identity  string
+}
+
+// timeRequest returns the request's timing, starting it when no outer
+// middleware has. owner is true for the caller that started it, which must
+// call finish once the handler returns.
+func timeRequest(w http.ResponseWriter, r *http.Request) (t *requestTiming, _ http.ResponseWriter, _ *http.Request, owner bool) {
+	if t, ok := r.Context().Value(timingKey{}).(*requestTiming); ok {
+		return t, w, r, false
+	}
+	t = &requestTiming{
+		start:     time.Now(),
+		writer:    &responseWriter{ResponseWriter: w, statusCode: http.StatusOK},
+		principal: "anonymous",
+	}
+	return t, t.writer, r.WithContext(context.WithValue(r.Context(), timingKey{}, t)), true
+}
+
+// onDone registers a report run with the final status and duration.
+func (t *requestTiming) onDone(report func(status int, duration time.Duration)) {
+	t.done = append(t.done, report)
+}
+
+// finish stops the clock and runs the reports, outermost middleware first.
+func (t *requestTiming) finish() {
+	duration := time.Since(t.start)
+	for _, report := range t.done {
+		report(t.writer.statusCode, duration)
+	}
+}
+
+// recordCaller notes the authenticated caller on the request's timing, if
+// it is being timed. identity never holds a secret.
+func recordCaller(ctx context.Context, principal, identity string) {
+	if t, ok := ctx.Value(timingKey{}).(*requestTiming); ok {
+		t.principal = principal
+		t.identity = identity
+	}
+}
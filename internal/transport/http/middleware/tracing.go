@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"vinzhub-rest-api/internal/tracing"
+)
+
+// Tracing starts a server span per request when tracing is on, continuing
+// an incoming traceparent or deriving the trace from the request ID, and
+// returns the trace in a traceparent response header. The span is named
+// after the matched route once the request is done. With tracing off it
+// only checks a nil pointer.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !tracing.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, span := tracing.StartServer(r.Context(), r.Method, r.Header.Get("traceparent"), GetRequestID(r.Context()))
+		if span == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		span.SetString("http.request.method", r.Method)
+		span.SetString("url.path", r.URL.Path)
+		span.SetString("http.request_id", GetRequestID(ctx))
+		w.Header().Set("traceparent", span.Traceparent())
+
+		timing, w, r, owner := timeRequest(w, r.WithContext(ctx))
+		timing.onDone(func(status int, duration time.Duration) {
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				span.SetName(r.Method + " " + rctx.RoutePattern())
+				span.SetString("http.route", rctx.RoutePattern())
+			}
+			span.SetInt("http.response.status_code", int64(status))
+			if status >= 500 {
+				span.Fail(errStatus(status))
+			}
+			span.End()
+		})
+
+		next.ServeHTTP(w, r)
+		if owner {
+			timing.finish()
+		}
+	})
+}
+
+// errStatus describes a failed response for a span's status.
+type errStatus int
+
+func (e errStatus) Error() string {
+	return http.StatusText(int(e))
+}
@@ -0,0 +1,23 @@
+package http
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// mountProfiling serves the net/http/pprof handlers under /debug/pprof on r.
+// Named profiles (heap, allocs, goroutine, block, mutex, ...) are served by
+// the index handler, e.g. /debug/pprof/heap?gc=1.
+func mountProfiling(r chi.Router) {
+	r.Get("/debug/pprof", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/debug/pprof/", http.StatusMovedPermanently)
+	})
+	r.Get("/debug/pprof/cmdline", pprof.Cmdline)
+	r.Get("/debug/pprof/profile", pprof.Profile)
+	r.Get("/debug/pprof/symbol", pprof.Symbol)
+	r.Post("/debug/pprof/symbol", pprof.Symbol)
+	r.Get("/debug/pprof/trace", pprof.Trace)
+	r.Get("/debug/pprof/*", pprof.Index)
+}
@@ -0,0 +1,99 @@
+package response
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withCursorSecret sets the signing secret for one test.
+func withCursorSecret(t *testing.T, secret string) {
+	t.Helper()
+	cursorState.mu.Lock()
+	saved, savedTTL := cursorState.secret, cursorState.ttl
+	cursorState.mu.Unlock()
+	t.Cleanup(func() {
+		cursorState.mu.Lock()
+		cursorState.secret, cursorState.ttl = saved, savedTTL
+		cursorState.mu.Unlock()
+	})
+	SetCursorSecret(secret, DefaultCursorTTL)
+}
+
+// signedCursor signs an arbitrary cursor, as EncodeCursor would.
+func signedCursor(c Cursor) string {
+	payload, _ := json.Marshal(c)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signCursor([]byte(encoded)))
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	withCursorSecret(t, "test-secret")
+	token := EncodeCursor("audit", "2026-01-02", 42)
+	if strings.ContainsAny(token, "+/=") {
+		t.Errorf("token %q is not URL-safe", token)
+	}
+	c, err := DecodeCursor("audit", token)
+	if err != nil {
+		t.Fatalf("DecodeCursor: %v", err)
+	}
+	if c.Scope != "audit" || c.SortKey != "2026-01-02" || c.ID != 42 {
+		t.Errorf("cursor = %+v", c)
+	}
+}
+
+func TestCursorRejected(t *testing.T) {
+	withCursorSecret(t, "test-secret")
+	token := EncodeCursor("audit", "", 42)
+	encoded, sig, _ := strings.Cut(token, ".")
+
+	// Same payload with a higher ID, keeping the old signature
+	forged, _ := json.Marshal(Cursor{Scope: "audit", ID: 1 << 40, IssuedAt: time.Now().UTC()})
+	tampered := base64.RawURLEncoding.EncodeToString(forged) + "." + sig
+
+	flipped := []byte(sig)
+	flipped[0] ^= 1
+
+	tests := []struct {
+		name  string
+		scope string
+		token string
+		want  error
+	}{
+		{"tampered payload", "audit", tampered, ErrInvalidCursor},
+		{"tampered signature", "audit", encoded + "." + string(flipped), ErrInvalidCursor},
+		{"missing signature", "audit", encoded, ErrInvalidCursor},
+		{"garbage", "audit", "not a cursor", ErrInvalidCursor},
+		{"other listing", "flush_log", token, ErrInvalidCursor},
+		{"expired", "audit", signedCursor(Cursor{Scope: "audit", ID: 42, IssuedAt: time.Now().Add(-DefaultCursorTTL - time.Minute)}), ErrCursorExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.scope, tt.token); !errors.Is(err, tt.want) {
+				t.Errorf("DecodeCursor err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// Another instance's secret
+	SetCursorSecret("other-secret", 0)
+	if _, err := DecodeCursor("audit", token); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor signed with another secret: err = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestCursorTTL(t *testing.T) {
+	withCursorSecret(t, "test-secret")
+	SetCursorSecret("", time.Minute)
+	old := signedCursor(Cursor{Scope: "audit", ID: 1, IssuedAt: time.Now().Add(-2 * time.Minute)})
+	if _, err := DecodeCursor("audit", old); !errors.Is(err, ErrCursorExpired) {
+		t.Errorf("cursor past a configured TTL: err = %v, want ErrCursorExpired", err)
+	}
+	fresh := signedCursor(Cursor{Scope: "audit", ID: 1, IssuedAt: time.Now().Add(-30 * time.Second)})
+	if _, err := DecodeCursor("audit", fresh); err != nil {
+		t.Errorf("cursor within the TTL: %v", err)
+	}
+}
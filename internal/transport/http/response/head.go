@@ -0,0 +1,81 @@
+package response
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Head adapts a GET handler to HEAD. The handler runs unchanged, but its
+// body is counted instead of sent, and Content-Length reports the size the
+// GET response would have had.
+func Head(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hw := &headWriter{ResponseWriter: w}
+		next(hw, r)
+		hw.finish()
+	}
+}
+
+// headWriter holds back the status line until the handler is done, so the
+// counted body size can still be sent as Content-Length.
+type headWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *headWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *headWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.size += len(b)
+	return len(b), nil
+}
+
+func (w *headWriter) finish() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.size > 0 && w.Header().Get("Content-Length") == "" {
+		w.Header().Set("Content-Length", strconv.Itoa(w.size))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// SetValidators sets the Last-Modified and ETag headers of a response.
+// A zero time or empty ETag leaves that header out.
+func SetValidators(w http.ResponseWriter, etag string, lastModified time.Time) {
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if etag != "" {
+		w.Header().Set("ETag", `"`+etag+`"`)
+	}
+}
+
+// NotModified answers 304 with the validators when the request's
+// If-None-Match lists etag (or is "*"), and reports whether it did.
+// Weak and strong tags compare the same, as for GET and HEAD in RFC 9110.
+func NotModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	header := r.Header.Get("If-None-Match")
+	if etag == "" || header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == `"`+etag+`"` {
+			SetValidators(w, etag, lastModified)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHead(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantLength string
+	}{
+		{"body", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello "))
+			w.Write([]byte("world"))
+		}, http.StatusOK, "11"},
+		{"status kept", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.WriteHeader(http.StatusOK) // Superfluous, ignored
+			w.Write([]byte("down"))
+		}, http.StatusServiceUnavailable, "4"},
+		{"explicit length wins", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "99")
+			w.Write([]byte("short"))
+		}, http.StatusOK, "99"},
+		{"no body", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotModified)
+		}, http.StatusNotModified, ""},
+		{"nothing written", func(w http.ResponseWriter, r *http.Request) {}, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Head(tt.handler)(rec, httptest.NewRequest(http.MethodHead, "/", nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Length"); got != tt.wantLength {
+				t.Errorf("Content-Length = %q, want %q", got, tt.wantLength)
+			}
+			if rec.Body.Len() != 0 {
+				t.Errorf("body of %d bytes sent", rec.Body.Len())
+			}
+		})
+	}
+}
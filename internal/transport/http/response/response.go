@@ -16,9 +16,10 @@ type Response struct {
 
 // Meta contains pagination metadata.
 type Meta struct {
-	Page  int   `json:"page"`
-	Limit int   `json:"limit"`
-	Total int64 `json:"total"`
+	Page       int   `json:"page"`
+	Limit      int   `json:"limit"`
+	Total      int64 `json:"total"`
+	TotalPages int64 `json:"total_pages"`
 }
 
 // JSON sends a JSON response with the given status code.
@@ -43,15 +44,30 @@ func JSONWithMeta(w http.ResponseWriter, statusCode int, data interface{}, page,
 		Success: true,
 		Data:    data,
 		Meta: &Meta{
-			Page:  page,
-			Limit: limit,
-			Total: total,
+			Page:       page,
+			Limit:      limit,
+			Total:      total,
+			TotalPages: totalPages(total, limit),
 		},
 	}
 
 	_ = json.NewEncoder(w).Encode(response)
 }
 
+// Paginated sends one page of a listing with its pagination metadata.
+// page is 1-based.
+func Paginated(w http.ResponseWriter, data interface{}, page, perPage int, total int64) {
+	JSONWithMeta(w, http.StatusOK, data, page, perPage, total)
+}
+
+// totalPages is how many pages of limit rows hold total rows.
+func totalPages(total int64, limit int) int64 {
+	if limit <= 0 {
+		return 0
+	}
+	return (total + int64(limit) - 1) / int64(limit)
+}
+
 // Error sends an error response.
 func Error(w http.ResponseWriter, err error) {
 	// Check if it's an APIError
@@ -74,6 +90,11 @@ func NoContent(w http.ResponseWriter) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// Accepted sends a 202 Accepted response for work that completes later.
+func Accepted(w http.ResponseWriter, data interface{}) {
+	JSON(w, http.StatusAccepted, data)
+}
+
 // Created sends a 201 Created response with the created resource.
 func Created(w http.ResponseWriter, data interface{}) {
 	JSON(w, http.StatusCreated, data)
@@ -2,87 +2,231 @@ package http
 
 import (
 	"net/http"
+	"net/netip"
+	"time"
 
+	"vinzhub-rest-api/internal/cache"
+	"vinzhub-rest-api/internal/metrics"
 	"vinzhub-rest-api/internal/transport/http/handler"
 	"vinzhub-rest-api/internal/transport/http/middleware"
+	"vinzhub-rest-api/internal/transport/http/response"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/cors"
 )
 
+// RouterOptions tunes router construction.
+type RouterOptions struct {
+	// DisabledMiddleware turns off middleware for debugging: "name" for
+	// every group or "group:name" for one (e.g. "admin:logging"). Auth
+	// can't be disabled.
+	DisabledMiddleware []string
+
+	// Auth authenticates the client, admin and streaming groups, typically
+	// middleware.NewAuthMiddleware. Nil falls back to the deprecated
+	// middleware.APIKeyAuth.
+	Auth func(http.Handler) http.Handler
+
+	// CompressMinBytes gzips responses of at least this many bytes on
+	// every group but streaming. 0 turns response compression off.
+	CompressMinBytes int
+
+	// MaxBodyBytes caps request bodies on the public, client and admin
+	// groups, BatchMaxBodyBytes on the streaming group (bundle imports).
+	// 0 means no limit.
+	MaxBodyBytes      int64
+	BatchMaxBodyBytes int64
+
+	// IdempotencyStore remembers sync Idempotency-Keys for IdempotencyTTL,
+	// per roblox user. Nil (or a 0 TTL) ignores the header.
+	IdempotencyStore cache.IdempotencyStore
+	IdempotencyTTL   time.Duration
+
+	// AccessLogExclude lists paths the access log skips unless they fail
+	// with a 5xx.
+	AccessLogExclude []string
+
+	// AdminIPAllowlist limits the admin API, metrics, profiling and the
+	// dashboard redirect to these networks. Empty allows every address.
+	// TrustProxy takes the client IP from X-Forwarded-For (see
+	// middleware.IPAllowlist).
+	AdminIPAllowlist []netip.Prefix
+	TrustProxy       bool
+
+	// CORS is the cross-origin policy. Nil allows any origin without
+	// credentials, for development.
+	CORS *CORSOptions
+
+	// OpenProfiling serves /debug/pprof without auth (APP_DEBUG). Otherwise
+	// it needs an admin key, like the rest of the admin group.
+	OpenProfiling bool
+}
+
 // NewRouter creates and configures the HTTP router.
 // authHandler is optional - pass nil if not using token auth.
 func NewRouter(h *handler.Handler, invHandler *handler.InventoryHandler, adminHandler *handler.AdminHandler, authHandler *handler.AuthHandler) *chi.Mux {
-	return newRouterInternal(h, invHandler, adminHandler, authHandler)
+	return newRouterInternal(RouterOptions{}, h, invHandler, adminHandler, authHandler)
+}
+
+// NewRouterWithOptions is NewRouter with RouterOptions.
+func NewRouterWithOptions(opts RouterOptions, h *handler.Handler, invHandler *handler.InventoryHandler, adminHandler *handler.AdminHandler, authHandler *handler.AuthHandler) *chi.Mux {
+	return newRouterInternal(opts, h, invHandler, adminHandler, authHandler)
 }
 
 // NewRouterLegacy is backward-compatible for old main.go that doesn't have authHandler.
 // Deprecated: Use NewRouter with authHandler=nil instead.
 func NewRouterLegacy(h *handler.Handler, invHandler *handler.InventoryHandler, adminHandler *handler.AdminHandler) *chi.Mux {
-	return newRouterInternal(h, invHandler, adminHandler, nil)
+	return newRouterInternal(RouterOptions{}, h, invHandler, adminHandler, nil)
 }
 
-func newRouterInternal(h *handler.Handler, invHandler *handler.InventoryHandler, adminHandler *handler.AdminHandler, authHandler *handler.AuthHandler) *chi.Mux {
+// newRouterInternal mounts every route in its group. Middleware chains are
+// declared per group in chain.go.
+func newRouterInternal(opts RouterOptions, h *handler.Handler, invHandler *handler.InventoryHandler, adminHandler *handler.AdminHandler, authHandler *handler.AuthHandler) *chi.Mux {
 	r := chi.NewRouter()
+	chains := effectiveChains(opts)
+	logDisabled(opts)
+
+	chains[groupGlobal].use(r)
+
+	// Public: no authentication
+	r.Group(func(r chi.Router) {
+		chains[groupPublic].use(r)
+
+		r.Get("/api/v1/health", h.Health)
+		r.Get("/api/v1/ready", h.Ready)
+		r.Get("/api/v1/health/detail", h.HealthDetail)
+		r.Head("/api/v1/health", response.Head(h.Health))
+		r.Head("/api/v1/ready", response.Head(h.Ready))
+
+		// Token generation is how clients authenticate in the first place;
+		// refresh works once the session token has expired
+		if authHandler != nil {
+			r.Post("/api/v1/auth/token", authHandler.GenerateToken)
+			r.Post("/api/v1/auth/refresh", authHandler.RefreshToken)
+		}
+
+		// Static files (admin dashboard)
+		fileServer := http.FileServer(http.Dir("./static"))
+		r.Handle("/static/*", http.StripPrefix("/static/", fileServer))
+
+		// Admin dashboard redirect
+		r.With(middleware.IPAllowlist(opts.AdminIPAllowlist, opts.TrustProxy)).Get("/admin", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/static/admin.html", http.StatusMovedPermanently)
+		})
+
+		if opts.OpenProfiling {
+			mountProfiling(r)
+		}
+	})
 
+	// Client: session token or API key
+	r.Group(func(r chi.Router) {
+		chains[groupClient].use(r)
 
-	// Global middleware stack
-	r.Use(middleware.Recovery)
-	r.Use(middleware.RequestID)
-	r.Use(middleware.Logging)
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"}, // Configure for production
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Request-ID", "X-API-Key", "X-Token"},
-		ExposedHeaders:   []string{"X-Request-ID"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
-
-	// API Key/Token authentication (skip for health checks and auth endpoints)
-	r.Use(middleware.APIKeyAuth)
-
-	// API v1 routes
-	r.Route("/api/v1", func(r chi.Router) {
-		// Health check endpoints (no auth required)
-		r.Get("/health", h.Health)
-		r.Get("/ready", h.Ready)
-
-		// Auth endpoints (token generation doesn't require auth)
 		if authHandler != nil {
-			r.Route("/auth", func(r chi.Router) {
-				r.Post("/token", authHandler.GenerateToken)
+			r.Route("/api/v1/auth", func(r chi.Router) {
 				r.Post("/revoke", authHandler.RevokeToken)
-				r.Post("/refresh", authHandler.RefreshToken)
+				r.Get("/sessions", authHandler.ListSessions)
+				r.Delete("/sessions", authHandler.RevokeSession)
+				r.Delete("/sessions/{session_id}", authHandler.RevokeSession)
 			})
+			r.Get("/api/v1/account", authHandler.GetAccount)
+			r.Post("/api/v1/account/heartbeat", authHandler.Heartbeat)
 		}
 
-		// Inventory endpoints
 		if invHandler != nil {
-			r.Route("/inventory/{roblox_user_id}", func(r chi.Router) {
-				r.Post("/sync", invHandler.SyncRawInventory)
+			idempotent := middleware.Idempotency(opts.IdempotencyStore, opts.IdempotencyTTL, "roblox_user_id")
+			r.Get("/api/v1/inventory", invHandler.ListInventories)
+			r.Post("/api/v1/inventory/view", invHandler.ViewInventory)
+			r.Route("/api/v1/inventory/{roblox_user_id}", func(r chi.Router) {
+				r.With(idempotent).Post("/sync", invHandler.SyncRawInventory)
 				r.Get("/", invHandler.GetRawInventory)
+				r.Head("/", invHandler.HeadRawInventory)
+				r.Get("/exists", invHandler.Exists)
+				r.Get("/history", invHandler.GetInventoryHistory)
+				r.Get("/history/{timestamp}", invHandler.GetInventoryVersion)
+				r.Get("/diff", invHandler.DiffInventory)
+			})
+
+			// API v2 - same handlers with v2 response semantics
+			// (sync answers 202 while a write is only buffered)
+			r.Route("/api/v2/inventory/{roblox_user_id}", func(r chi.Router) {
+				r.With(idempotent).Post("/sync", invHandler.SyncRawInventory)
+				r.Get("/", invHandler.GetRawInventory)
+				r.Head("/", invHandler.HeadRawInventory)
 			})
 		}
+	})
+
+	// Admin: admin API and Prometheus metrics
+	r.Group(func(r chi.Router) {
+		chains[groupAdmin].use(r)
 
-		// Admin endpoints
 		if adminHandler != nil {
-			r.Route("/admin", func(r chi.Router) {
+			r.Route("/api/v1/admin", func(r chi.Router) {
 				r.Get("/stats", adminHandler.GetStats)
 				r.Get("/health", adminHandler.GetHealth)
+				r.Get("/users/{roblox_user_id}/compare", adminHandler.CompareUser)
+				r.Get("/flush-log", adminHandler.GetFlushLog)
+				r.Put("/log-level", adminHandler.SetLogLevel)
+				r.Get("/goroutines", adminHandler.GetGoroutines)
+				r.Get("/heap", adminHandler.GetHeap)
+				r.Post("/flush", adminHandler.FlushNow)
+				r.Post("/flush/resume", adminHandler.ResumeFlush)
+				r.Post("/retention/run", adminHandler.RunRetention)
+				r.Post("/storage/recompress", adminHandler.StartRecompress)
+				r.Get("/storage/recompress", adminHandler.GetRecompress)
+				r.Get("/log-archives", adminHandler.GetLogArchives)
+				r.Post("/buffer/rekey", adminHandler.RekeyBuffer)
+				r.Get("/buffer/{roblox_user_id}", adminHandler.GetBufferedEntry)
+				r.Delete("/buffer/{roblox_user_id}", adminHandler.DropBufferedEntry)
+				r.Delete("/buffer", adminHandler.ClearBuffer)
+				r.Get("/deadletter", adminHandler.GetDeadLetters)
+				r.Post("/deadletter", adminHandler.RequeueDeadLetters)
+				r.Get("/integrity", adminHandler.GetIntegrity)
+				r.Get("/schema-report", adminHandler.GetSchemaReport)
+				r.Get("/flags/inventory", adminHandler.GetInventoryFlags)
+				r.Post("/integrity/{id}/reverify", adminHandler.ReverifyIntegrityIssue)
+				r.Post("/integrity/{id}/acknowledge", adminHandler.AcknowledgeIntegrityIssue)
+				r.Get("/unlinked", adminHandler.GetUnlinked)
+				r.Delete("/unlinked", adminHandler.PurgeUnlinked)
+				r.Post("/prune", adminHandler.PruneInventories)
+				r.Post("/maintenance", adminHandler.RunMaintenance)
+				r.Get("/migrations", adminHandler.GetMigrations)
+				r.Post("/backup", adminHandler.CreateBackup)
+				r.Post("/key-accounts", adminHandler.CreateKeyAccount)
+				r.Put("/key-accounts/{id}", adminHandler.UpdateKeyAccount)
+				r.Get("/audit", adminHandler.GetAuditLog)
+				r.Post("/sql", adminHandler.RunSQL)
+				r.Post("/support-tokens", adminHandler.CreateSupportToken)
+				r.Get("/support-tokens", adminHandler.ListSupportTokens)
+				r.Delete("/support-tokens/{session_id}", adminHandler.RevokeSupportToken)
+				r.Post("/api-keys", adminHandler.CreateAPIKey)
+				r.Get("/api-keys", adminHandler.ListAPIKeys)
+				r.Delete("/api-keys/{id}", adminHandler.RevokeAPIKey)
+				r.Get("/accounts/{key_account_id}/sessions", adminHandler.GetAccountSessions)
+				r.Post("/accounts/{key_account_id}/reset-hwid", adminHandler.ResetHWID)
 			})
 		}
+
+		r.Handle("/metrics", metrics.Handler())
 	})
 
-	// Static files (admin dashboard)
-	fileServer := http.FileServer(http.Dir("./static"))
-	r.Handle("/static/*", http.StripPrefix("/static/", fileServer))
+	// Streaming: long-lived responses
+	r.Group(func(r chi.Router) {
+		chains[groupStreaming].use(r)
+
+		if adminHandler != nil {
+			r.Post("/api/v1/admin/export-bundle", adminHandler.ExportBundle)
+			r.Post("/api/v1/admin/import-bundle", adminHandler.ImportBundle)
+			r.Get("/api/v1/admin/export", adminHandler.ExportInventories)
+			r.Post("/api/v1/admin/import", adminHandler.ImportInventories)
+		}
 
-	// Admin dashboard redirect
-	r.Get("/admin", func(w http.ResponseWriter, r *http.Request) {
-		http.Redirect(w, r, "/static/admin.html", http.StatusMovedPermanently)
+		// CPU profiles and traces run for seconds and are already compressed
+		if !opts.OpenProfiling {
+			mountProfiling(r)
+		}
 	})
 
 	return r
 }
-
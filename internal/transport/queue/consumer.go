@@ -0,0 +1,311 @@
+// Package queue ingests inventory syncs published to a message queue, for
+// game servers that can't reach the HTTP API reliably.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vinzhub-rest-api/internal/lifecycle"
+	"vinzhub-rest-api/internal/logging"
+	"vinzhub-rest-api/internal/service"
+)
+
+const (
+	// popTimeout bounds how long a blocking pop waits, which is also how long
+	// Close may wait for the consumer loop to notice shutdown.
+	popTimeout = 2 * time.Second
+
+	// retryDelay is the pause after a transient failure before the next pop.
+	retryDelay = time.Second
+)
+
+// Message is the payload published by game servers.
+type Message struct {
+	RobloxUserID  string          `json:"roblox_user_id"`
+	Section       string          `json:"section,omitempty"`
+	Inventory     json.RawMessage `json:"inventory"`
+	ClientVersion string          `json:"client_version,omitempty"`
+}
+
+// Config configures the consumer.
+type Config struct {
+	URL               string // redis://[:password@]host:port/db or nats://[user:pass@]host:port
+	Subject           string // Redis list, or NATS subject, to consume from
+	DeadLetterSubject string // Receives malformed messages
+
+	// NATSStream is the JetStream stream holding Subject; NATSConsumer the
+	// durable consumer reading it, created when missing. NATS only.
+	NATSStream   string
+	NATSConsumer string
+
+	// Logger defaults to logging.Component("QueueConsumer")
+	Logger *slog.Logger
+}
+
+// delivery is one message taken from a transport, with what the transport
+// needs to ack it.
+type delivery struct {
+	payload []byte
+	ackRef  string // NATS reply subject; unused by Redis
+}
+
+// transport is the queue a Consumer reads from. Only the consumer loop calls
+// next, ack, requeue and deadLetter; stats may run concurrently.
+type transport interface {
+	// recover hands messages left in flight by stopped consumers back to the
+	// queue, returning how many.
+	recover(ctx context.Context) (int, error)
+	// next waits up to popTimeout for a message; nil without error when none
+	// came.
+	next(ctx context.Context) (*delivery, error)
+	ack(ctx context.Context, d *delivery) error
+	// requeue returns a message to the queue to be retried.
+	requeue(ctx context.Context, d *delivery) error
+	// deadLetter publishes entry on the dead-letter subject and drops the
+	// message.
+	deadLetter(ctx context.Context, d *delivery, entry []byte) error
+	// stats adds backlog figures to the consumer's admin stats.
+	stats(ctx context.Context, into map[string]interface{})
+	close() error
+}
+
+// Consumer reads messages from a queue and acks each only once the
+// inventory service accepted the write. On Redis it uses a reliable-queue
+// pattern (redisTransport), on NATS a JetStream pull consumer
+// (natsTransport).
+type Consumer struct {
+	queue      transport
+	subject    string
+	deadLetter string
+	service    *service.InventoryService
+	logger     *slog.Logger
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	started  atomic.Bool
+
+	processed     atomic.Int64
+	failed        atomic.Int64
+	deadLettered  atomic.Int64
+	lastMessageAt atomic.Int64 // unix seconds
+}
+
+// NewConsumer connects to the queue: redis:// and rediss:// URLs use a
+// Redis list, nats:// and tls:// URLs a JetStream stream.
+func NewConsumer(cfg Config, svc *service.InventoryService) (*Consumer, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid queue URL: %w", err)
+	}
+
+	logger := cfg.Logger
+	if logger == nil {
+		logger = logging.Component("QueueConsumer")
+	}
+
+	var queue transport
+	deadLetter := cfg.DeadLetterSubject
+	switch u.Scheme {
+	case "redis", "rediss":
+		if deadLetter == "" {
+			deadLetter = cfg.Subject + ":dead"
+		}
+		queue, err = newRedisTransport(cfg.URL, cfg.Subject, deadLetter, logger)
+	case "nats", "tls":
+		if deadLetter == "" {
+			deadLetter = cfg.Subject + ".dead"
+		}
+		queue, err = newNATSTransport(u, cfg.Subject, deadLetter, cfg.NATSStream, cfg.NATSConsumer)
+	default:
+		return nil, fmt.Errorf("unsupported queue scheme %q (supported: redis, rediss, nats, tls)", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Consumer{
+		queue:      queue,
+		subject:    cfg.Subject,
+		deadLetter: deadLetter,
+		service:    svc,
+		logger:     logger,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// Start recovers messages left in flight by stopped consumers and starts
+// consuming.
+func (c *Consumer) Start() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	recovered, err := c.queue.recover(ctx)
+	cancel()
+	if err != nil {
+		c.logger.Error("Recovering in-flight messages failed", "error", err)
+	}
+	if recovered > 0 {
+		c.logger.Info("Requeued in-flight messages of stopped consumers", "messages", recovered)
+	}
+
+	c.started.Store(true)
+	lifecycle.Go("queue.consumer", func() {
+		c.run()
+		close(c.done) // Not deferred: a panicking loop is restarted
+	})
+	c.logger.Info("Started", "subject", c.subject, "dead_letter", c.deadLetter)
+}
+
+// run is the consume loop. It exits after finishing the in-flight message.
+func (c *Consumer) run() {
+	for {
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+		lifecycle.Touch("queue.consumer")
+
+		ctx := context.Background()
+		d, err := c.queue.next(ctx)
+		if err != nil {
+			c.logger.Error("Pop failed", "error", err)
+			c.sleep(retryDelay)
+			continue
+		}
+		if d == nil {
+			continue // Timed out, check stop and wait again
+		}
+
+		c.lastMessageAt.Store(time.Now().Unix())
+		c.handle(ctx, d)
+	}
+}
+
+// handle processes one message and acks, dead-letters or requeues it.
+func (c *Consumer) handle(ctx context.Context, d *delivery) {
+	msg, err := decodeMessage(d.payload)
+	if err != nil {
+		c.deadLetterMessage(ctx, d, err)
+		return
+	}
+
+	_, err = c.service.Sync(ctx, service.SyncRequest{
+		RobloxUserID:  msg.RobloxUserID,
+		Section:       msg.Section,
+		RawJSON:       msg.Inventory,
+		ClientVersion: msg.ClientVersion,
+		Unthrottled:   true, // The queue paces itself; a refusal would only requeue
+	})
+	switch {
+	case err == nil:
+		c.processed.Add(1)
+		if err := c.queue.ack(ctx, d); err != nil {
+			c.logger.ErrorContext(ctx, "Ack failed", "error", err)
+		}
+	case permanentError(err):
+		// Permanent - retrying won't help
+		c.deadLetterMessage(ctx, d, err)
+	default:
+		// Transient (buffer/DB unavailable) - put it back and slow down
+		c.failed.Add(1)
+		c.logger.WarnContext(ctx, "Sync failed, requeueing", "roblox_user_id", msg.RobloxUserID, "error", err)
+		if err := c.queue.requeue(ctx, d); err != nil {
+			c.logger.ErrorContext(ctx, "Requeue failed", "error", err)
+		}
+		c.sleep(retryDelay)
+	}
+}
+
+// permanentError reports sync failures caused by the message itself, which
+// retrying can't fix. Anything else is taken as transient.
+func permanentError(err error) bool {
+	var schemaErr *service.SchemaError
+	switch {
+	case errors.As(err, &schemaErr),
+		errors.Is(err, service.ErrUnknownSection),
+		errors.Is(err, service.ErrInvalidRobloxUserID),
+		errors.Is(err, service.ErrNoKeyAccount),
+		errors.Is(err, service.ErrEmptyPayload):
+		return true
+	}
+	return false
+}
+
+// deadLetterMessage moves a message to the dead-letter subject with the
+// reason attached.
+func (c *Consumer) deadLetterMessage(ctx context.Context, d *delivery, reason error) {
+	c.deadLettered.Add(1)
+	entry, _ := json.Marshal(map[string]interface{}{
+		"error":       reason.Error(),
+		"message":     string(d.payload),
+		"received_at": time.Now().UTC(),
+	})
+	if err := c.queue.deadLetter(ctx, d, entry); err != nil {
+		c.logger.ErrorContext(ctx, "Dead-lettering failed", "error", err)
+	}
+}
+
+// decodeMessage parses and validates a queue message.
+func decodeMessage(data []byte) (*Message, error) {
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	if msg.RobloxUserID == "" {
+		return nil, errors.New("roblox_user_id is required")
+	}
+	if len(msg.Inventory) == 0 || string(msg.Inventory) == "null" {
+		return nil, errors.New("inventory is required")
+	}
+	if !json.Valid(msg.Inventory) {
+		return nil, errors.New("inventory is not valid JSON")
+	}
+	return &msg, nil
+}
+
+// sleep waits for d or until shutdown.
+func (c *Consumer) sleep(d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-c.stop:
+	}
+}
+
+// Stats returns consumer counters and the current backlog (lag) for admin stats.
+func (c *Consumer) Stats(ctx context.Context) map[string]interface{} {
+	stats := map[string]interface{}{
+		"subject":       c.subject,
+		"processed":     c.processed.Load(),
+		"failed":        c.failed.Load(),
+		"dead_lettered": c.deadLettered.Load(),
+	}
+	if last := c.lastMessageAt.Load(); last > 0 {
+		stats["last_message_at"] = time.Unix(last, 0).UTC()
+	}
+	c.queue.stats(ctx, stats)
+	return stats
+}
+
+// Close stops consuming and waits for the in-flight message to finish.
+// Call before closing the inventory buffer so its final flush sees every
+// acked message.
+func (c *Consumer) Close() error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	if c.started.Load() {
+		<-c.done
+	}
+	c.logger.Info("Stopped", "processed", c.processed.Load(), "failed", c.failed.Load(),
+		"dead_lettered", c.deadLettered.Load())
+	return c.queue.close()
+}
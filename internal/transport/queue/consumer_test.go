@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"vinzhub-rest-api/internal/service"
+)
+
+func TestPermanentError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"schema violation", &service.SchemaError{Violations: []service.SchemaViolation{{}}}, true},
+		{"wrapped schema violation", fmt.Errorf("sync: %w", &service.SchemaError{}), true},
+		{"unknown section", service.ErrUnknownSection, true},
+		{"invalid roblox user id", service.ErrInvalidRobloxUserID, true},
+		{"no key account", service.ErrNoKeyAccount, true},
+		{"empty payload", fmt.Errorf("%w: null", service.ErrEmptyPayload), true},
+		{"buffer full", &service.BufferFullError{RetryAfter: time.Second}, false},
+		{"key account lookup down", service.ErrKeyAccountUnavailable, false},
+		{"storage error", errors.New("database is locked"), false},
+	}
+	for _, tt := range tests {
+		if got := permanentError(tt.err); got != tt.want {
+			t.Errorf("%s: permanentError = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeMessage(t *testing.T) {
+	tests := []struct {
+		payload string
+		ok      bool
+	}{
+		{`{"roblox_user_id":"1","inventory":{"Items":[]}}`, true},
+		{`{"roblox_user_id":"1","section":"settings","inventory":[]}`, true},
+		{`not json`, false},
+		{`{"inventory":{}}`, false},
+		{`{"roblox_user_id":"1"}`, false},
+		{`{"roblox_user_id":"1","inventory":null}`, false},
+	}
+	for _, tt := range tests {
+		if _, err := decodeMessage([]byte(tt.payload)); (err == nil) != tt.ok {
+			t.Errorf("decodeMessage(%s) err = %v, want ok=%v", tt.payload, err, tt.ok)
+		}
+	}
+}
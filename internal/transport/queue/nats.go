@@ -0,0 +1,591 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// natsDialTimeout bounds connecting and the CONNECT/PING handshake.
+	natsDialTimeout = 5 * time.Second
+
+	// natsRequestTimeout bounds JetStream API calls and publish acks.
+	natsRequestTimeout = 5 * time.Second
+
+	// natsAckWait is how long JetStream waits for an ack before
+	// redelivering, which is how messages of a stopped consumer come back.
+	natsAckWait = 30 * time.Second
+)
+
+var (
+	errNATSTimeout      = errors.New("nats: request timed out")
+	errNATSNoResponders = errors.New("nats: no responders")
+	errConsumerNotFound = errors.New("jetstream: consumer not found")
+)
+
+// JetStream API error codes (err_code) this consumer reacts to.
+const (
+	jsErrConsumerNotFound = 10014
+	jsErrStreamNotFound   = 10059
+)
+
+// natsTransport consumes a JetStream stream through a durable pull
+// consumer shared by every instance, so each message goes to one of them.
+// Messages are acked (+ACK) once handled, negatively acked (-NAK) to be
+// retried, and terminated (+TERM) once dead-lettered; a message whose
+// consumer stopped mid-flight is redelivered after natsAckWait.
+type natsTransport struct {
+	url               *url.URL
+	subject           string
+	deadLetterSubject string
+	stream            string
+	durable           string
+
+	mu   sync.Mutex
+	conn *natsConn
+}
+
+// newNATSTransport connects to u and creates the durable consumer when it
+// doesn't exist yet.
+func newNATSTransport(u *url.URL, subject, deadLetter, stream, durable string) (*natsTransport, error) {
+	if stream == "" || durable == "" {
+		return nil, errors.New("NATS ingestion needs a stream and a consumer name")
+	}
+	t := &natsTransport{
+		url:               u,
+		subject:           subject,
+		deadLetterSubject: deadLetter,
+		stream:            stream,
+		durable:           durable,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), natsDialTimeout+natsRequestTimeout)
+	defer cancel()
+	if err := t.ensureConsumer(ctx); err != nil {
+		t.close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// connected returns the live connection, redialing when it dropped.
+func (t *natsTransport) connected() (*natsConn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn != nil && t.conn.alive() {
+		return t.conn, nil
+	}
+	if t.conn != nil {
+		t.conn.close()
+		t.conn = nil
+	}
+	conn, err := dialNATS(t.url)
+	if err != nil {
+		return nil, err
+	}
+	t.conn = conn
+	return conn, nil
+}
+
+// jsError is the error member of JetStream API responses.
+type jsError struct {
+	Code        int    `json:"code"`
+	ErrCode     int    `json:"err_code"`
+	Description string `json:"description"`
+}
+
+func (e *jsError) Error() string {
+	return fmt.Sprintf("jetstream: %s (code %d)", e.Description, e.ErrCode)
+}
+
+// consumerInfo is the part of a CONSUMER.INFO response the stats use.
+type consumerInfo struct {
+	Error         *jsError `json:"error"`
+	NumPending    int64    `json:"num_pending"`
+	NumAckPending int64    `json:"num_ack_pending"`
+}
+
+// apiRequest calls the JetStream API and decodes the response into out.
+func (t *natsTransport) apiRequest(ctx context.Context, subject string, body []byte, out interface{}) error {
+	conn, err := t.connected()
+	if err != nil {
+		return err
+	}
+	msg, err := conn.request(ctx, subject, body, natsRequestTimeout)
+	if errors.Is(err, errNATSNoResponders) {
+		return errors.New("jetstream is not enabled on the NATS server")
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(msg.data, out)
+}
+
+// info fetches the durable consumer's state.
+func (t *natsTransport) info(ctx context.Context) (*consumerInfo, error) {
+	var info consumerInfo
+	subject := "$JS.API.CONSUMER.INFO." + t.stream + "." + t.durable
+	if err := t.apiRequest(ctx, subject, nil, &info); err != nil {
+		return nil, err
+	}
+	if info.Error != nil {
+		switch info.Error.ErrCode {
+		case jsErrConsumerNotFound:
+			return nil, errConsumerNotFound
+		case jsErrStreamNotFound:
+			return nil, fmt.Errorf("jetstream stream %q not found", t.stream)
+		}
+		return nil, info.Error
+	}
+	return &info, nil
+}
+
+// ensureConsumer creates the durable consumer unless it exists. An existing
+// consumer is used as configured.
+func (t *natsTransport) ensureConsumer(ctx context.Context) error {
+	_, err := t.info(ctx)
+	if !errors.Is(err, errConsumerNotFound) {
+		return err
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"stream_name": t.stream,
+		"config": map[string]interface{}{
+			"durable_name":   t.durable,
+			"deliver_policy": "all",
+			"ack_policy":     "explicit",
+			"ack_wait":       natsAckWait.Nanoseconds(),
+			"filter_subject": t.subject,
+			"replay_policy":  "instant",
+		},
+	})
+	var resp struct {
+		Error *jsError `json:"error"`
+	}
+	subject := "$JS.API.CONSUMER.DURABLE.CREATE." + t.stream + "." + t.durable
+	if err := t.apiRequest(ctx, subject, body, &resp); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return nil
+}
+
+// recover is a no-op: JetStream redelivers unacked messages by itself.
+func (t *natsTransport) recover(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+// next pulls one message, waiting up to popTimeout.
+func (t *natsTransport) next(ctx context.Context) (*delivery, error) {
+	conn, err := t.connected()
+	if err != nil {
+		return nil, err
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"batch":   1,
+		"expires": popTimeout.Nanoseconds(),
+	})
+	subject := "$JS.API.CONSUMER.MSG.NEXT." + t.stream + "." + t.durable
+	msg, err := conn.request(ctx, subject, body, popTimeout+time.Second)
+	if errors.Is(err, errNATSTimeout) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch msg.status {
+	case "":
+		return &delivery{payload: msg.data, ackRef: msg.reply}, nil
+	case "404", "408":
+		return nil, nil // No messages before the pull expired
+	default:
+		return nil, fmt.Errorf("jetstream pull: status %s %s", msg.status, msg.description)
+	}
+}
+
+// ackWith publishes an ack verb to the message's reply subject.
+func (t *natsTransport) ackWith(d *delivery, verb string) error {
+	if d.ackRef == "" {
+		return errors.New("message has no ack subject")
+	}
+	conn, err := t.connected()
+	if err != nil {
+		return err
+	}
+	return conn.publish(d.ackRef, "", []byte(verb))
+}
+
+func (t *natsTransport) ack(ctx context.Context, d *delivery) error {
+	return t.ackWith(d, "+ACK")
+}
+
+// requeue asks for redelivery after retryDelay.
+func (t *natsTransport) requeue(ctx context.Context, d *delivery) error {
+	return t.ackWith(d, fmt.Sprintf(`-NAK {"delay":%d}`, retryDelay.Nanoseconds()))
+}
+
+// deadLetter publishes entry and terminates the message once a stream
+// stored it. A failed publish, or no stream on the dead-letter subject,
+// leaves the message unacked, so it's redelivered and dead-lettered again
+// rather than lost.
+func (t *natsTransport) deadLetter(ctx context.Context, d *delivery, entry []byte) error {
+	conn, err := t.connected()
+	if err != nil {
+		return err
+	}
+	msg, err := conn.request(ctx, t.deadLetterSubject, entry, natsRequestTimeout)
+	if errors.Is(err, errNATSNoResponders) {
+		return fmt.Errorf("no stream stores dead-letter subject %s", t.deadLetterSubject)
+	}
+	if err != nil {
+		return err
+	}
+	var ack struct {
+		Error *jsError `json:"error"`
+	}
+	if json.Unmarshal(msg.data, &ack) == nil && ack.Error != nil {
+		return ack.Error
+	}
+	return t.ackWith(d, "+TERM")
+}
+
+// stats reports the consumer's pending and in-flight counts.
+func (t *natsTransport) stats(ctx context.Context, into map[string]interface{}) {
+	into["stream"] = t.stream
+	info, err := t.info(ctx)
+	if err != nil {
+		into["error"] = err.Error()
+		return
+	}
+	into["lag"] = info.NumPending
+	into["in_flight"] = info.NumAckPending
+}
+
+func (t *natsTransport) close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.close()
+	t.conn = nil
+	return err
+}
+
+// natsMsg is a message delivered to the connection's inbox.
+type natsMsg struct {
+	subject     string
+	reply       string
+	status      string // Header status line code, e.g. "404"
+	description string
+	data        []byte
+}
+
+// natsConn is a minimal NATS client connection: publish, and requests
+// answered on a per-connection inbox.
+type natsConn struct {
+	conn  net.Conn
+	inbox string
+	seq   atomic.Uint64
+
+	wmu sync.Mutex
+	w   *bufio.Writer
+
+	pmu     sync.Mutex
+	pending map[string]chan *natsMsg
+
+	pong chan struct{}
+	done chan struct{}
+	err  error // Set before done is closed
+}
+
+// natsServerInfo is the part of the server's INFO the client needs.
+type natsServerInfo struct {
+	TLSRequired bool `json:"tls_required"`
+	Headers     bool `json:"headers"`
+}
+
+// dialNATS connects and authenticates with the credentials of u, upgrading
+// to TLS for tls:// URLs or when the server requires it.
+func dialNATS(u *url.URL) (*natsConn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	raw, err := net.DialTimeout("tcp", host, natsDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	raw.SetDeadline(time.Now().Add(natsDialTimeout))
+
+	r := bufio.NewReader(raw)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		raw.Close()
+		return nil, fmt.Errorf("nats: reading INFO: %w", err)
+	}
+	op, args, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+	if !strings.EqualFold(op, "INFO") {
+		raw.Close()
+		return nil, fmt.Errorf("nats: expected INFO, got %q", op)
+	}
+	var info natsServerInfo
+	if err := json.Unmarshal([]byte(args), &info); err != nil {
+		raw.Close()
+		return nil, fmt.Errorf("nats: invalid INFO: %w", err)
+	}
+	if !info.Headers {
+		raw.Close()
+		return nil, errors.New("nats: server doesn't support headers (NATS 2.2+ required)")
+	}
+
+	conn := raw
+	secure := u.Scheme == "tls"
+	if secure || info.TLSRequired {
+		tlsConn := tls.Client(raw, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			raw.Close()
+			return nil, fmt.Errorf("nats: TLS handshake: %w", err)
+		}
+		conn = tlsConn
+		r = bufio.NewReader(conn)
+	}
+
+	connect := map[string]interface{}{
+		"verbose":       false,
+		"pedantic":      false,
+		"tls_required":  secure,
+		"name":          "vinzhub-api",
+		"lang":          "go",
+		"version":       "1.0.0",
+		"protocol":      1,
+		"headers":       true,
+		"no_responders": true,
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			connect["user"] = u.User.Username()
+			connect["pass"] = pass
+		} else {
+			connect["auth_token"] = u.User.Username()
+		}
+	}
+	connectJSON, _ := json.Marshal(connect)
+
+	suffix := make([]byte, 8)
+	rand.Read(suffix)
+	c := &natsConn{
+		conn:    conn,
+		inbox:   "_INBOX." + hex.EncodeToString(suffix),
+		w:       bufio.NewWriter(conn),
+		pending: make(map[string]chan *natsMsg),
+		pong:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(r)
+
+	// The PONG confirms the server accepted CONNECT; a rejection arrives
+	// as -ERR and closes the connection.
+	if err := c.write("CONNECT %s\r\nSUB %s.* 1\r\nPING\r\n", connectJSON, c.inbox); err != nil {
+		c.close()
+		return nil, err
+	}
+	select {
+	case <-c.pong:
+	case <-c.done:
+		return nil, c.err
+	case <-time.After(natsDialTimeout):
+		c.close()
+		return nil, errors.New("nats: handshake timed out")
+	}
+	conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+// alive reports whether the read loop is still running.
+func (c *natsConn) alive() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// write sends one or more protocol lines.
+func (c *natsConn) write(format string, args ...interface{}) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	fmt.Fprintf(c.w, format, args...)
+	return c.w.Flush()
+}
+
+// publish sends data to subject, with reply as the reply-to subject when set.
+func (c *natsConn) publish(subject, reply string, data []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if reply != "" {
+		fmt.Fprintf(c.w, "PUB %s %s %d\r\n", subject, reply, len(data))
+	} else {
+		fmt.Fprintf(c.w, "PUB %s %d\r\n", subject, len(data))
+	}
+	c.w.Write(data)
+	c.w.WriteString("\r\n")
+	return c.w.Flush()
+}
+
+// request publishes data and waits up to timeout for the reply.
+func (c *natsConn) request(ctx context.Context, subject string, data []byte, timeout time.Duration) (*natsMsg, error) {
+	reply := c.inbox + "." + strconv.FormatUint(c.seq.Add(1), 10)
+	ch := make(chan *natsMsg, 1)
+	c.pmu.Lock()
+	c.pending[reply] = ch
+	c.pmu.Unlock()
+	defer func() {
+		c.pmu.Lock()
+		delete(c.pending, reply)
+		c.pmu.Unlock()
+	}()
+
+	if err := c.publish(subject, reply, data); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case msg := <-ch:
+		if msg.status == "503" {
+			return nil, errNATSNoResponders
+		}
+		return msg, nil
+	case <-timer.C:
+		return nil, errNATSTimeout
+	case <-c.done:
+		return nil, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// readLoop reads server operations until the connection fails, routing
+// inbox messages to their waiting request.
+func (c *natsConn) readLoop(r *bufio.Reader) {
+	err := c.read(r)
+	c.conn.Close()
+	c.err = fmt.Errorf("nats: connection lost: %w", err)
+	close(c.done)
+}
+
+func (c *natsConn) read(r *bufio.Reader) error {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		op, rest, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(op) {
+		case "MSG", "HMSG":
+			msg, err := readNATSMsg(r, strings.ToUpper(op) == "HMSG", strings.Fields(rest))
+			if err != nil {
+				return err
+			}
+			c.pmu.Lock()
+			ch := c.pending[msg.subject]
+			c.pmu.Unlock()
+			if ch != nil {
+				select {
+				case ch <- msg:
+				default:
+				}
+			}
+		case "PING":
+			if err := c.write("PONG\r\n"); err != nil {
+				return err
+			}
+		case "PONG":
+			select {
+			case c.pong <- struct{}{}:
+			default:
+			}
+		case "-ERR":
+			// Protocol errors close the connection; permission errors
+			// only fail the operation, which then times out
+			if !strings.Contains(strings.ToLower(rest), "permissions violation") {
+				return fmt.Errorf("server error: %s", strings.Trim(rest, "'"))
+			}
+		case "+OK", "INFO":
+		default:
+			return fmt.Errorf("unexpected operation %q", op)
+		}
+	}
+}
+
+// readNATSMsg reads the payload of a MSG (subject sid [reply] size) or
+// HMSG (subject sid [reply] header-size total-size) whose arguments are args.
+func readNATSMsg(r *bufio.Reader, headers bool, args []string) (*natsMsg, error) {
+	sizes := 1
+	if headers {
+		sizes = 2
+	}
+	if len(args) != 2+sizes && len(args) != 3+sizes {
+		return nil, fmt.Errorf("malformed message arguments %q", strings.Join(args, " "))
+	}
+	msg := &natsMsg{subject: args[0]}
+	if len(args) == 3+sizes {
+		msg.reply = args[2]
+	}
+
+	total, err := strconv.Atoi(args[len(args)-1])
+	if err != nil || total < 0 {
+		return nil, fmt.Errorf("malformed message size %q", args[len(args)-1])
+	}
+	headerSize := 0
+	if headers {
+		headerSize, err = strconv.Atoi(args[len(args)-2])
+		if err != nil || headerSize < 0 || headerSize > total {
+			return nil, fmt.Errorf("malformed header size %q", args[len(args)-2])
+		}
+	}
+
+	buf := make([]byte, total+2) // Payload plus trailing CRLF
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	if headers {
+		// "NATS/1.0 404 No Messages\r\n" followed by headers
+		status, _, _ := strings.Cut(string(buf[:headerSize]), "\r\n")
+		fields := strings.SplitN(status, " ", 3)
+		if len(fields) > 1 {
+			msg.status = fields[1]
+		}
+		if len(fields) > 2 {
+			msg.description = fields[2]
+		}
+	}
+	msg.data = buf[headerSize:total]
+	return msg, nil
+}
+
+func (c *natsConn) close() error {
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
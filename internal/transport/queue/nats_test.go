@@ -0,0 +1,373 @@
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeJetStream is a NATS server speaking just enough JetStream for the
+// transport: consumer info/create, pull requests, acks and publish acks.
+type fakeJetStream struct {
+	ln net.Listener
+
+	mu        sync.Mutex
+	connect   map[string]interface{}
+	created   map[string]interface{} // Consumer config once created
+	queue     [][]byte
+	acks      []string            // Ack verbs in order
+	published map[string][][]byte // Non-API publishes by subject
+	streams   map[string]bool     // Subjects captured by a stream
+	inflight  map[string][]byte   // Ack subject -> payload
+	ackSeq    int
+}
+
+func newFakeJetStream(t *testing.T, messages ...string) *fakeJetStream {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeJetStream{
+		ln:        ln,
+		published: make(map[string][][]byte),
+		streams:   make(map[string]bool),
+		inflight:  make(map[string][]byte),
+	}
+	for _, m := range messages {
+		s.queue = append(s.queue, []byte(m))
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeJetStream) url(userinfo string) *url.URL {
+	u, _ := url.Parse("nats://" + userinfo + s.ln.Addr().String())
+	return u
+}
+
+// fakeWriter writes server operations to one connection.
+type fakeWriter struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (w *fakeWriter) msg(subject, reply string, data []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if reply != "" {
+		fmt.Fprintf(w.conn, "MSG %s 1 %s %d\r\n%s\r\n", subject, reply, len(data), data)
+	} else {
+		fmt.Fprintf(w.conn, "MSG %s 1 %d\r\n%s\r\n", subject, len(data), data)
+	}
+}
+
+func (w *fakeWriter) status(subject, code, text string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	hdr := "NATS/1.0 " + code + " " + text + "\r\n\r\n"
+	fmt.Fprintf(w.conn, "HMSG %s 1 %d %d\r\n%s\r\n", subject, len(hdr), len(hdr), hdr)
+}
+
+func (w *fakeWriter) line(s string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	io.WriteString(w.conn, s+"\r\n")
+}
+
+func (s *fakeJetStream) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := &fakeWriter{conn: conn}
+
+	w.line(`INFO {"server_id":"fake","headers":true,"jetstream":true}`)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		op, rest, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		switch op {
+		case "CONNECT":
+			s.mu.Lock()
+			json.Unmarshal([]byte(rest), &s.connect)
+			s.mu.Unlock()
+		case "PING":
+			w.line("PONG")
+		case "PUB":
+			args := strings.Fields(rest)
+			size, _ := strconv.Atoi(args[len(args)-1])
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return
+			}
+			reply := ""
+			if len(args) == 3 {
+				reply = args[1]
+			}
+			s.handle(w, args[0], reply, data[:size])
+		}
+	}
+}
+
+func (s *fakeJetStream) handle(w *fakeWriter, subject, reply string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case subject == "$JS.API.CONSUMER.INFO.INGEST.api":
+		if s.created == nil {
+			w.msg(reply, "", []byte(`{"error":{"code":404,"err_code":10014,"description":"consumer not found"}}`))
+			return
+		}
+		w.msg(reply, "", []byte(fmt.Sprintf(`{"num_pending":%d,"num_ack_pending":%d}`, len(s.queue), len(s.inflight))))
+	case strings.HasPrefix(subject, "$JS.API.CONSUMER.INFO."):
+		w.msg(reply, "", []byte(`{"error":{"code":404,"err_code":10059,"description":"stream not found"}}`))
+	case subject == "$JS.API.CONSUMER.DURABLE.CREATE.INGEST.api":
+		var req struct {
+			Config map[string]interface{} `json:"config"`
+		}
+		json.Unmarshal(data, &req)
+		s.created = req.Config
+		w.msg(reply, "", []byte(`{"name":"api"}`))
+	case subject == "$JS.API.CONSUMER.MSG.NEXT.INGEST.api":
+		if len(s.queue) == 0 {
+			w.status(reply, "404", "No Messages")
+			return
+		}
+		msg := s.queue[0]
+		s.queue = s.queue[1:]
+		s.ackSeq++
+		ackSubject := fmt.Sprintf("$JS.ACK.INGEST.api.1.%d", s.ackSeq)
+		s.inflight[ackSubject] = msg
+		w.msg(reply, ackSubject, msg)
+	case strings.HasPrefix(subject, "$JS.ACK."):
+		verb := string(data)
+		s.acks = append(s.acks, verb)
+		if strings.HasPrefix(verb, "-NAK") {
+			s.queue = append(s.queue, s.inflight[subject])
+		}
+		delete(s.inflight, subject)
+	default:
+		s.published[subject] = append(s.published[subject], data)
+		if reply == "" {
+			return
+		}
+		if s.streams[subject] {
+			w.msg(reply, "", []byte(`{"stream":"DEAD","seq":1}`))
+		} else {
+			w.status(reply, "503", "")
+		}
+	}
+}
+
+// ackVerbs returns the ack verbs received so far. Acks are fire-and-forget,
+// so a request round trip first lets them land.
+func (s *fakeJetStream) ackVerbs(t *testing.T, tr *natsTransport) []string {
+	t.Helper()
+	if _, err := tr.info(context.Background()); err != nil {
+		t.Fatalf("info: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.acks...)
+}
+
+func newTestNATSTransport(t *testing.T, s *fakeJetStream) *natsTransport {
+	t.Helper()
+	tr, err := newNATSTransport(s.url("user:secret@"), "ingest.inventory", "ingest.inventory.dead", "INGEST", "api")
+	if err != nil {
+		t.Fatalf("newNATSTransport: %v", err)
+	}
+	t.Cleanup(func() { tr.close() })
+	return tr
+}
+
+func TestNATSTransportCreatesDurableConsumer(t *testing.T) {
+	s := newFakeJetStream(t)
+	newTestNATSTransport(t, s)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.connect["user"] != "user" || s.connect["pass"] != "secret" {
+		t.Errorf("CONNECT credentials = %v/%v, want user/secret", s.connect["user"], s.connect["pass"])
+	}
+	if s.connect["headers"] != true {
+		t.Errorf("CONNECT without headers: %v", s.connect)
+	}
+	if s.created == nil {
+		t.Fatal("durable consumer not created")
+	}
+	for key, want := range map[string]interface{}{
+		"durable_name":   "api",
+		"ack_policy":     "explicit",
+		"filter_subject": "ingest.inventory",
+	} {
+		if s.created[key] != want {
+			t.Errorf("consumer %s = %v, want %v", key, s.created[key], want)
+		}
+	}
+}
+
+func TestNATSTransportRejectsMissingStream(t *testing.T) {
+	s := newFakeJetStream(t)
+	_, err := newNATSTransport(s.url(""), "ingest.inventory", "", "NOPE", "api")
+	if err == nil || !strings.Contains(err.Error(), `"NOPE" not found`) {
+		t.Errorf("err = %v, want stream not found", err)
+	}
+}
+
+func TestNATSTransportAckAndRequeue(t *testing.T) {
+	s := newFakeJetStream(t, `{"n":1}`, `{"n":2}`)
+	tr := newTestNATSTransport(t, s)
+	ctx := context.Background()
+
+	first, err := tr.next(ctx)
+	if err != nil || first == nil || string(first.payload) != `{"n":1}` {
+		t.Fatalf("next = %v, %v; want message 1", first, err)
+	}
+	if err := tr.ack(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+
+	second, _ := tr.next(ctx)
+	if err := tr.requeue(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+	again, err := tr.next(ctx)
+	if err != nil || again == nil || string(again.payload) != `{"n":2}` {
+		t.Fatalf("after NAK next = %v, %v; want message 2 redelivered", again, err)
+	}
+	tr.ack(ctx, again)
+
+	if d, err := tr.next(ctx); d != nil || err != nil {
+		t.Errorf("empty stream: next = %v, %v; want nil, nil", d, err)
+	}
+
+	verbs := s.ackVerbs(t, tr)
+	if len(verbs) != 3 || verbs[0] != "+ACK" || !strings.HasPrefix(verbs[1], "-NAK") || verbs[2] != "+ACK" {
+		t.Errorf("ack verbs = %q, want +ACK, -NAK, +ACK", verbs)
+	}
+
+	stats := map[string]interface{}{}
+	tr.stats(ctx, stats)
+	if stats["lag"] != int64(0) || stats["in_flight"] != int64(0) {
+		t.Errorf("stats = %v, want no lag or in-flight messages", stats)
+	}
+}
+
+func TestNATSTransportDeadLetter(t *testing.T) {
+	for _, stored := range []bool{true, false} {
+		s := newFakeJetStream(t, `not json`)
+		s.streams["ingest.inventory.dead"] = stored
+		tr := newTestNATSTransport(t, s)
+
+		// Through the consumer, so a malformed message is dead-lettered
+		var logs bytes.Buffer
+		c := &Consumer{queue: tr, subject: tr.subject, deadLetter: tr.deadLetterSubject,
+			logger: slog.New(slog.NewTextHandler(&logs, nil))}
+		d, err := tr.next(context.Background())
+		if err != nil || d == nil {
+			t.Fatalf("next = %v, %v", d, err)
+		}
+		c.handle(context.Background(), d)
+
+		// Without a stream to keep it, the message stays unacked for redelivery
+		verbs := s.ackVerbs(t, tr)
+		if stored && (len(verbs) != 1 || verbs[0] != "+TERM") {
+			t.Errorf("stored: ack verbs = %q, want +TERM", verbs)
+		}
+		if !stored && len(verbs) != 0 {
+			t.Errorf("not stored: ack verbs = %q, want none", verbs)
+		}
+		if failed := strings.Contains(logs.String(), "Dead-lettering failed"); failed == stored {
+			t.Errorf("stored=%v: logs = %q", stored, logs.String())
+		}
+		s.mu.Lock()
+		dead := s.published["ingest.inventory.dead"]
+		s.mu.Unlock()
+		if len(dead) != 1 {
+			t.Fatalf("stored=%v: %d dead-letter publishes, want 1", stored, len(dead))
+		}
+		var entry map[string]interface{}
+		json.Unmarshal(dead[0], &entry)
+		if entry["message"] != "not json" || entry["error"] == "" {
+			t.Errorf("stored=%v: dead-letter entry = %s", stored, dead[0])
+		}
+		if c.deadLettered.Load() != 1 {
+			t.Errorf("stored=%v: deadLettered = %d, want 1", stored, c.deadLettered.Load())
+		}
+	}
+}
+
+func TestNATSTransportReconnects(t *testing.T) {
+	s := newFakeJetStream(t, `{"n":1}`)
+	tr := newTestNATSTransport(t, s)
+
+	tr.mu.Lock()
+	tr.conn.conn.Close() // Drop the connection under the transport
+	old := tr.conn
+	tr.mu.Unlock()
+	<-old.done
+
+	d, err := tr.next(context.Background())
+	if err != nil || d == nil {
+		t.Fatalf("next after disconnect = %v, %v; want the message", d, err)
+	}
+}
+
+func TestReadNATSMsg(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers bool
+		args    string
+		payload string
+		want    natsMsg
+	}{
+		{"msg", false, "inbox.1 1 5", "hello",
+			natsMsg{subject: "inbox.1", data: []byte("hello")}},
+		{"msg with reply", false, "inbox.1 1 $JS.ACK.x 2", "{}",
+			natsMsg{subject: "inbox.1", reply: "$JS.ACK.x", data: []byte("{}")}},
+		{"status", true, "inbox.2 1 32 32", "NATS/1.0 408 Request Timeout\r\n\r\n",
+			natsMsg{subject: "inbox.2", status: "408", description: "Request Timeout", data: []byte{}}},
+		{"headers and payload", true, "inbox.3 1 18 20", "NATS/1.0\r\nA: b\r\n\r\nhi",
+			natsMsg{subject: "inbox.3", data: []byte("hi")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tt.payload + "\r\n"))
+			msg, err := readNATSMsg(r, tt.headers, strings.Fields(tt.args))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.subject != tt.want.subject || msg.reply != tt.want.reply || msg.status != tt.want.status ||
+				msg.description != tt.want.description || string(msg.data) != string(tt.want.data) {
+				t.Errorf("got %+v, want %+v", *msg, tt.want)
+			}
+		})
+	}
+
+	for _, args := range []string{"inbox 1", "inbox 1 x", "inbox 1 a b c d"} {
+		if _, err := readNATSMsg(bufio.NewReader(strings.NewReader("")), false, strings.Fields(args)); err == nil {
+			t.Errorf("readNATSMsg(%q) accepted malformed arguments", args)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// aliveTTL is how long a consumer instance counts as running after its
+	// last heartbeat; its processing list is recovered once it lapses.
+	aliveTTL = 30 * time.Second
+
+	// aliveInterval is how often a running consumer renews its heartbeat.
+	aliveInterval = 10 * time.Second
+
+	// recoverInterval is how often a consumer looks for processing lists of
+	// stopped instances.
+	recoverInterval = time.Minute
+)
+
+// redisTransport consumes a Redis list with the reliable-queue pattern:
+// each message is atomically moved to this instance's processing list and
+// only removed from it once handled. Processing lists are keyed by a
+// per-process instance ID kept alive by a heartbeat key, so instances on
+// one host don't share a list; the list of an instance whose heartbeat
+// lapsed is pushed back onto the queue by whichever instance notices.
+type redisTransport struct {
+	client            *redis.Client
+	subject           string
+	deadLetterSubject string
+	instance          string
+	logger            *slog.Logger
+
+	// Only touched by the consumer loop
+	aliveAt     time.Time
+	recoveredAt time.Time
+}
+
+// newRedisTransport connects to the Redis server of rawURL.
+func newRedisTransport(rawURL, subject, deadLetter string, logger *slog.Logger) (*redisTransport, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid queue URL: %w", err)
+	}
+	// Blocking pops must not trip the client's read timeout
+	opts.ReadTimeout = popTimeout + 5*time.Second
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &redisTransport{
+		client:            client,
+		subject:           subject,
+		deadLetterSubject: deadLetter,
+		instance:          newInstanceID(),
+		logger:            logger,
+	}, nil
+}
+
+// newInstanceID returns an ID unique to this process: host, PID and a
+// random suffix, since PIDs repeat across restarts and containers.
+func newInstanceID() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// processingKey is the processing list of a consumer instance.
+func (t *redisTransport) processingKey(instance string) string {
+	return t.subject + ":processing:" + instance
+}
+
+// aliveKey is the heartbeat key of a consumer instance.
+func (t *redisTransport) aliveKey(instance string) string {
+	return t.subject + ":consumer:" + instance
+}
+
+// heartbeat renews this instance's alive key.
+func (t *redisTransport) heartbeat(ctx context.Context) error {
+	if err := t.client.Set(ctx, t.aliveKey(t.instance), time.Now().Unix(), aliveTTL).Err(); err != nil {
+		return err
+	}
+	t.aliveAt = time.Now()
+	return nil
+}
+
+// recover pushes the processing lists of instances without a heartbeat
+// back onto the queue, including lists of older versions keyed by
+// hostname. A message moves once even when instances recover together.
+func (t *redisTransport) recover(ctx context.Context) (int, error) {
+	if err := t.heartbeat(ctx); err != nil {
+		return 0, err
+	}
+	t.recoveredAt = time.Now()
+
+	prefix := t.processingKey("")
+	recovered := 0
+	iter := t.client.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		instance := strings.TrimPrefix(iter.Val(), prefix)
+		if instance == t.instance {
+			continue
+		}
+		alive, err := t.client.Exists(ctx, t.aliveKey(instance)).Result()
+		if err != nil {
+			return recovered, err
+		}
+		if alive > 0 {
+			continue
+		}
+		for {
+			err := t.client.RPopLPush(ctx, iter.Val(), t.subject).Err()
+			if err == redis.Nil {
+				break
+			}
+			if err != nil {
+				return recovered, err
+			}
+			recovered++
+		}
+	}
+	return recovered, iter.Err()
+}
+
+// next moves the next message onto this instance's processing list,
+// renewing the heartbeat and recovering stopped instances' lists on the way.
+func (t *redisTransport) next(ctx context.Context) (*delivery, error) {
+	if time.Since(t.aliveAt) >= aliveInterval {
+		if err := t.heartbeat(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if time.Since(t.recoveredAt) >= recoverInterval {
+		if n, err := t.recover(ctx); err != nil {
+			return nil, err
+		} else if n > 0 {
+			t.logger.InfoContext(ctx, "Requeued in-flight messages of stopped consumers", "messages", n)
+		}
+	}
+
+	payload, err := t.client.BRPopLPush(ctx, t.subject, t.processingKey(t.instance), popTimeout).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery{payload: []byte(payload)}, nil
+}
+
+// ack removes a handled message from the processing list.
+func (t *redisTransport) ack(ctx context.Context, d *delivery) error {
+	return t.client.LRem(ctx, t.processingKey(t.instance), 1, d.payload).Err()
+}
+
+// requeue moves a message from the processing list back onto the queue.
+func (t *redisTransport) requeue(ctx context.Context, d *delivery) error {
+	pipe := t.client.TxPipeline()
+	pipe.LRem(ctx, t.processingKey(t.instance), 1, d.payload)
+	pipe.RPush(ctx, t.subject, d.payload)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// deadLetter moves a message from the processing list to the dead-letter list.
+func (t *redisTransport) deadLetter(ctx context.Context, d *delivery, entry []byte) error {
+	pipe := t.client.TxPipeline()
+	pipe.LPush(ctx, t.deadLetterSubject, entry)
+	pipe.LRem(ctx, t.processingKey(t.instance), 1, d.payload)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// stats reports the queue and dead-letter list lengths.
+func (t *redisTransport) stats(ctx context.Context, into map[string]interface{}) {
+	into["instance"] = t.instance
+	if lag, err := t.client.LLen(ctx, t.subject).Result(); err == nil {
+		into["lag"] = lag
+	} else {
+		into["error"] = err.Error()
+	}
+	if dead, err := t.client.LLen(ctx, t.deadLetterSubject).Result(); err == nil {
+		into["dead_letter_depth"] = dead
+	}
+}
+
+// close drops the heartbeat, so the (empty) processing list isn't waited
+// on, and disconnects.
+func (t *redisTransport) close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	t.client.Del(ctx, t.aliveKey(t.instance))
+	return t.client.Close()
+}
@@ -0,0 +1,22 @@
+package queue
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProcessingListIsPerProcess(t *testing.T) {
+	a := &redisTransport{subject: "ingest", instance: newInstanceID()}
+	b := &redisTransport{subject: "ingest", instance: newInstanceID()}
+
+	if a.processingKey(a.instance) == b.processingKey(b.instance) {
+		t.Fatalf("two consumers on one host share processing list %q", a.processingKey(a.instance))
+	}
+	prefix := a.processingKey("")
+	if prefix != "ingest:processing:" || !strings.HasPrefix(a.processingKey(a.instance), prefix) {
+		t.Errorf("processing key %q doesn't start with %q", a.processingKey(a.instance), prefix)
+	}
+	if a.aliveKey(a.instance) == a.processingKey(a.instance) || strings.HasPrefix(a.aliveKey(a.instance), prefix) {
+		t.Errorf("alive key %q collides with processing lists", a.aliveKey(a.instance))
+	}
+}
@@ -10,6 +10,12 @@
     API Endpoint:
     - POST /api/v1/inventory/{roblox_user_id}/sync
     
+    Sync semantics (SetAPIVersion):
+    - v2 (default): sends Accept-Version: 2. The API answers 200 once the
+      inventory is persisted and 202 while it is only buffered; both count
+      as success. GetLastSyncStatus() tells which it was.
+    - v1: always 200 "synced", buffered or not
+    
     Usage:
         local InventorySync = loadstring(...)()
         InventorySync.Init()
@@ -28,6 +34,7 @@ local InventorySync = {}
 local Config = {
     APIBase = "https://sandbox.vinzhub.com/api/v1",
     Token = nil,            -- Session token (set via SetToken())
+    APIVersion = 2,         -- Sync semantics: 2 = 200 persisted / 202 buffered, 1 = always 200
     SyncInterval = 300,     -- Seconds between auto-sync (5 min to reduce DB load)
     Debug = false,          -- Enable debug logging
     FetchIcons = true,      -- Fetch fish icons from Roblox API
@@ -83,6 +90,7 @@ end
 local SyncRunning = false
 local AutoSyncStarted = false  -- Prevent multiple auto-sync loops
 local LastSync = 0
+local LastSyncStatus = nil  -- Decoded data of the last successful sync response
 local IconCache = {}  -- Cache for icon URLs to avoid repeated API calls
 
 
@@ -137,6 +145,9 @@ local function Request(method, endpoint, body)
         ["Content-Type"] = "application/json",
         ["X-Token"] = Config.Token  -- Use session token instead of API key
     }
+    if Config.APIVersion >= 2 then
+        headers["Accept-Version"] = tostring(Config.APIVersion)
+    end
 
     
     local requestData = {
@@ -168,7 +179,8 @@ local function Request(method, endpoint, body)
         print("[InventorySync] Response:", response.StatusCode) 
     end
     
-    return response.StatusCode == 200, response
+    -- 202: accepted into the write buffer (v2 only), persisted on the next flush
+    return response.StatusCode == 200 or response.StatusCode == 202, response
 end
 
 --------------------------------------------------------------------------------
@@ -578,8 +590,21 @@ function InventorySync.Sync()
     SyncRunning = false
     LastSync = os.time()
     
+    if success then
+        local ok, decoded = pcall(function()
+            return HttpService:JSONDecode(response.Body)
+        end)
+        LastSyncStatus = ok and decoded and decoded.data or nil
+    end
+    
     if Config.Debug then
-        print("[InventorySync] Sync:", success and "complete" or "failed")
+        local persistence = LastSyncStatus and LastSyncStatus.persistence
+        if success and persistence and persistence.buffered then
+            print("[InventorySync] Sync: accepted, persisted within",
+                math.ceil((persistence.expected_flush_within_ms or 0) / 1000), "s")
+        else
+            print("[InventorySync] Sync:", success and "complete" or "failed")
+        end
     end
     
     return success, response
@@ -614,6 +639,13 @@ function InventorySync.GetLastSync()
     return LastSync
 end
 
+-- Returns the data of the last successful sync response: status is
+-- "accepted" (buffered, v2 only) or "synced"; on v2, persistence holds
+-- buffered, queue_depth, expected_flush_within_ms and expected_persisted_by
+function InventorySync.GetLastSyncStatus()
+    return LastSyncStatus
+end
+
 --------------------------------------------------------------------------------
 -- CONFIGURATION API
 --------------------------------------------------------------------------------
@@ -703,6 +735,11 @@ function InventorySync.SetAPIBase(url)
     Config.APIBase = url
 end
 
+-- 2 (default) or 1; v1 answers 200 for buffered syncs too
+function InventorySync.SetAPIVersion(version)
+    Config.APIVersion = version
+end
+
 function InventorySync.SetSyncInterval(seconds)
     Config.SyncInterval = seconds
 end
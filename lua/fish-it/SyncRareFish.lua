@@ -227,7 +227,9 @@ local function syncToAPI()
     print("========================================")
     print(string.format("📥 Response Status: %d", response.StatusCode))
     
-    if response.StatusCode == 200 then
+    -- v1 route without Accept-Version always answers 200; 202 (buffered)
+    -- only comes with v2 semantics, and is a success too
+    if response.StatusCode == 200 or response.StatusCode == 202 then
         print("✅ SYNC SUCCESSFUL!")
         print("========================================")
         print("📊 SUMMARY:")
@@ -50,6 +50,15 @@ func (e *Error) ToJSON() []byte {
 
 // Common error constructors
 
+// New creates an error with a custom status code and error code.
+func New(statusCode int, code, message string) *Error {
+	return &Error{
+		StatusCode: statusCode,
+		Code:       code,
+		Message:    message,
+	}
+}
+
 // BadRequest creates a 400 Bad Request error.
 func BadRequest(message string) *Error {
 	return &Error{
@@ -114,6 +123,30 @@ func Conflict(message string) *Error {
 	}
 }
 
+// PayloadTooLarge creates a 413 Payload Too Large error.
+func PayloadTooLarge(message string) *Error {
+	if message == "" {
+		message = "Request body too large"
+	}
+	return &Error{
+		StatusCode: http.StatusRequestEntityTooLarge,
+		Code:       "PAYLOAD_TOO_LARGE",
+		Message:    message,
+	}
+}
+
+// TooManyRequests creates a 429 Too Many Requests error.
+func TooManyRequests(message string) *Error {
+	if message == "" {
+		message = "Too many requests"
+	}
+	return &Error{
+		StatusCode: http.StatusTooManyRequests,
+		Code:       "RATE_LIMITED",
+		Message:    message,
+	}
+}
+
 // InternalError creates a 500 Internal Server Error.
 func InternalError(message string) *Error {
 	if message == "" {
@@ -126,6 +159,18 @@ func InternalError(message string) *Error {
 	}
 }
 
+// NotImplemented creates a 501 error for features this deployment lacks.
+func NotImplemented(message string) *Error {
+	if message == "" {
+		message = "Not implemented"
+	}
+	return &Error{
+		StatusCode: http.StatusNotImplemented,
+		Code:       "NOT_IMPLEMENTED",
+		Message:    message,
+	}
+}
+
 // ServiceUnavailable creates a 503 Service Unavailable error.
 func ServiceUnavailable(message string) *Error {
 	if message == "" {
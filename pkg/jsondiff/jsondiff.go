@@ -0,0 +1,175 @@
+// Package jsondiff computes structural differences between two JSON documents.
+package jsondiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Operation describes how a value changed between two documents.
+type Operation string
+
+const (
+	OpAdded   Operation = "added"
+	OpRemoved Operation = "removed"
+	OpChanged Operation = "changed"
+)
+
+// DefaultMaxChanges caps the number of changes collected by Compare.
+const DefaultMaxChanges = 500
+
+// Change is a single difference, addressed by a JSON Pointer (RFC 6901).
+type Change struct {
+	Path string      `json:"path"`
+	Op   Operation   `json:"op"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// Result holds the outcome of a comparison.
+type Result struct {
+	Equal     bool     `json:"equal"`
+	Changes   []Change `json:"changes"`
+	Truncated bool     `json:"truncated,omitempty"`
+}
+
+// Options controls how Compare walks the documents.
+type Options struct {
+	// MaxDepth stops descending below this depth and reports the whole
+	// subtree as changed instead. Zero means unlimited.
+	MaxDepth int
+	// MaxChanges caps the number of reported changes. Zero uses DefaultMaxChanges.
+	MaxChanges int
+	// OmitValues drops Old/New from the changes, leaving only paths.
+	OmitValues bool
+}
+
+// Compare parses two JSON documents and returns their structural diff.
+func Compare(a, b []byte, opts Options) (*Result, error) {
+	var left, right interface{}
+	if err := json.Unmarshal(a, &left); err != nil {
+		return nil, fmt.Errorf("failed to parse left document: %w", err)
+	}
+	if err := json.Unmarshal(b, &right); err != nil {
+		return nil, fmt.Errorf("failed to parse right document: %w", err)
+	}
+	return CompareValues(left, right, opts), nil
+}
+
+// CompareValues diffs two already-decoded JSON values.
+func CompareValues(left, right interface{}, opts Options) *Result {
+	if opts.MaxChanges <= 0 {
+		opts.MaxChanges = DefaultMaxChanges
+	}
+	w := &walker{opts: opts, changes: []Change{}}
+	w.walk("", left, right, 0)
+	return &Result{
+		Equal:     len(w.changes) == 0 && !w.truncated,
+		Changes:   w.changes,
+		Truncated: w.truncated,
+	}
+}
+
+type walker struct {
+	opts      Options
+	changes   []Change
+	truncated bool
+}
+
+func (w *walker) add(path string, op Operation, oldVal, newVal interface{}) {
+	if len(w.changes) >= w.opts.MaxChanges {
+		w.truncated = true
+		return
+	}
+	if w.opts.OmitValues {
+		oldVal, newVal = nil, nil
+	}
+	if path == "" {
+		path = "/"
+	}
+	w.changes = append(w.changes, Change{Path: path, Op: op, Old: oldVal, New: newVal})
+}
+
+func (w *walker) walk(path string, left, right interface{}, depth int) {
+	if w.truncated {
+		return
+	}
+	if w.opts.MaxDepth > 0 && depth >= w.opts.MaxDepth {
+		if !reflect.DeepEqual(left, right) {
+			w.add(path, OpChanged, left, right)
+		}
+		return
+	}
+
+	switch l := left.(type) {
+	case map[string]interface{}:
+		r, ok := right.(map[string]interface{})
+		if !ok {
+			w.add(path, OpChanged, left, right)
+			return
+		}
+		for _, key := range unionKeys(l, r) {
+			lv, inLeft := l[key]
+			rv, inRight := r[key]
+			child := path + "/" + escape(key)
+			switch {
+			case inLeft && !inRight:
+				w.add(child, OpRemoved, lv, nil)
+			case !inLeft && inRight:
+				w.add(child, OpAdded, nil, rv)
+			default:
+				w.walk(child, lv, rv, depth+1)
+			}
+		}
+	case []interface{}:
+		r, ok := right.([]interface{})
+		if !ok {
+			w.add(path, OpChanged, left, right)
+			return
+		}
+		n := len(l)
+		if len(r) > n {
+			n = len(r)
+		}
+		for i := 0; i < n; i++ {
+			child := path + "/" + strconv.Itoa(i)
+			switch {
+			case i >= len(r):
+				w.add(child, OpRemoved, l[i], nil)
+			case i >= len(l):
+				w.add(child, OpAdded, nil, r[i])
+			default:
+				w.walk(child, l[i], r[i], depth+1)
+			}
+		}
+	default:
+		if !reflect.DeepEqual(left, right) {
+			w.add(path, OpChanged, left, right)
+		}
+	}
+}
+
+// unionKeys returns the sorted keys of both objects so output is deterministic.
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// escape encodes a key as a JSON Pointer reference token.
+func escape(key string) string {
+	key = strings.ReplaceAll(key, "~", "~0")
+	return strings.ReplaceAll(key, "/", "~1")
+}